@@ -1,13 +0,0 @@
-module bugsbyte/flight-info
-
-go 1.24.3
-
-require (
-	github.com/makiuchi-d/gozxing v0.1.1
-	golang.org/x/image v0.36.0
-)
-
-require (
-	golang.org/x/text v0.34.0 // indirect
-	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
-)
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"bugsbyte/flight-info/internal/refdata"
+)
+
+// ----------------------
+// DATA: AIRLINE LOOKUPS
+// ----------------------
+
+// Airline is an entry of the airline dataset (see refdata.Airline).
+type Airline = refdata.Airline
+
+// airlineLogoTemplate is AIRLINE_LOGO_URL, e.g.
+// "https://example.com/logos/{iata}.png".
+var airlineLogoTemplate = defaultConfig.AirlineLogoURL
+
+// airlineLogoURL expands airlineLogoTemplate for a; "" when it is unset.
+func airlineLogoURL(a Airline) string {
+	if airlineLogoTemplate == "" {
+		return ""
+	}
+	return strings.NewReplacer("{iata}", url.PathEscape(a.IATA), "{icao}", url.PathEscape(a.ICAO)).Replace(airlineLogoTemplate)
+}
+
+// AirlineResponse is the body of GET /airlines/{code}.
+type AirlineResponse struct {
+	Airline
+	LogoURL string `json:"logo_url,omitempty"`
+}
+
+func handleAirline(w http.ResponseWriter, r *http.Request) {
+	a, ok := refdata.LookupAirline(r.PathValue("code"))
+	if !ok {
+		httpError(w, "Airline not found", http.StatusNotFound)
+		return
+	}
+
+	// The logo URL template is part of the response, so of its ETag too.
+	etag := datasetETag(refdata.AirlinesJSON, []byte(airlineLogoTemplate))
+	serveLookup(w, r, etag, AirlineResponse{a, airlineLogoURL(a)})
+}
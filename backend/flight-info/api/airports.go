@@ -0,0 +1,188 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata" // zones must resolve in minimal containers too
+
+	"golang.org/x/text/language"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/internal/refdata"
+)
+
+// ----------------------
+// DATA: AIRPORT LOOKUPS
+// ----------------------
+
+// Airport is an entry of the airport dataset (see refdata.Airport).
+type Airport = refdata.Airport
+
+// The dataset is embedded at build time, so a city name in a language
+// enrichment can't negotiate is a programming error and panics at startup.
+func init() {
+	for _, a := range refdata.Airports() {
+		for lang := range a.Cities {
+			if !slices.ContainsFunc(displayLangs[1:], func(t language.Tag) bool { return t.String() == lang }) {
+				panic("invalid embedded airport dataset: " + a.Code + " has a city name in " + lang + ", not a display language")
+			}
+		}
+	}
+}
+
+// airportsETag is the ETag of the airport lookups.
+var airportsETag = datasetETag(refdata.AirportsJSON)
+
+func handleAirport(w http.ResponseWriter, r *http.Request) {
+	a, ok := refdata.LookupAirport(r.PathValue("code"))
+	if !ok {
+		httpError(w, "Airport not found", http.StatusNotFound)
+		return
+	}
+	serveLookup(w, r, airportsETag, a)
+}
+
+// maxAirportCodes caps the codes of one GET /airports.
+const maxAirportCodes = 100
+
+// AirportList is the body of GET /airports: the airports found, in the
+// order asked for, and the codes that aren't in the dataset.
+type AirportList struct {
+	Airports []Airport `json:"airports"`
+	NotFound []string  `json:"not_found,omitempty"`
+}
+
+// handleAirports looks up the comma-separated codes of ?codes=, such as
+// every airport of a trip. Unknown codes are listed, not an error.
+func handleAirports(w http.ResponseWriter, r *http.Request) {
+	var codes []string
+	for _, c := range strings.Split(r.URL.Query().Get("codes"), ",") {
+		if c = strings.ToUpper(strings.TrimSpace(c)); c != "" && !slices.Contains(codes, c) {
+			codes = append(codes, c)
+		}
+	}
+	switch {
+	case len(codes) == 0:
+		writeError(w, http.StatusBadRequest, invalidParam("codes", "codes is required: comma-separated IATA codes, e.g. LIS,FRA"))
+		return
+	case len(codes) > maxAirportCodes:
+		writeError(w, http.StatusBadRequest, invalidParam("codes", fmt.Sprintf("At most %d codes per request", maxAirportCodes)))
+		return
+	}
+	list := AirportList{Airports: []Airport{}}
+	for _, c := range codes {
+		if a, ok := refdata.LookupAirport(c); ok {
+			list.Airports = append(list.Airports, a)
+		} else {
+			list.NotFound = append(list.NotFound, c)
+		}
+	}
+	serveLookup(w, r, airportsETag, list)
+}
+
+// ----------------------
+// LOGIC: LOCALIZED CITY NAMES
+// ----------------------
+
+// Enrichment names the airports of a pass and their cities (see
+// bcbp.NameAirports), the cities in the language the request negotiated
+// (see matchLang) so a Portuguese app shows "Lisboa". Airport names are proper names and stay as the dataset
+// has them. A city without a name in that language falls back to English
+// on its own; the rest of the pass is unaffected.
+
+// displayLangs are the languages of Airport.Cities, English first as the
+// fallback.
+var displayLangs = []language.Tag{language.English, language.Portuguese, language.Spanish, language.German, language.French}
+
+var displayLangMatcher = language.NewMatcher(displayLangs)
+
+// airportLocation returns the departure airport's time zone, or nil when
+// the airport or its zone isn't in the dataset.
+func airportLocation(code string) *time.Location {
+	a, ok := refdata.LookupAirport(code)
+	if !ok || a.TZ == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(a.TZ)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+// ----------------------
+// LOGIC: LOCAL DEPARTURE TIMESTAMPS
+// ----------------------
+
+// resolveLocalTimes turns date_iso plus boarding/departure "HH:MM" into full
+// timestamps in the departure airport's zone. When the zone is unknown, or
+// the wall-clock time is skipped or repeated by a DST change, the timestamp
+// is left out with a warning: a countdown to the wrong instant is worse than
+// none.
+func resolveLocalTimes(p *bcbp.UnifiedBoardingPass) {
+	if p.DateISO == "" || (p.BoardingTime == "" && p.DepartureTime == "") {
+		return
+	}
+	loc := airportLocation(p.Departure)
+	if loc == nil {
+		// Train and bus stations aren't in the dataset; that's no surprise.
+		if !p.TransitMode.Ground() {
+			p.Warnings = append(p.Warnings, "departure airport "+p.Departure+" has no known time zone: local timestamps omitted")
+		}
+		return
+	}
+
+	resolve := func(clock, what string, local, utc *string) {
+		if clock == "" {
+			return
+		}
+		t, err := localInstant(p.DateISO, clock, loc)
+		if err != nil {
+			p.Warnings = append(p.Warnings, what+" "+p.DateISO+" "+clock+" "+err.Error()+" in "+loc.String()+": timestamp omitted")
+			return
+		}
+		*local = t.Format(time.RFC3339)
+		*utc = t.UTC().Format(time.RFC3339)
+	}
+	resolve(p.BoardingTime, "boarding time", &p.BoardingTimeLocal, &p.BoardingTimeUTC)
+	resolve(p.DepartureTime, "departure time", &p.DepartureTimeLocal, &p.DepartureTimeUTC)
+}
+
+var (
+	errSkippedTime   = errors.New("does not exist (DST gap)")
+	errAmbiguousTime = errors.New("occurs twice (DST overlap)")
+)
+
+// localInstant interprets an ISO date and "HH:MM" in loc. time.Date quietly
+// normalizes times in a DST gap and picks one of the two instants in an
+// overlap; both cases are reported instead.
+func localInstant(date, clock string, loc *time.Location) (time.Time, error) {
+	d, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return time.Time{}, err
+	}
+	c, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	t := time.Date(d.Year(), d.Month(), d.Day(), c.Hour(), c.Minute(), 0, 0, loc)
+	if t.Hour() != c.Hour() || t.Minute() != c.Minute() {
+		return time.Time{}, errSkippedTime
+	}
+	sameWall := func(u time.Time) bool {
+		u = u.In(loc)
+		return u.Day() == t.Day() && u.Hour() == t.Hour() && u.Minute() == t.Minute()
+	}
+	// Transitions shift clocks by at most an hour in every zone in use.
+	for _, shift := range []time.Duration{-time.Hour, -30 * time.Minute, 30 * time.Minute, time.Hour} {
+		if sameWall(t.Add(shift)) {
+			return time.Time{}, errAmbiguousTime
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// TEST HELPERS
+// ----------------------
+
+// setForTest sets one of the package's settings for the duration of t.
+func setForTest[T any](t *testing.T, p *T, v T) {
+	t.Helper()
+	old := *p
+	*p = v
+	t.Cleanup(func() { *p = old })
+}
+
+// testConfig is the configuration Serve would load from the environment
+// t has set.
+func testConfig(t *testing.T) Config {
+	t.Helper()
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// useTestStore turns persistence on for t, with a SQLite store in a
+// temporary directory.
+func useTestStore(t *testing.T) storage.Store {
+	t.Helper()
+	s, err := storage.Open("sqlite:" + filepath.Join(t.TempDir(), "passes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	setForTest(t, &passStore, s)
+	return s
+}
+
+// serve sends a request to the API and returns the response. header holds
+// name, value pairs.
+func serve(t *testing.T, h http.Handler, method, target string, body io.Reader, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, target, body)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// postBarcode posts text to /parse/barcode.
+func postBarcode(t *testing.T, h http.Handler, target, text string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := jsonBody(t, BarcodeRequest{Barcode: text})
+	return serve(t, h, http.MethodPost, target, bytes.NewReader(body), append([]string{"Content-Type", "application/json"}, header...)...)
+}
+
+// readFixture reads an input of the golden corpus.
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	return string(readFile(t, filepath.Join("..", "testdata", "golden", name)))
+}
+
+func readFile(t testing.TB, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func jsonBody(t testing.TB, v any) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
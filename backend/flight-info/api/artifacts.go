@@ -0,0 +1,350 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// PERSISTENCE: ORIGINAL UPLOADS (ARTIFACT_DIR)
+// ----------------------
+
+// For disputes it takes the photo or .pkpass a stored pass was parsed
+// from. With ARTIFACT_DIR set next to the pass store, the uploads to
+// /parse/barcode/image and /parse/pkpass are kept there, named by their
+// SHA-256, and the pass record references that hash; GET
+// /passes/{id}/artifact serves the file back. The directory has two
+// budgets: ARTIFACT_MAX_BYTES per file, above which an upload is not kept,
+// and ARTIFACT_DIR_MAX_BYTES in all, beyond which the least recently
+// stored or served files are evicted. A file's modification time is its
+// last use. Originals hold the passenger's data unredacted, so the server
+// refuses to start with ARTIFACT_DIR and REDACT_PII both set.
+
+// artifactStore is nil unless ARTIFACT_DIR is set.
+var artifactStore *ArtifactStore
+
+// Default budgets; the per-file one is the largest upload either endpoint
+// accepts.
+const (
+	defaultArtifactMaxBytes = maxPkPassUpload
+	defaultArtifactDirBytes = 1 << 30
+)
+
+// artifactGrace protects files from Prune for a while after they are
+// written, since their pass record may not reference them yet.
+const artifactGrace = time.Minute
+
+// pkpassContentType is the media type .pkpass files are served with.
+const pkpassContentType = "application/vnd.apple.pkpass"
+
+var errArtifactTooLarge = errors.New("artifact over ARTIFACT_MAX_BYTES")
+
+func init() {
+	describeMetric("artifacts_evicted_total", "counter", "Stored uploads deleted to keep ARTIFACT_DIR within ARTIFACT_DIR_MAX_BYTES.")
+	describeMetric("artifacts_pruned_total", "counter", "Stored uploads deleted because no pass references them any more.")
+	metric("artifacts_evicted_total")
+	metric("artifacts_pruned_total")
+}
+
+// ArtifactStore keeps uploads in a directory, one file per distinct
+// content.
+type ArtifactStore struct {
+	dir      string
+	maxSize  int64 // per file
+	maxTotal int64 // the whole directory
+	mu       sync.Mutex
+}
+
+// newArtifactStore creates dir if needed and evicts down to maxTotal, in
+// case the budget shrank since the last run.
+func newArtifactStore(dir string, maxSize, maxTotal int64) (*ArtifactStore, error) {
+	if maxSize <= 0 || maxTotal < maxSize {
+		return nil, errors.New("ARTIFACT_MAX_BYTES must be positive and at most ARTIFACT_DIR_MAX_BYTES")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	s := &ArtifactStore{dir: dir, maxSize: maxSize, maxTotal: maxTotal}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.evict(""); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// validArtifact reports whether sum is a hex SHA-256, as the file names
+// are.
+func validArtifact(sum string) bool {
+	b, err := hex.DecodeString(sum)
+	return err == nil && len(b) == sha256.Size
+}
+
+// Put stores size bytes of r under sum, their SHA-256 in hex, unless a
+// file with that content is already there, in which case it only counts
+// as used. Older files are evicted as needed to stay within budget.
+func (s *ArtifactStore) Put(r io.ReaderAt, size int64, sum string) error {
+	if size > s.maxSize {
+		return errArtifactTooLarge
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.dir, sum)
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		return nil
+	}
+	// Written under a dot name and renamed, so a crash never leaves a
+	// truncated file under a valid hash; evict and Prune skip dot names.
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, io.NewSectionReader(r, 0, size))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	_, err = s.evict(sum)
+	return err
+}
+
+// Open opens the file stored under sum and marks it used. The error
+// satisfies errors.Is(err, fs.ErrNotExist) once it has been evicted.
+func (s *ArtifactStore) Open(sum string) (*os.File, error) {
+	if !validArtifact(sum) {
+		return nil, os.ErrNotExist
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := filepath.Join(s.dir, sum)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return f, nil
+}
+
+// artifactFile is a stored file as evict and Prune see it.
+type artifactFile struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// files lists the stored files, least recently used first.
+func (s *ArtifactStore) files() ([]artifactFile, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []artifactFile
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed meanwhile
+		}
+		files = append(files, artifactFile{e.Name(), info.Size(), info.ModTime()})
+	}
+	slices.SortFunc(files, func(a, b artifactFile) int { return a.modTime.Compare(b.modTime) })
+	return files, nil
+}
+
+// evict deletes the least recently used files until the directory is
+// within maxTotal, never keep, and returns how many it deleted. s.mu must
+// be held.
+func (s *ArtifactStore) evict(keep string) (int, error) {
+	files, err := s.files()
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	n := 0
+	for _, f := range files {
+		if total <= s.maxTotal {
+			break
+		}
+		if f.name == keep {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, f.name)); err != nil {
+			return n, err
+		}
+		total -= f.size
+		n++
+	}
+	metric("artifacts_evicted_total").Add(int64(n))
+	if n > 0 {
+		slog.Info("Evicted stored uploads", "count", n, "dir_bytes", total, "max_bytes", s.maxTotal)
+	}
+	return n, nil
+}
+
+// Prune deletes the files no pass references, sparing those written in
+// the last artifactGrace, and returns how many it deleted.
+func (s *ArtifactStore) Prune(referenced map[string]bool, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, err := s.files()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, f := range files {
+		if referenced[f.name] || now.Sub(f.modTime) < artifactGrace {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, f.name)); err != nil {
+			return n, err
+		}
+		n++
+	}
+	metric("artifacts_pruned_total").Add(int64(n))
+	return n, nil
+}
+
+// pruneArtifacts drops the stored uploads of deleted passes. Failures are
+// logged; eviction catches up with what is left behind.
+func pruneArtifacts(ctx context.Context) {
+	if artifactStore == nil || passStore == nil {
+		return
+	}
+	refs, err := passStore.ArtifactRefs()
+	if err == nil {
+		var n int
+		n, err = artifactStore.Prune(refs, time.Now())
+		if n > 0 {
+			slog.InfoContext(ctx, "Deleted uploads of deleted passes", "count", n)
+		}
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Error pruning stored uploads", "err", err)
+	}
+}
+
+// artifact is the upload a request parsed, for storeArtifact.
+type artifact struct {
+	r           io.ReaderAt
+	size        int64
+	sum         [sha256.Size]byte
+	contentType string
+}
+
+type artifactKey struct{}
+
+// withArtifact attaches the upload r parsed to its context, for
+// processPass to keep once the pass is stored.
+func withArtifact(r *http.Request, a *artifact) *http.Request {
+	if artifactStore == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), artifactKey{}, a))
+}
+
+// imageArtifact is an uploaded image as an artifact.
+func imageArtifact(img []byte) *artifact {
+	return &artifact{r: bytes.NewReader(img), size: int64(len(img)), sum: sha256.Sum256(img), contentType: http.DetectContentType(img)}
+}
+
+// storeArtifact keeps the upload attached to ctx, if any, and references
+// it from p's stored record. A duplicate scan leaves the stored record, and
+// its upload, alone. Failures are logged but never fail the parse request.
+func storeArtifact(ctx context.Context, p *bcbp.UnifiedBoardingPass) {
+	a, _ := ctx.Value(artifactKey{}).(*artifact)
+	if a == nil || artifactStore == nil || passStore == nil || p.Duplicate {
+		return
+	}
+	sum := hex.EncodeToString(a.sum[:])
+	err := artifactStore.Put(a.r, a.size, sum)
+	if err == nil {
+		err = passStore.SetArtifact(storage.ID(p), sum, a.contentType)
+	}
+	switch {
+	case errors.Is(err, errArtifactTooLarge):
+		slog.InfoContext(ctx, "Upload not kept", "bytes", a.size, "max_bytes", artifactStore.maxSize)
+	case err != nil:
+		slog.ErrorContext(ctx, "Error storing upload", "err", err)
+	}
+}
+
+func handlePassArtifact(w http.ResponseWriter, r *http.Request) {
+	if passStore == nil {
+		httpError(w, "Persistence is disabled (set SQLITE_PATH or DATABASE_URL)", http.StatusNotImplemented)
+		return
+	}
+	if artifactStore == nil {
+		httpError(w, "Upload storage is disabled (set ARTIFACT_DIR)", http.StatusNotImplemented)
+		return
+	}
+	id := r.PathValue("id")
+	sum, contentType, err := passStore.Artifact(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		httpError(w, "Pass not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching pass", "pass", id, "err", err)
+		httpError(w, "Error fetching pass", http.StatusInternalServerError)
+		return
+	}
+	if sum == "" {
+		httpError(w, "No upload stored for this pass", http.StatusNotFound)
+		return
+	}
+	f, err := artifactStore.Open(sum)
+	if errors.Is(err, os.ErrNotExist) {
+		httpError(w, "The upload for this pass has been evicted", http.StatusGone)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error opening stored upload", "pass", id, "err", err)
+		httpError(w, "Error opening stored upload", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	name := id
+	switch contentType {
+	case pkpassContentType:
+		name += ".pkpass"
+	case "image/png", "image/jpeg", "image/gif", "image/webp", "image/bmp":
+		name += "." + strings.TrimPrefix(contentType, "image/")
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Header().Set("ETag", `"`+sum+`"`)
+	// The content never changes under its hash; ServeContent answers
+	// If-None-Match and ranges.
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// artifactName is a valid file name for test artifact i.
+func artifactName(i int) string {
+	return strings.Repeat(string(rune('a'+i)), 64)
+}
+
+// putArtifact stores size bytes as artifact i, last used age ago.
+func putArtifact(t *testing.T, s *ArtifactStore, i int, size int64, age time.Duration) error {
+	t.Helper()
+	err := s.Put(bytes.NewReader(make([]byte, size)), size, artifactName(i))
+	if err == nil {
+		used := time.Now().Add(-age)
+		os.Chtimes(filepath.Join(s.dir, artifactName(i)), used, used)
+	}
+	return err
+}
+
+// storedArtifacts lists the artifacts in s by test index.
+func storedArtifacts(t *testing.T, s *ArtifactStore) []int {
+	t.Helper()
+	files, err := s.files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, f := range files {
+		got = append(got, int(f.name[0]-'a'))
+	}
+	slices.Sort(got)
+	return got
+}
+
+func TestArtifactEvictionBySize(t *testing.T) {
+	type put struct {
+		size int64
+		age  time.Duration // last use, before the next put
+	}
+	tests := []struct {
+		name     string
+		maxSize  int64
+		maxTotal int64
+		puts     []put
+		open     []int // artifacts served before the last put
+		want     []int
+		tooLarge []int // puts refused for their size
+	}{
+		{
+			name: "within budget", maxSize: 100, maxTotal: 300,
+			puts: []put{{100, 3 * time.Hour}, {100, 2 * time.Hour}, {100, time.Hour}},
+			want: []int{0, 1, 2},
+		},
+		{
+			name: "least recently used first", maxSize: 100, maxTotal: 250,
+			puts: []put{{100, 2 * time.Hour}, {100, 3 * time.Hour}, {100, 0}},
+			want: []int{0, 2},
+		},
+		{
+			name: "as many as needed", maxSize: 200, maxTotal: 250,
+			puts: []put{{50, 3 * time.Hour}, {50, 2 * time.Hour}, {100, time.Hour}, {200, 0}},
+			want: []int{3},
+		},
+		{
+			name: "serving counts as use", maxSize: 100, maxTotal: 250,
+			puts: []put{{100, 3 * time.Hour}, {100, 2 * time.Hour}, {100, 0}},
+			open: []int{0},
+			want: []int{0, 2},
+		},
+		{
+			name: "over the per-file budget", maxSize: 100, maxTotal: 300,
+			puts:     []put{{100, time.Hour}, {101, 0}},
+			want:     []int{0},
+			tooLarge: []int{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newArtifactStore(t.TempDir(), tt.maxSize, tt.maxTotal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, p := range tt.puts {
+				if i == len(tt.puts)-1 {
+					for _, o := range tt.open {
+						f, err := s.Open(artifactName(o))
+						if err != nil {
+							t.Fatal(err)
+						}
+						f.Close()
+					}
+				}
+				err := putArtifact(t, s, i, p.size, p.age)
+				if slices.Contains(tt.tooLarge, i) {
+					if !errors.Is(err, errArtifactTooLarge) {
+						t.Fatalf("put %d: %v, want errArtifactTooLarge", i, err)
+					}
+				} else if err != nil {
+					t.Fatalf("put %d: %v", i, err)
+				}
+			}
+			if got := storedArtifacts(t, s); !slices.Equal(got, tt.want) {
+				t.Errorf("stored %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// A budget that shrank while the server was down is enforced on start.
+func TestArtifactEvictionOnStart(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newArtifactStore(dir, 100, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, age := range []time.Duration{time.Hour, 3 * time.Hour, 2 * time.Hour} {
+		if err := putArtifact(t, s, i, 100, age); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s, err = newArtifactStore(dir, 100, 100); err != nil {
+		t.Fatal(err)
+	}
+	if got := storedArtifacts(t, s); !slices.Equal(got, []int{0}) {
+		t.Errorf("stored %v, want [0]", got)
+	}
+}
+
+func TestArtifactPruneByAge(t *testing.T) {
+	tests := []struct {
+		name       string
+		age        time.Duration
+		referenced bool
+		kept       bool
+	}{
+		{"referenced", 24 * time.Hour, true, true},
+		{"unreferenced", 24 * time.Hour, false, false},
+		{"unreferenced past the grace", artifactGrace + time.Second, false, false},
+		{"unreferenced within the grace", artifactGrace - 10*time.Second, false, true},
+		{"referenced within the grace", time.Second, true, true},
+	}
+	s, err := newArtifactStore(t.TempDir(), 100, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := map[string]bool{}
+	var want []int
+	for i, tt := range tests {
+		if err := putArtifact(t, s, i, 10, tt.age); err != nil {
+			t.Fatal(err)
+		}
+		refs[artifactName(i)] = tt.referenced
+		if tt.kept {
+			want = append(want, i)
+		}
+	}
+	n, err := s.Prune(refs, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(tests)-len(want) {
+		t.Errorf("pruned %d, want %d", n, len(tests)-len(want))
+	}
+	got := storedArtifacts(t, s)
+	for i, tt := range tests {
+		if slices.Contains(got, i) != tt.kept {
+			t.Errorf("%s: kept %v, want %v", tt.name, !tt.kept, tt.kept)
+		}
+	}
+}
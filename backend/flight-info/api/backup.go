@@ -0,0 +1,220 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// ADMIN: BACKUP AND RESTORE
+// ----------------------
+
+const (
+	backupFormat = "flightinfo-passes"
+	// backupSchemaVersion is bumped whenever Backup or storage.StoredPass change in a
+	// way older servers can't read. Restore rejects any other version.
+	backupSchemaVersion = 1
+)
+
+// Backup is the body of GET /admin/backup and POST /admin/restore. The
+// header fields are always written before passes, so a restore can check
+// them before importing anything.
+type Backup struct {
+	Format        string                `json:"format"`
+	SchemaVersion int                   `json:"schema_version"`
+	CreatedAt     time.Time             `json:"created_at"`
+	Passes        []*storage.StoredPass `json:"passes"`
+}
+
+// RestoreReport is the body of POST /admin/restore.
+type RestoreReport struct {
+	DryRun  bool `json:"dry_run"`
+	Created int  `json:"created"`
+	Updated int  `json:"updated"` // the backup had a newer copy
+	Skipped int  `json:"skipped"` // the stored copy was as new or newer
+}
+
+// handleBackup streams every stored pass as a Backup, newest first.
+func handleBackup(w http.ResponseWriter, r *http.Request) {
+	if passStore == nil {
+		httpError(w, "Persistence is disabled (set SQLITE_PATH or DATABASE_URL)", http.StatusNotImplemented)
+		return
+	}
+
+	now := time.Now().UTC()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="flightinfo-backup-%s.json"`, now.Format("20060102-150405")))
+
+	// Written by hand so passes can be streamed; the result decodes as Backup.
+	header, _ := json.Marshal(backupFormat)
+	fmt.Fprintf(w, `{"format":%s,"schema_version":%d,"created_at":%q,"passes":[`,
+		header, backupSchemaVersion, now.Format(time.RFC3339Nano))
+	n := 0
+	err := passStore.Each(storage.PassFilter{}, func(sp *storage.StoredPass) error {
+		line, err := json.Marshal(sp)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			w.Write([]byte(","))
+		}
+		n++
+		w.Write([]byte("\n"))
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		return r.Context().Err()
+	})
+	if err != nil {
+		// Leave the JSON unterminated so a restore can't mistake it for a
+		// complete backup.
+		slog.ErrorContext(r.Context(), "Error writing backup", "passes", n, "err", err)
+		return
+	}
+	w.Write([]byte("\n]}\n"))
+}
+
+// handleRestore imports a Backup in a single transaction: either every pass
+// is merged or none is. Passes are matched by ID, i.e. by dedup key, and the
+// copy with the later updated_at wins. With ?dry_run=true the transaction is
+// rolled back and only the report is returned. On SQLite the store has one
+// connection, so other database work waits until the upload has been read.
+func handleRestore(w http.ResponseWriter, r *http.Request) {
+	if passStore == nil {
+		httpError(w, "Persistence is disabled (set SQLITE_PATH or DATABASE_URL)", http.StatusNotImplemented)
+		return
+	}
+
+	report := RestoreReport{DryRun: r.URL.Query().Get("dry_run") == "true"}
+	restore, err := passStore.Restore()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting restore", "err", err)
+		httpError(w, "Error restoring backup", http.StatusInternalServerError)
+		return
+	}
+	defer restore.Rollback()
+
+	// Errors from Merge are the database's; the rest are the backup's.
+	var dbErr error
+	err = decodeBackup(r.Body, func(sp *storage.StoredPass) error {
+		var outcome storage.RestoreOutcome
+		if outcome, dbErr = restore.Merge(sp); dbErr != nil {
+			return dbErr
+		}
+		switch outcome {
+		case storage.RestoreCreated:
+			report.Created++
+		case storage.RestoreUpdated:
+			report.Updated++
+		default:
+			report.Skipped++
+		}
+		return nil
+	})
+	if dbErr != nil {
+		slog.ErrorContext(r.Context(), "Error restoring backup", "err", dbErr)
+		httpError(w, "Error restoring backup", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		httpError(w, fmt.Sprintf("Invalid backup: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !report.DryRun {
+		if err := restore.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "Error committing restore", "err", err)
+			httpError(w, "Error restoring backup", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// decodeBackup reads a Backup one pass at a time, calling fn for each after
+// checking the format and schema version. Unknown top-level fields are
+// ignored.
+func decodeBackup(body io.Reader, fn func(*storage.StoredPass) error) error {
+	dec := json.NewDecoder(body)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	var (
+		format  string
+		version int
+		passes  = -1
+	)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch key, _ := tok.(string); key {
+		case "format":
+			if err := dec.Decode(&format); err != nil {
+				return fmt.Errorf("format: %w", err)
+			}
+		case "schema_version":
+			if err := dec.Decode(&version); err != nil {
+				return fmt.Errorf("schema_version: %w", err)
+			}
+		case "passes":
+			if format != backupFormat {
+				return fmt.Errorf("format must be %q and come before passes", backupFormat)
+			}
+			if version != backupSchemaVersion {
+				return fmt.Errorf("unsupported schema_version %d (this server reads version %d)", version, backupSchemaVersion)
+			}
+			if err := expectDelim(dec, '['); err != nil {
+				return fmt.Errorf("passes: %w", err)
+			}
+			for passes = 0; dec.More(); passes++ {
+				var sp storage.StoredPass
+				if err := dec.Decode(&sp); err != nil {
+					return fmt.Errorf("passes[%d]: %w", passes, err)
+				}
+				if sp.Pass == nil {
+					return fmt.Errorf("passes[%d]: missing pass", passes)
+				}
+				if err := fn(&sp); err != nil {
+					return err
+				}
+			}
+			if err := expectDelim(dec, ']'); err != nil {
+				return fmt.Errorf("passes: %w", err)
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+	if passes < 0 {
+		return errors.New("missing passes")
+	}
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return fmt.Errorf("expected %q, got %v", want, tok)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/aztec"
+	"github.com/boombuler/barcode/pdf417"
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// ----------------------
+// GENERATE: BARCODE IMAGE
+// ----------------------
+
+const (
+	minBarcodeImageSize     = 64
+	maxBarcodeImageSize     = 2048
+	defaultBarcodeImageSize = 512
+)
+
+// renderBarcode draws text as a PNG-ready image roughly size pixels wide.
+// QR uses gozxing's writer; gozxing has no Aztec or PDF417 encoder, so those
+// two go through boombuler/barcode.
+func renderBarcode(text, format string, size int, ecLevel string) (image.Image, error) {
+	switch format {
+	case "QR":
+		hints := map[gozxing.EncodeHintType]interface{}{
+			gozxing.EncodeHintType_ERROR_CORRECTION: ecLevel,
+			gozxing.EncodeHintType_MARGIN:           4,
+		}
+		return qrcode.NewQRCodeWriter().Encode(text, gozxing.BarcodeFormat_QR_CODE, size, size, hints)
+
+	case "AZTEC":
+		// 23% is the minimum error correction recommended by ISO 24778.
+		code, err := aztec.Encode([]byte(text), 23, 0)
+		if err != nil {
+			return nil, err
+		}
+		return scaleWithQuietZone(code, size, size, 0.08)
+
+	case "PDF417":
+		code, err := pdf417.Encode(text, 2)
+		if err != nil {
+			return nil, err
+		}
+		b := code.Bounds()
+		height := size * b.Dy() / b.Dx()
+		if height < 1 {
+			height = 1
+		}
+		return scaleWithQuietZone(code, size, height, 0.05)
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// scaleWithQuietZone scales the symbol to fit inside width x height leaving
+// a white margin (a fraction of the smaller side) that readers rely on.
+func scaleWithQuietZone(code barcode.Barcode, width, height int, margin float64) (image.Image, error) {
+	pad := int(float64(min(width, height)) * margin)
+	innerW, innerH := width-2*pad, height-2*pad
+	cb := code.Bounds()
+	// Integer module scaling keeps edges crisp; shrink to the largest multiple.
+	scale := min(innerW/cb.Dx(), innerH/cb.Dy())
+	if scale < 1 {
+		return nil, fmt.Errorf("size too small for %d modules of data", max(cb.Dx(), cb.Dy()))
+	}
+	scaled, err := barcode.Scale(code, cb.Dx()*scale, cb.Dy()*scale)
+	if err != nil {
+		return nil, err
+	}
+
+	canvas := image.NewGray(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	sb := scaled.Bounds()
+	offset := image.Pt((width-sb.Dx())/2, (height-sb.Dy())/2)
+	draw.Draw(canvas, sb.Add(offset), scaled, sb.Min, draw.Src)
+	return canvas, nil
+}
+
+// BarcodeImageOptions is the body of POST /generate/barcode/image.
+type BarcodeImageOptions struct {
+	Text            string `json:"text"`
+	Format          string `json:"format,omitempty"`           // AZTEC (default), QR or PDF417
+	Size            int    `json:"size,omitempty"`             // width in pixels
+	ErrorCorrection string `json:"error_correction,omitempty"` // QR only: L, M (default), Q or H
+}
+
+// BarcodeImageResponse is sent instead of the PNG when the client accepts
+// application/json.
+type BarcodeImageResponse struct {
+	Format      string `json:"format"`
+	MimeType    string `json:"mime_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	ImageBase64 string `json:"image_base64"`
+}
+
+func handleGenerateBarcodeImage(w http.ResponseWriter, r *http.Request) {
+	var req BarcodeImageOptions
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Text == "" {
+		httpError(w, "text is required", http.StatusBadRequest)
+		return
+	}
+
+	// Airlines print Aztec, so it's the default.
+	format := strings.ToUpper(strings.TrimSpace(req.Format))
+	if format == "" {
+		format = "AZTEC"
+	}
+	if format != "AZTEC" && format != "QR" && format != "PDF417" {
+		httpError(w, "format must be one of AZTEC, QR, PDF417", http.StatusBadRequest)
+		return
+	}
+
+	size := req.Size
+	if size == 0 {
+		size = defaultBarcodeImageSize
+	}
+	if size < minBarcodeImageSize || size > maxBarcodeImageSize {
+		httpError(w, fmt.Sprintf("size must be between %d and %d", minBarcodeImageSize, maxBarcodeImageSize), http.StatusBadRequest)
+		return
+	}
+
+	// Only QR exposes a selectable error correction level.
+	ecLevel := strings.ToUpper(strings.TrimSpace(req.ErrorCorrection))
+	if ecLevel == "" {
+		ecLevel = "M"
+	}
+	if format == "QR" && (len(ecLevel) != 1 || !strings.Contains("LMQH", ecLevel)) {
+		httpError(w, "error_correction must be one of L, M, Q, H", http.StatusBadRequest)
+		return
+	}
+
+	img, err := renderBarcode(req.Text, format, size, ecLevel)
+	if err != nil {
+		httpError(w, fmt.Sprintf("Error generating barcode: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		httpError(w, "Error encoding PNG", http.StatusInternalServerError)
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		b := img.Bounds()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(BarcodeImageResponse{
+			Format:      format,
+			MimeType:    "image/png",
+			Width:       b.Dx(),
+			Height:      b.Dy(),
+			ImageBase64: base64.StdEncoding.EncodeToString(buf.Bytes()),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(buf.Bytes())
+}
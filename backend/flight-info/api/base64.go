@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"bugsbyte/flight-info/scan"
+)
+
+// ----------------------
+// PARSING: BASE64 BODIES
+// ----------------------
+
+// Clients send base64 in every shape: wrapped at 76 columns, as chunks
+// joined with newlines, with or without padding, and from the URL-safe
+// alphabet. decodeBase64 accepts all of them, so every endpoint that takes
+// base64 behaves the same, and says where the input went wrong when it
+// can't.
+
+// base64Error is a rejected base64 input. Pos is the 1-based character
+// position of the first offending character in the input as sent.
+type base64Error struct {
+	Pos    int
+	Reason string
+}
+
+func (e *base64Error) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Reason, e.Pos)
+}
+
+// isBase64Space reports the characters decodeBase64 drops.
+func isBase64Space(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\r' || r == '\n' || r == '\f' || r == '\v'
+}
+
+// decodeBase64 decodes s after dropping whitespace. Padding is optional,
+// and padding in the middle is taken as the end of a chunk that was
+// encoded on its own. The standard alphabet is used unless s has a '-' or
+// '_', which are only in the URL-safe one; mixing the two is an error.
+func decodeBase64(s string) ([]byte, error) {
+	return appendBase64(nil, s)
+}
+
+// appendBase64 is decodeBase64 appending to dst, which it grows once to
+// the decoded size.
+func appendBase64(dst []byte, s string) ([]byte, error) {
+	// strings.Map only copies s when it has whitespace to drop.
+	clean := strings.Map(func(r rune) rune {
+		if isBase64Space(r) {
+			return -1
+		}
+		return r
+	}, s)
+
+	enc, other := base64.RawStdEncoding, "-_"
+	if strings.ContainsAny(clean, "-_") {
+		enc, other = base64.RawURLEncoding, "+/"
+	}
+	for i, r := range clean {
+		switch {
+		case strings.ContainsRune(other, r):
+			return nil, &base64Error{inputPos(s, i), fmt.Sprintf("character %q mixes the standard and URL-safe alphabets", r)}
+		case r != '=' && !strings.ContainsRune("+/-_", r) && (r >= utf8.RuneSelf || !isAlnum(byte(r))):
+			return nil, &base64Error{inputPos(s, i), fmt.Sprintf("character %q is not base64", r)}
+		}
+	}
+
+	// Padding only ever makes the decoded size smaller.
+	out := slices.Grow(dst[:0], enc.DecodedLen(len(clean)))
+	for off := 0; off < len(clean); {
+		chunk := clean[off:]
+		if i := strings.IndexByte(chunk, '='); i >= 0 {
+			chunk = chunk[:i]
+		}
+		if len(chunk)%4 == 1 {
+			// One character past a multiple of 4 can't encode anything.
+			return nil, &base64Error{inputPos(s, off+len(chunk)-1), "stray last character (a chunk is missing or truncated)"}
+		}
+		var err error
+		if out, err = enc.AppendDecode(out, []byte(chunk)); err != nil {
+			return nil, err
+		}
+		off += len(chunk)
+		for off < len(clean) && clean[off] == '=' {
+			off++
+		}
+	}
+	return out, nil
+}
+
+// inputPos maps a byte offset in s without its whitespace back to the
+// 1-based character position in s.
+func inputPos(s string, cleanOff int) int {
+	pos := 0
+	for _, r := range s {
+		if isBase64Space(r) {
+			pos++
+			continue
+		}
+		if cleanOff <= 0 {
+			break
+		}
+		cleanOff -= utf8.RuneLen(r)
+		pos++
+	}
+	return pos + 1
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+// imageBufs recycles the buffers base64 images are decoded into, which run
+// to megabytes, so a busy image endpoint doesn't allocate one per request.
+var imageBufs = sync.Pool{New: func() any { return new([]byte) }}
+
+// decodePooledBase64 is decodeBase64 into a buffer from imageBufs. The
+// caller must not keep the result past release, which returns the buffer.
+// Buffers of oversized images are left to the garbage collector.
+func decodePooledBase64(s string) (data []byte, release func(), err error) {
+	buf := imageBufs.Get().(*[]byte)
+	data, err = appendBase64(*buf, s)
+	release = func() {
+		if cap(data) <= scan.MaxImageBytes {
+			*buf = data[:0]
+			imageBufs.Put(buf)
+		}
+	}
+	if err != nil {
+		imageBufs.Put(buf)
+		return nil, func() {}, err
+	}
+	return data, release, nil
+}
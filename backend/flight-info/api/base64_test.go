@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// chunked encodes data the way a React Native client that reads a file in
+// chunks sends it: each chunk base64-encoded on its own, padding and all,
+// and the chunks joined with sep.
+func chunked(data []byte, chunk int, sep string) string {
+	var parts []string
+	for len(data) > 0 {
+		n := min(chunk, len(data))
+		parts = append(parts, base64.StdEncoding.EncodeToString(data[:n]))
+		data = data[n:]
+	}
+	return strings.Join(parts, sep)
+}
+
+// wrapped breaks s into lines of n characters ending in eol.
+func wrapped(s string, n int, eol string) string {
+	var b strings.Builder
+	for len(s) > n {
+		b.WriteString(s[:n] + eol)
+		s = s[n:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+func TestDecodeBase64(t *testing.T) {
+	data := []byte("M1DESMARAIS/LUC       EABC123 YULFRAAC 0834 326J001A0025 100")
+	std := base64.StdEncoding.EncodeToString(data)
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"padded", std},
+		{"unpadded", base64.RawStdEncoding.EncodeToString(data)},
+		{"url-safe", base64.URLEncoding.EncodeToString(data)},
+		{"wrapped at 76 with CRLF", wrapped(std, 76, "\r\n")},
+		{"chunks on lines", chunked(data, 3*5, "\n")},
+		{"padded chunks on lines", chunked(data, 7, "\n")},
+		{"padded chunks with blank lines", chunked(data, 8, "\n\n")},
+		{"padded chunks run together", chunked(data, 10, "")},
+		{"surrounding whitespace", "  \n" + std + "\n\t"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeBase64(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("got %q", got)
+			}
+		})
+	}
+}
+
+func TestDecodeBase64Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		pos   int
+	}{
+		{"not base64", "TTFE\nRV*N", 8},
+		{"mixed alphabets", "ab-d\nef+h", 8},
+		{"stray last character", "TTFE\nRVNN\nQ", 11},
+		{"chunk truncated to one character", "TTFE\nR===\nRVNN", 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeBase64(tt.input)
+			var b64Err *base64Error
+			if !errors.As(err, &b64Err) {
+				t.Fatalf("error %v, want a base64Error", err)
+			}
+			if b64Err.Pos != tt.pos {
+				t.Errorf("position %d, want %d (%v)", b64Err.Pos, tt.pos, err)
+			}
+		})
+	}
+}
+
+// The image endpoint takes chunked base64 like any other, and names the
+// position of a truncated chunk, counting the data: prefix.
+func TestBarcodeImageChunkedBase64(t *testing.T) {
+	img := chunked(readFile(t, "data/selftest/ac-aztec.png"), 1000, "\n")
+	post := func(b64 string) *httptest.ResponseRecorder {
+		return serve(t, Handler(), http.MethodPost, "/parse/barcode/image", bytes.NewReader(jsonBody(t, BarcodeImageRequest{Image: b64})), "Content-Type", "application/json")
+	}
+	if w := post(img); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	const prefix = "data:image/png;base64,"
+	first, _, _ := strings.Cut(img, "\n")
+	w := post(prefix + first + "\nQ")
+	var resp struct {
+		Error ErrorDetail `json:"error"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusBadRequest || resp.Error.Reason != reasonInvalidBase64 {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if want := len(prefix) + len(first) + 2; resp.Error.Position != want {
+		t.Errorf("position %d, want %d", resp.Error.Position, want)
+	}
+}
@@ -0,0 +1,356 @@
+package api
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/scan"
+)
+
+// ----------------------
+// HANDLERS: BATCH IMAGE PARSING
+// ----------------------
+
+// maxBatchBody caps the JSON body of /parse/barcode/images.
+const maxBatchBody = 64 << 20
+
+// batchMaxImages (BATCH_MAX_IMAGES) and batchWorkers (BATCH_WORKERS) bound a
+// batch's size and how many of its images are decoded at once. Every image
+// also goes through the heavy-work limiter.
+var (
+	batchMaxImages = defaultConfig.BatchMaxImages
+	batchWorkers   = defaultConfig.BatchWorkers
+)
+
+// BarcodeImagesRequest is the body of POST /parse/barcode/images.
+type BarcodeImagesRequest struct {
+	Images []string `json:"images"` // base64, optionally as data: URIs
+}
+
+// BatchItem is the outcome for one image of a batch: the pass, or the error
+// the single-image endpoint would have returned.
+type BatchItem struct {
+	Index    int                       `json:"index"`              // position in the request's images
+	Filename string                    `json:"filename,omitempty"` // the archive entry, for a zip upload
+	Pass     *bcbp.UnifiedBoardingPass `json:"pass,omitempty"`
+	Error    *ErrorDetail              `json:"error,omitempty"`
+}
+
+// BatchResult is the body of a synchronous POST /parse/barcode/images, and
+// the result of a finished job. Items are in request order, or archive
+// order for a zip upload.
+type BatchResult struct {
+	Items     []BatchItem `json:"items"`
+	Succeeded int         `json:"succeeded"`
+	Failed    int         `json:"failed"`
+	// Skipped are the entries of a zip upload that aren't images.
+	Skipped []string `json:"skipped,omitempty"`
+	// Error is set when a synchronous batch stopped before every image
+	// was parsed; Items then only has those that were.
+	Error *ErrorDetail `json:"error,omitempty"`
+}
+
+// BatchSummary is the last line of an NDJSON batch response, after one
+// BatchItem line per image. It is BatchResult without the items.
+type BatchSummary struct {
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Skipped   []string     `json:"skipped,omitempty"`
+	Error     *ErrorDetail `json:"error,omitempty"`
+}
+
+// batchShutdown is canceled once the server starts shutting down, which
+// stops synchronous batches; stopBatches is registered with
+// http.Server.RegisterOnShutdown.
+var batchShutdown, stopBatches = context.WithCancel(context.Background())
+
+func handleBarcodeImages(w http.ResponseWriter, r *http.Request) {
+	b := &batch{
+		q:         r.URL.Query(),
+		lang:      responseLang(w),
+		endpoint:  r.URL.Path,
+		requestID: w.Header().Get("X-Request-ID"),
+	}
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "multipart/form-data" {
+		file, ok := readBatchZip(w, r, b)
+		if !ok {
+			return
+		}
+		b.done = func() { file.Close() }
+	} else {
+		var req BarcodeImagesRequest
+		if status, d := decodeJSONBody(w, r, maxBatchBody, &req); status != 0 {
+			parseFailed(w, r, status, d, int(r.ContentLength), "")
+			return
+		}
+		b.images = req.Images
+	}
+	defer b.close()
+
+	if status, d := outputFormat(r.URL.Query()); status != 0 {
+		writeError(w, status, d)
+		return
+	}
+	prof, status, d := decodeProfile(r.URL.Query(), "")
+	if status != 0 {
+		writeError(w, status, d)
+		return
+	}
+	b.profile = prof
+	if b.len() == 0 {
+		if b.entries != nil {
+			httpError(w, "No images in the archive", http.StatusBadRequest)
+		} else {
+			httpError(w, "images is required", http.StatusBadRequest)
+		}
+		return
+	}
+	if b.len() > batchMaxImages {
+		writeError(w, http.StatusRequestEntityTooLarge, ErrorDetail{
+			Reason:  reasonTooLarge,
+			Message: fmt.Sprintf("At most %d images per batch", batchMaxImages),
+		})
+		return
+	}
+
+	if r.URL.Query().Get("async") != "true" {
+		b.stream(w, r)
+		return
+	}
+
+	j, err := batchJobs.create(b.len())
+	if err != nil {
+		httpRetryError(w, err.Error(), http.StatusServiceUnavailable, 60)
+		return
+	}
+	// The job outlives the request; it keeps its log attributes and span,
+	// and takes over the upload it reads from.
+	ctx := context.WithoutCancel(r.Context())
+	job := *b
+	b.done = nil
+	go func() {
+		defer job.close()
+		j.finish(job.run(ctx, j.add))
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/jobs/"+j.id)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(j.view())
+}
+
+// batch is one batch request, parsed with the same options for every image.
+// The images are either base64 strings or the entries of a zip upload.
+type batch struct {
+	q         url.Values
+	profile   scan.DecodeProfile
+	lang      string // for the items' user messages
+	endpoint  string // for the failure log
+	requestID string
+	images    []string
+	entries   []*zip.File
+	skipped   []string // entries that aren't images
+	done      func()   // releases the upload, if any
+}
+
+func (b *batch) len() int {
+	return len(b.images) + len(b.entries)
+}
+
+func (b *batch) close() {
+	if b.done != nil {
+		b.done()
+	}
+}
+
+// each parses the images batchWorkers at a time and calls fn with each
+// item as it finishes, one call at a time. Once ctx is done it starts no
+// more images, and drops the failures of those it was parsing, which may
+// only have failed for lack of time. It returns how many items fn got.
+func (b *batch) each(ctx context.Context, fn func(BatchItem)) int {
+	var (
+		g  errgroup.Group
+		mu sync.Mutex
+		n  int
+	)
+	g.SetLimit(batchWorkers)
+	for i := range b.len() {
+		if ctx.Err() != nil {
+			break
+		}
+		g.Go(func() error {
+			if ctx.Err() != nil {
+				return nil
+			}
+			item := b.parse(withBudget(ctx), i)
+			if item.Error != nil && ctx.Err() != nil {
+				return nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			n++
+			fn(item)
+			return nil
+		})
+	}
+	g.Wait()
+	return n
+}
+
+// run parses every image, calling onItem (if not nil) as each one
+// finishes. ctx must not be canceled, or the result would miss items.
+func (b *batch) run(ctx context.Context, onItem func(BatchItem)) *BatchResult {
+	result := &BatchResult{Items: make([]BatchItem, b.len()), Skipped: b.skipped}
+	b.each(ctx, func(item BatchItem) {
+		result.Items[item.Index] = item
+		if item.Error != nil {
+			result.Failed++
+		} else {
+			result.Succeeded++
+		}
+		if onItem != nil {
+			onItem(item)
+		}
+	})
+	return result
+}
+
+// stream answers a synchronous batch, writing each item as soon as it is
+// ready (see itemStream). JSON keeps the items in request order, so an
+// item that finishes before an earlier one waits for it; NDJSON sends them
+// in the order they finish. The batch stops early if the client goes away
+// or the server shuts down, and then ends with an error saying how far it
+// got.
+func (b *batch) stream(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	defer context.AfterFunc(batchShutdown, cancel)()
+
+	s := newItemStream(w, r, `{"items":[`)
+	sum := BatchSummary{Skipped: b.skipped}
+	var encodeErr error
+	write := func(item BatchItem) {
+		if encodeErr != nil {
+			return
+		}
+		if err := s.item(item); err != nil {
+			encodeErr = fmt.Errorf("item %d: %w", item.Index, err)
+			cancel()
+		}
+	}
+	pending := map[int]BatchItem{} // finished ahead of item next
+	next := 0
+	parsed := b.each(ctx, func(item BatchItem) {
+		if item.Error != nil {
+			sum.Failed++
+		} else {
+			sum.Succeeded++
+		}
+		if s.ndjson {
+			write(item)
+			return
+		}
+		pending[item.Index] = item
+		for ; ; next++ {
+			item, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			write(item)
+		}
+	})
+	// Items after one that was never parsed.
+	for i := next; len(pending) > 0; i++ {
+		if item, ok := pending[i]; ok {
+			delete(pending, i)
+			write(item)
+		}
+	}
+
+	switch {
+	case encodeErr != nil:
+		slog.ErrorContext(r.Context(), "Error encoding batch item", "err", encodeErr)
+		d := errorDetail(http.StatusInternalServerError, b.lang, ErrorDetail{Message: "Error encoding the results"})
+		sum.Error = &d
+	case parsed < b.len():
+		cause := "the client went away"
+		if batchShutdown.Err() != nil {
+			cause = "the server is shutting down"
+		}
+		d := errorDetail(http.StatusServiceUnavailable, b.lang, ErrorDetail{
+			Message: fmt.Sprintf("Batch interrupted after %d of %d images: %s", parsed, b.len(), cause),
+		})
+		sum.Error = &d
+	}
+	if sum.Error != nil {
+		sum.Error.RequestID = b.requestID
+	}
+	s.end(sum)
+}
+
+// parse handles image i like handleBarcodeImage, without the response
+// cache.
+func (b *batch) parse(ctx context.Context, i int) BatchItem {
+	var name string
+	fail := func(status int, d ErrorDetail, size int, sample string) BatchItem {
+		recordParseFailure(b.endpoint, b.requestID, status, d, size, sample)
+		d = errorDetail(status, b.lang, d)
+		return BatchItem{Index: i, Filename: name, Error: &d}
+	}
+
+	var (
+		img    []byte
+		size   int
+		status int
+		d      ErrorDetail
+	)
+	if b.entries != nil {
+		f := b.entries[i]
+		name, size = f.Name, int(f.UncompressedSize64)
+		img, status, d = readZipImage(f)
+	} else {
+		size = len(b.images[i])
+		var done func()
+		if img, done, status, d = decodeBase64Image(b.images[i]); status == 0 {
+			defer done()
+		}
+	}
+	if status != 0 {
+		return fail(status, d, size, "")
+	}
+	release := func() {}
+	if heavyWork != nil {
+		var err error
+		if release, err = heavyWork.acquire(ctx, scan.Weight(img)); err != nil {
+			return fail(http.StatusTooManyRequests, ErrorDetail{Message: fmt.Sprintf("Server busy: %v, retry later", err)}, len(img), "")
+		}
+	}
+	res, err := scan.DecodeWith(ctx, img, b.profile)
+	release()
+	if err != nil {
+		status, d := imageDecodeError(err, b.profile.Name)
+		return fail(status, d, len(img), "")
+	}
+	text := res.Text
+	data, err := parseBCBP(ctx, text, time.Now(), lenientParse(b.q))
+	if err != nil {
+		return fail(http.StatusUnprocessableEntity, notBoardingPassError(b.q, text, err), len(img), textSample(b.q, text))
+	}
+	tagImagePass(data, res)
+	if wantDetail(b.q) {
+		addDecodeDetail(data, res)
+	}
+	return BatchItem{Index: i, Filename: name, Pass: processPass(ctx, data, b.q)}
+}
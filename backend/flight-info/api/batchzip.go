@@ -0,0 +1,127 @@
+package api
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"bugsbyte/flight-info/pkpass"
+	"bugsbyte/flight-info/scan"
+)
+
+// ----------------------
+// HANDLERS: ZIP UPLOADS TO /parse/barcode/images
+// ----------------------
+
+// The back office gets folders of scanned paper passes. Instead of a JSON
+// body, /parse/barcode/images takes them as one zip, a multipart "file"
+// part, and parses every image in it like the images of a JSON batch. The
+// archive is checked against batchZipLimits before anything is inflated,
+// each entry is held to scan.MaxImageBytes, and the items come back in
+// archive order with their file names.
+
+// batchZipLimits bound a zip upload; the upload itself is capped at
+// maxBatchBody.
+var batchZipLimits = pkpass.ArchiveLimits{MaxEntries: 1000, MaxUncompressed: 512 << 20}
+
+// imageExts are the extensions an entry is taken for an image by; entries
+// without one are sniffed.
+var imageExts = []string{".png", ".jpg", ".jpeg", ".gif", ".bmp", ".webp"}
+
+// readBatchZip reads the zip upload of r into b's entries, writing the
+// error response itself when it can't. The caller closes the upload.
+func readBatchZip(w http.ResponseWriter, r *http.Request, b *batch) (*upload, bool) {
+	file, err := readFilePart(w, r, "file", maxBatchBody)
+	if err != nil {
+		switch {
+		case tooLarge(err):
+			parseFailed(w, r, http.StatusRequestEntityTooLarge,
+				ErrorDetail{Reason: reasonTooLarge, Message: "Upload too large"}, int(r.ContentLength), "")
+		case errors.Is(err, errNoFilePart):
+			parseFailed(w, r, http.StatusBadRequest,
+				ErrorDetail{Reason: reasonInvalidForm, Message: "Error retrieving file"}, int(r.ContentLength), "")
+		default:
+			parseFailed(w, r, http.StatusBadRequest,
+				ErrorDetail{Reason: reasonInvalidForm, Message: "Invalid multipart form"}, int(r.ContentLength), "")
+		}
+		return nil, false
+	}
+	if kind, ok := sniffUpload(file.head); !ok || kind != kindPkPass {
+		file.Close()
+		d := ErrorDetail{Reason: reasonUnsupportedType, Message: "Upload a zip archive of images, or send them as JSON"}
+		if ok {
+			d.DetectedType = kind.Name
+		}
+		parseFailed(w, r, http.StatusUnsupportedMediaType, d, int(file.size), "")
+		return nil, false
+	}
+	reader, err := pkpass.OpenArchive(file.r, file.size, batchZipLimits)
+	if err != nil {
+		file.Close()
+		if errors.Is(err, pkpass.ErrArchiveTooLarge) {
+			parseFailed(w, r, http.StatusRequestEntityTooLarge,
+				ErrorDetail{Reason: reasonTooLarge, Message: "Upload rejected: " + err.Error()}, int(file.size), "")
+		} else {
+			parseFailed(w, r, http.StatusUnprocessableEntity,
+				ErrorDetail{Reason: reasonInvalidArchive, Message: "Error reading zip archive: " + err.Error()}, int(file.size), "")
+		}
+		return nil, false
+	}
+
+	b.entries = []*zip.File{}
+	for _, f := range reader.File {
+		switch {
+		case f.FileInfo().IsDir() || hiddenEntry(f.Name):
+			// Not part of the folder.
+		case slices.Contains(imageExts, strings.ToLower(path.Ext(f.Name))) || sniffEntry(f):
+			b.entries = append(b.entries, f)
+		default:
+			b.skipped = append(b.skipped, f.Name)
+		}
+	}
+	return file, true
+}
+
+// hiddenEntry reports whether name is something the archiver added, such
+// as a macOS resource fork or .DS_Store, rather than a file of the folder.
+func hiddenEntry(name string) bool {
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".")
+}
+
+// sniffEntry reports whether f starts like an image, or can't be read at
+// all: that is reported as the entry's error instead of skipping it.
+func sniffEntry(f *zip.File) bool {
+	rc, err := f.Open()
+	if err != nil {
+		return true
+	}
+	defer rc.Close()
+	head := make([]byte, 32)
+	n, err := io.ReadFull(rc, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return true
+	}
+	kind, ok := sniffUpload(head[:n])
+	return ok && kind.isImage()
+}
+
+// readZipImage reads one entry of a zip upload and checks it like a
+// decoded base64 image. status is 0 on success.
+func readZipImage(f *zip.File) (img []byte, status int, d ErrorDetail) {
+	img, err := pkpass.ReadEntry(f, scan.MaxImageBytes)
+	switch {
+	case errors.Is(err, pkpass.ErrArchiveTooLarge):
+		return nil, http.StatusRequestEntityTooLarge, ErrorDetail{Reason: reasonTooLarge, Message: "Image too large"}
+	case err != nil:
+		return nil, http.StatusUnprocessableEntity, ErrorDetail{Reason: reasonInvalidArchive, Message: fmt.Sprintf("Error reading %s from the archive: %v", f.Name, err)}
+	}
+	if status, d := checkImageUpload(img); status != 0 {
+		return nil, status, d
+	}
+	return img, 0, ErrorDetail{}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// EXPORT: RAW BCBP FILE
+// ----------------------
+
+// Interline tooling takes boarding passes as .bcbp files: the barcode
+// text, nothing else. A pass parsed from a barcode has it in
+// RawData["raw_string"]. Any other pass (a .pkpass, whose barcode message
+// is never kept, or pass JSON built by hand) only has its parsed fields,
+// which are re-encoded (see bcbp.Encode) and flagged X-Reencoded.
+
+// reencodedHeader is set to "true" on a re-encoded .bcbp file.
+const reencodedHeader = "X-Reencoded"
+
+// bcbpText is the barcode text of p, and whether it had to be re-encoded
+// from the parsed fields because p has none.
+func bcbpText(p *bcbp.UnifiedBoardingPass) (text string, reencoded bool) {
+	raw := p.RawData["raw_string"]
+	if u := strings.ToUpper(raw); strings.HasPrefix(u, "M") || strings.HasPrefix(u, "S") {
+		return raw, false
+	}
+	return bcbp.Encode(p), true
+}
+
+// bcbpFilename reads like "TP432_2026-03-27.bcbp": carrier and flight,
+// then the date, whichever are known.
+func bcbpFilename(p *bcbp.UnifiedBoardingPass) string {
+	var parts []string
+	if flight := strings.TrimSpace(p.Carrier) + strings.TrimLeft(strings.TrimSpace(p.FlightNumber), "0"); flight != "" {
+		parts = append(parts, flight)
+	}
+	if p.DateISO != "" {
+		parts = append(parts, p.DateISO)
+	}
+	name := strings.Join(parts, "_")
+	if name == "" {
+		name = "boarding-pass"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '"' || r == '/' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name) + ".bcbp"
+}
+
+func writeBCBP(w http.ResponseWriter, p *bcbp.UnifiedBoardingPass) {
+	text, reencoded := bcbpText(p)
+	if reencoded {
+		w.Header().Set(reencodedHeader, "true")
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, bcbpFilename(p)))
+	w.Write([]byte(text))
+}
+
+func handlePassBCBP(w http.ResponseWriter, r *http.Request) {
+	if passStore == nil {
+		httpError(w, "Persistence is disabled (set SQLITE_PATH or DATABASE_URL)", http.StatusNotImplemented)
+		return
+	}
+
+	id := r.PathValue("id")
+	sp, err := passStore.Get(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		httpError(w, "Pass not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching pass", "pass", id, "err", err)
+		httpError(w, "Error fetching pass", http.StatusInternalServerError)
+		return
+	}
+	writeBCBP(w, sp.Pass)
+}
+
+// handleExportBCBP takes pass JSON, or a JSON string of barcode text. The
+// text is parsed as /parse/barcode would (lenient included), so what comes
+// back is a well-formed barcode, decoded from base64 or hex if it was sent
+// that way, and the file is named from its fields.
+func handleExportBCBP(w http.ResponseWriter, r *http.Request) {
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		httpError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	var raw string
+	if json.Unmarshal(body, &raw) == nil {
+		if strings.TrimSpace(raw) == "" {
+			httpError(w, "Barcode text is empty", http.StatusBadRequest)
+			return
+		}
+		pass, err := parseBarcodeText(r.Context(), raw, time.Now(), lenientParse(r.URL.Query()))
+		if err != nil {
+			httpError(w, fmt.Sprintf("Error parsing barcode: %v", err), http.StatusUnprocessableEntity)
+			return
+		}
+		writeBCBP(w, pass)
+		return
+	}
+
+	var pass bcbp.UnifiedBoardingPass
+	if err := json.Unmarshal(body, &pass); err != nil {
+		httpError(w, "Body must be pass JSON or a string of barcode text", http.StatusBadRequest)
+		return
+	}
+	writeBCBP(w, &pass)
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// largePkPassImage is the size of the incompressible image
+// BenchmarkHTTP/pkpass-large adds to the fixture, as big as a pass with
+// retina artwork gets. The upload then goes to a temporary file, and B/op
+// shows none of it is held in memory.
+const largePkPassImage = 5 << 20
+
+// BenchmarkHTTP posts the fixtures in testdata/bench to each parse
+// endpoint through the handler, JSON encoding included. force=true keeps
+// the response cache out of the measurement.
+func BenchmarkHTTP(b *testing.B) {
+	// The request log would dominate the numbers.
+	logger := slog.Default()
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	b.Cleanup(func() { slog.SetDefault(logger) })
+	h := Handler()
+	bench := func(b *testing.B, path, contentType string, body []byte) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for b.Loop() {
+			req := httptest.NewRequest(http.MethodPost, path+"?force=true", bytes.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				b.Fatalf("%s: %d %s", path, rec.Code, rec.Body)
+			}
+		}
+	}
+
+	b.Run("barcode", func(b *testing.B) {
+		bench(b, "/parse/barcode", "application/json",
+			jsonBody(b, map[string]string{"barcode": "M1DESMARAIS/LUC       EABC123 YULFRAAC 0834 326J001A0025 100"}))
+	})
+	for _, name := range []string{"aztec-512.png", "qr-1024.png", "aztec-2048.png", "aztec-photo-2016x1512.jpg"} {
+		img := readFile(b, "../testdata/bench/"+name)
+		b.Run("image/"+name, func(b *testing.B) {
+			bench(b, "/parse/barcode/image", "application/json",
+				jsonBody(b, map[string]string{"image": base64.StdEncoding.EncodeToString(img)}))
+		})
+	}
+	pass := readFile(b, "../testdata/bench/boarding.pkpass")
+	for _, c := range []struct {
+		name string
+		pass []byte
+	}{
+		{"pkpass", pass},
+		{"pkpass-large", withLargeImage(b, pass)},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			body, contentType := fileForm(c.pass)
+			bench(b, "/parse/pkpass", contentType, body.Bytes())
+		})
+	}
+}
+
+// withLargeImage copies the archive pass with a stored, random
+// largePkPassImage-byte background@3x.png added.
+func withLargeImage(b *testing.B, pass []byte) []byte {
+	zr, err := zip.NewReader(bytes.NewReader(pass), int64(len(pass)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	var out bytes.Buffer
+	zw := zip.NewWriter(&out)
+	for _, f := range zr.File {
+		if err := zw.Copy(f); err != nil {
+			b.Fatal(err)
+		}
+	}
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "background@3x.png", Method: zip.Store})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := io.CopyN(w, rand.Reader, largePkPassImage); err != nil {
+		b.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		b.Fatal(err)
+	}
+	return out.Bytes()
+}
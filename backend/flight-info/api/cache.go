@@ -0,0 +1,214 @@
+package api
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ----------------------
+// RESPONSE CACHE (LRU + TTL)
+// ----------------------
+
+// parseCache is nil when PARSE_CACHE_SIZE is 0.
+var parseCache *responseCache
+
+// maxCachedResponse keeps one unusually large pass from crowding out the
+// rest; with the entry limit this bounds the cache's memory.
+const maxCachedResponse = 64 << 10
+
+// cacheKeyParams are the query parameters that change a parse response.
+// force=true is not among them: forced parses always bypass the cache.
+var cacheKeyParams = []string{"enrich", "status", "redact", "raw", "detail", "reference_date", "format", "lenient", "decode_profile", "ocr"}
+
+func init() {
+	describeMetric("parse_cache_requests_total", "counter", "Parse response cache lookups by result.")
+	describeMetric("parse_cache_entries", "gauge", "Responses currently held in the parse cache.")
+}
+
+// responseCache maps an input hash to the encoded JSON response. Entries
+// expire after ttl and the least recently used one is evicted at capacity.
+type responseCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List // front = most recently used
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	body    []byte
+	expires time.Time
+}
+
+func newResponseCache(size int, ttl time.Duration) *responseCache {
+	metric("parse_cache_requests_total", "result", "hit")
+	metric("parse_cache_requests_total", "result", "miss")
+	metric("parse_cache_entries")
+	return &responseCache{size: size, ttl: ttl, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *responseCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return e.body, true
+}
+
+func (c *responseCache) Put(key string, body []byte) {
+	if len(body) > maxCachedResponse {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, body: body, expires: time.Now().Add(c.ttl)})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	metric("parse_cache_entries").Set(int64(c.order.Len()))
+}
+
+// SetTTL changes the lifetime of entries stored from now on.
+func (c *responseCache) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	c.ttl = ttl
+	c.mu.Unlock()
+}
+
+// remove must be called with mu held.
+func (c *responseCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+	metric("parse_cache_entries").Set(int64(c.order.Len()))
+}
+
+// parseKey hashes the parse kind, the normalized input, the response-shaping
+// query parameters, the schema version, today's date and, for enriched
+// passes, the display language. It keys the response cache.
+func parseKey(ctx context.Context, kind string, input []byte, q url.Values) string {
+	h := sha256.New()
+	h.Write([]byte(kind))
+	if v := schemaVersion(ctx); v != 0 {
+		h.Write([]byte("/v" + strconv.Itoa(v)))
+	}
+	h.Write([]byte{0})
+	if kind == "barcode" {
+		// Scanners differ in the line ending they append.
+		input = []byte(strings.Trim(string(input), "\r\n"))
+	}
+	h.Write(input)
+	for _, p := range cacheKeyParams {
+		v := q.Get(p)
+		if p == "redact" && wantRedaction(q) {
+			v = "true"
+		}
+		h.Write([]byte{0})
+		h.Write([]byte(p + "=" + v))
+	}
+	// Julian dates resolve around today without reference_date, and the
+	// date window is checked against it: the same input reads differently
+	// tomorrow.
+	h.Write([]byte("\x00today=" + timeNow().Format(time.DateOnly)))
+	if q.Get("enrich") == "true" {
+		// The cities are in the request's language.
+		h.Write([]byte("\x00lang=" + displayLang(ctx)))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// useCache reports whether the request may be served from, and stored in,
+// the cache. A request that stores its pass (see persisting) never may: a
+// hit would skip storing the scan and duplicate detection. Even a duplicate
+// writes, tagging the pass with the client and keeping a pkpass's web
+// service, and a pass deleted since must be stored again, so only
+// ?persist=false requests are cached while persistence is on.
+func useCache(q url.Values) bool {
+	return parseCache != nil && !persisting(q) && q.Get("force") != "true"
+}
+
+// serveCached writes the cached response for key, if any.
+func serveCached(w http.ResponseWriter, r *http.Request, key string) bool {
+	if !useCache(r.URL.Query()) {
+		return false
+	}
+	body, ok := parseCache.Get(key)
+	if !ok {
+		metric("parse_cache_requests_total", "result", "miss").Inc()
+		return false
+	}
+	metric("parse_cache_requests_total", "result", "hit").Inc()
+	w.Header().Set("X-Cache", "HIT")
+	writePassBody(w, r, body)
+	return true
+}
+
+// ----------------------
+// CONDITIONAL REQUESTS (ETAG / IF-NONE-MATCH)
+// ----------------------
+
+func init() {
+	describeMetric("parse_not_modified_total", "counter", "Parse requests answered 304 Not Modified.")
+	metric("parse_not_modified_total")
+}
+
+// wantETag reports whether a parse response gets an ETag. Responses that
+// aren't a function of the input get none: live flight status, forced
+// parses, and parses that store their pass, whose response says whether the
+// pass was already there.
+func wantETag(q url.Values) bool {
+	return q.Get("status") != "true" && q.Get("force") != "true" && !persisting(q)
+}
+
+// bodyETag is the strong ETag of a response body, a hash of its exact
+// bytes. parsed_at differs between parses, so only a body served again from
+// the cache has the same one.
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// writePassBody writes a parse response body with its ETag, or answers 304
+// when If-None-Match lists that ETag. Tags are compared weakly, as RFC 9110
+// §13.1.2 requires, and "*" is never matched. The parse endpoints are POSTs
+// only to carry their input, so this applies the GET semantics of
+// RFC 9110 §13.1.2.
+func writePassBody(w http.ResponseWriter, r *http.Request, body []byte) {
+	if !wantETag(r.URL.Query()) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+		return
+	}
+	etag := bodyETag(body)
+	w.Header().Set("ETag", etag)
+	for _, field := range r.Header.Values("If-None-Match") {
+		for _, tag := range strings.Split(field, ",") {
+			if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+				metric("parse_not_modified_total").Inc()
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// With persistence on, every scan reaches the store: a rescan of a deleted
+// pass stores it again instead of being answered from the cache or 304.
+func TestRescanAfterDeleteIsStored(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &parseCache, newResponseCache(16, time.Minute))
+	h := Handler()
+	barcode := readFixture(t, "bcbp/ac-yul-fra-mandatory.bcbp")
+
+	type passResponse struct {
+		ID        string `json:"id"`
+		Duplicate bool   `json:"duplicate"`
+	}
+	scan := func(header ...string) passResponse {
+		t.Helper()
+		w := postBarcode(t, h, "/parse/barcode", barcode, header...)
+		if w.Code != http.StatusOK {
+			t.Fatalf("scan: status %d: %s", w.Code, w.Body)
+		}
+		if w.Header().Get("X-Cache") == "HIT" {
+			t.Error("scan answered from the cache")
+		}
+		if w.Header().Get("ETag") != "" {
+			t.Errorf("scan has ETag %s", w.Header().Get("ETag"))
+		}
+		var p passResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	first := scan()
+	if first.ID == "" || first.Duplicate {
+		t.Fatalf("first scan: %+v", first)
+	}
+	if again := scan(); !again.Duplicate {
+		t.Errorf("second scan not a duplicate: %+v", again)
+	}
+	if w := serve(t, h, http.MethodDelete, "/passes/"+first.ID, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: status %d: %s", w.Code, w.Body)
+	}
+	if rescan := scan(); rescan.ID != first.ID || rescan.Duplicate {
+		t.Errorf("rescan: %+v", rescan)
+	}
+	if w := serve(t, h, http.MethodGet, "/passes/"+first.ID, nil); w.Code != http.StatusOK {
+		t.Errorf("get after rescan: status %d: %s", w.Code, w.Body)
+	}
+}
+
+// TestPersistFalseIsCached parses with ?persist=false on a server that
+// stores passes: nothing is stored, and the response is cached and
+// revalidates like one without persistence.
+func TestPersistFalseIsCached(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &parseCache, newResponseCache(16, time.Minute))
+	h := Handler()
+	barcode := readFixture(t, "bcbp/ac-yul-fra-mandatory.bcbp")
+
+	first := postBarcode(t, h, "/parse/barcode?persist=false", barcode)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || first.Header().Get("X-Cache") != "MISS" || etag == "" {
+		t.Fatalf("first: status %d, X-Cache %q, ETag %q", first.Code, first.Header().Get("X-Cache"), etag)
+	}
+	if again := postBarcode(t, h, "/parse/barcode?persist=false", barcode); again.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("second: X-Cache %q, want HIT", again.Header().Get("X-Cache"))
+	}
+	if w := postBarcode(t, h, "/parse/barcode?persist=false", barcode, "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Errorf("revalidation: status %d, want 304", w.Code)
+	}
+
+	w := serve(t, h, http.MethodGet, "/passes", nil)
+	var list PassList
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 0 {
+		t.Errorf("%d passes stored, want 0", list.Total)
+	}
+}
+
+// TestETagRoundTrip revalidates a response the cache still holds. Its ETag
+// is strong, a hash of the body.
+func TestETagRoundTrip(t *testing.T) {
+	setForTest(t, &parseCache, newResponseCache(16, time.Minute))
+	h := Handler()
+	barcode := readFixture(t, "bcbp/ac-yul-fra-mandatory.bcbp")
+
+	first := postBarcode(t, h, "/parse/barcode", barcode)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag != bodyETag(first.Body.Bytes()) {
+		t.Fatalf("first: status %d, ETag %q; want the strong ETag of the body, %s", first.Code, etag, bodyETag(first.Body.Bytes()))
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		inm    string
+		status int
+	}{
+		{"match", "/parse/barcode", etag, http.StatusNotModified},
+		{"match in list", "/parse/barcode", `"other", ` + etag, http.StatusNotModified},
+		{"weak form", "/parse/barcode", "W/" + etag, http.StatusNotModified},
+		{"other etag", "/parse/barcode", `"other"`, http.StatusOK},
+		{"star", "/parse/barcode", "*", http.StatusOK},
+		{"other parameters", "/parse/barcode?enrich=true", etag, http.StatusOK},
+		{"force", "/parse/barcode?force=true", etag, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postBarcode(t, h, tt.target, barcode, "If-None-Match", tt.inm)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+			if tt.status == http.StatusNotModified {
+				if w.Body.Len() != 0 {
+					t.Errorf("304 with body %s", w.Body)
+				}
+				if got := w.Header().Get("ETag"); got != etag {
+					t.Errorf("304 ETag %q, want %q", got, etag)
+				}
+			}
+		})
+	}
+}
+
+// TestETagWithoutCache revalidates with the cache off: the barcode is
+// parsed again, with a new parsed_at, so the body and its ETag differ.
+func TestETagWithoutCache(t *testing.T) {
+	setForTest(t, &parseCache, nil)
+	h := Handler()
+	barcode := readFixture(t, "bcbp/ac-yul-fra-mandatory.bcbp")
+
+	first := postBarcode(t, h, "/parse/barcode", barcode)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first: status %d, ETag %q", first.Code, etag)
+	}
+	time.Sleep(1100 * time.Millisecond) // parsed_at has second precision
+	w := postBarcode(t, h, "/parse/barcode", barcode, "If-None-Match", etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", w.Code)
+	}
+	if got := w.Header().Get("ETag"); got != bodyETag(w.Body.Bytes()) || got == etag {
+		t.Errorf("ETag %q, want %s, the strong ETag of the new body", got, bodyETag(w.Body.Bytes()))
+	}
+}
+
+// overnight sets the clock to noon UTC on 2026-11-24, the second day after
+// ac-yul-fra-mandatory's flight, and returns a function moving it a day
+// on, when the flight falls out of the date window.
+func overnight(t *testing.T) (nextDay func()) {
+	t.Helper()
+	now := time.Date(2026, 11, 24, 12, 0, 0, 0, time.UTC)
+	setForTest(t, &timeNow, func() time.Time { return now })
+	return func() { now = now.AddDate(0, 0, 1) }
+}
+
+// TestETagAcrossMidnight revalidates a parse response on the next day,
+// when the same barcode gets a date_suspect warning: the ETag must change
+// with the body.
+func TestETagAcrossMidnight(t *testing.T) {
+	nextDay := overnight(t)
+	setForTest(t, &parseCache, newResponseCache(16, 72*time.Hour))
+	h := Handler()
+	barcode := readFixture(t, "bcbp/ac-yul-fra-mandatory.bcbp")
+
+	first := postBarcode(t, h, "/parse/barcode", barcode)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || strings.Contains(first.Body.String(), "date_suspect") {
+		t.Fatalf("first: status %d, ETag %q: %s", first.Code, etag, first.Body)
+	}
+	if w := postBarcode(t, h, "/parse/barcode", barcode, "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Fatalf("same day: status %d, want 304", w.Code)
+	}
+
+	nextDay()
+	w := postBarcode(t, h, "/parse/barcode", barcode, "If-None-Match", etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("next day: status %d, want 200", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "date_suspect") {
+		t.Errorf("next day: no date_suspect warning: %s", w.Body)
+	}
+	if got := w.Header().Get("ETag"); got == "" || got == etag {
+		t.Errorf("next day: ETag %q, want one other than %q", got, etag)
+	}
+}
+
+// TestCacheAcrossMidnight parses the same barcode twice a day for two days:
+// the second parse of each day is a hit, and the next day's first isn't,
+// so its date_suspect is current.
+func TestCacheAcrossMidnight(t *testing.T) {
+	nextDay := overnight(t)
+	setForTest(t, &parseCache, newResponseCache(16, 72*time.Hour))
+	h := Handler()
+	barcode := readFixture(t, "bcbp/ac-yul-fra-mandatory.bcbp")
+
+	for day, suspect := range []bool{false, true} {
+		for _, want := range []string{"MISS", "HIT"} {
+			w := postBarcode(t, h, "/parse/barcode", barcode)
+			if w.Code != http.StatusOK {
+				t.Fatalf("day %d: status %d: %s", day+1, w.Code, w.Body)
+			}
+			if got := w.Header().Get("X-Cache"); got != want {
+				t.Errorf("day %d: X-Cache %s, want %s", day+1, got, want)
+			}
+			if got := strings.Contains(w.Body.String(), "date_suspect"); got != suspect {
+				t.Errorf("day %d, %s: date_suspect %v, want %v", day+1, want, got, suspect)
+			}
+		}
+		nextDay()
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/profile"
+	"bugsbyte/flight-info/scan"
+)
+
+// ----------------------
+// DISCOVERY: CAPABILITIES
+// ----------------------
+
+// Clients that talk to several deployments need to know what each one
+// does before offering a feature: whether it stores passes, signs Wallet
+// passes, reads Data Matrix. GET /capabilities answers from the running
+// configuration, so it is computed on every request from the same
+// variables the handlers check, never from a list kept by hand.
+
+// Capabilities is the body of GET /capabilities.
+type Capabilities struct {
+	// BarcodeFormats are the formats the image endpoints read, in the
+	// order they are tried.
+	BarcodeFormats []string `json:"barcode_formats"`
+	// ImageFormats are the image containers the image endpoints decode.
+	ImageFormats []string `json:"image_formats"`
+	// DecodeProfiles are the ways an image can be read, and
+	// DecodeProfile the one requests that don't name one get.
+	DecodeProfiles []string `json:"decode_profiles"`
+	DecodeProfile  string   `json:"decode_profile"`
+	Profiles       []string `json:"profiles"`
+	Languages      []string `json:"languages"`
+	// SchemaVersion is the newest pass schema version, and SchemaVersions
+	// every one served (see bcbp.WireSchemas).
+	SchemaVersion  int                `json:"schema_version"`
+	SchemaVersions []int              `json:"schema_versions"`
+	Features       map[string]Feature `json:"features"`
+	Limits         Limits             `json:"limits"`
+}
+
+// Feature is an optional feature and whether this server has it on.
+type Feature struct {
+	Enabled bool `json:"enabled"`
+	// Config is the environment that turns the feature on, empty for
+	// features that can't be configured.
+	Config string `json:"config,omitempty"`
+	// Provider names the backend of an enabled feature that has several.
+	Provider string `json:"provider,omitempty"`
+}
+
+// Limits are the input bounds requests are held to.
+type Limits struct {
+	MaxImageBytes       int64 `json:"max_image_bytes"`
+	MaxImagePixels      int64 `json:"max_image_pixels"`
+	MinImageSide        int   `json:"min_image_side"`
+	MaxPkPassBytes      int64 `json:"max_pkpass_bytes"`
+	MaxBatchImages      int   `json:"max_batch_images"`
+	MaxBatchBytes       int64 `json:"max_batch_bytes"`
+	MaxBatchZipEntries  int   `json:"max_batch_zip_entries"`
+	MaxScanFrameBytes   int   `json:"max_scan_frame_bytes"`
+	ScanFramesPerSecond int   `json:"scan_frames_per_second"`
+	// MaxArtifactBytes is the largest upload kept, when upload storage is
+	// on.
+	MaxArtifactBytes int64 `json:"max_artifact_bytes,omitempty"`
+}
+
+// currentCapabilities reads the capabilities off the configuration.
+func currentCapabilities() Capabilities {
+	langs := make([]string, len(messageLangs))
+	for i, l := range messageLangs {
+		langs[i] = l.String()
+	}
+	versions := make([]int, len(bcbp.WireSchemas))
+	for i, s := range bcbp.WireSchemas {
+		versions[i] = s.Version
+	}
+	c := Capabilities{
+		BarcodeFormats: scan.Formats(),
+		ImageFormats:   scan.ImageFormats(),
+		DecodeProfiles: scan.DecodeProfiles(),
+		DecodeProfile:  serverDecodeProfile(),
+		Profiles:       profile.Names(),
+		Languages:      langs,
+		SchemaVersion:  bcbp.CurrentSchemaVersion,
+		SchemaVersions: versions,
+		Features: map[string]Feature{
+			"persistence":    {Enabled: passStore != nil, Config: "SQLITE_PATH, DATABASE_URL"},
+			"upload_storage": {Enabled: artifactStore != nil, Config: "ARTIFACT_DIR"},
+			"retention":      {Enabled: passJanitor != nil, Config: "PASS_RETENTION"},
+			"push_notify":    {Enabled: passStore != nil, Config: "SQLITE_PATH, DATABASE_URL"},
+			"parse_stats":    {Enabled: parseStats != nil, Config: "SQLITE_PATH, DATABASE_URL"},
+			"flight_status":  {Enabled: flightStatus != nil, Config: "AERODATABOX_API_KEY"},
+			"webhooks":       {Enabled: webhooks != nil, Config: "WEBHOOK_URLS"},
+			"pkpass_refresh": {Enabled: passSecrets != nil, Config: "PASS_SECRET_KEY"},
+			"pass_links":     {Enabled: len(passLinkHosts) > 0, Config: "PASS_LINK_HOSTS"},
+			"ocr":            {Enabled: ocrEngine != nil, Config: "OCR_COMMAND"},
+			"pkpass_signing": {Enabled: pkpassSigner != nil, Config: "PKPASS_CERT, PKPASS_KEY"},
+			"google_wallet":  {Enabled: googleWallet != nil, Config: "GOOGLE_WALLET_KEY"},
+			"redact_all":     {Enabled: redactAll, Config: "REDACT_PII"},
+			"lenient_bcbp":   {Enabled: bcbpLenient, Config: "BCBP_LENIENT"},
+			"parse_cache":    {Enabled: parseCache != nil && passStore == nil, Config: "PARSE_CACHE_SIZE"},
+			"grpc":           {Enabled: grpcAddr != "" && grpcAddr != "off", Config: "GRPC_ADDR"},
+			"tracing":        {Enabled: tracingEnabled, Config: "OTEL_EXPORTER_OTLP_ENDPOINT"},
+			"admin":          {Enabled: adminToken != "", Config: "ADMIN_TOKEN"},
+			// From the embedded dataset, always there.
+			"airline_enrich": {Enabled: true},
+			// Parsing reads pass.json without checking the signature; no
+			// build verifies it yet.
+			"pkpass_signature_check": {Enabled: false},
+		},
+		Limits: Limits{
+			MaxImageBytes:       scan.MaxImageBytes,
+			MaxImagePixels:      scan.MaxImagePixels,
+			MinImageSide:        scan.MinImageSide,
+			MaxPkPassBytes:      maxPkPassUpload,
+			MaxBatchImages:      batchMaxImages,
+			MaxBatchBytes:       maxBatchBody,
+			MaxBatchZipEntries:  batchZipLimits.MaxEntries,
+			MaxScanFrameBytes:   scanFrameMax,
+			ScanFramesPerSecond: scanFPS,
+		},
+	}
+	if flightStatus != nil {
+		f := c.Features["flight_status"]
+		f.Provider = flightStatus.provider.Name()
+		c.Features["flight_status"] = f
+	}
+	if artifactStore != nil {
+		c.Limits.MaxArtifactBytes = artifactStore.maxSize
+	}
+	return c
+}
+
+func handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentCapabilities())
+}
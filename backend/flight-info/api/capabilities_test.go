@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bugsbyte/flight-info/scan"
+	"bugsbyte/flight-info/storage"
+)
+
+// TestCapabilitiesFollowEnv sets each variable the way Serve reads it and
+// checks that /capabilities reports the change, so the body can't drift
+// from the configuration.
+func TestCapabilitiesFollowEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		setup   func(t *testing.T) // configuration before env is read
+		apply   func(t *testing.T) // what Serve does with env
+		feature string
+		want    bool
+	}{
+		{
+			name: "redact", env: map[string]string{"REDACT_PII": "true"},
+			apply:   func(t *testing.T) { setForTest(t, &redactAll, testConfig(t).RedactPII) },
+			feature: "redact_all", want: true,
+		},
+		{
+			name: "lenient", env: map[string]string{"BCBP_LENIENT": "1"},
+			apply:   func(t *testing.T) { setForTest(t, &bcbpLenient, testConfig(t).BCBPLenient) },
+			feature: "lenient_bcbp", want: true,
+		},
+		{
+			name: "admin", env: map[string]string{"ADMIN_TOKEN": "secret"},
+			apply:   func(t *testing.T) { setForTest(t, &adminToken, testConfig(t).AdminToken) },
+			feature: "admin", want: true,
+		},
+		{
+			name: "persistence", env: map[string]string{"SQLITE_PATH": filepath.Join(t.TempDir(), "passes.db")},
+			apply:   openStoreFromEnv,
+			feature: "persistence", want: true,
+		},
+		{
+			// A pass store turns the parse cache off.
+			name: "persistence without cache", env: map[string]string{"SQLITE_PATH": filepath.Join(t.TempDir(), "passes.db")},
+			setup:   func(t *testing.T) { setForTest(t, &parseCache, newResponseCache(8, time.Minute)) },
+			apply:   openStoreFromEnv,
+			feature: "parse_cache", want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+			if got := capabilities(t).Features[tt.feature].Enabled; got == tt.want {
+				t.Fatalf("%s: enabled = %v before setting %v", tt.feature, got, tt.env)
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			tt.apply(t)
+			if got := capabilities(t).Features[tt.feature].Enabled; got != tt.want {
+				t.Errorf("%s: enabled = %v with %v, want %v", tt.feature, got, tt.env, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapabilitiesFollowLimits(t *testing.T) {
+	t.Setenv("WS_SCAN_FPS", "3")
+	setForTest(t, &scanFPS, testConfig(t).WSScanFPS)
+	old := serverDecodeProfile()
+	t.Cleanup(func() { defaultDecodeProfile.Store(old) })
+	profiles := scan.DecodeProfiles()
+	want := profiles[len(profiles)-1]
+	t.Setenv("DECODE_PROFILE", want)
+	defaultDecodeProfile.Store(testConfig(t).DecodeProfile)
+
+	c := capabilities(t)
+	if c.Limits.ScanFramesPerSecond != 3 {
+		t.Errorf("scan_frames_per_second = %d, want 3", c.Limits.ScanFramesPerSecond)
+	}
+	if c.DecodeProfile != want {
+		t.Errorf("decode_profile = %q, want %q", c.DecodeProfile, want)
+	}
+}
+
+// openStoreFromEnv opens the pass store SQLITE_PATH or DATABASE_URL
+// names, as Serve does.
+func openStoreFromEnv(t *testing.T) {
+	u, err := testConfig(t).databaseURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := storage.Open(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	setForTest(t, &passStore, s)
+}
+
+// capabilities fetches GET /capabilities.
+func capabilities(t *testing.T) Capabilities {
+	t.Helper()
+	w := serve(t, Handler(), http.MethodGet, "/capabilities", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var c Capabilities
+	if err := json.Unmarshal(w.Body.Bytes(), &c); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
@@ -0,0 +1,307 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"bugsbyte/flight-info/bagtag"
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/eticket"
+)
+
+// ----------------------
+// DEBUGGING: TRAFFIC CAPTURE (CAPTURE_DIR)
+// ----------------------
+
+// With CAPTURE_DIR set, the three parse endpoints write each input and the
+// parser's result to CAPTURE_DIR/<kind>/, for `flightinfo corpus promote`
+// to turn into golden files. Everything is masked in memory before it is
+// written: barcode text loses the passenger name and PNR, pass.json the name, PNR
+// and authentication token, and images are reduced to their size and the
+// decoded (masked) text. The result is stored redacted. A request with
+// "X-Capture: off" is not captured. Cache hits are not captured either,
+// since nothing was parsed.
+
+// captureDir is CAPTURE_DIR; "" turns capture off.
+var captureDir string
+
+// Capture is one captured request, as written to CAPTURE_DIR.
+type Capture struct {
+	Kind       string    `json:"kind"` // "barcode", "pkpass" or "image"
+	CapturedAt time.Time `json:"captured_at"`
+	Endpoint   string    `json:"endpoint"`
+	RequestID  string    `json:"request_id,omitempty"`
+
+	// The input, masked. Barcode is also the text decoded from an image.
+	Barcode  string          `json:"barcode,omitempty"`
+	PassJSON json.RawMessage `json:"pass_json,omitempty"`
+	Image    *CaptureImage   `json:"image,omitempty"`
+
+	// What the parser made of it: a redacted pass, a bag tag, a redacted
+	// ticket receipt or an error.
+	Pass    *bcbp.UnifiedBoardingPass `json:"pass,omitempty"`
+	BagTag  *bagtag.Tag               `json:"bag_tag,omitempty"`
+	Receipt *eticket.Receipt          `json:"eticket_receipt,omitempty"`
+	Error   string                    `json:"error,omitempty"`
+}
+
+// CaptureImage describes a captured image; the pixels are never kept.
+type CaptureImage struct {
+	Format        string `json:"format,omitempty"` // "png", "jpeg", ...
+	Width         int    `json:"width,omitempty"`
+	Height        int    `json:"height,omitempty"`
+	Bytes         int    `json:"bytes"`
+	BarcodeFormat string `json:"barcode_format,omitempty"`
+}
+
+func capturing(r *http.Request) bool {
+	return captureDir != "" && !strings.EqualFold(r.Header.Get("X-Capture"), "off")
+}
+
+// captureBarcode captures barcode text and what it parsed to.
+func captureBarcode(r *http.Request, text string, p *bcbp.UnifiedBoardingPass, doc any, err error) {
+	if !capturing(r) {
+		return
+	}
+	c := Capture{Kind: "barcode", Barcode: maskBarcodeText(text)}
+	c.setResult(p, doc, err)
+	writeCapture(r, c)
+}
+
+// captureImage captures an image's metadata, the text decoded from it, if
+// any, and what that parsed to.
+func captureImage(r *http.Request, img []byte, text, format string, p *bcbp.UnifiedBoardingPass, doc any, err error) {
+	if !capturing(r) {
+		return
+	}
+	meta := &CaptureImage{Bytes: len(img), BarcodeFormat: format}
+	if cfg, name, err := image.DecodeConfig(bytes.NewReader(img)); err == nil {
+		meta.Format, meta.Width, meta.Height = name, cfg.Width, cfg.Height
+	}
+	c := Capture{Kind: "image", Barcode: maskBarcodeText(text), Image: meta}
+	c.setResult(p, doc, err)
+	writeCapture(r, c)
+}
+
+// capturePkPass captures the pass.json of an upload, masked with the help
+// of p when the parse succeeded. passJSON is nil for uploads without one.
+func capturePkPass(r *http.Request, passJSON []byte, p *bcbp.UnifiedBoardingPass, err error) {
+	if !capturing(r) {
+		return
+	}
+	c := Capture{Kind: "pkpass"}
+	if passJSON != nil {
+		masked, merr := maskPassJSON(passJSON, p)
+		if merr != nil {
+			// Not JSON, so there is nothing to mask it by: leave it out.
+			slog.WarnContext(r.Context(), "pass.json not captured", "err", merr)
+		} else {
+			c.PassJSON = masked
+		}
+	}
+	c.setResult(p, nil, err)
+	writeCapture(r, c)
+}
+
+// setResult records the outcome: err, or p, or doc, a *bagtag.Tag or an
+// *eticket.Receipt.
+func (c *Capture) setResult(p *bcbp.UnifiedBoardingPass, doc any, err error) {
+	tag, _ := doc.(*bagtag.Tag)
+	receipt, _ := doc.(*eticket.Receipt)
+	switch {
+	case err != nil:
+		c.Error = err.Error()
+	case tag != nil:
+		c.BagTag = tag
+	case receipt != nil:
+		cp := *receipt
+		redactReceipt(&cp)
+		c.Receipt = &cp
+	case p != nil:
+		cp := *p
+		cp.RawData = maps.Clone(p.RawData)
+		cp.FieldSources = maps.Clone(p.FieldSources)
+		cp.Warnings = slices.Clone(p.Warnings)
+		cp.Passengers, cp.Legs = cloneLegs(p.Passengers), cloneLegs(p.Legs)
+		cp.Detail = nil
+		RedactPass(&cp)
+		c.Pass = &cp
+	}
+}
+
+// cloneLegs copies legs deep enough for RedactPass to mask the copy.
+func cloneLegs(legs []bcbp.PassengerLeg) []bcbp.PassengerLeg {
+	legs = slices.Clone(legs)
+	for i, l := range legs {
+		legs[i].Conditional = maps.Clone(l.Conditional)
+	}
+	return legs
+}
+
+// writeCapture writes c as CAPTURE_DIR/<kind>/<time>-<request id>.json.
+// Failures are logged; capture never fails a request.
+func writeCapture(r *http.Request, c Capture) {
+	c.CapturedAt = time.Now().UTC()
+	c.Endpoint = r.URL.Path
+	c.RequestID = r.Header.Get("X-Request-ID")
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err == nil {
+		dir := filepath.Join(captureDir, c.Kind)
+		name := c.CapturedAt.Format("20060102T150405.000") + "-" + c.RequestID + ".json"
+		if err = os.MkdirAll(dir, 0o700); err == nil {
+			err = os.WriteFile(filepath.Join(dir, name), append(b, '\n'), 0o600)
+		}
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error writing capture", "err", err)
+	}
+}
+
+// capturedName replaces the name field of captured barcodes.
+const capturedName = "PASSENGER/CAPTURED  "
+
+// maskBarcodeText masks the passenger name of BCBP text, and the PNR, the
+// names on the legs of a group pass and the identifying values the parser
+// finds (frequent flyer number, date of birth, ...) wherever they appear (later legs usually repeat them), after
+// undoing any UTF-16 or byte order mark so the fields are where they
+// should be. Masks keep the width, so the text still parses. A bag tag has
+// nothing to mask. Any other text could hold anything, so its letters
+// become X and its digits 9.
+func maskBarcodeText(text string) string {
+	if text == "" {
+		return ""
+	}
+	if _, ok := bagtag.Parse(text); ok {
+		return text
+	}
+	if r, ok := eticket.Parse(text); ok {
+		masked := r.AirlinePrefix + "9999999999"
+		if r.CheckDigit != "" {
+			masked += eticket.CheckDigit("9999999999")
+		}
+		return masked
+	}
+	text = bcbp.NormalizeEncoding(text)
+	rs := []rune(text)
+	if rs[0] != 'M' && rs[0] != 'S' && rs[0] != 'm' && rs[0] != 's' {
+		return strings.Map(func(r rune) rune {
+			switch {
+			case unicode.IsLetter(r):
+				return 'X'
+			case unicode.IsDigit(r):
+				return '9'
+			}
+			return r
+		}, text)
+	}
+	if p, err := bcbp.Parse(text); err == nil {
+		secrets := []string{p.PNR}
+		for _, l := range p.Passengers {
+			secrets = append(secrets, l.PassengerName, l.PNR)
+		}
+		personal := bcbp.PersonalAirlineUseKeys(p.Carrier)
+		for k, v := range p.RawData {
+			if isPIIKey(k, personal) {
+				secrets = append(secrets, v)
+			}
+		}
+		for _, v := range secrets {
+			if v = strings.TrimSpace(v); len(v) >= 3 {
+				text = strings.ReplaceAll(text, v, strings.Repeat("X", len(v)))
+			}
+		}
+	}
+	rs = []rune(text)
+	for i := 2; i < 22 && i < len(rs); i++ {
+		rs[i] = rune(capturedName[i-2])
+	}
+	return string(rs)
+}
+
+// passJSONSecretKeys are pass.json keys dropped outright: they let anyone
+// holding them talk to the airline's pass web service.
+var passJSONSecretKeys = []string{"authenticationToken", "webServiceURL"}
+
+// passJSONPIIKeys are pass.json and semantic tag keys whose whole value is
+// masked. userInfo is the issuer's own data and could hold anything.
+var passJSONPIIKeys = []string{"passengerName", "confirmationNumber", "membershipProgramNumber", "userInfo"}
+
+// maskPassJSON masks a pass.json: the passJSONPIIKeys, and fields whose
+// key names the passenger or the booking (see isPIIKey), are masked whole,
+// barcode messages lose the name, and words of p's passenger name and its
+// PNR are masked wherever else they appear.
+func maskPassJSON(data []byte, p *bcbp.UnifiedBoardingPass) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	var secrets []*regexp.Regexp
+	if p != nil {
+		for _, w := range append(strings.FieldsFunc(p.PassengerName, func(r rune) bool {
+			return !unicode.IsLetter(r)
+		}), strings.TrimSpace(p.PNR)) {
+			if len([]rune(w)) >= 3 {
+				secrets = append(secrets, regexp.MustCompile(`(?i)`+regexp.QuoteMeta(w)))
+			}
+		}
+	}
+	maskAll := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return 'X'
+			}
+			return r
+		}, s)
+	}
+	var walk func(v any, pii bool) any
+	walk = func(v any, pii bool) any {
+		switch v := v.(type) {
+		case map[string]any:
+			for _, k := range passJSONSecretKeys {
+				delete(v, k)
+			}
+			// A field: {"key": "passenger", "label": ..., "value": ...}.
+			key, _ := v["key"].(string)
+			fieldPII := key != "" && isPIIKey(key, nil)
+			for k, child := range v {
+				if k == "key" || k == "label" {
+					continue
+				}
+				v[k] = walk(child, pii || slices.Contains(passJSONPIIKeys, k) ||
+					(fieldPII && (k == "value" || k == "attributedValue")))
+			}
+			return v
+		case []any:
+			for i, child := range v {
+				v[i] = walk(child, pii)
+			}
+			return v
+		case string:
+			if pii {
+				return maskAll(v)
+			}
+			if len(v) >= 60 && (v[0] == 'M' || v[0] == 'S') {
+				v = maskBarcodeText(v)
+			}
+			for _, re := range secrets {
+				v = re.ReplaceAllStringFunc(v, func(m string) string { return strings.Repeat("X", len([]rune(m))) })
+			}
+			return v
+		}
+		return v
+	}
+	return json.Marshal(walk(v, false))
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+)
+
+// ----------------------
+// CLIENT HISTORY
+// ----------------------
+
+// The mobile app shows "recently scanned on this device" without accounts:
+// it sends a random ID of its own in X-Client-ID, every pass stored from
+// such a request is tagged with it, and GET /passes?client=me lists the
+// passes tagged with the caller's. The ID is opaque to the server and never
+// returned. Passes stored without one are never in a client's history, and
+// a pass scanned on two devices is in both.
+
+// clientIDHeader names the caller's device.
+const clientIDHeader = "X-Client-ID"
+
+// clientIDPattern is long enough that IDs can't be guessed from a
+// counter or a name; a UUID fits.
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9._~-]{16,128}$`)
+
+type clientIDKey struct{}
+
+// clientMiddleware puts a well-formed X-Client-ID in the request context
+// (see clientID) and rejects a malformed one, so a client bug shows up at
+// once instead of as an empty history.
+func clientMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(clientIDHeader)
+		if id == "" {
+			next(w, r)
+			return
+		}
+		if !clientIDPattern.MatchString(id) {
+			writeError(w, http.StatusBadRequest, invalidParam(clientIDHeader,
+				"X-Client-ID must be 16 to 128 letters, digits or ._~- characters"))
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), clientIDKey{}, id)))
+	}
+}
+
+// clientID is the request's X-Client-ID, "" for none.
+func clientID(ctx context.Context) string {
+	id, _ := ctx.Value(clientIDKey{}).(string)
+	return id
+}
+
+// clientFilter resolves the client query parameter, which can only be
+// "me": the caller's own X-Client-ID. The error is meant for the client.
+func clientFilter(r *http.Request) (string, error) {
+	if r.URL.Query().Get("client") != "me" {
+		return "", errors.New(`client must be "me"`)
+	}
+	id := clientID(r.Context())
+	if id == "" {
+		return "", errors.New("client=me needs an X-Client-ID header")
+	}
+	return id, nil
+}
+
+// tagClient tags the stored pass id with the request's client, if any.
+// Failures are logged; the pass is stored either way.
+func tagClient(ctx context.Context, id string) {
+	client := clientID(ctx)
+	if client == "" {
+		return
+	}
+	if err := passStore.TagClient(id, client); err != nil {
+		slog.ErrorContext(ctx, "Error tagging pass with its client", "pass", id, "err", err)
+	}
+}
+
+// ForgetReport is the body of DELETE /passes?client=me.
+type ForgetReport struct {
+	Deleted int `json:"deleted"`
+	Kept    int `json:"kept"` // in another client's history
+}
+
+// handlePasses is GET /passes, and DELETE /passes?client=me.
+func handlePasses(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		handleListPasses(w, r)
+		return
+	}
+	if passStore == nil {
+		httpError(w, "Persistence is disabled (set SQLITE_PATH or DATABASE_URL)", http.StatusNotImplemented)
+		return
+	}
+	// Anything but client=me alone would read as "delete every pass".
+	q := r.URL.Query()
+	q.Del("lang")
+	if len(q) != 1 || len(q["client"]) != 1 {
+		httpError(w, `DELETE /passes takes exactly client=me`, http.StatusBadRequest)
+		return
+	}
+	client, err := clientFilter(r)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deleted, kept, err := passStore.ForgetClient(client)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting client history", "err", err)
+		httpError(w, "Error deleting client history", http.StatusInternalServerError)
+		return
+	}
+	if deleted > 0 {
+		pruneArtifacts(r.Context())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ForgetReport{Deleted: deleted, Kept: kept})
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// ----------------------
+// CONFIG: TYPED VALUES
+// ----------------------
+
+// Config is the value of every setting, typed: what Serve and the import
+// command configure themselves from. Each field's setting tag names its
+// entry in settings, which holds the default, so Config has none of its
+// own. CONFIG_FILE, which says where the rest comes from, is the only
+// setting without a field.
+type Config struct {
+	LogLevel         string `setting:"LOG_LEVEL"`
+	LogFormat        string `setting:"LOG_FORMAT"`
+	ListenSocket     string `setting:"LISTEN_SOCKET"`
+	ListenSocketMode string `setting:"LISTEN_SOCKET_MODE"`
+	GRPCAddr         string `setting:"GRPC_ADDR"`
+	AdminToken       string `setting:"ADMIN_TOKEN"`
+	DebugEndpoints   bool   `setting:"DEBUG_ENDPOINTS"`
+	ProblemTypeBase  string `setting:"PROBLEM_TYPE_BASE"`
+
+	RedactPII          bool          `setting:"REDACT_PII"`
+	BCBPLenient        bool          `setting:"BCBP_LENIENT"`
+	SeatSentinels      string        `setting:"SEAT_SENTINELS"`
+	PkPassMapper       string        `setting:"PKPASS_MAPPER"`
+	PkPassShadowMapper bool          `setting:"PKPASS_SHADOW_MAPPER"`
+	DateWindowPast     time.Duration `setting:"DATE_WINDOW_PAST"`
+	DateWindowFuture   time.Duration `setting:"DATE_WINDOW_FUTURE"`
+
+	DecodeProfile     string        `setting:"DECODE_PROFILE"`
+	ImageMinSide      int           `setting:"IMAGE_MIN_SIDE"`
+	ImageMinSharpness float64       `setting:"IMAGE_MIN_SHARPNESS"`
+	BatchMaxImages    int           `setting:"BATCH_MAX_IMAGES"`
+	BatchWorkers      int           `setting:"BATCH_WORKERS"`
+	ImportWorkers     int           `setting:"IMPORT_WORKERS"`
+	JobTTL            time.Duration `setting:"JOB_TTL"`
+	JobMax            int           `setting:"JOB_MAX"`
+	WSScanMaxFrame    int           `setting:"WS_SCAN_MAX_FRAME"`
+	WSScanFPS         int           `setting:"WS_SCAN_FPS"`
+	OCRCommand        string        `setting:"OCR_COMMAND"`
+	OCRTimeout        time.Duration `setting:"OCR_TIMEOUT"`
+
+	ParseCacheSize    int           `setting:"PARSE_CACHE_SIZE"`
+	ParseCacheTTL     time.Duration `setting:"PARSE_CACHE_TTL"`
+	LookupCacheMaxAge time.Duration `setting:"LOOKUP_CACHE_MAX_AGE"`
+	ParseFailuresSize int           `setting:"PARSE_FAILURES_SIZE"`
+	DebugCapture      bool          `setting:"DEBUG_CAPTURE"`
+	CaptureDir        string        `setting:"CAPTURE_DIR"`
+
+	HeavyMaxConcurrent  int           `setting:"HEAVY_MAX_CONCURRENT"`
+	HeavyMaxQueue       int           `setting:"HEAVY_MAX_QUEUE"`
+	HeavyMaxWait        time.Duration `setting:"HEAVY_MAX_WAIT"`
+	RateLimitLight      int           `setting:"RATE_LIMIT_LIGHT"`
+	RateLimitLightBurst int           `setting:"RATE_LIMIT_LIGHT_BURST"` // 0: ten seconds' worth
+	RateLimitHeavy      int           `setting:"RATE_LIMIT_HEAVY"`
+	RateLimitHeavyBurst int           `setting:"RATE_LIMIT_HEAVY_BURST"` // 0: ten seconds' worth
+	TrustedProxies      string        `setting:"TRUSTED_PROXIES"`
+
+	UpstreamBreakerFailures int           `setting:"UPSTREAM_BREAKER_FAILURES"`
+	UpstreamBreakerCooldown time.Duration `setting:"UPSTREAM_BREAKER_COOLDOWN"`
+	RequestBudget           time.Duration `setting:"REQUEST_BUDGET"`
+	PassLinkHosts           string        `setting:"PASS_LINK_HOSTS"`
+	AeroDataBoxAPIKey       string        `setting:"AERODATABOX_API_KEY"`
+	AeroDataBoxURL          string        `setting:"AERODATABOX_URL"`
+	FlightStatusCacheTTL    time.Duration `setting:"FLIGHT_STATUS_CACHE_TTL"`
+	FlightStatusTimeout     time.Duration `setting:"FLIGHT_STATUS_TIMEOUT"`
+	AirlineLogoURL          string        `setting:"AIRLINE_LOGO_URL"`
+
+	SQLitePath          string        `setting:"SQLITE_PATH"`
+	DatabaseURL         string        `setting:"DATABASE_URL"`
+	ArtifactDir         string        `setting:"ARTIFACT_DIR"`
+	ArtifactMaxBytes    int           `setting:"ARTIFACT_MAX_BYTES"`
+	ArtifactDirMaxBytes int           `setting:"ARTIFACT_DIR_MAX_BYTES"`
+	PassSecretKey       string        `setting:"PASS_SECRET_KEY"`
+	PassRefreshTimeout  time.Duration `setting:"PASS_REFRESH_TIMEOUT"`
+	PassRetention       time.Duration `setting:"PASS_RETENTION"`
+	PassCleanupInterval time.Duration `setting:"PASS_CLEANUP_INTERVAL"`
+	NotifyInterval      time.Duration `setting:"NOTIFY_INTERVAL"`
+	ExpoPushURL         string        `setting:"EXPO_PUSH_URL"`
+	StatsFlushInterval  time.Duration `setting:"STATS_FLUSH_INTERVAL"`
+
+	PkPassCert           string `setting:"PKPASS_CERT"`
+	PkPassKey            string `setting:"PKPASS_KEY"`
+	PkPassWWDR           string `setting:"PKPASS_WWDR"`
+	PkPassTypeID         string `setting:"PKPASS_TYPE_ID"`
+	PkPassTeamID         string `setting:"PKPASS_TEAM_ID"`
+	GoogleWalletKey      string `setting:"GOOGLE_WALLET_KEY"`
+	GoogleWalletIssuerID string `setting:"GOOGLE_WALLET_ISSUER_ID"`
+
+	WebhookConfig      string        `setting:"WEBHOOK_CONFIG"`
+	WebhookURLs        string        `setting:"WEBHOOK_URLS"`
+	WebhookSecret      string        `setting:"WEBHOOK_SECRET"`
+	WebhookWorkers     int           `setting:"WEBHOOK_WORKERS"`
+	WebhookMaxAttempts int           `setting:"WEBHOOK_MAX_ATTEMPTS"`
+	WebhookTimeout     time.Duration `setting:"WEBHOOK_TIMEOUT"`
+
+	OTLPEndpoint       string `setting:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	OTLPTracesEndpoint string `setting:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"`
+	OTLPProtocol       string `setting:"OTEL_EXPORTER_OTLP_PROTOCOL"`
+	OTLPTracesProtocol string `setting:"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"`
+	OTELTracesExporter string `setting:"OTEL_TRACES_EXPORTER"`
+	OTELSDKDisabled    bool   `setting:"OTEL_SDK_DISABLED"`
+}
+
+// defaultConfig is every setting at its default: what Handler and the
+// tests run with, and where the package's variables start from.
+var defaultConfig = readConfig(func(s *setting) string { return s.def })
+
+// loadConfig checks the settings (see checkSettings) and reads them into
+// a Config. As for the environment, an empty value is the default unless
+// the setting is emptyOK. An invalid value leaves its field at the
+// default too, and err lists every invalid value.
+func loadConfig() (Config, error) {
+	err := checkSettings()
+	return readConfig(func(s *setting) string {
+		v, _ := s.value()
+		if v == "" && !s.emptyOK || v != "" && s.check(v) != nil {
+			return s.def
+		}
+		return v
+	}), err
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// readConfig fills each field of a Config from value(s) of its setting.
+func readConfig(value func(s *setting) string) Config {
+	var c Config
+	rv := reflect.ValueOf(&c).Elem()
+	for i := range rv.NumField() {
+		field := rv.Type().Field(i)
+		s, ok := lookupSetting(field.Tag.Get("setting"))
+		if !ok {
+			panic("Config." + field.Name + " has no setting")
+		}
+		if err := setField(rv.Field(i), value(s)); err != nil {
+			panic("invalid value of " + s.name + ": " + err.Error())
+		}
+	}
+	return c
+}
+
+// setField parses v into f, a Config field; "" is the zero value.
+func setField(f reflect.Value, v string) error {
+	if v == "" {
+		f.SetZero()
+		return nil
+	}
+	switch {
+	case f.Type() == durationType:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+	case f.Kind() == reflect.String:
+		f.SetString(v)
+	case f.Kind() == reflect.Int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(n))
+	case f.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	case f.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+	return nil
+}
+
+// loadSeatSentinels adds SEAT_SENTINELS, a comma-separated list of
+// VALUE=status such as "VOIR AGENT=see_agent", to bcbp.SeatSentinels.
+func loadSeatSentinels(list string) error {
+	for _, entry := range strings.Split(list, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		value, status, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("SEAT_SENTINELS: %q is not VALUE=status", entry)
+		}
+		if err := bcbp.AddSeatSentinel(value, strings.TrimSpace(status)); err != nil {
+			return fmt.Errorf("SEAT_SENTINELS: %w", err)
+		}
+	}
+	return nil
+}
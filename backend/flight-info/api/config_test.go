@@ -0,0 +1,90 @@
+package api
+
+import (
+	"maps"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestConfigCoversSettings checks that every setting but CONFIG_FILE has
+// exactly one Config field, so a new setting can't be left out of it.
+func TestConfigCoversSettings(t *testing.T) {
+	fields := map[string]int{}
+	for _, f := range reflect.VisibleFields(reflect.TypeFor[Config]()) {
+		fields[f.Tag.Get("setting")]++
+	}
+	for _, s := range settings {
+		want := 1
+		if s.name == "CONFIG_FILE" {
+			want = 0
+		}
+		if got := fields[s.name]; got != want {
+			t.Errorf("%s: %d Config fields, want %d", s.name, got, want)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("BATCH_WORKERS", "7")
+	t.Setenv("JOB_TTL", "2m")
+	t.Setenv("REDACT_PII", "true")
+	t.Setenv("IMAGE_MIN_SHARPNESS", "0.5")
+	cfg := testConfig(t)
+	if cfg.BatchWorkers != 7 || cfg.JobTTL != 2*time.Minute || !cfg.RedactPII || cfg.ImageMinSharpness != 0.5 {
+		t.Errorf("set values not read: %+v", cfg)
+	}
+	if cfg.BatchMaxImages != defaultConfig.BatchMaxImages || cfg.LogLevel != "info" {
+		t.Errorf("unset values not the defaults: %+v", cfg)
+	}
+
+	t.Setenv("BATCH_WORKERS", "0")
+	cfg, err := loadConfig()
+	if err == nil {
+		t.Fatal("BATCH_WORKERS=0 accepted")
+	}
+	if cfg.BatchWorkers != defaultConfig.BatchWorkers {
+		t.Errorf("invalid BATCH_WORKERS read as %d, want the default %d", cfg.BatchWorkers, defaultConfig.BatchWorkers)
+	}
+}
+
+// TestParseYAMLConfig reads flat NAME: value files, and rejects the YAML
+// that isn't one by line instead of reading it as a value.
+func TestParseYAMLConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want map[string]string
+		err  string // substring of the error, "" for none
+	}{
+		{name: "plain", yaml: "PARSE_CACHE_TTL: 30s\ndecode_profile: print # fast\n", want: map[string]string{"PARSE_CACHE_TTL": "30s", "DECODE_PROFILE": "print"}},
+		{name: "quoted with hash", yaml: "WEBHOOK_SECRET: \"a#b\"\nADMIN_TOKEN: 'c # d'\n", want: map[string]string{"WEBHOOK_SECRET": "a#b", "ADMIN_TOKEN": "c # d"}},
+		{name: "block scalar", yaml: "WEBHOOK_URLS: >-\n  https://a.example.com/p,\n  https://b.example.com/p\n", want: map[string]string{"WEBHOOK_URLS": "https://a.example.com/p, https://b.example.com/p"}},
+		{name: "null", yaml: "ADMIN_TOKEN: ~\n", want: map[string]string{"ADMIN_TOKEN": ""}},
+		{name: "empty", yaml: "# nothing set\n", want: map[string]string{}},
+		{name: "nested map", yaml: "PARSE_CACHE_TTL: 30s\nRATE_LIMIT:\n  per_minute: 60\n", err: "line 3: RATE_LIMIT"},
+		{name: "flow sequence", yaml: "WEBHOOK_URLS: [https://a.example.com]\n", err: "line 1: WEBHOOK_URLS"},
+		{name: "block sequence", yaml: "TRUSTED_PROXIES:\n  - 10.0.0.1\n", err: "line 2: TRUSTED_PROXIES"},
+		{name: "not a mapping", yaml: "- LOG_LEVEL\n", err: "line 1"},
+		{name: "twice", yaml: "LOG_LEVEL: info\nlog_level: debug\n", err: "line 2: LOG_LEVEL is set twice"},
+		{name: "malformed", yaml: "LOG_LEVEL: \"info\n", err: "line"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAMLConfig([]byte(tt.yaml))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// ----------------------
+// HANDLERS: CONFORMANCE REPORT
+// ----------------------
+
+// POST /analyze/conformance takes a set of barcode texts, say a day's scans
+// from the gates, and returns bcbp.Conform's tally by operating carrier:
+// which airlines print clean barcodes, which ones parse with findings, and
+// which don't parse at all. The barcodes themselves are never logged or
+// echoed back; examples point at them by index and offset, and excerpts of
+// the fields that identify the passenger are masked.
+
+// maxConformanceBarcodes and maxConformanceBody bound a report request.
+const (
+	maxConformanceBarcodes = 10000
+	maxConformanceBody     = 16 << 20
+)
+
+// ConformanceRequest is the body of POST /analyze/conformance.
+type ConformanceRequest struct {
+	Barcodes []string `json:"barcodes"`
+}
+
+// conformanceCSVHeader is the column order of the CSV report.
+var conformanceCSVHeader = []string{"carrier", "kind", "code", "count", "examples"}
+
+func handleConformance(w http.ResponseWriter, r *http.Request) {
+	var req ConformanceRequest
+	if status, d := decodeJSONBody(w, r, maxConformanceBody, &req); status != 0 {
+		writeError(w, status, d)
+		return
+	}
+	switch {
+	case len(req.Barcodes) == 0:
+		httpError(w, "barcodes is required", http.StatusBadRequest)
+		return
+	case len(req.Barcodes) > maxConformanceBarcodes:
+		writeError(w, http.StatusRequestEntityTooLarge, ErrorDetail{
+			Reason:  reasonTooLarge,
+			Message: fmt.Sprintf("At most %d barcodes per report", maxConformanceBarcodes),
+		})
+		return
+	}
+	ref, status, d := referenceDate(r.URL.Query())
+	if status != 0 {
+		writeError(w, status, d)
+		return
+	}
+
+	report := bcbp.Conform(req.Barcodes, ref)
+	if headerContains(r.Header, "Accept", "text/csv") {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		writeConformanceCSV(w, report)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// writeConformanceCSV writes report one row per count and group: for each
+// carrier the kinds total, clean, with_warnings and failed, then a warning
+// or failure row per group. examples lists index@offset, or the index
+// alone for parser warnings.
+func writeConformanceCSV(w http.ResponseWriter, report *bcbp.ConformanceReport) {
+	cw := csv.NewWriter(w)
+	cw.Write(conformanceCSVHeader)
+	for _, c := range report.Carriers {
+		for _, count := range []struct {
+			kind string
+			n    int
+		}{{"total", c.Total}, {"clean", c.Clean}, {"with_warnings", c.WithWarnings}, {"failed", c.Failed}} {
+			cw.Write([]string{c.Carrier, count.kind, "", strconv.Itoa(count.n), ""})
+		}
+		for _, groups := range []struct {
+			kind   string
+			groups []bcbp.ConformanceGroup
+		}{{"warning", c.Warnings}, {"failure", c.Failures}} {
+			for _, g := range groups.groups {
+				examples := make([]string, len(g.Examples))
+				for i, ex := range g.Examples {
+					examples[i] = strconv.Itoa(ex.Index)
+					if ex.Offset != nil {
+						examples[i] += "@" + strconv.Itoa(*ex.Offset)
+					}
+				}
+				cw.Write([]string{c.Carrier, groups.kind, g.Code, strconv.Itoa(g.Count), strings.Join(examples, " ")})
+			}
+		}
+	}
+	cw.Flush()
+}
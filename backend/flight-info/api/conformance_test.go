@@ -0,0 +1,71 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// TestConformanceMixedCarriers posts the mixed-carrier fixture and checks
+// that the JSON and CSV reports carry bcbp.Conform's tally.
+func TestConformanceMixedCarriers(t *testing.T) {
+	raws := strings.Split(strings.TrimSuffix(readFixture(t, "conformance/mixed-carriers.barcodes"), "\n"), "\n")
+	ref := time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC) // fixture.Reference
+	want := bcbp.Conform(raws, ref)
+	body := jsonBody(t, ConformanceRequest{Barcodes: raws})
+	target := "/analyze/conformance?reference_date=" + ref.Format(time.DateOnly)
+
+	w := serve(t, Handler(), http.MethodPost, target, bytes.NewReader(body), "Content-Type", "application/json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	wantJSON, _ := json.Marshal(want)
+	if got := bytes.TrimSpace(w.Body.Bytes()); !bytes.Equal(got, wantJSON) {
+		t.Errorf("JSON report:\n%s\nwant:\n%s", got, wantJSON)
+	}
+	for _, raw := range raws {
+		if len(raw) < 22 {
+			continue
+		}
+		if name := strings.TrimSpace(raw[2:22]); strings.Contains(w.Body.String(), name) {
+			t.Errorf("report echoes the name %q", name)
+		}
+	}
+
+	w = serve(t, Handler(), http.MethodPost, target, bytes.NewReader(body), "Content-Type", "application/json", "Accept", "text/csv")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("CSV: status %d, Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	totals := map[string]int{}
+	groups := 0
+	for _, row := range rows[1:] {
+		n, _ := strconv.Atoi(row[3])
+		switch row[1] {
+		case "total":
+			totals[row[0]] = n
+		case "warning", "failure":
+			groups++
+		}
+	}
+	wantGroups := 0
+	for _, c := range want.Carriers {
+		if totals[c.Carrier] != c.Total {
+			t.Errorf("CSV total for %q = %d, want %d", c.Carrier, totals[c.Carrier], c.Total)
+		}
+		wantGroups += len(c.Warnings) + len(c.Failures)
+	}
+	if len(totals) != len(want.Carriers) || groups != wantGroups {
+		t.Errorf("CSV has %d carriers and %d groups, want %d and %d", len(totals), groups, len(want.Carriers), wantGroups)
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// EXPORT: STORED PASSES AS CSV
+// ----------------------
+
+// passCSVHeader is the column order of GET /passes/export.csv. Append new
+// columns at the end; spreadsheets built on the export rely on positions.
+var passCSVHeader = []string{
+	"id", "passenger", "pnr", "carrier", "flight", "departure", "arrival",
+	"date_iso", "seat", "gate", "source", "created_at",
+}
+
+func passCSVRecord(sp *storage.StoredPass) []string {
+	p := sp.Pass
+	gate := p.RawData["gate"]
+	if gate == "" && p.FlightStatus != nil {
+		gate = p.FlightStatus.Gate
+	}
+	return []string{
+		sp.ID, p.PassengerName, p.PNR, p.Carrier, p.FlightNumber, p.Departure, p.Arrival,
+		p.DateISO, p.Seat, gate, sp.Source, sp.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// handlePassesCSV streams every stored pass matching the GET /passes filters,
+// newest first, with ?format applied to each. Rows are written as they are read from the database, so once
+// the header is out an error can only be logged and the body cut short.
+func handlePassesCSV(w http.ResponseWriter, r *http.Request) {
+	if passStore == nil {
+		httpError(w, "Persistence is disabled (set SQLITE_PATH or DATABASE_URL)", http.StatusNotImplemented)
+		return
+	}
+	if status, d := outputFormat(r.URL.Query()); status != 0 {
+		writeError(w, status, d)
+		return
+	}
+	filter, err := passFilterFromQuery(r, passFilterParams)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="passes-%s.csv"`, time.Now().UTC().Format(time.DateOnly)))
+
+	rc := http.NewResponseController(w)
+	cw := csv.NewWriter(w)
+	cw.Write(passCSVHeader)
+	rows := 0
+	err = passStore.Each(filter, func(sp *storage.StoredPass) error {
+		applyFormat(sp.Pass, r.URL.Query())
+		if err := cw.Write(passCSVRecord(sp)); err != nil {
+			return err
+		}
+		// Flush every so often so a slow client sees rows arrive.
+		if rows++; rows%100 == 0 {
+			cw.Flush()
+			rc.Flush()
+		}
+		return r.Context().Err()
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error exporting passes as CSV", "rows", rows, "err", err)
+	}
+}
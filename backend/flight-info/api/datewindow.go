@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// ----------------------
+// LOGIC: FLIGHT DATE SANITY WINDOW
+// ----------------------
+
+// A BCBP date is a day of the year, and the year is inferred. An old pass
+// scanned again, or an inference gone wrong, gives a date_iso months away
+// from anything the traveler is about to fly. Such a date is not dropped,
+// since it may well be right, but it gets a date_suspect warning when it
+// falls outside the window around the time of the check. /trips checks
+// stored passes again against the time of the request, not the warning they
+// were stored with, and leaves out the ones outside it unless asked for them.
+//
+// The flight is compared at the most forgiving instant it could be: its
+// departure_time_utc when known, else the whole day in the departure
+// airport's zone, else the whole day anywhere on Earth (UTC+14 to UTC-12).
+// A flight later today in Tokyo is never "in the past" for a server in
+// Lisbon.
+
+// Window around now for the flight date; DATE_WINDOW_PAST and
+// DATE_WINDOW_FUTURE.
+var (
+	dateWindowPast   = defaultConfig.DateWindowPast
+	dateWindowFuture = defaultConfig.DateWindowFuture
+)
+
+// dateSuspectPrefix starts the warning checkDateWindow adds.
+const dateSuspectPrefix = "date_suspect: "
+
+var (
+	earliestZone = time.FixedZone("UTC+14", 14*3600)
+	latestZone   = time.FixedZone("UTC-12", -12*3600)
+)
+
+// checkDateWindow warns when p's date is outside the window around now.
+func checkDateWindow(p *bcbp.UnifiedBoardingPass, now time.Time) {
+	off, outside := outsideDateWindow(p, now)
+	if !outside {
+		return
+	}
+	p.Warnings = append(p.Warnings, fmt.Sprintf("%sflight date %s is %s, outside the %s to %s window; the pass may be old or its year inferred wrong",
+		dateSuspectPrefix, p.DateISO, off, formatDays(-dateWindowPast), formatDays(dateWindowFuture)))
+}
+
+// outsideDateWindow reports whether p's flight is outside the window around
+// now, and by how much ("93 days in the past"). A pass without a date is
+// never outside it.
+func outsideDateWindow(p *bcbp.UnifiedBoardingPass, now time.Time) (off string, outside bool) {
+	first, last, ok := flightSpan(p)
+	if !ok {
+		return "", false
+	}
+	switch {
+	case last.Before(now.Add(-dateWindowPast)):
+		return fmt.Sprintf("%d days in the past", int(now.Sub(last).Hours()/24)), true
+	case first.After(now.Add(dateWindowFuture)):
+		return fmt.Sprintf("%d days ahead", int(first.Sub(now).Hours()/24)), true
+	}
+	return "", false
+}
+
+// flightSpan is the earliest and latest instant p's flight can be at.
+func flightSpan(p *bcbp.UnifiedBoardingPass) (first, last time.Time, ok bool) {
+	for _, ts := range []string{p.DepartureTimeUTC, p.BoardingTimeUTC} {
+		if t, err := time.Parse(time.RFC3339, ts); err == nil {
+			return t, t, true
+		}
+	}
+	if p.DateISO == "" {
+		return first, last, false
+	}
+	if loc := airportLocation(p.Departure); loc != nil {
+		d, err := time.ParseInLocation(time.DateOnly, p.DateISO, loc)
+		return d, d.AddDate(0, 0, 1), err == nil
+	}
+	d, err := time.ParseInLocation(time.DateOnly, p.DateISO, earliestZone)
+	if err != nil {
+		return first, last, false
+	}
+	end, _ := time.ParseInLocation(time.DateOnly, p.DateISO, latestZone)
+	return d, end.AddDate(0, 0, 1), true
+}
+
+// formatDays writes d as whole days, signed: "-2d", "+360d".
+func formatDays(d time.Duration) string {
+	return fmt.Sprintf("%+dd", int(d.Hours()/24))
+}
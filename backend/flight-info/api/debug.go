@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// ----------------------
+// DEBUG: PPROF AND RUNTIME STATS
+// ----------------------
+
+// debugEndpoints (DEBUG_ENDPOINTS) mounts /debug/pprof and /debug/vars.
+// Unset, the routes don't exist and 404; set, they still need the admin
+// token.
+var debugEndpoints bool
+
+// registerDebugRoutes mounts the profiling handlers on mux. net/http/pprof
+// also registers itself on http.DefaultServeMux, which is why the server
+// uses its own mux.
+func registerDebugRoutes(mux *http.ServeMux) {
+	debugRoute := func(h http.HandlerFunc) http.HandlerFunc { return api(adminMiddleware(h), http.MethodGet) }
+	mux.HandleFunc("/debug/pprof/", debugRoute(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", debugRoute(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", debugRoute(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", api(adminMiddleware(pprof.Symbol), http.MethodGet, http.MethodPost))
+	mux.HandleFunc("/debug/pprof/trace", debugRoute(pprof.Trace))
+	mux.HandleFunc("/debug/vars", debugRoute(handleDebugVars))
+}
+
+// DebugVars is the body of GET /debug/vars.
+type DebugVars struct {
+	Goroutines int       `json:"goroutines"`
+	GOMAXPROCS int       `json:"gomaxprocs"`
+	Memory     MemVars   `json:"memory"`
+	GC         GCVars    `json:"gc"`
+	Pipeline   Pipeline  `json:"pipeline"`
+	Time       time.Time `json:"time"`
+}
+
+// MemVars are the runtime.MemStats worth watching while images decode.
+type MemVars struct {
+	HeapAlloc   uint64 `json:"heap_alloc_bytes"`
+	HeapInuse   uint64 `json:"heap_inuse_bytes"`
+	HeapObjects uint64 `json:"heap_objects"`
+	Sys         uint64 `json:"sys_bytes"`
+	TotalAlloc  uint64 `json:"total_alloc_bytes"`
+}
+
+// GCVars summarize garbage collection since the process started.
+type GCVars struct {
+	NumGC         int64      `json:"num_gc"`
+	PauseTotal    string     `json:"pause_total"`
+	LastPause     string     `json:"last_pause,omitempty"`
+	LastGC        *time.Time `json:"last_gc,omitempty"`
+	CPUFraction   float64    `json:"cpu_fraction"`
+	NextHeapBytes uint64     `json:"next_heap_bytes"`
+}
+
+// Pipeline is the state of the parse pipeline: the heavy-work limiter and
+// the response cache, as in /metrics.
+type Pipeline struct {
+	HeavyCapacity     int64 `json:"heavy_capacity,omitempty"` // 0 when the limiter is off
+	HeavyInflight     int64 `json:"heavy_inflight"`
+	HeavyQueued       int64 `json:"heavy_queued"`
+	ParseCacheEntries int64 `json:"parse_cache_entries"`
+}
+
+func handleDebugVars(w http.ResponseWriter, r *http.Request) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+
+	vars := DebugVars{
+		Goroutines: runtime.NumGoroutine(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Memory: MemVars{
+			HeapAlloc:   ms.HeapAlloc,
+			HeapInuse:   ms.HeapInuse,
+			HeapObjects: ms.HeapObjects,
+			Sys:         ms.Sys,
+			TotalAlloc:  ms.TotalAlloc,
+		},
+		GC: GCVars{
+			NumGC:         gc.NumGC,
+			PauseTotal:    gc.PauseTotal.String(),
+			CPUFraction:   ms.GCCPUFraction,
+			NextHeapBytes: ms.NextGC,
+		},
+		Pipeline: Pipeline{
+			HeavyInflight:     metric("heavy_inflight").Value(),
+			HeavyQueued:       metric("heavy_queued").Value(),
+			ParseCacheEntries: metric("parse_cache_entries").Value(),
+		},
+		Time: time.Now().UTC(),
+	}
+	if len(gc.Pause) > 0 {
+		vars.GC.LastPause = gc.Pause[0].String()
+		last := gc.LastGC.UTC()
+		vars.GC.LastGC = &last
+	}
+	if heavyWork != nil {
+		vars.Pipeline.HeavyCapacity = heavyWork.capacity
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(vars)
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestDebugEndpoints(t *testing.T) {
+	setForTest(t, &adminToken, "secret")
+	paths := []string{"/debug/vars", "/debug/pprof/", "/debug/pprof/cmdline"}
+
+	t.Run("off", func(t *testing.T) {
+		h := Handler()
+		for _, p := range paths {
+			if w := serve(t, h, http.MethodGet, p, nil, "Authorization", "Bearer secret"); w.Code != http.StatusNotFound {
+				t.Errorf("%s: status %d, want 404", p, w.Code)
+			}
+		}
+	})
+
+	t.Run("on", func(t *testing.T) {
+		mux := newMux()
+		registerDebugRoutes(mux)
+		h := corsHandler(mux)
+		for _, p := range paths {
+			if w := serve(t, h, http.MethodGet, p, nil); w.Code != http.StatusUnauthorized {
+				t.Errorf("%s without token: status %d, want 401", p, w.Code)
+			}
+			if w := serve(t, h, http.MethodGet, p, nil, "Authorization", "Bearer secret"); w.Code != http.StatusOK {
+				t.Errorf("%s: status %d, want 200", p, w.Code)
+			}
+		}
+
+		w := serve(t, h, http.MethodGet, "/debug/vars", nil, "Authorization", "Bearer secret")
+		var vars DebugVars
+		if err := json.Unmarshal(w.Body.Bytes(), &vars); err != nil {
+			t.Fatal(err)
+		}
+		if vars.Goroutines == 0 || vars.GOMAXPROCS == 0 || vars.Memory.Sys == 0 {
+			t.Errorf("/debug/vars: %+v", vars)
+		}
+	})
+}
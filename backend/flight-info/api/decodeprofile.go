@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"bugsbyte/flight-info/scan"
+)
+
+// ----------------------
+// DECODE PROFILES (?decode_profile=)
+// ----------------------
+
+// The image endpoints read with a scan.DecodeProfile: the one a request
+// names, or DECODE_PROFILE, the server's default. A kiosk that only sees
+// Aztec codes sets it once instead of on every frame.
+
+// defaultDecodeProfile is DECODE_PROFILE, which PATCH /admin/config can
+// change while requests read it.
+var defaultDecodeProfile atomic.Value
+
+func init() {
+	defaultDecodeProfile.Store(scan.DefaultDecodeProfile)
+}
+
+// serverDecodeProfile is the name of the default decode profile.
+func serverDecodeProfile() string {
+	return defaultDecodeProfile.Load().(string)
+}
+
+// decodeProfile is the profile an image request reads with: name, from
+// the request body, if set, then ?decode_profile, then the default.
+// status is 0 unless the name is unknown.
+func decodeProfile(q url.Values, name string) (prof scan.DecodeProfile, status int, d ErrorDetail) {
+	if name == "" {
+		name = q.Get("decode_profile")
+	}
+	if name == "" {
+		name = serverDecodeProfile()
+	}
+	prof, ok := scan.LookupDecodeProfile(name)
+	if !ok {
+		return prof, http.StatusBadRequest, invalidParam("decode_profile",
+			fmt.Sprintf("decode_profile must be one of %s", strings.Join(scan.DecodeProfiles(), ", ")))
+	}
+	return prof, 0, ErrorDetail{}
+}
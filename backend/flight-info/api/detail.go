@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/pkpass"
+	"bugsbyte/flight-info/scan"
+)
+
+// ----------------------
+// DEBUGGING: PARSER DETAIL (?detail=full)
+// ----------------------
+
+// detail=full adds the parser's intermediate representation to a parse
+// response as "detail". It is never stored or sent to webhooks, and it is
+// redacted along with the rest of the pass.
+
+func wantDetail(q url.Values) bool {
+	return q.Get("detail") == "full"
+}
+
+// addBarcodeDetail splits the pass's raw barcode into its fields.
+func addBarcodeDetail(p *bcbp.UnifiedBoardingPass) {
+	raw, ok := p.RawData["raw_string"]
+	if !ok {
+		return
+	}
+	if p.Detail == nil {
+		p.Detail = &bcbp.ParseDetail{}
+	}
+	if p.Detail.Fields == nil && p.Detail.PassJSON == nil {
+		p.Detail.Fields = bcbp.Fields(raw)
+	}
+}
+
+// addDecodeDetail lists the barcodes the image readers found for p, the
+// one it was parsed from first (see scan.pickBest).
+func addDecodeDetail(p *bcbp.UnifiedBoardingPass, res *scan.Result) {
+	decode := []bcbp.DecodeCandidate{{Format: res.Format, Binarizer: res.Binarizer, Luminance: res.Luminance, Padding: res.Padding, Text: res.Text, Chosen: true}}
+	for _, c := range res.Rejected {
+		decode = append(decode, bcbp.DecodeCandidate{Format: c.Format, Binarizer: c.Binarizer, Luminance: c.Luminance, Padding: c.Padding, Text: c.Text})
+	}
+	if p.Detail == nil {
+		p.Detail = &bcbp.ParseDetail{}
+	}
+	p.Detail.Decode, p.Detail.DecodeProfile = decode, res.Profile
+}
+
+// addPkPassDetail attaches the decoded pass.json of the .pkpass p was
+// parsed from, size bytes read through r.
+func addPkPassDetail(p *bcbp.UnifiedBoardingPass, r io.ReaderAt, size int64) {
+	pk, err := pkpass.DecodeReader(r, size)
+	if err != nil {
+		return
+	}
+	b, err := json.Marshal(pk)
+	if err != nil {
+		return
+	}
+	p.Detail = &bcbp.ParseDetail{PassJSON: b}
+}
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// ----------------------
+// ERRORS: JSON ENVELOPE, REQUEST IDS, PANIC RECOVERY
+// ----------------------
+
+// ErrorResponse is the body of every error response:
+//
+//	{"error": {"code": "bad_request", "message": "Invalid JSON", "request_id": "9f2c..."}}
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail is the content of an ErrorResponse.
+type ErrorDetail struct {
+	Code string `json:"code"` // snake_case HTTP status text
+	// Reason tells apart failures that share a status on the parse
+	// endpoints, e.g. "no_barcode" and "not_boarding_pass" for a 422.
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message"` // technical, in English
+	// UserMessage is a traveler-friendly text in the language negotiated
+	// from ?lang or Accept-Language (see Lang).
+	UserMessage string `json:"user_message"`
+	Lang        string `json:"lang"`
+	RequestID   string `json:"request_id,omitempty"`
+	// RetryAfter repeats the Retry-After header (seconds) on 429s.
+	RetryAfter int `json:"retry_after,omitempty"`
+	// DecodedText is the barcode text read from an image that turned out
+	// not to be a boarding pass; left out for redacted requests.
+	DecodedText string `json:"decoded_text,omitempty"`
+	// DetectedType is what a 415 upload looked like ("pdf", "pkpass",
+	// ...), and UseEndpoint the endpoint that takes it, if any. A
+	// wallet_screenshot 422 points at /parse/pkpass too, with DeepLink,
+	// a URL that opens Apple Wallet to share the pass from.
+	DetectedType string `json:"detected_type,omitempty"`
+	UseEndpoint  string `json:"use_endpoint,omitempty"`
+	DeepLink     string `json:"deep_link,omitempty"`
+	// Parameter names the query parameter of an invalid_parameter 400.
+	Parameter string `json:"parameter,omitempty"`
+	// Position is the 1-based character position of the first offending
+	// character of an invalid_base64 400, when there is one.
+	Position int `json:"position,omitempty"`
+	// ImageQuality is what an image_too_small or image_blurry 422 measured.
+	ImageQuality *ImageQuality `json:"image_quality,omitempty"`
+	// DecodeProfile names the scan.DecodeProfile an image with no barcode
+	// was read with.
+	DecodeProfile string `json:"decode_profile,omitempty"`
+}
+
+// ImageQuality is the measured size and sharpness of an image that had no
+// readable barcode, with the thresholds it fell under (scan.MinImageSide
+// and scan.MinSharpness).
+type ImageQuality struct {
+	Width        int     `json:"width"`
+	Height       int     `json:"height"`
+	Sharpness    float64 `json:"sharpness,omitempty"` // only measured for image_blurry
+	MinSide      int     `json:"min_side"`
+	MinSharpness float64 `json:"min_sharpness"`
+}
+
+// Reasons for rejected parse requests, by status: 400 for requests that are
+// malformed as HTTP or JSON, 413 and 415 for the body as a whole, 422 for
+// well-formed input that doesn't yield a boarding pass, 501 for an option
+// this server isn't set up for.
+const (
+	reasonInvalidJSON        = "invalid_json"           // 400
+	reasonInvalidBase64      = "invalid_base64"         // 400
+	reasonInvalidForm        = "invalid_form"           // 400: multipart body without a file field
+	reasonInvalidParam       = "invalid_parameter"      // 400: a query parameter or header that doesn't parse
+	reasonTooLarge           = "too_large"              // 413
+	reasonUnsupportedType    = "unsupported_media_type" // 415: an upload of the wrong kind
+	reasonJSONRequired       = "json_required"          // 415: a JSON endpoint sent another Content-Type
+	reasonInvalidImage       = "invalid_image"          // 422: not a decodable image
+	reasonNoBarcode          = "no_barcode"             // 422: image without a readable barcode
+	reasonImageTooSmall      = "image_too_small"        // 422: no barcode, and too small to hold one
+	reasonImageBlurry        = "image_blurry"           // 422: no barcode, and too blurry to read one
+	reasonWalletScreenshot   = "wallet_screenshot"      // 422: no barcode in what looks like a Wallet screenshot
+	reasonNotBoardingPass    = "not_boarding_pass"      // 422: text that isn't BCBP
+	reasonInvalidEncoding    = "invalid_encoding"       // 422: BCBP text that isn't printable ASCII
+	reasonInvalidPkPass      = "invalid_pkpass"         // 422: not a readable pass archive
+	reasonInvalidArchive     = "invalid_archive"        // 422: a zip of images that can't be read
+	reasonFeatureUnavailable = "feature_unavailable"    // 501: e.g. ?ocr=true without a tesseract binary
+)
+
+// Clients whose Accept header prefers application/problem+json get errors
+// as RFC 9457 problem details instead of the envelope. The type is
+// problemTypeBase followed by the reason, or the code for errors without
+// one, and the envelope's fields come along as extension members, so
+// either format says the same.
+
+// problemJSON is the media type of problem details.
+const problemJSON = "application/problem+json"
+
+// problemTypeBase is PROBLEM_TYPE_BASE.
+var problemTypeBase = defaultConfig.ProblemTypeBase
+
+// ProblemDetails is an error as RFC 9457 problem details.
+type ProblemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"` // the HTTP status text
+	Status   int    `json:"status"`
+	Detail   string `json:"detail"` // Message
+	Instance string `json:"instance,omitempty"`
+	ErrorDetail
+}
+
+// problemDetails is d, filled in by errorDetail, as problem details.
+func problemDetails(status int, instance string, d ErrorDetail) ProblemDetails {
+	kind := d.Reason
+	if kind == "" {
+		kind = d.Code
+	}
+	return ProblemDetails{
+		Type:        problemTypeBase + kind,
+		Title:       http.StatusText(status),
+		Status:      status,
+		Detail:      d.Message,
+		Instance:    instance,
+		ErrorDetail: d,
+	}
+}
+
+// wantProblem reports whether r's Accept header ranks problem details
+// above JSON. Wildcards count for JSON only, so a client has to ask for
+// problem details by name.
+func wantProblem(r *http.Request) bool {
+	problem, plain := 0.0, 0.0
+	for _, v := range r.Header.Values("Accept") {
+		for _, t := range strings.Split(v, ",") {
+			mt, params, err := mime.ParseMediaType(t)
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if s, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(s, 64); err != nil {
+					continue
+				}
+			}
+			switch mt {
+			case problemJSON:
+				problem = max(problem, q)
+			case "application/json", "application/*", "*/*":
+				plain = max(plain, q)
+			}
+		}
+	}
+	return problem > 0 && problem >= plain
+}
+
+// httpError is a drop-in for http.Error that writes the JSON envelope. The
+// request ID is read back from the response header set by
+// requestIDMiddleware, so handlers don't need to thread the request through.
+func httpError(w http.ResponseWriter, message string, status int) {
+	writeError(w, status, ErrorDetail{Message: message})
+}
+
+// httpRetryError is httpError that, for retryAfter > 0, also tells the client
+// how many seconds to wait, in Retry-After and in the envelope.
+func httpRetryError(w http.ResponseWriter, message string, status, retryAfter int) {
+	writeError(w, status, ErrorDetail{Message: message, RetryAfter: retryAfter})
+}
+
+// writeError writes d as the error envelope, or as problem details for a
+// client that prefers them, filling in its code and request ID.
+func writeError(w http.ResponseWriter, status int, d ErrorDetail) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	if d.RetryAfter > 0 {
+		h.Set("Retry-After", strconv.Itoa(d.RetryAfter))
+	}
+	d = errorDetail(status, responseLang(w), d)
+	d.RequestID = h.Get("X-Request-ID")
+	h.Set("Content-Language", d.Lang)
+	lw := findLangWriter(w)
+	if lw != nil {
+		h.Add("Vary", "Accept")
+	}
+	if lw != nil && lw.problem {
+		h.Set("Content-Type", problemJSON)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(problemDetails(status, lw.instance, d))
+		return
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: d})
+}
+
+// errorDetail fills in the code and the user message in lang.
+func errorDetail(status int, lang string, d ErrorDetail) ErrorDetail {
+	d.Code = errorCode(status)
+	d.Lang = lang
+	d.UserMessage = userMessage(lang, d)
+	return d
+}
+
+// errorCode turns 404 into "not_found", 429 into "too_many_requests", etc.
+func errorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ToLower(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestIDMiddleware keeps a well-formed incoming X-Request-ID (so IDs from
+// a proxy carry through) or assigns a new one, and echoes it on the response.
+func requestIDMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+			r.Header.Set("X-Request-ID", id)
+		}
+		w.Header().Set("X-Request-ID", id)
+		next(w, r)
+	}
+}
+
+func init() {
+	describeMetric("http_panics_total", "counter", "Handler panics recovered by the server.")
+	metric("http_panics_total")
+}
+
+// statusRecorder notes whether the handler already started its response, in
+// which case a 500 can no longer be sent.
+type statusRecorder struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.wroteHeader = true
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+// recoverMiddleware turns a handler panic into a logged stack trace and a
+// JSON 500. The 500 gets its CORS headers from corsHandler, which wraps the
+// whole mux and sets them before any handler runs, so browsers can read it.
+func recoverMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			metric("http_panics_total").Inc()
+			slog.ErrorContext(r.Context(), "Panic serving request", "panic", v, "stack", string(debug.Stack()))
+			if rec.wroteHeader {
+				return
+			}
+			httpError(w, "Internal server error", http.StatusInternalServerError)
+		}()
+		next(rec, r)
+	}
+}
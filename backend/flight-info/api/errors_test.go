@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestErrorFormats sends each failure twice, once asking for problem
+// details, and checks that both formats say the same.
+func TestErrorFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+		kind   string // the reason, or the code without one
+	}{
+		{"invalid json", http.MethodPost, "/parse/barcode", `{"barcode":`, http.StatusBadRequest, reasonInvalidJSON},
+		{"not a boarding pass", http.MethodPost, "/parse/barcode", `{"barcode":"hello"}`, http.StatusUnprocessableEntity, reasonNotBoardingPass},
+		{"invalid parameter", http.MethodPost, "/parse/barcode?reference_date=soon", `{"barcode":"hello"}`, http.StatusBadRequest, reasonInvalidParam},
+		{"unknown route", http.MethodGet, "/nowhere", "", http.StatusNotFound, "not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			send := func(accept string) *httptest.ResponseRecorder {
+				header := []string{"Content-Type", "application/json", "X-Request-ID", "errors-test", "Accept-Language", "pt"}
+				if accept != "" {
+					header = append(header, "Accept", accept)
+				}
+				return serve(t, Handler(), tt.method, tt.target, strings.NewReader(tt.body), header...)
+			}
+			env, prob := send(""), send(problemJSON)
+
+			for _, w := range []*httptest.ResponseRecorder{env, prob} {
+				if w.Code != tt.status {
+					t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+				}
+				if got := w.Header().Get("Content-Language"); got != "pt" {
+					t.Errorf("Content-Language = %q, want pt", got)
+				}
+			}
+			if ct := env.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("envelope Content-Type = %q", ct)
+			}
+			if ct := prob.Header().Get("Content-Type"); ct != problemJSON {
+				t.Errorf("problem Content-Type = %q", ct)
+			}
+
+			var e ErrorResponse
+			if err := json.Unmarshal(env.Body.Bytes(), &e); err != nil {
+				t.Fatal(err)
+			}
+			var p ProblemDetails
+			if err := json.Unmarshal(prob.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.ErrorDetail != e.Error {
+				t.Errorf("problem members %+v, envelope %+v", p.ErrorDetail, e.Error)
+			}
+			if e.Error.RequestID != "errors-test" || e.Error.UserMessage == "" || e.Error.Lang != "pt" {
+				t.Errorf("envelope %+v lacks its request ID or user message", e.Error)
+			}
+			path, _, _ := strings.Cut(tt.target, "?")
+			want := ProblemDetails{
+				Type: problemTypeBase + tt.kind, Title: http.StatusText(tt.status), Status: tt.status,
+				Detail: e.Error.Message, Instance: path, ErrorDetail: e.Error,
+			}
+			if p != want {
+				t.Errorf("problem details\n%+v\nwant\n%+v", p, want)
+			}
+		})
+	}
+}
+
+// TestErrorFormatNegotiation checks which Accept headers get problem
+// details.
+func TestErrorFormatNegotiation(t *testing.T) {
+	for accept, want := range map[string]bool{
+		"":                                 false,
+		"*/*":                              false,
+		"application/json":                 false,
+		problemJSON:                        true,
+		problemJSON + ", application/json": true,
+		"application/json, " + problemJSON + ";q=0.5": false,
+		problemJSON + ";q=0.9, */*;q=0.1":             true,
+	} {
+		w := serve(t, Handler(), http.MethodPost, "/parse/barcode", bytes.NewReader([]byte(`{}`)),
+			"Content-Type", "application/json", "Accept", accept)
+		if got := w.Header().Get("Content-Type") == problemJSON; got != want {
+			t.Errorf("Accept %q: problem details = %v, want %v", accept, got, want)
+		}
+	}
+}
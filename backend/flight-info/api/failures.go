@@ -0,0 +1,222 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"bugsbyte/flight-info/flightinfopb"
+)
+
+// ----------------------
+// ADMIN: RECENT PARSE FAILURES
+// ----------------------
+
+const (
+	// maxFailureText caps captured barcode text; real BCBP strings are far
+	// shorter, so anything longer is junk worth seeing only in part.
+	maxFailureText = 512
+	// maxFailureBytes is how much of a binary upload (.pkpass) is captured,
+	// hex-encoded: enough to tell a ZIP from something else.
+	maxFailureBytes = 32
+)
+
+// parseFailures is nil when PARSE_FAILURES_SIZE is 0.
+var parseFailures *failureLog
+
+// debugCapture (DEBUG_CAPTURE) adds an input sample to recorded failures.
+// Barcode text carries PII, so it is off by default and skipped for
+// redacted requests.
+var debugCapture bool
+
+// ParseFailure is one failed parse attempt. Image bytes are never kept; for
+// an image whose barcode decoded but didn't parse, the sample is the text.
+type ParseFailure struct {
+	Time      time.Time `json:"time"`
+	Endpoint  string    `json:"endpoint"` // HTTP path or gRPC method
+	Code      string    `json:"code"`     // the error envelope's code, or the gRPC status code
+	Reason    string    `json:"reason,omitempty"`
+	Message   string    `json:"message"`
+	InputSize int       `json:"input_size"` // bytes; -1 when unknown
+	RequestID string    `json:"request_id,omitempty"`
+	Sample    string    `json:"sample,omitempty"` // only with DEBUG_CAPTURE
+}
+
+// failureLog is a fixed-size ring of the most recent failures.
+type failureLog struct {
+	mu      sync.Mutex
+	entries []ParseFailure
+	next    int // slot the next failure goes into
+	full    bool
+}
+
+func newFailureLog(size int) *failureLog {
+	return &failureLog{entries: make([]ParseFailure, size)}
+}
+
+func (l *failureLog) Add(f ParseFailure) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries[l.next] = f
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// List returns the recorded failures, newest first.
+func (l *failureLog) List() []ParseFailure {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.next
+	if l.full {
+		n = len(l.entries)
+	}
+	out := make([]ParseFailure, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return out
+}
+
+func (l *failureLog) Clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	clear(l.entries)
+	l.next, l.full = 0, false
+}
+
+// textSample and bytesSample build ParseFailure.Sample, or "" when capture
+// is off or the request asked for redaction.
+func textSample(q url.Values, text string) string {
+	if !debugCapture || wantRedaction(q) {
+		return ""
+	}
+	return truncateText(text, maxFailureText)
+}
+
+// truncateText cuts text to at most max bytes on a rune boundary, marking
+// the cut with an ellipsis.
+func truncateText(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "…"
+}
+
+func bytesSample(q url.Values, data []byte) string {
+	if !debugCapture || wantRedaction(q) {
+		return ""
+	}
+	if len(data) > maxFailureBytes {
+		return hex.EncodeToString(data[:maxFailureBytes]) + "…"
+	}
+	return hex.EncodeToString(data)
+}
+
+// parseFailed records a failed HTTP parse, then writes d as the error
+// response. size is the input size in bytes, -1 if it wasn't read.
+func parseFailed(w http.ResponseWriter, r *http.Request, status int, d ErrorDetail, size int, sample string) {
+	recordParseFailure(r.URL.Path, w.Header().Get("X-Request-ID"), status, d, size, sample)
+	writeError(w, status, d)
+}
+
+// recordParseFailure counts a failed HTTP parse (see recordParseError) and
+// adds it to the log, if it is enabled.
+func recordParseFailure(endpoint, requestID string, status int, d ErrorDetail, size int, sample string) {
+	recordParseError(endpoint, cmp.Or(d.Reason, errorCode(status)))
+	if parseFailures == nil {
+		return
+	}
+	parseFailures.Add(ParseFailure{
+		Time:      time.Now().UTC(),
+		Endpoint:  endpoint,
+		Code:      errorCode(status),
+		Reason:    d.Reason,
+		Message:   d.Message,
+		InputSize: size,
+		RequestID: requestID,
+		Sample:    sample,
+	})
+}
+
+// recordFailuresUnary records unary parse RPCs rejected for their input
+// (INVALID_ARGUMENT or NOT_FOUND). ScanFrames isn't recorded: most frames of
+// a camera stream are expected to miss.
+func recordFailuresUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, err
+	}
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument && st.Code() != codes.NotFound {
+		return resp, err
+	}
+	recordParseError(info.FullMethod, st.Code().String())
+	if parseFailures == nil {
+		return resp, err
+	}
+
+	f := ParseFailure{
+		Time:     time.Now().UTC(),
+		Endpoint: info.FullMethod,
+		Code:     st.Code().String(),
+		Message:  st.Message(),
+	}
+	switch req := req.(type) {
+	case *flightinfopb.ParseBarcodeRequest:
+		f.InputSize = len(req.GetBarcode())
+		f.Sample = textSample(optionValues(req.GetOptions()), req.GetBarcode())
+	case *flightinfopb.ParsePkPassRequest:
+		f.InputSize = len(req.GetPkpass())
+		f.Sample = bytesSample(optionValues(req.GetOptions()), req.GetPkpass())
+	case *flightinfopb.ParseBarcodeImageRequest:
+		f.InputSize = len(req.GetImage())
+	default:
+		return resp, err
+	}
+	parseFailures.Add(f)
+	return resp, err
+}
+
+// FailureList is the body of GET /admin/failures.
+type FailureList struct {
+	Failures []ParseFailure `json:"failures"`
+	Capacity int            `json:"capacity"`
+	Capture  bool           `json:"capture"` // DEBUG_CAPTURE
+}
+
+func handleFailures(w http.ResponseWriter, r *http.Request) {
+	if parseFailures == nil {
+		httpError(w, "Failure log is disabled (PARSE_FAILURES_SIZE=0)", http.StatusNotImplemented)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(FailureList{
+			Failures: parseFailures.List(),
+			Capacity: len(parseFailures.entries),
+			Capture:  debugCapture,
+		})
+
+	case http.MethodDelete:
+		parseFailures.Clear()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// ----------------------
+// ENRICHMENT: LIVE FLIGHT STATUS
+// ----------------------
+
+// flightStatus is nil unless a provider key is configured; ?status=true is
+// then ignored with a warning.
+var flightStatus *cachedStatusProvider
+
+// FlightQuery identifies one leg: Flight is the carrier designator plus
+// number ("TP576"), Date the local departure date ("YYYY-MM-DD"), and
+// Departure the origin IATA code, used to pick the leg of multi-leg flights.
+type FlightQuery struct {
+	Flight    string
+	Date      string
+	Departure string
+}
+
+// FlightStatusProvider looks up live status for a leg. Implementations
+// return errFlightNotFound when the provider has no such flight.
+type FlightStatusProvider interface {
+	Name() string
+	FlightStatus(ctx context.Context, q FlightQuery) (*bcbp.FlightStatus, error)
+}
+
+var errFlightNotFound = errors.New("flight not found")
+
+func init() {
+	describeMetric("flight_status_lookups_total", "counter", "Flight status lookups by result (cache hits, and lookups skipped for the time budget, included).")
+}
+
+// cachedStatusProvider keeps answers (including not-found) for ttl, so a
+// pass scanned repeatedly at a gate costs one request.
+type cachedStatusProvider struct {
+	provider FlightStatusProvider
+	ttl      time.Duration
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	entries map[FlightQuery]statusCacheEntry
+}
+
+type statusCacheEntry struct {
+	status  *bcbp.FlightStatus
+	err     error
+	expires time.Time
+}
+
+func newCachedStatusProvider(p FlightStatusProvider, ttl, timeout time.Duration) *cachedStatusProvider {
+	for _, result := range []string{"hit", "ok", "not_found", "error", "skipped"} {
+		metric("flight_status_lookups_total", "result", result)
+	}
+	return &cachedStatusProvider{provider: p, ttl: ttl, timeout: timeout, entries: map[FlightQuery]statusCacheEntry{}}
+}
+
+func (c *cachedStatusProvider) FlightStatus(ctx context.Context, q FlightQuery) (*bcbp.FlightStatus, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	if e, ok := c.entries[q]; ok && now.Before(e.expires) {
+		c.mu.Unlock()
+		metric("flight_status_lookups_total", "result", "hit").Inc()
+		return e.status, e.err
+	}
+	// Sweep expired entries on every miss; the map stays small.
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.mu.Unlock()
+
+	timeout, ok := upstreamTimeout(ctx, c.timeout)
+	if !ok {
+		metric("flight_status_lookups_total", "result", "skipped").Inc()
+		return nil, errBudgetSpent
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	status, err := c.provider.FlightStatus(ctx, q)
+	switch {
+	case err == nil:
+		metric("flight_status_lookups_total", "result", "ok").Inc()
+	case errors.Is(err, errFlightNotFound):
+		metric("flight_status_lookups_total", "result", "not_found").Inc()
+	default:
+		// Transient failures aren't cached; the next scan retries.
+		metric("flight_status_lookups_total", "result", "error").Inc()
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[q] = statusCacheEntry{status: status, err: err, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return status, err
+}
+
+// enrichFlightStatus attaches live status. Failures never fail the parse;
+// they become warnings.
+func enrichFlightStatus(ctx context.Context, p *bcbp.UnifiedBoardingPass) {
+	if flightStatus == nil {
+		p.Warnings = append(p.Warnings, "flight status requested but no provider is configured (set AERODATABOX_API_KEY)")
+		return
+	}
+
+	carrier := strings.TrimSpace(p.Carrier)
+	number := strings.TrimLeft(strings.TrimSpace(p.FlightNumber), "0")
+	if m := bcbp.FlightCarrierPattern.FindStringSubmatch(strings.ToUpper(number)); m != nil {
+		carrier = m[1]
+		number = strings.TrimLeft(strings.TrimSpace(number[len(m[1]):]), "0")
+	}
+	if carrier == "" || number == "" || p.DateISO == "" {
+		p.Warnings = append(p.Warnings, "flight status needs carrier, flight number and date: not looked up")
+		return
+	}
+
+	status, err := flightStatus.FlightStatus(ctx, FlightQuery{Flight: carrier + number, Date: p.DateISO, Departure: p.Departure})
+	switch {
+	case errors.Is(err, errFlightNotFound):
+		p.Warnings = append(p.Warnings, "flight status: "+carrier+number+" on "+p.DateISO+" not found by "+flightStatus.provider.Name())
+	case errors.Is(err, errBudgetSpent):
+		p.Warnings = append(p.Warnings, "flight status skipped: parsing used most of the request's time budget")
+	case errors.Is(err, errBreakerOpen):
+		p.Warnings = append(p.Warnings, "flight status unavailable from "+flightStatus.provider.Name()+": failing, not retried for now")
+	case err != nil:
+		slog.WarnContext(ctx, "Error looking up flight status", "flight", carrier+number, "err", err)
+		p.Warnings = append(p.Warnings, "flight status unavailable from "+flightStatus.provider.Name()+": lookup failed")
+	default:
+		p.FlightStatus = status
+	}
+}
+
+// ----------------------
+// AERODATABOX PROVIDER
+// ----------------------
+
+// aeroDataBox talks to the AeroDataBox flight API (RapidAPI-hosted by
+// default; baseURL can point at the API.Market host or a mock).
+type aeroDataBox struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+func (a *aeroDataBox) Name() string { return "aerodatabox" }
+
+type adbMovement struct {
+	Airport struct {
+		IATA string `json:"iata"`
+	} `json:"airport"`
+	ScheduledTime *adbTime `json:"scheduledTime"`
+	RevisedTime   *adbTime `json:"revisedTime"`
+	PredictedTime *adbTime `json:"predictedTime"`
+	Terminal      string   `json:"terminal"`
+	Gate          string   `json:"gate"`
+}
+
+type adbTime struct {
+	UTC   string `json:"utc"`   // "2026-10-20 13:35Z"
+	Local string `json:"local"` // "2026-10-20 14:35+01:00"
+}
+
+// rfc3339 converts AeroDataBox's "2006-01-02 15:04-07:00" into RFC 3339.
+func (t *adbTime) rfc3339() (string, time.Time) {
+	if t == nil {
+		return "", time.Time{}
+	}
+	for _, v := range []string{t.Local, t.UTC} {
+		if parsed, err := time.Parse("2006-01-02 15:04Z07:00", v); err == nil {
+			return parsed.Format(time.RFC3339), parsed
+		}
+	}
+	return "", time.Time{}
+}
+
+func (a *aeroDataBox) FlightStatus(ctx context.Context, q FlightQuery) (*bcbp.FlightStatus, error) {
+	u := a.baseURL + "/flights/number/" + url.PathEscape(q.Flight) + "/" + url.PathEscape(q.Date) + "?dateLocalRole=Departure"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-RapidAPI-Key", a.apiKey)
+	if host, err := url.Parse(a.baseURL); err == nil {
+		req.Header.Set("X-RapidAPI-Host", host.Host)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusNoContent:
+		return nil, errFlightNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("aerodatabox returned %s", resp.Status)
+	}
+
+	var flights []struct {
+		Status    string      `json:"status"`
+		Departure adbMovement `json:"departure"`
+		Arrival   adbMovement `json:"arrival"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&flights); err != nil {
+		return nil, fmt.Errorf("decoding aerodatabox response: %w", err)
+	}
+	if len(flights) == 0 {
+		return nil, errFlightNotFound
+	}
+
+	// Multi-leg flight numbers return one entry per leg.
+	f := flights[0]
+	for _, leg := range flights {
+		if strings.EqualFold(leg.Departure.Airport.IATA, q.Departure) {
+			f = leg
+			break
+		}
+	}
+	s := &bcbp.FlightStatus{
+		Provider:  a.Name(),
+		Status:    f.Status,
+		Cancelled: strings.EqualFold(f.Status, "Canceled") || strings.EqualFold(f.Status, "CanceledUncertain"),
+		Gate:      f.Departure.Gate,
+		Terminal:  f.Departure.Terminal,
+	}
+	var sched, est time.Time
+	s.ScheduledDeparture, sched = f.Departure.ScheduledTime.rfc3339()
+	estimate := f.Departure.RevisedTime
+	if estimate == nil {
+		estimate = f.Departure.PredictedTime
+	}
+	s.EstimatedDeparture, est = estimate.rfc3339()
+	s.ScheduledArrival, _ = f.Arrival.ScheduledTime.rfc3339()
+	arrEstimate := f.Arrival.RevisedTime
+	if arrEstimate == nil {
+		arrEstimate = f.Arrival.PredictedTime
+	}
+	s.EstimatedArrival, _ = arrEstimate.rfc3339()
+	if !sched.IsZero() && !est.IsZero() && est.After(sched) {
+		s.DelayMinutes = int(est.Sub(sched) / time.Minute)
+	}
+	return s, nil
+}
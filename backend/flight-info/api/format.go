@@ -0,0 +1,36 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/profile"
+)
+
+// ----------------------
+// OUTPUT FORMATS (?format=)
+// ----------------------
+
+// ?format names a profile (see the profile package) that rewrites the
+// pass just before it is written out, after redaction and storage, so
+// stored passes and webhooks keep the parser's output. New profiles only
+// need registering; the handlers look them up by name.
+
+// outputFormat checks ?format. status is 0 unless it names no profile.
+func outputFormat(q url.Values) (status int, d ErrorDetail) {
+	if _, ok := profile.Lookup(q.Get("format")); !ok {
+		return http.StatusBadRequest, invalidParam("format",
+			fmt.Sprintf("format must be one of %s", strings.Join(profile.Names(), ", ")))
+	}
+	return 0, ErrorDetail{}
+}
+
+// applyFormat rewrites p with the profile ?format names, if any.
+func applyFormat(p *bcbp.UnifiedBoardingPass, q url.Values) {
+	if prof, ok := profile.Lookup(q.Get("format")); ok {
+		prof.Apply(p)
+	}
+}
@@ -0,0 +1,262 @@
+package api
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// ----------------------
+// EXPORT: GOOGLE WALLET FLIGHT PASS
+// ----------------------
+
+// googleWallet is nil unless GOOGLE_WALLET_KEY points at a service-account
+// key; without it the endpoint hands back the unsigned objects.
+var googleWallet *GoogleWalletSigner
+
+// googleWalletIssuerID is GOOGLE_WALLET_ISSUER_ID, which names the issuer
+// of the unsigned objects too.
+var googleWalletIssuerID = defaultConfig.GoogleWalletIssuerID
+
+// GoogleWalletSigner signs "Save to Google Wallet" JWTs with a service
+// account key.
+type GoogleWalletSigner struct {
+	issuerID    string
+	clientEmail string
+	key         *rsa.PrivateKey
+}
+
+// loadGoogleWalletSigner reads a Google Cloud service-account JSON key file.
+func loadGoogleWalletSigner(keyPath, issuerID string) (*GoogleWalletSigner, error) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	var sa struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+	}
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return nil, fmt.Errorf("%s: %w", keyPath, err)
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("%s: private_key is not PEM encoded", keyPath)
+	}
+	key, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", keyPath, err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: service-account key must be RSA", keyPath)
+	}
+	if issuerID == "" {
+		return nil, errors.New("GOOGLE_WALLET_ISSUER_ID is required with GOOGLE_WALLET_KEY")
+	}
+	return &GoogleWalletSigner{issuerID: issuerID, clientEmail: sa.ClientEmail, key: rsaKey}, nil
+}
+
+// signJWT produces an RS256 JWT over the given claims.
+func (g *GoogleWalletSigner) signJWT(claims map[string]interface{}) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, g.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
+
+// GoogleWalletRequest is a UnifiedBoardingPass plus the optional details a
+// boarding pass alone doesn't carry.
+type GoogleWalletRequest struct {
+	bcbp.UnifiedBoardingPass
+	Gate          string `json:"gate,omitempty"`
+	Terminal      string `json:"terminal,omitempty"`
+	BoardingGroup string `json:"boarding_group,omitempty"`
+	ArrivalTime   string `json:"arrival_time,omitempty"`
+}
+
+var walletIDChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// buildGoogleWalletObjects maps the pass onto FlightClass (per flight) and
+// FlightObject (per passenger) resources. Anything unknown is left out rather
+// than guessed; each omission of a field Google cares about is reported in
+// the returned warnings.
+func buildGoogleWalletObjects(req *GoogleWalletRequest, issuerID string) (class, object map[string]interface{}, warnings []string) {
+	p := &req.UnifiedBoardingPass
+	if issuerID == "" {
+		issuerID = "ISSUER_ID"
+	}
+
+	flight := strings.TrimLeft(strings.TrimSpace(p.FlightNumber), "0")
+	classSuffix := walletIDChars.ReplaceAllString(strings.Join([]string{p.Carrier, flight, p.DateISO}, "-"), "_")
+	objectID := p.ID
+	if objectID == "" {
+		objectID = passID(p)
+	}
+
+	origin := map[string]interface{}{"airportIataCode": p.Departure}
+	gate := req.Gate
+	if gate == "" {
+		gate = p.RawData["gate"]
+	}
+	if gate != "" {
+		origin["gate"] = gate
+	}
+	if req.Terminal != "" {
+		origin["terminal"] = req.Terminal
+	}
+
+	class = map[string]interface{}{
+		"id":           issuerID + "." + classSuffix,
+		"issuerName":   strings.TrimSpace(p.Carrier),
+		"reviewStatus": "UNDER_REVIEW",
+		"flightHeader": map[string]interface{}{
+			"carrier":      map[string]interface{}{"carrierIataCode": strings.TrimSpace(p.Carrier)},
+			"flightNumber": flight,
+		},
+		"origin":      origin,
+		"destination": map[string]interface{}{"airportIataCode": p.Arrival},
+	}
+
+	if p.DateISO != "" {
+		if dep := localDateTime(p.DateISO, p.DepartureTime); dep != "" {
+			class["localScheduledDepartureDateTime"] = dep
+		} else {
+			warnings = append(warnings, "departure time unknown: localScheduledDepartureDateTime omitted (Google requires it before the class can be approved)")
+		}
+		if board := localDateTime(p.DateISO, p.BoardingTime); board != "" {
+			class["localBoardingDateTime"] = board
+		}
+		if arr := localDateTime(p.DateISO, req.ArrivalTime); arr != "" {
+			class["localScheduledArrivalDateTime"] = arr
+		}
+	} else {
+		warnings = append(warnings, "flight date unknown: all scheduled times omitted")
+	}
+
+	seating := map[string]interface{}{}
+	if p.Seat != "" {
+		seating["seatNumber"] = strings.TrimLeft(p.Seat, "0")
+	} else {
+		warnings = append(warnings, "no seat assigned: seatNumber omitted")
+	}
+	if p.CabinClass != "" {
+		seating["seatClass"] = p.CabinClass
+	}
+	if req.BoardingGroup != "" {
+		seating["boardingGroup"] = req.BoardingGroup
+	}
+
+	object = map[string]interface{}{
+		"id":            issuerID + "." + objectID,
+		"classId":       class["id"],
+		"state":         "ACTIVE",
+		"passengerName": p.PassengerName,
+		"reservationInfo": map[string]interface{}{
+			"confirmationCode": p.PNR,
+		},
+	}
+	if len(seating) > 0 {
+		object["boardingAndSeatingInfo"] = seating
+	}
+
+	barcodeValue := p.RawData["raw_string"]
+	if barcodeValue == "" {
+		barcodeValue = bcbp.Encode(p)
+	}
+	object["barcode"] = map[string]interface{}{"type": "AZTEC", "value": barcodeValue}
+
+	return class, object, warnings
+}
+
+// localDateTime joins an ISO date and an "HH:MM" clock into Google's
+// ISO 8601 local date-time without offset; "" if the time is unknown.
+func localDateTime(date, clock string) string {
+	if clock == "" {
+		return ""
+	}
+	t, err := time.Parse("2006-01-02 15:04", date+" "+clock)
+	if err != nil {
+		return ""
+	}
+	return t.Format("2006-01-02T15:04:05")
+}
+
+// GoogleWalletResponse carries the Wallet resources and, with a service
+// account configured, the signed JWT and its "Add to Google Wallet" link.
+type GoogleWalletResponse struct {
+	FlightClass  map[string]interface{} `json:"flight_class"`
+	FlightObject map[string]interface{} `json:"flight_object"`
+	Signed       bool                   `json:"signed"`
+	JWT          string                 `json:"jwt,omitempty"`
+	SaveURL      string                 `json:"save_url,omitempty"`
+	Warnings     []string               `json:"warnings,omitempty"`
+}
+
+func handleExportGoogleWallet(w http.ResponseWriter, r *http.Request) {
+	var req GoogleWalletRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Carrier == "" || req.Departure == "" || req.Arrival == "" {
+		httpError(w, "carrier, departure_airport and arrival_airport are required", http.StatusBadRequest)
+		return
+	}
+
+	issuerID := googleWalletIssuerID
+	if googleWallet != nil {
+		issuerID = googleWallet.issuerID
+	}
+	class, object, warnings := buildGoogleWalletObjects(&req, issuerID)
+
+	resp := GoogleWalletResponse{FlightClass: class, FlightObject: object, Warnings: warnings}
+
+	if googleWallet != nil {
+		jwt, err := googleWallet.signJWT(map[string]interface{}{
+			"iss":     googleWallet.clientEmail,
+			"aud":     "google",
+			"typ":     "savetowallet",
+			"iat":     time.Now().Unix(),
+			"origins": []string{},
+			"payload": map[string]interface{}{
+				"flightClasses": []interface{}{class},
+				"flightObjects": []interface{}{object},
+			},
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error signing Google Wallet JWT", "err", err)
+			httpError(w, "Error signing Google Wallet JWT", http.StatusInternalServerError)
+			return
+		}
+		resp.Signed = true
+		resp.JWT = jwt
+		resp.SaveURL = "https://pay.google.com/gp/v/save/" + jwt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,310 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"runtime/debug"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/flightinfopb"
+	"bugsbyte/flight-info/scan"
+)
+
+// ----------------------
+// gRPC API
+// ----------------------
+
+// grpcAddr (GRPC_ADDR) is where the gRPC API listens, "off" when it is
+// disabled.
+var grpcAddr string
+
+// grpcServer serves flightinfopb.FlightInfo with the same parsers and
+// post-processing as the HTTP handlers. It skips the parse response cache,
+// which holds encoded JSON.
+type grpcServer struct {
+	flightinfopb.UnimplementedFlightInfoServer
+}
+
+func init() {
+	describeMetric("grpc_panics_total", "counter", "gRPC handler panics recovered by the server.")
+	metric("grpc_panics_total")
+}
+
+func newGRPCServer() *grpc.Server {
+	s := grpc.NewServer(
+		// Room for the largest image plus the rest of the message.
+		grpc.MaxRecvMsgSize(scan.MaxImageBytes+1<<20),
+		grpc.ChainUnaryInterceptor(traceUnary, logUnary, recoverUnary, rateLimitUnary, recordFailuresUnary, budgetUnary),
+		grpc.ChainStreamInterceptor(traceStream, logStream, recoverStream, rateLimitStream),
+	)
+	flightinfopb.RegisterFlightInfoServer(s, &grpcServer{})
+	// Lets grpcurl and similar tools list and call the service without the .proto.
+	reflection.Register(s)
+	return s
+}
+
+func (grpcServer) ParseBarcode(ctx context.Context, req *flightinfopb.ParseBarcodeRequest) (*flightinfopb.BoardingPass, error) {
+	data, err := parseBarcodeText(ctx, req.GetBarcode(), time.Now(), bcbpLenient)
+	if err != nil {
+		slog.InfoContext(ctx, "Error parsing barcode", "err", err)
+		return nil, status.Errorf(codes.InvalidArgument, "Error parsing barcode: %v", err)
+	}
+	return passToProto(processPass(ctx, data, optionValues(req.GetOptions()))), nil
+}
+
+func (grpcServer) ParseBarcodeImage(ctx context.Context, req *flightinfopb.ParseBarcodeImageRequest) (*flightinfopb.BoardingPass, error) {
+	data, _, err := parseImage(ctx, req.GetImage())
+	if err != nil {
+		return nil, err
+	}
+	return passToProto(processPass(ctx, data, optionValues(req.GetOptions()))), nil
+}
+
+func (grpcServer) ParsePkPass(ctx context.Context, req *flightinfopb.ParsePkPassRequest) (*flightinfopb.BoardingPass, error) {
+	file := req.GetPkpass()
+	if len(file) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "pkpass is required")
+	}
+	release, err := acquireHeavyRPC(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	data, err := parsePKPass(ctx, bytes.NewReader(file), int64(len(file)))
+	release()
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Error parsing pkpass: %v", err)
+	}
+	ctx = withWebService(ctx, bytes.NewReader(file), int64(len(file)))
+	return passToProto(processPass(ctx, data, optionValues(req.GetOptions()))), nil
+}
+
+// ScanFrames decodes frames in arrival order. Frames without a barcode, or
+// with one that isn't a boarding pass (the camera may catch any QR code),
+// are skipped.
+func (grpcServer) ScanFrames(stream flightinfopb.FlightInfo_ScanFramesServer) error {
+	ctx := stream.Context()
+	var (
+		opts     url.Values
+		received uint32
+	)
+	for {
+		frame, err := stream.Recv()
+		if err == io.EOF {
+			return status.Errorf(codes.NotFound, "No boarding pass barcode found in %d frames", received)
+		}
+		if err != nil {
+			return err
+		}
+		if received == 0 {
+			opts = optionValues(frame.GetOptions())
+		}
+		received++
+
+		fctx := withBudget(ctx)
+		data, format, err := parseImage(fctx, frame.GetImage())
+		if status.Code(err) == codes.ResourceExhausted {
+			return err
+		}
+		if err != nil {
+			continue
+		}
+		return stream.SendAndClose(&flightinfopb.ScanResult{
+			Pass:           passToProto(processPass(fctx, data, opts)),
+			FrameIndex:     received - 1,
+			BarcodeFormat:  format,
+			FramesReceived: received,
+		})
+	}
+}
+
+// parseImage decodes and parses one image under the heavy-work limiter,
+// with the same status codes for both image RPCs.
+func parseImage(ctx context.Context, img []byte) (*bcbp.UnifiedBoardingPass, string, error) {
+	if len(img) == 0 {
+		return nil, "", status.Error(codes.InvalidArgument, "image is required")
+	}
+	if len(img) > scan.MaxImageBytes {
+		return nil, "", status.Error(codes.InvalidArgument, "Image too large")
+	}
+	release, err := acquireHeavyRPC(ctx, scan.Weight(img))
+	if err != nil {
+		return nil, "", err
+	}
+	prof, _ := scan.LookupDecodeProfile(serverDecodeProfile())
+	res, err := scan.DecodeWith(ctx, img, prof)
+	release()
+	if errors.Is(err, scan.ErrNoBarcode) {
+		return nil, "", status.Error(codes.NotFound, "Error decoding image: "+err.Error())
+	}
+	if err != nil {
+		return nil, "", status.Errorf(codes.InvalidArgument, "Error decoding image: %v", err)
+	}
+	data, err := parseBCBP(ctx, res.Text, time.Now(), bcbpLenient)
+	if err != nil {
+		return nil, "", status.Errorf(codes.InvalidArgument, "Error parsing barcode: %v", err)
+	}
+	tagImagePass(data, res)
+	return data, res.Format, nil
+}
+
+// acquireHeavyRPC is acquireHeavy for gRPC: a full queue or a timed-out wait
+// becomes RESOURCE_EXHAUSTED.
+func acquireHeavyRPC(ctx context.Context, weight int64) (release func(), err error) {
+	if heavyWork == nil {
+		return func() {}, nil
+	}
+	release, err = heavyWork.acquire(ctx, weight)
+	if err != nil {
+		return nil, status.Errorf(codes.ResourceExhausted, "Server busy: %v, retry later", err)
+	}
+	return release, nil
+}
+
+// optionValues maps ParseOptions onto the query parameters processPass reads.
+func optionValues(o *flightinfopb.ParseOptions) url.Values {
+	q := url.Values{}
+	for name, on := range map[string]bool{
+		"enrich": o.GetEnrich(),
+		"status": o.GetStatus(),
+		"redact": o.GetRedact(),
+		"force":  o.GetForce(),
+	} {
+		if on {
+			q.Set(name, "true")
+		}
+	}
+	return q
+}
+
+func passToProto(p *bcbp.UnifiedBoardingPass) *flightinfopb.BoardingPass {
+	pb := &flightinfopb.BoardingPass{
+		Id:                    p.ID,
+		Source:                string(p.Source),
+		PassengerName:         p.PassengerName,
+		Pnr:                   p.PNR,
+		FlightNumber:          p.FlightNumber,
+		DepartureAirport:      p.Departure,
+		ArrivalAirport:        p.Arrival,
+		DateJulian:            p.Date,
+		DateIso:               p.DateISO,
+		BoardingTime:          p.BoardingTime,
+		DepartureTime:         p.DepartureTime,
+		Seat:                  p.Seat,
+		CabinClass:            p.CabinClass,
+		Carrier:               p.Carrier,
+		RawExtraData:          p.RawData,
+		BoardingTimeLocal:     p.BoardingTimeLocal,
+		BoardingTimeUtc:       p.BoardingTimeUTC,
+		DepartureTimeLocal:    p.DepartureTimeLocal,
+		DepartureTimeUtc:      p.DepartureTimeUTC,
+		CarrierName:           p.CarrierName,
+		MarketingCarrier:      p.MarketingCarrier,
+		MarketingCarrierName:  p.MarketingCarrierName,
+		Warnings:              p.Warnings,
+		Duplicate:             p.Duplicate,
+		Updated:               p.Updated,
+		PassengerType:         p.PassengerType,
+		Gate:                  p.Gate,
+		Terminal:              p.Terminal,
+		CheckInDesk:           p.CheckInDesk,
+		BoardingDoor:          p.BoardingDoor,
+		BoardingGroup:         p.BoardingGroup,
+		SequenceNumber:        p.SequenceNumber,
+		Legs:                  legsToProto(p.Legs),
+		GroupPass:             p.GroupPass,
+		Passengers:            legsToProto(p.Passengers),
+		ParsedAt:              p.ParsedAt,
+		TransitMode:           string(p.TransitMode),
+		DepartureLocationType: string(p.DepartureType),
+		ArrivalLocationType:   string(p.ArrivalType),
+		SeatStatus:            p.SeatStatus,
+		PriorityBoarding:      p.PriorityBoarding,
+		FastTrack:             p.FastTrack,
+		PassengerStatus:       p.Status,
+		DateOfBirth:           p.DateOfBirth,
+		DepartureAirportName:  p.DepartureAirportName,
+		DepartureCity:         p.DepartureCity,
+		ArrivalAirportName:    p.ArrivalAirportName,
+		ArrivalCity:           p.ArrivalCity,
+	}
+	if len(p.FieldSources) > 0 {
+		pb.FieldSources = make(map[string]string, len(p.FieldSources))
+		for field, src := range p.FieldSources {
+			pb.FieldSources[field] = string(src)
+		}
+	}
+	for _, c := range p.Changes {
+		pb.Changes = append(pb.Changes, &flightinfopb.FieldChange{Field: c.Field, Old: c.Old, New: c.New})
+	}
+	if s := p.FlightStatus; s != nil {
+		pb.FlightStatus = &flightinfopb.FlightStatus{
+			Provider:           s.Provider,
+			Status:             s.Status,
+			Cancelled:          s.Cancelled,
+			ScheduledDeparture: s.ScheduledDeparture,
+			EstimatedDeparture: s.EstimatedDeparture,
+			ScheduledArrival:   s.ScheduledArrival,
+			EstimatedArrival:   s.EstimatedArrival,
+			DelayMinutes:       int32(s.DelayMinutes),
+			Gate:               s.Gate,
+			Terminal:           s.Terminal,
+		}
+	}
+	return pb
+}
+
+func legsToProto(legs []bcbp.PassengerLeg) []*flightinfopb.PassengerLeg {
+	var pb []*flightinfopb.PassengerLeg
+	for _, l := range legs {
+		pb = append(pb, &flightinfopb.PassengerLeg{
+			PassengerName:    l.PassengerName,
+			Pnr:              l.PNR,
+			DepartureAirport: l.Departure,
+			ArrivalAirport:   l.Arrival,
+			Carrier:          l.Carrier,
+			FlightNumber:     l.FlightNumber,
+			DateJulian:       l.Date,
+			Seat:             l.Seat,
+			SequenceNumber:   l.SequenceNumber,
+			PassengerStatus:  l.Status,
+			Conditional:      l.Conditional,
+		})
+	}
+	return pb
+}
+
+// recoverUnary and recoverStream are recoverMiddleware for gRPC: the panic is
+// logged with its stack and the client gets INTERNAL.
+func recoverUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = recoveredRPC(info.FullMethod, v)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func recoverStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = recoveredRPC(info.FullMethod, v)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+func recoveredRPC(method string, v any) error {
+	metric("grpc_panics_total").Inc()
+	slog.Error("Panic serving gRPC", "endpoint", method, "panic", v, "stack", string(debug.Stack()))
+	return status.Error(codes.Internal, "Internal server error")
+}
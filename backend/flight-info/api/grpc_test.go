@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"bugsbyte/flight-info/flightinfopb"
+)
+
+func TestParseBarcodeRPCHasLegs(t *testing.T) {
+	req := &flightinfopb.ParseBarcodeRequest{Barcode: readFixture(t, "bcbp/lh-ber-fra-jfk-two-legs.bcbp")}
+	pb, err := grpcServer{}.ParseBarcode(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legs := pb.GetLegs()
+	if len(legs) != 2 {
+		t.Fatalf("%d legs, want 2", len(legs))
+	}
+	if got := legs[0].GetDepartureAirport() + "-" + legs[1].GetArrivalAirport(); got != "BER-JFK" {
+		t.Errorf("legs go %s, want BER-JFK", got)
+	}
+	if legs[1].GetFlightNumber() == "" || legs[1].GetPnr() != "KLM4PQ" {
+		t.Errorf("second leg: %v", legs[1])
+	}
+}
+
+// TestParseBarcodeRPCMirrorsJSON checks the fields added to BoardingPass
+// after the first release against the JSON pass: a train leg with fast
+// track, and a pass with a date of birth and no fast track.
+func TestParseBarcodeRPCMirrorsJSON(t *testing.T) {
+	rail := parseRPC(t, "bcbp/lh-qkl-fra-jfk-airail.bcbp", true)
+	if rail.GetTransitMode() != "train" || rail.GetDepartureLocationType() != "rail" {
+		t.Errorf("transit_mode %q, departure_location_type %q; want train, rail", rail.GetTransitMode(), rail.GetDepartureLocationType())
+	}
+	if rail.FastTrack == nil || !*rail.FastTrack {
+		t.Errorf("fast_track %v, want set and true", rail.FastTrack)
+	}
+	if rail.GetParsedAt() == "" || rail.GetPassengerStatus() != "1" {
+		t.Errorf("parsed_at %q, passenger_status %q", rail.GetParsedAt(), rail.GetPassengerStatus())
+	}
+	if got := rail.GetFieldSources()["carrier"]; got != "bcbp_mandatory" {
+		t.Errorf("field_sources[carrier] %q, want bcbp_mandatory", got)
+	}
+	if rail.GetArrivalAirportName() == "" || rail.GetArrivalCity() == "" {
+		t.Errorf("enriched arrival %q, %q; want the airport name and city", rail.GetArrivalAirportName(), rail.GetArrivalCity())
+	}
+
+	dob := parseRPC(t, "bcbp/dl-atl-lax-dob-ktn.bcbp", false)
+	if dob.GetDateOfBirth() != "1985-03-14" {
+		t.Errorf("date_of_birth %q, want 1985-03-14", dob.GetDateOfBirth())
+	}
+	if dob.FastTrack == nil || *dob.FastTrack {
+		t.Errorf("fast_track %v, want set and false", dob.FastTrack)
+	}
+	if dob.GetArrivalAirportName() != "" {
+		t.Errorf("arrival_airport_name %q without enrich", dob.GetArrivalAirportName())
+	}
+}
+
+func parseRPC(t *testing.T, fixture string, enrich bool) *flightinfopb.BoardingPass {
+	t.Helper()
+	req := &flightinfopb.ParseBarcodeRequest{
+		Barcode: readFixture(t, fixture),
+		Options: &flightinfopb.ParseOptions{Enrich: enrich},
+	}
+	pb, err := grpcServer{}.ParseBarcode(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pb
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ----------------------
+// HANDLER GOLDEN TESTS
+// ----------------------
+
+// The responses of the main endpoints, status, headers and body, are
+// checked against testdata/handlers/<name>.golden, so a refactor that
+// changes one byte of them shows up. Run with -update to rewrite the files
+// after an intended change.
+
+var update = flag.Bool("update", false, "rewrite the golden files under testdata")
+
+// goldenRequestID is sent as X-Request-ID so error bodies and headers
+// don't change between runs.
+const goldenRequestID = "golden"
+
+// parsedAtPattern matches parsed_at, the one pass field set from the clock.
+var parsedAtPattern = regexp.MustCompile(`"parsed_at":"[^"]*"`)
+
+type handlerCase struct {
+	name        string
+	method      string
+	target      string
+	contentType string
+	body        func(t *testing.T) []byte
+	header      []string // name, value pairs
+}
+
+func TestHandlerGolden(t *testing.T) {
+	barcode := func(name string) func(t *testing.T) []byte {
+		return func(t *testing.T) []byte {
+			return jsonBody(t, BarcodeRequest{Barcode: readFixture(t, name)})
+		}
+	}
+	text := func(s string) func(t *testing.T) []byte {
+		return func(*testing.T) []byte { return []byte(s) }
+	}
+	const ref = "reference_date=2026-06-01"
+
+	cases := []handlerCase{
+		{name: "barcode", method: http.MethodPost, target: "/parse/barcode?" + ref, contentType: "application/json", body: barcode("bcbp/ac-yul-fra-mandatory.bcbp")},
+		{name: "barcode_v1", method: http.MethodPost, target: "/v1/parse/barcode?" + ref, contentType: "application/json", body: barcode("bcbp/ac-yul-fra-mandatory.bcbp")},
+		{name: "barcode_enrich_pt", method: http.MethodPost, target: "/parse/barcode?enrich=true&" + ref, contentType: "application/json", body: barcode("bcbp/lh-ber-fra-jfk-two-legs.bcbp"), header: []string{"Accept-Language", "pt"}},
+		{name: "barcode_redact", method: http.MethodPost, target: "/parse/barcode?redact=true&" + ref, contentType: "application/json", body: barcode("bcbp/aa-dfw-ord-dob-ktn-redress.bcbp")},
+		{name: "barcode_invalid", method: http.MethodPost, target: "/parse/barcode", contentType: "application/json", body: text(`{"barcode":"M1NOT A PASS"}`)},
+		{name: "barcode_bad_json", method: http.MethodPost, target: "/parse/barcode", contentType: "application/json", body: text(`{"barcode":`)},
+		{name: "barcode_wrong_type", method: http.MethodPost, target: "/parse/barcode", contentType: "text/plain", body: text("M1")},
+		{name: "pkpass", method: http.MethodPost, target: "/parse/pkpass?" + ref, body: nil},
+		{name: "image", method: http.MethodPost, target: "/parse/barcode/image?" + ref, contentType: "application/json", body: func(t *testing.T) []byte {
+			return jsonBody(t, BarcodeImageRequest{Image: base64.StdEncoding.EncodeToString(readFile(t, "data/selftest/ac-aztec.png"))})
+		}},
+		{name: "julian", method: http.MethodGet, target: "/util/julian?day=326&reference=2026-06-01"},
+		{name: "airport", method: http.MethodGet, target: "/airports/LIS"},
+		{name: "method_not_allowed", method: http.MethodPut, target: "/parse/barcode"},
+		{name: "not_found", method: http.MethodGet, target: "/nope"},
+		{name: "preflight", method: http.MethodOptions, target: "/parse/barcode", header: []string{"Origin", "https://example.com", "Access-Control-Request-Method", "POST"}},
+	}
+	// The pkpass case needs a multipart body.
+	pk := &cases[slices.IndexFunc(cases, func(c handlerCase) bool { return c.name == "pkpass" })]
+	pk.body = func(t *testing.T) []byte {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		mw.SetBoundary("goldenboundary")
+		fw, _ := mw.CreateFormFile("file", "ac-yul-fra.pkpass")
+		fw.Write(readFile(t, "data/selftest/ac-yul-fra.pkpass"))
+		mw.Close()
+		return buf.Bytes()
+	}
+	pk.contentType = "multipart/form-data; boundary=goldenboundary"
+
+	// The date window and the ETags follow the day; pin it to ref's.
+	setForTest(t, &timeNow, func() time.Time { return time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC) })
+	h := Handler()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var body io.Reader
+			if c.body != nil {
+				body = bytes.NewReader(c.body(t))
+			}
+			header := append([]string{"X-Request-ID", goldenRequestID}, c.header...)
+			if c.contentType != "" {
+				header = append(header, "Content-Type", c.contentType)
+			}
+			w := serve(t, h, c.method, c.target, body, header...)
+			checkGolden(t, filepath.Join("testdata", "handlers", c.name+".golden"), dumpResponse(w.Code, w.Header(), w.Body.Bytes()))
+		})
+	}
+}
+
+// dumpResponse writes the status, the headers sorted by name and the body,
+// with parsed_at and Last-Modified, set from the clock, replaced. A parse
+// response's ETag hashes its body, parsed_at included, so it is replaced
+// too once checked against the body.
+func dumpResponse(status int, h http.Header, body []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(strconv.Itoa(status) + " " + http.StatusText(status) + "\n")
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		v := strings.Join(h.Values(name), ", ")
+		switch {
+		case name == "Last-Modified":
+			v = "LAST_MODIFIED"
+		case name == "Etag" && v == bodyETag(body):
+			v = "BODY_ETAG"
+		}
+		b.WriteString(name + ": " + v + "\n")
+	}
+	b.WriteString("\n")
+	b.Write(parsedAtPattern.ReplaceAll(body, []byte(`"parsed_at":"PARSED_AT"`)))
+	return b.Bytes()
+}
+
+// checkGolden compares got with the file at path, or rewrites the file
+// with -update.
+func checkGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("response differs from %s:\n--- got\n%s\n--- want\n%s", path, got, want)
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// HANDLERS: PASS HISTORY
+// ----------------------
+
+// Every update of a stored pass that changes a field (see bcbp.Diff),
+// whether a parse with ?force=true, a refresh or an import with -force,
+// is kept as a revision with what it changed. GET /passes/{id}/history
+// lists them after the version first stored. Revisions are deleted with
+// their pass, and are not in backups.
+
+func handlePassHistory(w http.ResponseWriter, r *http.Request) {
+	if passStore == nil {
+		httpError(w, "Persistence is disabled (set SQLITE_PATH or DATABASE_URL)", http.StatusNotImplemented)
+		return
+	}
+	id := r.PathValue("id")
+
+	h, err := passStore.History(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		httpError(w, "Pass not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching pass history", "pass", id, "err", err)
+		httpError(w, "Error fetching pass history", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h)
+}
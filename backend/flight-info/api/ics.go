@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/internal/refdata"
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// EXPORT: ICS CALENDAR EVENT (RFC 5545)
+// ----------------------
+
+var errNoFlightDate = errors.New("pass has no resolvable flight date")
+
+// buildICS renders the pass as a single-event VCALENDAR. The event is timed
+// when a departure or boarding time is known and all-day otherwise. Times are
+// floating (no TZID): the pass only knows the local wall-clock time.
+func buildICS(p *bcbp.UnifiedBoardingPass, now time.Time) (string, error) {
+	date, err := time.Parse(time.DateOnly, p.DateISO)
+	if err != nil {
+		return "", errNoFlightDate
+	}
+
+	uid := p.ID
+	if uid == "" {
+		uid = passID(p)
+	}
+
+	var lines []string
+	add := func(l string) { lines = append(lines, l) }
+
+	add("BEGIN:VCALENDAR")
+	add("VERSION:2.0")
+	add("PRODID:-//bugsbyte//flight-info//EN")
+	add("CALSCALE:GREGORIAN")
+	add("METHOD:PUBLISH")
+	add("BEGIN:VEVENT")
+	add("UID:" + uid + "@flight-info")
+	add("DTSTAMP:" + now.UTC().Format("20060102T150405Z"))
+
+	clock := p.DepartureTime
+	if clock == "" {
+		clock = p.BoardingTime
+	}
+	if t, err := time.Parse("15:04", clock); err == nil {
+		start := time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+		add("DTSTART:" + start.Format("20060102T150405"))
+	} else {
+		add("DTSTART;VALUE=DATE:" + date.Format("20060102"))
+		add("DTEND;VALUE=DATE:" + date.AddDate(0, 0, 1).Format("20060102"))
+	}
+
+	add("SUMMARY:" + icsEscape(icsSummary(p)))
+	if loc := icsLocation(p.Departure); loc != "" {
+		add("LOCATION:" + icsEscape(loc))
+	}
+	if desc := icsDescription(p); desc != "" {
+		add("DESCRIPTION:" + icsEscape(desc))
+	}
+	add("END:VEVENT")
+	add("END:VCALENDAR")
+
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(icsFold(l))
+		b.WriteString("\r\n")
+	}
+	return b.String(), nil
+}
+
+// icsSummary reads like "TP432 LIS→FRA".
+func icsSummary(p *bcbp.UnifiedBoardingPass) string {
+	flight := strings.TrimLeft(strings.TrimSpace(p.FlightNumber), "0")
+	summary := strings.TrimSpace(p.Carrier) + flight
+	if p.Departure != "" || p.Arrival != "" {
+		summary = strings.TrimSpace(summary + " " + p.Departure + "→" + p.Arrival)
+	}
+	if summary == "" {
+		summary = "Flight"
+	}
+	return summary
+}
+
+func icsLocation(code string) string {
+	if a, ok := refdata.LookupAirport(code); ok {
+		return fmt.Sprintf("%s (%s)", a.Name, a.Code)
+	}
+	return code
+}
+
+func icsDescription(p *bcbp.UnifiedBoardingPass) string {
+	var parts []string
+	if p.PNR != "" {
+		parts = append(parts, "PNR: "+p.PNR)
+	}
+	if p.Seat != "" {
+		parts = append(parts, "Seat: "+p.Seat)
+	}
+	if gate := p.RawData["gate"]; gate != "" {
+		parts = append(parts, "Gate: "+gate)
+	}
+	if p.BoardingTime != "" {
+		parts = append(parts, "Boarding: "+p.BoardingTime)
+	}
+	return strings.Join(parts, "\n")
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func icsEscape(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+// icsFold splits content lines longer than 75 octets, never inside a UTF-8
+// sequence; continuation lines start with a single space.
+func icsFold(line string) string {
+	const limit = 75
+	if len(line) <= limit {
+		return line
+	}
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		n := utf8.RuneLen(r)
+		if width+n > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += n
+	}
+	return b.String()
+}
+
+func writeICS(w http.ResponseWriter, p *bcbp.UnifiedBoardingPass) {
+	ics, err := buildICS(p, time.Now())
+	if err != nil {
+		httpError(w, fmt.Sprintf("Error building calendar event: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+	name := strings.ReplaceAll(icsSummary(p), "→", "-")
+	name = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '"' || r == '/' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.ics"`, name))
+	w.Write([]byte(ics))
+}
+
+func handlePassICS(w http.ResponseWriter, r *http.Request) {
+	if passStore == nil {
+		httpError(w, "Persistence is disabled (set SQLITE_PATH or DATABASE_URL)", http.StatusNotImplemented)
+		return
+	}
+
+	id := r.PathValue("id")
+	sp, err := passStore.Get(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		httpError(w, "Pass not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching pass", "pass", id, "err", err)
+		httpError(w, "Error fetching pass", http.StatusInternalServerError)
+		return
+	}
+	writeICS(w, sp.Pass)
+}
+
+func handleExportICS(w http.ResponseWriter, r *http.Request) {
+	var pass bcbp.UnifiedBoardingPass
+	if err := json.NewDecoder(r.Body).Decode(&pass); err != nil {
+		httpError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	writeICS(w, &pass)
+}
@@ -0,0 +1,432 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/sync/errgroup"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/pkpass"
+	"bugsbyte/flight-info/scan"
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// ADMIN: BULK IMPORT
+// ----------------------
+
+// Passes kept from before the server stored them, a folder of .pkpass
+// files, barcode photos and text exports with one barcode per line, are
+// loaded with `flightinfo import PATH` or POST /admin/import (a zip of
+// the folder). Each file goes to the parser its content calls for, and
+// its passes are stored like a parse with the same dedup: a pass already
+// stored is skipped, or replaced with force. Nothing goes to webhooks.
+//
+// Barcodes carry no year, so their dates are resolved around the file's
+// modification time rather than today. Runs are resumable: the SHA-256 of
+// every file, or text line, whose pass was stored or already there is
+// recorded, and later runs skip it without parsing. A file that fails is
+// reported and left for the next run; it never stops the others.
+
+// Outcomes of an ImportItem.
+const (
+	ImportImported = "imported"
+	ImportUpdated  = "updated" // with force, over a stored pass
+	ImportSkipped  = "skipped" // a duplicate, or already imported
+	ImportFailed   = "failed"
+)
+
+const (
+	// maxImportBody caps the zip upload of POST /admin/import.
+	maxImportBody = 512 << 20
+	// maxImportFile caps one file of an import. Images are held to
+	// scan.MaxImageBytes besides.
+	maxImportFile = 64 << 20
+)
+
+// importZipLimits bound the archive of POST /admin/import, and a zip
+// given to the import command.
+var importZipLimits = pkpass.ArchiveLimits{MaxEntries: 50000, MaxUncompressed: 4 << 30}
+
+// importWorkers (IMPORT_WORKERS) is how many files an import parses at once.
+var importWorkers = defaultConfig.ImportWorkers
+
+// ImportItem is the outcome for one file, or one line of a text export.
+type ImportItem struct {
+	File   string `json:"file"`
+	Line   int    `json:"line,omitempty"`
+	Status string `json:"status"`
+	PassID string `json:"pass_id,omitempty"`
+	// Reason says why the item was skipped or failed.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ImportReport is the body of POST /admin/import, and what the import
+// command prints. Items are in file order.
+type ImportReport struct {
+	Imported int          `json:"imported"`
+	Updated  int          `json:"updated"`
+	Skipped  int          `json:"skipped"`
+	Failed   int          `json:"failed"`
+	Items    []ImportItem `json:"items"`
+}
+
+// ImportOptions are the flags of the import command.
+type ImportOptions struct {
+	Force   bool
+	Workers int // 0 for IMPORT_WORKERS
+}
+
+// Import loads the passes under path, a directory, a zip archive or a
+// single file, into the pass store. The configuration is read
+// as the server reads it. Only errors that stop the whole run, such as an
+// unreadable path or database, are returned.
+func Import(ctx context.Context, path string, opts ImportOptions) (*ImportReport, error) {
+	if err := loadConfigFile(); err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	dbURL, err := cfg.databaseURL()
+	if err != nil {
+		return nil, err
+	}
+	if dbURL == "" {
+		return nil, errors.New("neither SQLITE_PATH nor DATABASE_URL is set: imported passes go to the pass store")
+	}
+	im := &importer{force: opts.Force, workers: opts.Workers, redact: cfg.RedactPII, lenient: cfg.BCBPLenient}
+	if im.workers == 0 {
+		im.workers = cfg.ImportWorkers
+	}
+	im.profile, _ = scan.LookupDecodeProfile(cfg.DecodeProfile)
+
+	fsys, root, done, err := importSource(path)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	if im.store, err = storage.Open(dbURL); err != nil {
+		return nil, err
+	}
+	defer im.store.Close()
+	return im.run(ctx, fsys, root), nil
+}
+
+// importSource opens path for walking: a directory as is, a .zip as its
+// entries, and any other file as a directory holding only it, root.
+func importSource(p string) (fsys fs.FS, root string, done func(), err error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if info.IsDir() {
+		return os.DirFS(p), ".", func() {}, nil
+	}
+	if !strings.EqualFold(filepath.Ext(p), ".zip") {
+		return os.DirFS(filepath.Dir(p)), filepath.Base(p), func() {}, nil
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	zr, err := pkpass.OpenArchive(f, info.Size(), importZipLimits)
+	if err != nil {
+		f.Close()
+		return nil, "", nil, fmt.Errorf("reading %s: %w", p, err)
+	}
+	return zr, ".", func() { f.Close() }, nil
+}
+
+// importer is one import run.
+type importer struct {
+	store   storage.Store
+	force   bool
+	redact  bool
+	lenient bool
+	workers int
+	profile scan.DecodeProfile
+
+	mu     sync.Mutex
+	report ImportReport
+}
+
+// run imports every file under root in fsys, workers at a time. Once ctx
+// is done it starts no more files; the report has those it got to.
+func (im *importer) run(ctx context.Context, fsys fs.FS, root string) *ImportReport {
+	var g errgroup.Group
+	g.SetLimit(im.workers)
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			im.add(ImportItem{File: name, Status: ImportFailed, Reason: err.Error()})
+			return nil
+		case name != root && hiddenEntry(name):
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		case d.IsDir():
+			return nil
+		}
+		g.Go(func() error {
+			im.file(ctx, fsys, name, d)
+			return nil
+		})
+		return nil
+	})
+	g.Wait()
+	if err != nil && ctx.Err() == nil {
+		im.add(ImportItem{File: root, Status: ImportFailed, Reason: err.Error()})
+	}
+
+	r := &im.report
+	slices.SortFunc(r.Items, func(a, b ImportItem) int {
+		return cmp.Or(strings.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+	})
+	for _, it := range r.Items {
+		switch it.Status {
+		case ImportImported:
+			r.Imported++
+		case ImportUpdated:
+			r.Updated++
+		case ImportSkipped:
+			r.Skipped++
+		case ImportFailed:
+			r.Failed++
+		}
+	}
+	if r.Items == nil {
+		r.Items = []ImportItem{}
+	}
+	return r
+}
+
+func (im *importer) add(it ImportItem) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.report.Items = append(im.report.Items, it)
+}
+
+// file imports one file, routed by its content: a .pkpass, an image, or
+// text with a barcode on each line.
+func (im *importer) file(ctx context.Context, fsys fs.FS, name string, d fs.DirEntry) {
+	fail := func(reason string) { im.add(ImportItem{File: name, Status: ImportFailed, Reason: reason}) }
+	data, err := readImportFile(fsys, name)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	ref := time.Now()
+	if info, err := d.Info(); err == nil && !info.ModTime().IsZero() {
+		ref = info.ModTime()
+	}
+
+	kind, ok := sniffUpload(data)
+	switch {
+	case ok && kind == kindPkPass:
+		im.storeItem(ctx, ImportItem{File: name}, data, func() (*bcbp.UnifiedBoardingPass, error) {
+			p, err := parsePKPass(ctx, bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				return nil, fmt.Errorf("error parsing pkpass: %w", err)
+			}
+			return p, nil
+		})
+	case ok && kind.isImage():
+		im.storeItem(ctx, ImportItem{File: name}, data, func() (*bcbp.UnifiedBoardingPass, error) {
+			res, err := scan.DecodeWith(ctx, data, im.profile)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding image: %w", err)
+			}
+			p, err := parseBCBP(ctx, res.Text, ref, im.lenient)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing barcode: %w", err)
+			}
+			tagImagePass(p, res)
+			return p, nil
+		})
+	case ok:
+		fail("unsupported file: this looks like " + kind.Label)
+	case !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0:
+		fail("unsupported file: not a .pkpass, an image or barcode text")
+	default:
+		for i, line := range strings.Split(string(data), "\n") {
+			// Keep trailing spaces, which are part of the fixed-width format.
+			line = strings.TrimRight(line, "\r")
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			im.storeItem(ctx, ImportItem{File: name, Line: i + 1}, []byte(line), func() (*bcbp.UnifiedBoardingPass, error) {
+				p, err := parseBarcodeText(ctx, line, ref, im.lenient)
+				if err != nil {
+					return nil, fmt.Errorf("error parsing barcode: %w", err)
+				}
+				return p, nil
+			})
+		}
+	}
+}
+
+// readImportFile reads name from fsys, up to maxImportFile bytes.
+func readImportFile(fsys fs.FS, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxImportFile+1))
+	switch {
+	case err != nil:
+		return nil, err
+	case len(data) > maxImportFile:
+		return nil, fmt.Errorf("file too large: over %d bytes", maxImportFile)
+	case len(data) == 0:
+		return nil, errors.New("empty file")
+	}
+	return data, nil
+}
+
+// storeItem stores the pass parse returns for one item, unless the item's
+// content was imported before, and adds the outcome to the report.
+func (im *importer) storeItem(ctx context.Context, it ImportItem, content []byte, parse func() (*bcbp.UnifiedBoardingPass, error)) {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	if !im.force {
+		id, err := im.store.ImportedPass(hash)
+		switch {
+		case err == nil:
+			it.Status, it.PassID, it.Reason = ImportSkipped, id, "already imported"
+			im.add(it)
+			return
+		case !errors.Is(err, storage.ErrNotFound):
+			it.Status, it.Reason = ImportFailed, err.Error()
+			im.add(it)
+			return
+		}
+	}
+
+	p, err := parse()
+	if err != nil {
+		it.Status, it.Reason = ImportFailed, err.Error()
+		im.add(it)
+		return
+	}
+	bcbp.CheckSemantics(p)
+	resolveLocalTimes(p)
+	if im.redact {
+		RedactPass(p)
+	}
+	it.PassID = storage.ID(p)
+
+	if im.force {
+		_, err = im.store.Get(it.PassID)
+		it.Status = ImportImported
+		if err == nil {
+			it.Status = ImportUpdated
+		}
+		_, _, err = im.store.Upsert(p)
+	} else {
+		var inserted bool
+		_, inserted, err = im.store.InsertIfAbsent(p)
+		it.Status = ImportImported
+		if !inserted {
+			it.Status, it.Reason = ImportSkipped, "duplicate of a stored pass"
+		}
+	}
+	if err == nil {
+		err = im.store.MarkImported(hash, it.PassID)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error importing pass", "file", it.File, "err", err)
+		it.Status, it.Reason = ImportFailed, "error storing pass: "+err.Error()
+	}
+	im.add(it)
+}
+
+// handleImport imports the zip archive uploaded as the multipart "file"
+// part, with ?force=true replacing stored passes, and answers with the
+// ImportReport once every file is done.
+func handleImport(w http.ResponseWriter, r *http.Request) {
+	if passStore == nil {
+		httpError(w, "Persistence is disabled (set SQLITE_PATH or DATABASE_URL)", http.StatusNotImplemented)
+		return
+	}
+	file, err := readFilePart(w, r, "file", maxImportBody)
+	if err != nil {
+		switch {
+		case tooLarge(err):
+			writeError(w, http.StatusRequestEntityTooLarge, ErrorDetail{Reason: reasonTooLarge, Message: "Upload too large"})
+		case errors.Is(err, errNoFilePart):
+			writeError(w, http.StatusBadRequest, ErrorDetail{Reason: reasonInvalidForm, Message: "Error retrieving file"})
+		default:
+			writeError(w, http.StatusBadRequest, ErrorDetail{Reason: reasonInvalidForm, Message: "Invalid multipart form"})
+		}
+		return
+	}
+	defer file.Close()
+	if kind, ok := sniffUpload(file.head); !ok || kind != kindPkPass {
+		d := ErrorDetail{Reason: reasonUnsupportedType, Message: "Upload a zip archive of passes"}
+		if ok {
+			d.DetectedType = kind.Name
+		}
+		writeError(w, http.StatusUnsupportedMediaType, d)
+		return
+	}
+	zr, err := pkpass.OpenArchive(file.r, file.size, importZipLimits)
+	if err != nil {
+		if errors.Is(err, pkpass.ErrArchiveTooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, ErrorDetail{Reason: reasonTooLarge, Message: "Upload rejected: " + err.Error()})
+		} else {
+			writeError(w, http.StatusUnprocessableEntity, ErrorDetail{Reason: reasonInvalidArchive, Message: "Error reading zip archive: " + err.Error()})
+		}
+		return
+	}
+	if isPassArchive(zr) {
+		writeError(w, http.StatusUnprocessableEntity, ErrorDetail{Reason: reasonInvalidArchive,
+			Message: "This is a single .pkpass; zip the passes to import, or use /parse/pkpass"})
+		return
+	}
+
+	im := &importer{
+		store:   passStore,
+		force:   r.URL.Query().Get("force") == "true",
+		redact:  redactAll,
+		lenient: bcbpLenient,
+		workers: importWorkers,
+	}
+	im.profile, _ = scan.LookupDecodeProfile(serverDecodeProfile())
+	report := im.run(r.Context(), zr, ".")
+	slog.InfoContext(r.Context(), "Import finished", "imported", report.Imported, "updated", report.Updated,
+		"skipped", report.Skipped, "failed", report.Failed)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// isPassArchive reports whether zr is a .pkpass itself rather than a zip
+// of passes.
+func isPassArchive(zr *zip.Reader) bool {
+	return slices.ContainsFunc(zr.File, func(f *zip.File) bool { return path.Clean(f.Name) == "pass.json" })
+}
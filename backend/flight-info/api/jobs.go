@@ -0,0 +1,252 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ----------------------
+// ASYNC JOBS AND SERVER-SENT EVENTS
+// ----------------------
+
+// Jobs live in process memory only: a restart loses every job, running or
+// finished.
+
+// batchJobs is set up by Serve from JOB_TTL and JOB_MAX.
+var batchJobs = newJobStore(defaultConfig.JobTTL, defaultConfig.JobMax)
+
+// sseKeepAlive is how often an idle event stream gets a comment line, so
+// proxies don't time it out.
+const sseKeepAlive = 15 * time.Second
+
+var errTooManyJobs = errors.New("Too many jobs in progress, retry later")
+
+// jobStore holds jobs until ttl after they finish. Expired jobs are dropped
+// whenever a job is created or looked up.
+type jobStore struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	max  int
+	jobs map[string]*job
+}
+
+func newJobStore(ttl time.Duration, max int) *jobStore {
+	return &jobStore{ttl: ttl, max: max, jobs: map[string]*job{}}
+}
+
+// create registers a running job for total images, or fails when max jobs
+// are already held.
+func (s *jobStore) create(total int) (*job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(time.Now())
+	if len(s.jobs) >= s.max {
+		return nil, errTooManyJobs
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	j := &job{
+		id:      hex.EncodeToString(b),
+		total:   total,
+		ttl:     s.ttl,
+		created: time.Now().UTC(),
+		changed: make(chan struct{}),
+	}
+	s.jobs[j.id] = j
+	return j, nil
+}
+
+func (s *jobStore) get(id string) (*job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(time.Now())
+	j, ok := s.jobs[id]
+	return j, ok
+}
+
+// prune must be called with mu held.
+func (s *jobStore) prune(now time.Time) {
+	for id, j := range s.jobs {
+		if j.expired(now) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// job is one async batch. Items are kept in completion order for the event
+// stream; the result has them in request order.
+type job struct {
+	id      string
+	total   int
+	ttl     time.Duration
+	created time.Time
+
+	mu       sync.Mutex
+	items    []BatchItem
+	result   *BatchResult // nil while running
+	finished time.Time
+	changed  chan struct{} // closed, and replaced, on every update
+}
+
+func (j *job) add(item BatchItem) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.items = append(j.items, item)
+	j.notify()
+}
+
+func (j *job) finish(result *BatchResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.result = result
+	j.finished = time.Now().UTC()
+	j.notify()
+}
+
+// notify must be called with mu held.
+func (j *job) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+func (j *job) expired(now time.Time) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.result != nil && now.Sub(j.finished) > j.ttl
+}
+
+// since returns the items completed after the first n, the result once the
+// job is done, and a channel closed on the next update.
+func (j *job) since(n int) (items []BatchItem, result *BatchResult, changed <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if n < len(j.items) {
+		items = append(items, j.items[n:]...)
+	}
+	return items, j.result, j.changed
+}
+
+// Job is the body of GET /jobs/{id} and of the 202 that starts one.
+type Job struct {
+	ID         string       `json:"id"`
+	Status     string       `json:"status"` // "running" or "done"
+	Total      int          `json:"total"`
+	Completed  int          `json:"completed"`
+	CreatedAt  time.Time    `json:"created_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	ExpiresAt  *time.Time   `json:"expires_at,omitempty"`
+	EventsURL  string       `json:"events_url"`
+	Result     *BatchResult `json:"result,omitempty"` // once done
+}
+
+func (j *job) view() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	v := Job{
+		ID:        j.id,
+		Status:    "running",
+		Total:     j.total,
+		Completed: len(j.items),
+		CreatedAt: j.created,
+		EventsURL: "/jobs/" + j.id + "/events",
+		Result:    j.result,
+	}
+	if j.result != nil {
+		finished, expires := j.finished, j.finished.Add(j.ttl)
+		v.Status, v.FinishedAt, v.ExpiresAt = "done", &finished, &expires
+	}
+	return v
+}
+
+// lookupJob writes a 404 for unknown or expired IDs.
+func lookupJob(w http.ResponseWriter, r *http.Request) (*job, bool) {
+	j, ok := batchJobs.get(r.PathValue("id"))
+	if !ok {
+		httpError(w, "Job not found (jobs expire and don't survive restarts)", http.StatusNotFound)
+	}
+	return j, ok
+}
+
+func handleJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := lookupJob(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(j.view())
+}
+
+// JobProgress is the data of an "item" event.
+type JobProgress struct {
+	Completed int       `json:"completed"`
+	Total     int       `json:"total"`
+	Item      BatchItem `json:"item"`
+}
+
+// handleJobEvents streams an "item" event per finished image, in completion
+// order, then a "done" event with the BatchResult, and closes. Events that
+// already happened are replayed first, so subscribing late or reconnecting
+// loses nothing; item events carry their sequence number as the event ID,
+// and a reconnect's Last-Event-ID skips the ones already seen.
+func handleJobEvents(w http.ResponseWriter, r *http.Request) {
+	j, ok := lookupJob(w, r)
+	if !ok {
+		return
+	}
+	sent, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+	sent = max(sent, 0)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx
+	w.WriteHeader(http.StatusOK)
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		items, result, changed := j.since(sent)
+		for _, item := range items {
+			sent++
+			if err := writeEvent(w, "item", strconv.Itoa(sent), JobProgress{Completed: sent, Total: j.total, Item: item}); err != nil {
+				return
+			}
+		}
+		if result != nil {
+			writeEvent(w, "done", "", result)
+			rc.Flush()
+			return
+		}
+		if rc.Flush() != nil {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes one server-sent event with a JSON data line.
+func writeEvent(w http.ResponseWriter, event, id string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	return err
+}
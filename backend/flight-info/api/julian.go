@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// ----------------------
+// HANDLERS: JULIAN DATES
+// ----------------------
+
+// A BCBP date is a day of the year without the year, resolved to the
+// nearest match around today. Passes scanned long after the flight (archive
+// imports) need another anchor: ?reference_date on the barcode endpoints,
+// and GET /util/julian for clients that resolve dates themselves.
+
+// timeNow is what "today" is for date resolution, the date window and the
+// keys of parse responses; tests move it across midnight.
+var timeNow = time.Now
+
+// referenceDate reads ?reference_date, today when absent. status is 0
+// unless the parameter is malformed.
+func referenceDate(q url.Values) (ref time.Time, status int, d ErrorDetail) {
+	v := q.Get("reference_date")
+	if v == "" {
+		return timeNow(), 0, ErrorDetail{}
+	}
+	ref, err := parseDateParam(v)
+	if err != nil {
+		return time.Time{}, http.StatusBadRequest, invalidParam("reference_date", "reference_date must be a YYYY-MM-DD date")
+	}
+	return ref, 0, ErrorDetail{}
+}
+
+// parseDateParam reads an RFC 3339 full-date, or a timestamp of which only
+// the date counts.
+func parseDateParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), err
+}
+
+// invalidParam is the 400 detail for a malformed query parameter.
+func invalidParam(name, message string) ErrorDetail {
+	return ErrorDetail{Reason: reasonInvalidParam, Parameter: name, Message: message}
+}
+
+// JulianResponse is the body of GET /util/julian.
+type JulianResponse struct {
+	Day       int    `json:"day"`
+	Reference string `json:"reference"` // YYYY-MM-DD
+	Date      string `json:"date"`      // YYYY-MM-DD
+}
+
+func handleJulian(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	day, err := strconv.Atoi(q.Get("day"))
+	if err != nil || day < 1 || day > 366 {
+		writeError(w, http.StatusBadRequest, invalidParam("day", "day must be a day of the year, 1 to 366"))
+		return
+	}
+	ref := time.Now()
+	if v := q.Get("reference"); v != "" {
+		if ref, err = parseDateParam(v); err != nil {
+			writeError(w, http.StatusBadRequest, invalidParam("reference", "reference must be a YYYY-MM-DD date"))
+			return
+		}
+	}
+	date := bcbp.ResolveJulianDate(strconv.Itoa(day), ref)
+	if date == "" {
+		writeError(w, http.StatusBadRequest, invalidParam("day", "day 366 has no leap year within a year of the reference"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(JulianResponse{Day: day, Reference: ref.Format(time.DateOnly), Date: date})
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// ----------------------
+// CONCURRENCY LIMITER FOR HEAVY WORK
+// ----------------------
+
+// heavyWork bounds CPU-bound work (image decoding, pkpass unpacking and
+// signing) so a burst of uploads can't starve cheap requests like
+// /parse/barcode, which never touch the limiter.
+var heavyWork *heavyLimiter
+
+var (
+	errHeavyQueueFull = errors.New("too many heavy requests queued")
+	errHeavyTimeout   = errors.New("timed out waiting for a heavy-work slot")
+)
+
+type heavyLimiter struct {
+	sem      *semaphore.Weighted
+	capacity int64
+	maxQueue int64
+	maxWait  time.Duration
+	queued   atomic.Int64
+}
+
+func init() {
+	describeMetric("heavy_inflight", "gauge", "Weight of heavy operations currently running.")
+	describeMetric("heavy_queued", "gauge", "Heavy operations waiting for a slot.")
+	describeMetric("heavy_rejected_total", "counter", "Heavy operations rejected with 429, by reason.")
+}
+
+func newHeavyLimiter(capacity, maxQueue int, maxWait time.Duration) *heavyLimiter {
+	metric("heavy_inflight")
+	metric("heavy_queued")
+	metric("heavy_rejected_total", "reason", "queue_full")
+	metric("heavy_rejected_total", "reason", "timeout")
+	return &heavyLimiter{
+		sem:      semaphore.NewWeighted(int64(capacity)),
+		capacity: int64(capacity),
+		maxQueue: int64(maxQueue),
+		maxWait:  maxWait,
+	}
+}
+
+// acquire waits up to maxWait for weight units. Weights above capacity are
+// clamped so an oversized job still runs, alone.
+func (l *heavyLimiter) acquire(ctx context.Context, weight int64) (release func(), err error) {
+	if weight < 1 {
+		weight = 1
+	}
+	if weight > l.capacity {
+		weight = l.capacity
+	}
+
+	if !l.sem.TryAcquire(weight) {
+		if l.queued.Add(1) > l.maxQueue {
+			l.queued.Add(-1)
+			metric("heavy_rejected_total", "reason", "queue_full").Inc()
+			return nil, errHeavyQueueFull
+		}
+		metric("heavy_queued").Inc()
+		ctx, cancel := context.WithTimeout(ctx, l.maxWait)
+		err := l.sem.Acquire(ctx, weight)
+		cancel()
+		l.queued.Add(-1)
+		metric("heavy_queued").Dec()
+		if err != nil {
+			metric("heavy_rejected_total", "reason", "timeout").Inc()
+			return nil, errHeavyTimeout
+		}
+	}
+
+	metric("heavy_inflight").Add(weight)
+	var once atomic.Bool
+	return func() {
+		if once.CompareAndSwap(false, true) {
+			metric("heavy_inflight").Add(-weight)
+			l.sem.Release(weight)
+		}
+	}, nil
+}
+
+// acquireHeavy reserves a slot for the request or writes a 429 with
+// Retry-After. The returned release is safe to call more than once.
+func acquireHeavy(w http.ResponseWriter, r *http.Request, weight int64) (release func(), ok bool) {
+	if heavyWork == nil {
+		return func() {}, true
+	}
+	release, err := heavyWork.acquire(r.Context(), weight)
+	if err != nil {
+		retry := int(heavyWork.maxWait / time.Second)
+		if retry < 1 {
+			retry = 1
+		}
+		httpRetryError(w, fmt.Sprintf("Server busy: %v, retry later", err), http.StatusTooManyRequests, retry)
+		return nil, false
+	}
+	return release, true
+}
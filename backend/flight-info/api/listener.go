@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+)
+
+// ----------------------
+// LISTENER: TCP, UNIX SOCKET, SYSTEMD SOCKET ACTIVATION
+// ----------------------
+
+// defaultHTTPAddr is the TCP address used when neither socket activation nor
+// LISTEN_SOCKET is configured.
+const defaultHTTPAddr = ":8080"
+
+// sdListenFDsStart is the first file descriptor systemd passes (SD_LISTEN_FDS_START).
+const sdListenFDsStart = 3
+
+// httpListener picks the HTTP listener, in order of precedence: a socket
+// inherited from systemd (LISTEN_FDS), a Unix domain socket at LISTEN_SOCKET,
+// or TCP on defaultHTTPAddr. desc names it for the startup log, and cleanup
+// removes the socket file, if this process created one.
+func httpListener(cfg Config) (lis net.Listener, desc string, cleanup func(), err error) {
+	cleanup = func() {}
+
+	lis, err = systemdListener()
+	if err != nil {
+		return nil, "", cleanup, err
+	}
+	if lis != nil {
+		return lis, "systemd:" + lis.Addr().String(), cleanup, nil
+	}
+
+	if path := cfg.ListenSocket; path != "" {
+		mode, err := strconv.ParseUint(cfg.ListenSocketMode, 8, 32)
+		if err != nil {
+			return nil, "", cleanup, fmt.Errorf("LISTEN_SOCKET_MODE: %w", err)
+		}
+		lis, err = listenUnix(path, fs.FileMode(mode))
+		if err != nil {
+			return nil, "", cleanup, err
+		}
+		cleanup = func() {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				slog.Warn("Error removing socket", "path", path, "err", err)
+			}
+		}
+		return lis, "unix:" + path, cleanup, nil
+	}
+
+	lis, err = net.Listen("tcp", defaultHTTPAddr)
+	if err != nil {
+		return nil, "", cleanup, err
+	}
+	return lis, "tcp:" + defaultHTTPAddr, cleanup, nil
+}
+
+// systemdListener returns the first socket passed by systemd socket
+// activation, or nil when LISTEN_PID doesn't name this process. The LISTEN_
+// variables are cleared so child processes don't claim the socket too.
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("LISTEN_FDS: want at least one socket, got %q", os.Getenv("LISTEN_FDS"))
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	// Only the first socket is served; extra ones would need a second server.
+	f := os.NewFile(sdListenFDsStart, "LISTEN_FD_3")
+	defer f.Close()
+	lis, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("systemd socket: %w", err)
+	}
+	return lis, nil
+}
+
+// listenUnix listens on a Unix domain socket at path with the given
+// permissions. A socket file left behind by a crashed server is removed
+// first; one that still accepts connections, or a path that isn't a socket,
+// is an error rather than something to delete.
+func listenUnix(path string, mode fs.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("LISTEN_SOCKET: %s exists and is not a socket", path)
+		}
+		if c, err := net.Dial("unix", path); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("LISTEN_SOCKET: %s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("LISTEN_SOCKET: removing stale socket: %w", err)
+		}
+	}
+
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// The cleanup from httpListener removes the file after a graceful
+	// shutdown; Close must not race it.
+	lis.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(path, mode); err != nil {
+		lis.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("LISTEN_SOCKET_MODE: %w", err)
+	}
+	return lis, nil
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// ----------------------
+// LOGGING: SLOG SETUP, REQUEST CONTEXT, ACCESS LOG
+// ----------------------
+
+// logLevel is LOG_LEVEL, which PATCH /admin/config can change.
+var logLevel slog.LevelVar
+
+// setupLogging installs the default slog logger from LOG_LEVEL (debug, info,
+// warn, error; default info) and LOG_FORMAT (text or json; default text).
+func setupLogging(cfg Config) error {
+	if err := logLevel.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		return fmt.Errorf("LOG_LEVEL: %w", err)
+	}
+	opts := &slog.HandlerOptions{Level: &logLevel}
+
+	var h slog.Handler
+	switch format := strings.ToLower(cfg.LogFormat); format {
+	case "text":
+		h = slog.NewTextHandler(os.Stdout, opts)
+	case "json":
+		h = slog.NewJSONHandler(os.Stdout, opts)
+	default:
+		return fmt.Errorf("LOG_FORMAT: unknown format %q (want text or json)", format)
+	}
+	slog.SetDefault(slog.New(contextHandler{h}))
+	return nil
+}
+
+// fatal logs at error level and exits, for configuration errors at startup.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+type logAttrsKey struct{}
+
+// withLogAttrs returns a context whose log records carry attrs in addition to
+// any the context already had.
+func withLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	return context.WithValue(ctx, logAttrsKey{}, append(prev[:len(prev):len(prev)], attrs...))
+}
+
+// contextHandler adds the attributes from withLogAttrs to every record
+// logged with a context, e.g. slog.ErrorContext(r.Context(), ...).
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(logAttrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// accessRecorder captures the status and size of a response for the access
+// log.
+type accessRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (a *accessRecorder) WriteHeader(code int) {
+	if a.status == 0 {
+		a.status = code
+	}
+	a.ResponseWriter.WriteHeader(code)
+}
+
+func (a *accessRecorder) Write(b []byte) (int, error) {
+	if a.status == 0 {
+		a.status = http.StatusOK
+	}
+	n, err := a.ResponseWriter.Write(b)
+	a.bytes += n
+	return n, err
+}
+
+func (a *accessRecorder) Unwrap() http.ResponseWriter { return a.ResponseWriter }
+
+// loggingMiddleware tags the request context with the request ID, method and
+// endpoint, so everything the handler logs carries them, and writes one
+// access log line per request. It runs inside requestIDMiddleware and
+// outside panic recovery, so recovered panics are logged as 500s.
+func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := withLogAttrs(r.Context(),
+			slog.String("request_id", r.Header.Get("X-Request-ID")),
+			slog.String("method", r.Method),
+			slog.String("endpoint", r.URL.Path))
+		rec := &accessRecorder{ResponseWriter: w}
+		next(rec, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if rec.status >= 500 {
+			level = slog.LevelError
+		}
+		if !slog.Default().Enabled(ctx, level) {
+			return
+		}
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		slog.LogAttrs(ctx, level, "request",
+			slog.Int("status", rec.status),
+			slog.Int("bytes", rec.bytes),
+			durationAttr(start))
+	}
+}
+
+// durationAttr is the time since start in milliseconds, which reads the
+// same in text and JSON (slog.Duration is nanoseconds in JSON).
+func durationAttr(start time.Time) slog.Attr {
+	return slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000)
+}
+
+// logUnary and logStream are loggingMiddleware for gRPC.
+func logUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	ctx = withLogAttrs(ctx, slog.String("endpoint", info.FullMethod))
+	resp, err := handler(ctx, req)
+	logRPC(ctx, start, err)
+	return resp, err
+}
+
+func logStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx := withLogAttrs(ss.Context(), slog.String("endpoint", info.FullMethod))
+	err := handler(srv, &loggedStream{ServerStream: ss, ctx: ctx})
+	logRPC(ctx, start, err)
+	return err
+}
+
+type loggedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *loggedStream) Context() context.Context { return s.ctx }
+
+func logRPC(ctx context.Context, start time.Time, err error) {
+	if !slog.Default().Enabled(ctx, slog.LevelInfo) {
+		return
+	}
+	slog.LogAttrs(ctx, slog.LevelInfo, "rpc",
+		slog.String("code", status.Code(err).String()),
+		durationAttr(start))
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// ----------------------
+// HTTP: CACHING THE DATASET LOOKUPS
+// ----------------------
+
+// /airlines and /airports answer from datasets embedded at build time, so
+// a response only changes with the binary. They carry a long Cache-Control
+// max-age, an ETag hashed from the dataset (and, for airlines, the logo
+// URL template) and a Last-Modified of when the datasets were built, and
+// http.ServeContent answers If-None-Match and If-Modified-Since with 304s.
+
+// lookupMaxAge is the Cache-Control max-age of lookup responses
+// (LOOKUP_CACHE_MAX_AGE).
+var lookupMaxAge = defaultConfig.LookupCacheMaxAge
+
+// datasetModified is when the embedded datasets were built: the time of
+// the commit the binary was built from, or the process start when the
+// build had no clean checkout to stamp.
+var datasetModified = func() time.Time {
+	start := time.Now().UTC().Truncate(time.Second)
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return start
+	}
+	var vcsTime time.Time
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.time":
+			vcsTime, _ = time.Parse(time.RFC3339, s.Value)
+		case "vcs.modified":
+			if s.Value == "true" {
+				return start
+			}
+		}
+	}
+	if vcsTime.IsZero() {
+		return start
+	}
+	return vcsTime.UTC()
+}()
+
+// datasetETag is a strong ETag over parts.
+func datasetETag(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		fmt.Fprintf(h, "%d:", len(p))
+		h.Write(p)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:8]) + `"`
+}
+
+// serveLookup writes v as JSON with the caching headers, or a 304 when the
+// request's validators match etag.
+func serveLookup(w http.ResponseWriter, r *http.Request, etag string, v any) {
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(v)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(lookupMaxAge.Seconds())))
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "", datasetModified, bytes.NewReader(body.Bytes()))
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"golang.org/x/text/language"
+)
+
+// ----------------------
+// ERRORS: LOCALIZED USER MESSAGES
+// ----------------------
+
+// ErrorDetail.Message is for developers and logs and stays in English;
+// ErrorDetail.UserMessage is the catalog text below, meant to be shown to
+// travelers as is. Codes and reasons never change with the language.
+
+// messageLangs are the catalog languages, English first as the fallback.
+var messageLangs = []language.Tag{language.English, language.Portuguese}
+
+var langMatcher = language.NewMatcher(messageLangs)
+
+// userMessages maps a language to texts keyed by ErrorDetail.Reason or, for
+// errors without one, ErrorDetail.Code. "" is the fallback for anything
+// else.
+var userMessages = map[string]map[string]string{
+	"en": {
+		reasonInvalidJSON:        "The request couldn't be read. Please try again.",
+		reasonInvalidBase64:      "This image couldn't be opened. Try another photo or screenshot.",
+		reasonInvalidForm:        "No file was received. Please choose the boarding pass file again.",
+		reasonInvalidParam:       "The request couldn't be read. Please try again.",
+		reasonTooLarge:           "This file is too large. Try a smaller image or file.",
+		reasonUnsupportedType:    "This file type isn't supported. Use a photo, a screenshot or an Apple Wallet pass.",
+		reasonJSONRequired:       "This request must be sent as JSON.",
+		reasonInvalidImage:       "This image couldn't be opened. Try another photo or screenshot.",
+		reasonNoBarcode:          "We couldn't find a barcode in this image. Make sure it's sharp, well lit and shows the whole code.",
+		reasonImageTooSmall:      "This image is too small to read the barcode. Send a full-size photo or screenshot.",
+		reasonImageBlurry:        "This image is too blurry to read the barcode. Hold the camera steady and take the photo again.",
+		reasonWalletScreenshot:   "This looks like a screenshot of an Apple Wallet pass. Share the pass itself instead: in Wallet, open the pass, tap the ••• button and choose Share Pass.",
+		reasonNotBoardingPass:    "This barcode isn't a boarding pass.",
+		reasonInvalidEncoding:    "This barcode couldn't be read correctly. Please scan it again.",
+		reasonInvalidPkPass:      "This file isn't a valid Apple Wallet boarding pass.",
+		reasonInvalidArchive:     "This zip file couldn't be opened. Check that it isn't damaged and try again.",
+		reasonFeatureUnavailable: "Reading the printed text of a pass isn't available right now. Try a photo where the whole barcode is sharp.",
+		reasonNoWebService:       "This pass can't be updated automatically. Add the latest version from your airline's app.",
+		reasonIssuerUnreachable:  "We couldn't reach your airline to update this pass. Please try again later.",
+		reasonIssuerRejected:     "Your airline no longer updates this pass. Add the latest version from your airline's app.",
+		reasonIssuerInvalid:      "Your airline sent an update we couldn't read. Please try again later.",
+		reasonUnknownLink:        "This link can't be opened here. Share the pass itself instead: in Wallet, open the pass, tap the ••• button and choose Share Pass.",
+		reasonExportRequired:     "This link doesn't lead straight to the pass. Export the pass file from Wallet or your airline's app and upload it instead.",
+		reasonLinkUnreachable:    "We couldn't open this link. Please try again later, or upload the pass file instead.",
+		"not_found":              "We couldn't find what you were looking for.",
+		"gone":                   "This file is no longer available.",
+		"too_many_requests":      "Too many requests. Please wait a moment and try again.",
+		"service_unavailable":    "The service is busy. Please try again in a moment.",
+		"internal_server_error":  "Something went wrong on our side. Please try again.",
+		"":                       "Something went wrong. Please try again.",
+	},
+	"pt": {
+		reasonInvalidJSON:        "Não foi possível ler o pedido. Tente novamente.",
+		reasonInvalidBase64:      "Não foi possível abrir esta imagem. Experimente outra fotografia ou captura de ecrã.",
+		reasonInvalidForm:        "Nenhum ficheiro foi recebido. Escolha novamente o ficheiro do cartão de embarque.",
+		reasonInvalidParam:       "Não foi possível ler o pedido. Tente novamente.",
+		reasonTooLarge:           "Este ficheiro é demasiado grande. Experimente uma imagem ou ficheiro mais pequeno.",
+		reasonUnsupportedType:    "Este tipo de ficheiro não é suportado. Use uma fotografia, uma captura de ecrã ou um passe da Apple Wallet.",
+		reasonJSONRequired:       "Este pedido tem de ser enviado em JSON.",
+		reasonInvalidImage:       "Não foi possível abrir esta imagem. Experimente outra fotografia ou captura de ecrã.",
+		reasonNoBarcode:          "Não encontrámos um código de barras nesta imagem. Confirme que está nítida, bem iluminada e mostra o código inteiro.",
+		reasonImageTooSmall:      "Esta imagem é demasiado pequena para ler o código de barras. Envie uma fotografia ou captura de ecrã em tamanho real.",
+		reasonImageBlurry:        "Esta imagem está demasiado desfocada para ler o código de barras. Segure a câmara com firmeza e tire a fotografia novamente.",
+		reasonWalletScreenshot:   "Parece uma captura de ecrã de um cartão da Apple Wallet. Partilhe antes o próprio cartão: na Wallet, abra o cartão, toque no botão ••• e escolha a opção de partilhar.",
+		reasonNotBoardingPass:    "Este código de barras não é um cartão de embarque.",
+		reasonInvalidEncoding:    "Não foi possível ler corretamente este código de barras. Digitalize-o novamente.",
+		reasonInvalidPkPass:      "Este ficheiro não é um cartão de embarque válido da Apple Wallet.",
+		reasonInvalidArchive:     "Não foi possível abrir este ficheiro zip. Confirme que não está danificado e tente novamente.",
+		reasonFeatureUnavailable: "De momento não é possível ler o texto impresso do cartão. Experimente uma fotografia em que o código de barras inteiro esteja nítido.",
+		reasonNoWebService:       "Este cartão não pode ser atualizado automaticamente. Adicione a versão mais recente a partir da aplicação da sua companhia aérea.",
+		reasonIssuerUnreachable:  "Não foi possível contactar a sua companhia aérea para atualizar este cartão. Tente novamente mais tarde.",
+		reasonIssuerRejected:     "A sua companhia aérea já não atualiza este cartão. Adicione a versão mais recente a partir da aplicação da companhia.",
+		reasonIssuerInvalid:      "A sua companhia aérea enviou uma atualização que não conseguimos ler. Tente novamente mais tarde.",
+		reasonUnknownLink:        "Não é possível abrir esta ligação aqui. Partilhe antes o próprio cartão: na Wallet, abra o cartão, toque no botão ••• e escolha a opção de partilhar.",
+		reasonExportRequired:     "Esta ligação não leva diretamente ao cartão. Exporte o ficheiro do cartão a partir da Wallet ou da aplicação da sua companhia aérea e carregue-o.",
+		reasonLinkUnreachable:    "Não foi possível abrir esta ligação. Tente novamente mais tarde ou carregue o ficheiro do cartão.",
+		"not_found":              "Não encontrámos o que procurava.",
+		"gone":                   "Este ficheiro já não está disponível.",
+		"too_many_requests":      "Demasiados pedidos. Aguarde um momento e tente novamente.",
+		"service_unavailable":    "O serviço está ocupado. Tente novamente dentro de momentos.",
+		"internal_server_error":  "Ocorreu um erro do nosso lado. Tente novamente.",
+		"":                       "Ocorreu um erro. Tente novamente.",
+	},
+}
+
+// negotiateLang picks the catalog language for r (see matchLang).
+func negotiateLang(r *http.Request) string {
+	return matchLang(r, messageLangs, langMatcher)
+}
+
+// matchLang picks one of langs, matched by m, for r: the lang query
+// parameter if it names a supported language, otherwise the best match for
+// Accept-Language by quality value, otherwise the first of langs. It
+// returns the base language, such as "pt" for pt-BR.
+func matchLang(r *http.Request, langs []language.Tag, m language.Matcher) string {
+	var tags []language.Tag
+	if t, err := language.Parse(r.URL.Query().Get("lang")); err == nil {
+		tags = append(tags, t)
+	}
+	if accept, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language")); err == nil {
+		tags = append(tags, accept...)
+	}
+	_, i, conf := m.Match(tags...)
+	if conf == language.No {
+		i = 0
+	}
+	base, _ := langs[i].Base()
+	return base.String()
+}
+
+// userMessage is the catalog text for d in lang.
+func userMessage(lang string, d ErrorDetail) string {
+	catalog := userMessages[lang]
+	if catalog == nil {
+		catalog = userMessages["en"]
+	}
+	if d.Reason != "" {
+		if m, ok := catalog[d.Reason]; ok {
+			return m
+		}
+	}
+	if m, ok := catalog[d.Code]; ok {
+		return m
+	}
+	return catalog[""]
+}
+
+// langWriter carries what langMiddleware negotiated down to writeError,
+// which, like httpError, only gets the ResponseWriter: the language, and
+// whether errors go out as problem details (see wantProblem).
+type langWriter struct {
+	http.ResponseWriter
+	lang string
+	// problem is set when the client prefers problem details; instance is
+	// then the request path, their instance.
+	problem  bool
+	instance string
+}
+
+func (l *langWriter) Unwrap() http.ResponseWriter { return l.ResponseWriter }
+
+type displayLangKey struct{}
+
+// langMiddleware negotiates the error message language and format once
+// per request, and the language of display names (see displayLang). It
+// goes right inside requestIDMiddleware so every later wrapper, including
+// panic recovery, can find them.
+func langMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Language")
+		ctx := context.WithValue(r.Context(), displayLangKey{}, matchLang(r, displayLangs, displayLangMatcher))
+		lw := &langWriter{ResponseWriter: w, lang: negotiateLang(r)}
+		if wantProblem(r) {
+			lw.problem, lw.instance = true, r.URL.Path
+		}
+		next(lw, r.WithContext(ctx))
+	}
+}
+
+// displayLang is the language names shown to travelers are given in,
+// such as the cities enrichment adds: English outside an HTTP request.
+func displayLang(ctx context.Context) string {
+	if l, ok := ctx.Value(displayLangKey{}).(string); ok {
+		return l
+	}
+	return "en"
+}
+
+// responseLang finds the language langMiddleware chose for w, English when
+// the route has none.
+func responseLang(w http.ResponseWriter) string {
+	if l := findLangWriter(w); l != nil {
+		return l.lang
+	}
+	return "en"
+}
+
+// findLangWriter finds the langWriter under w; nil when the route has
+// none.
+func findLangWriter(w http.ResponseWriter) *langWriter {
+	for {
+		if l, ok := w.(*langWriter); ok {
+			return l
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = u.Unwrap()
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ----------------------
+// METRICS (PROMETHEUS TEXT FORMAT)
+// ----------------------
+
+// A deliberately tiny registry: the server only needs integer counters and
+// gauges, and pulling in client_golang for that is not worth it.
+
+type metricSeries struct {
+	name   string
+	labels string // rendered, e.g. `result="success"`
+	value  atomic.Int64
+}
+
+type metricInfo struct {
+	kind string // "counter" or "gauge"
+	help string
+}
+
+var metrics = struct {
+	mu     sync.Mutex
+	info   map[string]metricInfo
+	series map[string]*metricSeries
+}{
+	info:   map[string]metricInfo{},
+	series: map[string]*metricSeries{},
+}
+
+// describeMetric registers the HELP/TYPE lines for a metric family.
+func describeMetric(name, kind, help string) {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	metrics.info[name] = metricInfo{kind: kind, help: help}
+}
+
+// metric returns the series for name and the given label pairs
+// ("key", "value", ...), creating it on first use.
+func metric(name string, labelPairs ...string) *metricSeries {
+	var labels []string
+	for i := 0; i+1 < len(labelPairs); i += 2 {
+		labels = append(labels, fmt.Sprintf("%s=%q", labelPairs[i], labelPairs[i+1]))
+	}
+	rendered := strings.Join(labels, ",")
+	key := name + "{" + rendered + "}"
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	s, ok := metrics.series[key]
+	if !ok {
+		s = &metricSeries{name: name, labels: rendered}
+		metrics.series[key] = s
+	}
+	return s
+}
+
+func (s *metricSeries) Inc()         { s.value.Add(1) }
+func (s *metricSeries) Dec()         { s.value.Add(-1) }
+func (s *metricSeries) Add(n int64)  { s.value.Add(n) }
+func (s *metricSeries) Set(n int64)  { s.value.Store(n) }
+func (s *metricSeries) Value() int64 { return s.value.Load() }
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics.mu.Lock()
+	byName := map[string][]*metricSeries{}
+	for _, s := range metrics.series {
+		byName[s.name] = append(byName[s.name], s)
+	}
+	info := make(map[string]metricInfo, len(metrics.info))
+	for k, v := range metrics.info {
+		info[k] = v
+	}
+	metrics.mu.Unlock()
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, name := range names {
+		if i, ok := info[name]; ok {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, i.help, name, i.kind)
+		}
+		series := byName[name]
+		sort.Slice(series, func(a, b int) bool { return series[a].labels < series[b].labels })
+		for _, s := range series {
+			if s.labels == "" {
+				fmt.Fprintf(w, "%s %d\n", name, s.Value())
+			} else {
+				fmt.Fprintf(w, "%s{%s} %d\n", name, s.labels, s.Value())
+			}
+		}
+	}
+}
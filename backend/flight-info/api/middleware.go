@@ -0,0 +1,114 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ----------------------
+// MIDDLEWARE
+// ----------------------
+
+// corsHandler wraps the whole mux, so every response carries the CORS
+// headers: unknown paths, 405s, panics and routes added later included.
+// Without them a browser only sees an opaque network error.
+func corsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setCORSHeaders(w)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsMiddleware narrows Access-Control-Allow-Methods to what the route
+// accepts and answers its preflights. Preflights for paths without a
+// route fall through to the 404.
+func corsMiddleware(methods []string, next http.HandlerFunc) http.HandlerFunc {
+	allow := allowHeader(methods)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Methods", allow)
+
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Allow", allow)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func setCORSHeaders(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Modified-Since, If-None-Match, X-Client-ID, X-Request-ID")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Language, ETag, X-Request-ID, X-Cache, X-Reencoded, X-Schema-Version")
+}
+
+// methodMiddleware rejects methods the route doesn't serve with a 405 in
+// the error envelope and an Allow header, before the handler runs, so
+// handlers only see their own methods. HEAD goes to the GET handler as a
+// GET; net/http drops the body. OPTIONS is left to corsMiddleware.
+func methodMiddleware(methods []string, next http.HandlerFunc) http.HandlerFunc {
+	allow := allowHeader(methods)
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case slices.Contains(methods, r.Method), r.Method == http.MethodOptions:
+		case r.Method == http.MethodHead && slices.Contains(methods, http.MethodGet):
+			r = r.WithContext(r.Context())
+			r.Method = http.MethodGet
+		default:
+			w.Header().Set("Allow", allow)
+			httpError(w, fmt.Sprintf("Method %s not allowed (use %s)", r.Method, strings.Join(methods, " or ")), http.StatusMethodNotAllowed)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// allowHeader is the Allow header of a route serving methods: those, HEAD
+// with GET, and OPTIONS.
+func allowHeader(methods []string) string {
+	allow := slices.Clone(methods)
+	if slices.Contains(methods, http.MethodGet) && !slices.Contains(methods, http.MethodHead) {
+		allow = append(allow, http.MethodHead)
+	}
+	return strings.Join(append(allow, http.MethodOptions), ", ")
+}
+
+// api wraps every route: request ID and error language first, so logs,
+// panics, errors and spans can report them, then tracing, logging, panic
+// recovery, CORS, the methods the route serves and the light rate limit.
+func api(h http.HandlerFunc, methods ...string) http.HandlerFunc {
+	return requestIDMiddleware(budgetMiddleware(schemaMiddleware(langMiddleware(tracingMiddleware(loggingMiddleware(recoverMiddleware(corsMiddleware(methods, methodMiddleware(methods, rateLimitMiddleware(false, clientMiddleware(h)))))))))))
+}
+
+// apiHeavy is api for the routes behind the heavy-work limiter, with the
+// heavy rate limit instead of the light one.
+func apiHeavy(h http.HandlerFunc, methods ...string) http.HandlerFunc {
+	return requestIDMiddleware(budgetMiddleware(schemaMiddleware(langMiddleware(tracingMiddleware(loggingMiddleware(recoverMiddleware(corsMiddleware(methods, methodMiddleware(methods, rateLimitMiddleware(true, clientMiddleware(h)))))))))))
+}
+
+// adminToken guards the /admin endpoints; they are disabled while it is
+// empty (ADMIN_TOKEN unset).
+var adminToken string
+
+// adminMiddleware requires "Authorization: Bearer <ADMIN_TOKEN>". It goes
+// inside api so CORS preflights and errors behave as on every other route.
+func adminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			httpError(w, "Admin endpoints are disabled (set ADMIN_TOKEN)", http.StatusNotImplemented)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			httpError(w, "Missing or invalid admin token", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
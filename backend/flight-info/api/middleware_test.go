@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Every documented route answers every method it doesn't serve with a 405
+// in the error envelope, with an Allow header listing the ones it does.
+func TestWrongMethod(t *testing.T) {
+	routes := map[string][]string{}
+	var paths []string
+	for _, op := range apiOperations {
+		if routes[op.Path] == nil {
+			paths = append(paths, op.Path)
+		}
+		routes[op.Path] = append(routes[op.Path], op.Method)
+	}
+	methods := []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+	h := Handler()
+	for _, path := range paths {
+		served := routes[path]
+		allow := strings.Split(allowHeader(served), ", ")
+		slices.Sort(allow)
+		target := strings.NewReplacer("{id}", "x", "{code}", "x").Replace(path)
+		for _, m := range methods {
+			if slices.Contains(allow, m) {
+				continue
+			}
+			for _, prefix := range []string{"", "/v1"} {
+				t.Run(m+" "+prefix+path, func(t *testing.T) {
+					w := serve(t, h, m, prefix+target, nil)
+					if w.Code != http.StatusMethodNotAllowed {
+						t.Fatalf("status %d, want 405", w.Code)
+					}
+					got := strings.Split(w.Header().Get("Allow"), ", ")
+					slices.Sort(got)
+					if !slices.Equal(got, allow) {
+						t.Errorf("Allow %q, want %q", got, allow)
+					}
+					if m != http.MethodHead && errorEnvelope(w.Body.Bytes()).Code != "method_not_allowed" {
+						t.Errorf("body %s", w.Body)
+					}
+					if w.Header().Get("Access-Control-Allow-Origin") == "" {
+						t.Error("no CORS headers")
+					}
+				})
+			}
+		}
+	}
+}
+
+// A preflight to a path without a route gets the CORS headers with its
+// 404, so the browser shows the 404 rather than a network error; one to a
+// route still gets its 200.
+func TestPreflightUnknownRoute(t *testing.T) {
+	h := Handler()
+	preflight := []string{"Origin", "https://app.example", "Access-Control-Request-Method", "POST", "Access-Control-Request-Headers", "content-type"}
+	tests := []struct {
+		method, target string
+		status         int
+	}{
+		{http.MethodOptions, "/parse/barcodes", http.StatusNotFound},
+		{http.MethodOptions, "/v1/parse/barcodes", http.StatusNotFound},
+		{http.MethodOptions, "/", http.StatusNotFound},
+		{http.MethodPost, "/parse/barcodes", http.StatusNotFound},
+		{http.MethodOptions, "/parse/barcode", http.StatusOK},
+		{http.MethodOptions, "/v1/parse/barcode", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			w := serve(t, h, tt.method, tt.target, nil, preflight...)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+			for _, name := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Methods", "Access-Control-Allow-Headers"} {
+				if w.Header().Get(name) == "" {
+					t.Errorf("no %s", name)
+				}
+			}
+			if tt.status == http.StatusNotFound && errorEnvelope(w.Body.Bytes()).Code != "not_found" {
+				t.Errorf("body %s", w.Body)
+			}
+		})
+	}
+}
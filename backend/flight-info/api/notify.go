@@ -0,0 +1,242 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// NOTIFICATIONS: EXPO PUSH ON FLIGHT DAY
+// ----------------------
+
+const (
+	defaultExpoPushURL = "https://exp.host/--/api/v2/push/send"
+	defaultNotifyLead  = 3 * time.Hour
+	maxNotifyLead      = 48 * time.Hour
+	// Untimed passes are reminded at this local hour on the flight day.
+	untimedNotifyHour = 8
+)
+
+var expoTokenPattern = regexp.MustCompile(`^Expo(nent)?PushToken\[[A-Za-z0-9_-]+\]$`)
+
+func init() {
+	describeMetric("notifications_sent_total", "counter", "Expo push notifications by result.")
+}
+
+// notificationSendAt works out when to remind the passenger: lead before
+// the departure (or boarding) time in the departure airport's zone, or at
+// untimedNotifyHour on the flight day when no time is known. Airports
+// without a known zone are treated as UTC.
+func notificationSendAt(p *bcbp.UnifiedBoardingPass, lead time.Duration) (time.Time, error) {
+	loc := airportLocation(p.Departure)
+	if loc == nil {
+		loc = time.UTC
+	}
+	day, err := time.ParseInLocation(time.DateOnly, p.DateISO, loc)
+	if err != nil {
+		return time.Time{}, errNoFlightDate
+	}
+
+	clock := p.DepartureTime
+	if clock == "" {
+		clock = p.BoardingTime
+	}
+	if t, err := time.Parse("15:04", clock); err == nil {
+		dep := time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+		return dep.Add(-lead), nil
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), untimedNotifyHour, 0, 0, 0, loc), nil
+}
+
+// NotificationScheduler periodically sends due reminders through Expo.
+type NotificationScheduler struct {
+	store    storage.Store
+	client   *http.Client
+	pushURL  string
+	interval time.Duration
+}
+
+func newNotificationScheduler(store storage.Store, pushURL string, interval time.Duration) *NotificationScheduler {
+	for _, result := range []string{"ok", "error"} {
+		metric("notifications_sent_total", "result", result)
+	}
+	return &NotificationScheduler{
+		store:    store,
+		client:   newUpstreamClient(10 * time.Second),
+		pushURL:  pushURL,
+		interval: interval,
+	}
+}
+
+// Run sends due notifications every interval until ctx is cancelled.
+func (n *NotificationScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+	for {
+		n.tick(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *NotificationScheduler) tick(now time.Time) {
+	due, err := n.store.ClaimDueNotifications(now, 100)
+	if err != nil {
+		slog.Error("Error loading due notifications", "err", err)
+		return
+	}
+	for _, notif := range due {
+		sendErr := n.send(notif)
+		if sendErr != nil {
+			slog.Warn("Error sending notification", "notification", notif.ID, "err", sendErr)
+			metric("notifications_sent_total", "result", "error").Inc()
+		} else {
+			metric("notifications_sent_total", "result", "ok").Inc()
+		}
+		if err := n.store.MarkNotification(notif.ID, sendErr); err != nil {
+			slog.Error("Error recording notification", "notification", notif.ID, "err", err)
+		}
+	}
+}
+
+func (n *NotificationScheduler) send(notif *storage.Notification) error {
+	sp, err := n.store.Get(notif.PassID)
+	if err != nil {
+		return fmt.Errorf("loading pass %s: %w", notif.PassID, err)
+	}
+	title, body := notificationText(sp.Pass)
+
+	payload, _ := json.Marshal([]map[string]interface{}{{
+		"to":    notif.Token,
+		"title": title,
+		"body":  body,
+		"sound": "default",
+		"data":  map[string]string{"pass_id": notif.PassID},
+	}})
+	req, err := http.NewRequest(http.MethodPost, n.pushURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("expo returned %s", resp.Status)
+	}
+
+	var result struct {
+		Data []struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decoding expo response: %w", err)
+	}
+	if len(result.Data) == 1 && result.Data[0].Status != "ok" {
+		return fmt.Errorf("expo: %s", result.Data[0].Message)
+	}
+	return nil
+}
+
+func notificationText(p *bcbp.UnifiedBoardingPass) (title, body string) {
+	title = "Flight " + strings.TrimSpace(p.Carrier) + strings.TrimLeft(p.FlightNumber, "0") + " today"
+
+	parts := []string{p.Departure + "→" + p.Arrival}
+	if p.DepartureTime != "" {
+		parts[0] += " departs " + p.DepartureTime
+	} else if p.BoardingTime != "" {
+		parts[0] += " boards " + p.BoardingTime
+	}
+	if p.Seat != "" {
+		parts = append(parts, "seat "+p.Seat)
+	}
+	if gate := p.RawData["gate"]; gate != "" {
+		parts = append(parts, "gate "+gate)
+	}
+	return title, strings.Join(parts, " · ")
+}
+
+// NotifyRequest is the body of POST /passes/{id}/notify.
+type NotifyRequest struct {
+	Token    string `json:"token"`
+	LeadTime string `json:"lead_time,omitempty"` // Go duration, e.g. "3h" or "90m"
+}
+
+func handlePassNotify(w http.ResponseWriter, r *http.Request) {
+	if passStore == nil {
+		httpError(w, "Persistence is disabled (set SQLITE_PATH or DATABASE_URL)", http.StatusNotImplemented)
+		return
+	}
+
+	var req NotifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !expoTokenPattern.MatchString(req.Token) {
+		httpError(w, "token must be an Expo push token (ExponentPushToken[...])", http.StatusBadRequest)
+		return
+	}
+	lead := defaultNotifyLead
+	if req.LeadTime != "" {
+		d, err := time.ParseDuration(req.LeadTime)
+		if err != nil || d < 0 || d > maxNotifyLead {
+			httpError(w, fmt.Sprintf("lead_time must be a duration between 0 and %s", maxNotifyLead), http.StatusBadRequest)
+			return
+		}
+		lead = d
+	}
+
+	id := r.PathValue("id")
+	sp, err := passStore.Get(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		httpError(w, "Pass not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching pass", "pass", id, "err", err)
+		httpError(w, "Error fetching pass", http.StatusInternalServerError)
+		return
+	}
+
+	sendAt, err := notificationSendAt(sp.Pass, lead)
+	if err != nil {
+		httpError(w, "Pass has no resolvable flight date to schedule a notification for", http.StatusUnprocessableEntity)
+		return
+	}
+	if sendAt.Before(time.Now()) {
+		httpError(w, "Notification time "+sendAt.Format(time.RFC3339)+" is already in the past", http.StatusUnprocessableEntity)
+		return
+	}
+
+	n, err := passStore.AddNotification(id, req.Token, lead, sendAt)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error registering notification", "pass", id, "err", err)
+		httpError(w, "Error registering notification", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(n)
+}
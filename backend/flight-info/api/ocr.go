@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/ocr"
+)
+
+// ----------------------
+// PARSING: OCR FALLBACK
+// ----------------------
+
+// With ?ocr=true, /parse/barcode/image reads a pass whose barcode won't
+// decode from the text printed on it instead (see package ocr). The text
+// is recognized by a tesseract binary, OCR_COMMAND, looked up at startup;
+// "off" turns the fallback off. A server without one answers ?ocr=true
+// with feature_unavailable instead of quietly leaving OCR out.
+
+var (
+	ocrEngine  *ocr.Engine // nil when OCR is unavailable
+	ocrTimeout = defaultConfig.OCRTimeout
+)
+
+// loadOCR finds the OCR_COMMAND binary. Only a command set explicitly is
+// an error when it's missing: the default, tesseract, is optional.
+func loadOCR(cfg Config) error {
+	ocrTimeout = cfg.OCRTimeout
+	command := cfg.OCRCommand
+	_, _, set := configLookup("OCR_COMMAND")
+	if command == "off" {
+		return nil
+	}
+	e, err := ocr.Find(command)
+	if err != nil {
+		if set {
+			return fmt.Errorf("OCR_COMMAND: %w", err)
+		}
+		slog.Debug("No tesseract binary found; OCR fallback unavailable", "err", err)
+		return nil
+	}
+	ocrEngine = e
+	return nil
+}
+
+func wantOCR(q url.Values) bool {
+	return q.Get("ocr") == "true"
+}
+
+// ocrUnavailableError is the 501 for ?ocr=true on a server without OCR.
+func ocrUnavailableError() ErrorDetail {
+	return ErrorDetail{
+		Reason:  reasonFeatureUnavailable,
+		Message: "OCR is not available on this server (install tesseract or set OCR_COMMAND)",
+	}
+}
+
+// recognizePass reads a pass from the text printed in img, after its
+// barcode didn't decode. The caller holds a heavy slot for it.
+func recognizePass(ctx context.Context, img []byte, ref time.Time) (*bcbp.UnifiedBoardingPass, error) {
+	ctx, cancel := context.WithTimeout(ctx, ocrTimeout)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "ocr", trace.WithAttributes(attribute.Int("ocr.image_bytes", len(img))))
+	lines, err := ocrEngine.Lines(ctx, img)
+	var p *bcbp.UnifiedBoardingPass
+	if err == nil {
+		span.SetAttributes(attribute.Int("ocr.lines", len(lines)))
+		p, err = ocr.Parse(lines, ref)
+	}
+	endSpan(span, err)
+	return p, err
+}
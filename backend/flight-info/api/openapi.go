@@ -0,0 +1,574 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"bugsbyte/flight-info/bagtag"
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/eticket"
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// API DOCS: OPENAPI 3.1 + SWAGGER UI
+// ----------------------
+
+// The spec is built from apiOperations at first request. Request and
+// response schemas are reflected from the same Go types the handlers decode
+// and encode, so a new or renamed field shows up without touching this file;
+// a new endpoint needs an entry below.
+
+type apiParam struct {
+	Name        string
+	In          string // "query", "path" or "header"
+	Type        string // JSON schema type, "string" if empty
+	Description string
+}
+
+type apiResponse struct {
+	Status      string // "200", "201", ...
+	Description string
+	Body        any    // Go value whose type is the JSON schema; nil for no JSON body
+	ContentType string // non-JSON body, e.g. "text/calendar"
+}
+
+type apiOperation struct {
+	Method, Path string
+	Summary      string
+	Description  string
+	Params       []apiParam
+	Body         any    // JSON request body type
+	Multipart    string // form field name of a file upload, instead of or besides Body
+	Responses    []apiResponse
+}
+
+// parseOptionParams are the query parameters and headers every parse
+// endpoint reads.
+var parseOptionParams = []apiParam{
+	{Name: "enrich", In: "query", Type: "boolean", Description: "Add airline names and codeshare details."},
+	{Name: "status", In: "query", Type: "boolean", Description: "Add live flight status (needs a configured provider)."},
+	{Name: "redact", In: "query", Type: "boolean", Description: "Mask the passenger name, PNR and other PII in the response."},
+	{Name: "force", In: "query", Type: "boolean", Description: "Bypass the response cache and overwrite a stored duplicate."},
+	{Name: "persist", In: "query", Type: "boolean", Description: "false parses without storing the pass, as without persistence: cached and with an ETag (default true)."},
+	{Name: "raw", In: "query", Type: "boolean", Description: "Include raw_extra_data (default true, false under /v1)."},
+	{Name: "detail", In: "query", Type: "string", Description: "full adds the parser's intermediate representation as detail."},
+	formatParam,
+	{Name: "lang", In: "query", Description: "Language of error user_message (en or pt) and of enriched city names (en, pt, es, de or fr); overrides Accept-Language."},
+	{Name: "lenient", In: "query", Type: "boolean", Description: "Accept test-environment barcodes: NUL padding and a lowercase format code are normalized, with a warning (default BCBP_LENIENT)."},
+	clientIDParam,
+}
+
+// parseParams are those of the single-pass endpoints, which answer
+// conditional requests.
+var parseParams = append(slices.Clone(parseOptionParams),
+	apiParam{Name: "If-None-Match", In: "header", Description: "ETag of an earlier response for the same input and parameters."})
+
+// barcodeParseParams are parseParams plus those of the endpoints that read
+// a BCBP date.
+var barcodeParseParams = append(slices.Clone(parseParams), referenceDateParam)
+
+var formatParam = apiParam{Name: "format", In: "query",
+	Description: "Output profile the pass is rewritten with: default (unchanged) or dcs."}
+
+var decodeProfileParam = apiParam{Name: "decode_profile", In: "query",
+	Description: "How the image is read: generic (every reader), kiosk_aztec or print (default DECODE_PROFILE)."}
+
+var clientIDParam = apiParam{Name: "X-Client-ID", In: "header",
+	Description: "Opaque device ID, 16-128 of A-Z a-z 0-9 . _ ~ -; stored passes are tagged with it for client=me."}
+
+var referenceDateParam = apiParam{Name: "reference_date", In: "query",
+	Description: "YYYY-MM-DD the Julian date is resolved around, instead of today."}
+
+// passFilterAPIParams document passFilterParams.
+var passFilterAPIParams = []apiParam{
+	{Name: "passenger", In: "query", Description: "Case-insensitive substring."},
+	{Name: "pnr", In: "query"},
+	{Name: "flight", In: "query"},
+	{Name: "departure", In: "query", Description: "IATA code."},
+	{Name: "arrival", In: "query", Description: "IATA code."},
+	{Name: "date_from", In: "query", Description: "YYYY-MM-DD, inclusive."},
+	{Name: "date_to", In: "query", Description: "YYYY-MM-DD, inclusive."},
+	{Name: "source", In: "query", Description: "barcode, pkpass, image:wallet_screenshot or image:ocr."},
+	{Name: "client", In: "query", Description: "me: only passes stored from requests with the caller's X-Client-ID."},
+	clientIDParam,
+}
+
+var idParam = apiParam{Name: "id", In: "path", Description: "Pass ID."}
+
+var passResponse = apiResponse{Status: "200", Description: "Parsed boarding pass, with an ETag unless status or force is set.", Body: bcbp.UnifiedBoardingPass{}}
+
+var bagTagResponse = apiResponse{Status: "200", Description: "For a barcode of exactly 10 digits, a bag tag license plate (document_type bag_tag) instead.", Body: bagtag.Tag{}}
+
+var receiptResponse = apiResponse{Status: "200", Description: "For a 13-digit e-ticket number, as on an itinerary receipt, the ticket (document_type eticket_receipt) instead.", Body: eticket.Receipt{}}
+
+var notModifiedResponse = apiResponse{Status: "304", Description: "If-None-Match matched; no body."}
+
+var lookupNotModifiedResponse = apiResponse{Status: "304", Description: "If-None-Match or If-Modified-Since matched; no body."}
+
+var apiOperations = []apiOperation{
+	{Method: "POST", Path: "/parse/barcode", Summary: "Parse barcode text",
+		Description: "Parses raw IATA BCBP text as read by a scanner. A barcode that is an https link to a shared pass, on a PASS_LINK_HOSTS host, is downloaded and parsed like /parse/pkpass.",
+		Params:      barcodeParseParams, Body: BarcodeRequest{},
+		Responses: []apiResponse{passResponse, bagTagResponse, receiptResponse, notModifiedResponse,
+			{Status: "422", Description: "For a link: not a pass link host (reason unknown_link), or the link wants a sign-in or doesn't send a pass (export_required)."},
+			{Status: "502", Description: "For a link: it couldn't be downloaded (link_unreachable); 504 when it didn't answer in time."},
+		}},
+	{Method: "POST", Path: "/parse/pkpass", Summary: "Parse a .pkpass file",
+		Params: parseParams, Multipart: "file", Responses: []apiResponse{passResponse, notModifiedResponse}},
+	{Method: "POST", Path: "/parse/barcode/image", Summary: "Decode and parse a barcode image",
+		Description: "Accepts base64 PNG, JPEG, GIF, BMP or WebP (Aztec, QR, Data Matrix, Code 128, ITF). PDF417 is not supported.",
+		Params: append(slices.Clone(barcodeParseParams), decodeProfileParam,
+			apiParam{Name: "ocr", In: "query", Type: "boolean", Description: "When no barcode decodes, read the pass from its printed text instead: source image:ocr, every field marked ocr (needs OCR_COMMAND)."}),
+		Body: BarcodeImageRequest{},
+		Responses: []apiResponse{passResponse, bagTagResponse, receiptResponse, notModifiedResponse,
+			{Status: "501", Description: "ocr=true, but the server has no OCR engine (reason feature_unavailable)."}}},
+	{Method: "POST", Path: "/parse/barcode/images", Summary: "Decode and parse several barcode images",
+		Description: "Each image is handled like /parse/barcode/image. The images come as base64 in JSON, or as a zip archive in a multipart file part, read in archive order. With async=true the batch runs as a job and the response is 202 with the job; otherwise the items are streamed as they finish, as JSON or, with Accept: application/x-ndjson, as NDJSON. A batch cut short ends with an error.",
+		Params: append([]apiParam{
+			{Name: "async", In: "query", Type: "boolean", Description: "Return a job at once and process in the background."},
+			decodeProfileParam,
+		}, parseOptionParams...),
+		Body: BarcodeImagesRequest{}, Multipart: "file",
+		Responses: []apiResponse{
+			{Status: "200", Description: "Per-image results, in request or archive order.", Body: BatchResult{}},
+			{Status: "200", Description: "With Accept: application/x-ndjson, a BatchItem per line in completion order, then a BatchSummary.", ContentType: ndjsonType},
+			{Status: "202", Description: "Job started; follow events_url for progress.", Body: Job{}},
+		}},
+	{Method: "GET", Path: "/ws/scan", Summary: "Live scanning over WebSocket",
+		Description: "Send camera frames as binary messages; each gets a ScanMessage back (pass, no_barcode, error or skipped). The session closes after the first pass unless continue=true.",
+		Params: append([]apiParam{
+			{Name: "continue", In: "query", Type: "boolean", Description: "Keep scanning after a pass is found."},
+			decodeProfileParam,
+		}, slices.DeleteFunc(slices.Clone(parseOptionParams), func(p apiParam) bool { return p.Name == "force" })...),
+		Responses: []apiResponse{
+			{Status: "101", Description: "WebSocket session; every server message is a JSON ScanMessage.", Body: ScanMessage{}},
+			{Status: "426", Description: "Not a WebSocket upgrade request."},
+		}},
+	{Method: "GET", Path: "/jobs/{id}", Summary: "Batch job status and result",
+		Params:    []apiParam{{Name: "id", In: "path", Description: "Job ID."}},
+		Responses: []apiResponse{{Status: "200", Description: "The job; result is set once status is done.", Body: Job{}}}},
+	{Method: "GET", Path: "/jobs/{id}/events", Summary: "Batch job progress as server-sent events",
+		Description: "An item event (JobProgress) per finished image, in completion order, then a done event (BatchResult). Past events are replayed; Last-Event-ID resumes.",
+		Params:      []apiParam{{Name: "id", In: "path", Description: "Job ID."}},
+		Responses:   []apiResponse{{Status: "200", Description: "Event stream.", ContentType: "text/event-stream"}}},
+	{Method: "GET", Path: "/passes", Summary: "List stored passes",
+		Params: append([]apiParam{
+			{Name: "limit", In: "query", Type: "integer", Description: fmt.Sprintf("Page size, 1-%d (default %d).", maxPassesLimit, defaultPassesLimit)},
+			{Name: "offset", In: "query", Type: "integer"},
+			formatParam,
+		}, passFilterAPIParams...),
+		Responses: []apiResponse{{Status: "200", Description: "A page of stored passes, newest first.", Body: PassList{}}}},
+	{Method: "DELETE", Path: "/passes", Summary: "Delete the caller's scan history",
+		Description: "Untags every pass stored with the caller's X-Client-ID and deletes those no other client scanned. Takes exactly client=me.",
+		Params: []apiParam{
+			{Name: "client", In: "query", Description: "me."},
+			clientIDParam,
+		},
+		Responses: []apiResponse{{Status: "200", Description: "How many passes were deleted and kept.", Body: ForgetReport{}}}},
+	{Method: "GET", Path: "/passes/export.csv", Summary: "Stored passes as CSV",
+		Description: "Every stored pass matching the filters, newest first. Columns: " + strings.Join(passCSVHeader, ", ") + ".",
+		Params:      passFilterAPIParams,
+		Responses:   []apiResponse{{Status: "200", Description: "CSV download.", ContentType: "text/csv"}}},
+	{Method: "GET", Path: "/passes/{id}", Summary: "Fetch a stored pass", Params: []apiParam{idParam, formatParam},
+		Responses: []apiResponse{{Status: "200", Description: "The stored pass.", Body: storage.StoredPass{}}}},
+	{Method: "DELETE", Path: "/passes/{id}", Summary: "Delete a stored pass", Params: []apiParam{idParam},
+		Responses: []apiResponse{{Status: "204", Description: "Deleted, along with its notifications."}}},
+	{Method: "GET", Path: "/passes/{id}/artifact", Summary: "Upload a stored pass was parsed from",
+		Description: "The image or .pkpass, as uploaded, when the server keeps uploads (ARTIFACT_DIR). 404 when none was kept for the pass, 410 once it has been evicted.",
+		Params:      []apiParam{idParam},
+		Responses:   []apiResponse{{Status: "200", Description: "The original upload.", ContentType: "application/octet-stream"}}},
+	{Method: "GET", Path: "/passes/{id}/bcbp", Summary: "Stored pass as a .bcbp file",
+		Description: "The barcode text the pass was parsed from; a pass without one (a .pkpass) is re-encoded from its fields, with X-Reencoded: true.",
+		Params:      []apiParam{idParam},
+		Responses:   []apiResponse{{Status: "200", Description: "The barcode text.", ContentType: "application/octet-stream"}}},
+	{Method: "GET", Path: "/passes/{id}/history", Summary: "Revisions of a stored pass",
+		Description: "The version first stored, then one revision per update (?force=true, a refresh or an import with -force) that changed a field, oldest first, with the fields it changed.",
+		Params:      []apiParam{idParam},
+		Responses:   []apiResponse{{Status: "200", Description: "The pass's revisions.", Body: storage.PassHistory{}}}},
+	{Method: "GET", Path: "/passes/{id}/ics", Summary: "Stored pass as a calendar event", Params: []apiParam{idParam},
+		Responses: []apiResponse{{Status: "200", Description: "iCalendar file.", ContentType: "text/calendar"}}},
+	{Method: "POST", Path: "/passes/{id}/notify", Summary: "Schedule a push reminder",
+		Description: "Registers an Expo push token to be notified lead_time (default 3h, at most 48h) before departure.",
+		Params:      []apiParam{idParam}, Body: NotifyRequest{},
+		Responses: []apiResponse{{Status: "201", Description: "Scheduled notification.", Body: storage.Notification{}}}},
+	{Method: "POST", Path: "/passes/{id}/refresh", Summary: "Fetch the latest version of a stored Wallet pass",
+		Description: "Asks the web service of the .pkpass the pass was stored from for its latest version, replaces the stored pass and lists the fields that changed. Needs PASS_SECRET_KEY; the web service's token is never returned.",
+		Params:      []apiParam{idParam, formatParam},
+		Responses: []apiResponse{
+			{Status: "200", Description: "The refresh, with the stored pass.", Body: PassRefresh{}},
+			{Status: "409", Description: "The pass has no web service (reason no_web_service)."},
+			{Status: "502", Description: "The web service couldn't be reached (issuer_unreachable), refused the request (issuer_rejected) or sent no readable pass (issuer_invalid)."},
+			{Status: "504", Description: "The web service didn't answer in time (issuer_unreachable)."},
+		}},
+	{Method: "GET", Path: "/trips", Summary: "Stored passes grouped into trips",
+		Params: []apiParam{
+			{Name: "include_suspect", In: "query", Type: "boolean", Description: "Also group passes whose flight date got a date_suspect warning when parsed."},
+			formatParam,
+		},
+		Responses: []apiResponse{{Status: "200", Description: "Trips, linked by PNR and passenger.", Body: TripList{}}}},
+	{Method: "POST", Path: "/analyze/conformance", Summary: "Per-airline BCBP conformance report",
+		Description: "Parses each barcode strictly and tallies clean parses, parses with findings or parser warnings, and failures by operating carrier. Examples point at the barcodes by index and offset; fields that identify the passenger are masked. Send Accept: text/csv for a CSV report.",
+		Params:      []apiParam{referenceDateParam},
+		Body:        ConformanceRequest{},
+		Responses: []apiResponse{
+			{Status: "200", Description: "The report.", Body: bcbp.ConformanceReport{}},
+			{Status: "200", Description: "With Accept: text/csv, one row per count and group.", ContentType: "text/csv"},
+		}},
+	{Method: "GET", Path: "/airlines/{code}", Summary: "Airline by IATA or ICAO code",
+		Params:    []apiParam{{Name: "code", In: "path", Description: "Two-letter IATA or three-letter ICAO code."}},
+		Responses: []apiResponse{{Status: "200", Description: "The airline.", Body: AirlineResponse{}}, lookupNotModifiedResponse}},
+	{Method: "GET", Path: "/airports", Summary: "Several airports by IATA code",
+		Description: "Unknown codes are listed in not_found rather than failing the request.",
+		Params:      []apiParam{{Name: "codes", In: "query", Description: "Comma-separated IATA codes, e.g. LIS,FRA,NRT; at most 100."}},
+		Responses:   []apiResponse{{Status: "200", Description: "The airports found, in the order asked for.", Body: AirportList{}}, lookupNotModifiedResponse}},
+	{Method: "GET", Path: "/airports/{code}", Summary: "Airport by IATA code",
+		Params:    []apiParam{{Name: "code", In: "path", Description: "Three-letter IATA code."}},
+		Responses: []apiResponse{{Status: "200", Description: "The airport.", Body: Airport{}}, lookupNotModifiedResponse}},
+	{Method: "GET", Path: "/util/julian", Summary: "Resolve a BCBP Julian date",
+		Description: "The date of the given day of the year nearest to the reference, as the barcode parser resolves it.",
+		Params: []apiParam{
+			{Name: "day", In: "query", Type: "integer", Description: "Day of the year, 1-366, e.g. 045."},
+			{Name: "reference", In: "query", Description: "YYYY-MM-DD to resolve around (default today)."},
+		},
+		Responses: []apiResponse{{Status: "200", Description: "The resolved date.", Body: JulianResponse{}}}},
+	{Method: "POST", Path: "/export/ics", Summary: "Pass JSON as a calendar event", Body: bcbp.UnifiedBoardingPass{},
+		Responses: []apiResponse{{Status: "200", Description: "iCalendar file.", ContentType: "text/calendar"}}},
+	{Method: "POST", Path: "/export/bcbp", Summary: "Pass JSON or barcode text as a .bcbp file",
+		Description: "The body is pass JSON, or a JSON string of barcode text, which is parsed first. A pass without barcode text is re-encoded from its fields, with X-Reencoded: true.",
+		Params:      []apiParam{{Name: "lenient", In: "query", Type: "boolean", Description: "Parse barcode text as /parse/barcode?lenient does (default BCBP_LENIENT)."}},
+		Body:        bcbp.UnifiedBoardingPass{},
+		Responses: []apiResponse{
+			{Status: "200", Description: "The barcode text.", ContentType: "application/octet-stream"},
+			{Status: "422", Description: "The barcode text doesn't parse."},
+		}},
+	{Method: "POST", Path: "/export/googlewallet", Summary: "Pass JSON as a Google Wallet flight pass", Body: GoogleWalletRequest{},
+		Responses: []apiResponse{{Status: "200", Description: "Wallet class and object, signed when a service account is configured.", Body: GoogleWalletResponse{}}}},
+	{Method: "POST", Path: "/generate/pkpass", Summary: "Pass JSON as an Apple Wallet .pkpass",
+		Params:    []apiParam{{Name: "unsigned", In: "query", Type: "boolean", Description: "Build an unsigned test pass when no certificate is configured."}},
+		Body:      bcbp.UnifiedBoardingPass{},
+		Responses: []apiResponse{{Status: "200", Description: "The .pkpass archive.", ContentType: "application/vnd.apple.pkpass"}}},
+	{Method: "POST", Path: "/generate/barcode/image", Summary: "Render text as an Aztec, QR or PDF417 image",
+		Description: "Returns a PNG, or JSON with the PNG in base64 when the Accept header includes application/json.",
+		Body:        BarcodeImageOptions{},
+		Responses: []apiResponse{
+			{Status: "200", Description: "PNG image, or the JSON form with Accept: application/json.", ContentType: "image/png"},
+			{Status: "200", Body: BarcodeImageResponse{}},
+		}},
+	{Method: "GET", Path: "/admin/backup", Summary: "Download every stored pass",
+		Description: "Requires Authorization: Bearer ADMIN_TOKEN.",
+		Responses:   []apiResponse{{Status: "200", Description: "Backup file for POST /admin/restore.", Body: Backup{}}}},
+	{Method: "POST", Path: "/admin/restore", Summary: "Import a backup",
+		Description: "Requires Authorization: Bearer ADMIN_TOKEN. Merges by pass ID; the copy with the later updated_at wins. All or nothing.",
+		Params:      []apiParam{{Name: "dry_run", In: "query", Type: "boolean", Description: "Report what would change without writing."}},
+		Body:        Backup{},
+		Responses:   []apiResponse{{Status: "200", Description: "What was (or would be) created, updated and skipped.", Body: RestoreReport{}}}},
+	{Method: "POST", Path: "/admin/import", Summary: "Import a folder of passes",
+		Description: "Requires Authorization: Bearer ADMIN_TOKEN. A zip, as the multipart \"file\" part, of .pkpass files, barcode images and text files with a barcode per line. Each pass is stored with the usual dedup; content imported before is skipped. A file that fails doesn't stop the others.",
+		Params:      []apiParam{{Name: "force", In: "query", Type: "boolean", Description: "Replace stored passes, and import content imported before again."}},
+		Multipart:   "file",
+		Responses: []apiResponse{
+			{Status: "200", Description: "The outcome for every file, or line of a text file.", Body: ImportReport{}},
+			{Status: "415", Description: "The upload isn't a zip archive.", Body: ErrorResponse{}},
+			{Status: "501", Description: "Persistence is disabled (set SQLITE_PATH or DATABASE_URL)."},
+		}},
+	{Method: "POST", Path: "/admin/cleanup", Summary: "Delete expired passes now",
+		Description: "Requires Authorization: Bearer ADMIN_TOKEN and a PASS_RETENTION policy.",
+		Responses:   []apiResponse{{Status: "200", Description: "How many passes were deleted.", Body: CleanupReport{}}}},
+	{Method: "GET", Path: "/admin/failures", Summary: "Recent parse failures",
+		Description: "Requires Authorization: Bearer ADMIN_TOKEN. Input samples are included only with DEBUG_CAPTURE.",
+		Responses:   []apiResponse{{Status: "200", Description: "Failures, newest first.", Body: FailureList{}}}},
+	{Method: "DELETE", Path: "/admin/failures", Summary: "Clear the parse failure log",
+		Description: "Requires Authorization: Bearer ADMIN_TOKEN.",
+		Responses:   []apiResponse{{Status: "204", Description: "Cleared."}}},
+	{Method: "GET", Path: "/admin/shadow", Summary: "Pkpass mapper disagreements",
+		Description: "Requires Authorization: Bearer ADMIN_TOKEN and PKPASS_SHADOW_MAPPER; 501 without it.",
+		Responses:   []apiResponse{{Status: "200", Description: "Disagreements per field, most first.", Body: ShadowReport{}}}},
+	{Method: "DELETE", Path: "/admin/shadow", Summary: "Reset the pkpass mapper disagreement counts",
+		Description: "Requires Authorization: Bearer ADMIN_TOKEN and PKPASS_SHADOW_MAPPER.",
+		Responses:   []apiResponse{{Status: "204", Description: "Reset."}}},
+	{Method: "GET", Path: "/admin/config", Summary: "Live configuration",
+		Description: "Requires Authorization: Bearer ADMIN_TOKEN. Every setting with its value, secrets masked, and source: default, env, file or runtime.",
+		Responses:   []apiResponse{{Status: "200", Description: "The settings.", Body: ConfigReport{}}}},
+	{Method: "PATCH", Path: "/admin/config", Summary: "Change settings without a restart",
+		Description: "Requires Authorization: Bearer ADMIN_TOKEN. An object of settings to new values, applied all or none; only LOG_LEVEL, DECODE_PROFILE and PARSE_CACHE_TTL can change at runtime.",
+		Body:        map[string]string{},
+		Responses: []apiResponse{
+			{Status: "200", Description: "The settings after the change.", Body: ConfigReport{}},
+			{Status: "400", Description: "Unknown setting, one that needs a restart, or a bad value.", Body: ErrorResponse{}},
+			{Status: "409", Description: "PARSE_CACHE_TTL while the parse cache is off, or 0.", Body: ErrorResponse{}},
+		}},
+	{Method: "POST", Path: "/admin/selftest", Summary: "Run the embedded self-test",
+		Description: "Requires Authorization: Bearer ADMIN_TOKEN. Parses a barcode string, a pkpass and an image of every supported symbology embedded in the server, together within 10 seconds, and reports each case. Nothing is stored, cached or sent to webhooks.",
+		Responses:   []apiResponse{{Status: "200", Description: "Pass or fail per case, with timings; ok is false if any case failed.", Body: SelfTestReport{}}}},
+	{Method: "GET", Path: "/capabilities", Summary: "What this server supports",
+		Description: "Barcode formats, image formats, optional features and input limits, as configured; hide what is off.",
+		Responses:   []apiResponse{{Status: "200", Description: "The capabilities.", Body: Capabilities{}}}},
+	{Method: "GET", Path: "/stats", Summary: "Parse counts",
+		Description: "Requires SQLITE_PATH or DATABASE_URL; 501 without it. Counts by UTC day or hour, source, carrier and error, for an inclusive range of days.",
+		Params: []apiParam{
+			{Name: "from", In: "query", Type: "string", Description: "First day, YYYY-MM-DD (UTC). Default: 6 days before to."},
+			{Name: "to", In: "query", Type: "string", Description: "Last day, YYYY-MM-DD (UTC), at most 366 days after from. Default: today."},
+			{Name: "by", In: "query", Type: "string", Description: "Buckets of series: day (default) or hour."},
+		},
+		Responses: []apiResponse{{Status: "200", Description: "The counts.", Body: StatsReport{}}}},
+	{Method: "GET", Path: "/metrics", Summary: "Prometheus metrics",
+		Responses: []apiResponse{{Status: "200", Description: "Prometheus text exposition format.", ContentType: "text/plain"}}},
+}
+
+// openAPISpec is marshalled once; it only depends on types and constants.
+var openAPISpec = sync.OnceValue(func() []byte {
+	g := &schemaGen{components: map[string]any{}}
+	g.component(reflect.TypeOf(ErrorResponse{}))
+	g.component(reflect.TypeOf(ProblemDetails{}))
+
+	paths := map[string]map[string]any{}
+	for _, op := range apiOperations {
+		o := map[string]any{"summary": op.Summary}
+		if op.Description != "" {
+			o["description"] = op.Description
+		}
+		var params []any
+		for _, p := range op.Params {
+			typ := p.Type
+			if typ == "" {
+				typ = "string"
+			}
+			param := map[string]any{"name": p.Name, "in": p.In, "schema": map[string]any{"type": typ}}
+			if p.In == "path" {
+				param["required"] = true
+			}
+			if p.Description != "" {
+				param["description"] = p.Description
+			}
+			params = append(params, param)
+		}
+		if params != nil {
+			o["parameters"] = params
+		}
+
+		content := map[string]any{}
+		if op.Multipart != "" {
+			content["multipart/form-data"] = map[string]any{"schema": map[string]any{
+				"type":       "object",
+				"required":   []string{op.Multipart},
+				"properties": map[string]any{op.Multipart: map[string]any{"type": "string", "format": "binary"}},
+			}}
+		}
+		if op.Body != nil {
+			content["application/json"] = map[string]any{"schema": g.schema(reflect.TypeOf(op.Body))}
+		}
+		if len(content) > 0 {
+			o["requestBody"] = map[string]any{"required": true, "content": content}
+		}
+
+		responses := map[string]any{
+			"default": map[string]any{"$ref": "#/components/responses/Error"},
+		}
+		for _, r := range op.Responses {
+			resp, _ := responses[r.Status].(map[string]any)
+			if resp == nil {
+				resp = map[string]any{"description": r.Description}
+				responses[r.Status] = resp
+			} else if r.Description != "" {
+				resp["description"] = resp["description"].(string) + " " + r.Description
+			}
+			content, _ := resp["content"].(map[string]any)
+			if content == nil {
+				content = map[string]any{}
+			}
+			switch {
+			case r.Body != nil:
+				schema := g.schema(reflect.TypeOf(r.Body))
+				// A second JSON body for the same status is an alternative.
+				if prev, ok := content["application/json"].(map[string]any); ok {
+					alts, ok := prev["schema"].(map[string]any)["oneOf"].([]any)
+					if !ok {
+						alts = []any{prev["schema"]}
+					}
+					schema = map[string]any{"oneOf": append(alts, schema)}
+				}
+				content["application/json"] = map[string]any{"schema": schema}
+			case r.ContentType != "":
+				content[r.ContentType] = map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}
+			}
+			if len(content) > 0 {
+				resp["content"] = content
+			}
+		}
+		o["responses"] = responses
+
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]any{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = o
+	}
+
+	spec := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "Flight Info API",
+			"version":     "1.0.0",
+			"description": "Parses boarding passes (IATA BCBP barcodes, barcode images and Apple Wallet .pkpass files) into a unified JSON format. Every path is also served under /v1, whose passes are schema version 1 as described here; unprefixed paths always include the core string fields, empty or not.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": g.components,
+			"responses": map[string]any{
+				"Error": map[string]any{
+					"description": "Error envelope; code is the snake_case HTTP status text. Clients whose Accept header prefers application/problem+json get RFC 9457 problem details instead, with the envelope's fields as extension members.",
+					"headers": map[string]any{
+						"X-Request-ID": map[string]any{"schema": map[string]any{"type": "string"}},
+					},
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/ErrorResponse"}},
+						problemJSON:        map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/ProblemDetails"}},
+					},
+				},
+			},
+		},
+	}
+	b, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		panic(err) // only plain maps and strings above
+	}
+	return append(b, '\n')
+})
+
+// schemaGen turns Go types into JSON schemas the way encoding/json would
+// marshal them. Named structs become components referenced by $ref.
+type schemaGen struct {
+	components map[string]any
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		s := map[string]any{"type": "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			s["additionalProperties"] = g.schema(t.Elem())
+		}
+		return s
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return g.component(t)
+	}
+	return map[string]any{} // interface{}: any JSON value
+}
+
+func (g *schemaGen) component(t reflect.Type) map[string]any {
+	ref := map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	if _, ok := g.components[t.Name()]; !ok {
+		g.components[t.Name()] = nil // placeholder, for self-referencing types
+		g.components[t.Name()] = g.object(t)
+	}
+	return ref
+}
+
+// object lists the exported fields by their json names; fields without
+// omitempty are always present in the JSON and so marked required.
+// Embedded structs without a json name are flattened, as encoding/json does.
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if ft := f.Type; f.Anonymous && name == "" {
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					walk(ft)
+					continue
+				}
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = g.schema(f.Type)
+			if !strings.Contains(","+opts+",", ",omitempty,") {
+				required = append(required, name)
+			}
+		}
+	}
+	walk(t)
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec())
+}
+
+//go:embed docs.html
+var docsHTML []byte
+
+// handleDocs serves Swagger UI pointed at /openapi.json. The page loads the
+// UI bundle from a CDN, so it needs internet access in the browser.
+func handleDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsHTML)
+}
+
+// checkAPIRoutes warns at startup about documented operations no registered
+// route serves, so the spec can't silently drift from main().
+func checkAPIRoutes(mux *http.ServeMux) {
+	for _, op := range apiOperations {
+		path := strings.NewReplacer("{id}", "x", "{code}", "x").Replace(op.Path)
+		req, err := http.NewRequest(op.Method, path, nil)
+		if err != nil {
+			continue
+		}
+		_, pattern := mux.Handler(req)
+		if pattern != op.Path {
+			slog.Warn("OpenAPI documents a route that is not registered", "method", op.Method, "path", op.Path, "routed_to", pattern)
+		}
+	}
+}
@@ -0,0 +1,508 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bagtag"
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/eticket"
+	"bugsbyte/flight-info/ocr"
+	"bugsbyte/flight-info/pkpass"
+	"bugsbyte/flight-info/scan"
+)
+
+// ----------------------
+// HANDLERS: PARSING
+// ----------------------
+
+// BarcodeRequest is the body of POST /parse/barcode.
+type BarcodeRequest struct {
+	Barcode string `json:"barcode"`
+}
+
+// maxBarcodeBody caps the JSON body of /parse/barcode; a BCBP string is a
+// few hundred bytes.
+const maxBarcodeBody = 64 << 10
+
+func handleBarcode(w http.ResponseWriter, r *http.Request) {
+	var req BarcodeRequest
+	if status, d := decodeJSONBody(w, r, maxBarcodeBody, &req); status != 0 {
+		parseFailed(w, r, status, d, int(r.ContentLength), "")
+		return
+	}
+	if isPassLink(req.Barcode) {
+		handlePassLink(w, r, req.Barcode)
+		return
+	}
+	if tag, ok := bagtag.Parse(req.Barcode); ok {
+		captureBarcode(r, req.Barcode, nil, tag, nil)
+		respondWithBagTag(w, tag)
+		return
+	}
+	if receipt, ok := eticket.Parse(req.Barcode); ok {
+		captureBarcode(r, req.Barcode, nil, receipt, nil)
+		respondWithReceipt(w, r, receipt)
+		return
+	}
+	ref, status, d := referenceDate(r.URL.Query())
+	if status != 0 {
+		parseFailed(w, r, status, d, len(req.Barcode), "")
+		return
+	}
+	if status, d := outputFormat(r.URL.Query()); status != 0 {
+		parseFailed(w, r, status, d, len(req.Barcode), "")
+		return
+	}
+
+	key := parseKey(r.Context(), "barcode", []byte(req.Barcode), r.URL.Query())
+	if serveCached(w, r, key) {
+		return
+	}
+
+	data, err := parseBarcodeText(r.Context(), req.Barcode, ref, lenientParse(r.URL.Query()))
+	captureBarcode(r, req.Barcode, data, nil, err)
+	if err != nil {
+		// The input carries PII: only logged at debug level, and never when
+		// redacting.
+		if !wantRedaction(r.URL.Query()) && slog.Default().Enabled(r.Context(), slog.LevelDebug) {
+			slog.DebugContext(r.Context(), "Barcode input", "input", req.Barcode)
+		}
+		slog.InfoContext(r.Context(), "Error parsing barcode", "err", err)
+		parseFailed(w, r, http.StatusUnprocessableEntity,
+			ErrorDetail{Reason: bcbpErrorReason(err), Message: fmt.Sprintf("Error parsing barcode: %v", err)},
+			len(req.Barcode), textSample(r.URL.Query(), req.Barcode))
+		return
+	}
+	respondWithPass(w, r, data, key)
+}
+
+// parseBarcodeText is parseBCBP for barcode text as a client typed or
+// pasted it: text that doesn't parse is tried as the base64 or hex
+// encoding of a barcode (see bcbp.DecodeTransport), which scanner SDKs
+// hand back. RawData["input_encoding"] then names the encoding. The error
+// is the one for the text as sent.
+func parseBarcodeText(ctx context.Context, raw string, ref time.Time, lenient bool) (*bcbp.UnifiedBoardingPass, error) {
+	data, err := parseBCBP(ctx, raw, ref, lenient)
+	if err == nil {
+		return data, nil
+	}
+	text, encoding, ok := bcbp.DecodeTransport(raw)
+	if !ok {
+		return nil, err
+	}
+	decoded, decodeErr := parseBCBP(ctx, text, ref, lenient)
+	if decodeErr != nil {
+		return nil, err
+	}
+	decoded.RawData["input_encoding"] = encoding
+	return decoded, nil
+}
+
+func handlePkPass(w http.ResponseWriter, r *http.Request) {
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "multipart/form-data" {
+		parseFailed(w, r, http.StatusUnsupportedMediaType,
+			ErrorDetail{Reason: reasonUnsupportedType, Message: "Content-Type must be multipart/form-data"},
+			int(r.ContentLength), "")
+		return
+	}
+	file, err := readFilePart(w, r, "file", maxPkPassUpload)
+	if err != nil {
+		switch {
+		case tooLarge(err):
+			parseFailed(w, r, http.StatusRequestEntityTooLarge,
+				ErrorDetail{Reason: reasonTooLarge, Message: "Upload too large"}, int(r.ContentLength), "")
+		case errors.Is(err, errNoFilePart):
+			parseFailed(w, r, http.StatusBadRequest,
+				ErrorDetail{Reason: reasonInvalidForm, Message: "Error retrieving file"}, int(r.ContentLength), "")
+		default:
+			parseFailed(w, r, http.StatusBadRequest,
+				ErrorDetail{Reason: reasonInvalidForm, Message: "Invalid multipart form"}, int(r.ContentLength), "")
+		}
+		return
+	}
+	defer file.Close()
+
+	if status, d := checkPkPassUpload(file.head); status != 0 {
+		parseFailed(w, r, status, d, int(file.size), "")
+		return
+	}
+	if status, d := outputFormat(r.URL.Query()); status != 0 {
+		parseFailed(w, r, status, d, int(file.size), "")
+		return
+	}
+
+	key := parseKey(r.Context(), "pkpass", file.sum[:], r.URL.Query())
+	if serveCached(w, r, key) {
+		return
+	}
+
+	release, ok := acquireHeavy(w, r, 1)
+	if !ok {
+		return
+	}
+	data, err := parsePKPass(r.Context(), file.r, file.size)
+	release()
+	if capturing(r) {
+		passJSON, _ := pkpass.PassJSON(file.r, file.size)
+		capturePkPass(r, passJSON, data, err)
+	}
+	if err != nil {
+		parseFailed(w, r, http.StatusUnprocessableEntity,
+			ErrorDetail{Reason: reasonInvalidPkPass, Message: fmt.Sprintf("Error parsing pkpass: %v", err)},
+			int(file.size), bytesSample(r.URL.Query(), file.head))
+		return
+	}
+	if wantDetail(r.URL.Query()) {
+		addPkPassDetail(data, file.r, file.size)
+	}
+	r = withArtifact(r, &artifact{r: file.r, size: file.size, sum: file.sum, contentType: pkpassContentType})
+	r = r.WithContext(withWebService(r.Context(), file.r, file.size))
+	respondWithPass(w, r, data, key)
+}
+
+// decodeJSONBody decodes a JSON request body of at most limit bytes into v.
+// On failure it returns the status and error to send: 415 for a Content-Type
+// other than JSON (a missing one is accepted), 413 past limit, and 400 for
+// malformed JSON. status is 0 on success.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, limit int64, v any) (status int, d ErrorDetail) {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mt != "application/json" && !strings.HasSuffix(mt, "+json")) {
+			return http.StatusUnsupportedMediaType,
+				ErrorDetail{Reason: reasonJSONRequired, Message: "Content-Type must be application/json"}
+		}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		if tooLarge(err) {
+			return http.StatusRequestEntityTooLarge,
+				ErrorDetail{Reason: reasonTooLarge, Message: "Request body too large"}
+		}
+		return http.StatusBadRequest, ErrorDetail{Reason: reasonInvalidJSON, Message: "Invalid JSON"}
+	}
+	return 0, ErrorDetail{}
+}
+
+// tooLarge reports whether err comes from an http.MaxBytesReader limit.
+func tooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// respondWithPass runs a freshly parsed pass through processPass, then
+// writes it as the response with its ETag and caches it under key.
+func respondWithPass(w http.ResponseWriter, r *http.Request, data *bcbp.UnifiedBoardingPass, key string) {
+	data = processPass(r.Context(), data, r.URL.Query())
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding pass", "err", err)
+		httpError(w, "Error encoding pass", http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n')
+	if useCache(r.URL.Query()) {
+		parseCache.Put(key, body)
+		w.Header().Set("X-Cache", "MISS")
+	}
+	writePassBody(w, r, body)
+}
+
+// respondWithBagTag writes a bag tag read by a barcode endpoint. Tags carry
+// no PII and are not passes: they skip the cache, storage and webhooks.
+func respondWithBagTag(w http.ResponseWriter, tag *bagtag.Tag) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tag)
+}
+
+// respondWithReceipt writes the ticket number of an itinerary receipt read
+// by a barcode endpoint, which is not a pass either. The number is masked
+// when redacting.
+func respondWithReceipt(w http.ResponseWriter, r *http.Request, receipt *eticket.Receipt) {
+	if wantRedaction(r.URL.Query()) {
+		redactReceipt(receipt)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
+}
+
+// processPass is everything after parsing that the HTTP and gRPC APIs
+// share: enrichment, persistence, webhooks, redaction and the response
+// shape. q holds the /parse query parameters (enrich, status, redact, force,
+// persist, raw, detail).
+func processPass(ctx context.Context, data *bcbp.UnifiedBoardingPass, q url.Values) *bcbp.UnifiedBoardingPass {
+	if wantDetail(q) && data.Source != bcbp.SourcePkPass {
+		addBarcodeDetail(data)
+	}
+	recordParse(string(data.Source), data.Carrier)
+	EnrichPass(ctx, data, q)
+	if redactAll {
+		RedactPass(data)
+	}
+	// The detail is for this response only.
+	detail := data.Detail
+	data.Detail = nil
+	if persisting(q) {
+		data = persistPass(ctx, data, q.Get("force") == "true")
+		storeArtifact(ctx, data)
+		storeWebService(ctx, data)
+	}
+	notifyWebhooks(data)
+	data.Detail = detail
+	if !redactAll && q.Get("redact") == "true" {
+		RedactPass(data)
+	}
+	shapePass(ctx, data, q)
+	return data
+}
+
+// EnrichPass runs the semantic checks (see bcbp.Rules), fills in derived
+// fields (local timestamps), checks the flight date against the sanity
+// window and applies the optional lookups
+// requested in q: enrich=true for airline names, codeshares and airport
+// and city names (in the language negotiated for ctx), status=true for live
+// flight status.
+func EnrichPass(ctx context.Context, p *bcbp.UnifiedBoardingPass, q url.Values) {
+	bcbp.CheckSemantics(p)
+	resolveLocalTimes(p)
+	checkDateWindow(p, timeNow())
+	if q.Get("enrich") == "true" {
+		bcbp.NameCarriers(p)
+		bcbp.NameAirports(p, displayLang(ctx))
+	}
+	if q.Get("status") == "true" {
+		enrichFlightStatus(ctx, p)
+	}
+	if p.Source == bcbp.SourceOCR {
+		ocr.MarkFields(p)
+	}
+}
+
+// bcbpLenient is BCBP_LENIENT: lenient barcode parsing by default, for
+// servers in front of a check-in test environment.
+var bcbpLenient bool
+
+// lenientParse reports whether barcodes are parsed with
+// bcbp.ParseLenientAt: ?lenient, or BCBP_LENIENT when it is absent.
+func lenientParse(q url.Values) bool {
+	if v := q.Get("lenient"); v != "" {
+		return v == "true"
+	}
+	return bcbpLenient
+}
+
+var dataURIPrefix = regexp.MustCompile(`^data:[^;,]+;base64,`)
+
+// BarcodeImageRequest is the body of POST /parse/barcode/image.
+type BarcodeImageRequest struct {
+	Image string `json:"image"` // base64, optionally as a data: URI
+	// DecodeProfile overrides ?decode_profile (see scan.DecodeProfile).
+	DecodeProfile string `json:"decode_profile,omitempty"`
+}
+
+func handleBarcodeImage(w http.ResponseWriter, r *http.Request) {
+	var req BarcodeImageRequest
+	if status, d := decodeJSONBody(w, r, 2*scan.MaxImageBytes, &req); status != 0 {
+		parseFailed(w, r, status, d, int(r.ContentLength), "")
+		return
+	}
+	img, done, status, d := decodeBase64Image(req.Image)
+	if status != 0 {
+		parseFailed(w, r, status, d, len(req.Image), "")
+		return
+	}
+	defer done()
+	ref, status, d := referenceDate(r.URL.Query())
+	if status != 0 {
+		parseFailed(w, r, status, d, len(img), "")
+		return
+	}
+	if status, d := outputFormat(r.URL.Query()); status != 0 {
+		parseFailed(w, r, status, d, len(img), "")
+		return
+	}
+	prof, status, d := decodeProfile(r.URL.Query(), req.DecodeProfile)
+	if status != 0 {
+		parseFailed(w, r, status, d, len(img), "")
+		return
+	}
+	if wantOCR(r.URL.Query()) && ocrEngine == nil {
+		writeError(w, http.StatusNotImplemented, ocrUnavailableError())
+		return
+	}
+
+	q := r.URL.Query()
+	q.Set("decode_profile", prof.Name)
+	key := parseKey(r.Context(), "image", img, q)
+	if serveCached(w, r, key) {
+		return
+	}
+
+	release, ok := acquireHeavy(w, r, scan.Weight(img))
+	if !ok {
+		return
+	}
+	res, err := scan.DecodeWith(r.Context(), img, prof)
+	release()
+	if err != nil && wantOCR(r.URL.Query()) && errors.Is(err, scan.ErrNoBarcode) {
+		// OCR gets a slot of its own: tesseract takes longer than the
+		// decode, and other requests may be waiting.
+		release, ok := acquireHeavy(w, r, scan.Weight(img))
+		if !ok {
+			return
+		}
+		data, ocrErr := recognizePass(r.Context(), img, ref)
+		release()
+		if ocrErr == nil {
+			captureImage(r, img, "", "", data, nil, nil)
+			respondWithPass(w, withArtifact(r, imageArtifact(img)), data, key)
+			return
+		}
+		err = fmt.Errorf("%w; OCR: %v", err, ocrErr)
+	}
+	if err != nil {
+		captureImage(r, img, "", "", nil, nil, err)
+		status, d := imageDecodeError(err, prof.Name)
+		parseFailed(w, r, status, d, len(img), "")
+		return
+	}
+	text, format := res.Text, res.Format
+
+	if tag, ok := bagtag.Parse(text); ok {
+		tag.BarcodeFormat = format
+		captureImage(r, img, text, format, nil, tag, nil)
+		respondWithBagTag(w, tag)
+		return
+	}
+	if receipt, ok := eticket.Parse(text); ok {
+		receipt.BarcodeFormat = format
+		captureImage(r, img, text, format, nil, receipt, nil)
+		respondWithReceipt(w, r, receipt)
+		return
+	}
+	data, err := parseBCBP(r.Context(), text, ref, lenientParse(r.URL.Query()))
+	captureImage(r, img, text, format, data, nil, err)
+	if err != nil {
+		parseFailed(w, r, http.StatusUnprocessableEntity, notBoardingPassError(r.URL.Query(), text, err),
+			len(img), textSample(r.URL.Query(), text))
+		return
+	}
+	tagImagePass(data, res)
+	if wantDetail(r.URL.Query()) {
+		addDecodeDetail(data, res)
+	}
+	respondWithPass(w, withArtifact(r, imageArtifact(img)), data, key)
+}
+
+// decodeBase64Image decodes a base64 image (see decodePooledBase64),
+// optionally a data: URI, and checks it against scan.MaxImageBytes and
+// checkImageUpload. status is 0 on success, and the caller then calls
+// release once done with img.
+func decodeBase64Image(b64 string) (img []byte, release func(), status int, d ErrorDetail) {
+	// Slice the prefix off rather than ReplaceAllString, which would copy the
+	// whole (multi-megabyte) string first.
+	prefix := 0
+	if loc := dataURIPrefix.FindStringIndex(b64); loc != nil {
+		b64, prefix = b64[loc[1]:], loc[1]
+	}
+	img, release, err := decodePooledBase64(b64)
+	if err != nil {
+		d := ErrorDetail{Reason: reasonInvalidBase64, Message: "Invalid base64 image data"}
+		if e, ok := err.(*base64Error); ok {
+			// The prefix is ASCII, so its length is its character count.
+			d.Position = prefix + e.Pos
+			d.Message += fmt.Sprintf(": %s at position %d", e.Reason, d.Position)
+		}
+		return nil, nil, http.StatusBadRequest, d
+	}
+	status, d = checkBase64Image(img)
+	if status != 0 {
+		release()
+		return nil, nil, status, d
+	}
+	return img, release, 0, ErrorDetail{}
+}
+
+// checkBase64Image checks a decoded image against scan.MaxImageBytes and
+// checkImageUpload.
+func checkBase64Image(img []byte) (status int, d ErrorDetail) {
+	if len(img) == 0 {
+		return http.StatusBadRequest, ErrorDetail{Reason: reasonInvalidBase64, Message: "Invalid base64 image data: empty image"}
+	}
+	if len(img) > scan.MaxImageBytes {
+		return http.StatusRequestEntityTooLarge, ErrorDetail{Reason: reasonTooLarge, Message: "Image too large"}
+	}
+	return checkImageUpload(img)
+}
+
+// tagImagePass records how a pass parsed from an image was read: the
+// barcode format, and a wallet_screenshot source when the image was one.
+func tagImagePass(p *bcbp.UnifiedBoardingPass, res *scan.Result) {
+	p.RawData["barcode_format"] = res.Format
+	if res.WalletScreenshot {
+		p.Source = bcbp.SourceWalletScreenshot
+	}
+}
+
+// walletDeepLink opens Apple Wallet on iOS, where the traveler can share
+// the pass file instead of a screenshot.
+const walletDeepLink = "shoebox://"
+
+// imageDecodeError classifies a scan.DecodeWith error for an image read
+// with the profile named prof.
+func imageDecodeError(err error, prof string) (status int, d ErrorDetail) {
+	status, d = http.StatusUnprocessableEntity, ErrorDetail{Reason: reasonInvalidImage}
+	switch {
+	case errors.Is(err, scan.ErrImageTooLarge):
+		status, d.Reason = http.StatusRequestEntityTooLarge, reasonTooLarge
+	case errors.Is(err, scan.ErrWalletScreenshot):
+		d.Reason, d.UseEndpoint, d.DeepLink = reasonWalletScreenshot, "/parse/pkpass", walletDeepLink
+	case errors.Is(err, scan.ErrNoBarcode):
+		d.Reason = reasonNoBarcode
+		var qerr *scan.QualityError
+		if errors.As(err, &qerr) {
+			d.Reason = reasonImageTooSmall
+			if qerr.Err == scan.ErrImageBlurry {
+				d.Reason = reasonImageBlurry
+			}
+			d.ImageQuality = &ImageQuality{
+				Width: qerr.Width, Height: qerr.Height, Sharpness: math.Round(qerr.Sharpness*10) / 10,
+				MinSide: scan.MinImageSide, MinSharpness: scan.MinSharpness,
+			}
+		}
+	}
+	if errors.Is(err, scan.ErrNoBarcode) {
+		d.DecodeProfile = prof
+	}
+	d.Message = fmt.Sprintf("Error decoding image: %v", err)
+	return status, d
+}
+
+// notBoardingPassError is the 422 for barcode text read from an image that
+// didn't parse, carrying the text unless q asks for redaction.
+func notBoardingPassError(q url.Values, text string, err error) ErrorDetail {
+	d := ErrorDetail{Reason: bcbpErrorReason(err), Message: fmt.Sprintf("Error parsing barcode: %v", err)}
+	if !wantRedaction(q) {
+		d.DecodedText = truncateText(text, maxFailureText)
+	}
+	return d
+}
+
+// bcbpErrorReason classifies a bcbp.Parse error.
+func bcbpErrorReason(err error) string {
+	if errors.Is(err, bcbp.ErrInvalidEncoding) {
+		return reasonInvalidEncoding
+	}
+	return reasonNotBoardingPass
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// errorReason is the reason of an error envelope, "" for any other body.
+func errorReason(t *testing.T, body []byte) string {
+	t.Helper()
+	return errorEnvelope(body).Reason
+}
+
+// errorEnvelope is the error of an error envelope, empty for any other
+// body.
+func errorEnvelope(body []byte) ErrorDetail {
+	var e struct {
+		Error ErrorDetail `json:"error"`
+	}
+	json.Unmarshal(body, &e)
+	return e.Error
+}
+
+// checkerboard is a sharp image without a barcode.
+func checkerboard() image.Image {
+	img := image.NewGray(image.Rect(0, 0, 400, 400))
+	for i := range img.Pix {
+		if x, y := i%400, i/400; (x/40+y/40)%2 == 0 {
+			img.Pix[i] = 0xff
+		}
+	}
+	return img
+}
+
+// pngBase64 encodes img as base64 PNG.
+func pngBase64(t *testing.T, img image.Image) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestParseStatusCodes(t *testing.T) {
+	barcode := string(jsonBody(t, BarcodeRequest{Barcode: readFixture(t, "bcbp/ac-yul-fra-mandatory.bcbp")}))
+	imageBody := func(img string) string { return string(jsonBody(t, BarcodeImageRequest{Image: img})) }
+	qr, err := renderBarcode("https://example.com/not-a-pass", "QR", 400, "M")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		status      int
+		reason      string
+	}{
+		{"json", "/parse/barcode", "application/json", barcode, http.StatusOK, ""},
+		{"json with charset", "/parse/barcode", "application/json; charset=utf-8", barcode, http.StatusOK, ""},
+		{"json suffix", "/parse/barcode", "application/vnd.api+json", barcode, http.StatusOK, ""},
+		{"missing content type", "/parse/barcode", "", barcode, http.StatusOK, ""},
+		{"form", "/parse/barcode", "application/x-www-form-urlencoded", "barcode=M1", http.StatusUnsupportedMediaType, reasonJSONRequired},
+		{"multipart", "/parse/barcode", "multipart/form-data; boundary=x", barcode, http.StatusUnsupportedMediaType, reasonJSONRequired},
+		{"text", "/parse/barcode", "text/plain", barcode, http.StatusUnsupportedMediaType, reasonJSONRequired},
+		{"malformed content type", "/parse/barcode", "application/", barcode, http.StatusUnsupportedMediaType, reasonJSONRequired},
+		{"malformed json", "/parse/barcode", "application/json", `{"barcode":`, http.StatusBadRequest, reasonInvalidJSON},
+		{"not bcbp", "/parse/barcode", "application/json", `{"barcode":"hello"}`, http.StatusUnprocessableEntity, reasonNotBoardingPass},
+		{"too large", "/parse/barcode", "application/json", `{"barcode":"` + strings.Repeat("M", maxBarcodeBody) + `"}`, http.StatusRequestEntityTooLarge, reasonTooLarge},
+		{"image form", "/parse/barcode/image", "application/x-www-form-urlencoded", "image=x", http.StatusUnsupportedMediaType, reasonJSONRequired},
+		{"image missing content type", "/parse/barcode/image", "", imageBody(base64.StdEncoding.EncodeToString(readFile(t, "data/selftest/ac-aztec.png"))), http.StatusOK, ""},
+		{"image not an image", "/parse/barcode/image", "application/json", imageBody(base64.StdEncoding.EncodeToString([]byte("not an image"))), http.StatusUnprocessableEntity, reasonInvalidImage},
+		{"image without barcode", "/parse/barcode/image", "application/json", imageBody(pngBase64(t, checkerboard())), http.StatusUnprocessableEntity, reasonNoBarcode},
+		{"image not a boarding pass", "/parse/barcode/image", "application/json", imageBody(pngBase64(t, qr)), http.StatusUnprocessableEntity, reasonNotBoardingPass},
+	}
+	h := Handler()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header []string
+			if tt.contentType != "" {
+				header = []string{"Content-Type", tt.contentType}
+			}
+			w := serve(t, h, http.MethodPost, tt.target, strings.NewReader(tt.body), header...)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := errorReason(t, w.Body.Bytes()); got != tt.reason {
+				t.Errorf("reason %q, want %q", got, tt.reason)
+			}
+		})
+	}
+}
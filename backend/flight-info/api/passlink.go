@@ -0,0 +1,293 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ----------------------
+// PARSING: SHARED PASS LINKS
+// ----------------------
+
+// Travelers share Wallet passes as links (https://wallet.apple.com/...,
+// an airline's "add to Wallet" link) and paste those where a barcode
+// goes. A barcode sent to /parse/barcode that starts with http:// or
+// https:// is taken as such a link: if its host is one of passLinkHosts,
+// the server downloads it and parses what it gets like an upload to
+// /parse/pkpass. Links that want a sign-in, open a web page or send
+// anything but a pass get an export_required 422: the pass has to be
+// exported from Wallet or the airline's app and uploaded as a file.
+//
+// The download only ever connects to public addresses (see
+// publicAddressOnly) and pass link hosts (see checkPassLinkRedirect),
+// redirects included, and goes through the breaker of its host (see
+// breakerTransport).
+
+// passLinkHosts are PASS_LINK_HOSTS: hosts whose links are fetched. An
+// entry matches that host; "*.example.com" matches its subdomains.
+var passLinkHosts = strings.Split(defaultConfig.PassLinkHosts, ",")
+
+// passLinkTimeout bounds the download of a link, redirects included.
+var passLinkTimeout = 10 * time.Second
+
+// passLinkMaxRedirects is how many redirects a download follows.
+const passLinkMaxRedirects = 5
+
+// Reasons for a link that yields no pass: its host isn't a pass link host
+// (422), it wants a sign-in or sends something else than a pass (422), or
+// it couldn't be downloaded (502, or 504 on a timeout).
+const (
+	reasonUnknownLink     = "unknown_link"     // 422
+	reasonExportRequired  = "export_required"  // 422
+	reasonLinkUnreachable = "link_unreachable" // 502, 504
+)
+
+func init() {
+	describeMetric("pass_link_fetches_total", "counter", "Shared pass links sent to /parse/barcode by result.")
+	for _, result := range []string{"ok", "unknown_link", "export_required", "unreachable", "too_large"} {
+		metric("pass_link_fetches_total", "result", result)
+	}
+}
+
+var passLinkClient = &http.Client{
+	Transport: &breakerTransport{next: &http.Transport{
+		// No proxy: it would connect on the server's behalf, to anything.
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: publicAddressOnly}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}},
+	CheckRedirect: checkPassLinkRedirect,
+}
+
+// checkPassLinkRedirect holds every redirect to what a link must be: https,
+// no credentials, and a host in passLinkHosts, so a pass link host can't
+// bounce the download to any other host.
+func checkPassLinkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= passLinkMaxRedirects {
+		return fmt.Errorf("more than %d redirects", passLinkMaxRedirects)
+	}
+	if req.URL.Scheme != "https" {
+		return errors.New("redirected off https")
+	}
+	if req.URL.User != nil || !passLinkHost(req.URL.Hostname()) {
+		return fmt.Errorf("redirected to %s, which is not a pass link host", req.URL.Hostname())
+	}
+	return nil
+}
+
+// cgnat is the shared address space of carrier-grade NAT, which
+// netip.Addr.IsPrivate leaves out.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddressOnly refuses connections to loopback, private, link-local
+// and other addresses that aren't on the internet, after DNS resolution,
+// so a link can't reach the server's own network whatever its host
+// resolves to.
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	a := ap.Addr().Unmap()
+	if !a.IsGlobalUnicast() || a.IsPrivate() || cgnat.Contains(a) {
+		return fmt.Errorf("%s is not a public address", a)
+	}
+	return nil
+}
+
+// isPassLink reports whether a barcode is a link rather than barcode text.
+func isPassLink(barcode string) bool {
+	s := strings.ToLower(strings.TrimSpace(barcode))
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// passLinkHost reports whether host is one of passLinkHosts.
+func passLinkHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, h := range passLinkHosts {
+		if sub, ok := strings.CutPrefix(h, "*."); ok {
+			if strings.HasSuffix(host, "."+sub) {
+				return true
+			}
+		} else if host == h {
+			return true
+		}
+	}
+	return false
+}
+
+// loadPassLinkHosts reads PASS_LINK_HOSTS, a comma-separated list; an
+// empty list turns links off.
+func loadPassLinkHosts(v string) ([]string, error) {
+	var hosts []string
+	for _, h := range strings.Split(v, ",") {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" {
+			continue
+		}
+		if strings.ContainsAny(strings.TrimPrefix(h, "*."), "*/:") {
+			return nil, fmt.Errorf("PASS_LINK_HOSTS: %q is not a host name", h)
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// passLinkError is a link that yielded no pass, as the error to send.
+type passLinkError struct {
+	status int
+	result string // pass_link_fetches_total result
+	detail ErrorDetail
+}
+
+func (e *passLinkError) Error() string { return e.detail.Message }
+
+// exportRequired is the passLinkError for a link that doesn't hand out
+// the pass itself.
+func exportRequired(why string) *passLinkError {
+	return &passLinkError{http.StatusUnprocessableEntity, "export_required", ErrorDetail{
+		Reason:      reasonExportRequired,
+		Message:     why + "; export the pass from Wallet or the airline's app and upload the .pkpass",
+		UseEndpoint: "/parse/pkpass",
+	}}
+}
+
+// fetchPassLink downloads the pass at u, which must be a pass link.
+func fetchPassLink(ctx context.Context, u *url.URL) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, passLinkTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", pkpassContentType+", */*;q=0.1")
+	resp, err := passLinkClient.Do(req)
+	if err != nil {
+		// url.Error repeats the URL, which may carry a share token.
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		var nerr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &nerr) && nerr.Timeout()) {
+			return nil, &passLinkError{http.StatusGatewayTimeout, "unreachable", ErrorDetail{
+				Reason:  reasonLinkUnreachable,
+				Message: fmt.Sprintf("The link didn't answer within %s", passLinkTimeout),
+			}}
+		}
+		return nil, &passLinkError{http.StatusBadGateway, "unreachable", ErrorDetail{
+			Reason:  reasonLinkUnreachable,
+			Message: fmt.Sprintf("The link couldn't be downloaded: %v", err),
+		}}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, exportRequired(fmt.Sprintf("The link needs a sign-in (%d)", resp.StatusCode))
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, exportRequired(fmt.Sprintf("The link no longer has a pass (%d)", resp.StatusCode))
+	case resp.StatusCode != http.StatusOK:
+		return nil, &passLinkError{http.StatusBadGateway, "unreachable", ErrorDetail{
+			Reason:  reasonLinkUnreachable,
+			Message: fmt.Sprintf("The link answered %d", resp.StatusCode),
+		}}
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxPkPassUpload+1))
+	switch {
+	case err != nil:
+		return nil, &passLinkError{http.StatusBadGateway, "unreachable", ErrorDetail{
+			Reason:  reasonLinkUnreachable,
+			Message: "The link stopped sending the pass midway",
+		}}
+	case len(b) > maxPkPassUpload:
+		return nil, &passLinkError{http.StatusRequestEntityTooLarge, "too_large", ErrorDetail{
+			Reason:  reasonTooLarge,
+			Message: fmt.Sprintf("The link sent more than %d bytes", maxPkPassUpload),
+		}}
+	}
+	// Sign-in pages and "open in Wallet" pages are usually 200s.
+	kind, ok := sniffUpload(b[:min(len(b), sniffLen)])
+	switch {
+	case ok && kind == kindPkPass:
+		return b, nil
+	case ok:
+		return nil, exportRequired("The link sent " + kind.Label + ", not a pass")
+	case strings.HasPrefix(http.DetectContentType(b), "text/html"):
+		return nil, exportRequired("The link opens a web page, not a pass")
+	}
+	return nil, exportRequired("The link sent something that isn't a pass")
+}
+
+// handlePassLink is /parse/barcode for a barcode that is a link (see
+// isPassLink).
+func handlePassLink(w http.ResponseWriter, r *http.Request, link string) {
+	ctx := r.Context()
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Scheme != "https" || u.User != nil || !passLinkHost(u.Hostname()) {
+		metric("pass_link_fetches_total", "result", "unknown_link").Inc()
+		parseFailed(w, r, http.StatusUnprocessableEntity, ErrorDetail{
+			Reason:      reasonUnknownLink,
+			Message:     "This link isn't a shared pass link this server fetches; export the pass and upload the .pkpass",
+			UseEndpoint: "/parse/pkpass",
+		}, len(link), "")
+		return
+	}
+	if status, d := outputFormat(r.URL.Query()); status != 0 {
+		parseFailed(w, r, status, d, len(link), "")
+		return
+	}
+
+	b, err := fetchPassLink(ctx, u)
+	var lerr *passLinkError
+	if errors.As(err, &lerr) {
+		metric("pass_link_fetches_total", "result", lerr.result).Inc()
+		slog.InfoContext(ctx, "Pass link yielded no pass", "host", u.Host, "status", lerr.status, "err", lerr)
+		parseFailed(w, r, lerr.status, lerr.detail, len(link), "")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching pass link", "host", u.Host, "err", err)
+		httpError(w, "Error fetching pass link", http.StatusInternalServerError)
+		return
+	}
+	metric("pass_link_fetches_total", "result", "ok").Inc()
+
+	size := int64(len(b))
+	sum := sha256.Sum256(b)
+	key := parseKey(ctx, "pkpass", sum[:], r.URL.Query())
+	if serveCached(w, r, key) {
+		return
+	}
+	release, ok := acquireHeavy(w, r, 1)
+	if !ok {
+		return
+	}
+	data, err := parsePKPass(ctx, bytes.NewReader(b), size)
+	release()
+	if err != nil {
+		parseFailed(w, r, http.StatusUnprocessableEntity,
+			ErrorDetail{Reason: reasonInvalidPkPass, Message: fmt.Sprintf("Error parsing pkpass: %v", err)},
+			len(b), bytesSample(r.URL.Query(), b[:min(len(b), sniffLen)]))
+		return
+	}
+	if wantDetail(r.URL.Query()) {
+		addPkPassDetail(data, bytes.NewReader(b), size)
+	}
+	r = withArtifact(r, &artifact{r: bytes.NewReader(b), size: size, sum: sum, contentType: pkpassContentType})
+	r = r.WithContext(withWebService(r.Context(), bytes.NewReader(b), size))
+	respondWithPass(w, r, data, key)
+}
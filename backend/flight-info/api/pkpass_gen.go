@@ -0,0 +1,340 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/smallstep/pkcs7"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/internal/refdata"
+)
+
+// ----------------------
+// GENERATE: APPLE WALLET .PKPASS
+// ----------------------
+
+// pkpassSigner is nil unless PKPASS_CERT and PKPASS_KEY are configured.
+var pkpassSigner *PassSigner
+
+// pkpassTypeID and pkpassTeamID are PKPASS_TYPE_ID and PKPASS_TEAM_ID,
+// which generated passes carry whether signed or not.
+var (
+	pkpassTypeID = defaultConfig.PkPassTypeID
+	pkpassTeamID = defaultConfig.PkPassTeamID
+)
+
+// PassSigner holds the pass type certificate and key that sign generated
+// .pkpass manifests.
+type PassSigner struct {
+	cert  *x509.Certificate
+	key   crypto.PrivateKey
+	chain []*x509.Certificate // Apple WWDR intermediate
+}
+
+// loadPassSigner reads the pass type certificate, its private key, and the
+// optional WWDR intermediate, all PEM encoded.
+func loadPassSigner(certPath, keyPath, wwdrPath string) (*PassSigner, error) {
+	certs, err := readPEMCertificates(certPath)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", keyPath)
+	}
+	key, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", keyPath, err)
+	}
+
+	s := &PassSigner{cert: certs[0], key: key, chain: certs[1:]}
+	if wwdrPath != "" {
+		wwdr, err := readPEMCertificates(wwdrPath)
+		if err != nil {
+			return nil, err
+		}
+		s.chain = append(s.chain, wwdr...)
+	}
+	return s, nil
+}
+
+func readPEMCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s: no certificates found", path)
+	}
+	return certs, nil
+}
+
+func parsePrivateKey(der []byte) (crypto.PrivateKey, error) {
+	if k, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParseECPrivateKey(der); err == nil {
+		return k, nil
+	}
+	return nil, errors.New("unsupported private key format")
+}
+
+// sign produces the detached PKCS#7 signature over manifest.json that
+// Wallet expects in the "signature" entry.
+func (s *PassSigner) sign(manifest []byte) ([]byte, error) {
+	sd, err := pkcs7.NewSignedData(manifest)
+	if err != nil {
+		return nil, err
+	}
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := sd.AddSignerChain(s.cert, s.key, s.chain, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, err
+	}
+	sd.Detach()
+	return sd.Finish()
+}
+
+type pkpassField struct {
+	Key   string `json:"key"`
+	Label string `json:"label,omitempty"`
+	Value string `json:"value"`
+}
+
+type pkpassBarcode struct {
+	Format          string `json:"format"`
+	Message         string `json:"message"`
+	MessageEncoding string `json:"messageEncoding"`
+	AltText         string `json:"altText,omitempty"`
+}
+
+// buildPassJSON maps a pass onto Wallet's boardingPass style: airports as
+// primary fields, passenger and date as secondary, seat/gate/class as
+// auxiliary. The barcode reuses the original BCBP string when the pass came
+// from a barcode and re-encodes the parsed fields otherwise.
+func buildPassJSON(p *bcbp.UnifiedBoardingPass, typeID, teamID string) ([]byte, error) {
+	serial := p.ID
+	if serial == "" {
+		serial = passID(p)
+	}
+
+	message, _ := bcbpText(p)
+
+	org := strings.TrimSpace(p.Carrier)
+	if org == "" {
+		org = "Flight Info"
+	}
+
+	var primary, secondary, auxiliary, back, header []pkpassField
+	add := func(list *[]pkpassField, key, label, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			*list = append(*list, pkpassField{Key: key, Label: label, Value: value})
+		}
+	}
+
+	add(&header, "flight", "FLIGHT", strings.TrimSpace(p.Carrier)+strings.TrimLeft(p.FlightNumber, "0"))
+	add(&primary, "origin", airportLabel(p.Departure), p.Departure)
+	add(&primary, "destination", airportLabel(p.Arrival), p.Arrival)
+	add(&secondary, "passenger", "PASSENGER", p.PassengerName)
+	add(&secondary, "date", "DATE", p.DateISO)
+	add(&secondary, "boardingTime", "BOARDING", p.BoardingTime)
+	add(&auxiliary, "seat", "SEAT", p.Seat)
+	add(&auxiliary, "gate", "GATE", p.RawData["gate"])
+	add(&auxiliary, "class", "CLASS", p.CabinClass)
+	add(&back, "pnr", "BOOKING REFERENCE", p.PNR)
+
+	pass := map[string]interface{}{
+		"formatVersion":      1,
+		"passTypeIdentifier": typeID,
+		"teamIdentifier":     teamID,
+		"serialNumber":       serial,
+		"organizationName":   org,
+		"description":        "Boarding pass " + icsSummary(p),
+		"foregroundColor":    "rgb(255, 255, 255)",
+		"backgroundColor":    "rgb(0, 57, 107)",
+		"labelColor":         "rgb(200, 220, 255)",
+		"boardingPass": map[string]interface{}{
+			"transitType":     "PKTransitTypeAir",
+			"headerFields":    nonNilFields(header),
+			"primaryFields":   nonNilFields(primary),
+			"secondaryFields": nonNilFields(secondary),
+			"auxiliaryFields": nonNilFields(auxiliary),
+			"backFields":      nonNilFields(back),
+		},
+		"barcodes": []pkpassBarcode{{
+			Format:          "PKBarcodeFormatAztec",
+			Message:         message,
+			MessageEncoding: "iso-8859-1",
+		}},
+	}
+	if t, err := time.Parse(time.DateOnly, p.DateISO); err == nil {
+		pass["relevantDate"] = t.Format(time.RFC3339)
+	}
+	return json.MarshalIndent(pass, "", "  ")
+}
+
+func nonNilFields(f []pkpassField) []pkpassField {
+	if f == nil {
+		return []pkpassField{}
+	}
+	return f
+}
+
+func airportLabel(code string) string {
+	if a, ok := refdata.LookupAirport(code); ok {
+		return strings.ToUpper(a.City)
+	}
+	return ""
+}
+
+// placeholderPNG is a solid-colour image standing in for the carrier's
+// artwork; Wallet refuses passes without an icon.
+func placeholderPNG(w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	fill := color.RGBA{R: 0, G: 57, B: 107, A: 255}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, fill)
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// buildPKPass assembles the .pkpass zip: pass.json, images, manifest.json
+// with SHA-1 digests of every file, and — when a signer is given — the
+// detached signature over the manifest.
+func buildPKPass(p *bcbp.UnifiedBoardingPass, signer *PassSigner, typeID, teamID string) ([]byte, error) {
+	passJSON, err := buildPassJSON(p, typeID, teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{
+		"pass.json":   passJSON,
+		"icon.png":    placeholderPNG(29, 29),
+		"icon@2x.png": placeholderPNG(58, 58),
+		"icon@3x.png": placeholderPNG(87, 87),
+		"logo.png":    placeholderPNG(160, 50),
+		"logo@2x.png": placeholderPNG(320, 100),
+		"logo@3x.png": placeholderPNG(480, 150),
+	}
+
+	manifest := map[string]string{}
+	for name, data := range files {
+		sum := sha1.Sum(data)
+		manifest[name] = hex.EncodeToString(sum[:])
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files["manifest.json"] = manifestJSON
+
+	if signer != nil {
+		sig, err := signer.sign(manifestJSON)
+		if err != nil {
+			return nil, fmt.Errorf("signing manifest: %w", err)
+		}
+		files["signature"] = sig
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		fw, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func handleGeneratePkPass(w http.ResponseWriter, r *http.Request) {
+	var pass bcbp.UnifiedBoardingPass
+	if err := json.NewDecoder(r.Body).Decode(&pass); err != nil {
+		httpError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	// Wallet won't install an unsigned pass, so producing one must be asked for.
+	unsigned := r.URL.Query().Get("unsigned") == "true"
+	if pkpassSigner == nil && !unsigned {
+		httpError(w, "No signing certificate configured (set PKPASS_CERT and PKPASS_KEY); "+
+			"use ?unsigned=true to generate an unsigned test pass", http.StatusNotImplemented)
+		return
+	}
+	signer := pkpassSigner
+	if unsigned {
+		signer = nil
+	}
+
+	release, ok := acquireHeavy(w, r, 1)
+	if !ok {
+		return
+	}
+	data, err := buildPKPass(&pass, signer, pkpassTypeID, pkpassTeamID)
+	release()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating pkpass", "err", err)
+		httpError(w, fmt.Sprintf("Error generating pkpass: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apple.pkpass")
+	w.Header().Set("Content-Disposition", `attachment; filename="boardingpass.pkpass"`)
+	w.Write(data)
+}
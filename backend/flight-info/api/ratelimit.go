@@ -0,0 +1,277 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// ----------------------
+// PER-CLIENT RATE LIMITING
+// ----------------------
+
+// lightRate and heavyRate are nil when their limit is 0. Heavy requests (the
+// ones behind the heavy-work limiter) only count against heavyRate, so a
+// client polling /passes doesn't lose its image uploads and vice versa.
+var lightRate, heavyRate *rateLimiter
+
+// trustedProxies are the networks whose X-Forwarded-For is believed. Empty
+// means the connection's address is always the client.
+var trustedProxies []netip.Prefix
+
+// unixPeerAddr is the RemoteAddr of a client connected over a Unix socket,
+// which has no address of its own.
+const unixPeerAddr = "@"
+
+// heavyRPCs are the gRPC methods in the heavy tier.
+var heavyRPCs = map[string]bool{
+	"/flightinfo.v1.FlightInfo/ParseBarcodeImage": true,
+	"/flightinfo.v1.FlightInfo/ParsePkPass":       true,
+	"/flightinfo.v1.FlightInfo/ScanFrames":        true,
+}
+
+func init() {
+	describeMetric("rate_limited_total", "counter", "Requests rejected by the per-client rate limit, by tier.")
+	describeMetric("rate_limit_clients", "gauge", "Clients with a rate-limit bucket, by tier.")
+}
+
+// rateLimiter is a token bucket per client: each holds up to burst tokens
+// and refills at rate per second; a request takes one.
+type rateLimiter struct {
+	tier    string
+	rate    float64
+	burst   float64
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter allows perMinute requests a minute per client, in bursts
+// of up to burst.
+func newRateLimiter(tier string, perMinute, burst int) *rateLimiter {
+	metric("rate_limited_total", "tier", tier)
+	metric("rate_limit_clients", "tier", tier)
+	return &rateLimiter{
+		tier:    tier,
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// tierRateLimiter is the limiter for RATE_LIMIT_<TIER> (requests per
+// minute per client, 0 to disable) and RATE_LIMIT_<TIER>_BURST (0 for ten
+// seconds' worth), which checkSettings has already bounded. It returns nil
+// when the tier is disabled.
+func tierRateLimiter(tier string, perMinute, burst int) *rateLimiter {
+	if perMinute == 0 {
+		return nil
+	}
+	if burst == 0 {
+		burst = max(1, perMinute/6)
+	}
+	return newRateLimiter(tier, perMinute, burst)
+}
+
+// allow takes a token from client's bucket. When it is empty, it returns
+// how long until the next token.
+func (l *rateLimiter) allow(client string, now time.Time) (ok bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.buckets[client]
+	if b == nil {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+		metric("rate_limit_clients", "tier", l.tier).Inc()
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		metric("rate_limited_total", "tier", l.tier).Inc()
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// prune drops the buckets that have refilled completely: a new bucket would
+// be full too, so forgetting them changes nothing.
+func (l *rateLimiter) prune(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+			metric("rate_limit_clients", "tier", l.tier).Dec()
+		}
+	}
+}
+
+// pruneRateLimits prunes idle buckets every interval until ctx is cancelled,
+// so memory doesn't grow with every address ever seen.
+func pruneRateLimits(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, l := range []*rateLimiter{lightRate, heavyRate} {
+				if l != nil {
+					l.prune(now)
+				}
+			}
+		}
+	}
+}
+
+// retrySeconds rounds a wait up to whole seconds for Retry-After.
+func retrySeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
+
+// rateLimitMiddleware rejects requests over the client's limit in the light
+// or heavy tier with 429 and Retry-After. It runs inside CORS, so browsers
+// can read the rejection, and preflights aren't counted.
+func rateLimitMiddleware(heavy bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := lightRate
+		if heavy {
+			l = heavyRate
+		}
+		if l == nil {
+			next(w, r)
+			return
+		}
+		if ok, wait := l.allow(clientIP(r.RemoteAddr, r.Header.Values("X-Forwarded-For")), time.Now()); !ok {
+			retry := retrySeconds(wait)
+			httpRetryError(w, fmt.Sprintf("Rate limit exceeded, retry in %ds", retry), http.StatusTooManyRequests, retry)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// rateLimitUnary and rateLimitStream apply the same limits to gRPC calls,
+// keyed by peer address (or x-forwarded-for metadata from a trusted proxy),
+// with RESOURCE_EXHAUSTED. A ScanFrames stream counts once.
+func rateLimitUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := allowRPC(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func rateLimitStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := allowRPC(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func allowRPC(ctx context.Context, method string) error {
+	l := lightRate
+	if heavyRPCs[method] {
+		l = heavyRate
+	}
+	if l == nil {
+		return nil
+	}
+	var remote string
+	if p, ok := peer.FromContext(ctx); ok {
+		remote = p.Addr.String()
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ok, wait := l.allow(clientIP(remote, md.Get("x-forwarded-for")), time.Now()); !ok {
+		return status.Errorf(codes.ResourceExhausted, "Rate limit exceeded, retry in %ds", retrySeconds(wait))
+	}
+	return nil
+}
+
+// clientIP is the address the limits are keyed on: the connection's, or,
+// when that is a trusted proxy, the nearest untrusted hop of
+// X-Forwarded-For. Hops are read right to left because only the ones our
+// own proxies appended can be believed. A peer on the LISTEN_SOCKET Unix
+// socket is always a trusted hop: only a local process can connect there.
+func clientIP(remoteAddr string, forwarded []string) string {
+	var addr netip.Addr
+	if remoteAddr != unixPeerAddr {
+		host, _, err := net.SplitHostPort(remoteAddr)
+		if err != nil {
+			host = remoteAddr
+		}
+		addr, err = netip.ParseAddr(host)
+		if err != nil || !isTrustedProxy(addr) {
+			return host
+		}
+	}
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		if !isTrustedProxy(hop) {
+			return hop.Unmap().String()
+		}
+		addr = hop
+	}
+	// Every hop was a proxy, or the header was malformed: use the last proxy.
+	if !addr.IsValid() {
+		return remoteAddr
+	}
+	return addr.Unmap().String()
+}
+
+func isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies reads a comma-separated list of CIDRs; a bare address
+// is a single host.
+func parseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+			}
+			out = append(out, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
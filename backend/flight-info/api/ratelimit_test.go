@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/netip"
+	"testing"
+)
+
+// TestClientIP checks which address the limits are keyed on, including a
+// proxy in front of LISTEN_SOCKET, whose connections have no address.
+func TestClientIP(t *testing.T) {
+	setForTest(t, &trustedProxies, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{name: "direct", remote: "198.51.100.4:5123", forwarded: []string{"203.0.113.7"}, want: "198.51.100.4"},
+		{name: "trusted proxy", remote: "10.0.0.2:5123", forwarded: []string{"203.0.113.7"}, want: "203.0.113.7"},
+		{name: "proxy chain", remote: "10.0.0.2:5123", forwarded: []string{"198.51.100.9, 203.0.113.7, 10.0.0.3"}, want: "203.0.113.7"},
+		{name: "unix socket", remote: "@", forwarded: []string{"203.0.113.7"}, want: "203.0.113.7"},
+		{name: "unix socket via proxy", remote: "@", forwarded: []string{"203.0.113.7, 10.0.0.3"}, want: "203.0.113.7"},
+		{name: "unix socket without header", remote: "@", want: "@"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clientIP(tt.remote, tt.forwarded); got != tt.want {
+				t.Errorf("clientIP(%q, %q) = %q, want %q", tt.remote, tt.forwarded, got, tt.want)
+			}
+		})
+	}
+}
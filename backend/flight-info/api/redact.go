@@ -0,0 +1,184 @@
+package api
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/eticket"
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// PRIVACY: PII REDACTION
+// ----------------------
+
+// redactAll is set from REDACT_PII. When on, passes are redacted before they
+// are stored or sent to webhooks, not just in the response.
+var redactAll bool
+
+// piiRawKeys are RawData keys (matched as substrings of the lowercased key)
+// whose values identify the passenger; they are dropped rather than masked.
+// Keys that also contain a piiSafeKeys word ("airlineName") are kept,
+// unless the carrier's profile reads personal data from them (see
+// isPIIKey).
+var (
+	piiRawKeys = []string{
+		"passenger", "name", "pnr", "record", "confirmation", "booking",
+		"frequent_flyer_number", "frequentflyer", "ffnumber", "loyalty", "member",
+		"birth", "ktn", "redress",
+	}
+	piiSafeKeys = []string{"airline", "airport", "carrier", "city"}
+)
+
+func wantRedaction(q url.Values) bool {
+	return redactAll || q.Get("redact") == "true"
+}
+
+// RedactPass masks the passenger name (first letter of the surname kept)
+// and PNR (last two characters kept), on every leg of a multi-leg pass,
+// drops the date of birth and identifying RawData and leg conditional
+// entries (frequent flyer and traveler numbers, the airline use data of a
+// carrier with a profile ...) with their field sources, and masks any
+// remaining occurrence of those values anywhere else in RawData, including
+// the raw barcode, in warnings and in the parser detail. Masks keep the
+// original length, so raw_string stays a well-formed fixed-width BCBP
+// string. Operational fields (flight, airports, date, seat) are untouched.
+//
+// The pass ID is derived before masking so duplicate detection keeps
+// working on redacted stores.
+func RedactPass(p *bcbp.UnifiedBoardingPass) {
+	storage.ID(p)
+
+	var secrets []string
+	addSecret := func(v string) {
+		// Very short values would mask unrelated text in the raw barcode.
+		if v = strings.TrimSpace(v); len(v) >= 3 {
+			secrets = append(secrets, v)
+		}
+	}
+	addSecret(p.PassengerName)
+	addSecret(p.PNR)
+	legs := [][]bcbp.PassengerLeg{p.Passengers, p.Legs}
+	for _, ll := range legs {
+		for _, l := range ll {
+			addSecret(l.PassengerName)
+			addSecret(l.PNR)
+			personal := bcbp.PersonalAirlineUseKeys(l.Carrier)
+			for k, v := range l.Conditional {
+				if isPIIKey(k, personal) {
+					addSecret(v)
+					delete(l.Conditional, k)
+				}
+			}
+		}
+	}
+	personal := bcbp.PersonalAirlineUseKeys(p.Carrier)
+	for k, v := range p.RawData {
+		if isPIIKey(k, personal) {
+			addSecret(v)
+			delete(p.RawData, k)
+			delete(p.FieldSources, k)
+		}
+	}
+	delete(p.FieldSources, "date_of_birth")
+
+	p.PassengerName = maskName(p.PassengerName)
+	p.PNR = maskKeepLast(p.PNR, 2)
+	p.DateOfBirth = ""
+	for _, ll := range legs {
+		for i, l := range ll {
+			ll[i].PassengerName = maskName(l.PassengerName)
+			ll[i].PNR = maskKeepLast(l.PNR, 2)
+		}
+	}
+
+	// Longest first: a value inside another (the KTN in the airline use
+	// data) would otherwise break up the longer one before it is masked.
+	slices.SortFunc(secrets, func(a, b string) int { return len(b) - len(a) })
+	mask := func(v string) string {
+		for _, s := range secrets {
+			v = strings.ReplaceAll(v, s, strings.Repeat("*", len(s)))
+		}
+		return v
+	}
+	for k, v := range p.RawData {
+		p.RawData[k] = mask(v)
+	}
+	for i, w := range p.Warnings {
+		p.Warnings[i] = mask(w)
+	}
+	for _, ll := range legs {
+		for _, l := range ll {
+			for k, v := range l.Conditional {
+				l.Conditional[k] = mask(v)
+			}
+		}
+	}
+	if d := p.Detail; d != nil {
+		for i, f := range d.Fields {
+			d.Fields[i].Raw, d.Fields[i].Value = mask(f.Raw), mask(f.Value)
+		}
+		if d.PassJSON != nil {
+			d.PassJSON = []byte(mask(string(d.PassJSON)))
+		}
+		for i, c := range d.Decode {
+			d.Decode[i].Text = mask(c.Text)
+		}
+	}
+}
+
+// isPIIKey reports whether the RawData key identifies the passenger.
+// personal are the keys the carrier's profile reads personal data from
+// (bcbp.PersonalAirlineUseKeys); they are never taken as safe.
+func isPIIKey(key string, personal []string) bool {
+	if slices.Contains(personal, key) {
+		return true
+	}
+	key = strings.ToLower(key)
+	for _, k := range piiSafeKeys {
+		if strings.Contains(key, k) {
+			return false
+		}
+	}
+	for _, k := range piiRawKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskName turns "SILVA/JOAO MR" into "S****/**** **".
+func maskName(name string) string {
+	seenFirst := false
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == ' ' {
+			return r
+		}
+		if !seenFirst {
+			seenFirst = true
+			return r
+		}
+		return '*'
+	}, name)
+}
+
+// redactReceipt masks the document number of a ticket, last two digits
+// kept as for a PNR, and drops the check digit that would give it away.
+// The airline prefix stays.
+func redactReceipt(r *eticket.Receipt) {
+	r.DocumentNumber = maskKeepLast(r.DocumentNumber, 2)
+	r.TicketNumber = r.AirlinePrefix + r.DocumentNumber
+	r.CheckDigit = ""
+}
+
+// maskKeepLast masks all but the last n runes.
+func maskKeepLast(v string, n int) string {
+	r := []rune(v)
+	for i := 0; i < len(r)-n; i++ {
+		r[i] = '*'
+	}
+	return string(r)
+}
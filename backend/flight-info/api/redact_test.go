@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedactedResponseHasNoPII(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		patch   *strings.Replacer // applied to the fixture, keeping its length
+		legs    int
+		pii     []string
+	}{
+		{"aa profile", "bcbp/aa-dfw-ord-dob-ktn-redress.bcbp", nil, 0, []string{"GARCIA", "MIGUEL", "XK7RPL", "4GH82K1", "98765432A", "1234567", "19780923", "1978-09-23"}},
+		{"two legs", "bcbp/lh-ber-fra-jfk-two-legs.bcbp", nil, 2, []string{"MUELLER", "ANNA", "KLM4PQ", "992001234567890"}},
+		// Malformed values are skipped with a warning and left in the
+		// airline use data, which is masked whole for a profiled carrier.
+		{"dl malformed dob", "bcbp/dl-atl-jfk-bad-dob.bcbp", nil, 0, []string{"JOHNSON", "EMILY", "GQ4TZB", "1403198", "TT1234567"}},
+		{"dl invalid dob and ktn", "bcbp/dl-atl-jfk-bad-dob.bcbp", strings.NewReplacer("1403198TT1234567", "31FEB85TT12-4567"), 0, []string{"JOHNSON", "EMILY", "GQ4TZB", "31FEB85", "TT12-4567"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := readFixture(t, tt.fixture)
+			if tt.patch != nil {
+				in = tt.patch.Replace(in)
+			}
+			w := postBarcode(t, Handler(), "/parse/barcode?redact=true&detail=true", in)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var resp struct {
+				RawData      map[string]string `json:"raw_extra_data"`
+				Legs         []json.RawMessage `json:"legs"`
+				FieldSources map[string]string `json:"field_sources"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.RawData["raw_string"] == "" {
+				t.Fatal("no raw_string")
+			}
+			if len(resp.Legs) != tt.legs {
+				t.Fatalf("%d legs, want %d", len(resp.Legs), tt.legs)
+			}
+			for _, k := range []string{"date_of_birth", "ktn", "redress_number", "airline_use"} {
+				if _, ok := resp.FieldSources[k]; ok {
+					t.Errorf("field_sources has %s", k)
+				}
+			}
+			for _, v := range tt.pii {
+				if strings.Contains(resp.RawData["raw_string"], v) {
+					t.Errorf("raw_string has %q: %s", v, resp.RawData["raw_string"])
+				}
+				for i, l := range resp.Legs {
+					if strings.Contains(string(l), v) {
+						t.Errorf("leg %d has %q: %s", i+1, v, l)
+					}
+				}
+				if strings.Contains(w.Body.String(), v) {
+					t.Errorf("response has %q", v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/pkpass"
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// HANDLERS: WALLET PASS REFRESH
+// ----------------------
+
+// Airlines update a Wallet pass when the gate or seat changes, and a pass
+// that says where its web service is can be asked for its latest version.
+// When a .pkpass is stored, its web service (pkpass.WebService) is kept
+// with it, sealed (see secretBox); POST /passes/{id}/refresh then fetches
+// the latest version from the issuer, parses it, replaces the stored pass
+// and reports which fields changed. The pass keeps its ID: it is the same
+// Wallet pass, even if the flight on it changed. The web service never
+// leaves the server: it is not in pass responses, backups or logs, which
+// show its host at most.
+
+// passRefreshTimeout bounds one request to a pass's web service;
+// PASS_REFRESH_TIMEOUT.
+var passRefreshTimeout = defaultConfig.PassRefreshTimeout
+
+var passRefreshClient = newUpstreamClient(0)
+
+func init() {
+	describeMetric("pass_refreshes_total", "counter", "POST /passes/{id}/refresh by result.")
+	for _, result := range []string{"updated", "unchanged", "not_modified", "unreachable", "rejected", "invalid"} {
+		metric("pass_refreshes_total", "result", result)
+	}
+}
+
+type webServiceKey struct{}
+
+// withWebService attaches the web service of the .pkpass ctx parses, size
+// bytes read through r, for processPass to keep once the pass is stored.
+func withWebService(ctx context.Context, r io.ReaderAt, size int64) context.Context {
+	if passStore == nil || passSecrets == nil {
+		return ctx
+	}
+	ws, ok := pkpass.WebServiceReader(r, size)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, webServiceKey{}, &ws)
+}
+
+// storeWebService seals the web service attached to ctx, if any, onto p's
+// stored record. A duplicate scan only adds one to a record that has none.
+// Failures are logged but never fail the parse request.
+func storeWebService(ctx context.Context, p *bcbp.UnifiedBoardingPass) {
+	ws, _ := ctx.Value(webServiceKey{}).(*pkpass.WebService)
+	if ws == nil || passStore == nil || passSecrets == nil {
+		return
+	}
+	if err := saveWebService(p.ID, *ws, "", !p.Duplicate); err != nil {
+		slog.ErrorContext(ctx, "Error storing pass web service", "pass", p.ID, "web_service", *ws, "err", err)
+	}
+}
+
+// saveWebService seals ws onto the stored pass id (see storage.Store.SetWebService).
+func saveWebService(id string, ws pkpass.WebService, modified string, replace bool) error {
+	b, err := json.Marshal(ws)
+	if err != nil {
+		return err
+	}
+	return passStore.SetWebService(id, passSecrets.seal(b, id), modified, replace)
+}
+
+// loadWebService opens the web service stored with pass id; ok is false
+// when it has none.
+func loadWebService(id string) (ws pkpass.WebService, modified string, ok bool, err error) {
+	sealed, modified, err := passStore.WebService(id)
+	if err != nil || sealed == "" {
+		return ws, "", false, err
+	}
+	b, err := passSecrets.open(sealed, id)
+	if err != nil {
+		return ws, "", false, err
+	}
+	if err := json.Unmarshal(b, &ws); err != nil {
+		return ws, "", false, err
+	}
+	return ws, modified, true, nil
+}
+
+// PassRefresh is the body of POST /passes/{id}/refresh.
+type PassRefresh struct {
+	// Status is "updated" when the issuer sent a version that changed
+	// fields, "unchanged" when it sent one that didn't, and "not_modified"
+	// when it had nothing newer (a 304).
+	Status  string              `json:"status"`
+	Changes []bcbp.FieldChange  `json:"changes"`
+	Pass    *storage.StoredPass `json:"pass"`
+}
+
+// Reasons for a failed refresh: the issuer's web service couldn't be
+// reached (502, or 504 on a timeout), refused the request (502), or sent
+// something that isn't a pass (502).
+const (
+	reasonNoWebService      = "no_web_service"     // 409: the stored pass has no web service
+	reasonIssuerUnreachable = "issuer_unreachable" // 502, 504
+	reasonIssuerRejected    = "issuer_rejected"    // 502: the issuer answered 401, 404, ...
+	reasonIssuerInvalid     = "issuer_invalid"     // 502: the issuer's answer isn't a readable pass
+)
+
+// refreshError is a failed fetch from a web service, as the error to send.
+type refreshError struct {
+	status int
+	result string // pass_refreshes_total result
+	detail ErrorDetail
+}
+
+func (e *refreshError) Error() string { return e.detail.Message }
+
+// fetchLatestPass asks ws for the pass's latest version, conditional on
+// modified (a Last-Modified it sent before). pass is nil for a 304.
+func fetchLatestPass(ctx context.Context, ws pkpass.WebService, modified string) (pass []byte, lastModified string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, passRefreshTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ws.LatestURL(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", ws.AuthorizationHeader())
+	if modified != "" {
+		req.Header.Set("If-Modified-Since", modified)
+	}
+	resp, err := passRefreshClient.Do(req)
+	if err != nil {
+		// url.Error repeats the URL, which is part of the credentials.
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		var nerr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &nerr) && nerr.Timeout()) {
+			return nil, "", &refreshError{http.StatusGatewayTimeout, "unreachable", ErrorDetail{
+				Reason:  reasonIssuerUnreachable,
+				Message: fmt.Sprintf("The pass's web service didn't answer within %s", passRefreshTimeout),
+			}}
+		}
+		return nil, "", &refreshError{http.StatusBadGateway, "unreachable", ErrorDetail{
+			Reason:  reasonIssuerUnreachable,
+			Message: fmt.Sprintf("The pass's web service couldn't be reached: %v", err),
+		}}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return nil, modified, nil
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, "", &refreshError{http.StatusBadGateway, "rejected", ErrorDetail{
+			Reason:  reasonIssuerRejected,
+			Message: fmt.Sprintf("The pass's web service no longer accepts its authentication token (%d)", resp.StatusCode),
+		}}
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, "", &refreshError{http.StatusBadGateway, "rejected", ErrorDetail{
+			Reason:  reasonIssuerRejected,
+			Message: fmt.Sprintf("The pass's web service no longer has this pass (%d)", resp.StatusCode),
+		}}
+	case resp.StatusCode != http.StatusOK:
+		return nil, "", &refreshError{http.StatusBadGateway, "rejected", ErrorDetail{
+			Reason:  reasonIssuerRejected,
+			Message: fmt.Sprintf("The pass's web service answered %d", resp.StatusCode),
+		}}
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxPkPassUpload+1))
+	switch {
+	case err != nil:
+		return nil, "", &refreshError{http.StatusBadGateway, "unreachable", ErrorDetail{
+			Reason:  reasonIssuerUnreachable,
+			Message: "The pass's web service stopped sending the pass midway",
+		}}
+	case len(b) > maxPkPassUpload:
+		return nil, "", &refreshError{http.StatusBadGateway, "invalid", ErrorDetail{
+			Reason:  reasonIssuerInvalid,
+			Message: fmt.Sprintf("The pass's web service sent more than %d bytes", maxPkPassUpload),
+		}}
+	}
+	return b, resp.Header.Get("Last-Modified"), nil
+}
+
+func handlePassRefresh(w http.ResponseWriter, r *http.Request) {
+	if passStore == nil {
+		httpError(w, "Persistence is disabled (set SQLITE_PATH or DATABASE_URL)", http.StatusNotImplemented)
+		return
+	}
+	if passSecrets == nil {
+		httpError(w, "Pass refresh is disabled (set PASS_SECRET_KEY)", http.StatusNotImplemented)
+		return
+	}
+	if status, d := outputFormat(r.URL.Query()); status != 0 {
+		writeError(w, status, d)
+		return
+	}
+	ctx := r.Context()
+	id := r.PathValue("id")
+	sp, err := passStore.Get(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		httpError(w, "Pass not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching pass", "pass", id, "err", err)
+		httpError(w, "Error fetching pass", http.StatusInternalServerError)
+		return
+	}
+	ws, modified, ok, err := loadWebService(id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error reading pass web service", "pass", id, "err", err)
+		httpError(w, "Error reading the pass's web service", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusConflict, ErrorDetail{
+			Reason:  reasonNoWebService,
+			Message: "This pass has no web service to refresh from; only Wallet passes with a webServiceURL do",
+		})
+		return
+	}
+
+	b, lastModified, err := fetchLatestPass(ctx, ws, modified)
+	var rerr *refreshError
+	if errors.As(err, &rerr) {
+		metric("pass_refreshes_total", "result", rerr.result).Inc()
+		slog.WarnContext(ctx, "Pass refresh failed", "pass", id, "web_service", ws, "status", rerr.status, "err", rerr)
+		writeError(w, rerr.status, rerr.detail)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error refreshing pass", "pass", id, "web_service", ws, "err", err)
+		httpError(w, "Error refreshing pass", http.StatusInternalServerError)
+		return
+	}
+
+	result := PassRefresh{Status: "not_modified", Changes: []bcbp.FieldChange{}, Pass: sp}
+	if b != nil {
+		p, err := pkpass.ParseReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			metric("pass_refreshes_total", "result", "invalid").Inc()
+			slog.WarnContext(ctx, "Pass refresh failed", "pass", id, "web_service", ws, "err", err)
+			writeError(w, http.StatusBadGateway, ErrorDetail{
+				Reason:  reasonIssuerInvalid,
+				Message: fmt.Sprintf("The pass's web service sent something that isn't a readable pass: %v", err),
+			})
+			return
+		}
+		p.ID = id
+		EnrichPass(ctx, p, url.Values{})
+		if redactAll {
+			RedactPass(p)
+		}
+		stored, changes, err := passStore.Upsert(p)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error storing pass", "pass", id, "err", err)
+			httpError(w, "Error storing pass", http.StatusInternalServerError)
+			return
+		}
+		result.Pass = stored
+		result.Status = "unchanged"
+		if len(changes) > 0 {
+			result.Changes = changes
+			result.Status = "updated"
+		}
+		// The new version may come with a new token.
+		if next, ok := pkpass.WebServiceReader(bytes.NewReader(b), int64(len(b))); ok {
+			ws = next
+		}
+		if result.Status == "updated" {
+			notifyWebhooks(p)
+		}
+	}
+	if err := saveWebService(id, ws, lastModified, true); err != nil {
+		slog.ErrorContext(ctx, "Error storing pass web service", "pass", id, "web_service", ws, "err", err)
+	}
+	metric("pass_refreshes_total", "result", result.Status).Inc()
+
+	shapeStored(r, result.Pass)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// RETENTION: DELETING EXPIRED PASSES
+// ----------------------
+
+// passJanitor is nil unless both persistence and PASS_RETENTION are set.
+var passJanitor *PassJanitor
+
+func init() {
+	describeMetric("passes_expired_total", "counter", "Stored passes deleted by the retention policy.")
+	metric("passes_expired_total")
+}
+
+// PassJanitor applies the retention policy on an interval.
+type PassJanitor struct {
+	store    storage.Store
+	ttl      time.Duration
+	interval time.Duration
+	mu       sync.Mutex // one cleanup at a time, scheduled or manual
+}
+
+func newPassJanitor(store storage.Store, ttl, interval time.Duration) *PassJanitor {
+	return &PassJanitor{store: store, ttl: ttl, interval: interval}
+}
+
+// Run cleans up every interval until ctx is cancelled.
+func (j *PassJanitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := j.Clean(time.Now()); err != nil {
+			slog.Error("Error deleting expired passes", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Clean deletes the passes expired at now and returns how many went. On
+// error the count covers the batches that were committed.
+func (j *PassJanitor) Clean(now time.Time) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	n, err := j.store.DeleteExpired(j.ttl, now)
+	metric("passes_expired_total").Add(int64(n))
+	if n > 0 {
+		slog.Info("Deleted expired passes", "count", n, "ttl", j.ttl)
+		pruneArtifacts(context.Background())
+	}
+	return n, err
+}
+
+// CleanupReport is the body of POST /admin/cleanup.
+type CleanupReport struct {
+	Deleted int    `json:"deleted"`
+	TTL     string `json:"ttl"`
+}
+
+func handleCleanup(w http.ResponseWriter, r *http.Request) {
+	if passJanitor == nil {
+		httpError(w, "Retention is disabled (set SQLITE_PATH or DATABASE_URL, and PASS_RETENTION)", http.StatusNotImplemented)
+		return
+	}
+
+	n, err := passJanitor.Clean(time.Now())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting expired passes", "deleted", n, "err", err)
+		httpError(w, "Error deleting expired passes", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CleanupReport{Deleted: n, TTL: passJanitor.ttl.String()})
+}
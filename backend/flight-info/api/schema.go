@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// SCHEMA VERSIONS (/v1)
+// ----------------------
+
+// Every route is also served under /v1. The handlers are the same; the
+// only difference is the pass JSON, which under /v1 is schema version 1:
+// empty fields are left out and schema_version is 1. Unprefixed routes keep
+// version 0, where the core string fields are always present.
+// Webhooks and stored passes keep version 0 too, with raw_extra_data.
+// bcbp.WireSchemas registers the fields of each version.
+
+type schemaVersionKey struct{}
+
+// v1Handler serves /v1/... by handing the rest of the path back to mux.
+func v1Handler(mux *http.ServeMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), schemaVersionKey{}, 1)
+		http.StripPrefix("/v1", mux).ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// schemaMiddleware sets X-Schema-Version on every response to the pass
+// schema version of the route, so clients can check what they got even
+// from version 0, whose passes don't say.
+func schemaMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Schema-Version", strconv.Itoa(schemaVersion(r.Context())))
+		next(w, r)
+	}
+}
+
+// schemaVersion is the pass schema version the request asked for.
+func schemaVersion(ctx context.Context) int {
+	v, _ := ctx.Value(schemaVersionKey{}).(int)
+	return v
+}
+
+// wantRaw reports whether responses keep raw_extra_data: ?raw=true or
+// ?raw=false when given, otherwise yes for version 0 and no under /v1.
+func wantRaw(ctx context.Context, q url.Values) bool {
+	switch q.Get("raw") {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return schemaVersion(ctx) == 0
+}
+
+// shapePass applies the schema version, the raw option and the output
+// format to a pass about to be written out. Everything that needs RawData (warnings, redaction,
+// storage, webhooks) must already have run.
+func shapePass(ctx context.Context, p *bcbp.UnifiedBoardingPass, q url.Values) {
+	p.SchemaVersion = schemaVersion(ctx)
+	if !wantRaw(ctx, q) {
+		p.RawData = nil
+	}
+	applyFormat(p, q)
+}
+
+// shapeStored is shapePass for stored passes read back for r.
+func shapeStored(r *http.Request, passes ...*storage.StoredPass) {
+	for _, sp := range passes {
+		shapePass(r.Context(), sp.Pass, r.URL.Query())
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ----------------------
+// PERSISTENCE: SECRETS AT REST
+// ----------------------
+
+// Some of what the store keeps is a credential rather than pass data, such
+// as the token a Wallet pass's issuer accepts for updates. Those values are
+// sealed with AES-256-GCM under PASS_SECRET_KEY before they reach the database,
+// so a copy of the database (a backup, a laptop) doesn't hand them out.
+// Without the key they aren't stored at all.
+
+// passSecrets is nil when PASS_SECRET_KEY is not set.
+var passSecrets *secretBox
+
+// secretBox seals and opens values with one AES-256-GCM key.
+type secretBox struct {
+	aead cipher.AEAD
+}
+
+// sealedPrefix marks a sealed value and its format, so the key or cipher
+// can change later without guessing at old rows.
+const sealedPrefix = "v1:"
+
+var errSecretKey = errors.New("must be 32 bytes, as 64 hex digits or in base64")
+
+// newSecretBox reads key, 32 bytes in hex or base64.
+func newSecretBox(key string) (*secretBox, error) {
+	key = strings.TrimSpace(key)
+	b, err := hex.DecodeString(key)
+	if err != nil {
+		b, err = base64.StdEncoding.DecodeString(key)
+	}
+	if err != nil || len(b) != 32 {
+		return nil, errSecretKey
+	}
+	block, err := aes.NewCipher(b)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &secretBox{aead: aead}, nil
+}
+
+// seal encrypts plaintext under a fresh nonce. id is bound to the result,
+// so a sealed value copied to another row doesn't open there.
+func (s *secretBox) seal(plaintext []byte, id string) string {
+	nonce := make([]byte, s.aead.NonceSize())
+	rand.Read(nonce)
+	return sealedPrefix + base64.StdEncoding.EncodeToString(s.aead.Seal(nonce, nonce, plaintext, []byte(id)))
+}
+
+// open decrypts a value seal returned for id.
+func (s *secretBox) open(sealed, id string) ([]byte, error) {
+	rest, ok := strings.CutPrefix(sealed, sealedPrefix)
+	if !ok {
+		return nil, errors.New("unknown sealed value format")
+	}
+	b, err := base64.StdEncoding.DecodeString(rest)
+	if err != nil || len(b) < s.aead.NonceSize() {
+		return nil, errors.New("malformed sealed value")
+	}
+	n := s.aead.NonceSize()
+	plaintext, err := s.aead.Open(nil, b[:n], b[n:], []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("opening sealed value: %w", err)
+	}
+	return plaintext, nil
+}
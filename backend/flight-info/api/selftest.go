@@ -0,0 +1,176 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"bugsbyte/flight-info/bagtag"
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/pkpass"
+	"bugsbyte/flight-info/scan"
+)
+
+// ----------------------
+// ADMIN: SELF-TEST
+// ----------------------
+
+// POST /admin/selftest runs a few inputs embedded in the binary through the
+// parsers, so an operator can check that a build, on the box it runs on,
+// reads barcodes, pass archives and every symbology scan reads, and has
+// its airport dataset, without go test there. Each pass goes through the
+// semantic checks and enrichment like a parsed one, but is never stored,
+// cached, sent to webhooks or counted in the parse statistics.
+
+//go:embed data/selftest
+var selfTestFiles embed.FS
+
+// selfTestTimeout is how long the cases, run together, may take.
+const selfTestTimeout = 10 * time.Second
+
+type selfTestCase struct {
+	name   string
+	file   string // under data/selftest
+	format string // the symbology an image must be read as
+	// want is the PNR of the pass, or the license plate of a bag tag.
+	want string
+}
+
+var selfTestCases = []selfTestCase{
+	{name: "bcbp", file: "ac-yul-fra.bcbp", want: "ABC123"},
+	{name: "pkpass", file: "ac-yul-fra.pkpass", want: "ABC123"},
+	{name: "aztec", file: "ac-aztec.png", format: "AZTEC", want: "ABC123"},
+	{name: "qr_code", file: "ac-qr.png", format: "QR_CODE", want: "ABC123"},
+	{name: "data_matrix", file: "u2-datamatrix.png", format: "DATA_MATRIX", want: "AB12CDE"},
+	{name: "code_128", file: "ba-code128.png", format: "CODE_128", want: "QRSTUV"},
+	{name: "itf", file: "tp-bagtag-itf.png", format: "ITF", want: "0047512345"},
+}
+
+// SelfTestReport is the body of POST /admin/selftest.
+type SelfTestReport struct {
+	OK         bool             `json:"ok"` // every case passed
+	DurationMS float64          `json:"duration_ms"`
+	Cases      []SelfTestResult `json:"cases"`
+}
+
+// SelfTestResult is one case of a SelfTestReport.
+type SelfTestResult struct {
+	Name       string  `json:"name"`
+	Input      string  `json:"input"`
+	OK         bool    `json:"ok"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+func handleSelfTest(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), selfTestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	report := SelfTestReport{OK: true, Cases: make([]SelfTestResult, len(selfTestCases))}
+	var wg sync.WaitGroup
+	for i, c := range selfTestCases {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			caseStart := time.Now()
+			err := c.run(ctx)
+			res := SelfTestResult{Name: c.name, Input: c.file, OK: err == nil, DurationMS: millis(time.Since(caseStart))}
+			if err != nil {
+				res.Error = err.Error()
+			}
+			report.Cases[i] = res
+		}()
+	}
+	wg.Wait()
+	report.DurationMS = millis(time.Since(start))
+	for _, c := range report.Cases {
+		report.OK = report.OK && c.OK
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// run parses c's input as its endpoint would and checks the result.
+func (c selfTestCase) run(ctx context.Context) error {
+	input, err := selfTestFiles.ReadFile(path.Join("data/selftest", c.file))
+	if err != nil {
+		return err
+	}
+	var p *bcbp.UnifiedBoardingPass
+	switch path.Ext(c.file) {
+	case ".bcbp":
+		p, err = parseBCBP(ctx, string(input), time.Now(), false)
+	case ".pkpass":
+		// Not parsePKPass: its shadow comparison would count the self-test.
+		p, err = pkpass.ParseReader(bytes.NewReader(input), int64(len(input)))
+	default:
+		release := func() {}
+		if heavyWork != nil {
+			if release, err = heavyWork.acquire(ctx, scan.Weight(input)); err != nil {
+				return fmt.Errorf("server busy: %w", err)
+			}
+		}
+		prof, _ := scan.LookupDecodeProfile(serverDecodeProfile())
+		res, err := scan.DecodeWith(ctx, input, prof)
+		release()
+		if err != nil {
+			return err
+		}
+		if res.Format != c.format {
+			return fmt.Errorf("read as %s, want %s", res.Format, c.format)
+		}
+		if tag, ok := bagtag.Parse(res.Text); ok {
+			return c.check(tag.LicensePlate)
+		}
+		p, err = parseBCBP(ctx, res.Text, time.Now(), false)
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.check(p.PNR); err != nil {
+		return err
+	}
+	// Enrichment names the airports from the embedded dataset.
+	EnrichPass(ctx, p, url.Values{"enrich": {"true"}})
+	if p.DepartureAirportName == "" {
+		return fmt.Errorf("enrichment: no name for airport %s", p.Departure)
+	}
+	return ctx.Err()
+}
+
+func (c selfTestCase) check(got string) error {
+	if got != c.want {
+		return fmt.Errorf("got %q, want %q", got, c.want)
+	}
+	return nil
+}
+
+// checkSelfTestCases checks at startup that every symbology scan reads
+// has a self-test case, so a reader added later isn't left out.
+func checkSelfTestCases() error {
+	var errs []error
+	for _, f := range scan.Formats() {
+		found := false
+		for _, c := range selfTestCases {
+			found = found || c.format == f
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("no self-test image for %s", f))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,399 @@
+// Package api is the HTTP and gRPC server: handlers, middleware, storage,
+// enrichment and the optional integrations, all configured from the
+// environment by Serve.
+package api
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"bugsbyte/flight-info/pkpass"
+	"bugsbyte/flight-info/scan"
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// SERVER
+// ----------------------
+
+// Handler is the HTTP API with the default configuration: nothing is read
+// from the environment, so there is no persistence, rate limiting or
+// debug routes. The benchmarks and handler tests use it.
+func Handler() http.Handler {
+	return corsHandler(newMux())
+}
+
+// newMux registers every API route, also under /v1.
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/parse/barcode", api(handleBarcode, http.MethodPost))
+	mux.HandleFunc("/parse/pkpass", apiHeavy(handlePkPass, http.MethodPost))
+	mux.HandleFunc("/parse/barcode/image", apiHeavy(handleBarcodeImage, http.MethodPost))
+	mux.HandleFunc("/parse/barcode/images", apiHeavy(handleBarcodeImages, http.MethodPost))
+	mux.HandleFunc("/ws/scan", apiHeavy(handleScanSocket, http.MethodGet))
+	mux.HandleFunc("/jobs/{id}", api(handleJob, http.MethodGet))
+	mux.HandleFunc("/jobs/{id}/events", api(handleJobEvents, http.MethodGet))
+	mux.HandleFunc("/passes", api(handlePasses, http.MethodGet, http.MethodDelete))
+	mux.HandleFunc("/passes/export.csv", api(handlePassesCSV, http.MethodGet))
+	mux.HandleFunc("/passes/{id}", api(handlePassByID, http.MethodGet, http.MethodDelete))
+	mux.HandleFunc("/passes/{id}/artifact", api(handlePassArtifact, http.MethodGet))
+	mux.HandleFunc("/passes/{id}/bcbp", api(handlePassBCBP, http.MethodGet))
+	mux.HandleFunc("/passes/{id}/history", api(handlePassHistory, http.MethodGet))
+	mux.HandleFunc("/passes/{id}/ics", api(handlePassICS, http.MethodGet))
+	mux.HandleFunc("/passes/{id}/notify", api(handlePassNotify, http.MethodPost))
+	mux.HandleFunc("/passes/{id}/refresh", api(handlePassRefresh, http.MethodPost))
+	mux.HandleFunc("/trips", api(handleTrips, http.MethodGet))
+	mux.HandleFunc("/analyze/conformance", api(handleConformance, http.MethodPost))
+	mux.HandleFunc("/airlines/{code}", api(handleAirline, http.MethodGet))
+	mux.HandleFunc("/airports", api(handleAirports, http.MethodGet))
+	mux.HandleFunc("/airports/{code}", api(handleAirport, http.MethodGet))
+	mux.HandleFunc("/util/julian", api(handleJulian, http.MethodGet))
+	mux.HandleFunc("/export/ics", api(handleExportICS, http.MethodPost))
+	mux.HandleFunc("/export/bcbp", api(handleExportBCBP, http.MethodPost))
+	mux.HandleFunc("/export/googlewallet", api(handleExportGoogleWallet, http.MethodPost))
+	mux.HandleFunc("/generate/pkpass", apiHeavy(handleGeneratePkPass, http.MethodPost))
+	mux.HandleFunc("/generate/barcode/image", api(handleGenerateBarcodeImage, http.MethodPost))
+	mux.HandleFunc("/admin/backup", api(adminMiddleware(handleBackup), http.MethodGet))
+	mux.HandleFunc("/admin/restore", api(adminMiddleware(handleRestore), http.MethodPost))
+	mux.HandleFunc("/admin/import", api(adminMiddleware(handleImport), http.MethodPost))
+	mux.HandleFunc("/admin/cleanup", api(adminMiddleware(handleCleanup), http.MethodPost))
+	mux.HandleFunc("/admin/failures", api(adminMiddleware(handleFailures), http.MethodGet, http.MethodDelete))
+	mux.HandleFunc("/admin/shadow", api(adminMiddleware(handleShadow), http.MethodGet, http.MethodDelete))
+	mux.HandleFunc("/admin/config", api(adminMiddleware(handleConfig), http.MethodGet, http.MethodPatch))
+	mux.HandleFunc("/admin/selftest", api(adminMiddleware(handleSelfTest), http.MethodPost))
+	mux.HandleFunc("/stats", api(handleStats, http.MethodGet))
+	mux.HandleFunc("/metrics", requestIDMiddleware(loggingMiddleware(recoverMiddleware(methodMiddleware([]string{http.MethodGet}, handleMetrics)))))
+	mux.HandleFunc("/capabilities", api(handleCapabilities, http.MethodGet))
+	mux.HandleFunc("/openapi.json", api(handleOpenAPI, http.MethodGet))
+	mux.HandleFunc("/docs", requestIDMiddleware(loggingMiddleware(recoverMiddleware(methodMiddleware([]string{http.MethodGet}, handleDocs)))))
+	mux.HandleFunc("/v1/", v1Handler(mux))
+	mux.HandleFunc("/", requestIDMiddleware(langMiddleware(loggingMiddleware(recoverMiddleware(handleNotFound)))))
+	return mux
+}
+
+// handleNotFound answers every path without a route, whatever the method,
+// preflights included, with a 404 in the error envelope.
+func handleNotFound(w http.ResponseWriter, r *http.Request) {
+	httpError(w, fmt.Sprintf("No endpoint at %s", r.URL.Path), http.StatusNotFound)
+}
+
+// Serve configures every feature from the environment and CONFIG_FILE and
+// runs the HTTP and gRPC servers until SIGINT or SIGTERM. Configuration
+// errors exit the process.
+func Serve() {
+	if err := loadConfigFile(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+	cfg, cfgErr := loadConfig()
+	if err := setupLogging(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading logging configuration: %v\n", err)
+		os.Exit(1)
+	}
+	if cfgErr != nil {
+		fatal("Invalid configuration", "err", cfgErr)
+	}
+	logConfig()
+
+	// SIGINT or SIGTERM cancels ctx. The background loops started below
+	// run on it, and Serve waits for them after the HTTP server has shut
+	// down.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var background sync.WaitGroup
+	goBackground := func(run func(context.Context)) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			run(ctx)
+		}()
+	}
+
+	mux := newMux()
+	checkAPIRoutes(mux)
+
+	debugEndpoints = cfg.DebugEndpoints
+	if debugEndpoints {
+		registerDebugRoutes(mux)
+	}
+	redactAll = cfg.RedactPII
+	bcbpLenient = cfg.BCBPLenient
+	if err := loadSeatSentinels(cfg.SeatSentinels); err != nil {
+		fatal("Error loading parser configuration", "err", err)
+	}
+	if err := loadPkPassMapper(cfg); err != nil {
+		fatal("Error loading parser configuration", "err", err)
+	}
+	if err := scan.CheckDecodeProfiles(); err != nil {
+		fatal("Invalid decode profiles", "err", err)
+	}
+	if err := checkSelfTestCases(); err != nil {
+		fatal("Invalid self-test cases", "err", err)
+	}
+	defaultDecodeProfile.Store(cfg.DecodeProfile)
+	if err := setupTracing(context.Background(), cfg); err != nil {
+		fatal("Error loading tracing configuration", "err", err)
+	}
+	dateWindowPast, dateWindowFuture = cfg.DateWindowPast, cfg.DateWindowFuture
+	lookupMaxAge = cfg.LookupCacheMaxAge
+	if cfg.ParseCacheSize > 0 && cfg.ParseCacheTTL > 0 {
+		parseCache = newResponseCache(cfg.ParseCacheSize, cfg.ParseCacheTTL)
+	}
+	if cfg.ParseFailuresSize > 0 {
+		parseFailures = newFailureLog(cfg.ParseFailuresSize)
+	}
+	debugCapture = cfg.DebugCapture
+	if captureDir = cfg.CaptureDir; captureDir != "" {
+		if err := os.MkdirAll(captureDir, 0o700); err != nil {
+			fatal("Error creating capture directory", "dir", captureDir, "err", err)
+		}
+		slog.Info("Traffic capture enabled", "dir", captureDir)
+	}
+	if cfg.HeavyMaxConcurrent > 0 {
+		heavyWork = newHeavyLimiter(cfg.HeavyMaxConcurrent, cfg.HeavyMaxQueue, cfg.HeavyMaxWait)
+	}
+	batchMaxImages, batchWorkers = cfg.BatchMaxImages, cfg.BatchWorkers
+	importWorkers = cfg.ImportWorkers
+	batchJobs = newJobStore(cfg.JobTTL, cfg.JobMax)
+	scanFrameMax, scanFPS = cfg.WSScanMaxFrame, cfg.WSScanFPS
+	scan.MinImageSide, scan.MinSharpness = cfg.ImageMinSide, cfg.ImageMinSharpness
+	var err error
+	if trustedProxies, err = parseTrustedProxies(cfg.TrustedProxies); err != nil {
+		fatal("Error loading rate limit configuration", "err", err)
+	}
+	lightRate = tierRateLimiter("light", cfg.RateLimitLight, cfg.RateLimitLightBurst)
+	heavyRate = tierRateLimiter("heavy", cfg.RateLimitHeavy, cfg.RateLimitHeavyBurst)
+	if lightRate != nil || heavyRate != nil {
+		goBackground(func(ctx context.Context) { pruneRateLimits(ctx, time.Minute) })
+	}
+	breakerFailures, breakerCooldown = cfg.UpstreamBreakerFailures, cfg.UpstreamBreakerCooldown
+	requestBudget = cfg.RequestBudget
+	if passLinkHosts, err = loadPassLinkHosts(cfg.PassLinkHosts); err != nil {
+		fatal("Error loading pass link configuration", "err", err)
+	}
+	if err := loadOCR(cfg); err != nil {
+		fatal("Error loading OCR configuration", "err", err)
+	}
+	problemTypeBase = cfg.ProblemTypeBase
+	adminToken = cfg.AdminToken
+	dbURL, err := cfg.databaseURL()
+	if err != nil {
+		fatal("Error loading persistence configuration", "err", err)
+	}
+	if dbURL != "" {
+		store, err := storage.Open(dbURL)
+		if err != nil {
+			fatal("Error opening database", "database", redactDatabaseURL(dbURL), "err", err)
+		}
+		defer store.Close()
+		passStore = store
+	}
+	if dir := cfg.ArtifactDir; dir != "" {
+		if passStore == nil {
+			fatal("ARTIFACT_DIR needs SQLITE_PATH or DATABASE_URL: uploads are kept for stored passes")
+		}
+		if redactAll {
+			fatal("ARTIFACT_DIR can't be used with REDACT_PII: the uploads hold the passenger's data unredacted")
+		}
+		if artifactStore, err = newArtifactStore(dir, int64(cfg.ArtifactMaxBytes), int64(cfg.ArtifactDirMaxBytes)); err != nil {
+			fatal("Error opening upload storage", "dir", dir, "err", err)
+		}
+	}
+	if key := cfg.PassSecretKey; key != "" {
+		if passStore == nil {
+			fatal("PASS_SECRET_KEY needs SQLITE_PATH or DATABASE_URL: it seals what is stored with passes")
+		}
+		if passSecrets, err = newSecretBox(key); err != nil {
+			fatal("Error loading PASS_SECRET_KEY", "err", err)
+		}
+		passRefreshTimeout = cfg.PassRefreshTimeout
+	}
+	if cfg.PkPassCert != "" && cfg.PkPassKey != "" {
+		signer, err := loadPassSigner(cfg.PkPassCert, cfg.PkPassKey, cfg.PkPassWWDR)
+		if err != nil {
+			fatal("Error loading pkpass signing certificate", "err", err)
+		}
+		pkpassSigner = signer
+	}
+	pkpassTypeID, pkpassTeamID = cfg.PkPassTypeID, cfg.PkPassTeamID
+	googleWalletIssuerID = cfg.GoogleWalletIssuerID
+	if key := cfg.GoogleWalletKey; key != "" {
+		signer, err := loadGoogleWalletSigner(key, cfg.GoogleWalletIssuerID)
+		if err != nil {
+			fatal("Error loading Google Wallet service-account key", "err", err)
+		}
+		googleWallet = signer
+	}
+	webhookCfg, err := loadWebhookConfig(cfg)
+	if err != nil {
+		fatal("Error loading webhook configuration", "err", err)
+	}
+	if webhookCfg != nil {
+		webhooks = newWebhookDispatcher(webhookCfg)
+	}
+	if key := cfg.AeroDataBoxAPIKey; key != "" {
+		provider := &aeroDataBox{
+			baseURL: strings.TrimRight(cfg.AeroDataBoxURL, "/"),
+			apiKey:  key,
+			client:  newUpstreamClient(0),
+		}
+		flightStatus = newCachedStatusProvider(provider, cfg.FlightStatusCacheTTL, cfg.FlightStatusTimeout)
+	}
+	airlineLogoTemplate = cfg.AirlineLogoURL
+	var notifyInterval time.Duration
+	if passStore != nil {
+		notifyInterval = cfg.NotifyInterval
+		scheduler := newNotificationScheduler(passStore, cfg.ExpoPushURL, notifyInterval)
+		goBackground(scheduler.Run)
+
+		parseStats = newStatsRecorder(passStore, cfg.StatsFlushInterval)
+		goBackground(parseStats.Run)
+
+		if cfg.PassRetention > 0 {
+			passJanitor = newPassJanitor(passStore, cfg.PassRetention, cfg.PassCleanupInterval)
+			goBackground(passJanitor.Run)
+		}
+	}
+
+	// The gRPC API gets its own port, started once everything above is set
+	// up; GRPC_ADDR=off disables it.
+	grpcAddr = cfg.GRPCAddr
+	var grpcSrv *grpc.Server
+	if grpcAddr != "off" {
+		lis, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			fatal("Error starting gRPC server", "err", err)
+		}
+		grpcSrv = newGRPCServer()
+		go func() {
+			if err := grpcSrv.Serve(lis); err != nil {
+				fatal("gRPC server stopped", "err", err)
+			}
+		}()
+	}
+
+	lis, listenerDesc, removeSocket, err := httpListener(cfg)
+	if err != nil {
+		fatal("Error starting HTTP server", "err", err)
+	}
+	logStartup(cfg, listenerDesc, grpcAddr, notifyInterval, webhookCfg)
+
+	// SIGINT or SIGTERM lets in-flight requests finish, waits for the
+	// background loops, then removes the Unix socket, if any.
+	srv := &http.Server{Handler: corsHandler(mux)}
+	srv.RegisterOnShutdown(closeScanSessions)
+	srv.RegisterOnShutdown(stopBatches)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		slog.Info("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("HTTP shutdown incomplete", "err", err)
+		}
+		if grpcSrv != nil {
+			grpcSrv.GracefulStop()
+		}
+		background.Wait()
+		flushStats()
+	}()
+	if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		removeSocket()
+		fatal("HTTP server stopped", "err", err)
+	}
+	<-done
+	removeSocket()
+}
+
+// shutdownTimeout bounds how long a graceful shutdown waits for in-flight
+// HTTP requests.
+const shutdownTimeout = 15 * time.Second
+
+// logStartup reports the configuration the server came up with. The route
+// list is at debug level; /docs has the full API.
+func logStartup(cfg Config, listener, grpcAddr string, notifyInterval time.Duration, webhookCfg *WebhookConfig) {
+	slog.Info("Server starting", "listener", listener)
+	for _, op := range apiOperations {
+		slog.Debug("Route", "method", op.Method, "path", op.Path, "summary", op.Summary)
+	}
+	if grpcAddr != "off" {
+		slog.Info("gRPC enabled", "service", "flightinfo.v1.FlightInfo", "addr", grpcAddr)
+	}
+	if passStore != nil {
+		dbURL, _ := cfg.databaseURL()
+		slog.Info("Persistence enabled", "database", redactDatabaseURL(dbURL), "notify_interval", notifyInterval)
+		if artifactStore != nil {
+			slog.Info("Upload storage enabled", "dir", artifactStore.dir, "max_bytes", artifactStore.maxSize, "dir_max_bytes", artifactStore.maxTotal)
+		}
+		if passSecrets != nil {
+			slog.Info("Pass refresh enabled", "timeout", passRefreshTimeout)
+		}
+		if passJanitor != nil {
+			slog.Info("Retention enabled", "ttl", passJanitor.ttl, "interval", passJanitor.interval)
+		}
+	} else {
+		slog.Info("Persistence disabled (set SQLITE_PATH or DATABASE_URL to enable)")
+	}
+	if tracingEnabled {
+		slog.Info("Tracing enabled", "endpoint", cmp.Or(cfg.OTLPTracesEndpoint, cfg.OTLPEndpoint))
+	}
+	if adminToken != "" {
+		slog.Info("Admin endpoints enabled")
+	}
+	if debugEndpoints {
+		slog.Warn("DEBUG_ENDPOINTS is on: /debug/pprof and /debug/vars are mounted behind the admin token")
+	}
+	if debugCapture && parseFailures != nil {
+		slog.Warn("DEBUG_CAPTURE is on: failed parse inputs are kept in memory")
+	}
+	if p := serverDecodeProfile(); p != scan.DefaultDecodeProfile {
+		slog.Info("Default decode profile", "profile", p)
+	}
+	if ocrEngine != nil {
+		slog.Info("OCR fallback enabled", "command", ocrEngine.Path(), "timeout", ocrTimeout)
+	}
+	if bcbpLenient {
+		slog.Warn("BCBP_LENIENT is on: NUL-padded barcodes with a lowercase format code are accepted")
+	}
+	if shadowMapping {
+		slog.Info("Pkpass shadow mapping on", "primary", pkpass.DefaultMapper, "shadow", shadowMapper())
+	}
+	if redactAll {
+		slog.Info("PII redaction on for responses, storage and webhooks")
+	}
+	if heavyWork != nil {
+		slog.Info("Heavy work limiter", "concurrent", heavyWork.capacity, "queue", heavyWork.maxQueue, "max_wait", heavyWork.maxWait)
+	}
+	for _, l := range []*rateLimiter{lightRate, heavyRate} {
+		if l != nil {
+			slog.Info("Rate limit", "tier", l.tier, "per_minute", int(l.rate*60), "burst", int(l.burst), "trusted_proxies", len(trustedProxies))
+		}
+	}
+	if parseCache != nil && passStore != nil {
+		slog.Info("Parse cache off: passes are stored")
+	} else if parseCache != nil {
+		slog.Info("Parse cache", "entries", parseCache.size, "ttl", parseCache.ttl)
+	}
+	if flightStatus != nil {
+		slog.Info("Flight status", "provider", flightStatus.provider.Name(), "ttl", flightStatus.ttl)
+	}
+	if webhooks != nil {
+		slog.Info("Webhooks", "urls", len(webhookCfg.URLs), "workers", webhookCfg.Workers)
+	}
+	slog.Info("Ensure your phone and computer are on the same Wi-Fi, and use the computer's IP address (not localhost) in the Expo app")
+}
@@ -0,0 +1,591 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"bugsbyte/flight-info/pkpass"
+	"bugsbyte/flight-info/scan"
+)
+
+// ----------------------
+// CONFIG: SETTINGS, CONFIG_FILE, SOURCES
+// ----------------------
+
+// Every variable the server reads is a setting in settings, with its type,
+// default and bounds. A value comes from, in order: a runtime change (PATCH
+// /admin/config), the environment, CONFIG_FILE, the default. checkSettings
+// rejects keys of CONFIG_FILE that aren't settings and values that don't
+// parse or are out of range, all at once, before anything is configured;
+// loadConfig then reads them into a Config.
+
+// settingKind is the type of a setting's value.
+type settingKind string
+
+const (
+	kindString   settingKind = "string"
+	kindInt      settingKind = "int"
+	kindFloat    settingKind = "float"
+	kindBool     settingKind = "bool"
+	kindDuration settingKind = "duration"
+)
+
+// settingBound is the range of a number or duration.
+type settingBound int
+
+const (
+	anyValue settingBound = iota
+	notNegative
+	positive
+)
+
+// Sources of a setting's value, as in ConfigSetting.Source.
+const (
+	sourceDefault = "default"
+	sourceEnv     = "env"
+	sourceFile    = "file"
+	sourceRuntime = "runtime"
+)
+
+// setting is one configuration variable.
+type setting struct {
+	name string
+	kind settingKind
+	// def is the default as it would be written, "" when there is none or
+	// it is worked out from other settings.
+	def string
+	// emptyOK settings take an empty value as set, not as the default:
+	// PASS_LINK_HOSTS= turns pass links off.
+	emptyOK bool
+	bound   settingBound
+	// valid checks a value beyond its kind and bound: one of a list, say.
+	valid func(v string) error
+	// secret values are masked in the startup log and /admin/config.
+	secret bool
+	// envOnly settings are read by libraries straight from the environment,
+	// or say where the rest comes from; CONFIG_FILE can't set them.
+	envOnly bool
+	// apply, when set, prepares a change to v and returns the function
+	// that puts it into effect, without a restart: PATCH /admin/config
+	// accepts the setting.
+	apply func(v string) (commit func(), err error)
+}
+
+// settings are all the settings there are, in the order /admin/config
+// lists them.
+var settings = []setting{
+	{name: "CONFIG_FILE", kind: kindString, envOnly: true},
+	{name: "LOG_LEVEL", kind: kindString, def: "info", valid: validLogLevel, apply: applyLogLevel},
+	{name: "LOG_FORMAT", kind: kindString, def: "text", valid: oneOf("text", "json")},
+	{name: "LISTEN_SOCKET", kind: kindString},
+	{name: "LISTEN_SOCKET_MODE", kind: kindString, def: "0660", valid: validFileMode},
+	{name: "GRPC_ADDR", kind: kindString, def: ":9090"},
+	{name: "ADMIN_TOKEN", kind: kindString, secret: true},
+	{name: "DEBUG_ENDPOINTS", kind: kindBool, def: "false"},
+	{name: "PROBLEM_TYPE_BASE", kind: kindString, def: "urn:flight-info:error:"},
+
+	{name: "REDACT_PII", kind: kindBool, def: "false"},
+	{name: "BCBP_LENIENT", kind: kindBool, def: "false"},
+	{name: "SEAT_SENTINELS", kind: kindString},
+	{name: "PKPASS_MAPPER", kind: kindString, def: string(pkpass.MapKeywords), valid: validMapper},
+	{name: "PKPASS_SHADOW_MAPPER", kind: kindBool, def: "false"},
+	{name: "DATE_WINDOW_PAST", kind: kindDuration, def: "48h", bound: notNegative},
+	{name: "DATE_WINDOW_FUTURE", kind: kindDuration, def: "8640h", bound: notNegative},
+
+	{name: "DECODE_PROFILE", kind: kindString, def: scan.DefaultDecodeProfile, valid: validDecodeProfile, apply: applyDecodeProfile},
+	{name: "IMAGE_MIN_SIDE", kind: kindInt, def: strconv.Itoa(scan.MinImageSide), bound: notNegative},
+	{name: "IMAGE_MIN_SHARPNESS", kind: kindFloat, def: strconv.FormatFloat(scan.MinSharpness, 'g', -1, 64), bound: notNegative},
+	{name: "BATCH_MAX_IMAGES", kind: kindInt, def: "50", bound: positive},
+	{name: "BATCH_WORKERS", kind: kindInt, def: "4", bound: positive},
+	{name: "IMPORT_WORKERS", kind: kindInt, def: "4", bound: positive},
+	{name: "JOB_TTL", kind: kindDuration, def: "15m", bound: positive},
+	{name: "JOB_MAX", kind: kindInt, def: "100", bound: positive},
+	{name: "WS_SCAN_MAX_FRAME", kind: kindInt, def: "2097152", bound: positive},
+	{name: "WS_SCAN_FPS", kind: kindInt, def: "5", bound: positive},
+	{name: "OCR_COMMAND", kind: kindString, def: "tesseract"},
+	{name: "OCR_TIMEOUT", kind: kindDuration, def: "15s", bound: positive},
+
+	{name: "PARSE_CACHE_SIZE", kind: kindInt, def: "1024", bound: notNegative},
+	{name: "PARSE_CACHE_TTL", kind: kindDuration, def: "1m", bound: notNegative, apply: applyCacheTTL},
+	{name: "LOOKUP_CACHE_MAX_AGE", kind: kindDuration, def: "24h", bound: notNegative},
+	{name: "PARSE_FAILURES_SIZE", kind: kindInt, def: "200", bound: notNegative},
+	{name: "DEBUG_CAPTURE", kind: kindBool, def: "false"},
+	{name: "CAPTURE_DIR", kind: kindString},
+
+	{name: "HEAVY_MAX_CONCURRENT", kind: kindInt, def: strconv.Itoa(runtime.NumCPU()), bound: notNegative},
+	{name: "HEAVY_MAX_QUEUE", kind: kindInt, def: strconv.Itoa(4 * runtime.NumCPU()), bound: notNegative},
+	{name: "HEAVY_MAX_WAIT", kind: kindDuration, def: "10s", bound: notNegative},
+	{name: "RATE_LIMIT_LIGHT", kind: kindInt, def: "0", bound: notNegative},
+	{name: "RATE_LIMIT_LIGHT_BURST", kind: kindInt, bound: positive},
+	{name: "RATE_LIMIT_HEAVY", kind: kindInt, def: "0", bound: notNegative},
+	{name: "RATE_LIMIT_HEAVY_BURST", kind: kindInt, bound: positive},
+	{name: "TRUSTED_PROXIES", kind: kindString},
+
+	{name: "UPSTREAM_BREAKER_FAILURES", kind: kindInt, def: "5", bound: positive},
+	{name: "UPSTREAM_BREAKER_COOLDOWN", kind: kindDuration, def: "30s", bound: positive},
+	{name: "REQUEST_BUDGET", kind: kindDuration, def: "10s", bound: notNegative},
+	{name: "PASS_LINK_HOSTS", kind: kindString, def: "wallet.apple.com", emptyOK: true},
+	{name: "AERODATABOX_API_KEY", kind: kindString, secret: true},
+	{name: "AERODATABOX_URL", kind: kindString, def: "https://aerodatabox.p.rapidapi.com"},
+	{name: "FLIGHT_STATUS_CACHE_TTL", kind: kindDuration, def: "5m", bound: notNegative},
+	{name: "FLIGHT_STATUS_TIMEOUT", kind: kindDuration, def: "3s", bound: positive},
+	{name: "AIRLINE_LOGO_URL", kind: kindString},
+
+	{name: "SQLITE_PATH", kind: kindString},
+	{name: "DATABASE_URL", kind: kindString, secret: true, valid: validDatabaseURL},
+	{name: "ARTIFACT_DIR", kind: kindString},
+	{name: "ARTIFACT_MAX_BYTES", kind: kindInt, def: strconv.Itoa(defaultArtifactMaxBytes), bound: positive},
+	{name: "ARTIFACT_DIR_MAX_BYTES", kind: kindInt, def: strconv.Itoa(defaultArtifactDirBytes), bound: positive},
+	{name: "PASS_SECRET_KEY", kind: kindString, secret: true},
+	{name: "PASS_REFRESH_TIMEOUT", kind: kindDuration, def: "10s", bound: positive},
+	{name: "PASS_RETENTION", kind: kindDuration, def: "0s", bound: notNegative},
+	{name: "PASS_CLEANUP_INTERVAL", kind: kindDuration, def: "1h", bound: positive},
+	{name: "NOTIFY_INTERVAL", kind: kindDuration, def: "1m", bound: positive},
+	{name: "EXPO_PUSH_URL", kind: kindString, def: defaultExpoPushURL},
+	{name: "STATS_FLUSH_INTERVAL", kind: kindDuration, def: "10s", bound: positive},
+
+	{name: "PKPASS_CERT", kind: kindString},
+	{name: "PKPASS_KEY", kind: kindString},
+	{name: "PKPASS_WWDR", kind: kindString},
+	{name: "PKPASS_TYPE_ID", kind: kindString, def: "pass.com.example.flightinfo"},
+	{name: "PKPASS_TEAM_ID", kind: kindString, def: "TEAMID0000"},
+	{name: "GOOGLE_WALLET_KEY", kind: kindString},
+	{name: "GOOGLE_WALLET_ISSUER_ID", kind: kindString},
+
+	{name: "WEBHOOK_CONFIG", kind: kindString},
+	{name: "WEBHOOK_URLS", kind: kindString},
+	{name: "WEBHOOK_SECRET", kind: kindString, secret: true},
+	{name: "WEBHOOK_WORKERS", kind: kindInt, def: "4", bound: positive},
+	{name: "WEBHOOK_MAX_ATTEMPTS", kind: kindInt, def: "4", bound: positive},
+	{name: "WEBHOOK_TIMEOUT", kind: kindDuration, def: "5s", bound: positive},
+
+	{name: "OTEL_EXPORTER_OTLP_ENDPOINT", kind: kindString, envOnly: true},
+	{name: "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", kind: kindString, envOnly: true},
+	{name: "OTEL_EXPORTER_OTLP_PROTOCOL", kind: kindString, def: "http/protobuf", envOnly: true},
+	{name: "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", kind: kindString, envOnly: true},
+	{name: "OTEL_TRACES_EXPORTER", kind: kindString, envOnly: true},
+	{name: "OTEL_SDK_DISABLED", kind: kindBool, def: "false", envOnly: true},
+}
+
+// config is what the settings are read from besides the environment.
+var config = struct {
+	sync.RWMutex
+	path    string            // CONFIG_FILE
+	file    map[string]string // its values
+	runtime map[string]string // values set by PATCH /admin/config
+}{runtime: map[string]string{}}
+
+// lookupSetting finds a setting by name.
+func lookupSetting(name string) (*setting, bool) {
+	i := slices.IndexFunc(settings, func(s setting) bool { return s.name == name })
+	if i < 0 {
+		return nil, false
+	}
+	return &settings[i], true
+}
+
+// configLookup returns the value of the setting name and where it came
+// from; ok is false when it isn't set anywhere. A variable that isn't a
+// setting is read from the environment alone.
+func configLookup(name string) (v, source string, ok bool) {
+	config.RLock()
+	defer config.RUnlock()
+	if v, ok := config.runtime[name]; ok {
+		return v, sourceRuntime, true
+	}
+	if v, ok := os.LookupEnv(name); ok {
+		return v, sourceEnv, true
+	}
+	if v, ok := config.file[name]; ok {
+		return v, sourceFile, true
+	}
+	return "", sourceDefault, false
+}
+
+// value is the value of s, its default when it isn't set.
+func (s *setting) value() (v, source string) {
+	v, source, ok := configLookup(s.name)
+	if !ok {
+		v = s.def
+	}
+	return v, source
+}
+
+// configValue is the value of the setting name, "" when it isn't set.
+func configValue(name string) string {
+	v, _, _ := configLookup(name)
+	return v
+}
+
+// loadConfigFile reads CONFIG_FILE, if set: a JSON object, or for .yaml
+// and .yml a flat YAML mapping, of setting names (either case) to values.
+func loadConfigFile() error {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("CONFIG_FILE: %w", err)
+	}
+	var values map[string]string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		values, err = parseJSONConfig(data)
+	case ".yaml", ".yml":
+		values, err = parseYAMLConfig(data)
+	default:
+		return fmt.Errorf("CONFIG_FILE: %s: want a .json, .yaml or .yml file", path)
+	}
+	if err != nil {
+		return fmt.Errorf("CONFIG_FILE: %s: %w", path, err)
+	}
+	config.Lock()
+	config.path, config.file = path, values
+	config.Unlock()
+	return nil
+}
+
+// parseJSONConfig reads a JSON object of strings, numbers and booleans.
+func parseJSONConfig(data []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	for k, r := range raw {
+		v, err := configString(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		values[strings.ToUpper(k)] = v
+	}
+	return values, nil
+}
+
+// configString is a JSON string, number or boolean as a setting value.
+func configString(r json.RawMessage) (string, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(r))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", errors.New("want a string, number or boolean")
+}
+
+// parseYAMLConfig reads a YAML mapping of names to scalars. Anything else,
+// a nested mapping or a list, is an error naming its line, not a value.
+func parseYAMLConfig(data []byte) (map[string]string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	if len(doc.Content) == 0 {
+		return values, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: want NAME: value lines", root.Line)
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		k, v := root.Content[i], root.Content[i+1]
+		if k.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: want a setting name", k.Line)
+		}
+		key := strings.ToUpper(k.Value)
+		if v.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: %s: want a string, number or boolean", v.Line, key)
+		}
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("line %d: %s is set twice", k.Line, key)
+		}
+		if v.Tag == "!!null" {
+			values[key] = ""
+		} else {
+			values[key] = v.Value
+		}
+	}
+	return values, nil
+}
+
+// checkSettings checks CONFIG_FILE's keys and every value set.
+func checkSettings() error {
+	var errs []error
+	config.RLock()
+	for k := range config.file {
+		s, ok := lookupSetting(k)
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("CONFIG_FILE: unknown setting %s", k))
+		case s.envOnly:
+			errs = append(errs, fmt.Errorf("CONFIG_FILE: %s can only be set in the environment", k))
+		}
+	}
+	config.RUnlock()
+	for i := range settings {
+		s := &settings[i]
+		if v := configValue(s.name); v != "" {
+			if err := s.check(v); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(errs...)
+}
+
+// check reports whether v is a valid value of s.
+func (s *setting) check(v string) error {
+	var n float64
+	switch s.kind {
+	case kindInt:
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a whole number", s.name, v)
+		}
+		n = float64(i)
+	case kindFloat:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", s.name, v)
+		}
+		n = f
+	case kindBool:
+		if _, err := strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("%s: %q is not true or false", s.name, v)
+		}
+	case kindDuration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a duration such as 90s or 1h30m", s.name, v)
+		}
+		n = float64(d)
+	}
+	switch {
+	case s.bound == notNegative && n < 0:
+		return fmt.Errorf("%s must not be negative", s.name)
+	case s.bound == positive && n <= 0:
+		return fmt.Errorf("%s must be positive", s.name)
+	}
+	if s.valid != nil {
+		if err := s.valid(v); err != nil {
+			return fmt.Errorf("%s: %w", s.name, err)
+		}
+	}
+	return nil
+}
+
+func oneOf(values ...string) func(string) error {
+	return func(v string) error {
+		if !slices.Contains(values, strings.ToLower(v)) {
+			return fmt.Errorf("%q is not one of %s", v, strings.Join(values, ", "))
+		}
+		return nil
+	}
+}
+
+func validLogLevel(v string) error {
+	var level slog.Level
+	return level.UnmarshalText([]byte(v))
+}
+
+func validFileMode(v string) error {
+	_, err := strconv.ParseUint(v, 8, 32)
+	return err
+}
+
+func validMapper(v string) error {
+	_, err := pkpass.ParseMapper(v)
+	return err
+}
+
+func validDatabaseURL(v string) error {
+	scheme, _, _ := strings.Cut(v, ":")
+	return oneOf("sqlite", "postgres", "postgresql")(scheme)
+}
+
+func validDecodeProfile(v string) error {
+	if _, ok := scan.LookupDecodeProfile(v); !ok {
+		return fmt.Errorf("%q is not one of %s", v, strings.Join(scan.DecodeProfiles(), ", "))
+	}
+	return nil
+}
+
+// masked is v as the startup log and /admin/config show it.
+func (s *setting) masked(v string) string {
+	if s.secret && v != "" {
+		return "********"
+	}
+	return v
+}
+
+// logConfig logs the value of every setting that has one.
+func logConfig() {
+	report := currentConfig()
+	if report.File != "" {
+		slog.Info("Configuration file", "path", report.File)
+	}
+	var attrs []any
+	for _, c := range report.Settings {
+		if c.Value != "" {
+			attrs = append(attrs, slog.String(c.Name, c.Value))
+		}
+	}
+	slog.Info("Configuration", attrs...)
+}
+
+// ----------------------
+// CONFIG: RUNTIME CHANGES
+// ----------------------
+
+func applyLogLevel(v string) (func(), error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(v)); err != nil {
+		return nil, err
+	}
+	return func() { logLevel.Set(level) }, nil
+}
+
+func applyDecodeProfile(v string) (func(), error) {
+	return func() { defaultDecodeProfile.Store(v) }, nil
+}
+
+func applyCacheTTL(v string) (func(), error) {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return nil, err
+	}
+	if parseCache == nil || d == 0 {
+		return nil, errors.New("the parse cache can't be turned on or off without a restart")
+	}
+	return func() { parseCache.SetTTL(d) }, nil
+}
+
+// ----------------------
+// ADMIN: CONFIGURATION
+// ----------------------
+
+// ConfigReport is the body of GET and PATCH /admin/config.
+type ConfigReport struct {
+	// File is CONFIG_FILE, "" without one.
+	File     string          `json:"file,omitempty"`
+	Settings []ConfigSetting `json:"settings"`
+}
+
+// ConfigSetting is the live value of one setting.
+type ConfigSetting struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"` // secrets are masked
+	// Source is default, env, file or runtime (PATCH /admin/config).
+	Source string `json:"source"`
+	Secret bool   `json:"secret,omitempty"`
+	// Runtime marks the settings PATCH /admin/config can change.
+	Runtime bool `json:"runtime,omitempty"`
+}
+
+// currentConfig reads every setting's value and source.
+func currentConfig() ConfigReport {
+	config.RLock()
+	report := ConfigReport{File: config.path}
+	config.RUnlock()
+	for i := range settings {
+		s := &settings[i]
+		v, source := s.value()
+		report.Settings = append(report.Settings, ConfigSetting{
+			Name:    s.name,
+			Type:    string(s.kind),
+			Value:   s.masked(v),
+			Source:  source,
+			Secret:  s.secret,
+			Runtime: s.apply != nil,
+		})
+	}
+	return report
+}
+
+func handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPatch {
+		if !patchConfig(w, r) {
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentConfig())
+}
+
+// patchConfig applies a PATCH /admin/config body, an object of setting
+// names to new values, all or none. It writes the error and returns false
+// if one is rejected.
+func patchConfig(w http.ResponseWriter, r *http.Request) bool {
+	var body map[string]json.RawMessage
+	if status, d := decodeJSONBody(w, r, 64<<10, &body); status != 0 {
+		writeError(w, status, d)
+		return false
+	}
+	changes := map[string]string{}
+	commits := map[string]func(){}
+	for k, raw := range body {
+		name := strings.ToUpper(k)
+		s, ok := lookupSetting(name)
+		if !ok {
+			writeError(w, http.StatusBadRequest, invalidParam(k, fmt.Sprintf("Unknown setting %s", k)))
+			return false
+		}
+		if s.apply == nil {
+			writeError(w, http.StatusBadRequest, invalidParam(k, fmt.Sprintf("%s can't be changed at runtime", name)))
+			return false
+		}
+		v, err := configString(raw)
+		if err == nil {
+			err = s.check(v)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, invalidParam(k, err.Error()))
+			return false
+		}
+		commit, err := s.apply(v)
+		if err != nil {
+			writeError(w, http.StatusConflict, ErrorDetail{Parameter: k, Message: fmt.Sprintf("%s: %v", name, err)})
+			return false
+		}
+		changes[name], commits[name] = v, commit
+	}
+	for _, name := range slices.Sorted(maps.Keys(changes)) {
+		s, _ := lookupSetting(name)
+		old, _ := s.value()
+		commits[name]()
+		config.Lock()
+		config.runtime[name] = changes[name]
+		config.Unlock()
+		slog.InfoContext(r.Context(), "Configuration changed", "setting", name, "from", s.masked(old), "to", s.masked(changes[name]))
+	}
+	return true
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/pkpass"
+)
+
+// ----------------------
+// ADMIN: PKPASS MAPPER SHADOWING
+// ----------------------
+
+// With PKPASS_SHADOW_MAPPER on, every .pkpass is mapped twice: by the
+// primary mapper (PKPASS_MAPPER), whose pass is the one sent, and by the
+// other one. Fields the two set differently are counted per field, logged,
+// and added to the failure log with their values anonymized (see
+// anonymize), so GET /admin/shadow can tell how often switching the
+// default would change an answer, and where. The flag goes away once the
+// default has switched.
+
+// shadowMapping is PKPASS_SHADOW_MAPPER.
+var shadowMapping bool
+
+// shadowFields are the fields compared, by their field_sources name.
+var shadowFields = []struct {
+	name string
+	get  func(*bcbp.UnifiedBoardingPass) string
+}{
+	{"flight_number", func(p *bcbp.UnifiedBoardingPass) string { return p.FlightNumber }},
+	{"carrier", func(p *bcbp.UnifiedBoardingPass) string { return p.Carrier }},
+	{"departure_airport", func(p *bcbp.UnifiedBoardingPass) string { return p.Departure }},
+	{"arrival_airport", func(p *bcbp.UnifiedBoardingPass) string { return p.Arrival }},
+	{"passenger_name", func(p *bcbp.UnifiedBoardingPass) string { return p.PassengerName }},
+	{"pnr", func(p *bcbp.UnifiedBoardingPass) string { return p.PNR }},
+	{"seat", func(p *bcbp.UnifiedBoardingPass) string { return p.Seat }},
+	{"seat_status", func(p *bcbp.UnifiedBoardingPass) string { return p.SeatStatus }},
+	{"cabin_class", func(p *bcbp.UnifiedBoardingPass) string { return p.CabinClass }},
+	{"date_iso", func(p *bcbp.UnifiedBoardingPass) string { return p.DateISO }},
+	{"boarding_time", func(p *bcbp.UnifiedBoardingPass) string { return p.BoardingTime }},
+	{"departure_time", func(p *bcbp.UnifiedBoardingPass) string { return p.DepartureTime }},
+	{"gate", func(p *bcbp.UnifiedBoardingPass) string { return p.Gate }},
+	{"terminal", func(p *bcbp.UnifiedBoardingPass) string { return p.Terminal }},
+	{"boarding_group", func(p *bcbp.UnifiedBoardingPass) string { return p.BoardingGroup }},
+	{"sequence_number", func(p *bcbp.UnifiedBoardingPass) string { return p.SequenceNumber }},
+	{"priority_boarding", func(p *bcbp.UnifiedBoardingPass) string { return strconv.FormatBool(p.PriorityBoarding) }},
+}
+
+func init() {
+	describeMetric("pkpass_shadow_passes_total", "counter", "Passes mapped by both pkpass mappers (PKPASS_SHADOW_MAPPER).")
+	describeMetric("pkpass_shadow_disagreements_total", "counter", "Passes the two pkpass mappers mapped differently, by field.")
+	metric("pkpass_shadow_passes_total")
+	for _, f := range shadowFields {
+		metric("pkpass_shadow_disagreements_total", "field", f.name)
+	}
+}
+
+// shadowStats are the counts GET /admin/shadow reports, since startup or
+// the last DELETE.
+var shadowStats = struct {
+	sync.Mutex
+	since         time.Time
+	passes        int64
+	disagreements map[string]int64
+}{since: time.Now().UTC(), disagreements: map[string]int64{}}
+
+// loadPkPassMapper reads PKPASS_MAPPER and PKPASS_SHADOW_MAPPER.
+func loadPkPassMapper(cfg Config) error {
+	m, err := pkpass.ParseMapper(cfg.PkPassMapper)
+	if err != nil {
+		return fmt.Errorf("PKPASS_MAPPER: %w", err)
+	}
+	pkpass.DefaultMapper = m
+	shadowMapping = cfg.PkPassShadowMapper
+	return nil
+}
+
+// shadowMapper is the mapper that isn't the primary one.
+func shadowMapper() pkpass.Mapper {
+	if pkpass.DefaultMapper == pkpass.MapSemantic {
+		return pkpass.MapKeywords
+	}
+	return pkpass.MapSemantic
+}
+
+// compareShadow maps the .pkpass of size bytes read through r with the
+// shadow mapper and records where it differs from primary, the pass the
+// primary mapper made of it.
+func compareShadow(ctx context.Context, r io.ReaderAt, size int64, primary *bcbp.UnifiedBoardingPass) {
+	_, span := tracer.Start(ctx, "shadow map pkpass")
+	shadow, err := pkpass.ParseReaderWith(r, size, time.Now(), shadowMapper())
+	endSpan(span, err)
+	if err != nil {
+		// Decoding is the same for both mappers, so this is rare.
+		slog.WarnContext(ctx, "Shadow pkpass mapper failed", "mapper", shadowMapper(), "err", err)
+		return
+	}
+
+	var fields, examples []string
+	for _, f := range shadowFields {
+		a, b := f.get(primary), f.get(shadow)
+		if a == b {
+			continue
+		}
+		fields = append(fields, f.name)
+		examples = append(examples, fmt.Sprintf("%s: %s %q, %s %q", f.name, pkpass.DefaultMapper, anonymize(a), shadowMapper(), anonymize(b)))
+	}
+
+	shadowStats.Lock()
+	shadowStats.passes++
+	for _, f := range fields {
+		shadowStats.disagreements[f]++
+	}
+	shadowStats.Unlock()
+	metric("pkpass_shadow_passes_total").Inc()
+	if len(fields) == 0 {
+		return
+	}
+	for _, f := range fields {
+		metric("pkpass_shadow_disagreements_total", "field", f).Inc()
+	}
+	slog.InfoContext(ctx, "Pkpass mappers disagree", "primary", pkpass.DefaultMapper, "shadow", shadowMapper(), "fields", fields)
+	if parseFailures != nil {
+		parseFailures.Add(ParseFailure{
+			Time:      time.Now().UTC(),
+			Endpoint:  "pkpass shadow mapper",
+			Code:      "mapper_disagreement",
+			Reason:    strings.Join(fields, ","),
+			Message:   strings.Join(examples, "; "),
+			InputSize: int(size),
+		})
+	}
+}
+
+// anonymize keeps the shape of a field value and nothing else: letters
+// become A (a in lower case), digits 9, the rest stays. "TP576" and
+// "Joao Silva" read "AA999" and "Aaaa Aaaaa".
+func anonymize(v string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsUpper(r):
+			return 'A'
+		case unicode.IsLetter(r):
+			return 'a'
+		case unicode.IsDigit(r):
+			return '9'
+		}
+		return r
+	}, v)
+}
+
+// ShadowReport is the body of GET /admin/shadow.
+type ShadowReport struct {
+	Primary pkpass.Mapper `json:"primary"`
+	Shadow  pkpass.Mapper `json:"shadow"`
+	Since   time.Time     `json:"since"`
+	Passes  int64         `json:"passes"`
+	// Fields has every compared field, most disagreements first.
+	Fields []ShadowField `json:"fields"`
+}
+
+// ShadowField is how often the mappers set one field differently.
+type ShadowField struct {
+	Field         string  `json:"field"`
+	Disagreements int64   `json:"disagreements"`
+	Rate          float64 `json:"rate"` // of Passes
+}
+
+func handleShadow(w http.ResponseWriter, r *http.Request) {
+	if !shadowMapping {
+		httpError(w, "Shadow mapping is disabled (PKPASS_SHADOW_MAPPER=false)", http.StatusNotImplemented)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		shadowStats.Lock()
+		report := ShadowReport{
+			Primary: pkpass.DefaultMapper,
+			Shadow:  shadowMapper(),
+			Since:   shadowStats.since,
+			Passes:  shadowStats.passes,
+		}
+		for _, f := range shadowFields {
+			sf := ShadowField{Field: f.name, Disagreements: shadowStats.disagreements[f.name]}
+			if report.Passes > 0 {
+				sf.Rate = float64(sf.Disagreements) / float64(report.Passes)
+			}
+			report.Fields = append(report.Fields, sf)
+		}
+		shadowStats.Unlock()
+		slices.SortStableFunc(report.Fields, func(a, b ShadowField) int {
+			return cmp.Compare(b.Disagreements, a.Disagreements)
+		})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+
+	case http.MethodDelete:
+		shadowStats.Lock()
+		shadowStats.since, shadowStats.passes = time.Now().UTC(), 0
+		clear(shadowStats.disagreements)
+		shadowStats.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
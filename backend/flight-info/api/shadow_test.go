@@ -0,0 +1,103 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"bugsbyte/flight-info/pkpass"
+)
+
+// pkpassForm is a multipart form uploading a .pkpass archive holding
+// passJSON.
+func pkpassForm(t *testing.T, passJSON []byte) (body *bytes.Buffer, contentType string) {
+	t.Helper()
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, b := range map[string][]byte{"pass.json": passJSON, "manifest.json": []byte("{}")} {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(b)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	body = &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	part, _ := mw.CreateFormFile("file", "pass.pkpass")
+	part.Write(archive.Bytes())
+	mw.Close()
+	return body, mw.FormDataContentType()
+}
+
+// TestShadowMapperDisagreement uploads a pass whose fields only the
+// semantic mapper reads fully, with keyword matching primary, and checks
+// that the disagreement is counted, logged anonymized and reported.
+func TestShadowMapperDisagreement(t *testing.T) {
+	setForTest(t, &shadowMapping, true)
+	setForTest(t, &pkpass.DefaultMapper, pkpass.MapKeywords)
+	setForTest(t, &parseFailures, newFailureLog(10))
+	setForTest(t, &adminToken, "secret")
+	h := Handler()
+	if w := serve(t, h, http.MethodDelete, "/admin/shadow", nil, "Authorization", "Bearer secret"); w.Code != http.StatusNoContent {
+		t.Fatalf("reset: status %d", w.Code)
+	}
+	before := metric("pkpass_shadow_passes_total").Value()
+
+	body, ct := pkpassForm(t, readFile(t, "../testdata/golden/mapper/semantic/ib-field-semantics.pass.json"))
+	w := serve(t, h, http.MethodPost, "/parse/pkpass", body, "Content-Type", ct)
+	if w.Code != http.StatusOK {
+		t.Fatalf("parse: status %d: %s", w.Code, w.Body)
+	}
+
+	w = serve(t, h, http.MethodGet, "/admin/shadow", nil, "Authorization", "Bearer secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("shadow: status %d: %s", w.Code, w.Body)
+	}
+	var report ShadowReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Primary != pkpass.MapKeywords || report.Shadow != pkpass.MapSemantic || report.Passes != 1 {
+		t.Fatalf("report %s vs %s over %d passes, want keywords vs semantic over 1", report.Primary, report.Shadow, report.Passes)
+	}
+	var disagree []string
+	for _, f := range report.Fields {
+		if f.Disagreements > 0 {
+			disagree = append(disagree, f.Field)
+			if f.Disagreements != 1 || f.Rate != 1 {
+				t.Errorf("%s: %d disagreements at rate %v, want 1 at 1", f.Field, f.Disagreements, f.Rate)
+			}
+		}
+	}
+	if !slices.Contains(disagree, "gate") || !slices.Contains(disagree, "pnr") {
+		t.Fatalf("disagreements on %v, want gate and pnr among them", disagree)
+	}
+	if got := metric("pkpass_shadow_passes_total").Value() - before; got != 1 {
+		t.Errorf("pkpass_shadow_passes_total went up by %d, want 1", got)
+	}
+
+	failures := parseFailures.List()
+	if len(failures) != 1 {
+		t.Fatalf("%d failure log entries, want 1", len(failures))
+	}
+	f := failures[0]
+	if f.Code != "mapper_disagreement" || f.Reason != strings.Join(disagree, ",") {
+		t.Errorf("failure %s %q, want mapper_disagreement %q", f.Code, f.Reason, strings.Join(disagree, ","))
+	}
+	for _, pii := range []string{"Garcia", "QW7RTZ", "3104"} {
+		if strings.Contains(f.Message, pii) {
+			t.Errorf("failure message %q has %q", f.Message, pii)
+		}
+	}
+	if !strings.Contains(f.Message, `gate: keywords "", semantic "A99"`) {
+		t.Errorf("failure message %q doesn't show the gate's shape", f.Message)
+	}
+}
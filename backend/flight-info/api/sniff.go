@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ----------------------
+// PARSING: UPLOAD CONTENT SNIFFING
+// ----------------------
+
+// Uploads are checked by their magic bytes before the full decode, so a PDF
+// sent to the image endpoint gets a 415 saying so instead of an image
+// decoder error.
+
+// uploadKind is what the first bytes of an upload look like.
+type uploadKind struct {
+	Name  string // detected_type in the error envelope
+	Label string // for the message: "a PDF"
+	// Endpoint takes this kind of file; "" when none does.
+	Endpoint string
+}
+
+var (
+	kindPNG    = uploadKind{"png", "a PNG image", "/parse/barcode/image"}
+	kindJPEG   = uploadKind{"jpeg", "a JPEG image", "/parse/barcode/image"}
+	kindGIF    = uploadKind{"gif", "a GIF image", "/parse/barcode/image"}
+	kindWebP   = uploadKind{"webp", "a WebP image", "/parse/barcode/image"}
+	kindBMP    = uploadKind{"bmp", "a BMP image", "/parse/barcode/image"}
+	kindHEIC   = uploadKind{"heic", "a HEIC/AVIF photo", ""}
+	kindPkPass = uploadKind{"pkpass", "a .pkpass (zip archive)", "/parse/pkpass"}
+	kindOffice = uploadKind{"office", "an Office document", ""}
+	// There is no PDF endpoint yet; once there is, set it here and the 415s
+	// will point at it.
+	kindPDF = uploadKind{"pdf", "a PDF", ""}
+)
+
+// sniffUpload recognizes data by its magic bytes; ok is false for anything
+// else, which is left to the real decoder to reject.
+func sniffUpload(data []byte) (kind uploadKind, ok bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return kindPNG, true
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return kindJPEG, true
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return kindGIF, true
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return kindWebP, true
+	case bytes.HasPrefix(data, []byte("BM")) && len(data) >= 26:
+		return kindBMP, true
+	case len(data) >= 12 && string(data[4:8]) == "ftyp" && isHEIFBrand(string(data[8:12])):
+		return kindHEIC, true
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return kindPDF, true
+	case bytes.HasPrefix(data, []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")): // .doc, .xls, .ppt
+		return kindOffice, true
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		if isOfficeZip(data) {
+			return kindOffice, true
+		}
+		return kindPkPass, true
+	}
+	return uploadKind{}, false
+}
+
+func isHEIFBrand(brand string) bool {
+	switch brand {
+	case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1", "avif", "avis":
+		return true
+	}
+	return false
+}
+
+// isOfficeZip tells .docx/.xlsx/.pptx and OpenDocument files, which are zips
+// too, from passes by the name of the first archive entry.
+func isOfficeZip(data []byte) bool {
+	if len(data) < 30 {
+		return false
+	}
+	n := int(binary.LittleEndian.Uint16(data[26:28]))
+	if len(data) < 30+n {
+		return false
+	}
+	name := string(data[30 : 30+n])
+	if name == "[Content_Types].xml" || name == "mimetype" {
+		return true
+	}
+	for _, dir := range []string{"_rels/", "docProps/", "word/", "xl/", "ppt/"} {
+		if strings.HasPrefix(name, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// isImage reports whether scan can decode the kind.
+func (k uploadKind) isImage() bool {
+	return k.Endpoint == "/parse/barcode/image"
+}
+
+// misroutedUpload is the 415 for an upload of kind sent to an endpoint that
+// doesn't take it.
+func misroutedUpload(kind uploadKind) (status int, d ErrorDetail) {
+	d = ErrorDetail{Reason: reasonUnsupportedType, DetectedType: kind.Name, UseEndpoint: kind.Endpoint}
+	switch {
+	case kind.Endpoint != "":
+		d.Message = fmt.Sprintf("This looks like %s; use %s", kind.Label, kind.Endpoint)
+	case kind == kindHEIC:
+		d.Message = "This looks like " + kind.Label + ", which can't be decoded; send a JPEG or PNG (or a screenshot) to /parse/barcode/image"
+	default:
+		d.Message = "This looks like " + kind.Label + ", which isn't supported; send a screenshot of the barcode to /parse/barcode/image"
+	}
+	return http.StatusUnsupportedMediaType, d
+}
+
+// checkImageUpload rejects uploads to the image endpoints that are
+// recognizably not a decodable image. status is 0 otherwise.
+func checkImageUpload(data []byte) (status int, d ErrorDetail) {
+	if kind, ok := sniffUpload(data); ok && !kind.isImage() {
+		return misroutedUpload(kind)
+	}
+	return 0, ErrorDetail{}
+}
+
+// checkPkPassUpload is checkImageUpload for /parse/pkpass.
+func checkPkPassUpload(data []byte) (status int, d ErrorDetail) {
+	if kind, ok := sniffUpload(data); ok && kind != kindPkPass {
+		return misroutedUpload(kind)
+	}
+	return 0, ErrorDetail{}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// zipOf is a zip archive holding empty files with names, in order.
+func zipOf(t *testing.T, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		if _, err := zw.Create(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// fileForm is a multipart form with data as its file field.
+func fileForm(data []byte) (body *bytes.Buffer, contentType string) {
+	body = &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	part, _ := mw.CreateFormFile("file", "upload")
+	part.Write(data)
+	mw.Close()
+	return body, mw.FormDataContentType()
+}
+
+// TestMisroutedUploads sends each kind of file the sniffer recognizes to an
+// endpoint that doesn't take it: each is a 415 naming the detected type and,
+// when there is one, the endpoint to use instead.
+func TestMisroutedUploads(t *testing.T) {
+	png := readFile(t, "data/selftest/ac-aztec.png")
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, checkerboard(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		data     []byte
+		detected string
+		use      string
+	}{
+		{"pdf to image", "/parse/barcode/image", []byte("%PDF-1.7\n1 0 obj\n<< /Type /Catalog >>\nendobj\n"), "pdf", ""},
+		{"pkpass to image", "/parse/barcode/image", zipOf(t, "pass.json", "manifest.json", "signature"), "pkpass", "/parse/pkpass"},
+		{"docx to image", "/parse/barcode/image", zipOf(t, "[Content_Types].xml", "word/document.xml"), "office", ""},
+		{"odt to image", "/parse/barcode/image", zipOf(t, "mimetype", "content.xml"), "office", ""},
+		{"doc to image", "/parse/barcode/image", append([]byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"), make([]byte, 504)...), "office", ""},
+		{"heic to image", "/parse/barcode/image", append([]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"), make([]byte, 64)...), "heic", ""},
+		{"avif to image", "/parse/barcode/image", append([]byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00avifmif1miaf"), make([]byte, 64)...), "heic", ""},
+		{"png to pkpass", "/parse/pkpass", png, "png", "/parse/barcode/image"},
+		{"jpeg to pkpass", "/parse/pkpass", jpg.Bytes(), "jpeg", "/parse/barcode/image"},
+		{"pdf to pkpass", "/parse/pkpass", []byte("%PDF-1.4\n"), "pdf", ""},
+		{"docx to pkpass", "/parse/pkpass", zipOf(t, "[Content_Types].xml", "_rels/.rels"), "office", ""},
+	}
+	h := Handler()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w *httptest.ResponseRecorder
+			if tt.target == "/parse/pkpass" {
+				body, contentType := fileForm(tt.data)
+				w = serve(t, h, http.MethodPost, tt.target, body, "Content-Type", contentType)
+			} else {
+				body := jsonBody(t, BarcodeImageRequest{Image: base64.StdEncoding.EncodeToString(tt.data)})
+				w = serve(t, h, http.MethodPost, tt.target, bytes.NewReader(body), "Content-Type", "application/json")
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			e := resp.Error
+			if w.Code != http.StatusUnsupportedMediaType || e.Reason != reasonUnsupportedType ||
+				e.DetectedType != tt.detected || e.UseEndpoint != tt.use {
+				t.Errorf("status %d, reason %q, detected_type %q, use_endpoint %q; want 415 %s, %q, %q",
+					w.Code, e.Reason, e.DetectedType, e.UseEndpoint, reasonUnsupportedType, tt.detected, tt.use)
+			}
+		})
+	}
+}
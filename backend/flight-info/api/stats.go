@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// PERSISTENCE: PARSE STATISTICS
+// ----------------------
+
+// With persistence on, every parse is counted in parse_stats by UTC hour,
+// source, carrier and error, so GET /stats can say how an event went
+// without a Prometheus server, and the counts survive restarts. Requests
+// only bump an in-memory counter (see statsRecorder.Add); the counts are
+// written every STATS_FLUSH_INTERVAL and at shutdown, in one transaction.
+// Responses served from the parse cache weren't parsed and aren't counted.
+
+// parseStats is nil when persistence is disabled.
+var parseStats *statsRecorder
+
+// statsRecorder batches parse counts for the store.
+type statsRecorder struct {
+	store    storage.Store
+	interval time.Duration
+	mu       sync.Mutex
+	pending  map[storage.StatsKey]int64
+}
+
+func newStatsRecorder(store storage.Store, interval time.Duration) *statsRecorder {
+	return &statsRecorder{store: store, interval: interval, pending: map[storage.StatsKey]int64{}}
+}
+
+// Add counts one parse at now. errCode is the error's reason or code, ""
+// if the parse succeeded.
+func (s *statsRecorder) Add(now time.Time, source, carrier, errCode string) {
+	k := storage.StatsKey{
+		Hour:    now.UTC().Truncate(time.Hour).Unix(),
+		Source:  source,
+		Carrier: strings.ToUpper(strings.TrimSpace(carrier)),
+		Err:     errCode,
+	}
+	s.mu.Lock()
+	s.pending[k]++
+	s.mu.Unlock()
+}
+
+// Run flushes every interval until ctx is cancelled.
+func (s *statsRecorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.Flush(); err != nil {
+			slog.Error("Error saving parse statistics", "err", err)
+		}
+	}
+}
+
+// Flush writes the pending counts. On error they are kept for the next
+// flush.
+func (s *statsRecorder) Flush() error {
+	s.mu.Lock()
+	batch := s.pending
+	s.pending = map[storage.StatsKey]int64{}
+	s.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := s.store.AddStats(batch)
+	if err != nil {
+		s.mu.Lock()
+		for k, n := range batch {
+			s.pending[k] += n
+		}
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// flushStats writes what is pending at shutdown, once requests are done.
+func flushStats() {
+	if parseStats == nil {
+		return
+	}
+	if err := parseStats.Flush(); err != nil {
+		slog.Error("Error saving parse statistics", "err", err)
+	}
+}
+
+// recordParse counts a parse that gave a pass.
+func recordParse(source, carrier string) {
+	if parseStats != nil {
+		parseStats.Add(time.Now(), source, carrier, "")
+	}
+}
+
+// recordParseError counts a parse of endpoint (HTTP path or gRPC method)
+// that failed with errCode.
+func recordParseError(endpoint, errCode string) {
+	if parseStats != nil {
+		parseStats.Add(time.Now(), inputKind(endpoint), "", errCode)
+	}
+}
+
+// inputKind is the kind of input endpoint takes, the source of a parse
+// that failed before there was a pass: barcode, pkpass or image.
+func inputKind(endpoint string) string {
+	e := strings.ToLower(endpoint)
+	switch {
+	case strings.Contains(e, "pkpass"):
+		return "pkpass"
+	case strings.Contains(e, "image"), strings.Contains(e, "scan"):
+		return "image"
+	}
+	return "barcode"
+}
+
+// ----------------------
+// HANDLERS: PARSE STATISTICS
+// ----------------------
+
+const (
+	// statsDefaultDays is the range of GET /stats without from.
+	statsDefaultDays = 7
+	// statsMaxDays caps the range of one GET /stats.
+	statsMaxDays = 366
+)
+
+// StatsReport is the body of GET /stats.
+type StatsReport struct {
+	From   string `json:"from"` // YYYY-MM-DD, UTC, inclusive
+	To     string `json:"to"`
+	By     string `json:"by"` // "day" or "hour"
+	Parsed int64  `json:"parsed"`
+	Failed int64  `json:"failed"`
+	// Series has one bucket per day or hour of the range, empty ones
+	// included.
+	Series []StatsBucket `json:"series"`
+	// Sources, Carriers and Errors break the totals down. Passes without
+	// a carrier are left out of Carriers.
+	Sources  map[string]StatsCount `json:"sources"`
+	Carriers map[string]int64      `json:"carriers"`
+	Errors   map[string]int64      `json:"errors"`
+}
+
+// StatsBucket is the counts of one day or hour.
+type StatsBucket struct {
+	Start  time.Time `json:"start"`
+	Parsed int64     `json:"parsed"`
+	Failed int64     `json:"failed"`
+}
+
+// StatsCount is the counts of one source.
+type StatsCount struct {
+	Parsed int64 `json:"parsed"`
+	Failed int64 `json:"failed"`
+}
+
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	if parseStats == nil {
+		httpError(w, "Parse statistics need persistence (set SQLITE_PATH or DATABASE_URL)", http.StatusNotImplemented)
+		return
+	}
+	q := r.URL.Query()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	day := func(name string, def time.Time) (time.Time, bool) {
+		v := q.Get(name)
+		if v == "" {
+			return def, true
+		}
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, invalidParam(name, name+" must be a YYYY-MM-DD date"))
+			return time.Time{}, false
+		}
+		return t, true
+	}
+	to, ok := day("to", today)
+	if !ok {
+		return
+	}
+	from, ok := day("from", to.AddDate(0, 0, 1-statsDefaultDays))
+	if !ok {
+		return
+	}
+	by := q.Get("by")
+	switch {
+	case by == "":
+		by = "day"
+	case by != "day" && by != "hour":
+		writeError(w, http.StatusBadRequest, invalidParam("by", "by must be day or hour"))
+		return
+	}
+	end := to.AddDate(0, 0, 1)
+	switch {
+	case from.After(to):
+		writeError(w, http.StatusBadRequest, invalidParam("from", "from must not be after to"))
+		return
+	case end.Sub(from) > statsMaxDays*24*time.Hour:
+		writeError(w, http.StatusBadRequest, invalidParam("from", "At most 366 days per request"))
+		return
+	}
+
+	// Counts not flushed yet are part of the answer.
+	if err := parseStats.Flush(); err != nil {
+		slog.ErrorContext(r.Context(), "Error saving parse statistics", "err", err)
+	}
+	counts, err := passStore.Stats(from, end)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading parse statistics", "err", err)
+		httpError(w, "Error reading parse statistics", http.StatusInternalServerError)
+		return
+	}
+
+	step := 24 * time.Hour
+	if by == "hour" {
+		step = time.Hour
+	}
+	report := StatsReport{
+		From:     from.Format(time.DateOnly),
+		To:       to.Format(time.DateOnly),
+		By:       by,
+		Series:   []StatsBucket{},
+		Sources:  map[string]StatsCount{},
+		Carriers: map[string]int64{},
+		Errors:   map[string]int64{},
+	}
+	buckets := map[int64]*StatsBucket{}
+	for t := from; t.Before(end); t = t.Add(step) {
+		report.Series = append(report.Series, StatsBucket{Start: t})
+	}
+	for i := range report.Series {
+		buckets[report.Series[i].Start.Unix()] = &report.Series[i]
+	}
+	for k, n := range counts {
+		b := buckets[time.Unix(k.Hour, 0).UTC().Truncate(step).Unix()]
+		src := report.Sources[k.Source]
+		if k.Err == "" {
+			report.Parsed += n
+			b.Parsed += n
+			src.Parsed += n
+			if k.Carrier != "" {
+				report.Carriers[k.Carrier] += n
+			}
+		} else {
+			report.Failed += n
+			b.Failed += n
+			src.Failed += n
+			report.Errors[k.Err] += n
+		}
+		report.Sources[k.Source] = src
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
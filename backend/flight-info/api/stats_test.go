@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bugsbyte/flight-info/storage"
+)
+
+// TestStatsSurviveRestart counts parses, flushes them as shutdown does,
+// closes the store and reopens it, as a restarted server would.
+func TestStatsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passes.db")
+	start := func() storage.Store {
+		s, err := storage.Open("sqlite:" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setForTest(t, &passStore, s)
+		setForTest(t, &parseStats, newStatsRecorder(s, time.Hour))
+		return s
+	}
+	h := Handler()
+
+	s := start()
+	for _, text := range []string{readFixture(t, "bcbp/ac-yul-fra-mandatory.bcbp"), readFixture(t, "bcbp/lh-ber-fra-jfk-two-legs.bcbp"), "hello"} {
+		postBarcode(t, h, "/parse/barcode", text)
+	}
+	flushStats()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = start()
+	defer s.Close()
+	postBarcode(t, h, "/parse/barcode", readFixture(t, "bcbp/ac-yul-fra-mandatory.bcbp"))
+	flushStats()
+
+	w := serve(t, h, http.MethodGet, "/stats", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var report StatsReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Parsed != 3 || report.Failed != 1 {
+		t.Errorf("parsed %d, failed %d; want 3 and 1 across the restart", report.Parsed, report.Failed)
+	}
+	if report.Carriers["AC"] != 2 || report.Carriers["LH"] != 1 {
+		t.Errorf("carriers = %v, want AC 2 and LH 1", report.Carriers)
+	}
+	if report.Errors[reasonNotBoardingPass] != 1 {
+		t.Errorf("errors = %v, want one %s", report.Errors, reasonNotBoardingPass)
+	}
+}
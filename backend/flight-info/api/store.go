@@ -0,0 +1,263 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// PERSISTENCE
+// ----------------------
+
+// passStore is nil when neither SQLITE_PATH nor DATABASE_URL is set; every
+// caller must check it.
+var passStore storage.Store
+
+// databaseURL is the pass store to open: DATABASE_URL, or SQLITE_PATH as a
+// sqlite: URL, "" when neither is set.
+func (c Config) databaseURL() (string, error) {
+	v, path := c.DatabaseURL, c.SQLitePath
+	switch {
+	case v != "" && path != "":
+		return "", errors.New("set SQLITE_PATH or DATABASE_URL, not both")
+	case path != "":
+		return "sqlite:" + path, nil
+	}
+	return v, nil
+}
+
+// redactDatabaseURL is a database URL fit for the log, without its
+// password.
+func redactDatabaseURL(v string) string {
+	if u, err := url.Parse(v); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return v
+}
+
+// passID is bcbp.StableID, for passes that didn't come straight from a
+// parser (backups, export requests) and may lack an ID.
+func passID(p *bcbp.UnifiedBoardingPass) string {
+	id, _ := bcbp.StableID(p)
+	return id
+}
+
+// persisting reports whether a parse request stores its pass: persistence
+// is enabled and the request doesn't opt out with ?persist=false, which
+// answers it as a server without persistence would.
+func persisting(q url.Values) bool {
+	return passStore != nil && q.Get("persist") != "false"
+}
+
+// persistPass stores a freshly parsed pass when persistence is enabled and
+// returns the pass to send back to the client. A re-scan of an already stored
+// pass returns the stored record flagged as a duplicate. When it differs
+// from the stored record (see bcbp.Diff), as a pass the airline re-issued
+// does, or with force, the stored record is overwritten and flagged as
+// updated, with the fields that changed. Either way the pass is tagged
+// with the request's client, if any. Storage failures are logged but never
+// fail the parse request.
+func persistPass(ctx context.Context, p *bcbp.UnifiedBoardingPass, force bool) *bcbp.UnifiedBoardingPass {
+	if passStore == nil {
+		return p
+	}
+
+	if !force {
+		sp, inserted, err := passStore.InsertIfAbsent(p)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error storing pass", "err", err)
+			return p
+		}
+		if inserted {
+			tagClient(ctx, sp.ID)
+			return p
+		}
+		if len(bcbp.Diff(sp.Pass, p)) == 0 {
+			tagClient(ctx, sp.ID)
+			sp.Pass.Duplicate = true
+			return sp.Pass
+		}
+	}
+
+	_, changes, err := passStore.Upsert(p)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error storing pass", "err", err)
+		return p
+	}
+	tagClient(ctx, p.ID)
+	p.Updated = changes != nil
+	if len(changes) > 0 {
+		p.Changes = changes
+	}
+	return p
+}
+
+// ----------------------
+// HANDLERS: STORED PASSES
+// ----------------------
+
+const (
+	defaultPassesLimit = 50
+	maxPassesLimit     = 200
+)
+
+// passFilterParams are the filters GET /passes and GET /passes/export.csv
+// understand; anything else is rejected so a typo doesn't silently return
+// every pass.
+var passFilterParams = []string{
+	"passenger", "pnr", "flight", "departure", "arrival",
+	"date_from", "date_to", "source", "client",
+}
+
+var passListParams = append(slices.Clone(passFilterParams), "limit", "offset")
+
+// passFilterFromQuery builds a filter from the request's query string,
+// rejecting parameters outside allowed. client=me is resolved to the
+// request's X-Client-ID. The error is meant for the client.
+func passFilterFromQuery(r *http.Request, allowed []string) (storage.PassFilter, error) {
+	q := r.URL.Query()
+	for name := range q {
+		// lang picks the error message language on every route, and raw
+		// and format shape every pass response.
+		if name != "lang" && name != "raw" && name != "format" && !slices.Contains(allowed, name) {
+			return storage.PassFilter{}, fmt.Errorf("Unknown query parameter %q; valid filters: %s",
+				name, strings.Join(allowed, ", "))
+		}
+	}
+
+	filter := storage.PassFilter{
+		Passenger: q.Get("passenger"),
+		PNR:       q.Get("pnr"),
+		Flight:    q.Get("flight"),
+		Departure: q.Get("departure"),
+		Arrival:   q.Get("arrival"),
+		DateFrom:  q.Get("date_from"),
+		DateTo:    q.Get("date_to"),
+		Source:    q.Get("source"),
+	}
+	for name, v := range map[string]string{"date_from": filter.DateFrom, "date_to": filter.DateTo} {
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, v); err != nil {
+			return storage.PassFilter{}, fmt.Errorf("%s must be a YYYY-MM-DD date", name)
+		}
+	}
+	if q.Has("client") {
+		var err error
+		if filter.Client, err = clientFilter(r); err != nil {
+			return storage.PassFilter{}, err
+		}
+	}
+	return filter, nil
+}
+
+// PassList is the body of GET /passes.
+type PassList struct {
+	Passes []*storage.StoredPass `json:"passes"`
+	Total  int                   `json:"total"`
+	Limit  int                   `json:"limit"`
+	Offset int                   `json:"offset"`
+}
+
+func handleListPasses(w http.ResponseWriter, r *http.Request) {
+	if passStore == nil {
+		httpError(w, "Persistence is disabled (set SQLITE_PATH or DATABASE_URL)", http.StatusNotImplemented)
+		return
+	}
+	if status, d := outputFormat(r.URL.Query()); status != 0 {
+		writeError(w, status, d)
+		return
+	}
+
+	filter, err := passFilterFromQuery(r, passListParams)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := queryInt(r, "limit", defaultPassesLimit)
+	if err != nil || limit < 1 || limit > maxPassesLimit {
+		httpError(w, fmt.Sprintf("limit must be between 1 and %d", maxPassesLimit), http.StatusBadRequest)
+		return
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		httpError(w, "offset must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+
+	passes, total, err := passStore.List(filter, limit, offset)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing passes", "err", err)
+		httpError(w, "Error listing passes", http.StatusInternalServerError)
+		return
+	}
+
+	shapeStored(r, passes...)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PassList{Passes: passes, Total: total, Limit: limit, Offset: offset})
+}
+
+func handlePassByID(w http.ResponseWriter, r *http.Request) {
+	if passStore == nil {
+		httpError(w, "Persistence is disabled (set SQLITE_PATH or DATABASE_URL)", http.StatusNotImplemented)
+		return
+	}
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		if status, d := outputFormat(r.URL.Query()); status != 0 {
+			writeError(w, status, d)
+			return
+		}
+		sp, err := passStore.Get(id)
+		if errors.Is(err, storage.ErrNotFound) {
+			httpError(w, "Pass not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error fetching pass", "pass", id, "err", err)
+			httpError(w, "Error fetching pass", http.StatusInternalServerError)
+			return
+		}
+		shapeStored(r, sp)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sp)
+
+	case http.MethodDelete:
+		err := passStore.Delete(id)
+		if errors.Is(err, storage.ErrNotFound) {
+			httpError(w, "Pass not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error deleting pass", "pass", id, "err", err)
+			httpError(w, "Error deleting pass", http.StatusInternalServerError)
+			return
+		}
+		pruneArtifacts(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/storage"
+)
+
+// TestRescanRecordsChanges re-scans a stored pass, first unchanged, then
+// re-issued with another seat: only the second updates the record, without
+// ?force, and keeps a revision.
+func TestRescanRecordsChanges(t *testing.T) {
+	store := useTestStore(t)
+	h := Handler()
+	barcode := readFixture(t, "bcbp/ac-yul-fra-mandatory.bcbp")
+	reissued := strings.Replace(barcode, "326J001A", "326J014C", 1)
+	if reissued == barcode {
+		t.Fatal("fixture has no seat 001A")
+	}
+
+	scan := func(text string) *bcbp.UnifiedBoardingPass {
+		t.Helper()
+		w := postBarcode(t, h, "/parse/barcode", text)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		var p bcbp.UnifiedBoardingPass
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		return &p
+	}
+
+	first := scan(barcode)
+	if again := scan(barcode); !again.Duplicate || again.Updated || again.Changes != nil {
+		t.Errorf("unchanged re-scan: duplicate %v, updated %v, changes %v; want a duplicate", again.Duplicate, again.Updated, again.Changes)
+	}
+
+	got := scan(reissued)
+	want := []bcbp.FieldChange{{Field: "seat", Old: "001A", New: "014C"}}
+	if got.ID != first.ID || got.Duplicate || !got.Updated || !slices.Equal(got.Changes, want) {
+		t.Errorf("re-issued pass: id %s, duplicate %v, updated %v, changes %v; want id %s updated with %v",
+			got.ID, got.Duplicate, got.Updated, got.Changes, first.ID, want)
+	}
+	sp, err := store.Get(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp.Pass.Seat != got.Seat {
+		t.Errorf("stored seat %q, want %q", sp.Pass.Seat, got.Seat)
+	}
+
+	w := serve(t, h, http.MethodGet, "/passes/"+first.ID+"/history", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("history: status %d: %s", w.Code, w.Body)
+	}
+	var history storage.PassHistory
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(history.Revisions); n != 2 || !slices.Equal(history.Revisions[1].Changes, want) {
+		t.Errorf("history = %+v, want the first version and the seat change", history.Revisions)
+	}
+}
+
+// TestListPassesFormat lists and exports a stored pass with each output
+// profile: the list routes take ?format like the parse routes, and reject
+// an unknown profile for the same reason.
+func TestListPassesFormat(t *testing.T) {
+	useTestStore(t)
+	h := Handler()
+	if w := postBarcode(t, h, "/parse/barcode", readFixture(t, "bcbp/ac-yul-fra-mandatory.bcbp")); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	list := func(format string) *bcbp.UnifiedBoardingPass {
+		t.Helper()
+		w := serve(t, h, http.MethodGet, "/passes?format="+format, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("format=%s: status %d: %s", format, w.Code, w.Body)
+		}
+		var list struct {
+			Passes []struct {
+				Pass *bcbp.UnifiedBoardingPass `json:"pass"`
+			} `json:"passes"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		if len(list.Passes) != 1 {
+			t.Fatalf("format=%s: %d passes, want 1", format, len(list.Passes))
+		}
+		return list.Passes[0].Pass
+	}
+
+	def := list("default")
+	if def.Seat != "001A" || def.FlightNumber != "0834" || def.DateISO == "" {
+		t.Fatalf("format=default: seat %q, flight %q, date %q; want the parser's output", def.Seat, def.FlightNumber, def.DateISO)
+	}
+	date, err := time.Parse(time.DateOnly, def.DateISO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDate := strings.ToUpper(date.Format("02Jan06"))
+	if dcs := list("dcs"); dcs.Seat != "1A" || dcs.FlightNumber != "0834" || dcs.DateISO != wantDate {
+		t.Errorf("format=dcs: seat %q, flight %q, date %q; want 1A, 0834, %s", dcs.Seat, dcs.FlightNumber, dcs.DateISO, wantDate)
+	}
+
+	w := serve(t, h, http.MethodGet, "/passes/export.csv?format=dcs", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("export: status %d: %s", w.Code, w.Body)
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	col := func(name string) int { return slices.Index(passCSVHeader, name) }
+	if len(rows) != 2 || rows[1][col("seat")] != "1A" || rows[1][col("date_iso")] != wantDate {
+		t.Errorf("export rows = %q, want one with seat 1A and date %s", rows, wantDate)
+	}
+
+	for _, target := range []string{"/passes?format=bogus", "/passes/export.csv?format=bogus", "/trips?format=bogus"} {
+		w := serve(t, h, http.MethodGet, target, nil)
+		var e ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &e)
+		if w.Code != http.StatusBadRequest || e.Error.Reason != "invalid_parameter" || e.Error.Parameter != "format" {
+			t.Errorf("%s: status %d: %s; want 400 invalid_parameter for format", target, w.Code, w.Body)
+		}
+	}
+}
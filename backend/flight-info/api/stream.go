@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ----------------------
+// RESPONSES: STREAMED ITEMS
+// ----------------------
+
+// A synchronous batch of 50 photos takes a while, and its items are ready
+// one at a time. Rather than building the whole body and sending it at the
+// end, such responses go out item by item, each flushed as soon as it is
+// written: as the elements of a JSON array by default, or as NDJSON, one
+// JSON value per line, for clients that send Accept: application/x-ndjson.
+// A write blocks while the client is slow to read, and with it whoever
+// produces the next item, so a slow client slows the work down instead of
+// having the server buffer it. Once the first item is out the status can't
+// change any more; a failure after that ends the stream with an error
+// element rather than cutting it short.
+
+// ndjsonType is the media type of newline-delimited JSON.
+const ndjsonType = "application/x-ndjson"
+
+// wantNDJSON reports whether r's Accept header lists NDJSON.
+func wantNDJSON(r *http.Request) bool {
+	for _, v := range r.Header.Values("Accept") {
+		for _, t := range strings.Split(v, ",") {
+			if mt, _, err := mime.ParseMediaType(t); err == nil && mt == ndjsonType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// itemStream writes a 200 response one item at a time. In JSON it is the
+// array of an object: open is written before the first item, and the
+// fields of end's tail after the last.
+type itemStream struct {
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	ndjson bool
+	n      int   // items written
+	err    error // the first write error; the client is gone
+}
+
+// newItemStream sends the headers and, in JSON, open (e.g. `{"items":[`).
+func newItemStream(w http.ResponseWriter, r *http.Request, open string) *itemStream {
+	s := &itemStream{w: w, rc: http.NewResponseController(w), ndjson: wantNDJSON(r)}
+	if s.ndjson {
+		w.Header().Set("Content-Type", ndjsonType)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("X-Accel-Buffering", "no") // nginx
+	w.WriteHeader(http.StatusOK)
+	if !s.ndjson {
+		s.write([]byte(open))
+	}
+	return s
+}
+
+// item writes v as the next element and flushes it. The error is v's
+// encoding error; write errors only make the later writes no-ops, since
+// the client that would read them is gone.
+func (s *itemStream) item(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	switch {
+	case s.ndjson:
+		b = append(b, '\n')
+	case s.n > 0:
+		b = append([]byte(",\n"), b...)
+	default:
+		b = append([]byte("\n"), b...)
+	}
+	s.write(b)
+	s.n++
+	return nil
+}
+
+// end writes tail, a struct encoded as a JSON object: in NDJSON it is the
+// last line, in JSON its fields follow the array and close the object.
+func (s *itemStream) end(tail any) {
+	b, err := json.Marshal(tail)
+	if err != nil || len(b) < 2 {
+		b = []byte(`{}`)
+	}
+	if s.ndjson {
+		s.write(append(b, '\n'))
+		return
+	}
+	sep := "\n],"
+	if len(b) == 2 {
+		sep = "\n]"
+	}
+	s.write(append([]byte(sep), append(b[1:], '\n')...))
+}
+
+func (s *itemStream) write(b []byte) {
+	if s.err != nil {
+		return
+	}
+	if _, s.err = s.w.Write(b); s.err == nil {
+		s.err = s.rc.Flush()
+	}
+}
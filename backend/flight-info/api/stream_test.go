@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestItemStreamFirstByte reads the first item of a stream while the
+// handler is still blocked before the second: it has to be flushed, not
+// held until the response is complete.
+func TestItemStreamFirstByte(t *testing.T) {
+	first, _ := json.Marshal(BatchItem{Index: 0})
+	for _, accept := range []string{"application/json", ndjsonType} {
+		t.Run(accept, func(t *testing.T) {
+			release := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				s := newItemStream(w, r, `{"items":[`)
+				s.item(BatchItem{Index: 0})
+				<-release
+				s.item(BatchItem{Index: 1})
+				s.end(BatchSummary{Succeeded: 2})
+			}))
+			defer srv.Close()
+			released := false
+			defer func() {
+				if !released {
+					close(release)
+				}
+			}()
+
+			req, _ := http.NewRequest(http.MethodPost, srv.URL, nil)
+			req.Header.Set("Accept", accept)
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if got := resp.Header.Get("Content-Type"); got != accept {
+				t.Errorf("Content-Type = %q, want %q", got, accept)
+			}
+
+			// chunks gets the body as it arrives.
+			chunks := make(chan string)
+			go func() {
+				defer close(chunks)
+				buf := make([]byte, 512)
+				for {
+					n, err := resp.Body.Read(buf)
+					if n > 0 {
+						chunks <- string(buf[:n])
+					}
+					if err != nil {
+						return
+					}
+				}
+			}()
+			var got strings.Builder
+			for !strings.Contains(got.String(), string(first)) {
+				select {
+				case c, ok := <-chunks:
+					if !ok {
+						t.Fatalf("body ended before the first item: %q", got.String())
+					}
+					got.WriteString(c)
+				case <-time.After(5 * time.Second):
+					t.Fatalf("no first item while the handler is blocked; got %q", got.String())
+				}
+			}
+
+			released = true
+			close(release)
+			for c := range chunks {
+				got.WriteString(c)
+			}
+			body := got.String()
+			if accept == ndjsonType {
+				lines := strings.Split(strings.TrimSpace(body), "\n")
+				if len(lines) != 3 {
+					t.Fatalf("%d lines, want 2 items and the summary:\n%s", len(lines), body)
+				}
+				for _, l := range lines {
+					if !json.Valid([]byte(l)) {
+						t.Errorf("line %q isn't JSON", l)
+					}
+				}
+				return
+			}
+			var res BatchResult
+			if err := json.Unmarshal([]byte(body), &res); err != nil {
+				t.Fatalf("%v:\n%s", err, body)
+			}
+			if len(res.Items) != 2 || res.Items[1].Index != 1 || res.Succeeded != 2 {
+				t.Errorf("body = %s", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"archive/zip"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/pkpass"
+)
+
+// ----------------------
+// TRACING: OPENTELEMETRY SPANS AND OTLP EXPORT
+// ----------------------
+
+// tracer creates the api spans. Until setupTracing installs an SDK it is the
+// global no-op tracer.
+var tracer = otel.Tracer("bugsbyte/flight-info/api")
+
+// tracingEnabled is set by setupTracing; when false the middleware and
+// interceptors below pass requests straight through.
+var tracingEnabled bool
+
+// setupTracing exports spans over OTLP when OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set; the exporters read the other
+// standard OTEL_ variables (headers, timeout, insecure, ...) themselves.
+// OTEL_EXPORTER_OTLP_PROTOCOL picks "http/protobuf" (default) or "grpc".
+func setupTracing(ctx context.Context, cfg Config) error {
+	if cfg.OTLPEndpoint == "" && cfg.OTLPTracesEndpoint == "" {
+		return nil
+	}
+	if cfg.OTELSDKDisabled || cfg.OTELTracesExporter == "none" {
+		return nil
+	}
+
+	protocol := cmp.Or(cfg.OTLPTracesProtocol, cfg.OTLPProtocol)
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch protocol {
+	case "http/protobuf":
+		exporter, err = otlptracehttp.New(ctx)
+	case "grpc":
+		exporter, err = otlptracegrpc.New(ctx)
+	default:
+		return fmt.Errorf("OTEL_EXPORTER_OTLP_PROTOCOL: unsupported protocol %q (want http/protobuf or grpc)", protocol)
+	}
+	if err != nil {
+		return err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default name.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "flight-info")),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK())
+	if err != nil {
+		return err
+	}
+
+	// The SDK reads OTEL_TRACES_SAMPLER and the OTEL_BSP_ batching variables.
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res)))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Warn("Error exporting spans", "err", err)
+	}))
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	tracingEnabled = true
+	return nil
+}
+
+// tracingMiddleware continues the trace of an incoming traceparent header, or
+// starts one, with a server span per request.
+func tracingMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !tracingEnabled {
+			next(w, r)
+			return
+		}
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method+" "+r.URL.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("http.request.id", r.Header.Get("X-Request-ID"))))
+		defer span.End()
+
+		rec := &accessRecorder{ResponseWriter: w}
+		next(rec, r.WithContext(ctx))
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	}
+}
+
+// traceUnary and traceStream are tracingMiddleware for gRPC, reading
+// traceparent from the incoming metadata.
+func traceUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !tracingEnabled {
+		return handler(ctx, req)
+	}
+	ctx, span := startRPCSpan(ctx, info.FullMethod)
+	defer span.End()
+	resp, err := handler(ctx, req)
+	endRPCSpan(span, err)
+	return resp, err
+}
+
+func traceStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !tracingEnabled {
+		return handler(srv, ss)
+	}
+	ctx, span := startRPCSpan(ss.Context(), info.FullMethod)
+	defer span.End()
+	err := handler(srv, &loggedStream{ServerStream: ss, ctx: ctx})
+	endRPCSpan(span, err)
+	return err
+}
+
+func startRPCSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	service, name, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	return tracer.Start(ctx, strings.TrimPrefix(method, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.service", service),
+			attribute.String("rpc.method", name)))
+}
+
+func endRPCSpan(span trace.Span, err error) {
+	st := grpcstatus.Convert(err)
+	span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(st.Code())))
+	if err != nil {
+		span.SetStatus(codes.Error, st.Message())
+	}
+}
+
+// metadataCarrier lets the propagator read gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) { metadata.MD(c).Set(key, value) }
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// parseBCBP and parsePKPass wrap bcbp.ParseAt and pkpass.Parse in "parse
+// BCBP" and "unzip pkpass" spans; those packages also build for wasm and stay
+// free of tracing. ref is the day the Julian date is resolved around.
+func parseBCBP(ctx context.Context, raw string, ref time.Time, lenient bool) (*bcbp.UnifiedBoardingPass, error) {
+	_, span := tracer.Start(ctx, "parse BCBP", trace.WithAttributes(
+		attribute.Int("bcbp.length", len(raw)),
+		attribute.Bool("bcbp.lenient", lenient)))
+	parse := bcbp.ParseAt
+	if lenient {
+		parse = bcbp.ParseLenientAt
+	}
+	pass, err := parse(raw, ref)
+	endSpan(span, err)
+	return pass, err
+}
+
+func parsePKPass(ctx context.Context, r io.ReaderAt, size int64) (*bcbp.UnifiedBoardingPass, error) {
+	_, span := tracer.Start(ctx, "unzip pkpass", trace.WithAttributes(attribute.Int64("pkpass.bytes", size)))
+	if span.IsRecording() {
+		// Reading the central directory is cheap, but skipped when untraced.
+		if zr, err := zip.NewReader(r, size); err == nil {
+			span.SetAttributes(attribute.Int("pkpass.entries", len(zr.File)))
+		}
+	}
+	pass, err := pkpass.ParseReader(r, size)
+	endSpan(span, err)
+	if err == nil && shadowMapping {
+		compareShadow(ctx, r, size, pass)
+	}
+	return pass, err
+}
+
+// endSpan records err, if any, on the span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
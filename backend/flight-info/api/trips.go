@@ -0,0 +1,197 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/storage"
+)
+
+// ----------------------
+// TRIPS: GROUPING STORED PASSES
+// ----------------------
+
+// Trip is every stored leg sharing a PNR and passenger, e.g. the two passes
+// of LIS→FRA→NRT or the outbound and return of LIS→FRA→LIS. A pass whose
+// barcode covers several legs counts each of them in LegCount.
+type Trip struct {
+	PNR              string     `json:"pnr"`
+	PassengerName    string     `json:"passenger_name"`
+	Origin           string     `json:"origin"`
+	FinalDestination string     `json:"final_destination"`
+	LegCount         int        `json:"leg_count"`
+	Legs             []*TripLeg `json:"legs"`
+	Warnings         []string   `json:"warnings,omitempty"`
+}
+
+// TripLeg is a stored pass of a trip. Infant is the pass of the lap
+// infant linked with it (see storage.StoredPass.LinkedPassID), which
+// makes no trip of its own.
+type TripLeg struct {
+	*storage.StoredPass
+	Infant *storage.StoredPass `json:"infant,omitempty"`
+}
+
+// groupTrips groups passes by PNR + passenger and orders each trip's legs by
+// resolved date and time. Legs without a resolved date go last. Passes
+// without a PNR can't be linked to anything, so each becomes its own trip.
+// An infant's pass linked with a listed adult's is nested under that leg.
+func groupTrips(passes []*storage.StoredPass) []*Trip {
+	var (
+		trips  []*Trip
+		byKey  = map[string]*Trip{}
+		normal = func(s string) string { return strings.ToUpper(strings.TrimSpace(s)) }
+		byID   = map[string]*storage.StoredPass{}
+		infant = map[string]*storage.StoredPass{} // by the ID of its adult's pass
+	)
+	for _, sp := range passes {
+		byID[sp.ID] = sp
+	}
+	for _, sp := range passes {
+		if adult := byID[sp.LinkedPassID]; adult != nil && sp.Pass.PassengerType == bcbp.PassengerInfant && adult.Pass.PassengerType != bcbp.PassengerInfant {
+			infant[adult.ID] = sp
+		}
+	}
+	for _, sp := range passes {
+		if adult := byID[sp.LinkedPassID]; adult != nil && infant[adult.ID] == sp {
+			continue
+		}
+		key := normal(sp.Pass.PNR) + "|" + normal(sp.Pass.PassengerName)
+		t := byKey[key]
+		if t == nil || normal(sp.Pass.PNR) == "" {
+			t = &Trip{PNR: sp.Pass.PNR, PassengerName: sp.Pass.PassengerName}
+			trips = append(trips, t)
+			byKey[key] = t
+		}
+		t.Legs = append(t.Legs, &TripLeg{StoredPass: sp, Infant: infant[sp.ID]})
+	}
+
+	for _, t := range trips {
+		sort.SliceStable(t.Legs, func(i, j int) bool {
+			a, b := t.Legs[i].Pass, t.Legs[j].Pass
+			if (a.DateISO == "") != (b.DateISO == "") {
+				return a.DateISO != ""
+			}
+			if a.DateISO != b.DateISO {
+				return a.DateISO < b.DateISO
+			}
+			if ta, tb := legClock(a), legClock(b); ta != tb {
+				return ta < tb
+			}
+			return t.Legs[i].CreatedAt.Before(t.Legs[j].CreatedAt)
+		})
+
+		undated := 0
+		for _, leg := range t.Legs {
+			if leg.Pass.DateISO == "" {
+				undated++
+			}
+		}
+		if undated > 0 {
+			t.Warnings = append(t.Warnings, fmt.Sprintf(
+				"%d of %d legs have no resolvable date and are ordered last", undated, len(t.Legs)))
+		}
+
+		t.LegCount = 0
+		for _, leg := range t.Legs {
+			t.LegCount += max(len(leg.Pass.Legs), 1)
+		}
+		t.Origin = passOrigin(t.Legs[0].Pass)
+		t.FinalDestination = passDestination(t.Legs[len(t.Legs)-1].Pass)
+	}
+
+	// Most recently scanned trips first, matching GET /passes.
+	sort.SliceStable(trips, func(i, j int) bool {
+		return latestScan(trips[i]) > latestScan(trips[j])
+	})
+	return trips
+}
+
+// passOrigin and passDestination are where a pass starts and ends: the
+// first and last of its Legs when one barcode covers several, else its
+// own airports.
+func passOrigin(p *bcbp.UnifiedBoardingPass) string {
+	if len(p.Legs) > 0 {
+		return p.Legs[0].Departure
+	}
+	return p.Departure
+}
+
+func passDestination(p *bcbp.UnifiedBoardingPass) string {
+	if len(p.Legs) > 0 {
+		return p.Legs[len(p.Legs)-1].Arrival
+	}
+	return p.Arrival
+}
+
+// legClock is the best known time of day for ordering legs on the same date;
+// unknown times sort after known ones.
+func legClock(p *bcbp.UnifiedBoardingPass) string {
+	if p.DepartureTime != "" {
+		return p.DepartureTime
+	}
+	if p.BoardingTime != "" {
+		return p.BoardingTime
+	}
+	return "99:99"
+}
+
+func latestScan(t *Trip) int64 {
+	var latest int64
+	for _, leg := range t.Legs {
+		if ms := leg.CreatedAt.UnixMilli(); ms > latest {
+			latest = ms
+		}
+	}
+	return latest
+}
+
+// TripList is the body of GET /trips.
+type TripList struct {
+	Trips []*Trip `json:"trips"`
+	Total int     `json:"total"`
+}
+
+func handleTrips(w http.ResponseWriter, r *http.Request) {
+	if passStore == nil {
+		httpError(w, "Persistence is disabled (set SQLITE_PATH or DATABASE_URL)", http.StatusNotImplemented)
+		return
+	}
+	if status, d := outputFormat(r.URL.Query()); status != 0 {
+		writeError(w, status, d)
+		return
+	}
+
+	passes, err := passStore.ListAll(storage.PassFilter{})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing passes", "err", err)
+		httpError(w, "Error listing trips", http.StatusInternalServerError)
+		return
+	}
+
+	// The window moves with the clock, so the date_suspect warning a pass
+	// was stored with may be stale: check it again now.
+	if r.URL.Query().Get("include_suspect") != "true" {
+		now := timeNow()
+		passes = slices.DeleteFunc(passes, func(sp *storage.StoredPass) bool {
+			_, outside := outsideDateWindow(sp.Pass, now)
+			return outside
+		})
+	}
+
+	// Shaped after grouping: a format may rewrite the dates trips are
+	// ordered by.
+	trips := groupTrips(passes)
+	shapeStored(r, passes...)
+	if trips == nil {
+		trips = []*Trip{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TripList{Trips: trips, Total: len(trips)})
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/storage"
+)
+
+// TestTripsMultiLegBarcode stores one barcode covering BER→FRA→JFK: the
+// trip ends at JFK and counts both legs.
+func TestTripsMultiLegBarcode(t *testing.T) {
+	useTestStore(t)
+	h := Handler()
+	if w := postBarcode(t, h, "/parse/barcode", readFixture(t, "bcbp/lh-ber-fra-jfk-two-legs.bcbp")); w.Code != http.StatusOK {
+		t.Fatalf("parse: status %d: %s", w.Code, w.Body)
+	}
+
+	w := serve(t, h, http.MethodGet, "/trips?include_suspect=true", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var list TripList
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 1 {
+		t.Fatalf("%d trips, want 1", list.Total)
+	}
+	trip := list.Trips[0]
+	if trip.Origin != "BER" || trip.FinalDestination != "JFK" || trip.LegCount != 2 || len(trip.Legs) != 1 {
+		t.Errorf("trip %s→%s, leg_count %d, %d passes; want BER→JFK, 2, 1",
+			trip.Origin, trip.FinalDestination, trip.LegCount, len(trip.Legs))
+	}
+}
+
+// TestGroupTripsMixedLegs joins a two-leg pass with a later single-leg
+// one under the same PNR.
+func TestGroupTripsMixedLegs(t *testing.T) {
+	pass := func(id, date, dep, arr string, legs ...bcbp.PassengerLeg) *storage.StoredPass {
+		return &storage.StoredPass{ID: id, Pass: &bcbp.UnifiedBoardingPass{
+			PNR: "KLM4PQ", PassengerName: "MUELLER/ANNA", DateISO: date,
+			Departure: dep, Arrival: arr, Legs: legs,
+		}}
+	}
+	passes := []*storage.StoredPass{
+		pass("return", "2026-02-20", "JFK", "BER"),
+		pass("out", "2026-02-14", "BER", "FRA",
+			bcbp.PassengerLeg{Departure: "BER", Arrival: "FRA"},
+			bcbp.PassengerLeg{Departure: "FRA", Arrival: "JFK"}),
+	}
+	trips := groupTrips(passes)
+	if len(trips) != 1 {
+		t.Fatalf("%d trips, want 1", len(trips))
+	}
+	trip := trips[0]
+	if trip.Origin != "BER" || trip.FinalDestination != "BER" || trip.LegCount != 3 {
+		t.Errorf("trip %s→%s, leg_count %d; want BER→BER, 3", trip.Origin, trip.FinalDestination, trip.LegCount)
+	}
+	if trip.Legs[0].ID != "out" {
+		t.Errorf("first pass %s, want out", trip.Legs[0].ID)
+	}
+}
+
+// TestTripsDateWindowMovesWithClock stores a pass while its flight is in the
+// window and checks that it leaves /trips once the clock passes the flight,
+// whatever the pass was stored with.
+func TestTripsDateWindowMovesWithClock(t *testing.T) {
+	useTestStore(t)
+	// Four days before the fixture's Julian day 045.
+	now := time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)
+	setForTest(t, &timeNow, func() time.Time { return now })
+	h := Handler()
+	if w := postBarcode(t, h, "/parse/barcode", readFixture(t, "bcbp/lh-ber-fra-jfk-two-legs.bcbp")); w.Code != http.StatusOK {
+		t.Fatalf("parse: status %d: %s", w.Code, w.Body)
+	}
+
+	trips := func() TripList {
+		t.Helper()
+		w := serve(t, h, http.MethodGet, "/trips", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		var list TripList
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		return list
+	}
+	list := trips()
+	if list.Total != 1 {
+		t.Fatalf("at scan time: %d trips, want 1", list.Total)
+	}
+
+	flight, err := time.Parse(time.DateOnly, list.Trips[0].Legs[0].Pass.DateISO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = flight.Add(dateWindowPast + 72*time.Hour)
+	if list := trips(); list.Total != 0 {
+		t.Errorf("after the flight: %d trips, want 0", list.Total)
+	}
+}
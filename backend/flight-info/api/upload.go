@@ -0,0 +1,113 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+)
+
+// ----------------------
+// PARSING: STREAMED UPLOADS
+// ----------------------
+
+// /parse/pkpass reads its multipart body part by part instead of through
+// ParseMultipartForm, which buffers the whole form and then had the file
+// copied once more. The file part is spooled to memory while it is small
+// and to a temporary file past that, so a request holds at most
+// pkpassMemUpload bytes however large the upload; the body as a whole is
+// still capped at maxPkPassUpload.
+
+const (
+	// maxPkPassUpload caps the multipart body of /parse/pkpass.
+	maxPkPassUpload = 10 << 20
+	// pkpassMemUpload is how much of an upload is kept in memory before it
+	// moves to a temporary file. Real passes are well under it.
+	pkpassMemUpload = 1 << 20
+	// sniffLen is how much of the start of an upload is kept for sniffing
+	// and failure samples.
+	sniffLen = 512
+)
+
+// errNoFilePart is returned by readFilePart for a form without a file part
+// of the requested name.
+var errNoFilePart = errors.New("no file part in the form")
+
+// upload is a spooled file part. Close removes its temporary file, if any.
+type upload struct {
+	r    io.ReaderAt
+	size int64
+	sum  [sha256.Size]byte
+	head []byte // the first sniffLen bytes
+	file *os.File
+}
+
+func (u *upload) Close() error {
+	if u.file == nil {
+		return nil
+	}
+	u.file.Close()
+	return os.Remove(u.file.Name())
+}
+
+// readFilePart streams r's multipart body up to the first part named field
+// and spools that part. Errors past the body limit satisfy tooLarge.
+func readFilePart(w http.ResponseWriter, r *http.Request, field string, limit int64) (*upload, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, errNoFilePart
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == field {
+			return spoolPart(part)
+		}
+		part.Close()
+	}
+}
+
+// spoolPart copies part to memory, moving to a temporary file once it
+// outgrows pkpassMemUpload, and hashes it on the way.
+func spoolPart(part *multipart.Part) (*upload, error) {
+	u := &upload{}
+	h := sha256.New()
+	src := io.TeeReader(part, h)
+	var mem bytes.Buffer
+	n, err := io.CopyN(&mem, src, pkpassMemUpload+1)
+	switch {
+	case err == io.EOF:
+		u.r = bytes.NewReader(mem.Bytes())
+	case err != nil:
+		return nil, err
+	default:
+		if u.file, err = os.CreateTemp("", "pkpass-*"); err != nil {
+			return nil, err
+		}
+		m, err := io.Copy(u.file, io.MultiReader(&mem, src))
+		if err != nil {
+			u.Close()
+			return nil, err
+		}
+		n = m
+		u.r = u.file
+	}
+	u.size = n
+	h.Sum(u.sum[:0])
+	head := make([]byte, min(u.size, sniffLen))
+	if _, err := u.r.ReadAt(head, 0); err != nil && err != io.EOF {
+		u.Close()
+		return nil, err
+	}
+	u.head = head
+	return u, nil
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// junk is an endless stream of one byte, so a large upload needn't be held
+// in memory by the test either.
+type junk byte
+
+func (j junk) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(j)
+	}
+	return len(p), nil
+}
+
+func TestPkPassUploadTooLarge(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	const size = 30 << 20
+	body := &countingReader{r: io.MultiReader(
+		strings.NewReader("--b\r\nContent-Disposition: form-data; name=\"file\"; filename=\"big.pkpass\"\r\n\r\nPK"),
+		io.LimitReader(junk('x'), size),
+		strings.NewReader("\r\n--b--\r\n"),
+	)}
+
+	w := serve(t, Handler(), http.MethodPost, "/parse/pkpass", body, "Content-Type", "multipart/form-data; boundary=b")
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status %d, want 413: %s", w.Code, w.Body)
+	}
+	if got := errorReason(t, w.Body.Bytes()); got != reasonTooLarge {
+		t.Errorf("reason %q, want %q", got, reasonTooLarge)
+	}
+	if !strings.Contains(w.Body.String(), `"message":"Upload too large"`) {
+		t.Errorf("body %s", w.Body)
+	}
+	// The upload is cut off at the limit, not read to the end.
+	if body.n > maxPkPassUpload+1<<20 {
+		t.Errorf("read %d bytes of the upload, limit is %d", body.n, maxPkPassUpload)
+	}
+	// Nor is the part spooled past it left behind.
+	if files, _ := os.ReadDir(tmp); len(files) > 0 {
+		t.Errorf("%d temporary files left", len(files))
+	}
+}
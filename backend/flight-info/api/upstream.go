@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// ----------------------
+// OUTBOUND HTTP: CIRCUIT BREAKERS AND BUDGETS
+// ----------------------
+
+// Every request the server makes (flight status, pass refresh, webhooks,
+// push notifications, shared pass links) goes through a breakerTransport,
+// which keeps a circuit breaker per host. After breakerFailures consecutive failures a
+// host's breaker opens and calls to it fail at once with errBreakerOpen,
+// instead of each waiting out its timeout. After breakerCooldown one call
+// is let through as a probe (half open): its success closes the breaker,
+// its failure opens it for another cooldown. Timeouts stay with each
+// caller, as before.
+//
+// Each parse also has a time budget, REQUEST_BUDGET from the moment its
+// request arrives (see withBudget). Enrichment that calls out is capped
+// at what is left of it, and skipped with a warning once less than a
+// quarter is left: a parse that took most of the budget decoding an image
+// doesn't then wait on a flight status lookup too.
+
+// Breaker states, as upstream_breaker_state reports them.
+const (
+	breakerClosed   = 0
+	breakerHalfOpen = 1
+	breakerOpen     = 2
+)
+
+var breakerStateNames = [...]string{"closed", "half_open", "open"}
+
+// breakerFailures and breakerCooldown are UPSTREAM_BREAKER_FAILURES and
+// UPSTREAM_BREAKER_COOLDOWN.
+var (
+	breakerFailures = defaultConfig.UpstreamBreakerFailures
+	breakerCooldown = defaultConfig.UpstreamBreakerCooldown
+)
+
+var errBreakerOpen = errors.New("circuit open")
+
+func init() {
+	describeMetric("upstream_breaker_state", "gauge", "Circuit breaker state by upstream host: 0 closed, 1 half open, 2 open.")
+	describeMetric("upstream_breaker_transitions_total", "counter", "Circuit breaker state changes by upstream host and new state.")
+	describeMetric("upstream_rejected_total", "counter", "Outbound requests failed at once because the host's breaker was open.")
+}
+
+// breaker is the circuit breaker of one host.
+type breaker struct {
+	host string
+
+	mu       sync.Mutex
+	state    int
+	failures int // consecutive, while closed
+	openedAt time.Time
+	probing  bool // a half-open probe is in flight
+}
+
+// allow reports whether a call may go out now. A call it allows must be
+// followed by done.
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < breakerCooldown {
+			return false
+		}
+		b.set(breakerHalfOpen)
+		fallthrough
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// done records the outcome of a call allow let through.
+func (b *breaker) done(ok bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen {
+		b.probing = false
+		if ok {
+			b.failures = 0
+			b.set(breakerClosed)
+		} else {
+			b.openedAt = now
+			b.set(breakerOpen)
+		}
+		return
+	}
+	if ok {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == breakerClosed && b.failures >= breakerFailures {
+		b.openedAt = now
+		b.set(breakerOpen)
+	}
+}
+
+// set moves the breaker to state; b.mu is held.
+func (b *breaker) set(state int) {
+	b.state = state
+	metric("upstream_breaker_state", "host", b.host).Set(int64(state))
+	metric("upstream_breaker_transitions_total", "host", b.host, "state", breakerStateNames[state]).Inc()
+}
+
+// breakers are those of every host called so far, whichever transport
+// called it.
+var breakers = struct {
+	sync.Mutex
+	m map[string]*breaker
+}{m: map[string]*breaker{}}
+
+func hostBreaker(host string) *breaker {
+	breakers.Lock()
+	defer breakers.Unlock()
+	b, ok := breakers.m[host]
+	if !ok {
+		b = &breaker{host: host}
+		breakers.m[host] = b
+		metric("upstream_breaker_state", "host", host)
+	}
+	return b
+}
+
+// breakerTransport is an http.RoundTripper that goes through the breaker
+// of each request's host.
+type breakerTransport struct {
+	next http.RoundTripper
+	now  func() time.Time // time.Now when nil
+}
+
+// upstreamTransport is the transport of every outbound client (see
+// newUpstreamClient).
+var upstreamTransport = &breakerTransport{next: http.DefaultTransport}
+
+// newUpstreamClient is an HTTP client through upstreamTransport; a zero
+// timeout leaves it to the request's context.
+func newUpstreamClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: upstreamTransport}
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	now := time.Now
+	if t.now != nil {
+		now = t.now
+	}
+	b := hostBreaker(req.URL.Host)
+	if !b.allow(now()) {
+		metric("upstream_rejected_total", "host", req.URL.Host).Inc()
+		return nil, fmt.Errorf("%s: %w", req.URL.Host, errBreakerOpen)
+	}
+	resp, err := t.next.RoundTrip(req)
+	// A caller that gave up says nothing about the host; a timeout does.
+	if errors.Is(req.Context().Err(), context.Canceled) {
+		b.mu.Lock()
+		b.probing = false
+		b.mu.Unlock()
+		return resp, err
+	}
+	b.done(err == nil && resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests, now())
+	return resp, err
+}
+
+// requestBudget is REQUEST_BUDGET; zero turns budgets off.
+var requestBudget = defaultConfig.RequestBudget
+
+var errBudgetSpent = errors.New("request time budget spent")
+
+type budgetKey struct{}
+
+// budget is the time a request has: from its arrival to its end.
+type budget struct{ start, end time.Time }
+
+// withBudget starts a budget for one parse, replacing any ctx had: a
+// frame of a scan stream or an item of a batch gets the whole of its own.
+func withBudget(ctx context.Context) context.Context {
+	if requestBudget <= 0 {
+		return ctx
+	}
+	now := time.Now()
+	return context.WithValue(ctx, budgetKey{}, budget{now, now.Add(requestBudget)})
+}
+
+// budgetMiddleware starts the request's budget (see withBudget).
+func budgetMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(w, r.WithContext(withBudget(r.Context())))
+	}
+}
+
+// budgetUnary is budgetMiddleware for gRPC.
+func budgetUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(withBudget(ctx), req)
+}
+
+// upstreamTimeout is how long an outbound call for ctx may take: limit, cut
+// to what is left of the request's budget and deadline. ok is false when
+// the call should be skipped: less than a quarter of the budget, or
+// nothing before the deadline, is left.
+func upstreamTimeout(ctx context.Context, limit time.Duration) (timeout time.Duration, ok bool) {
+	now := time.Now()
+	timeout = limit
+	if b, has := ctx.Value(budgetKey{}).(budget); has {
+		left := b.end.Sub(now)
+		if left < b.end.Sub(b.start)/4 {
+			return 0, false
+		}
+		timeout = min(timeout, left)
+	}
+	if deadline, has := ctx.Deadline(); has {
+		left := deadline.Sub(now)
+		if left <= 0 {
+			return 0, false
+		}
+		timeout = min(timeout, left)
+	}
+	return timeout, true
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc is an http.RoundTripper for a fake upstream.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// TestBreakerCycle takes one host's breaker from closed to open, through
+// a failed and a successful half-open probe, and back to closed, on a
+// clock the test moves.
+func TestBreakerCycle(t *testing.T) {
+	setForTest(t, &breakerFailures, 3)
+	setForTest(t, &breakerCooldown, 30*time.Second)
+	const host = "breaker-cycle.test"
+	t.Cleanup(func() {
+		breakers.Lock()
+		delete(breakers.m, host)
+		breakers.Unlock()
+	})
+
+	now := time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC)
+	status, calls := http.StatusBadGateway, 0
+	tr := &breakerTransport{
+		next: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("")), Request: r}, nil
+		}),
+		now: func() time.Time { return now },
+	}
+	get := func() error {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+host+"/status", nil)
+		resp, err := tr.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	state := func() string {
+		b := hostBreaker(host)
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return breakerStateNames[b.state]
+	}
+	expect := func(step, want string, wantCalls int) {
+		t.Helper()
+		if got := state(); got != want {
+			t.Fatalf("%s: state %s, want %s", step, got, want)
+		}
+		if calls != wantCalls {
+			t.Fatalf("%s: upstream called %d times, want %d", step, calls, wantCalls)
+		}
+	}
+
+	for range breakerFailures - 1 {
+		get()
+	}
+	expect("below the failure threshold", "closed", 2)
+	get()
+	expect("at the threshold", "open", 3)
+
+	if err := get(); !errors.Is(err, errBreakerOpen) {
+		t.Fatalf("open: err = %v, want errBreakerOpen", err)
+	}
+	now = now.Add(breakerCooldown - time.Second)
+	if err := get(); !errors.Is(err, errBreakerOpen) {
+		t.Fatalf("before the cooldown: err = %v, want errBreakerOpen", err)
+	}
+	expect("open", "open", 3)
+
+	// A probe that fails opens the breaker for another cooldown.
+	now = now.Add(time.Second)
+	get()
+	expect("failed probe", "open", 4)
+	now = now.Add(breakerCooldown / 2)
+	if err := get(); !errors.Is(err, errBreakerOpen) {
+		t.Fatalf("after a failed probe: err = %v, want errBreakerOpen", err)
+	}
+
+	// While the probe is out, other calls are still refused.
+	now = now.Add(breakerCooldown)
+	status = http.StatusOK
+	var probeState string
+	var during error
+	upstream := tr.next
+	tr.next = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		probeState, during = state(), get()
+		return upstream.RoundTrip(r)
+	})
+	if err := get(); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	tr.next = upstream
+	if probeState != "half_open" || !errors.Is(during, errBreakerOpen) {
+		t.Fatalf("during the probe: state %s, err %v; want half_open, errBreakerOpen", probeState, during)
+	}
+	expect("successful probe", "closed", 5)
+
+	if err := get(); err != nil {
+		t.Fatalf("closed: %v", err)
+	}
+	expect("closed", "closed", 6)
+	if got := metric("upstream_breaker_state", "host", host).Value(); got != breakerClosed {
+		t.Errorf("upstream_breaker_state = %d, want %d", got, breakerClosed)
+	}
+}
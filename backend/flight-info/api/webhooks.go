@@ -0,0 +1,224 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// ----------------------
+// WEBHOOKS: PUSH PARSED PASSES
+// ----------------------
+
+// webhooks is nil when no webhook URL is configured.
+var webhooks *WebhookDispatcher
+
+// WebhookConfig is the webhook setup from WEBHOOK_CONFIG and the WEBHOOK_*
+// variables.
+type WebhookConfig struct {
+	URLs        []string      `json:"urls"`
+	Secret      string        `json:"secret"`
+	Workers     int           `json:"workers"`
+	QueueSize   int           `json:"queue_size"`
+	MaxAttempts int           `json:"max_attempts"`
+	Timeout     time.Duration `json:"-"`
+	TimeoutStr  string        `json:"timeout"`
+}
+
+// loadWebhookConfig merges an optional JSON file (WEBHOOK_CONFIG) with the
+// WEBHOOK_ settings, which win when set. Returns nil when no URL is
+// configured anywhere.
+func loadWebhookConfig(c Config) (*WebhookConfig, error) {
+	cfg := &WebhookConfig{Workers: c.WebhookWorkers, QueueSize: 256, MaxAttempts: c.WebhookMaxAttempts, Timeout: c.WebhookTimeout}
+
+	if path := c.WebhookConfig; path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if cfg.TimeoutStr != "" {
+			d, err := time.ParseDuration(cfg.TimeoutStr)
+			if err != nil {
+				return nil, fmt.Errorf("%s: timeout: %w", path, err)
+			}
+			cfg.Timeout = d
+		}
+	}
+
+	if v := c.WebhookURLs; v != "" {
+		cfg.URLs = nil
+		for _, u := range strings.Split(v, ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				cfg.URLs = append(cfg.URLs, u)
+			}
+		}
+	}
+	set := func(name string) bool { return configValue(name) != "" }
+	if set("WEBHOOK_SECRET") {
+		cfg.Secret = c.WebhookSecret
+	}
+	if set("WEBHOOK_WORKERS") {
+		cfg.Workers = c.WebhookWorkers
+	}
+	if set("WEBHOOK_MAX_ATTEMPTS") {
+		cfg.MaxAttempts = c.WebhookMaxAttempts
+	}
+	if set("WEBHOOK_TIMEOUT") {
+		cfg.Timeout = c.WebhookTimeout
+	}
+
+	if len(cfg.URLs) == 0 {
+		return nil, nil
+	}
+	if cfg.Workers < 1 || cfg.QueueSize < 1 || cfg.MaxAttempts < 1 {
+		return nil, fmt.Errorf("webhook workers, queue_size and max_attempts must be positive")
+	}
+	return cfg, nil
+}
+
+type webhookJob struct {
+	url  string
+	body []byte
+}
+
+// WebhookDispatcher delivers payloads from a bounded queue with a fixed
+// worker pool, so a slow receiver can only ever delay other deliveries,
+// never API responses.
+type WebhookDispatcher struct {
+	cfg    *WebhookConfig
+	client *http.Client
+	queue  chan webhookJob
+}
+
+func init() {
+	describeMetric("webhook_deliveries_total", "counter", "Webhook deliveries by final result.")
+	describeMetric("webhook_attempts_total", "counter", "Individual webhook HTTP attempts, including retries.")
+	describeMetric("webhook_queue_depth", "gauge", "Webhook deliveries waiting for a worker.")
+}
+
+func newWebhookDispatcher(cfg *WebhookConfig) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		cfg:    cfg,
+		client: newUpstreamClient(cfg.Timeout),
+		queue:  make(chan webhookJob, cfg.QueueSize),
+	}
+	// Export zeroes from startup so dashboards see the series before traffic.
+	for _, result := range []string{"success", "failure", "dropped"} {
+		metric("webhook_deliveries_total", "result", result)
+	}
+	metric("webhook_attempts_total")
+	metric("webhook_queue_depth")
+
+	for i := 0; i < cfg.Workers; i++ {
+		go d.worker()
+	}
+	return d
+}
+
+// Enqueue schedules the pass for delivery to every URL. If the queue is
+// full the delivery is dropped and counted rather than blocking the caller.
+func (d *WebhookDispatcher) Enqueue(p *bcbp.UnifiedBoardingPass) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		slog.Error("Error encoding webhook payload", "err", err)
+		return
+	}
+	for _, u := range d.cfg.URLs {
+		select {
+		case d.queue <- webhookJob{url: u, body: body}:
+			metric("webhook_queue_depth").Inc()
+		default:
+			metric("webhook_deliveries_total", "result", "dropped").Inc()
+		}
+	}
+}
+
+func (d *WebhookDispatcher) worker() {
+	for job := range d.queue {
+		metric("webhook_queue_depth").Dec()
+		if err := d.deliver(job); err != nil {
+			slog.Warn("Webhook delivery failed", "url", job.url, "err", err)
+			metric("webhook_deliveries_total", "result", "failure").Inc()
+		} else {
+			metric("webhook_deliveries_total", "result", "success").Inc()
+		}
+	}
+}
+
+// deliver POSTs with exponential backoff (0.5s, 1s, 2s, ...). 4xx responses
+// other than 408/429 are not retried since the receiver rejected the payload.
+func (d *WebhookDispatcher) deliver(job webhookJob) error {
+	signature := webhookSignature(d.cfg.Secret, job.body)
+	backoff := 500 * time.Millisecond
+
+	var lastErr error
+	for attempt := 1; attempt <= d.cfg.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		metric("webhook_attempts_total").Inc()
+
+		ctx, cancel := context.WithTimeout(context.Background(), d.cfg.Timeout)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.url, bytes.NewReader(job.body))
+		if err != nil {
+			cancel()
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "flight-info-webhook/1")
+		req.Header.Set("X-Webhook-Event", "pass.parsed")
+		if signature != "" {
+			req.Header.Set("X-Signature-256", signature)
+		}
+
+		resp, err := d.client.Do(req)
+		cancel()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("receiver returned %s", resp.Status)
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+			return lastErr
+		}
+	}
+	return lastErr
+}
+
+// webhookSignature is "sha256=<hex HMAC-SHA256 of the body>", the same
+// scheme GitHub uses, so existing receivers can verify it unchanged.
+func webhookSignature(secret string, body []byte) string {
+	if secret == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyWebhooks is called after every successful parse.
+func notifyWebhooks(p *bcbp.UnifiedBoardingPass) {
+	if webhooks != nil {
+		webhooks.Enqueue(p)
+	}
+}
@@ -0,0 +1,478 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/scan"
+)
+
+// ----------------------
+// HANDLERS: LIVE SCANNING OVER WEBSOCKET
+// ----------------------
+
+// A /ws/scan session is a WebSocket: the client sends camera frames as
+// binary messages (JPEG, or any format /parse/barcode/image takes) and gets
+// one JSON text message back per frame. Only the server side of RFC 6455
+// that this needs is implemented: no extensions or subprotocols.
+
+// scanFrameMax (WS_SCAN_MAX_FRAME) caps one frame in bytes; a bigger one
+// closes the session with 1009. scanFPS (WS_SCAN_FPS) is how many frames per
+// second a session gets decoded; frames arriving sooner are answered
+// "skipped" without being decoded.
+var (
+	scanFrameMax = defaultConfig.WSScanMaxFrame
+	scanFPS      = defaultConfig.WSScanFPS
+)
+
+// scanIdleTimeout closes a session that sends nothing for that long;
+// scanWriteTimeout bounds every write to the client.
+const (
+	scanIdleTimeout  = time.Minute
+	scanWriteTimeout = 10 * time.Second
+)
+
+// WebSocket opcodes and close codes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+
+	wsCloseNormal        = 1000
+	wsCloseGoingAway     = 1001
+	wsCloseProtocolError = 1002
+	wsCloseTooBig        = 1009
+)
+
+// wsGUID is the RFC 6455 key suffix for Sec-WebSocket-Accept.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	errScanFrameTooLarge = errors.New("frame too large")
+	errScanClosed        = errors.New("closed by client")
+)
+
+// wsProtocolError is a client frame that breaks RFC 6455; the session is
+// closed with 1002.
+type wsProtocolError string
+
+func (e wsProtocolError) Error() string { return string(e) }
+
+func init() {
+	describeMetric("ws_scan_sessions", "gauge", "Open /ws/scan sessions.")
+	describeMetric("ws_scan_frames_total", "counter", "Frames received on /ws/scan, by result.")
+	metric("ws_scan_sessions")
+}
+
+// ScanMessage is what the server sends for each frame of a /ws/scan
+// session.
+type ScanMessage struct {
+	Type  string                    `json:"type"`  // "pass", "no_barcode", "error" or "skipped"
+	Frame int                       `json:"frame"` // 1-based, counting every binary message received
+	Pass  *bcbp.UnifiedBoardingPass `json:"pass,omitempty"`
+	Error *ErrorDetail              `json:"error,omitempty"`
+}
+
+// scanSessions tracks the open sessions so a shutdown can close them:
+// hijacked connections are invisible to http.Server.Shutdown.
+var scanSessions = struct {
+	sync.Mutex
+	open    map[*scanSession]struct{}
+	closing bool
+}{open: map[*scanSession]struct{}{}}
+
+// closeScanSessions interrupts every session's read; each one then sends a
+// 1001 close and ends. Registered with http.Server.RegisterOnShutdown.
+func closeScanSessions() {
+	scanSessions.Lock()
+	defer scanSessions.Unlock()
+	scanSessions.closing = true
+	for s := range scanSessions.open {
+		s.conn.SetReadDeadline(time.Now())
+	}
+}
+
+func handleScanSocket(w http.ResponseWriter, r *http.Request) {
+	if !headerContains(r.Header, "Connection", "upgrade") || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		httpError(w, "This endpoint only speaks WebSocket", http.StatusUpgradeRequired)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		httpError(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		httpError(w, "Sec-WebSocket-Key is required", http.StatusBadRequest)
+		return
+	}
+	prof, status, d := decodeProfile(r.URL.Query(), "")
+	if status != 0 {
+		writeError(w, status, d)
+		return
+	}
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		httpError(w, "WebSocket upgrade not supported on this connection", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(key + wsGUID))
+	fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\nX-Request-ID: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]), w.Header().Get("X-Request-ID"))
+	conn.SetWriteDeadline(time.Now().Add(scanWriteTimeout))
+	if brw.Flush() != nil {
+		return
+	}
+
+	q := r.URL.Query()
+	s := &scanSession{
+		conn:      conn,
+		br:        brw.Reader,
+		bw:        brw.Writer,
+		q:         q,
+		profile:   prof,
+		cont:      q.Get("continue") == "true",
+		lang:      responseLang(w),
+		endpoint:  r.URL.Path,
+		requestID: w.Header().Get("X-Request-ID"),
+		interval:  time.Second / time.Duration(scanFPS),
+	}
+	scanSessions.Lock()
+	if scanSessions.closing {
+		scanSessions.Unlock()
+		s.close(wsCloseGoingAway, "server shutting down")
+		return
+	}
+	scanSessions.open[s] = struct{}{}
+	scanSessions.Unlock()
+	metric("ws_scan_sessions").Inc()
+	defer func() {
+		scanSessions.Lock()
+		delete(scanSessions.open, s)
+		scanSessions.Unlock()
+		metric("ws_scan_sessions").Dec()
+	}()
+
+	s.run(r.Context())
+}
+
+// headerContains reports whether the comma-separated header name lists
+// token, ignoring case.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scanSession is one /ws/scan connection. Only its own goroutine reads and
+// writes the connection.
+type scanSession struct {
+	conn net.Conn
+	br   *bufio.Reader
+	bw   *bufio.Writer
+
+	q         url.Values
+	profile   scan.DecodeProfile
+	cont      bool   // ?continue=true: keep scanning after a pass
+	lang      string // for the error messages
+	endpoint  string // for the failure log
+	requestID string
+
+	interval time.Duration // between decoded frames
+	next     time.Time     // earliest time the next frame is decoded
+
+	// msg holds the message being read. It is reused for every frame of
+	// the session, so a steady stream of similar frames stops allocating.
+	msg     []byte
+	control [125]byte
+
+	frames, decoded, passes int
+}
+
+// run reads frames until the client leaves, a pass is found (unless
+// continuing), or the session breaks a limit.
+func (s *scanSession) run(ctx context.Context) {
+	code, reason := wsCloseNormal, "pass found"
+	defer func() {
+		slog.InfoContext(ctx, "Scan session closed", "frames", s.frames, "decoded", s.decoded, "passes", s.passes, "close_code", code, "close_reason", reason)
+	}()
+	for {
+		s.armRead()
+		op, frame, err := s.readMessage()
+		if err != nil {
+			code, reason = s.readError(err)
+			if code != 0 {
+				s.close(code, reason)
+			}
+			return
+		}
+		if op == wsText {
+			d := errorDetail(http.StatusUnsupportedMediaType, s.lang, ErrorDetail{
+				Reason:  reasonUnsupportedType,
+				Message: "Frames must be binary messages holding an image",
+			})
+			if s.send(ScanMessage{Type: "error", Error: &d}) != nil {
+				code, reason = 0, "write failed"
+				return
+			}
+			continue
+		}
+
+		s.frames++
+		msg := s.scan(withBudget(ctx), frame)
+		metric("ws_scan_frames_total", "result", msg.Type).Inc()
+		if s.send(msg) != nil {
+			code, reason = 0, "write failed"
+			return
+		}
+		if msg.Type == "pass" && !s.cont {
+			s.close(code, reason)
+			return
+		}
+	}
+}
+
+// armRead sets the idle deadline for the next read, or an expired one once
+// the server is shutting down, so a shutdown can't be missed between reads.
+func (s *scanSession) armRead() {
+	scanSessions.Lock()
+	defer scanSessions.Unlock()
+	if scanSessions.closing {
+		s.conn.SetReadDeadline(time.Now())
+		return
+	}
+	s.conn.SetReadDeadline(time.Now().Add(scanIdleTimeout))
+}
+
+// readError maps a failed read to the close code to send, 0 for none.
+func (s *scanSession) readError(err error) (code int, reason string) {
+	var protoErr wsProtocolError
+	switch {
+	case errors.Is(err, errScanClosed):
+		return 0, "closed by client"
+	case errors.Is(err, errScanFrameTooLarge):
+		return wsCloseTooBig, fmt.Sprintf("frames are limited to %d bytes", scanFrameMax)
+	case errors.As(err, &protoErr):
+		return wsCloseProtocolError, protoErr.Error()
+	}
+	scanSessions.Lock()
+	closing := scanSessions.closing
+	scanSessions.Unlock()
+	if closing {
+		return wsCloseGoingAway, "server shutting down"
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return wsCloseGoingAway, "idle timeout"
+	}
+	return 0, "connection lost"
+}
+
+// scan decodes one frame like /parse/barcode/image. A frame without a
+// barcode is the normal case while the camera moves, so it is neither an
+// error nor recorded in the failure log.
+func (s *scanSession) scan(ctx context.Context, frame []byte) ScanMessage {
+	m := ScanMessage{Frame: s.frames}
+	now := time.Now()
+	if now.Before(s.next) {
+		m.Type = "skipped"
+		return m
+	}
+	s.next = now.Add(s.interval)
+	s.decoded++
+
+	fail := func(status int, d ErrorDetail, sample string) ScanMessage {
+		recordParseFailure(s.endpoint, s.requestID, status, d, len(frame), sample)
+		d = errorDetail(status, s.lang, d)
+		m.Type, m.Error = "error", &d
+		return m
+	}
+
+	if status, d := checkImageUpload(frame); status != 0 {
+		return fail(status, d, "")
+	}
+	release := func() {}
+	if heavyWork != nil {
+		var err error
+		if release, err = heavyWork.acquire(ctx, scan.Weight(frame)); err != nil {
+			return fail(http.StatusTooManyRequests, ErrorDetail{Message: fmt.Sprintf("Server busy: %v, retry later", err)}, "")
+		}
+	}
+	res, err := scan.DecodeWith(ctx, frame, s.profile)
+	release()
+	if errors.Is(err, scan.ErrNoBarcode) {
+		m.Type = "no_barcode"
+		return m
+	}
+	if err != nil {
+		status, d := imageDecodeError(err, s.profile.Name)
+		return fail(status, d, "")
+	}
+	data, err := parseBCBP(ctx, res.Text, time.Now(), lenientParse(s.q))
+	if err != nil {
+		return fail(http.StatusUnprocessableEntity, notBoardingPassError(s.q, res.Text, err), textSample(s.q, res.Text))
+	}
+	data.RawData["barcode_format"] = res.Format
+	s.passes++
+	m.Type, m.Pass = "pass", processPass(ctx, data, s.q)
+	return m
+}
+
+// readMessage reads the next data message into s.msg, answering pings and
+// joining fragments on the way. The returned slice is only valid until the
+// next call.
+func (s *scanSession) readMessage() (op byte, payload []byte, err error) {
+	s.msg = s.msg[:0]
+	var hdr [8]byte
+	for {
+		if _, err := io.ReadFull(s.br, hdr[:2]); err != nil {
+			return 0, nil, err
+		}
+		fin, opcode := hdr[0]&0x80 != 0, hdr[0]&0x0f
+		if hdr[0]&0x70 != 0 {
+			return 0, nil, wsProtocolError("reserved bits set")
+		}
+		if hdr[1]&0x80 == 0 {
+			return 0, nil, wsProtocolError("client frames must be masked")
+		}
+		n := uint64(hdr[1] & 0x7f)
+		switch n {
+		case 126:
+			if _, err := io.ReadFull(s.br, hdr[:2]); err != nil {
+				return 0, nil, err
+			}
+			n = uint64(binary.BigEndian.Uint16(hdr[:2]))
+		case 127:
+			if _, err := io.ReadFull(s.br, hdr[:8]); err != nil {
+				return 0, nil, err
+			}
+			n = binary.BigEndian.Uint64(hdr[:8])
+		}
+		var mask [4]byte
+		if _, err := io.ReadFull(s.br, mask[:]); err != nil {
+			return 0, nil, err
+		}
+
+		if opcode >= wsClose {
+			if !fin || n > uint64(len(s.control)) {
+				return 0, nil, wsProtocolError("invalid control frame")
+			}
+			data := s.control[:n]
+			if _, err := io.ReadFull(s.br, data); err != nil {
+				return 0, nil, err
+			}
+			unmask(data, mask)
+			switch opcode {
+			case wsClose:
+				s.writeFrame(wsClose, data[:min(len(data), 2)])
+				return 0, nil, errScanClosed
+			case wsPing:
+				if err := s.writeFrame(wsPong, data); err != nil {
+					return 0, nil, err
+				}
+			case wsPong:
+			default:
+				return 0, nil, wsProtocolError("unknown opcode")
+			}
+			continue
+		}
+
+		switch {
+		case opcode == wsContinuation && op == 0:
+			return 0, nil, wsProtocolError("continuation without a message")
+		case opcode != wsContinuation && op != 0:
+			return 0, nil, wsProtocolError("new message inside a fragmented one")
+		case opcode != wsContinuation && opcode != wsText && opcode != wsBinary:
+			return 0, nil, wsProtocolError("unknown opcode")
+		case opcode != wsContinuation:
+			op = opcode
+		}
+		if n > uint64(scanFrameMax-len(s.msg)) {
+			return 0, nil, errScanFrameTooLarge
+		}
+		start := len(s.msg)
+		s.msg = slices.Grow(s.msg, int(n))[:start+int(n)]
+		if _, err := io.ReadFull(s.br, s.msg[start:]); err != nil {
+			return 0, nil, err
+		}
+		unmask(s.msg[start:], mask)
+		if fin {
+			return op, s.msg, nil
+		}
+	}
+}
+
+func unmask(b []byte, mask [4]byte) {
+	for i := range b {
+		b[i] ^= mask[i%4]
+	}
+}
+
+// send writes m as one text message.
+func (s *scanSession) send(m ScanMessage) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return s.writeFrame(wsText, b)
+}
+
+// writeFrame writes one unfragmented, unmasked frame.
+func (s *scanSession) writeFrame(op byte, payload []byte) error {
+	var hdr [10]byte
+	hdr[0] = 0x80 | op
+	n := 2
+	switch l := len(payload); {
+	case l < 126:
+		hdr[1] = byte(l)
+	case l <= 0xffff:
+		hdr[1] = 126
+		binary.BigEndian.PutUint16(hdr[2:], uint16(l))
+		n = 4
+	default:
+		hdr[1] = 127
+		binary.BigEndian.PutUint64(hdr[2:], uint64(l))
+		n = 10
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(scanWriteTimeout))
+	s.bw.Write(hdr[:n])
+	s.bw.Write(payload)
+	return s.bw.Flush()
+}
+
+// close sends a close frame with code and reason. The connection itself is
+// closed when the handler returns.
+func (s *scanSession) close(code int, reason string) {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason[:min(len(reason), 123)]...)
+	s.writeFrame(wsClose, payload)
+}
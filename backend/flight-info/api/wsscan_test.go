@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestScanSocket(t *testing.T) {
+	setForTest(t, &scanFPS, 1000) // no frame skipped as too soon
+	srv := httptest.NewServer(Handler())
+	defer srv.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/scan", "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	noBarcode, _ := base64.StdEncoding.DecodeString(pngBase64(t, checkerboard()))
+
+	exchange := func(msg any) ScanMessage {
+		t.Helper()
+		if err := websocket.Message.Send(ws, msg); err != nil {
+			t.Fatal(err)
+		}
+		var m ScanMessage
+		if err := websocket.JSON.Receive(ws, &m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	if m := exchange(noBarcode); m.Type != "no_barcode" || m.Frame != 1 {
+		t.Errorf("frame without barcode: %+v", m)
+	}
+	if m := exchange("hello"); m.Type != "error" || m.Error == nil || m.Error.Reason != reasonUnsupportedType {
+		t.Errorf("text message: %+v", m)
+	}
+	m := exchange(readFile(t, "data/selftest/ac-aztec.png"))
+	if m.Type != "pass" || m.Frame != 2 || m.Pass == nil || m.Pass.PNR != "ABC123" {
+		t.Fatalf("frame with pass: %+v", m)
+	}
+	// The session ends with the pass.
+	var rest []byte
+	if err := websocket.Message.Receive(ws, &rest); !errors.Is(err, io.EOF) {
+		t.Errorf("after the pass: %v, %q", err, rest)
+	}
+}
+
+func TestScanSocketNeedsUpgrade(t *testing.T) {
+	w := serve(t, Handler(), http.MethodGet, "/ws/scan", nil)
+	if w.Code != http.StatusUpgradeRequired || w.Header().Get("Upgrade") != "websocket" {
+		t.Errorf("status %d, Upgrade %q", w.Code, w.Header().Get("Upgrade"))
+	}
+}
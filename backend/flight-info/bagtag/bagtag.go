@@ -0,0 +1,60 @@
+// Package bagtag reads IATA bag tag license plates: the 10-digit number
+// printed under the 1D barcode of a checked bag's tag (IATA RP 1740a).
+package bagtag
+
+import (
+	"strings"
+
+	"bugsbyte/flight-info/internal/refdata"
+)
+
+// DocumentType is Tag.DocumentType, which tells a bag tag response apart
+// from a boarding pass.
+const DocumentType = "bag_tag"
+
+// Tag is a decoded license plate.
+type Tag struct {
+	DocumentType string `json:"document_type"`
+	LicensePlate string `json:"license_plate"` // all 10 digits
+	// TagType is from the first digit: "interline" (0), "fallback" (1,
+	// printed when the departure control system is down) or "rush" (2, a
+	// mishandled bag being forwarded). Other digits leave it empty.
+	TagType       string `json:"tag_type,omitempty"`
+	AirlinePrefix string `json:"airline_prefix"` // IATA accounting code of the issuing airline
+	// Carrier and CarrierName are the airline with that prefix, when the
+	// airline dataset has it.
+	Carrier     string `json:"carrier,omitempty"`
+	CarrierName string `json:"carrier_name,omitempty"`
+	Serial      string `json:"serial"` // the airline's 6-digit bag number
+	// BarcodeFormat is the symbology, when read from an image (usually ITF).
+	BarcodeFormat string   `json:"barcode_format,omitempty"`
+	Warnings      []string `json:"warnings,omitempty"`
+}
+
+var tagTypes = map[byte]string{'0': "interline", '1': "fallback", '2': "rush"}
+
+// Parse reads raw as a license plate. Only exactly 10 digits, after
+// trimming spaces and line endings, are one: anything else, a BCBP string
+// included, is left to the boarding pass parsers and ok is false.
+func Parse(raw string) (tag *Tag, ok bool) {
+	s := strings.TrimSpace(raw)
+	if len(s) != 10 || strings.Trim(s, "0123456789") != "" {
+		return nil, false
+	}
+	tag = &Tag{
+		DocumentType:  DocumentType,
+		LicensePlate:  s,
+		TagType:       tagTypes[s[0]],
+		AirlinePrefix: s[1:4],
+		Serial:        s[4:],
+	}
+	if tag.TagType == "" {
+		tag.Warnings = append(tag.Warnings, "tag_type: leading digit "+s[:1]+" is not an interline (0), fallback (1) or rush (2) tag")
+	}
+	if a, ok := refdata.AirlineByPrefix(tag.AirlinePrefix); ok {
+		tag.Carrier, tag.CarrierName = a.IATA, a.Name
+	} else {
+		tag.Warnings = append(tag.Warnings, "airline prefix "+tag.AirlinePrefix+" not in airline dataset: carrier left empty")
+	}
+	return tag, true
+}
@@ -0,0 +1,22 @@
+package bcbp_test
+
+import (
+	"testing"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// The benchmarks of the parse paths are next to each parser, on the
+// fixtures in testdata/bench; `make bench` runs them all (see the
+// Makefile).
+
+const benchBarcode = "M1DESMARAIS/LUC       EABC123 YULFRAAC 0834 326J001A0025 100"
+
+func BenchmarkParse(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := bcbp.Parse(benchBarcode); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
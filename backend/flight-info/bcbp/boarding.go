@@ -0,0 +1,60 @@
+package bcbp
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ----------------------
+// LOGIC: BOARDING GROUPS
+// ----------------------
+
+var (
+	// groupValue is a whole group value: an optional "Group"/"Zone" word
+	// and the group itself, a number or a letter with an optional digit.
+	groupValue = regexp.MustCompile(`^(?:BOARDING\s*)?(?:GROUP|GRP|GR|ZONE|ZN|G|Z)?\s*[.:#-]?\s*([0-9]{1,2}|[A-Z][0-9]?)$`)
+	// groupInText finds a group in free text, where the word is required.
+	groupInText = regexp.MustCompile(`\b(?:GROUP|GRP|ZONE)\s*[.:#-]?\s*([0-9]{1,2}|[A-Z][0-9]?)\b`)
+	// priorityWords mark priority boarding, in a group value or free text.
+	priorityWords = regexp.MustCompile(`\b(?:SKY\s*)?(?:PRIORITY|PRIORITAIRE|PRIORIDADE|PRIORIDAD|PRIO|SPEEDY)\b(?:\s*BOARDING)?`)
+)
+
+// NormalizeBoardingGroup reads a boarding group as airlines print it:
+// "Zone 3", "GRP3", "Group: 3" and "3" all give "3". Priority wording
+// ("Priority", "SkyPriority", "Speedy Boarding") sets priority and is
+// dropped, so "Priority Group 1" gives "1" and "Priority" alone gives "".
+// ok is false for a value it can't read.
+func NormalizeBoardingGroup(v string) (group string, priority, ok bool) {
+	s := strings.ToUpper(strings.TrimSpace(v))
+	if priorityWords.MatchString(s) {
+		priority = true
+		s = strings.TrimSpace(priorityWords.ReplaceAllString(s, ""))
+		if s == "" {
+			return "", true, true
+		}
+	}
+	m := groupValue.FindStringSubmatch(s)
+	if m == nil {
+		return "", priority, false
+	}
+	return trimGroup(m[1]), priority, true
+}
+
+// boardingFromAirlineUse looks for a boarding group and priority wording in
+// the free-form airline use data of a barcode. Only groups spelled out
+// with "GROUP", "GRP" or "ZONE" count; anything else there is opaque.
+func boardingFromAirlineUse(data string) (group string, priority bool) {
+	s := strings.ToUpper(data)
+	if m := groupInText.FindStringSubmatch(s); m != nil {
+		group = trimGroup(m[1])
+	}
+	return group, priorityWords.MatchString(s)
+}
+
+// trimGroup drops a leading zero: "03" is group 3, but "0" stays.
+func trimGroup(g string) string {
+	if len(g) > 1 {
+		g = strings.TrimLeft(g, "0")
+	}
+	return g
+}
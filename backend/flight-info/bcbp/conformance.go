@@ -0,0 +1,346 @@
+package bcbp
+
+import (
+	"cmp"
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ----------------------
+// LOGIC: CONFORMANCE (RESOLUTION 792)
+// ----------------------
+
+// Parse reads what airlines print, not what the standard says they should:
+// a flight number padded with spaces instead of zeros, a name without its
+// slash, a conditional section whose sizes don't add up, all parse. Check
+// is the strict reading. It holds every field of the first leg to its
+// format in IATA Resolution 792 and the sizes of the conditional section
+// to what follows them, and reports each departure as a Finding. Conform
+// runs Parse and Check over a set of barcodes and tallies the outcome by
+// operating carrier, for keeping track of which airlines print clean
+// barcodes.
+
+// Finding codes other than "invalid_" and a field name, which is a field
+// in a format the standard doesn't allow.
+const (
+	FindingShortMandatory = "short_mandatory_section" // under the 60 characters of the mandatory section
+	FindingSizeOverrun    = "size_overrun"            // a conditional size past the end of its section
+	FindingVersionMarker  = "missing_version_marker"  // a conditional section without its '>'
+	FindingTrailingData   = "trailing_data"           // a one-leg pass with more than its sizes account for
+)
+
+// Finding is one way a barcode departs from Resolution 792, at
+// raw[Offset:Offset+Length].
+type Finding struct {
+	Code   string `json:"code"`
+	Field  string `json:"field,omitempty"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+func matches(expr string) func(string) bool {
+	return regexp.MustCompile(expr).MatchString
+}
+
+// fieldFormats are the formats of the fields Fields returns, as Resolution
+// 792 defines them, padding included. Optional conditional fields may be
+// blank.
+var fieldFormats = map[string]func(v string) bool{
+	"format_code":       matches(`^[MS]$`),
+	"number_of_legs":    matches(`^[1-4]$`),
+	"passenger_name":    matches(`^[A-Z][A-Z .'-]*/[A-Z .'-]*$`),
+	"electronic_ticket": matches(`^[E ]$`),
+	"pnr":               matches(`^[A-Z0-9]{5,7} *$`),
+	"departure_airport": matches(`^[A-Z]{3}$`),
+	"arrival_airport":   matches(`^[A-Z]{3}$`),
+	"carrier":           matches(`^([A-Z0-9]{2} |[A-Z]{3})$`),
+	"flight_number":     matches(`^[0-9]{4}[A-Z ]$`),
+	"date_julian": func(v string) bool {
+		day, err := strconv.Atoi(v)
+		return err == nil && len(v) == 3 && day >= 1 && day <= 366
+	},
+	"cabin_class":            matches(`^[A-Z]$`),
+	"seat":                   matches(`^([0-9]{3}[A-Z]|INF |    )$`),
+	"sequence_number":        matches(`^[0-9]{4}[A-Z ]$`),
+	"passenger_status":       matches(`^[0-9A-Z]$`),
+	"conditional_size":       matches(`^[0-9A-F]{2}$`),
+	"bcbp_version":           matches(`^[1-8]$`),
+	"unique_size":            matches(`^[0-9A-F]{2}$`),
+	"repeated_size":          matches(`^[0-9A-F]{2}$`),
+	"airline_numeric_code":   matches(`^([0-9]{3}| {3})$`),
+	"document_serial":        matches(`^[0-9 ]{10}$`),
+	"selectee":               matches(`^[013 ]$`),
+	"intl_doc_verification":  matches(`^[012 ]$`),
+	"marketing_carrier":      matches(`^([A-Z0-9]{2} |[A-Z]{3}| {3})$`),
+	"frequent_flyer_airline": matches(`^([A-Z0-9]{2} |[A-Z]{3}| {3})$`),
+	"id_ad_indicator":        matches(`^[0-9A-Z ]$`),
+	"free_baggage":           matches(`^([0-9]{2}[KL]|[0-9]PC| {3})$`),
+	"fast_track":             matches(`^[YN ]$`),
+}
+
+// Check lists where raw, as NormalizeEncoding leaves it, departs from
+// Resolution 792, in the order of the fields. Only the first leg is
+// checked. Input Parse rejects gets no findings.
+func Check(raw string) []Finding {
+	raw = NormalizeEncoding(raw)
+	if _, err := ParseAt(raw, time.Time{}); err != nil {
+		return nil
+	}
+	var out []Finding
+	for _, f := range Fields(raw) {
+		if len(raw) < 60 && f.End == len(raw) {
+			continue // cut short: FindingShortMandatory
+		}
+		if valid, ok := fieldFormats[f.Name]; ok && !valid(f.Raw) {
+			out = append(out, Finding{"invalid_" + f.Name, f.Name, f.Start, f.End - f.Start})
+		}
+	}
+	if len(raw) < 60 {
+		return append(out, Finding{Code: FindingShortMandatory, Offset: len(raw)})
+	}
+	return append(out, checkSizes(raw)...)
+}
+
+// checkSizes follows the sizes of the conditional section: each must end
+// within the one around it, and a one-leg pass must end where the
+// outermost does, unless security data follows.
+func checkSizes(raw string) []Finding {
+	hexSize := func(pos int) (int, bool) {
+		if pos+2 > len(raw) {
+			return 0, false
+		}
+		n, err := strconv.ParseUint(raw[pos:pos+2], 16, 8)
+		return int(n), err == nil
+	}
+	size, ok := hexSize(58)
+	if !ok {
+		return nil // invalid_conditional_size
+	}
+	end := 60 + size
+	switch {
+	case end > len(raw):
+		return []Finding{{FindingSizeOverrun, "conditional_size", 58, 2}}
+	case size > 0 && raw[60] != '>':
+		return []Finding{{FindingVersionMarker, "version_marker", 60, 1}}
+	}
+	var out []Finding
+	if size >= 4 {
+		if unique, ok := hexSize(62); ok {
+			pos := 64 + unique
+			if pos+2 > end {
+				out = append(out, Finding{FindingSizeOverrun, "unique_size", 62, 2})
+			} else if repeated, ok := hexSize(pos); ok && pos+2+repeated > end {
+				out = append(out, Finding{FindingSizeOverrun, "repeated_size", pos, 2})
+			}
+		}
+	}
+	if raw[1] == '1' && end < len(raw) && raw[end] != '^' {
+		out = append(out, Finding{FindingTrailingData, "", end, len(raw) - end})
+	}
+	return out
+}
+
+// ----------------------
+// LOGIC: CONFORMANCE REPORT
+// ----------------------
+
+// conformanceExamples is how many examples a ConformanceGroup keeps.
+const conformanceExamples = 3
+
+// ConformanceReport is Conform's tally, overall and by operating carrier.
+type ConformanceReport struct {
+	ConformanceCounts
+	Carriers []CarrierConformance `json:"carriers"`
+}
+
+// ConformanceCounts splits barcodes three ways: clean parses, parses with
+// findings or parser warnings, and barcodes Parse rejects.
+type ConformanceCounts struct {
+	Total        int `json:"total"`
+	Clean        int `json:"clean"`
+	WithWarnings int `json:"with_warnings"`
+	Failed       int `json:"failed"`
+}
+
+// CarrierConformance is the tally for one operating carrier, most common
+// problems first. Carrier is empty for barcodes too short or broken to
+// name one.
+type CarrierConformance struct {
+	Carrier string `json:"carrier"`
+	ConformanceCounts
+	// Warnings are grouped by Finding code, or for parser warnings by the
+	// text before their colon ("date_of_birth", "group_pass").
+	Warnings []ConformanceGroup `json:"warnings,omitempty"`
+	// Failures are grouped by ParseError code.
+	Failures []ConformanceGroup `json:"failures,omitempty"`
+}
+
+// ConformanceGroup is the barcodes that share a problem.
+type ConformanceGroup struct {
+	Code     string               `json:"code"`
+	Count    int                  `json:"count"`
+	Examples []ConformanceExample `json:"examples"`
+}
+
+// ConformanceExample points at a problem in one barcode. Parser warnings
+// have no offset. Excerpt is the field as found, with the letters of
+// fields that identify the passenger masked as A and the digits as 9, so
+// its shape still shows.
+type ConformanceExample struct {
+	Index   int    `json:"index"` // position in the barcodes given to Conform
+	Field   string `json:"field,omitempty"`
+	Offset  *int   `json:"offset,omitempty"`
+	Length  int    `json:"length,omitempty"`
+	Excerpt string `json:"excerpt,omitempty"`
+}
+
+// identifyingFields are masked in excerpts.
+var identifyingFields = map[string]bool{
+	"passenger_name": true, "pnr": true, "document_serial": true,
+	"frequent_flyer_number": true, "airline_use": true,
+}
+
+// Conform parses every barcode in raws strictly, resolving dates around
+// ref, and tallies the outcome by operating carrier, the carrier with the
+// most barcodes first.
+func Conform(raws []string, ref time.Time) *ConformanceReport {
+	type groups map[string]*ConformanceGroup
+	type tally struct {
+		c                  CarrierConformance
+		warnings, failures groups
+	}
+	byCarrier := map[string]*tally{}
+	add := func(g groups, code string, ex ConformanceExample) {
+		grp := g[code]
+		if grp == nil {
+			grp = &ConformanceGroup{Code: code, Examples: []ConformanceExample{}}
+			g[code] = grp
+		}
+		if grp.Count++; len(grp.Examples) < conformanceExamples {
+			grp.Examples = append(grp.Examples, ex)
+		}
+	}
+
+	report := &ConformanceReport{}
+	for i, raw := range raws {
+		raw = NormalizeEncoding(raw)
+		p, err := ParseAt(raw, ref)
+		carrier := failureCarrier(raw)
+		if err == nil {
+			carrier = strings.TrimSpace(p.Carrier)
+		}
+		t := byCarrier[carrier]
+		if t == nil {
+			t = &tally{c: CarrierConformance{Carrier: carrier}, warnings: groups{}, failures: groups{}}
+			byCarrier[carrier] = t
+		}
+		for _, counts := range []*ConformanceCounts{&report.ConformanceCounts, &t.c.ConformanceCounts} {
+			counts.Total++
+		}
+
+		if err != nil {
+			code, offset := "unknown", 0
+			var perr *ParseError
+			if errors.As(err, &perr) {
+				code, offset = perr.Code, perr.Offset
+			}
+			add(t.failures, code, ConformanceExample{Index: i, Offset: &offset})
+			report.Failed++
+			t.c.Failed++
+			continue
+		}
+
+		seen := map[string]bool{}
+		for _, f := range Check(raw) {
+			if seen[f.Code] {
+				continue
+			}
+			seen[f.Code] = true
+			offset := f.Offset
+			add(t.warnings, f.Code, ConformanceExample{
+				Index: i, Field: f.Field, Offset: &offset, Length: f.Length,
+				Excerpt: excerpt(raw, f),
+			})
+		}
+		for _, w := range p.Warnings {
+			code := warningCode(w)
+			if seen[code] {
+				continue
+			}
+			seen[code] = true
+			add(t.warnings, code, ConformanceExample{Index: i})
+		}
+		if len(seen) > 0 {
+			report.WithWarnings++
+			t.c.WithWarnings++
+		} else {
+			report.Clean++
+			t.c.Clean++
+		}
+	}
+
+	sorted := func(g groups) []ConformanceGroup {
+		var out []ConformanceGroup
+		for _, grp := range g {
+			out = append(out, *grp)
+		}
+		slices.SortFunc(out, func(a, b ConformanceGroup) int {
+			return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Code, b.Code))
+		})
+		return out
+	}
+	report.Carriers = []CarrierConformance{}
+	for _, t := range byCarrier {
+		t.c.Warnings = sorted(t.warnings)
+		t.c.Failures = sorted(t.failures)
+		report.Carriers = append(report.Carriers, t.c)
+	}
+	slices.SortFunc(report.Carriers, func(a, b CarrierConformance) int {
+		return cmp.Or(cmp.Compare(b.Total, a.Total), cmp.Compare(a.Carrier, b.Carrier))
+	})
+	return report
+}
+
+// failureCarrier is the carrier field of a barcode Parse rejected, if it
+// got that far and the field is printable once its padding, spaces or
+// NULs, is trimmed.
+func failureCarrier(raw string) string {
+	if len(raw) < 39 {
+		return ""
+	}
+	c := strings.Trim(raw[36:39], " \x00")
+	for i := range len(c) {
+		if c[i] < 0x21 || c[i] > 0x7e {
+			return ""
+		}
+	}
+	return c
+}
+
+// warningCode is the group of a parser warning: its text before the
+// colon, in snake case.
+func warningCode(w string) string {
+	prefix, _, _ := strings.Cut(w, ":")
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(prefix)), " ", "_")
+}
+
+// excerpt is the span of raw f points at, masked for identifying fields.
+func excerpt(raw string, f Finding) string {
+	s := raw[f.Offset:min(f.Offset+f.Length, len(raw))]
+	if !identifyingFields[f.Field] {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+			return 'A'
+		case r >= '0' && r <= '9':
+			return '9'
+		}
+		return r
+	}, s)
+}
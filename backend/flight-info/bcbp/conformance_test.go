@@ -0,0 +1,106 @@
+package bcbp_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/internal/fixture"
+)
+
+// mixedCarriers reads the barcodes of the mixed-carrier conformance
+// fixture.
+func mixedCarriers(t *testing.T) []string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(corpus, "conformance", "mixed-carriers.barcodes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+// TestConformAggregates checks how Conform adds up a set of barcodes from
+// several carriers, beyond what the golden report pins: every barcode is
+// counted once, under its own carrier, and the tallies add up.
+func TestConformAggregates(t *testing.T) {
+	raws := mixedCarriers(t)
+	report := bcbp.Conform(raws, fixture.Reference)
+
+	if report.Total != len(raws) {
+		t.Errorf("total = %d, want %d", report.Total, len(raws))
+	}
+	var sum bcbp.ConformanceCounts
+	carriers := map[int]string{} // barcode index to its carrier
+	for _, c := range report.Carriers {
+		if n := c.Clean + c.WithWarnings + c.Failed; n != c.Total {
+			t.Errorf("%q: clean+with_warnings+failed = %d, total %d", c.Carrier, n, c.Total)
+		}
+		sum.Total += c.Total
+		sum.Clean += c.Clean
+		sum.WithWarnings += c.WithWarnings
+		sum.Failed += c.Failed
+
+		for _, groups := range [][]bcbp.ConformanceGroup{c.Warnings, c.Failures} {
+			for _, g := range groups {
+				if g.Count < len(g.Examples) || len(g.Examples) == 0 || len(g.Examples) > 3 {
+					t.Errorf("%q %s: count %d with %d examples", c.Carrier, g.Code, g.Count, len(g.Examples))
+				}
+				for _, ex := range g.Examples {
+					if other, ok := carriers[ex.Index]; ok && other != c.Carrier {
+						t.Errorf("barcode %d is an example of both %q and %q", ex.Index, other, c.Carrier)
+					}
+					carriers[ex.Index] = c.Carrier
+				}
+			}
+		}
+	}
+	if sum != report.ConformanceCounts {
+		t.Errorf("carriers add up to %+v, report says %+v", sum, report.ConformanceCounts)
+	}
+	if len(report.Carriers) < 4 {
+		t.Errorf("%d carriers, want the fixture's mix", len(report.Carriers))
+	}
+	if !slices.IsSortedFunc(report.Carriers, func(a, b bcbp.CarrierConformance) int {
+		if a.Total != b.Total {
+			return b.Total - a.Total
+		}
+		return strings.Compare(a.Carrier, b.Carrier)
+	}) {
+		t.Error("carriers aren't sorted by total, then code")
+	}
+}
+
+// TestConformExamplesCapped repeats one finding past the example limit
+// and mixes in a clean barcode of another carrier.
+func TestConformExamplesCapped(t *testing.T) {
+	raws := mixedCarriers(t)
+	bad := raws[2] // AC, Julian date 400
+	clean := raws[3]
+	in := []string{bad, clean, bad, bad, bad, bad}
+	report := bcbp.Conform(in, fixture.Reference)
+
+	if len(report.Carriers) != 2 || report.Carriers[0].Carrier != "AC" || report.Carriers[1].Carrier != "TP" {
+		t.Fatalf("carriers = %+v, want AC then TP", report.Carriers)
+	}
+	ac := report.Carriers[0]
+	if ac.Total != 5 || ac.WithWarnings != 5 || len(ac.Warnings) != 1 {
+		t.Fatalf("AC = %+v", ac)
+	}
+	g := ac.Warnings[0]
+	if g.Code != "invalid_date_julian" || g.Count != 5 || len(g.Examples) != 3 {
+		t.Errorf("group = %s, count %d, %d examples; want invalid_date_julian, 5, 3", g.Code, g.Count, len(g.Examples))
+	}
+	var idx []int
+	for _, ex := range g.Examples {
+		idx = append(idx, ex.Index)
+	}
+	if !slices.Equal(idx, []int{0, 2, 3}) {
+		t.Errorf("examples at %v, want the first three, [0 2 3]", idx)
+	}
+	if tp := report.Carriers[1]; tp.Total != 1 || tp.Clean != 1 {
+		t.Errorf("TP = %+v, want one clean barcode", tp)
+	}
+}
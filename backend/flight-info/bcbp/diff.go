@@ -0,0 +1,65 @@
+package bcbp
+
+import "strings"
+
+// ----------------------
+// LOGIC: CHANGES BETWEEN VERSIONS
+// ----------------------
+
+// Airlines update a pass when the gate, seat or time changes, and the
+// updated version keeps the pass's ID. Diff compares two versions field by
+// field, over the fields a traveler acts on. Values that differ only in
+// case or in leading, trailing or repeated spaces, as two parsers or two
+// scans of the same pass may write them, are the same value: "12a " is
+// seat "12A", but "1 2A" is not.
+
+// FieldChange is a field a later version of a pass changed. Old and New
+// are empty for a field the version added or dropped.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// diffFields are the fields Diff compares, by their JSON name.
+var diffFields = []struct {
+	name string
+	get  func(*UnifiedBoardingPass) string
+}{
+	{"flight_number", func(p *UnifiedBoardingPass) string { return p.FlightNumber }},
+	{"date_iso", func(p *UnifiedBoardingPass) string { return p.DateISO }},
+	{"departure_airport", func(p *UnifiedBoardingPass) string { return p.Departure }},
+	{"arrival_airport", func(p *UnifiedBoardingPass) string { return p.Arrival }},
+	{"departure_time", func(p *UnifiedBoardingPass) string { return p.DepartureTime }},
+	{"boarding_time", func(p *UnifiedBoardingPass) string { return p.BoardingTime }},
+	{"terminal", func(p *UnifiedBoardingPass) string { return p.Terminal }},
+	{"gate", func(p *UnifiedBoardingPass) string { return p.Gate }},
+	{"check_in_desk", func(p *UnifiedBoardingPass) string { return p.CheckInDesk }},
+	{"boarding_door", func(p *UnifiedBoardingPass) string { return p.BoardingDoor }},
+	{"boarding_group", func(p *UnifiedBoardingPass) string { return p.BoardingGroup }},
+	{"seat", func(p *UnifiedBoardingPass) string { return p.Seat }},
+	{"seat_status", func(p *UnifiedBoardingPass) string { return p.SeatStatus }},
+	{"cabin_class", func(p *UnifiedBoardingPass) string { return p.CabinClass }},
+	{"sequence_number", func(p *UnifiedBoardingPass) string { return p.SequenceNumber }},
+	{"passenger_status", func(p *UnifiedBoardingPass) string { return p.Status }},
+}
+
+// Diff lists the fields of old that p, a later version of the same pass,
+// changed, in a fixed order. It is empty, not nil, when none did.
+func Diff(old, p *UnifiedBoardingPass) []FieldChange {
+	changes := []FieldChange{}
+	for _, f := range diffFields {
+		a, b := f.get(old), f.get(p)
+		if sameValue(a, b) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: f.name, Old: a, New: b})
+	}
+	return changes
+}
+
+// sameValue reports whether a and b are equal once runs of whitespace are
+// collapsed, the ends trimmed and case ignored.
+func sameValue(a, b string) bool {
+	return strings.EqualFold(strings.Join(strings.Fields(a), " "), strings.Join(strings.Fields(b), " "))
+}
@@ -0,0 +1,100 @@
+package bcbp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ----------------------
+// LOGIC: IATA BCBP ENCODER
+// ----------------------
+
+// Encode re-encodes the mandatory fields of a pass as a single-leg BCBP
+// string (60 characters, no conditional section), the inverse of Parse. Values are uppercased, stripped of diacritics, and
+// padded or truncated to their fixed widths.
+func Encode(p *UnifiedBoardingPass) string {
+	field := func(v string, width int) string {
+		v = bcbpASCII(strings.ToUpper(strings.TrimSpace(v)))
+		if len(v) > width {
+			v = v[:width]
+		}
+		return v + strings.Repeat(" ", width-len(v))
+	}
+
+	julian := p.Date
+	if julian == "" {
+		if t, err := time.Parse(time.DateOnly, p.DateISO); err == nil {
+			julian = fmt.Sprintf("%03d", t.YearDay())
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("M1")
+	b.WriteString(field(p.PassengerName, 20))
+	b.WriteString("E")
+	b.WriteString(field(p.PNR, 7))
+	b.WriteString(field(p.Departure, 3))
+	b.WriteString(field(p.Arrival, 3))
+	b.WriteString(field(p.Carrier, 3))
+	b.WriteString(bcbpFlightNumber(p.FlightNumber))
+	b.WriteString(field(julian, 3))
+	b.WriteString(field(p.CabinClass, 1))
+	b.WriteString(bcbpSeat(p.Seat))
+	b.WriteString(field("", 5)) // check-in sequence number
+	b.WriteString("0")          // passenger status
+	b.WriteString("00")         // conditional section size
+	return b.String()
+}
+
+// bcbpFlightNumber formats "432" or "TP432A" as "0432A": four digits plus
+// an optional operational suffix.
+func bcbpFlightNumber(v string) string {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	v = strings.TrimLeftFunc(v, func(r rune) bool { return r < '0' || r > '9' })
+	digits := strings.TrimRightFunc(v, func(r rune) bool { return r < '0' || r > '9' })
+	suffix := strings.TrimSpace(v[len(digits):])
+	if len(digits) > 4 {
+		digits = digits[len(digits)-4:]
+	}
+	if len(suffix) > 1 {
+		suffix = suffix[:1]
+	}
+	if suffix == "" {
+		suffix = " "
+	}
+	return fmt.Sprintf("%04s", digits) + suffix
+}
+
+// bcbpSeat formats "12C" as "012C".
+func bcbpSeat(v string) string {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	if v == "" {
+		return "    "
+	}
+	if len(v) > 4 {
+		v = v[:4]
+	}
+	return strings.Repeat("0", 4-len(v)) + v
+}
+
+var stripMarks = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// bcbpASCII drops diacritics ("JOÃO" → "JOAO") and replaces anything else
+// outside printable ASCII, since BCBP fields are fixed-width in bytes.
+func bcbpASCII(v string) string {
+	if out, _, err := transform.String(stripMarks, v); err == nil {
+		v = out
+	}
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return '?'
+		}
+		return r
+	}, v)
+}
@@ -0,0 +1,77 @@
+package bcbp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// ----------------------
+// LOGIC: INPUT ENCODING
+// ----------------------
+
+// ErrInvalidEncoding is returned by Parse for input whose mandatory section
+// isn't printable ASCII, even after the clean-up in NormalizeEncoding.
+var ErrInvalidEncoding = errors.New("invalid encoding")
+
+// NormalizeEncoding undoes what scanner middleware does to barcode text:
+// a UTF-8 byte order mark is stripped, and UTF-16 (with a byte order mark,
+// or recognized by a NUL byte next to every character) is transcoded.
+// Anything else is returned as is. Parse calls it first.
+func NormalizeEncoding(raw string) string {
+	switch {
+	case strings.HasPrefix(raw, "\xff\xfe"):
+		return decodeUTF16(raw[2:], binary.LittleEndian)
+	case strings.HasPrefix(raw, "\xfe\xff"):
+		return decodeUTF16(raw[2:], binary.BigEndian)
+	}
+	raw = strings.TrimPrefix(raw, "\ufeff")
+	switch {
+	case nulInterleaved(raw, 1):
+		return decodeUTF16(raw, binary.LittleEndian)
+	case nulInterleaved(raw, 0):
+		return decodeUTF16(raw, binary.BigEndian)
+	}
+	return raw
+}
+
+// nulInterleaved reports whether every byte at an offset of the given
+// parity is NUL, as in UTF-16 ASCII text: odd offsets for little-endian,
+// even ones for big-endian. A little-endian string may have lost its last
+// NUL.
+func nulInterleaved(raw string, parity int) bool {
+	if len(raw) < 4 {
+		return false
+	}
+	for i := parity; i < len(raw); i += 2 {
+		if i^1 >= len(raw) || raw[i] != 0 || raw[i^1] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func decodeUTF16(raw string, order binary.ByteOrder) string {
+	if len(raw)%2 == 1 {
+		raw += "\x00"
+	}
+	units := make([]uint16, len(raw)/2)
+	for i := range units {
+		units[i] = order.Uint16([]byte(raw[2*i : 2*i+2]))
+	}
+	return strings.TrimPrefix(string(utf16.Decode(units)), "\ufeff")
+}
+
+// checkMandatoryASCII fails on the first byte of the mandatory section
+// that isn't printable ASCII, before Fields slices through it.
+func checkMandatoryASCII(raw string) error {
+	for i := range min(len(raw), 60) {
+		if c := raw[i]; c < 0x20 || c > 0x7e {
+			return &ParseError{ErrCodeInvalidEncoding, i,
+				fmt.Errorf("%w: byte %d (0x%02x) of the mandatory section is not printable ASCII", ErrInvalidEncoding, i, c)}
+		}
+	}
+	return nil
+}
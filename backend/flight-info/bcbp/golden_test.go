@@ -0,0 +1,39 @@
+package bcbp_test
+
+import (
+	"flag"
+	"testing"
+
+	"bugsbyte/flight-info/internal/fixture"
+)
+
+// The golden-file corpus: every input in testdata/golden is parsed and
+// compared with its .want.json, and every pass checked against the wire
+// registry. After a deliberate parser change,
+//
+//	go test ./bcbp -run Golden -update
+//
+// rewrites the expectations; review the diff before committing it.
+
+var update = flag.Bool("update", false, "rewrite the golden expectations from the current output")
+
+const corpus = "../testdata/golden"
+
+func TestGolden(t *testing.T) {
+	cases, err := fixture.Load(corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			if err := c.Check(*update); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	t.Run(fixture.SchemaDir, func(t *testing.T) {
+		for _, err := range fixture.CheckSchemas(corpus, *update) {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package bcbp
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ----------------------
+// LOGIC: GROUP PASSES
+// ----------------------
+
+// Some charter operators issue one barcode for a whole party. The header
+// names the first passenger, and each further leg repeats a flight for
+// another passenger, whose name starts the leg's airline use data in the
+// header's fixed-width SURNAME/GIVEN form. A barcode is a group pass when
+// a later leg names someone other than the header; then Passengers lists
+// every leg with its passenger, and the top-level fields stay those of
+// the first leg. Ordinary multi-leg barcodes, with no names in their legs
+// or the same name throughout, keep their usual shape.
+
+// groupName matches a passenger name in the header's SURNAME/GIVEN form.
+var groupName = regexp.MustCompile(`^[A-Z][A-Z' -]*/[A-Z][A-Z' .-]*$`)
+
+// applyGroup fills in Passengers and GroupPass when legs, the legs after
+// first, make p a group pass, and reports whether they did. A later leg
+// without a name of its own is taken to be the header's passenger's.
+func applyGroup(p *UnifiedBoardingPass, first PassengerLeg, legs []PassengerLeg) bool {
+	group := false
+	for _, l := range legs {
+		if l.PassengerName != "" && l.PassengerName != p.PassengerName {
+			group = true
+		}
+	}
+	if !group {
+		return false
+	}
+
+	p.GroupPass = true
+	p.Passengers = []PassengerLeg{first}
+	names := map[string]bool{p.PassengerName: true}
+	for _, l := range legs {
+		if l.PassengerName == "" {
+			l.PassengerName = p.PassengerName
+		}
+		names[l.PassengerName] = true
+		p.Passengers = append(p.Passengers, l)
+	}
+	p.SetFieldSource("passengers", FromBCBPAirlineUse)
+	p.Warnings = append(p.Warnings, fmt.Sprintf("group pass: %d passengers on %d legs; passenger_name and the flight fields are the first leg's", len(names), len(p.Passengers)))
+	return true
+}
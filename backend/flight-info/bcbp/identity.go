@@ -0,0 +1,97 @@
+package bcbp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ----------------------
+// LOGIC: STABLE PASS ID
+// ----------------------
+
+// Stamp sets ID and ParsedAt on a pass a parser just built from raw. Both
+// parsers call it last, so a barcode and a .pkpass for the same flight get
+// the same ID. A pass without enough fields for StableID gets a hash of raw
+// instead, and a warning saying so.
+func Stamp(p *UnifiedBoardingPass, raw []byte) {
+	StampReader(p, bytes.NewReader(raw))
+}
+
+// StampReader is Stamp for raw input too large to hold in memory. raw is
+// only read when the pass needs the fallback ID; the error is raw's.
+func StampReader(p *UnifiedBoardingPass, raw io.Reader) error {
+	p.ParsedAt = time.Now().UTC().Format(time.RFC3339)
+	id, ok := StableID(p)
+	if !ok {
+		h := sha256.New()
+		if _, err := io.Copy(h, raw); err != nil {
+			return err
+		}
+		id = hex.EncodeToString(h.Sum(nil))[:16]
+		p.Warnings = append(p.Warnings, "id: PNR, flight number, date or passenger name missing; derived from the raw input, so other copies of this pass won't share it")
+	}
+	p.ID = id
+	return nil
+}
+
+// StableID hashes the normalized PNR, carrier, flight number, date and
+// passenger name. ok is false when the PNR, flight number, date or name is
+// missing; the ID is then still derived from what there is, but may clash.
+func StableID(p *UnifiedBoardingPass) (id string, ok bool) {
+	carrier, number := splitFlight(p.Carrier, p.FlightNumber)
+	date := p.DateISO
+	if date == "" {
+		date = strings.TrimSpace(p.Date)
+	}
+	pnr := strings.ToUpper(strings.TrimSpace(p.PNR))
+	name := normalizeName(p.PassengerName)
+	sum := sha256.Sum256([]byte(strings.Join([]string{pnr, carrier, number, date, name}, "|")))
+	return hex.EncodeToString(sum[:])[:16], pnr != "" && number != "" && date != "" && name != ""
+}
+
+// flightDesignator is a flight number with its airline prefix, as pkpass
+// files print it: "AC834", "TP 1234", "U2 8765A".
+var flightDesignator = regexp.MustCompile(`^([A-Z][A-Z0-9]|[0-9][A-Z])\s*(\d{1,4}[A-Z]?)$`)
+
+// splitFlight separates "AC 0834" into "AC" and "834". An explicit carrier
+// wins over the prefix.
+func splitFlight(carrier, flight string) (string, string) {
+	carrier = strings.ToUpper(strings.TrimSpace(carrier))
+	flight = strings.ToUpper(strings.TrimSpace(flight))
+	if m := flightDesignator.FindStringSubmatch(flight); m != nil {
+		if carrier == "" {
+			carrier = m[1]
+		}
+		flight = m[2]
+	}
+	return carrier, strings.TrimLeft(flight, "0")
+}
+
+var nameTitles = []string{"MR", "MRS", "MS", "MISS", "MSTR", "DR", "PROF", "CHD", "INF"}
+
+// normalizeName reduces "DESMARAIS/LUC MR" and "Luc Desmarais" to the same
+// string: upper-case letter runs with accents removed, titles dropped,
+// sorted.
+func normalizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, norm.NFD.String(name))
+	words := strings.FieldsFunc(strings.ToUpper(name), func(r rune) bool {
+		return !('A' <= r && r <= 'Z')
+	})
+	words = slices.DeleteFunc(words, func(w string) bool { return slices.Contains(nameTitles, w) })
+	slices.Sort(words)
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,94 @@
+package bcbp
+
+import (
+	"cmp"
+	"strings"
+)
+
+// ----------------------
+// LOGIC: INFANTS
+// ----------------------
+
+// An infant travelling on an adult's lap gets a boarding pass of its own,
+// with the adult's PNR and no seat. Carriers mark it in one of three
+// places: the passenger description of the unique conditional section
+// (code 4), the seat slot ("INF"), or, when they fill in neither, an "INF"
+// after the given name where a title would go ("SILVA/MARIA INF").
+// PassengerType is set from whichever there is; the name is kept as
+// printed, and StableID already leaves the title out.
+
+// PassengerInfant is the PassengerType of a lap infant's pass.
+const PassengerInfant = "infant"
+
+// detectInfant sets PassengerType on an infant's pass. seat is the seat
+// slot as printed, before SetSeat turned "INF" into a seat status.
+func detectInfant(p *UnifiedBoardingPass, seat string) {
+	src := FromInferred
+	switch {
+	case p.RawData["passenger_description"] == "infant":
+		src = FromBCBPConditional
+	case seatKey(seat) == "INF":
+		src = FromBCBPMandatory
+	case infantName(p.PassengerName):
+	default:
+		return
+	}
+	p.PassengerType = PassengerInfant
+	p.SetFieldSource("passenger_type", src)
+}
+
+// infantName reports whether name, in SURNAME/GIVEN form, ends in the
+// title INF.
+func infantName(name string) bool {
+	_, given, ok := strings.Cut(name, "/")
+	words := strings.Fields(given)
+	return ok && len(words) > 1 && words[len(words)-1] == "INF"
+}
+
+// AccompanyingAdult returns the index of the pass in candidates that
+// infant, a lap infant's pass, travels with: one for the same flight
+// under the same PNR, of neither an infant nor a child. A passenger
+// description saying the passenger travels with an infant counts first,
+// then the infant's surname, then the order of candidates. It is -1 when
+// there is none.
+func AccompanyingAdult(infant *UnifiedBoardingPass, candidates []*UnifiedBoardingPass) int {
+	best, bestScore := -1, -1
+	for i, a := range candidates {
+		if a.PassengerType == PassengerInfant || !sameBookedFlight(infant, a) {
+			continue
+		}
+		score := 0
+		switch a.RawData["passenger_description"] {
+		case "child", "unaccompanied_minor", "no_passenger", "infant":
+			continue
+		case "adult_with_infant":
+			score += 2
+		}
+		if s := surname(infant.PassengerName); s != "" && s == surname(a.PassengerName) {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// sameBookedFlight reports whether a and b have the same PNR, carrier,
+// flight number and date.
+func sameBookedFlight(a, b *UnifiedBoardingPass) bool {
+	pnr := func(p *UnifiedBoardingPass) string { return strings.ToUpper(strings.TrimSpace(p.PNR)) }
+	date := func(p *UnifiedBoardingPass) string { return cmp.Or(p.DateISO, strings.TrimSpace(p.Date)) }
+	ac, an := splitFlight(a.Carrier, a.FlightNumber)
+	bc, bn := splitFlight(b.Carrier, b.FlightNumber)
+	return pnr(a) != "" && pnr(a) == pnr(b) && an != "" && ac == bc && an == bn && date(a) == date(b)
+}
+
+// surname is the part of a SURNAME/GIVEN name before the slash.
+func surname(name string) string {
+	s, _, ok := strings.Cut(name, "/")
+	if !ok {
+		return ""
+	}
+	return strings.ToUpper(strings.TrimSpace(s))
+}
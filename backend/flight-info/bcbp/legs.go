@@ -0,0 +1,166 @@
+package bcbp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ----------------------
+// LOGIC: MULTI-LEG BARCODES
+// ----------------------
+
+// A barcode holds up to nine legs. The first is the 60-character mandatory
+// section and its variable size field; each later leg has a shorter
+// mandatory part and a variable size field of its own, holding a repeated
+// conditional section (with its own hex size) and airline use data, but no
+// version or unique section: the first leg's version applies to them. Each leg's items go into RawData under
+// leg-numbered keys ("leg2_frequent_flyer_number"; the first leg's keep
+// their plain keys) and into the leg's Conditional.
+
+// legMandatorySize is the width of the mandatory part of legs 2 and on,
+// from the PNR to the conditional size: the first leg's fields minus the
+// format code, leg count, name and e-ticket indicator.
+const legMandatorySize = 37
+
+// WarnLegOverrun starts the warning for a later leg whose variable size
+// field runs past the end of the barcode.
+const WarnLegOverrun = "leg_size_overrun"
+
+// laterLegs reads legs 2 and on of raw, as many as the leg count says and
+// the sizes allow, stopping at the security section. PassengerName is the
+// name found at the start of the leg's airline use data, if any. A leg
+// whose variable size field runs past the end of raw is read up to the
+// end, with a warning.
+func laterLegs(raw string) (legs []PassengerLeg, warnings []string) {
+	n, err := strconv.Atoi(raw[1:2])
+	if err != nil || n < 2 || len(raw) < 60 {
+		return nil, nil
+	}
+	size, err := strconv.ParseUint(raw[58:60], 16, 8)
+	if err != nil {
+		return nil, nil
+	}
+	pos := 60 + int(size)
+
+	for i := range n - 1 {
+		if pos+legMandatorySize > len(raw) || raw[pos] == '^' {
+			break
+		}
+		m := raw[pos : pos+legMandatorySize]
+		size, err := strconv.ParseUint(m[35:37], 16, 8)
+		if err != nil {
+			break
+		}
+		start, end := pos+legMandatorySize, pos+legMandatorySize+int(size)
+		if end > len(raw) {
+			warnings = append(warnings, fmt.Sprintf("%s: leg %d declares %d characters of conditional data, %d past the end of the barcode; read to the end",
+				WarnLegOverrun, i+2, size, end-len(raw)))
+			end = len(raw)
+		}
+		field := func(start, end int) string { return strings.TrimSpace(m[start:end]) }
+		leg := PassengerLeg{
+			PNR:            field(0, 7),
+			Departure:      field(7, 10),
+			Arrival:        field(10, 13),
+			Carrier:        field(13, 16),
+			FlightNumber:   field(16, 21),
+			Date:           field(21, 24),
+			Seat:           field(25, 29),
+			SequenceNumber: field(29, 34),
+			Status:         field(34, 35),
+		}
+		var use string
+		leg.Conditional, use = legConditional(raw[start:end], bcbpVersion(raw))
+		if name := strings.TrimSpace(use[:min(20, len(use))]); groupName.MatchString(name) {
+			leg.PassengerName = name
+		}
+		legs = append(legs, leg)
+		pos = end
+	}
+	return legs, warnings
+}
+
+// legConditional reads a later leg's variable size field: the repeated
+// section's size, its items, then airline use, returned as is besides.
+// Items cut short by the end of the field, or that version predates, are
+// left out.
+func legConditional(cond string, version int) (items map[string]string, use string) {
+	if len(cond) < 2 {
+		return nil, ""
+	}
+	size, err := strconv.ParseUint(cond[:2], 16, 8)
+	if err != nil {
+		return nil, ""
+	}
+	end := min(2+int(size), len(cond))
+	items = map[string]string{}
+	pos := 2
+	for _, f := range repeatedFields {
+		if pos+f.width > end {
+			break
+		}
+		if v := strings.TrimSpace(cond[pos : pos+f.width]); v != "" && version >= f.since {
+			items[f.key] = codeValue(f.key, v)
+		}
+		pos += f.width
+	}
+	use = cond[end:]
+	if v := strings.TrimSpace(use); v != "" {
+		items["airline_use"] = v
+	}
+	if len(items) == 0 {
+		items = nil
+	}
+	return items, use
+}
+
+// applyLegs reads legs 2 and on of raw into RawData, then into Passengers
+// for a group pass (see applyGroup) or into Legs.
+func applyLegs(p *UnifiedBoardingPass, raw string) {
+	legs, warnings := laterLegs(raw)
+	p.Warnings = append(p.Warnings, warnings...)
+	if len(legs) == 0 {
+		return
+	}
+	for i, l := range legs {
+		for k, v := range l.Conditional {
+			p.RawData[fmt.Sprintf("leg%d_%s", i+2, k)] = v
+		}
+	}
+
+	first := PassengerLeg{
+		PassengerName:  p.PassengerName,
+		PNR:            p.PNR,
+		Departure:      p.Departure,
+		Arrival:        p.Arrival,
+		Carrier:        p.Carrier,
+		FlightNumber:   p.FlightNumber,
+		Date:           p.Date,
+		Seat:           p.Seat,
+		SequenceNumber: p.SequenceNumber,
+		Status:         p.Status,
+	}
+	first.Conditional = map[string]string{}
+	for _, f := range repeatedFields {
+		if v := p.RawData[f.key]; v != "" {
+			first.Conditional[f.key] = v
+		}
+	}
+	if v := p.RawData["airline_use"]; v != "" {
+		first.Conditional["airline_use"] = v
+	}
+	if len(first.Conditional) == 0 {
+		first.Conditional = nil
+	}
+	if applyGroup(p, first, legs) {
+		return
+	}
+
+	p.Legs = []PassengerLeg{first}
+	for _, l := range legs {
+		l.PassengerName = p.PassengerName
+		p.Legs = append(p.Legs, l)
+	}
+	p.SetFieldSource("legs", FromBCBPMandatory)
+}
@@ -0,0 +1,53 @@
+package bcbp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ----------------------
+// LOGIC: LENIENT INPUT
+// ----------------------
+
+// Check-in test environments (Amadeus Altea's among them) emit barcodes
+// that are BCBP except for two things: the format code is lowercase, and
+// fields are padded with NUL bytes instead of spaces. Production passes
+// never look like that, so the strict parser rejects the NULs, but a
+// lenient parse normalizes both before validation and says so in a
+// warning starting with LenientWarningPrefix.
+
+// LenientWarningPrefix starts the warning ParseLenientAt adds when it
+// changed the input.
+const LenientWarningPrefix = "lenient: "
+
+// NormalizeLenient reads NUL bytes as spaces and uppercases the format
+// code of raw, after NormalizeEncoding. changes describes what it did, and
+// is empty when raw was already well-formed.
+func NormalizeLenient(raw string) (normalized string, changes []string) {
+	raw = NormalizeEncoding(raw)
+	if n := strings.Count(raw, "\x00"); n > 0 {
+		raw = strings.ReplaceAll(raw, "\x00", " ")
+		changes = append(changes, fmt.Sprintf("%d NUL padding bytes read as spaces", n))
+	}
+	if raw != "" && (raw[0] == 'm' || raw[0] == 's') {
+		changes = append(changes, fmt.Sprintf("format code %q read as %q", raw[:1], strings.ToUpper(raw[:1])))
+		raw = strings.ToUpper(raw[:1]) + raw[1:]
+	}
+	return raw, changes
+}
+
+// ParseLenientAt is ParseAt on the input as NormalizeLenient leaves it.
+// RawData["raw_string"] is the normalized text, so the pass gets the same
+// ID as its well-formed equivalent.
+func ParseLenientAt(raw string, ref time.Time) (*UnifiedBoardingPass, error) {
+	raw, changes := NormalizeLenient(raw)
+	p, err := ParseAt(raw, ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) > 0 {
+		p.Warnings = append(p.Warnings, LenientWarningPrefix+"test-environment barcode normalized: "+strings.Join(changes, "; "))
+	}
+	return p, nil
+}
@@ -0,0 +1,76 @@
+package bcbp
+
+import "bugsbyte/flight-info/internal/refdata"
+
+// ----------------------
+// LOGIC: CITY AND STATION CODES
+// ----------------------
+
+// Not every IATA location code is an airport. Bookings to "any London
+// airport" carry the metropolitan code (LON, PAR, NYC), and air-rail
+// itineraries such as Lufthansa's AIRail give the train leg a station code
+// (QKL for Köln Hbf, XDB for Lille Europe). ClassifyLocations says which
+// kind each end of a pass and its legs is, so enrichment names them and
+// doesn't warn about an unknown airport, and a train leg is a train.
+
+// LocationType is the kind of place an IATA location code names, as the
+// airport dataset has it.
+type LocationType = refdata.LocationType
+
+const (
+	LocationAirport = refdata.LocationAirport
+	LocationCity    = refdata.LocationCity // a metropolitan area, all its airports
+	LocationRail    = refdata.LocationRail // a railway station
+	LocationBus     = refdata.LocationBus  // a bus station
+)
+
+// transitModeOf is the vehicle that serves a leg between ends of types
+// from and to, "" for a flight.
+func transitModeOf(from, to LocationType) TransitMode {
+	switch {
+	case from == LocationRail || to == LocationRail:
+		return TransitTrain
+	case from == LocationBus || to == LocationBus:
+		return TransitBus
+	}
+	return ""
+}
+
+// locationType is the kind of place code is in the airport dataset, with
+// airports and unknown codes left out: only the other types are written
+// on a pass.
+func locationType(code string) LocationType {
+	if t := refdata.LocationTypeOf(code); t != LocationAirport {
+		return t
+	}
+	return ""
+}
+
+// ClassifyLocations sets the location types of both ends of p and of its
+// legs, for codes that aren't airports, and the transit mode of a leg to
+// or from a station, over an air Wallet pass's transitType: AIRail passes
+// are issued as flights. Train and bus passes are left alone; their
+// stations are names, not codes.
+func ClassifyLocations(p *UnifiedBoardingPass) {
+	if p.TransitMode.Ground() {
+		return
+	}
+	p.DepartureType, p.ArrivalType = locationType(p.Departure), locationType(p.Arrival)
+	if p.DepartureType != "" {
+		p.SetFieldSource("departure_location_type", FromInferred)
+	}
+	if p.ArrivalType != "" {
+		p.SetFieldSource("arrival_location_type", FromInferred)
+	}
+	if m := transitModeOf(p.DepartureType, p.ArrivalType); m != "" {
+		p.TransitMode = m
+		p.SetFieldSource("transit_mode", FromInferred)
+	}
+	for _, legs := range [][]PassengerLeg{p.Legs, p.Passengers} {
+		for i := range legs {
+			l := &legs[i]
+			l.DepartureType, l.ArrivalType = locationType(l.Departure), locationType(l.Arrival)
+			l.TransitMode = transitModeOf(l.DepartureType, l.ArrivalType)
+		}
+	}
+}
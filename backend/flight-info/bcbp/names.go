@@ -0,0 +1,129 @@
+package bcbp
+
+import (
+	"regexp"
+	"strings"
+
+	"bugsbyte/flight-info/internal/refdata"
+)
+
+// ----------------------
+// LOGIC: AIRLINE AND AIRPORT NAMES
+// ----------------------
+
+// A pass carries codes; NameCarriers and NameAirports look them up in the
+// embedded datasets for the names an app shows. Both run on request (the
+// api's ?enrich=true, and always in the WebAssembly build), so a parsed
+// pass only has what its barcode or pass.json said.
+
+// FlightCarrierPattern splits the carrier designator off flight numbers
+// like "TP 432" or "U21234"; designators are two characters, at least one
+// a letter.
+var FlightCarrierPattern = regexp.MustCompile(`^([A-Z][A-Z0-9]|[0-9][A-Z])\s*\d{1,4}[A-Z]?$`)
+
+// lookupAirlineText resolves free text such as "LH", "Lufthansa" or
+// "Operated by Lufthansa" from pkpass fields.
+func lookupAirlineText(v string) (refdata.Airline, bool) {
+	v = strings.TrimSpace(v)
+	if a, ok := refdata.LookupAirline(v); ok {
+		return a, true
+	}
+	lower := strings.ToLower(v)
+	var best refdata.Airline
+	for _, a := range refdata.Airlines() {
+		// Longest match wins, so "Iberia Express" beats "Iberia".
+		if strings.Contains(lower, strings.ToLower(a.Name)) && len(a.Name) > len(best.Name) {
+			best = a
+		}
+	}
+	return best, best.Name != ""
+}
+
+// NameCarriers fills in airline names. For barcodes Carrier is the
+// operating carrier and the conditional section may name a different
+// marketing carrier; pkpass files usually show the marketing flight number
+// and mention the operator in an "operated by" field. Codes not in the
+// dataset are left unnamed with a warning.
+func NameCarriers(p *UnifiedBoardingPass) {
+	marketing := strings.TrimSpace(p.RawData["marketing_carrier"])
+	marketingSource := FromBCBPConditional
+	if p.MarketingCarrier != "" {
+		marketing, marketingSource = p.MarketingCarrier, p.FieldSources["marketing_carrier"]
+	}
+	// A pkpass carrier the parser read from an operated-by clause or the
+	// barcode message stands; otherwise it is inferred here, as it is for
+	// passes read by OCR, which are matched the same way.
+	if (p.Source == SourcePkPass || p.Source == SourceOCR) && p.Carrier == "" {
+		if m := FlightCarrierPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(p.FlightNumber))); m != nil {
+			marketing, marketingSource = m[1], FromInferred
+		}
+		for k, v := range p.RawData {
+			if !strings.Contains(strings.ToLower(k), "operat") {
+				continue
+			}
+			if a, ok := lookupAirlineText(v); ok && p.Carrier == "" {
+				p.Carrier = a.IATA
+			}
+		}
+		if p.Carrier == "" {
+			p.Carrier = marketing
+		}
+		if p.Carrier != "" {
+			p.SetFieldSource("carrier", FromInferred)
+		}
+	}
+
+	carrier := strings.TrimSpace(p.Carrier)
+	if carrier == "" {
+		p.Warnings = append(p.Warnings, "carrier unknown: carrier_name left empty")
+		return
+	}
+	if a, ok := refdata.LookupAirline(carrier); ok {
+		p.CarrierName = a.Name
+	} else {
+		p.Warnings = append(p.Warnings, "carrier "+carrier+" not in airline dataset: carrier_name left empty")
+	}
+
+	if marketing == "" || strings.EqualFold(marketing, carrier) {
+		return
+	}
+	p.MarketingCarrier = marketing
+	p.SetFieldSource("marketing_carrier", marketingSource)
+	if a, ok := refdata.LookupAirline(marketing); ok {
+		p.MarketingCarrierName = a.Name
+	} else {
+		p.Warnings = append(p.Warnings, "marketing carrier "+marketing+" not in airline dataset: marketing_carrier_name left empty")
+	}
+}
+
+// NameAirports fills in the airport and city names of both ends of p,
+// cities in lang ("pt", "de", ...; see refdata.Airport.CityIn). A city
+// code names only the city, its metropolitan area; a station code names
+// the station. Codes not in the dataset are left unnamed with a warning,
+// except for ground transport, whose stations mostly aren't.
+func NameAirports(p *UnifiedBoardingPass, lang string) {
+	for _, end := range []struct {
+		what       string
+		code       string
+		name, city *string
+	}{
+		{"departure", p.Departure, &p.DepartureAirportName, &p.DepartureCity},
+		{"arrival", p.Arrival, &p.ArrivalAirportName, &p.ArrivalCity},
+	} {
+		code := strings.TrimSpace(end.code)
+		if code == "" {
+			continue
+		}
+		a, ok := refdata.LookupAirport(code)
+		if !ok {
+			if !p.TransitMode.Ground() {
+				p.Warnings = append(p.Warnings, end.what+" airport "+code+" not in airport dataset: "+end.what+"_city left empty")
+			}
+			continue
+		}
+		if a.Type != LocationCity {
+			*end.name = a.Name
+		}
+		*end.city = a.CityIn(lang)
+	}
+}
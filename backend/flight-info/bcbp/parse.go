@@ -0,0 +1,394 @@
+package bcbp
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ----------------------
+// LOGIC: IATA BCBP PARSER (SMART VERSION)
+// ----------------------
+
+// ParseError is the error Parse returns for input that isn't BCBP. Code
+// says what was wrong with it, and Offset where: the byte at fault, or the
+// length of input that was too short.
+type ParseError struct {
+	Code   string
+	Offset int
+	Err    error
+}
+
+// ParseError codes.
+const (
+	ErrCodeTooShort        = "too_short"
+	ErrCodeFormatCode      = "format_code"      // doesn't start with M or S
+	ErrCodeInvalidEncoding = "invalid_encoding" // wraps ErrInvalidEncoding
+)
+
+func (e *ParseError) Error() string { return e.Err.Error() }
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// Parse reads the mandatory fields of the first leg, and the conditional
+// fields when present, from raw BCBP text. A byte order mark is stripped
+// and UTF-16 is transcoded first; a mandatory section that still isn't
+// printable ASCII fails with ErrInvalidEncoding. The Julian date is resolved to
+// the nearest matching date around today. The raw string is kept in
+// RawData["raw_string"], and the pass is stamped with an ID (see Stamp).
+func Parse(raw string) (*UnifiedBoardingPass, error) {
+	return ParseAt(raw, time.Now())
+}
+
+// ParseAt is Parse with the Julian date resolved around ref instead of
+// today, for results that don't change with the clock.
+func ParseAt(raw string, ref time.Time) (*UnifiedBoardingPass, error) {
+	raw = NormalizeEncoding(raw)
+
+	// 1. Basic Validation
+	if len(raw) < 20 {
+		return nil, &ParseError{ErrCodeTooShort, len(raw), errors.New("barcode too short")}
+	}
+	upper := strings.ToUpper(string(raw[0]))
+	if upper != "M" && upper != "S" {
+		return nil, &ParseError{ErrCodeFormatCode, 0, errors.New("barcode must start with 'M' or 'S'")}
+	}
+	if err := checkMandatoryASCII(raw); err != nil {
+		return nil, err
+	}
+
+	v := map[string]string{}
+	for _, f := range Fields(raw) {
+		v[f.Name] = f.Value
+	}
+	name := v["passenger_name"]
+	pnr := v["pnr"]
+	from := v["departure_airport"]
+	to := v["arrival_airport"]
+	carrier := v["carrier"]
+	flight := v["flight_number"]
+	date := v["date_julian"]
+	compartment := v["cabin_class"]
+	seat := v["seat"]
+	sequence := v["sequence_number"]
+	status := v["passenger_status"]
+
+	pass := &UnifiedBoardingPass{
+		Source:         SourceBarcode,
+		PassengerName:  name,
+		PNR:            pnr,
+		Departure:      from,
+		Arrival:        to,
+		Carrier:        carrier,
+		FlightNumber:   flight,
+		Date:           date,
+		DateISO:        ResolveJulianDate(date, ref),
+		CabinClass:     compartment,
+		SequenceNumber: sequence,
+		Status:         status,
+		RawData: map[string]string{
+			"raw_string": raw,
+		},
+	}
+	for k, v := range parseConditional(raw) {
+		pass.RawData[k] = v
+	}
+	for field, v := range map[string]string{
+		"passenger_name": name, "pnr": pnr, "departure_airport": from, "arrival_airport": to,
+		"carrier": carrier, "flight_number": flight, "date_julian": date, "cabin_class": compartment,
+		"sequence_number": sequence, "passenger_status": status,
+	} {
+		if v != "" {
+			pass.SetFieldSource(field, FromBCBPMandatory)
+		}
+	}
+	if pass.DateISO != "" {
+		pass.SetFieldSource("date_iso", FromInferred)
+	}
+	pass.SetSeat(seat, FromBCBPMandatory)
+	detectInfant(pass, seat)
+	if fast, ok := map[string]bool{"yes": true, "no": false}[pass.RawData["fast_track"]]; ok {
+		pass.FastTrack = &fast
+		pass.SetFieldSource("fast_track", FromBCBPConditional)
+	}
+	if group, priority := boardingFromAirlineUse(pass.RawData["airline_use"]); group != "" || priority {
+		pass.BoardingGroup = group
+		pass.PriorityBoarding = priority
+		if group != "" {
+			pass.SetFieldSource("boarding_group", FromBCBPConditional)
+		}
+		if priority {
+			pass.SetFieldSource("priority_boarding", FromBCBPConditional)
+		}
+	}
+	applyCarrierProfile(pass, ref)
+	applyLegs(pass, raw)
+	ClassifyLocations(pass)
+	Stamp(pass, []byte(raw))
+
+	return pass, nil
+}
+
+// parseConditional reads the first leg's conditional fields (marketing
+// carrier, frequent flyer, ...) and the unique section's sources of
+// issuance into RawData keys, leaving out the sizes and markers that only
+// give the section its structure. Coded values are spelled out (see
+// conditionalCodes).
+func parseConditional(raw string) map[string]string {
+	out := map[string]string{}
+	version := bcbpVersion(raw)
+	for _, f := range conditionalFields(raw) {
+		switch {
+		case f.Name == "unique_section":
+			pos := 0
+			for _, u := range uniqueFields {
+				if pos+u.width > len(f.Raw) {
+					break
+				}
+				if v := strings.TrimSpace(f.Raw[pos : pos+u.width]); u.key != "" && v != "" && version >= u.since {
+					out[u.key] = codeValue(u.key, v)
+				}
+				pos += u.width
+			}
+		case f.Value != "" && !structuralFields[f.Name]:
+			out[f.Name] = codeValue(f.Name, f.Value)
+		}
+	}
+	return out
+}
+
+// bcbpVersion is the version number of raw's conditional section, 0 when
+// it has none or it isn't a digit.
+func bcbpVersion(raw string) int {
+	if len(raw) < 62 || raw[60] != '>' || raw[61] < '0' || raw[61] > '9' {
+		return 0
+	}
+	return int(raw[61] - '0')
+}
+
+// conditionalCodes spell out the coded conditional items, by RawData key.
+// A code that isn't listed is kept as printed.
+var conditionalCodes = map[string]map[string]string{
+	"fast_track": {"Y": "yes", "N": "no"},
+	"passenger_description": {
+		"0": "adult", "1": "male", "2": "female", "3": "child", "4": "infant",
+		"5": "no_passenger", "6": "adult_with_infant", "7": "unaccompanied_minor",
+	},
+	"intl_doc_verification": {"0": "not_required", "1": "required", "2": "performed"},
+	"check_in_source": {
+		"W": "web", "K": "kiosk", "R": "remote", "M": "mobile",
+		"O": "airport_agent", "T": "town_agent", "V": "third_party",
+	},
+	"boarding_pass_source": {
+		"W": "web", "K": "kiosk", "X": "transfer_kiosk", "R": "remote", "M": "mobile",
+		"O": "airport_agent", "T": "town_agent", "V": "third_party",
+	},
+}
+
+func codeValue(key, v string) string {
+	if s, ok := conditionalCodes[key][v]; ok {
+		return s
+	}
+	return v
+}
+
+// FieldSpan is one field of a BCBP string: its name, byte offsets
+// raw[Start:End], and its value before and after trimming spaces.
+type FieldSpan struct {
+	Name  string `json:"name"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Raw   string `json:"raw"`
+	Value string `json:"value"`
+}
+
+// mandatoryFields is the fixed-width section of the first leg. The names
+// are the UnifiedBoardingPass JSON names where there is one.
+var mandatoryFields = []struct {
+	name       string
+	start, end int
+}{
+	{"format_code", 0, 1}, // 'M' or 'S'
+	{"number_of_legs", 1, 2},
+	{"passenger_name", 2, 22},
+	{"electronic_ticket", 22, 23}, // 'E'
+	{"pnr", 23, 30},               // booking reference
+	{"departure_airport", 30, 33}, // IATA code
+	{"arrival_airport", 33, 36},   // IATA code
+	{"carrier", 36, 39},           // operating carrier designator
+	{"flight_number", 39, 44},
+	{"date_julian", 44, 47}, // day of year
+	{"cabin_class", 47, 48}, // compartment code
+	{"seat", 48, 52},
+	{"sequence_number", 52, 57}, // check-in sequence
+	{"passenger_status", 57, 58},
+	{"conditional_size", 58, 60}, // hex
+}
+
+// structuralFields are spans that hold sizes or markers, not data.
+var structuralFields = map[string]bool{
+	"conditional_size": true, "version_marker": true, "unique_size": true,
+	"unique_section": true, "repeated_size": true,
+}
+
+// Fields splits raw into the spans of the first leg: the mandatory section
+// (IATA BCBP fixed-width fields) and, when its sizes add up, the
+// conditional section. Fields past the end of raw are left out, and the
+// last one may be cut short.
+func Fields(raw string) []FieldSpan {
+	var out []FieldSpan
+	for _, f := range mandatoryFields {
+		if span, ok := fieldSpan(raw, f.name, f.start, f.end); ok {
+			out = append(out, span)
+		}
+	}
+	return append(out, conditionalFields(raw)...)
+}
+
+func fieldSpan(raw, name string, start, end int) (FieldSpan, bool) {
+	if start >= len(raw) {
+		return FieldSpan{}, false
+	}
+	end = min(end, len(raw))
+	return FieldSpan{Name: name, Start: start, End: end, Raw: raw[start:end], Value: strings.TrimSpace(raw[start:end])}, true
+}
+
+// conditionalFields reads the first leg's repeated conditional fields that
+// follow the mandatory 60 characters. Barcodes without a conditional
+// section, or with sizes that don't add up, simply yield fewer fields.
+//
+//	[58-59]  Field size of variable size field (hex)
+//	[60]     Beginning of version number ('>')
+//	[61]     Version number
+//	[62-63]  Field size of following structured message, unique (hex)
+//	...      Unique fields, then the repeated fields for this leg:
+//	         size (2 hex), airline numeric code (3), document serial (10),
+//	         selectee (1), intl. documentation verification (1),
+//	         marketing carrier (3), frequent flyer airline (3),
+//	         frequent flyer number (16), ID/AD indicator (1),
+//	         free baggage allowance (3), fast track (1)
+//	...      Individual airline use, up to the end of the variable size field
+//
+// Items a version predates (see repeatedFields) are skipped over, not
+// read: their bytes belong to the airline in older barcodes.
+func conditionalFields(raw string) []FieldSpan {
+	var out []FieldSpan
+	add := func(name string, start, end int) {
+		if span, ok := fieldSpan(raw, name, start, end); ok {
+			out = append(out, span)
+		}
+	}
+	hexSize := func(pos int) (int, bool) {
+		if pos+2 > len(raw) {
+			return 0, false
+		}
+		n, err := strconv.ParseUint(raw[pos:pos+2], 16, 8)
+		return int(n), err == nil
+	}
+
+	varSize, ok := hexSize(58)
+	if !ok || varSize < 4 || len(raw) < 60+varSize || raw[60] != '>' {
+		return out
+	}
+	add("version_marker", 60, 61)
+	add("bcbp_version", 61, 62)
+
+	uniqueSize, ok := hexSize(62)
+	if !ok {
+		return out
+	}
+	add("unique_size", 62, 64)
+	if uniqueSize > 0 {
+		add("unique_section", 64, 64+uniqueSize)
+	}
+	pos := 64 + uniqueSize
+	repeatedSize, ok := hexSize(pos)
+	if !ok || pos+2+repeatedSize > 60+varSize {
+		return out
+	}
+	add("repeated_size", pos, pos+2)
+	pos += 2
+	end := pos + repeatedSize
+
+	version := bcbpVersion(raw)
+	for _, f := range repeatedFields {
+		if pos+f.width > end {
+			break
+		}
+		if version >= f.since {
+			add(f.key, pos, pos+f.width)
+		}
+		pos += f.width
+	}
+	if end < 60+varSize {
+		add("airline_use", end, 60+varSize)
+	}
+	return out
+}
+
+// repeatedFields are the items of a leg's repeated conditional section, in
+// order, under their RawData keys. Since is the first BCBP version that
+// has the item.
+var repeatedFields = []struct {
+	key   string
+	width int
+	since int
+}{
+	{"airline_numeric_code", 3, 0},
+	{"document_serial", 10, 0},
+	{"selectee", 1, 0},
+	{"intl_doc_verification", 1, 6},
+	{"marketing_carrier", 3, 0},
+	{"frequent_flyer_airline", 3, 0},
+	{"frequent_flyer_number", 16, 0},
+	{"id_ad_indicator", 1, 0},
+	{"free_baggage", 3, 0},
+	{"fast_track", 1, 6},
+}
+
+// uniqueFields are the leading items of the unique conditional section,
+// as repeatedFields. Those without a key aren't read. All three have been
+// there since the first version.
+var uniqueFields = []struct {
+	key   string
+	width int
+	since int
+}{
+	{"passenger_description", 1, 0},
+	{"check_in_source", 1, 0},
+	{"boarding_pass_source", 1, 0},
+}
+
+// ResolveJulianDate turns a BCBP day-of-year ("046") into an ISO date.
+// The barcode carries no year, so the candidate closest to ref (from the
+// previous, current, and next year) wins — a pass is almost always scanned
+// within a few months of the flight. Returns "" when the day is invalid.
+func ResolveJulianDate(julian string, ref time.Time) string {
+	day, err := strconv.Atoi(julian)
+	if err != nil || day < 1 || day > 366 {
+		return ""
+	}
+
+	ref = time.Date(ref.Year(), ref.Month(), ref.Day(), 0, 0, 0, 0, time.UTC)
+	var best time.Time
+	for _, year := range []int{ref.Year() - 1, ref.Year(), ref.Year() + 1} {
+		candidate := time.Date(year, time.January, day, 0, 0, 0, 0, time.UTC)
+		if candidate.Year() != year {
+			continue // day 366 in a non-leap year
+		}
+		if best.IsZero() || absDuration(candidate.Sub(ref)) < absDuration(best.Sub(ref)) {
+			best = candidate
+		}
+	}
+	if best.IsZero() {
+		return ""
+	}
+	return best.Format(time.DateOnly)
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
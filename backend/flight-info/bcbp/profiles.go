@@ -0,0 +1,122 @@
+package bcbp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ----------------------
+// LOGIC: CARRIER PROFILES
+// ----------------------
+
+// The airline use section at the end of a leg's conditional data is free
+// for the issuing airline; IATA doesn't define it. A few carriers put the
+// passenger's date of birth and US traveler numbers there at fixed
+// positions. carrierProfiles holds those layouts, by operating carrier and
+// the airline numeric code of the ticket, and only passes matching both
+// are read with one: the same positions on another airline's pass mean
+// something else, so a carrier without a layout confirmed against its own
+// passes must not get a profile.
+
+// airlineUseField is a value at fixed positions of the airline use data.
+type airlineUseField struct {
+	key        string // RawData key
+	start, end int
+	pattern    *regexp.Regexp // the value must match it whole
+}
+
+// carrierProfile is what is known about one carrier's barcodes.
+type carrierProfile struct {
+	numericCode string // airline_numeric_code of its own tickets
+	airlineUse  []airlineUseField
+	dobLayout   string // time layout of RawData["date_of_birth"]
+}
+
+var (
+	ktnPattern      = regexp.MustCompile(`^[0-9A-Z]{9}$`) // TSA PreCheck Known Traveler Number
+	redressPattern  = regexp.MustCompile(`^[0-9]{7}$`)    // DHS TRIP redress number
+	dobDDMMMYY      = regexp.MustCompile(`^[0-9]{2}[A-Z]{3}[0-9]{2}$`)
+	dobYYYYMMDD     = regexp.MustCompile(`^[0-9]{8}$`)
+	carrierProfiles = map[string]carrierProfile{
+		// Delta: date of birth as DDMMMYY, then the Known Traveler Number.
+		"DL": {
+			numericCode: "006",
+			dobLayout:   "02Jan06",
+			airlineUse: []airlineUseField{
+				{"date_of_birth", 0, 7, dobDDMMMYY},
+				{"ktn", 7, 16, ktnPattern},
+			},
+		},
+		// American: date of birth as YYYYMMDD, the Known Traveler Number,
+		// then the redress number.
+		"AA": {
+			numericCode: "001",
+			dobLayout:   "20060102",
+			airlineUse: []airlineUseField{
+				{"date_of_birth", 0, 8, dobYYYYMMDD},
+				{"ktn", 8, 17, ktnPattern},
+				{"redress_number", 17, 24, redressPattern},
+			},
+		},
+	}
+)
+
+// applyCarrierProfile reads the airline use data of p with its carrier's
+// profile, if it has one and the ticket is the carrier's own. Values that
+// don't match their pattern are skipped with a warning naming the field
+// but not the value, which is personal data; a blank one is
+// simply absent. The values go to RawData as printed, with a
+// FromBCBPAirlineUse field source under the same key. ref anchors
+// two-digit birth years: a birth date is never after ref.
+func applyCarrierProfile(p *UnifiedBoardingPass, ref time.Time) {
+	prof, ok := carrierProfiles[p.Carrier]
+	data := p.RawData["airline_use"]
+	if !ok || data == "" || p.RawData["airline_numeric_code"] != prof.numericCode {
+		return
+	}
+	for _, f := range prof.airlineUse {
+		if f.start >= len(data) {
+			break
+		}
+		v := data[f.start:min(f.end, len(data))]
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		if !f.pattern.MatchString(v) {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("%s: the value in the %s airline use data is not in the expected format; skipped", f.key, p.Carrier))
+			continue
+		}
+		if f.key == "date_of_birth" {
+			dob, err := time.Parse(prof.dobLayout, v)
+			if err != nil {
+				p.Warnings = append(p.Warnings, fmt.Sprintf("date_of_birth: the value in the %s airline use data is not a date; skipped", p.Carrier))
+				continue
+			}
+			if dob.After(ref) {
+				// A two-digit year was taken as the wrong century.
+				dob = dob.AddDate(-100, 0, 0)
+			}
+			p.DateOfBirth = dob.Format(time.DateOnly)
+		}
+		p.RawData[f.key] = v
+		p.SetFieldSource(f.key, FromBCBPAirlineUse)
+	}
+}
+
+// PersonalAirlineUseKeys returns the RawData keys that hold personal data
+// read from the airline use data of carrier's passes: every key its
+// profile reads, and "airline_use" itself, whose positions are personal
+// whether or not they parsed. It is nil for a carrier without a profile.
+func PersonalAirlineUseKeys(carrier string) []string {
+	prof, ok := carrierProfiles[carrier]
+	if !ok {
+		return nil
+	}
+	keys := []string{"airline_use"}
+	for _, f := range prof.airlineUse {
+		keys = append(keys, f.key)
+	}
+	return keys
+}
@@ -0,0 +1,121 @@
+package bcbp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ----------------------
+// LOGIC: SEATS THAT AREN'T SEATS
+// ----------------------
+
+// Low-cost carriers print "---", "N/A" or "SEE AGENT" where the seat
+// goes when none is assigned yet, and some put the boarding group letter
+// there. SetSeat keeps such values out of Seat and says what they meant in
+// SeatStatus instead; a real seat leaves SeatStatus empty.
+
+// Seat statuses, for a seat slot that holds no seat.
+const (
+	SeatUnassigned = "unassigned" // no seat yet, or none to have (open seating, infants)
+	SeatSeeAgent   = "see_agent"  // the seat is given at the desk or gate
+	SeatStandby    = "standby"
+)
+
+// SeatSentinels maps the seat values that mean there is no seat, in upper
+// case with single spaces, to their seat status. The API adds those of
+// SEAT_SENTINELS at startup (see AddSeatSentinel).
+var SeatSentinels = map[string]string{
+	"N/A":              SeatUnassigned,
+	"NA":               SeatUnassigned,
+	"NONE":             SeatUnassigned,
+	"NO SEAT":          SeatUnassigned,
+	"TBA":              SeatUnassigned,
+	"TBD":              SeatUnassigned,
+	"ANY":              SeatUnassigned,
+	"FREE":             SeatUnassigned,
+	"FREE SEATING":     SeatUnassigned,
+	"OPEN":             SeatUnassigned,
+	"OPEN SEATING":     SeatUnassigned,
+	"UNASSIGNED":       SeatUnassigned,
+	"NOT ASSIGNED":     SeatUnassigned,
+	"INF":              SeatUnassigned, // BCBP: an infant on a lap
+	"SEE AGENT":        SeatSeeAgent,
+	"ASK AGENT":        SeatSeeAgent,
+	"SEE GATE":         SeatSeeAgent,
+	"AT GATE":          SeatSeeAgent,
+	"GATE":             SeatSeeAgent,
+	"CHECK AT GATE":    SeatSeeAgent,
+	"ASSIGNED AT GATE": SeatSeeAgent,
+	"SEE DESK":         SeatSeeAgent,
+	"STANDBY":          SeatStandby,
+	"STBY":             SeatStandby,
+	"SBY":              SeatStandby,
+}
+
+var (
+	// seatMarks is a value of dashes, stars and the like: "---", "**".
+	seatMarks = regexp.MustCompile(`^[-*_./ ]+$`)
+	// seatGroup is a boarding group letter in the seat slot, with an
+	// optional boarding position ("A23").
+	seatGroup = regexp.MustCompile(`^([A-Z])[0-9]{0,2}$`)
+)
+
+// AddSeatSentinel adds value to SeatSentinels with status, which must be
+// one of the seat statuses.
+func AddSeatSentinel(value, status string) error {
+	switch status {
+	case SeatUnassigned, SeatSeeAgent, SeatStandby:
+	default:
+		return fmt.Errorf("seat status %q is not %s, %s or %s", status, SeatUnassigned, SeatSeeAgent, SeatStandby)
+	}
+	key := seatKey(value)
+	if key == "" {
+		return fmt.Errorf("empty seat value for %s", status)
+	}
+	SeatSentinels[key] = status
+	return nil
+}
+
+func seatKey(v string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(v)), " ")
+}
+
+// NormalizeSeat reads a seat slot as printed. A seat comes back as it is.
+// A value that means there is no seat (see SeatSentinels), or is only
+// dashes, gives "" and its status. A boarding group letter gives "", status
+// unassigned and the group.
+func NormalizeSeat(v string) (seat, status, group string) {
+	key := seatKey(v)
+	if s, ok := SeatSentinels[key]; ok {
+		return "", s, ""
+	}
+	if seatMarks.MatchString(key) {
+		return "", SeatUnassigned, ""
+	}
+	if m := seatGroup.FindStringSubmatch(key); m != nil {
+		return "", SeatUnassigned, m[1]
+	}
+	return v, "", ""
+}
+
+// SetSeat sets p's seat from the seat slot v read from src (see
+// NormalizeSeat). A group found there fills in BoardingGroup if the pass
+// has none yet.
+func (p *UnifiedBoardingPass) SetSeat(v string, src FieldSource) {
+	seat, status, group := NormalizeSeat(v)
+	p.Seat, p.SeatStatus = seat, status
+	// A later seat field replaces an earlier one, sources included.
+	delete(p.FieldSources, "seat")
+	delete(p.FieldSources, "seat_status")
+	switch {
+	case seat != "":
+		p.SetFieldSource("seat", src)
+	case status != "":
+		p.SetFieldSource("seat_status", src)
+	}
+	if group != "" && p.BoardingGroup == "" {
+		p.BoardingGroup = group
+		p.SetFieldSource("boarding_group", src)
+	}
+}
@@ -0,0 +1,193 @@
+package bcbp
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/internal/refdata"
+)
+
+// ----------------------
+// LOGIC: SEMANTIC CHECKS
+// ----------------------
+
+// A pass can be well formed and still not make sense: a departure airport
+// typed twice at the counter, a Wallet pass whose route text names another
+// airport than its fields, a barcode issued days after the flight it is
+// for. Parsing keeps such values, since any of them may be the right one,
+// and CheckSemantics runs Rules over the finished pass to say what looks
+// wrong. Each finding is a warning starting with its code.
+//
+// A new check is a Rule appended to Rules, and a golden fixture that
+// trips it.
+
+// Warning codes of the Rules.
+const (
+	WarnSameAirports     = "same_airports"
+	WarnFlightNumberZero = "flight_number_zero"
+	WarnSeatOpenSeating  = "seat_on_open_seating"
+	WarnIssuedAfter      = "issued_after_flight"
+	WarnRouteMismatch    = "route_mismatch"
+)
+
+// Warning is one finding of a Rule.
+type Warning struct {
+	Code    string
+	Message string
+}
+
+// String is the warning as it goes into UnifiedBoardingPass.Warnings.
+func (w Warning) String() string { return w.Code + ": " + w.Message }
+
+// Rule checks a finished pass and returns what it found wrong, if anything.
+type Rule func(p *UnifiedBoardingPass) []Warning
+
+// Rules are the checks CheckSemantics runs, in order.
+var Rules = []Rule{
+	sameAirports,
+	flightNumberZero,
+	seatOnOpenSeating,
+	issuedAfterFlight,
+	routeMismatch,
+}
+
+// CheckSemantics appends the findings of Rules to p's warnings.
+func CheckSemantics(p *UnifiedBoardingPass) {
+	for _, rule := range Rules {
+		for _, w := range rule(p) {
+			p.Warnings = append(p.Warnings, w.String())
+		}
+	}
+}
+
+// sameAirports flags a leg that departs from where it arrives.
+func sameAirports(p *UnifiedBoardingPass) []Warning {
+	var out []Warning
+	check := func(leg, from, to string) {
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if from != "" && strings.EqualFold(from, to) {
+			out = append(out, Warning{WarnSameAirports, fmt.Sprintf("%sdeparture and arrival are both %s", leg, from)})
+		}
+	}
+	legs := p.Passengers
+	if len(legs) == 0 {
+		legs = p.Legs
+	}
+	if len(legs) == 0 {
+		check("", p.Departure, p.Arrival)
+	}
+	for i, l := range legs {
+		check(fmt.Sprintf("leg %d: ", i+1), l.Departure, l.Arrival)
+	}
+	return out
+}
+
+// zeroFlight is a flight number without a number, only zeros, with the
+// carrier in front or a suffix letter.
+var zeroFlight = regexp.MustCompile(`^(?:[A-Z][A-Z0-9]|[0-9][A-Z])?\s*0+[A-Z]?$`)
+
+// flightNumberZero flags flight number 0, which no airline flies: a
+// placeholder, or a field left blank and padded.
+func flightNumberZero(p *UnifiedBoardingPass) []Warning {
+	if f := strings.ToUpper(strings.TrimSpace(p.FlightNumber)); zeroFlight.MatchString(f) {
+		return []Warning{{WarnFlightNumberZero, fmt.Sprintf("flight number %q is zero", p.FlightNumber)}}
+	}
+	return nil
+}
+
+// assignedSeat is a row and a seat letter, as opposed to the free text
+// ("GATE", "STBY", "INF") passes put in the seat field when there is none.
+var assignedSeat = regexp.MustCompile(`^[0-9]{1,3}[A-Z]$`)
+
+// seatOnOpenSeating flags an assigned seat on a carrier whose passengers
+// pick their own, as the airline dataset has it.
+func seatOnOpenSeating(p *UnifiedBoardingPass) []Warning {
+	carrier, seat := strings.TrimSpace(p.Carrier), strings.TrimSpace(p.Seat)
+	if carrier == "" || !assignedSeat.MatchString(seat) || !refdata.OpenSeating(carrier) {
+		return nil
+	}
+	return []Warning{{WarnSeatOpenSeating, fmt.Sprintf("seat %s on %s, which doesn't assign seats", seat, carrier)}}
+}
+
+// maxIssueLag is how long after the flight date a boarding pass may say it
+// was issued, for time zones and flights past midnight.
+const maxIssueLag = 2 * 24 * time.Hour
+
+// issuedAfterFlight flags a barcode whose date of issue is after the
+// flight date. The date of issue is the unique conditional section's
+// "YDDD": the last digit of the year and the day of the year, read as the
+// nearest such date to the flight.
+func issuedAfterFlight(p *UnifiedBoardingPass) []Warning {
+	flight, err := time.Parse(time.DateOnly, p.DateISO)
+	if err != nil {
+		return nil
+	}
+	issued, ok := issueDate(p.RawData["raw_string"], flight)
+	if !ok || issued.Sub(flight) <= maxIssueLag {
+		return nil
+	}
+	return []Warning{{WarnIssuedAfter, fmt.Sprintf("boarding pass issued on %s, %d days after the flight date %s",
+		issued.Format(time.DateOnly), int(issued.Sub(flight).Hours()/24), p.DateISO)}}
+}
+
+// issueDate reads the date of issue from raw's unique conditional section,
+// the nearest date to flight with that year digit and day.
+func issueDate(raw string, flight time.Time) (time.Time, bool) {
+	var unique string
+	for _, f := range conditionalFields(raw) {
+		if f.Name == "unique_section" {
+			unique = f.Raw
+		}
+	}
+	// Passenger description, source of check-in and source of issuance
+	// come first.
+	if len(unique) < 7 {
+		return time.Time{}, false
+	}
+	digit, err1 := strconv.Atoi(unique[3:4])
+	day, err2 := strconv.Atoi(unique[4:7])
+	if err1 != nil || err2 != nil || day < 1 || day > 366 {
+		return time.Time{}, false
+	}
+	var best time.Time
+	for year := flight.Year() - 5; year <= flight.Year()+5; year++ {
+		d := time.Date(year, time.January, day, 0, 0, 0, 0, time.UTC)
+		if year%10 != digit || d.Year() != year {
+			continue
+		}
+		if best.IsZero() || absDuration(d.Sub(flight)) < absDuration(best.Sub(flight)) {
+			best = d
+		}
+	}
+	return best, !best.IsZero()
+}
+
+// routeText is a field value that is nothing but a route: two airport codes
+// and an arrow, dash or "to" between them.
+var routeText = regexp.MustCompile(`^([A-Z]{3})\s*(?:→|->|>|-|–|—|✈|to|TO)\s*([A-Z]{3})$`)
+
+// routeMismatch flags a Wallet pass whose route field names other airports
+// than its departure and arrival. Only fields keyed as a route count:
+// connections and itineraries name other flights.
+func routeMismatch(p *UnifiedBoardingPass) []Warning {
+	if p.Source != SourcePkPass || p.Departure == "" || p.Arrival == "" {
+		return nil
+	}
+	var out []Warning
+	for _, k := range slices.Sorted(maps.Keys(p.RawData)) {
+		if !strings.Contains(strings.ToLower(k), "route") {
+			continue
+		}
+		m := routeText.FindStringSubmatch(strings.TrimSpace(p.RawData[k]))
+		if m == nil || (m[1] == p.Departure && m[2] == p.Arrival) {
+			continue
+		}
+		out = append(out, Warning{WarnRouteMismatch, fmt.Sprintf("%s %q contradicts %s to %s", k, p.RawData[k], p.Departure, p.Arrival)})
+	}
+	return out
+}
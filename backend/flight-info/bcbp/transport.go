@@ -0,0 +1,84 @@
+package bcbp
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ----------------------
+// LOGIC: TRANSPORT ENCODINGS
+// ----------------------
+
+// Some scanner SDKs hand back the barcode payload base64- or hex-encoded
+// rather than as text, and that is what gets pasted. Neither encoding of a
+// BCBP string starts with 'M' or 'S' ("M1" is "TTE" in base64 and "4D31" in
+// hex), so it is only ever tried on input Parse rejected. A short hex or
+// base64 string can decode to anything, so the decoded text is only taken
+// when it reads as a boarding pass by the standard's own rules: mostly
+// printable, with a mandatory section Check finds nothing wrong with.
+
+// Input encodings DecodeTransport recognizes, as
+// RawData["input_encoding"] names them.
+const (
+	InputEncodingBase64 = "base64"
+	InputEncodingHex    = "hex"
+)
+
+// minPrintable is the share of printable ASCII bytes decoded text needs;
+// security data may hold a few others.
+const minPrintable = 0.95
+
+// DecodeTransport reads raw as the hex or base64 encoding of a BCBP
+// string, whitespace aside. ok is false unless the decoded text is one
+// whose mandatory section conforms to Resolution 792 (see Check).
+func DecodeTransport(raw string) (text, encoding string, ok bool) {
+	s := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, raw)
+	if s == "" {
+		return "", "", false
+	}
+	if b, err := hex.DecodeString(s); err == nil && strictMandatory(b) {
+		return string(b), InputEncodingHex, true
+	}
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		if b, err := enc.DecodeString(s); err == nil && strictMandatory(b) {
+			return string(b), InputEncodingBase64, true
+		}
+	}
+	return "", "", false
+}
+
+// strictMandatory reports whether b is mostly printable and parses, with a
+// whole mandatory section in the standard's formats.
+func strictMandatory(b []byte) bool {
+	if len(b) < 60 || (b[0] != 'M' && b[0] != 'S') {
+		return false
+	}
+	printable := 0
+	for _, c := range b {
+		if c >= 0x20 && c <= 0x7e {
+			printable++
+		}
+	}
+	if float64(printable) < minPrintable*float64(len(b)) {
+		return false
+	}
+	if _, err := ParseAt(string(b), time.Time{}); err != nil {
+		return false
+	}
+	for _, f := range Check(string(b)) {
+		if f.Offset < 60 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,293 @@
+// Package bcbp parses and encodes IATA Bar Coded Boarding Pass (BCBP)
+// strings, and defines UnifiedBoardingPass, the format every parser in this
+// module produces.
+package bcbp
+
+import "encoding/json"
+
+// UnifiedBoardingPass is a boarding pass from any source. Fields a source
+// doesn't provide are left empty, and empty fields are left out of the JSON,
+// except in schema version 0 (see MarshalJSON).
+type UnifiedBoardingPass struct {
+	// SchemaVersion picks the JSON shape: 0, the original, or 1. It is only
+	// set on the way out, by the API, and is echoed in version 1.
+	SchemaVersion int `json:"schema_version,omitempty"`
+
+	// ID is the same for every scan of the same pass, from either source;
+	// ParsedAt is when the parser ran (RFC 3339, UTC). See Stamp.
+	ID       string `json:"id,omitempty"`
+	ParsedAt string `json:"parsed_at,omitempty"`
+	Source   Source `json:"source,omitempty"`
+	// TransitMode is the pkpass transitType. Barcodes only set it for a
+	// first leg to or from a station (see ClassifyLocations).
+	TransitMode   TransitMode `json:"transit_mode,omitempty"`
+	PassengerName string      `json:"passenger_name,omitempty"`
+	PassengerType string      `json:"passenger_type,omitempty"` // "infant" on a lap infant's pass, else empty
+	PNR           string      `json:"pnr,omitempty"`
+	FlightNumber  string      `json:"flight_number,omitempty"`
+	Departure     string      `json:"departure_airport,omitempty"`
+	Arrival       string      `json:"arrival_airport,omitempty"`
+	// DepartureType and ArrivalType are set when the code isn't an
+	// airport: a city or a station (see ClassifyLocations).
+	DepartureType LocationType `json:"departure_location_type,omitempty"`
+	ArrivalType   LocationType `json:"arrival_location_type,omitempty"`
+	Date          string       `json:"date_julian,omitempty"`
+	DateISO       string       `json:"date_iso,omitempty"`
+	BoardingTime  string       `json:"boarding_time,omitempty"`
+	DepartureTime string       `json:"departure_time,omitempty"`
+	Seat          string       `json:"seat,omitempty"`
+	// SeatStatus says why Seat is empty when the seat slot held a
+	// placeholder such as "---" or "SEE AGENT" (see SetSeat).
+	SeatStatus     string `json:"seat_status,omitempty"`
+	CabinClass     string `json:"cabin_class,omitempty"`
+	Carrier        string `json:"carrier,omitempty"`
+	Gate           string `json:"gate,omitempty"`
+	Terminal       string `json:"terminal,omitempty"`
+	CheckInDesk    string `json:"check_in_desk,omitempty"` // pkpass only; a range as "340-348"
+	BoardingDoor   string `json:"boarding_door,omitempty"` // pkpass only; left out when it repeats Gate
+	BoardingGroup  string `json:"boarding_group,omitempty"`
+	SequenceNumber string `json:"sequence_number,omitempty"` // check-in sequence, as printed
+	// BoardingGroup is normalized ("Zone 3" is "3"); PriorityBoarding is set
+	// when the group or a field says so.
+	PriorityBoarding bool `json:"priority_boarding,omitempty"`
+	// FastTrack is the BCBP fast track indicator, nil when the barcode
+	// has none (before version 6, or left blank).
+	FastTrack *bool `json:"fast_track,omitempty"`
+	// Status is the BCBP passenger status code, e.g. "1" for checked in.
+	Status string `json:"passenger_status,omitempty"`
+	// DateOfBirth (YYYY-MM-DD) is only read from barcodes of carriers with
+	// a known airline use layout (see carrierProfiles).
+	DateOfBirth string `json:"date_of_birth,omitempty"`
+	// GroupPass is set when one barcode covers several passengers, each
+	// leg in Passengers (see applyGroup); the fields above are the first
+	// leg's.
+	GroupPass  bool           `json:"group_pass,omitempty"`
+	Passengers []PassengerLeg `json:"passengers,omitempty"`
+	// Legs lists every leg of a multi-leg barcode for one passenger (see
+	// applyLegs), the first leg's being the fields above. A group pass
+	// lists its legs in Passengers instead.
+	Legs    []PassengerLeg    `json:"legs,omitempty"`
+	RawData map[string]string `json:"raw_extra_data,omitempty"`
+	// FieldSources maps the JSON name of each field read or inferred from
+	// the pass to where its value came from.
+	FieldSources map[string]FieldSource `json:"field_sources,omitempty"`
+
+	// RFC 3339 timestamps in the departure airport's zone and in UTC, set
+	// when both the date and the matching clock time are known.
+	BoardingTimeLocal  string `json:"boarding_time_local,omitempty"`
+	BoardingTimeUTC    string `json:"boarding_time_utc,omitempty"`
+	DepartureTimeLocal string `json:"departure_time_local,omitempty"`
+	DepartureTimeUTC   string `json:"departure_time_utc,omitempty"`
+
+	// Filled in only with ?enrich=true. The cities are in the language the
+	// request negotiated.
+	CarrierName          string `json:"carrier_name,omitempty"`
+	MarketingCarrier     string `json:"marketing_carrier,omitempty"`
+	MarketingCarrierName string `json:"marketing_carrier_name,omitempty"`
+	DepartureAirportName string `json:"departure_airport_name,omitempty"`
+	DepartureCity        string `json:"departure_city,omitempty"`
+	ArrivalAirportName   string `json:"arrival_airport_name,omitempty"`
+	ArrivalCity          string `json:"arrival_city,omitempty"`
+
+	// Filled in only with ?status=true and a configured provider.
+	FlightStatus *FlightStatus `json:"flight_status,omitempty"`
+
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Detail is what the parser saw before building the pass, set only
+	// with ?detail=full.
+	Detail *ParseDetail `json:"detail,omitempty"`
+
+	// Set only on responses when persistence is enabled. Changes lists
+	// what an update changed in the stored version (see Diff).
+	Duplicate bool          `json:"duplicate,omitempty"`
+	Updated   bool          `json:"updated,omitempty"`
+	Changes   []FieldChange `json:"changes,omitempty"`
+}
+
+// PassengerLeg is one leg of a multi-leg barcode and the passenger it is
+// for, with the fields as printed in the barcode.
+type PassengerLeg struct {
+	PassengerName string `json:"passenger_name"`
+	PNR           string `json:"pnr,omitempty"`
+	Departure     string `json:"departure_airport,omitempty"`
+	Arrival       string `json:"arrival_airport,omitempty"`
+	// As on UnifiedBoardingPass, set only for a city or a station.
+	DepartureType  LocationType `json:"departure_location_type,omitempty"`
+	ArrivalType    LocationType `json:"arrival_location_type,omitempty"`
+	TransitMode    TransitMode  `json:"transit_mode,omitempty"`
+	Carrier        string       `json:"carrier,omitempty"`
+	FlightNumber   string       `json:"flight_number,omitempty"`
+	Date           string       `json:"date_julian,omitempty"`
+	Seat           string       `json:"seat,omitempty"`
+	SequenceNumber string       `json:"sequence_number,omitempty"`
+	Status         string       `json:"passenger_status,omitempty"`
+	// Conditional holds the leg's own conditional items (frequent flyer,
+	// bag allowance, fast track, airline use), under their RawData keys.
+	Conditional map[string]string `json:"conditional,omitempty"`
+}
+
+// Source is the kind of input a pass was parsed from.
+type Source string
+
+const (
+	SourceBarcode Source = "barcode"
+	SourcePkPass  Source = "pkpass"
+	// SourceWalletScreenshot is a barcode read from a screenshot of an
+	// Apple Wallet pass rather than from a scan or photo.
+	SourceWalletScreenshot Source = "image:wallet_screenshot"
+	// SourceOCR is a pass read from the text printed on an image whose
+	// barcode did not decode.
+	SourceOCR Source = "image:ocr"
+)
+
+// TransitMode is the kind of vehicle a pass is for.
+type TransitMode string
+
+const (
+	TransitAir     TransitMode = "air"
+	TransitTrain   TransitMode = "train"
+	TransitBus     TransitMode = "bus"
+	TransitBoat    TransitMode = "boat"
+	TransitGeneric TransitMode = "generic"
+)
+
+// Ground reports whether the pass is for a train or bus, whose origin and
+// destination are station names rather than airport codes.
+func (m TransitMode) Ground() bool {
+	return m == TransitTrain || m == TransitBus
+}
+
+// FieldSource is where the value of one field came from, so clients can
+// tell a value printed in the barcode from one a heuristic picked.
+type FieldSource string
+
+const (
+	FromBCBPMandatory   FieldSource = "bcbp_mandatory"   // fixed-width section of the barcode
+	FromBCBPConditional FieldSource = "bcbp_conditional" // conditional section of the barcode
+	FromBCBPAirlineUse  FieldSource = "bcbp_airline_use" // airline use data, read with a carrier profile
+	FromPkPassSemantics FieldSource = "pkpass_semantics" // semantic tags of pass.json
+	FromPkPassLabel     FieldSource = "pkpass_label"     // a pass.json field matched by key or label
+	FromInferred        FieldSource = "inferred"         // derived from other fields or reference data
+	FromOCR             FieldSource = "ocr"              // text recognized in an image; low confidence
+)
+
+// SetFieldSource records where the value of the JSON field name came from.
+func (p *UnifiedBoardingPass) SetFieldSource(name string, src FieldSource) {
+	if p.FieldSources == nil {
+		p.FieldSources = map[string]FieldSource{}
+	}
+	p.FieldSources[name] = src
+}
+
+// passV1 has UnifiedBoardingPass's fields and tags but not its MarshalJSON.
+type passV1 UnifiedBoardingPass
+
+// passV0 is schema version 0: the fields the pass had before version 1, in
+// their original order, with the core string fields always written, even
+// when empty. They shadow those of passV1, whose newer fields follow.
+// Clients compare this JSON byte for byte; don't reorder it.
+type passV0 struct {
+	ID                   string            `json:"id,omitempty"`
+	Source               Source            `json:"source"`
+	PassengerName        string            `json:"passenger_name"`
+	PNR                  string            `json:"pnr"`
+	FlightNumber         string            `json:"flight_number"`
+	Departure            string            `json:"departure_airport"`
+	Arrival              string            `json:"arrival_airport"`
+	Date                 string            `json:"date_julian,omitempty"`
+	DateISO              string            `json:"date_iso,omitempty"`
+	BoardingTime         string            `json:"boarding_time,omitempty"`
+	DepartureTime        string            `json:"departure_time,omitempty"`
+	Seat                 string            `json:"seat"`
+	CabinClass           string            `json:"cabin_class"`
+	Carrier              string            `json:"carrier"`
+	RawData              map[string]string `json:"raw_extra_data,omitempty"`
+	BoardingTimeLocal    string            `json:"boarding_time_local,omitempty"`
+	BoardingTimeUTC      string            `json:"boarding_time_utc,omitempty"`
+	DepartureTimeLocal   string            `json:"departure_time_local,omitempty"`
+	DepartureTimeUTC     string            `json:"departure_time_utc,omitempty"`
+	CarrierName          string            `json:"carrier_name,omitempty"`
+	MarketingCarrier     string            `json:"marketing_carrier,omitempty"`
+	MarketingCarrierName string            `json:"marketing_carrier_name,omitempty"`
+	FlightStatus         *FlightStatus     `json:"flight_status,omitempty"`
+	Warnings             []string          `json:"warnings,omitempty"`
+	Duplicate            bool              `json:"duplicate,omitempty"`
+	Updated              bool              `json:"updated,omitempty"`
+	*passV1
+}
+
+// MarshalJSON writes p in the shape of p.SchemaVersion. Version 0 is the
+// default, so everything that doesn't ask for version 1 keeps getting the
+// original JSON.
+func (p UnifiedBoardingPass) MarshalJSON() ([]byte, error) {
+	if p.SchemaVersion >= 1 {
+		return json.Marshal((*passV1)(&p))
+	}
+	return json.Marshal(passV0{
+		ID:                   p.ID,
+		Source:               p.Source,
+		PassengerName:        p.PassengerName,
+		PNR:                  p.PNR,
+		FlightNumber:         p.FlightNumber,
+		Departure:            p.Departure,
+		Arrival:              p.Arrival,
+		Date:                 p.Date,
+		DateISO:              p.DateISO,
+		BoardingTime:         p.BoardingTime,
+		DepartureTime:        p.DepartureTime,
+		Seat:                 p.Seat,
+		CabinClass:           p.CabinClass,
+		Carrier:              p.Carrier,
+		RawData:              p.RawData,
+		BoardingTimeLocal:    p.BoardingTimeLocal,
+		BoardingTimeUTC:      p.BoardingTimeUTC,
+		DepartureTimeLocal:   p.DepartureTimeLocal,
+		DepartureTimeUTC:     p.DepartureTimeUTC,
+		CarrierName:          p.CarrierName,
+		MarketingCarrier:     p.MarketingCarrier,
+		MarketingCarrierName: p.MarketingCarrierName,
+		FlightStatus:         p.FlightStatus,
+		Warnings:             p.Warnings,
+		Duplicate:            p.Duplicate,
+		Updated:              p.Updated,
+		passV1:               (*passV1)(&p),
+	})
+}
+
+// ParseDetail is a parser's intermediate representation: the BCBP fields
+// with their offsets for a barcode, or the decoded pass.json for a pkpass.
+// Decode lists what the image readers found when the barcode came from an
+// image.
+type ParseDetail struct {
+	Fields   []FieldSpan       `json:"fields,omitempty"`
+	PassJSON json.RawMessage   `json:"pass_json,omitempty"`
+	Decode   []DecodeCandidate `json:"decode,omitempty"`
+	// DecodeProfile names the profile the image readers ran with.
+	DecodeProfile string `json:"decode_profile,omitempty"`
+}
+
+// DecodeCandidate is one barcode found in an image. Chosen marks the one
+// the pass was parsed from.
+type DecodeCandidate struct {
+	Format    string `json:"format"`
+	Binarizer string `json:"binarizer"`
+	Luminance string `json:"luminance,omitempty"`
+	Padding   string `json:"padding,omitempty"`
+	Text      string `json:"text"`
+	Chosen    bool   `json:"chosen,omitempty"`
+}
+
+// FlightStatus is live flight information from a status provider.
+type FlightStatus struct {
+	Provider           string `json:"provider"`
+	Status             string `json:"status"` // provider's wording, e.g. "Expected", "Delayed", "Canceled"
+	Cancelled          bool   `json:"cancelled,omitempty"`
+	ScheduledDeparture string `json:"scheduled_departure,omitempty"` // RFC 3339
+	EstimatedDeparture string `json:"estimated_departure,omitempty"`
+	ScheduledArrival   string `json:"scheduled_arrival,omitempty"`
+	EstimatedArrival   string `json:"estimated_arrival,omitempty"`
+	DelayMinutes       int    `json:"delay_minutes,omitempty"`
+	Gate               string `json:"gate,omitempty"`
+	Terminal           string `json:"terminal,omitempty"`
+}
@@ -0,0 +1,49 @@
+package bcbp
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestSchemaV0Bytes checks version 0 against JSON written by the pass type
+// before version 1 existed: old fields in their old order, then the new
+// ones.
+func TestSchemaV0Bytes(t *testing.T) {
+	const fullV0 = `{"id":"4b59a21c3cdf59a1","source":"barcode","passenger_name":"SILVA/JOAO","pnr":"XYZ987","flight_number":"0576","departure_airport":"LIS","arrival_airport":"FRA","date_julian":"050","date_iso":"2026-02-19","boarding_time":"09:10","departure_time":"09:40","seat":"012C","cabin_class":"Y","carrier":"TP","raw_extra_data":{"bcbp_version":"6","raw_string":"M1SILVA/JOAO"},"boarding_time_local":"2026-02-19T09:10:00Z","boarding_time_utc":"2026-02-19T09:10:00Z","departure_time_local":"2026-02-19T09:40:00Z","departure_time_utc":"2026-02-19T09:40:00Z","carrier_name":"TAP Air Portugal","marketing_carrier":"LH","marketing_carrier_name":"Lufthansa","flight_status":{"provider":"aerodatabox","status":"Expected","gate":"14"},"warnings":["a warning"],"duplicate":true,"updated":true}`
+
+	full := UnifiedBoardingPass{
+		ID: "4b59a21c3cdf59a1", Source: SourceBarcode, PassengerName: "SILVA/JOAO", PNR: "XYZ987",
+		FlightNumber: "0576", Departure: "LIS", Arrival: "FRA", Date: "050", DateISO: "2026-02-19",
+		BoardingTime: "09:10", DepartureTime: "09:40", Seat: "012C", CabinClass: "Y", Carrier: "TP",
+		RawData:           map[string]string{"bcbp_version": "6", "raw_string": "M1SILVA/JOAO"},
+		BoardingTimeLocal: "2026-02-19T09:10:00Z", BoardingTimeUTC: "2026-02-19T09:10:00Z",
+		DepartureTimeLocal: "2026-02-19T09:40:00Z", DepartureTimeUTC: "2026-02-19T09:40:00Z",
+		CarrierName: "TAP Air Portugal", MarketingCarrier: "LH", MarketingCarrierName: "Lufthansa",
+		FlightStatus: &FlightStatus{Provider: "aerodatabox", Status: "Expected", Gate: "14"},
+		Warnings:     []string{"a warning"}, Duplicate: true, Updated: true,
+	}
+	withNew := full
+	withNew.ParsedAt, withNew.Gate = "2026-02-15T10:12:00Z", "B12"
+
+	tests := []struct {
+		name string
+		pass UnifiedBoardingPass
+		want string
+	}{
+		{"full", full, fullV0},
+		{"empty", UnifiedBoardingPass{Source: SourcePkPass}, `{"source":"pkpass","passenger_name":"","pnr":"","flight_number":"","departure_airport":"","arrival_airport":"","seat":"","cabin_class":"","carrier":""}`},
+		{"newer fields last", withNew, strings.TrimSuffix(fullV0, "}") + `,"parsed_at":"2026-02-15T10:12:00Z","gate":"B12"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.pass)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
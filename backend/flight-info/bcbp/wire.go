@@ -0,0 +1,392 @@
+package bcbp
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ----------------------
+// SCHEMA: WIRE REGISTRY
+// ----------------------
+
+// Two apps read UnifiedBoardingPass JSON, and a renamed tag or a field that
+// turns from a string into a number breaks them without failing anything
+// here. WireSchemas lists, for each schema version, every path the pass
+// JSON may have and its JSON type. The golden-file check (TestGolden)
+// holds the registry to the JSON MarshalJSON actually writes, in both
+// directions, and holds each version to its committed lock file in
+// testdata/golden/schema: a version may gain fields, each named in
+// WireChangelog, but never lose or retype one. That takes a new version.
+
+// WireType is the JSON type of a field. WireMap is an object whose keys
+// are data, such as raw_extra_data: only the object itself is registered.
+type WireType string
+
+const (
+	WireString WireType = "string"
+	WireNumber WireType = "number"
+	WireBool   WireType = "boolean"
+	WireObject WireType = "object"
+	WireArray  WireType = "array"
+	WireMap    WireType = "map"
+)
+
+// WireField is one path of the pass JSON: object fields are joined with
+// dots and array elements are "[]", as in "passengers[].seat". Always
+// fields are written whenever their parent object is, even when empty.
+type WireField struct {
+	Path   string   `json:"path"`
+	Type   WireType `json:"type"`
+	Always bool     `json:"always,omitempty"`
+}
+
+// WireSchema is the pass JSON of one schema version.
+type WireSchema struct {
+	Version int         `json:"version"`
+	Fields  []WireField `json:"fields"`
+}
+
+// WireChange is a WireChangelog entry. Fields added to a released version
+// are named in backquotes in the Note of a change at or before it.
+type WireChange struct {
+	Version int    `json:"version"`
+	Note    string `json:"note"`
+}
+
+// CurrentSchemaVersion is the newest schema version.
+const CurrentSchemaVersion = 1
+
+// WireChangelog says what each schema version changed, oldest first.
+var WireChangelog = []WireChange{
+	{0, "The original shape: `source`, `passenger_name`, `pnr`, `flight_number`, `departure_airport`, `arrival_airport`, `seat`, `cabin_class` and `carrier` are always written, as \"\" when unknown."},
+	{0, "`detail.decode_profile` names how an image was read (in every version)."},
+	{0, "Enrichment names the airports, `departure_airport_name` and `arrival_airport_name`, and their cities, `departure_city` and `arrival_city` (in every version)."},
+	{0, "Multi-leg barcodes list every leg in `legs`, each `legs[]` with the fields of a group pass leg (`legs[].passenger_name`, `legs[].pnr`, `legs[].departure_airport`, `legs[].arrival_airport`, `legs[].carrier`, `legs[].flight_number`, `legs[].date_julian`, `legs[].seat`, `legs[].sequence_number`, `legs[].passenger_status`), and the legs of both have their own conditional items, `legs[].conditional` and `passengers[].conditional` (in every version)."},
+	{0, "`seat_status` says why `seat` is empty when the pass had a placeholder for it: `unassigned`, `see_agent` or `standby` (in every version)."},
+	{0, "Ends that aren't airports have `departure_location_type` and `arrival_location_type`, `city`, `rail` or `bus`, as do `legs[].departure_location_type`, `legs[].arrival_location_type`, `passengers[].departure_location_type` and `passengers[].arrival_location_type`; a leg to or from a station has `legs[].transit_mode` or `passengers[].transit_mode` (in every version)."},
+	{0, "`detail.decode[].padding` names the border an image cropped to its barcode was read on, `white` or `black` (in every version)."},
+	{0, "`fast_track` is the barcode's fast track indicator, from BCBP version 6 on (in every version)."},
+	{0, "An updated pass lists what the update changed in `changes`, each `changes[]` with `changes[].field`, `changes[].old` and `changes[].new` (in every version)."},
+	{0, "`.pkpass` desk, counter and door fields are read into `check_in_desk` and `boarding_door` (in every version)."},
+	{0, "`passenger_type` is `infant` on a lap infant's barcode (in every version)."},
+	{1, "`schema_version` is written, and every empty field is left out."},
+}
+
+// wireFields are the paths every version has. Always is set for the
+// fields that are written whenever their parent is, in every version.
+var wireFields = []WireField{
+	{Path: "id", Type: WireString},
+	{Path: "parsed_at", Type: WireString},
+	{Path: "source", Type: WireString},
+	{Path: "transit_mode", Type: WireString},
+	{Path: "passenger_name", Type: WireString},
+	{Path: "passenger_type", Type: WireString},
+	{Path: "pnr", Type: WireString},
+	{Path: "flight_number", Type: WireString},
+	{Path: "departure_airport", Type: WireString},
+	{Path: "arrival_airport", Type: WireString},
+	{Path: "departure_location_type", Type: WireString},
+	{Path: "arrival_location_type", Type: WireString},
+	{Path: "date_julian", Type: WireString},
+	{Path: "date_iso", Type: WireString},
+	{Path: "boarding_time", Type: WireString},
+	{Path: "departure_time", Type: WireString},
+	{Path: "seat", Type: WireString},
+	{Path: "seat_status", Type: WireString},
+	{Path: "cabin_class", Type: WireString},
+	{Path: "carrier", Type: WireString},
+	{Path: "gate", Type: WireString},
+	{Path: "terminal", Type: WireString},
+	{Path: "check_in_desk", Type: WireString},
+	{Path: "boarding_door", Type: WireString},
+	{Path: "boarding_group", Type: WireString},
+	{Path: "sequence_number", Type: WireString},
+	{Path: "priority_boarding", Type: WireBool},
+	{Path: "passenger_status", Type: WireString},
+	{Path: "fast_track", Type: WireBool},
+	{Path: "date_of_birth", Type: WireString},
+	{Path: "group_pass", Type: WireBool},
+	{Path: "passengers", Type: WireArray},
+	{Path: "passengers[]", Type: WireObject},
+	{Path: "passengers[].passenger_name", Type: WireString, Always: true},
+	{Path: "passengers[].pnr", Type: WireString},
+	{Path: "passengers[].departure_airport", Type: WireString},
+	{Path: "passengers[].arrival_airport", Type: WireString},
+	{Path: "passengers[].departure_location_type", Type: WireString},
+	{Path: "passengers[].arrival_location_type", Type: WireString},
+	{Path: "passengers[].transit_mode", Type: WireString},
+	{Path: "passengers[].carrier", Type: WireString},
+	{Path: "passengers[].flight_number", Type: WireString},
+	{Path: "passengers[].date_julian", Type: WireString},
+	{Path: "passengers[].seat", Type: WireString},
+	{Path: "passengers[].sequence_number", Type: WireString},
+	{Path: "passengers[].passenger_status", Type: WireString},
+	{Path: "passengers[].conditional", Type: WireMap},
+	{Path: "legs", Type: WireArray},
+	{Path: "legs[]", Type: WireObject},
+	{Path: "legs[].passenger_name", Type: WireString, Always: true},
+	{Path: "legs[].pnr", Type: WireString},
+	{Path: "legs[].departure_airport", Type: WireString},
+	{Path: "legs[].arrival_airport", Type: WireString},
+	{Path: "legs[].departure_location_type", Type: WireString},
+	{Path: "legs[].arrival_location_type", Type: WireString},
+	{Path: "legs[].transit_mode", Type: WireString},
+	{Path: "legs[].carrier", Type: WireString},
+	{Path: "legs[].flight_number", Type: WireString},
+	{Path: "legs[].date_julian", Type: WireString},
+	{Path: "legs[].seat", Type: WireString},
+	{Path: "legs[].sequence_number", Type: WireString},
+	{Path: "legs[].passenger_status", Type: WireString},
+	{Path: "legs[].conditional", Type: WireMap},
+	{Path: "raw_extra_data", Type: WireMap},
+	{Path: "field_sources", Type: WireMap},
+	{Path: "boarding_time_local", Type: WireString},
+	{Path: "boarding_time_utc", Type: WireString},
+	{Path: "departure_time_local", Type: WireString},
+	{Path: "departure_time_utc", Type: WireString},
+	{Path: "carrier_name", Type: WireString},
+	{Path: "marketing_carrier", Type: WireString},
+	{Path: "marketing_carrier_name", Type: WireString},
+	{Path: "departure_airport_name", Type: WireString},
+	{Path: "departure_city", Type: WireString},
+	{Path: "arrival_airport_name", Type: WireString},
+	{Path: "arrival_city", Type: WireString},
+	{Path: "flight_status", Type: WireObject},
+	{Path: "flight_status.provider", Type: WireString, Always: true},
+	{Path: "flight_status.status", Type: WireString, Always: true},
+	{Path: "flight_status.cancelled", Type: WireBool},
+	{Path: "flight_status.scheduled_departure", Type: WireString},
+	{Path: "flight_status.estimated_departure", Type: WireString},
+	{Path: "flight_status.scheduled_arrival", Type: WireString},
+	{Path: "flight_status.estimated_arrival", Type: WireString},
+	{Path: "flight_status.delay_minutes", Type: WireNumber},
+	{Path: "flight_status.gate", Type: WireString},
+	{Path: "flight_status.terminal", Type: WireString},
+	{Path: "warnings", Type: WireArray},
+	{Path: "warnings[]", Type: WireString},
+	{Path: "detail", Type: WireObject},
+	{Path: "detail.fields", Type: WireArray},
+	{Path: "detail.fields[]", Type: WireObject},
+	{Path: "detail.fields[].name", Type: WireString, Always: true},
+	{Path: "detail.fields[].start", Type: WireNumber, Always: true},
+	{Path: "detail.fields[].end", Type: WireNumber, Always: true},
+	{Path: "detail.fields[].raw", Type: WireString, Always: true},
+	{Path: "detail.fields[].value", Type: WireString, Always: true},
+	{Path: "detail.pass_json", Type: WireMap},
+	{Path: "detail.decode", Type: WireArray},
+	{Path: "detail.decode[]", Type: WireObject},
+	{Path: "detail.decode[].format", Type: WireString, Always: true},
+	{Path: "detail.decode[].binarizer", Type: WireString, Always: true},
+	{Path: "detail.decode[].luminance", Type: WireString},
+	{Path: "detail.decode[].padding", Type: WireString},
+	{Path: "detail.decode[].text", Type: WireString, Always: true},
+	{Path: "detail.decode[].chosen", Type: WireBool},
+	{Path: "detail.decode_profile", Type: WireString},
+	{Path: "duplicate", Type: WireBool},
+	{Path: "updated", Type: WireBool},
+	{Path: "changes", Type: WireArray},
+	{Path: "changes[]", Type: WireObject},
+	{Path: "changes[].field", Type: WireString, Always: true},
+	{Path: "changes[].old", Type: WireString, Always: true},
+	{Path: "changes[].new", Type: WireString, Always: true},
+}
+
+// v0Always are the fields version 0 writes even when empty (see passV0).
+var v0Always = []string{
+	"source", "passenger_name", "pnr", "flight_number", "departure_airport",
+	"arrival_airport", "seat", "cabin_class", "carrier",
+}
+
+// WireSchemas is the registry, one entry per version, oldest first.
+var WireSchemas = []WireSchema{
+	{Version: 0, Fields: wireSchema(0)},
+	{Version: 1, Fields: wireSchema(1)},
+}
+
+func wireSchema(version int) []WireField {
+	var fields []WireField
+	if version >= 1 {
+		fields = append(fields, WireField{Path: "schema_version", Type: WireNumber, Always: true})
+	}
+	for _, f := range wireFields {
+		if version == 0 && slices.Contains(v0Always, f.Path) {
+			f.Always = true
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// CheckWireRegistry checks the registry against itself: versions run from
+// 0 to CurrentSchemaVersion, and each has a WireChangelog entry.
+func CheckWireRegistry() error {
+	for i, s := range WireSchemas {
+		if s.Version != i {
+			return fmt.Errorf("WireSchemas[%d] is version %d", i, s.Version)
+		}
+		if !slices.ContainsFunc(WireChangelog, func(c WireChange) bool { return c.Version == s.Version }) {
+			return fmt.Errorf("schema version %d has no WireChangelog entry", s.Version)
+		}
+	}
+	if last := WireSchemas[len(WireSchemas)-1].Version; last != CurrentSchemaVersion {
+		return fmt.Errorf("CurrentSchemaVersion is %d but the newest registered version is %d", CurrentSchemaVersion, last)
+	}
+	return nil
+}
+
+// walk lists the paths of the JSON document b with their types, as the
+// registry names them, and the Always fields missing from objects that are
+// there. Maps registered as WireMap aren't descended into.
+func (s WireSchema) walk(b []byte) (paths map[string]WireType, missing []string, err error) {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, nil, err
+	}
+	maps := map[string]bool{}
+	always := map[string][]string{} // by parent
+	for _, f := range s.Fields {
+		if f.Type == WireMap {
+			maps[f.Path] = true
+		}
+		if f.Always {
+			always[wireParent(f.Path)] = append(always[wireParent(f.Path)], f.Path)
+		}
+	}
+	paths = map[string]WireType{}
+	var walk func(path string, v any)
+	walk = func(path string, v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			prefix := ""
+			if path != "" {
+				if maps[path] {
+					paths[path] = WireMap
+					return
+				}
+				paths[path] = WireObject
+				prefix = path + "."
+			}
+			for _, want := range always[path] {
+				if _, ok := v[strings.TrimPrefix(want, prefix)]; !ok && !slices.Contains(missing, want) {
+					missing = append(missing, want)
+				}
+			}
+			for k, e := range v {
+				walk(prefix+k, e)
+			}
+		case []any:
+			paths[path] = WireArray
+			for _, e := range v {
+				walk(path+"[]", e)
+			}
+		case string:
+			paths[path] = WireString
+		case float64:
+			paths[path] = WireNumber
+		case bool:
+			paths[path] = WireBool
+		}
+	}
+	walk("", v)
+	return paths, missing, nil
+}
+
+// Validate checks the pass JSON b against s: every path must be
+// registered with its type, and every Always field must be in each object
+// that is there.
+func (s WireSchema) Validate(b []byte) error {
+	return s.validate(b, false)
+}
+
+// ValidateExact is Validate for a pass with every field set: b must also
+// have every registered path.
+func (s WireSchema) ValidateExact(b []byte) error {
+	return s.validate(b, true)
+}
+
+func (s WireSchema) validate(b []byte, exact bool) error {
+	paths, missing, err := s.walk(b)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	for _, path := range missing {
+		problems = append(problems, fmt.Sprintf("%s: missing", path))
+	}
+	registered := map[string]WireType{}
+	for _, f := range s.Fields {
+		registered[f.Path] = f.Type
+		if _, ok := paths[f.Path]; exact && !ok && !f.Always {
+			problems = append(problems, fmt.Sprintf("%s: registered but not written", f.Path))
+		}
+	}
+	for path, t := range paths {
+		switch want, ok := registered[path]; {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s: not registered", path))
+		case want != t:
+			problems = append(problems, fmt.Sprintf("%s: %s, registered as %s", path, t, want))
+		}
+	}
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return fmt.Errorf("schema version %d: %s", s.Version, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// wireParent is the path path is nested in, "" at the top level.
+func wireParent(path string) string {
+	if p, ok := strings.CutSuffix(path, "[]"); ok {
+		return p
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// WireCompatible checks current against released, the same version as it
+// was committed: every released field must still be there with the same
+// type, and whether it is Always can't change. Added fields must be named
+// in WireChangelog.
+func WireCompatible(released, current WireSchema) error {
+	now := map[string]WireField{}
+	for _, f := range current.Fields {
+		now[f.Path] = f
+	}
+	var problems []string
+	for _, f := range released.Fields {
+		switch c, ok := now[f.Path]; {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s removed", f.Path))
+		case c.Type != f.Type:
+			problems = append(problems, fmt.Sprintf("%s retyped from %s to %s", f.Path, f.Type, c.Type))
+		case c.Always != f.Always:
+			problems = append(problems, fmt.Sprintf("%s changed from always=%t to always=%t", f.Path, f.Always, c.Always))
+		}
+		delete(now, f.Path)
+	}
+	for path := range now {
+		if !changelogNames(current.Version, path) {
+			problems = append(problems, fmt.Sprintf("%s added without a WireChangelog entry naming it", path))
+		}
+	}
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return fmt.Errorf("schema version %d: %s", current.Version, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// changelogNames reports whether a change at or before version names path.
+func changelogNames(version int, path string) bool {
+	for _, c := range WireChangelog {
+		if c.Version <= version && strings.Contains(c.Note, "`"+path+"`") {
+			return true
+		}
+	}
+	return false
+}
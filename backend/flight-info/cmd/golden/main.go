@@ -0,0 +1,64 @@
+// Command golden runs the golden-file check of bcbp/golden_test.go outside
+// go test, on any corpus directory: each input is parsed and compared with
+// its committed .want.json. With -update, the expectations are rewritten
+// from the current output instead; review the diff before committing it.
+//
+//	go run ./cmd/golden [-update] [-run SUBSTR] [-dir testdata/golden]
+//
+// Every pass is also checked against the wire registry, and the registry
+// against its lock files in schema/ (see fixture.CheckSchemas).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"bugsbyte/flight-info/internal/fixture"
+)
+
+func main() {
+	dir := flag.String("dir", "testdata/golden", "corpus directory")
+	update := flag.Bool("update", false, "rewrite the expectations from the current output")
+	run := flag.String("run", "", "only fixtures whose name contains this")
+	flag.Parse()
+
+	cases, err := fixture.Load(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "golden: %v\n", err)
+		os.Exit(1)
+	}
+
+	var failed, checked int
+	for _, c := range cases {
+		if *run != "" && !strings.Contains(c.Name, *run) {
+			continue
+		}
+		checked++
+		if err := c.Check(*update); err != nil {
+			fmt.Fprintf(os.Stderr, "FAIL %s: %v\n", c.Name, err)
+			failed++
+		}
+	}
+	if strings.Contains(fixture.SchemaDir, *run) {
+		checked++
+		errs := fixture.CheckSchemas(*dir, *update)
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "FAIL %s: %v\n", fixture.SchemaDir, err)
+		}
+		if len(errs) > 0 {
+			failed++
+		}
+	}
+
+	switch {
+	case failed > 0:
+		fmt.Fprintf(os.Stderr, "%d of %d fixtures failed\n", failed, checked)
+		os.Exit(1)
+	case *update:
+		fmt.Printf("updated %d fixtures\n", checked)
+	default:
+		fmt.Printf("ok, %d fixtures\n", checked)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/api"
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/pkpass"
+	"bugsbyte/flight-info/scan"
+)
+
+// ----------------------
+// CLI: SUBCOMMANDS
+// ----------------------
+
+// Exit codes of the parse subcommands.
+const (
+	exitOK     = 0
+	exitFailed = 1 // unreadable input or parse error
+	exitUsage  = 2
+	exitStrict = 3 // parsed, but -strict found a problem; the pass is still printed
+)
+
+const cliUsage = `Usage:
+  flightinfo [serve]                          run the HTTP and gRPC servers (default)
+  flightinfo parse-barcode [flags] [TEXT|-]   parse BCBP barcode text
+  flightinfo parse-pkpass  [flags] [FILE|-]   parse an Apple Wallet .pkpass file
+  flightinfo parse-image   [flags] [FILE|-]   decode and parse a barcode image
+  flightinfo corpus promote [-to DIR] [-force] CAPTURE...
+                                              turn CAPTURE_DIR files into golden fixtures
+  flightinfo import [import flags] PATH       store the passes in a directory or zip
+
+Without an argument, or with "-", input is read from stdin. The pass is
+printed as UnifiedBoardingPass JSON.
+
+Flags:
+  -enrich   add airline names and codeshare details
+  -redact   mask the passenger name, PNR and other PII
+  -strict   exit 3 if the pass has warnings or lacks flight, airports or date
+  -lenient  accept test-environment barcodes (NUL padding, lowercase format code)
+  -pretty   indent the JSON output
+  -decode-profile NAME
+            parse-image: how the image is read (generic, kiosk_aztec, print)
+
+Corpus flags:
+  -to DIR   golden corpus to write to (default testdata/golden)
+  -force    overwrite fixtures that already exist
+
+Import flags:
+  -force      replace stored passes, and import files imported before again
+  -workers N  files parsed at once (default IMPORT_WORKERS)
+  -json       print the report as JSON
+`
+
+// runCommand dispatches on the first argument and returns the exit code.
+func runCommand(args []string) int {
+	cmd := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	switch cmd {
+	case "serve":
+		fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+		fs.Usage = func() { fmt.Fprint(os.Stderr, cliUsage) }
+		if err := fs.Parse(args); err != nil {
+			return flagExit(err)
+		}
+		api.Serve()
+		return exitOK
+	case "parse-barcode", "parse-pkpass", "parse-image":
+		return runParse(cmd, args, os.Stdin, os.Stdout, os.Stderr)
+	case "corpus":
+		return runCorpus(args, os.Stdout, os.Stderr)
+	case "import":
+		return runImport(args, os.Stdout, os.Stderr)
+	case "help":
+		fmt.Print(cliUsage)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "flightinfo: unknown command %q\n\n%s", cmd, cliUsage)
+		return exitUsage
+	}
+}
+
+// runParse parses one input the way the matching /parse endpoint would,
+// without persistence, webhooks or the cache: those belong to the server.
+func runParse(cmd string, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, cliUsage) }
+	enrich := fs.Bool("enrich", false, "")
+	redact := fs.Bool("redact", false, "")
+	strict := fs.Bool("strict", false, "")
+	lenient := fs.Bool("lenient", false, "")
+	pretty := fs.Bool("pretty", false, "")
+	decodeProfile := fs.String("decode-profile", scan.DefaultDecodeProfile, "")
+	if err := fs.Parse(args); err != nil {
+		return flagExit(err)
+	}
+	prof, ok := scan.LookupDecodeProfile(*decodeProfile)
+	if !ok {
+		fmt.Fprintf(stderr, "flightinfo %s: -decode-profile must be one of %s\n", cmd, strings.Join(scan.DecodeProfiles(), ", "))
+		return exitUsage
+	}
+	if fs.NArg() > 1 {
+		fmt.Fprintf(stderr, "flightinfo %s: expected at most one argument, got %d\n", cmd, fs.NArg())
+		return exitUsage
+	}
+	arg := fs.Arg(0)
+
+	fail := func(err error) int {
+		fmt.Fprintf(stderr, "flightinfo %s: %v\n", cmd, err)
+		return exitFailed
+	}
+
+	var (
+		pass *bcbp.UnifiedBoardingPass
+		err  error
+	)
+	parseBarcode := bcbp.Parse
+	if *lenient {
+		parseBarcode = func(raw string) (*bcbp.UnifiedBoardingPass, error) { return bcbp.ParseLenientAt(raw, time.Now()) }
+	}
+	switch cmd {
+	case "parse-barcode":
+		text := arg
+		if arg == "" || arg == "-" {
+			b, err := io.ReadAll(stdin)
+			if err != nil {
+				return fail(err)
+			}
+			// Keep trailing spaces, which are part of the fixed-width format.
+			text = strings.TrimRight(string(b), "\r\n")
+		}
+		if pass, err = parseBarcode(text); err != nil {
+			return fail(fmt.Errorf("error parsing barcode: %w", err))
+		}
+	case "parse-pkpass":
+		data, err := readInput(arg, stdin, 0)
+		if err != nil {
+			return fail(err)
+		}
+		if pass, err = pkpass.Parse(data); err != nil {
+			return fail(fmt.Errorf("error parsing pkpass: %w", err))
+		}
+	case "parse-image":
+		data, err := readInput(arg, stdin, scan.MaxImageBytes)
+		if err != nil {
+			return fail(err)
+		}
+		res, err := scan.DecodeWith(context.Background(), data, prof)
+		if err != nil {
+			return fail(fmt.Errorf("error decoding image: %w", err))
+		}
+		if pass, err = parseBarcode(res.Text); err != nil {
+			return fail(fmt.Errorf("error parsing barcode: %w", err))
+		}
+		pass.RawData["barcode_format"] = res.Format
+		if res.WalletScreenshot {
+			pass.Source = bcbp.SourceWalletScreenshot
+		}
+	}
+
+	q := url.Values{}
+	if *enrich {
+		q.Set("enrich", "true")
+	}
+	api.EnrichPass(context.Background(), pass, q)
+	if *redact {
+		api.RedactPass(pass)
+	}
+
+	enc := json.NewEncoder(stdout)
+	if *pretty {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(pass); err != nil {
+		return fail(err)
+	}
+
+	if *strict {
+		if problems := strictProblems(pass); len(problems) > 0 {
+			for _, p := range problems {
+				fmt.Fprintf(stderr, "flightinfo %s: strict: %s\n", cmd, p)
+			}
+			return exitStrict
+		}
+	}
+	return exitOK
+}
+
+// flagExit maps a flag parsing error to an exit code; -h is not an error.
+func flagExit(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitUsage
+}
+
+// readInput reads the named file, or stdin for "" and "-". A positive limit
+// rejects larger inputs.
+func readInput(name string, stdin io.Reader, limit int64) ([]byte, error) {
+	r := stdin
+	if name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	if limit > 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(data)) > limit {
+		return nil, errors.New("input too large")
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty input")
+	}
+	return data, nil
+}
+
+// strictProblems lists what makes a pass unusable for scripts that expect
+// a complete flight: warnings and missing core fields.
+func strictProblems(p *bcbp.UnifiedBoardingPass) []string {
+	problems := append([]string(nil), p.Warnings...)
+	for _, f := range []struct{ name, value string }{
+		{"flight_number", p.FlightNumber},
+		{"departure_airport", p.Departure},
+		{"arrival_airport", p.Arrival},
+		{"date_iso", p.DateISO},
+	} {
+		if strings.TrimSpace(f.value) == "" {
+			problems = append(problems, f.name+" is missing")
+		}
+	}
+	return problems
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunParse(t *testing.T) {
+	barcode := readFile(t, "../../testdata/golden/bcbp/ac-yul-fra-mandatory.bcbp")
+	warned := readFile(t, "../../testdata/golden/bcbp/dl-atl-jfk-bad-dob.bcbp")
+	selftest := "../../api/data/selftest/"
+
+	tests := []struct {
+		name   string
+		cmd    string
+		args   []string
+		stdin  string
+		code   int
+		pnr    string // of the printed pass; "" when none is printed
+		stderr string
+	}{
+		{name: "barcode argument", cmd: "parse-barcode", args: []string{barcode}, pnr: "ABC123"},
+		{name: "barcode stdin", cmd: "parse-barcode", stdin: barcode + "\n", pnr: "ABC123"},
+		{name: "barcode dash", cmd: "parse-barcode", args: []string{"-redact", "-"}, stdin: barcode, pnr: "****23"},
+		{name: "bad barcode", cmd: "parse-barcode", args: []string{"M1NOT A PASS"}, code: exitFailed, stderr: "error parsing barcode"},
+		{name: "strict clean", cmd: "parse-barcode", args: []string{"-strict", barcode}, pnr: "ABC123"},
+		{name: "strict warnings", cmd: "parse-barcode", args: []string{"-strict", warned}, code: exitStrict, pnr: "GQ4TZB", stderr: "strict: "},
+		{name: "two arguments", cmd: "parse-barcode", args: []string{barcode, barcode}, code: exitUsage, stderr: "expected at most one argument"},
+		{name: "unknown flag", cmd: "parse-barcode", args: []string{"-nope"}, code: exitUsage, stderr: "Usage:"},
+		{name: "help", cmd: "parse-barcode", args: []string{"-h"}, code: exitOK, stderr: "Usage:"},
+		{name: "pkpass", cmd: "parse-pkpass", args: []string{selftest + "ac-yul-fra.pkpass"}, pnr: "ABC123"},
+		{name: "pkpass missing", cmd: "parse-pkpass", args: []string{filepath.Join(t.TempDir(), "none.pkpass")}, code: exitFailed, stderr: "no such file"},
+		{name: "pkpass empty stdin", cmd: "parse-pkpass", code: exitFailed, stderr: "empty input"},
+		{name: "image", cmd: "parse-image", args: []string{selftest + "ac-aztec.png"}, pnr: "ABC123"},
+		{name: "image bad profile", cmd: "parse-image", args: []string{"-decode-profile", "nope", selftest + "ac-aztec.png"}, code: exitUsage, stderr: "-decode-profile must be one of"},
+		{name: "image not an image", cmd: "parse-image", args: []string{selftest + "ac-yul-fra.bcbp"}, code: exitFailed, stderr: "error decoding image"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runParse(tt.cmd, tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if code != tt.code {
+				t.Errorf("exit %d, want %d; stderr: %s", code, tt.code, stderr.String())
+			}
+			if !strings.Contains(stderr.String(), tt.stderr) {
+				t.Errorf("stderr %q lacks %q", stderr.String(), tt.stderr)
+			}
+			if tt.pnr == "" {
+				if stdout.Len() > 0 {
+					t.Errorf("printed %s", stdout.String())
+				}
+				return
+			}
+			var pass struct {
+				PNR string `json:"pnr"`
+			}
+			if err := json.Unmarshal(stdout.Bytes(), &pass); err != nil {
+				t.Fatalf("%v: %s", err, stdout.String())
+			}
+			if pass.PNR != tt.pnr {
+				t.Errorf("pnr %q, want %q", pass.PNR, tt.pnr)
+			}
+		})
+	}
+}
+
+func TestRunCommandUnknown(t *testing.T) {
+	if code := runCommand([]string{"nope"}); code != exitUsage {
+		t.Errorf("exit %d, want %d", code, exitUsage)
+	}
+	if code := runCommand([]string{"serve", "-nope"}); code != exitUsage {
+		t.Errorf("serve -nope: exit %d, want %d", code, exitUsage)
+	}
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bugsbyte/flight-info/api"
+	"bugsbyte/flight-info/internal/fixture"
+)
+
+// ----------------------
+// CLI: CORPUS
+// ----------------------
+
+// `corpus promote` turns requests captured with CAPTURE_DIR into golden
+// fixtures: the masked barcode text (also for images, whose pixels are not
+// captured) becomes a .bcbp, the masked pass.json a .pass.json, and the
+// expectation is generated the way `make golden-update` would.
+// Review both before committing them.
+
+func runCorpus(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "promote" {
+		fmt.Fprint(stderr, cliUsage)
+		return exitUsage
+	}
+	fs := flag.NewFlagSet("corpus promote", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, cliUsage) }
+	to := fs.String("to", filepath.Join("testdata", "golden"), "")
+	force := fs.Bool("force", false, "")
+	if err := fs.Parse(args[1:]); err != nil {
+		return flagExit(err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	files, err := captureFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "flightinfo corpus: %v\n", err)
+		return exitFailed
+	}
+	code := exitOK
+	for _, f := range files {
+		path, err := promoteCapture(f, *to, *force)
+		switch {
+		case errors.Is(err, errNothingToPromote), errors.Is(err, os.ErrExist):
+			fmt.Fprintf(stderr, "skip %s: %v\n", f, err)
+		case err != nil:
+			fmt.Fprintf(stderr, "flightinfo corpus: %s: %v\n", f, err)
+			code = exitFailed
+		default:
+			fmt.Fprintf(stdout, "%s -> %s\n", f, path)
+		}
+	}
+	return code
+}
+
+var errNothingToPromote = errors.New("no barcode text or pass.json captured")
+
+// captureFiles expands directories to the .json files under them.
+func captureFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		err := filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if path == arg || strings.HasSuffix(path, ".json") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// promoteCapture writes the fixture and expectation for one capture file
+// under dir and returns the input's path. Existing fixtures are kept
+// unless force is set.
+func promoteCapture(file, dir string, force bool) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	var c api.Capture
+	if err := json.Unmarshal(data, &c); err != nil {
+		return "", fmt.Errorf("not a capture: %w", err)
+	}
+
+	name := "captured-" + strings.TrimSuffix(filepath.Base(file), ".json")
+	fc := fixture.Case{Name: name}
+	var input []byte
+	switch {
+	case c.PassJSON != nil:
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, c.PassJSON, "", "  "); err != nil {
+			return "", err
+		}
+		buf.WriteByte('\n')
+		input = buf.Bytes()
+		fc.Kind, fc.Path = fixture.KindPassJSON, filepath.Join(dir, "pkpass", name+".pass.json")
+		if fc.Input, err = fixture.PkPass(input); err != nil {
+			return "", err
+		}
+	case c.Barcode != "":
+		input = []byte(c.Barcode)
+		fc.Kind, fc.Path, fc.Input = fixture.KindBCBP, filepath.Join(dir, "bcbp", name+".bcbp"), input
+	default:
+		return "", errNothingToPromote
+	}
+
+	if !force {
+		if _, err := os.Stat(fc.Path); err == nil {
+			return "", fmt.Errorf("%s: %w", fc.Path, os.ErrExist)
+		}
+	}
+	want, err := fc.Got()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(fc.Path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(fc.Path, input, 0o644); err != nil {
+		return "", err
+	}
+	return fc.Path, os.WriteFile(fc.WantPath(), want, 0o644)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"bugsbyte/flight-info/api"
+)
+
+// ----------------------
+// CLI: IMPORT
+// ----------------------
+
+// `import` loads a folder of old passes into the store at SQLITE_PATH or
+// DATABASE_URL (see api.Import). Files that fail are listed and don't stop the run, which
+// can be interrupted and run again: what was imported is skipped.
+
+func runImport(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, cliUsage) }
+	force := fs.Bool("force", false, "")
+	workers := fs.Int("workers", 0, "")
+	asJSON := fs.Bool("json", false, "")
+	if err := fs.Parse(args); err != nil {
+		return flagExit(err)
+	}
+	if fs.NArg() != 1 || *workers < 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := api.Import(ctx, fs.Arg(0), api.ImportOptions{Force: *force, Workers: *workers})
+	if err != nil {
+		fmt.Fprintf(stderr, "flightinfo import: %v\n", err)
+		return exitFailed
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		for _, it := range report.Items {
+			name := it.File
+			if it.Line > 0 {
+				name = fmt.Sprintf("%s:%d", it.File, it.Line)
+			}
+			switch it.Status {
+			case api.ImportSkipped:
+				fmt.Fprintf(stderr, "skip %s: %s\n", name, it.Reason)
+			case api.ImportFailed:
+				fmt.Fprintf(stderr, "fail %s: %s\n", name, it.Reason)
+			default:
+				fmt.Fprintf(stdout, "%s %s -> %s\n", it.Status, name, it.PassID)
+			}
+		}
+		fmt.Fprintf(stdout, "imported %d, updated %d, skipped %d, failed %d\n",
+			report.Imported, report.Updated, report.Skipped, report.Failed)
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(stderr, "flightinfo import: interrupted; run again to import the rest")
+		return exitFailed
+	}
+	if report.Failed > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
@@ -0,0 +1,9 @@
+// Command server runs the flight info HTTP and gRPC servers, or parses a
+// single pass from the command line; see cliUsage.
+package main
+
+import "os"
+
+func main() {
+	os.Exit(runCommand(os.Args[1:]))
+}
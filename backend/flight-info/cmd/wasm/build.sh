@@ -0,0 +1,9 @@
+#!/bin/sh
+# Builds flightinfo.wasm and copies the matching wasm_exec.js next to it.
+# Usage: ./build.sh [OUTDIR]   (default: dist)
+set -e
+cd "$(dirname "$0")"
+out=${1:-dist}
+mkdir -p "$out"
+GOOS=js GOARCH=wasm go build -trimpath -ldflags="-s -w" -o "$out/flightinfo.wasm" .
+cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" loader.js "$out/"
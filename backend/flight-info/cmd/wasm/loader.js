@@ -0,0 +1,23 @@
+// Loads flightinfo.wasm in the browser and resolves once the global
+// flightinfo object is ready. Include the wasm_exec.js shipped with the Go
+// toolchain first (build.sh copies both files into dist/):
+//
+//   <script src="wasm_exec.js"></script>
+//   <script src="loader.js"></script>
+//   <script>
+//     loadFlightInfo().then((fi) => {
+//       const pass = fi.parseBarcode("M1SILVA/JOAO          EXYZ987 LISFRATP 0576 300Y012C0001 100");
+//       if (pass.error) throw new Error(pass.error);
+//       console.log(pass.flight_number, pass.departure_airport, pass.arrival_airport);
+//     });
+//
+//     // .pkpass files from an <input type="file">:
+//     // fi.parsePKPass(new Uint8Array(await file.arrayBuffer()))
+//   </script>
+async function loadFlightInfo(url = "flightinfo.wasm") {
+  if (globalThis.flightinfo) return globalThis.flightinfo;
+  const go = new Go();
+  const { instance } = await WebAssembly.instantiateStreaming(fetch(url), go.importObject);
+  go.run(instance); // returns when the Go program exits, which it never does
+  return globalThis.flightinfo;
+}
@@ -0,0 +1,85 @@
+//go:build js && wasm
+
+// Command wasm builds the barcode and .pkpass parsers for the browser. It
+// registers a global flightinfo object with two functions:
+//
+//	flightinfo.parseBarcode(text, lang)   // string     -> UnifiedBoardingPass
+//	flightinfo.parsePKPass(bytes, lang)   // Uint8Array -> UnifiedBoardingPass
+//
+// Both return a plain object with the same fields as the HTTP API's JSON
+// with ?enrich=true: airline, airport and city names are filled in from
+// the embedded datasets, cities in lang ("en" when left out). An input
+// that can't be parsed gives {error: "..."}. Image decoding, flight
+// status and local times stay server-only. See loader.js for how to load
+// the module.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"syscall/js"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/pkpass"
+)
+
+func main() {
+	js.Global().Set("flightinfo", js.ValueOf(map[string]any{
+		"parseBarcode": js.FuncOf(parseBarcode),
+		"parsePKPass":  js.FuncOf(parsePKPass),
+	}))
+	// Keep the Go runtime alive so the callbacks stay callable.
+	select {}
+}
+
+// parseBarcode implements flightinfo.parseBarcode(text).
+func parseBarcode(_ js.Value, args []js.Value) any {
+	if len(args) < 1 || args[0].Type() != js.TypeString {
+		return errorValue("parseBarcode expects the barcode text as a string")
+	}
+	pass, err := bcbp.Parse(args[0].String())
+	if err != nil {
+		return errorValue(fmt.Sprintf("Error parsing barcode: %v", err))
+	}
+	return passValue(pass, langArg(args))
+}
+
+// parsePKPass implements flightinfo.parsePKPass(bytes).
+func parsePKPass(_ js.Value, args []js.Value) any {
+	if len(args) < 1 || !args[0].InstanceOf(js.Global().Get("Uint8Array")) {
+		return errorValue("parsePKPass expects the .pkpass file as a Uint8Array")
+	}
+	data := make([]byte, args[0].Length())
+	js.CopyBytesToGo(data, args[0])
+	pass, err := pkpass.Parse(data)
+	if err != nil {
+		return errorValue(fmt.Sprintf("Error parsing pkpass: %v", err))
+	}
+	return passValue(pass, langArg(args))
+}
+
+// langArg is the optional language argument after the input, "en" when
+// it is missing or not a string.
+func langArg(args []js.Value) string {
+	if len(args) < 2 || args[1].Type() != js.TypeString || args[1].String() == "" {
+		return "en"
+	}
+	return args[1].String()
+}
+
+// passValue names the pass's airlines and airports, cities in lang, and
+// converts it to a JS object through its JSON encoding, so field names
+// and omitted fields match the HTTP responses exactly.
+func passValue(pass *bcbp.UnifiedBoardingPass, lang string) any {
+	bcbp.NameCarriers(pass)
+	bcbp.NameAirports(pass, lang)
+	body, err := json.Marshal(pass)
+	if err != nil {
+		return errorValue(fmt.Sprintf("Error encoding pass: %v", err))
+	}
+	return js.Global().Get("JSON").Call("parse", string(body))
+}
+
+func errorValue(msg string) any {
+	return map[string]any{"error": msg}
+}
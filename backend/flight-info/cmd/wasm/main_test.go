@@ -0,0 +1,62 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"os"
+	"syscall/js"
+	"testing"
+)
+
+// TestNames parses through the exported functions, as a web client does,
+// and checks the airline, airport and city names the datasets give. Run
+// with the Go toolchain's wasm runner on PATH (needs Node.js):
+//
+//	PATH="$(go env GOROOT)/lib/wasm:$PATH" GOOS=js GOARCH=wasm go test ./cmd/wasm
+func TestNames(t *testing.T) {
+	const barcode = "M1SILVA/JOAO          EXYZ987 LISFRATP 0576 300Y012C0001 100"
+	pkpass, err := os.ReadFile("../../testdata/bench/boarding.pkpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bytes := js.Global().Get("Uint8Array").New(len(pkpass))
+	js.CopyBytesToJS(bytes, pkpass)
+
+	tests := []struct {
+		name string
+		call func() any
+		want map[string]string
+	}{
+		{"barcode", func() any { return parseBarcode(js.Undefined(), []js.Value{js.ValueOf(barcode)}) }, map[string]string{
+			"carrier_name":           "TAP Air Portugal",
+			"departure_airport_name": "Humberto Delgado Airport",
+			"departure_city":         "Lisbon",
+			"arrival_airport_name":   "Frankfurt Airport",
+			"arrival_city":           "Frankfurt",
+		}},
+		{"barcode in pt", func() any {
+			return parseBarcode(js.Undefined(), []js.Value{js.ValueOf(barcode), js.ValueOf("pt")})
+		}, map[string]string{
+			"departure_airport_name": "Humberto Delgado Airport",
+			"departure_city":         "Lisboa",
+		}},
+		{"pkpass", func() any { return parsePKPass(js.Undefined(), []js.Value{bytes}) }, map[string]string{
+			"departure_airport_name": "Montréal-Trudeau International Airport",
+			"departure_city":         "Montreal",
+			"arrival_airport_name":   "Frankfurt Airport",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := js.ValueOf(tt.call())
+			if e := v.Get("error"); !e.IsUndefined() {
+				t.Fatalf("error: %s", e.String())
+			}
+			for field, want := range tt.want {
+				if got := v.Get(field); got.Type() != js.TypeString || got.String() != want {
+					t.Errorf("%s = %v, want %q", field, got, want)
+				}
+			}
+		})
+	}
+}
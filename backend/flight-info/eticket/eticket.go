@@ -0,0 +1,81 @@
+// Package eticket reads IATA electronic ticket numbers: the 13 digits an
+// itinerary receipt prints, often under a Code 128 barcode that agents
+// scan instead of the boarding pass's.
+package eticket
+
+import (
+	"strconv"
+	"strings"
+
+	"bugsbyte/flight-info/internal/refdata"
+)
+
+// DocumentType is Receipt.DocumentType, which tells a receipt response
+// apart from a boarding pass.
+const DocumentType = "eticket_receipt"
+
+// Receipt is a decoded ticket number.
+type Receipt struct {
+	DocumentType  string `json:"document_type"`
+	TicketNumber  string `json:"ticket_number"`  // the 13 digits, without the check digit
+	AirlinePrefix string `json:"airline_prefix"` // IATA accounting code of the issuing airline
+	// Carrier and CarrierName are the airline with that prefix, when the
+	// airline dataset has it.
+	Carrier        string `json:"carrier,omitempty"`
+	CarrierName    string `json:"carrier_name,omitempty"`
+	DocumentNumber string `json:"document_number"` // the 10 digits after the prefix
+	// CheckDigit is set when the number came with one.
+	CheckDigit string `json:"check_digit,omitempty"`
+	// BarcodeFormat is the symbology, when read from an image (usually
+	// CODE_128).
+	BarcodeFormat string   `json:"barcode_format,omitempty"`
+	Warnings      []string `json:"warnings,omitempty"`
+}
+
+// Parse reads raw as a ticket number: 13 digits, or 14 with the check
+// digit, after trimming spaces and line endings. A dash or space may
+// follow the prefix and precede the check digit, as printed
+// ("047-2123456789 3"). Anything else, a bag tag license plate or a BCBP
+// string included, is left to the other parsers and ok is false, and so
+// is a 14th digit that isn't the check digit: that is more likely a
+// GS1 product number than a ticket.
+func Parse(raw string) (r *Receipt, ok bool) {
+	s := strings.TrimSpace(raw)
+	if len(s) > 3 && (s[3] == '-' || s[3] == ' ') {
+		s = s[:3] + s[4:]
+	}
+	if len(s) == 15 && (s[13] == '-' || s[13] == ' ') {
+		s = s[:13] + s[14:]
+	}
+	if (len(s) != 13 && len(s) != 14) || strings.Trim(s, "0123456789") != "" {
+		return nil, false
+	}
+	r = &Receipt{
+		DocumentType:   DocumentType,
+		TicketNumber:   s[:13],
+		AirlinePrefix:  s[:3],
+		DocumentNumber: s[3:13],
+	}
+	if len(s) == 14 {
+		if s[13:] != CheckDigit(r.DocumentNumber) {
+			return nil, false
+		}
+		r.CheckDigit = s[13:]
+	}
+	if a, ok := refdata.AirlineByPrefix(r.AirlinePrefix); ok {
+		r.Carrier, r.CarrierName = a.IATA, a.Name
+	} else {
+		r.Warnings = append(r.Warnings, "airline prefix "+r.AirlinePrefix+" not in airline dataset: carrier left empty")
+	}
+	return r, true
+}
+
+// CheckDigit is the IATA check digit of a 10-digit document number: the
+// number modulo 7.
+func CheckDigit(number string) string {
+	n, err := strconv.ParseUint(number, 10, 64)
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatUint(n%7, 10)
+}
@@ -0,0 +1,243 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: flightinfo.proto
+
+package flightinfopb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	FlightInfo_ParseBarcode_FullMethodName      = "/flightinfo.v1.FlightInfo/ParseBarcode"
+	FlightInfo_ParseBarcodeImage_FullMethodName = "/flightinfo.v1.FlightInfo/ParseBarcodeImage"
+	FlightInfo_ParsePkPass_FullMethodName       = "/flightinfo.v1.FlightInfo/ParsePkPass"
+	FlightInfo_ScanFrames_FullMethodName        = "/flightinfo.v1.FlightInfo/ScanFrames"
+)
+
+// FlightInfoClient is the client API for FlightInfo service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// FlightInfo exposes the same parsers as the HTTP API. Errors use the
+// standard gRPC codes: INVALID_ARGUMENT where HTTP answers 400,
+// RESOURCE_EXHAUSTED where it answers 429.
+type FlightInfoClient interface {
+	ParseBarcode(ctx context.Context, in *ParseBarcodeRequest, opts ...grpc.CallOption) (*BoardingPass, error)
+	ParseBarcodeImage(ctx context.Context, in *ParseBarcodeImageRequest, opts ...grpc.CallOption) (*BoardingPass, error)
+	ParsePkPass(ctx context.Context, in *ParsePkPassRequest, opts ...grpc.CallOption) (*BoardingPass, error)
+	// ScanFrames decodes camera frames as they arrive and returns as soon as
+	// one holds a parseable boarding pass barcode. Frames sent after that are
+	// discarded. Ending the stream without a match yields NOT_FOUND.
+	ScanFrames(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImageFrame, ScanResult], error)
+}
+
+type flightInfoClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFlightInfoClient(cc grpc.ClientConnInterface) FlightInfoClient {
+	return &flightInfoClient{cc}
+}
+
+func (c *flightInfoClient) ParseBarcode(ctx context.Context, in *ParseBarcodeRequest, opts ...grpc.CallOption) (*BoardingPass, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BoardingPass)
+	err := c.cc.Invoke(ctx, FlightInfo_ParseBarcode_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *flightInfoClient) ParseBarcodeImage(ctx context.Context, in *ParseBarcodeImageRequest, opts ...grpc.CallOption) (*BoardingPass, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BoardingPass)
+	err := c.cc.Invoke(ctx, FlightInfo_ParseBarcodeImage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *flightInfoClient) ParsePkPass(ctx context.Context, in *ParsePkPassRequest, opts ...grpc.CallOption) (*BoardingPass, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BoardingPass)
+	err := c.cc.Invoke(ctx, FlightInfo_ParsePkPass_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *flightInfoClient) ScanFrames(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImageFrame, ScanResult], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FlightInfo_ServiceDesc.Streams[0], FlightInfo_ScanFrames_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ImageFrame, ScanResult]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FlightInfo_ScanFramesClient = grpc.ClientStreamingClient[ImageFrame, ScanResult]
+
+// FlightInfoServer is the server API for FlightInfo service.
+// All implementations must embed UnimplementedFlightInfoServer
+// for forward compatibility.
+//
+// FlightInfo exposes the same parsers as the HTTP API. Errors use the
+// standard gRPC codes: INVALID_ARGUMENT where HTTP answers 400,
+// RESOURCE_EXHAUSTED where it answers 429.
+type FlightInfoServer interface {
+	ParseBarcode(context.Context, *ParseBarcodeRequest) (*BoardingPass, error)
+	ParseBarcodeImage(context.Context, *ParseBarcodeImageRequest) (*BoardingPass, error)
+	ParsePkPass(context.Context, *ParsePkPassRequest) (*BoardingPass, error)
+	// ScanFrames decodes camera frames as they arrive and returns as soon as
+	// one holds a parseable boarding pass barcode. Frames sent after that are
+	// discarded. Ending the stream without a match yields NOT_FOUND.
+	ScanFrames(grpc.ClientStreamingServer[ImageFrame, ScanResult]) error
+	mustEmbedUnimplementedFlightInfoServer()
+}
+
+// UnimplementedFlightInfoServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFlightInfoServer struct{}
+
+func (UnimplementedFlightInfoServer) ParseBarcode(context.Context, *ParseBarcodeRequest) (*BoardingPass, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ParseBarcode not implemented")
+}
+func (UnimplementedFlightInfoServer) ParseBarcodeImage(context.Context, *ParseBarcodeImageRequest) (*BoardingPass, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ParseBarcodeImage not implemented")
+}
+func (UnimplementedFlightInfoServer) ParsePkPass(context.Context, *ParsePkPassRequest) (*BoardingPass, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ParsePkPass not implemented")
+}
+func (UnimplementedFlightInfoServer) ScanFrames(grpc.ClientStreamingServer[ImageFrame, ScanResult]) error {
+	return status.Errorf(codes.Unimplemented, "method ScanFrames not implemented")
+}
+func (UnimplementedFlightInfoServer) mustEmbedUnimplementedFlightInfoServer() {}
+func (UnimplementedFlightInfoServer) testEmbeddedByValue()                    {}
+
+// UnsafeFlightInfoServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FlightInfoServer will
+// result in compilation errors.
+type UnsafeFlightInfoServer interface {
+	mustEmbedUnimplementedFlightInfoServer()
+}
+
+func RegisterFlightInfoServer(s grpc.ServiceRegistrar, srv FlightInfoServer) {
+	// If the following call pancis, it indicates UnimplementedFlightInfoServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&FlightInfo_ServiceDesc, srv)
+}
+
+func _FlightInfo_ParseBarcode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ParseBarcodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FlightInfoServer).ParseBarcode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FlightInfo_ParseBarcode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FlightInfoServer).ParseBarcode(ctx, req.(*ParseBarcodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FlightInfo_ParseBarcodeImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ParseBarcodeImageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FlightInfoServer).ParseBarcodeImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FlightInfo_ParseBarcodeImage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FlightInfoServer).ParseBarcodeImage(ctx, req.(*ParseBarcodeImageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FlightInfo_ParsePkPass_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ParsePkPassRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FlightInfoServer).ParsePkPass(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FlightInfo_ParsePkPass_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FlightInfoServer).ParsePkPass(ctx, req.(*ParsePkPassRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FlightInfo_ScanFrames_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FlightInfoServer).ScanFrames(&grpc.GenericServerStream[ImageFrame, ScanResult]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FlightInfo_ScanFramesServer = grpc.ClientStreamingServer[ImageFrame, ScanResult]
+
+// FlightInfo_ServiceDesc is the grpc.ServiceDesc for FlightInfo service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FlightInfo_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "flightinfo.v1.FlightInfo",
+	HandlerType: (*FlightInfoServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ParseBarcode",
+			Handler:    _FlightInfo_ParseBarcode_Handler,
+		},
+		{
+			MethodName: "ParseBarcodeImage",
+			Handler:    _FlightInfo_ParseBarcodeImage_Handler,
+		},
+		{
+			MethodName: "ParsePkPass",
+			Handler:    _FlightInfo_ParsePkPass_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ScanFrames",
+			Handler:       _FlightInfo_ScanFrames_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "flightinfo.proto",
+}
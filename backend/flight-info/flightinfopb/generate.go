@@ -0,0 +1,4 @@
+// Package flightinfopb holds the gRPC API definition and its generated code.
+package flightinfopb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative flightinfo.proto
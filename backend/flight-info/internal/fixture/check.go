@@ -0,0 +1,58 @@
+package fixture
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Check parses c and compares the result with its expectation, after
+// checking it against the wire registry (see CheckWire). With update it
+// rewrites the expectation from the result instead.
+func (c Case) Check(update bool) error {
+	got, err := c.Got()
+	if err != nil {
+		return err
+	}
+	if err := c.CheckWire(); err != nil {
+		return err
+	}
+	if update {
+		return os.WriteFile(c.WantPath(), got, 0o644)
+	}
+	want, err := os.ReadFile(c.WantPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no expectation (run with -update): %w", err)
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("differs from %s\n%s", c.WantPath(), Diff(want, got))
+	}
+	return nil
+}
+
+// Diff lists the lines that differ, position by position; the expectations
+// are indented JSON with sorted map keys, so a changed field shows up as
+// one or two lines.
+func Diff(want, got []byte) string {
+	w := strings.Split(string(want), "\n")
+	g := strings.Split(string(got), "\n")
+	var b strings.Builder
+	for i := range max(len(w), len(g)) {
+		var wl, gl string
+		if i < len(w) {
+			wl = w[i]
+		}
+		if i < len(g) {
+			gl = g[i]
+		}
+		if wl != gl {
+			fmt.Fprintf(&b, "  line %d\n    want: %s\n    got:  %s\n", i+1, wl, gl)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,321 @@
+// Package fixture loads the golden-file corpus in testdata/golden, parses
+// each input with the parser its extension names, and normalizes the result
+// so it can be compared with the committed expectation byte for byte.
+package fixture
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bagtag"
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/eticket"
+	"bugsbyte/flight-info/ocr"
+	"bugsbyte/flight-info/pkpass"
+	"bugsbyte/flight-info/profile"
+	"bugsbyte/flight-info/scan"
+)
+
+// Reference is the "today" Julian dates are resolved around, so barcode
+// expectations don't change with the clock.
+var Reference = time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+// WantSuffix is appended to an input's name, minus its extension, to get
+// its expectation: bcbp/ac-yul-fra.bcbp is checked against
+// bcbp/ac-yul-fra.want.json.
+const WantSuffix = ".want.json"
+
+// Input kinds, by file extension.
+const (
+	KindBCBP     = "bcbp"      // .bcbp: barcode text, byte for byte (no trailing newline)
+	KindPassJSON = "pass.json" // .pass.json: a pass.json, zipped into a .pkpass on load
+	KindPkPass   = "pkpass"    // .pkpass: a whole archive
+	KindImage    = "image"     // .png, .jpg, .gif: a barcode image
+	// .barcodes: barcode text, one per line, expected as their
+	// bcbp.Conform report
+	KindBarcodes = "barcodes"
+	// .revisions.json: a JSON array of passes, each an update of the one
+	// before, expected as the bcbp.Diff of each update
+	KindRevisions = "revisions"
+	// .ocr.txt: the text OCR recognized on a pass, one line per line
+	KindOCR = "ocr"
+)
+
+// FormatDir holds the fixtures of the output profiles: an input under
+// format/<profile>/ is expected with that profile applied.
+const FormatDir = "format"
+
+// LenientDir holds barcodes from check-in test environments: inputs under
+// lenient/ are parsed with bcbp.ParseLenientAt.
+const LenientDir = "lenient"
+
+// MapperDir holds passes mapped otherwise than by pkpass.DefaultMapper:
+// an input under mapper/<mapper>/ is parsed with that mapper.
+const MapperDir = "mapper"
+
+// Case is one input of the corpus.
+type Case struct {
+	Name   string // path relative to the corpus, without the extension
+	Kind   string
+	Path   string
+	Input  []byte // for KindPassJSON, the zipped .pkpass
+	Format string // the profile applied to the pass, for inputs under FormatDir
+	// Lenient is set for inputs under LenientDir.
+	Lenient bool
+	Mapper  pkpass.Mapper // for inputs under MapperDir
+}
+
+// WantPath is where the expectation for c lives.
+func (c Case) WantPath() string {
+	return strings.TrimSuffix(c.Path, inputExt(c.Path)) + WantSuffix
+}
+
+// Load reads every input under dir, sorted by name. Files it doesn't
+// recognize, expectations included, are skipped.
+func Load(dir string) ([]Case, error) {
+	var cases []Case
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		ext := inputExt(path)
+		kind := kindOf(ext)
+		if kind == "" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if kind == KindPassJSON {
+			if data, err = PkPass(data); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		rel, _ := filepath.Rel(dir, path)
+		name := filepath.ToSlash(strings.TrimSuffix(rel, ext))
+		var format string
+		if rest, ok := strings.CutPrefix(name, FormatDir+"/"); ok {
+			format, _, _ = strings.Cut(rest, "/")
+			if _, ok := profile.Lookup(format); !ok {
+				return fmt.Errorf("%s: no profile %q", path, format)
+			}
+		}
+		mapper := pkpass.DefaultMapper
+		if rest, ok := strings.CutPrefix(name, MapperDir+"/"); ok {
+			m, _, _ := strings.Cut(rest, "/")
+			if mapper, err = pkpass.ParseMapper(m); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		cases = append(cases, Case{
+			Name:    name,
+			Kind:    kind,
+			Path:    path,
+			Input:   data,
+			Format:  format,
+			Lenient: strings.HasPrefix(name, LenientDir+"/"),
+			Mapper:  mapper,
+		})
+		return nil
+	})
+	slices.SortFunc(cases, func(a, b Case) int { return strings.Compare(a.Name, b.Name) })
+	return cases, err
+}
+
+func inputExt(path string) string {
+	if strings.HasSuffix(path, WantSuffix) {
+		return WantSuffix
+	}
+	for _, ext := range []string{".pass.json", ".revisions.json", ".ocr.txt"} {
+		if strings.HasSuffix(path, ext) {
+			return ext
+		}
+	}
+	return filepath.Ext(path)
+}
+
+func kindOf(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".bcbp":
+		return KindBCBP
+	case ".pass.json":
+		return KindPassJSON
+	case ".pkpass":
+		return KindPkPass
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return KindImage
+	case ".barcodes":
+		return KindBarcodes
+	case ".revisions.json":
+		return KindRevisions
+	case ".ocr.txt":
+		return KindOCR
+	}
+	return ""
+}
+
+// PkPass wraps a pass.json in a minimal, unsigned .pkpass archive. The
+// archive has no timestamps, so the same pass.json always gives the same
+// bytes (and so the same fallback ID).
+func PkPass(passJSON []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{{"pass.json", passJSON}, {"manifest.json", []byte("{}")}} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Parse runs c through the parser for its kind and the semantic checks,
+// the way the matching /parse endpoint would before enrichment.
+func (c Case) Parse() (*bcbp.UnifiedBoardingPass, error) {
+	p, err := c.parse()
+	if err == nil {
+		bcbp.CheckSemantics(p)
+	}
+	return p, err
+}
+
+func (c Case) parse() (*bcbp.UnifiedBoardingPass, error) {
+	parseBarcode := bcbp.ParseAt
+	if c.Lenient {
+		parseBarcode = bcbp.ParseLenientAt
+	}
+	switch c.Kind {
+	case KindBCBP:
+		return parseBarcode(string(c.Input), Reference)
+	case KindPassJSON, KindPkPass:
+		return pkpass.ParseReaderWith(bytes.NewReader(c.Input), int64(len(c.Input)), Reference, c.Mapper)
+	case KindImage:
+		res, err := scan.DecodeResult(context.Background(), c.Input)
+		if err != nil {
+			return nil, err
+		}
+		p, err := parseBarcode(res.Text, Reference)
+		if err != nil {
+			return nil, err
+		}
+		p.RawData["barcode_format"] = res.Format
+		if res.WalletScreenshot {
+			p.Source = bcbp.SourceWalletScreenshot
+		}
+		return p, nil
+	case KindOCR:
+		return ocr.Parse(strings.Split(strings.TrimSuffix(string(c.Input), "\n"), "\n"), Reference)
+	}
+	return nil, fmt.Errorf("unknown fixture kind %q", c.Kind)
+}
+
+// Normalize is the expectation format: the pass as indented schema version
+// 0 JSON without parsed_at, or {"error": "..."} when parsing failed.
+func Normalize(p *bcbp.UnifiedBoardingPass, parseErr error) ([]byte, error) {
+	var v any
+	if parseErr != nil {
+		v = map[string]string{"error": parseErr.Error()}
+	} else {
+		c := *p
+		c.ParsedAt = ""
+		v = &c
+	}
+	return marshal(v)
+}
+
+func marshal(v any) ([]byte, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// Got parses and normalizes c, with its Format applied. Barcode text and
+// images that hold a bag tag license plate or an e-ticket number give the
+// tag or receipt instead, as the /parse endpoints do. A list of barcodes
+// gives its conformance report, and a list of revisions their changes.
+func (c Case) Got() ([]byte, error) {
+	if c.Kind == KindBarcodes {
+		raws := strings.Split(strings.TrimSuffix(string(c.Input), "\n"), "\n")
+		return marshal(bcbp.Conform(raws, Reference))
+	}
+	if c.Kind == KindRevisions {
+		var passes []*bcbp.UnifiedBoardingPass
+		if err := json.Unmarshal(c.Input, &passes); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Path, err)
+		}
+		changes := [][]bcbp.FieldChange{}
+		for i := 1; i < len(passes); i++ {
+			changes = append(changes, bcbp.Diff(passes[i-1], passes[i]))
+		}
+		return marshal(changes)
+	}
+	if tag, ok := c.BagTag(); ok {
+		return marshal(tag)
+	}
+	if receipt, ok := c.Receipt(); ok {
+		return marshal(receipt)
+	}
+	p, err := c.Parse()
+	if err == nil && c.Format != "" {
+		prof, _ := profile.Lookup(c.Format)
+		prof.Apply(p)
+	}
+	return Normalize(p, err)
+}
+
+// BagTag reads c as a bag tag license plate; ok is false for anything else.
+func (c Case) BagTag() (tag *bagtag.Tag, ok bool) {
+	switch c.Kind {
+	case KindBCBP:
+		return bagtag.Parse(string(c.Input))
+	case KindImage:
+		text, format, err := scan.Decode(c.Input)
+		if err != nil {
+			return nil, false
+		}
+		if tag, ok = bagtag.Parse(text); ok {
+			tag.BarcodeFormat = format
+		}
+		return tag, ok
+	}
+	return nil, false
+}
+
+// Receipt reads c as an e-ticket number; ok is false for anything else.
+func (c Case) Receipt() (receipt *eticket.Receipt, ok bool) {
+	switch c.Kind {
+	case KindBCBP:
+		return eticket.Parse(string(c.Input))
+	case KindImage:
+		text, format, err := scan.Decode(c.Input)
+		if err != nil {
+			return nil, false
+		}
+		if receipt, ok = eticket.Parse(text); ok {
+			receipt.BarcodeFormat = format
+		}
+		return receipt, ok
+	}
+	return nil, false
+}
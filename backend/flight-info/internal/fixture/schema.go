@@ -0,0 +1,156 @@
+package fixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// SchemaDir holds the lock file of each schema version, schema/v<N>.lock.json:
+// the registered fields as they were committed (see bcbp.WireSchemas).
+const SchemaDir = "schema"
+
+// LockPath is where the lock file of version lives under dir.
+func LockPath(dir string, version int) string {
+	return filepath.Join(dir, SchemaDir, fmt.Sprintf("v%d.lock.json", version))
+}
+
+// CheckSchemas checks the wire registry: a pass with every field set must
+// marshal to exactly the registered fields of each version, and each
+// version must still have every field of its lock file, with the same
+// type. With update, lock files that only gained fields are rewritten;
+// losing or retyping a field fails either way.
+func CheckSchemas(dir string, update bool) []error {
+	if err := bcbp.CheckWireRegistry(); err != nil {
+		return []error{err}
+	}
+	var errs []error
+	for _, s := range bcbp.WireSchemas {
+		full := FullPass()
+		full.SchemaVersion = s.Version
+		b, err := json.Marshal(full)
+		if err == nil {
+			err = s.ValidateExact(b)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("full pass: %w", err))
+		}
+		if err := checkLock(dir, s, update); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func checkLock(dir string, s bcbp.WireSchema, update bool) error {
+	path := LockPath(dir, s.Version)
+	got, err := marshal(s)
+	if err != nil {
+		return err
+	}
+	want, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && update:
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, got, 0o644)
+	case errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("schema version %d: no lock file %s (run with -update)", s.Version, path)
+	case err != nil:
+		return err
+	}
+	var released bcbp.WireSchema
+	if err := json.Unmarshal(want, &released); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := bcbp.WireCompatible(released, s); err != nil {
+		return fmt.Errorf("%w (a version can only gain fields, each named in bcbp.WireChangelog; removing or retyping one takes a new version)", err)
+	}
+	switch {
+	case bytes.Equal(got, want):
+		return nil
+	case update:
+		return os.WriteFile(path, got, 0o644)
+	}
+	return fmt.Errorf("schema version %d gained fields not in %s (run with -update)", s.Version, path)
+}
+
+// CheckWire marshals the pass c parses to every schema version and
+// validates it against the registry. Inputs that don't give a pass pass.
+func (c Case) CheckWire() error {
+	if c.Kind == KindBarcodes || c.Kind == KindRevisions {
+		return nil
+	}
+	if _, ok := c.BagTag(); ok {
+		return nil
+	}
+	if _, ok := c.Receipt(); ok {
+		return nil
+	}
+	p, err := c.Parse()
+	if err != nil {
+		return nil
+	}
+	for _, s := range bcbp.WireSchemas {
+		p.SchemaVersion = s.Version
+		b, err := json.Marshal(p)
+		if err == nil {
+			err = s.Validate(b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FullPass is a pass with every field set, down to one element of each
+// slice and one entry of each map, for CheckSchemas.
+func FullPass() *bcbp.UnifiedBoardingPass {
+	p := &bcbp.UnifiedBoardingPass{}
+	fill(reflect.ValueOf(p).Elem())
+	return p
+}
+
+var rawMessageType = reflect.TypeFor[json.RawMessage]()
+
+func fill(v reflect.Value) {
+	if v.Type() == rawMessageType {
+		v.SetBytes([]byte(`{"key":"value"}`))
+		return
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem())
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fill(v.Index(0))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		k, e := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+		fill(k)
+		fill(e)
+		v.SetMapIndex(k, e)
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i))
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package refdata
+
+import (
+	_ "embed"
+	"encoding/json"
+	"strings"
+)
+
+// ----------------------
+// DATA: EMBEDDED AIRLINE DATASET
+// ----------------------
+
+// Airline is one entry of data/airlines.json.
+type Airline struct {
+	IATA    string `json:"iata"`
+	ICAO    string `json:"icao"`
+	Name    string `json:"name"`
+	Country string `json:"country"`
+	Prefix  string `json:"prefix,omitempty"` // three-digit accounting code, as on tickets and bag tags
+	// OpenSeating is set for airlines that don't assign seats.
+	OpenSeating bool `json:"open_seating,omitempty"`
+}
+
+// AirlinesJSON is the airline dataset as embedded, for ETags.
+//
+//go:embed data/airlines.json
+var AirlinesJSON []byte
+
+// airlineList is the dataset in file order, airlines keyed by both IATA
+// and ICAO code; the two never collide since they differ in length.
+var airlineList, airlines = func() ([]Airline, map[string]Airline) {
+	var list []Airline
+	if err := json.Unmarshal(AirlinesJSON, &list); err != nil {
+		panic("invalid embedded airline dataset: " + err.Error())
+	}
+	m := make(map[string]Airline, 2*len(list))
+	for _, a := range list {
+		m[a.IATA] = a
+		m[a.ICAO] = a
+	}
+	return list, m
+}()
+
+// airlinesByPrefix is keyed by Airline.Prefix, for the airlines that have
+// one in the dataset.
+var airlinesByPrefix = func() map[string]Airline {
+	m := map[string]Airline{}
+	for _, a := range airlineList {
+		if a.Prefix != "" {
+			m[a.Prefix] = a
+		}
+	}
+	return m
+}()
+
+// Airlines is every entry of the dataset, in file order.
+func Airlines() []Airline { return airlineList }
+
+// LookupAirline finds an IATA or ICAO code, in any case and with
+// surrounding space.
+func LookupAirline(code string) (Airline, bool) {
+	a, ok := airlines[strings.ToUpper(strings.TrimSpace(code))]
+	return a, ok
+}
+
+// AirlineByPrefix finds the airline with a three-digit accounting prefix.
+func AirlineByPrefix(prefix string) (Airline, bool) {
+	a, ok := airlinesByPrefix[prefix]
+	return a, ok
+}
+
+// OpenSeating reports whether carrier doesn't assign seats.
+func OpenSeating(carrier string) bool {
+	a, _ := LookupAirline(carrier)
+	return a.OpenSeating
+}
@@ -0,0 +1,99 @@
+// Package refdata holds the embedded airport and airline datasets that the
+// parsers and the api package look codes up in: airport names and time
+// zones, city and station codes, airline names and accounting prefixes.
+package refdata
+
+import (
+	_ "embed"
+	"encoding/json"
+	"strings"
+)
+
+// ----------------------
+// DATA: EMBEDDED AIRPORT DATASET
+// ----------------------
+
+// LocationType is the kind of place an IATA location code names.
+type LocationType string
+
+const (
+	LocationAirport LocationType = "airport"
+	LocationCity    LocationType = "city" // a metropolitan area, all its airports
+	LocationRail    LocationType = "rail" // a railway station
+	LocationBus     LocationType = "bus"  // a bus station
+)
+
+// Airport is one entry of data/airports.json; TZ is an IANA zone name.
+// Besides airports, the dataset has the metropolitan codes of cities with
+// several airports and the railway stations sold as flight legs (see
+// LocationType).
+type Airport struct {
+	Code    string       `json:"code"`
+	Type    LocationType `json:"type"` // "airport" when the dataset leaves it out
+	Name    string       `json:"name"`
+	City    string       `json:"city"`
+	Country string       `json:"country"`
+	TZ      string       `json:"tz,omitempty"` // IANA zone, e.g. "Europe/Lisbon"
+	// Cities is the city's name in other languages, where it isn't City:
+	// {"pt": "Lisboa", "de": "Lissabon"}.
+	Cities map[string]string `json:"cities,omitempty"`
+	// Airports are the airports of a city code's metropolitan area.
+	Airports []string `json:"airports,omitempty"`
+}
+
+// CityIn is the city's name in lang, or City when the dataset has none.
+func (a Airport) CityIn(lang string) string {
+	if c := a.Cities[lang]; c != "" {
+		return c
+	}
+	return a.City
+}
+
+// AirportsJSON is the airport dataset as embedded, for ETags.
+//
+//go:embed data/airports.json
+var AirportsJSON []byte
+
+// airportList is the dataset in file order, airports keyed by IATA code.
+// The dataset is embedded at build time, so a malformed file is a
+// programming error and panics at startup.
+var airportList, airports = func() ([]Airport, map[string]Airport) {
+	var list []Airport
+	if err := json.Unmarshal(AirportsJSON, &list); err != nil {
+		panic("invalid embedded airport dataset: " + err.Error())
+	}
+	m := make(map[string]Airport, len(list))
+	for i, a := range list {
+		switch a.Type {
+		case "":
+			list[i].Type = LocationAirport
+		case LocationAirport, LocationCity, LocationRail, LocationBus:
+		default:
+			panic("invalid embedded airport dataset: " + a.Code + " has type " + string(a.Type))
+		}
+		m[a.Code] = list[i]
+	}
+	return list, m
+}()
+
+// Airports is every entry of the dataset, in file order.
+func Airports() []Airport { return airportList }
+
+// LookupAirport finds code, in any case and with surrounding space.
+func LookupAirport(code string) (Airport, bool) {
+	a, ok := airports[strings.ToUpper(strings.TrimSpace(code))]
+	return a, ok
+}
+
+// IsAirport reports whether code is an airport, not a city or station.
+func IsAirport(code string) bool {
+	a, ok := airports[code]
+	return ok && a.Type == LocationAirport
+}
+
+// LocationTypeOf returns the kind of place code is, "" when it isn't in
+// the dataset.
+func LocationTypeOf(code string) LocationType {
+	a, _ := LookupAirport(code)
+	return a.Type
+}
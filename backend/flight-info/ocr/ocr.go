@@ -0,0 +1,67 @@
+// Package ocr reads a boarding pass from the text printed on it, for images
+// whose barcode won't decode. The text is recognized by an external
+// tesseract binary (see Engine), and Parse turns its lines into a pass with
+// the label matching pkpass uses for pass.json fields. Passes read this way
+// are a best effort: every field is marked as read by OCR, and the pass
+// carries a warning saying so.
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Engine runs a tesseract binary.
+type Engine struct {
+	path string
+}
+
+// Find looks command up in PATH (or takes it as a path, if it has a
+// slash) and checks that it runs.
+func Find(command string) (*Engine, error) {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return nil, err
+	}
+	if out, err := exec.Command(path, "--version").CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s --version: %w: %s", path, err, firstLine(out))
+	}
+	return &Engine{path: path}, nil
+}
+
+// Path is the binary e runs.
+func (e *Engine) Path() string {
+	return e.path
+}
+
+// Lines recognizes the text in img, any format tesseract reads (PNG, JPEG,
+// GIF, WebP and TIFF, depending on how it was built), and returns its
+// non-blank lines, trimmed. Runs of spaces between columns are kept, so
+// callers can tell columns apart. The process is killed when ctx is done.
+func (e *Engine) Lines(ctx context.Context, img []byte) ([]string, error) {
+	cmd := exec.CommandContext(ctx, e.path, "stdin", "stdout", "-c", "preserve_interword_spaces=1")
+	cmd.Stdin = bytes.NewReader(img)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("tesseract: %w: %s", err, firstLine(stderr.Bytes()))
+	}
+	var lines []string
+	for _, l := range strings.Split(stdout.String(), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines, nil
+}
+
+func firstLine(b []byte) string {
+	s, _, _ := strings.Cut(strings.TrimSpace(string(b)), "\n")
+	return s
+}
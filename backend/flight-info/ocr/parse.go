@@ -0,0 +1,301 @@
+package ocr
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/internal/refdata"
+	"bugsbyte/flight-info/pkpass"
+)
+
+// ErrNoFields is returned by Parse for text with neither a flight number
+// nor both airports in it: not a boarding pass, or not one OCR could read.
+var ErrNoFields = errors.New("no flight number or route found in the text")
+
+// Warning is the first warning of every pass Parse returns.
+const Warning = "ocr: no barcode could be read, so every field was read from the printed text and may be wrong; check it against the pass"
+
+// ----------------------
+// LABELS
+// ----------------------
+
+// label is a printed label and the key its value is handed to pkpass's
+// matcher under, chosen for the keyword that matcher looks for.
+type label struct {
+	phrase string
+	key    string
+}
+
+// labels are the labels Parse recognizes, in English and Portuguese,
+// longest first so "BOARDING TIME" isn't read as "BOARDING" and "FLIGHT
+// DATE" isn't read as a flight number.
+var labels = sortedLabels(map[string][]string{
+	"passengerName": {"passenger name", "name of passenger", "passenger", "name", "passageiro", "nome"},
+	"origin":        {"from", "origin", "departing from", "origem"},
+	"destination":   {"to", "destination", "arriving at", "para", "destino"},
+	"pnr":           {"booking reference", "booking ref", "booking code", "booking", "confirmation", "record locator", "pnr", "reserva", "localizador"},
+	"flightNumber":  {"flight number", "flight no", "flight", "voo"},
+	"gate":          {"gate", "porta", "portão"},
+	"seat":          {"seat", "lugar", "assento"},
+	"terminal":      {"terminal"},
+	"checkInDesk":   {"check-in desk", "check-in counter", "desk", "counter", "balcão"},
+	"boardingDoor":  {"boarding door", "door"},
+	"boardingGroup": {"boarding group", "group", "zone", "grupo"},
+	"boardingTime":  {"boarding time", "boarding", "boards", "embarque", "hora de embarque"},
+	"departureTime": {"departure time", "departure", "departs", "partida"},
+	"class":         {"class", "cabin", "classe"},
+	"sequence":      {"sequence", "seq no", "seq"},
+	"date":          {"flight date", "date", "data"},
+})
+
+func sortedLabels(byKey map[string][]string) []label {
+	var ls []label
+	for key, phrases := range byKey {
+		for _, p := range phrases {
+			ls = append(ls, label{p, key})
+		}
+	}
+	slices.SortFunc(ls, func(a, b label) int {
+		if n := len(b.phrase) - len(a.phrase); n != 0 {
+			return n
+		}
+		return strings.Compare(a.phrase, b.phrase)
+	})
+	return ls
+}
+
+// cutLabel reads a label at the start of s, followed by the end of s, a
+// colon or a space, and returns it and what follows, trimmed.
+func cutLabel(s string) (l label, rest string, ok bool) {
+	lower := strings.ToLower(s)
+	for _, l := range labels {
+		after, found := strings.CutPrefix(lower, l.phrase)
+		if !found || (after != "" && !strings.ContainsAny(after[:1], " \t:.")) {
+			continue
+		}
+		rest = s[len(s)-len(after):]
+		return l, strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(rest), ":.")), true
+	}
+	return label{}, "", false
+}
+
+// headerRow reads line as a row of labels only, like a column header.
+func headerRow(line string) ([]label, bool) {
+	var row []label
+	for rest := line; rest != ""; {
+		l, after, ok := cutLabel(rest)
+		if !ok {
+			return nil, false
+		}
+		row, rest = append(row, l), after
+	}
+	return row, len(row) > 0
+}
+
+// valueLine returns lines[i], trimmed, if there is one and it doesn't
+// start with a label.
+func valueLine(lines []string, i int) (string, bool) {
+	if i >= len(lines) {
+		return "", false
+	}
+	line := strings.TrimSpace(lines[i])
+	_, _, labeled := cutLabel(line)
+	return line, !labeled
+}
+
+var columnGap = regexp.MustCompile(`\s{2,}|\t`)
+
+// columns splits line into n values: at wide gaps if there are n cells,
+// else at every space if there are n words.
+func columns(line string, n int) ([]string, bool) {
+	if cells := columnGap.Split(line, -1); len(cells) == n {
+		return cells, true
+	}
+	if words := strings.Fields(line); len(words) == n {
+		return words, true
+	}
+	return nil, false
+}
+
+// ----------------------
+// PARSING
+// ----------------------
+
+// Parse builds a pass from the lines of text recognized on a printed or
+// photographed pass. A value is paired with its label when they share a
+// line ("GATE B12", "Seat: 23A"), when a label stands alone on the line
+// above it, or when a row of labels heads a row of values. Other lines
+// are kept as unlabeled text, which airport codes are still looked for
+// in. Dates are resolved around ref, the year nearest to it when the
+// pass doesn't print one. Every field of the pass is marked as read by
+// OCR, and its first warning is Warning.
+func Parse(lines []string, ref time.Time) (*bcbp.UnifiedBoardingPass, error) {
+	var fields []pkpass.Field
+	var date string
+	add := func(l label, printed, value string) {
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "":
+			return
+		case l.key == "date":
+			if d, ok := parseDate(value, ref); ok && date == "" {
+				date = d
+			}
+			return
+		case l.key == "boardingTime" || l.key == "departureTime":
+			if !clock.MatchString(value) {
+				// A time label before something else ("BOARDING PASS"):
+				// left unlabeled, so "departure" isn't read as an airport.
+				fields = append(fields, pkpass.Field{Value: printed + " " + value})
+				return
+			}
+			value = strings.Replace(value, ".", ":", 1)
+		case l.key == "origin" || l.key == "destination":
+			value = airportIn(value)
+		}
+		fields = append(fields, pkpass.Field{Key: l.key, Label: printed, Value: value})
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if row, ok := headerRow(line); ok {
+			if next, ok := valueLine(lines, i+1); ok {
+				if len(row) == 1 {
+					add(row[0], strings.TrimRight(line, ":. "), next)
+					i++
+					continue
+				}
+				if values, ok := columns(next, len(row)); ok {
+					for j, l := range row {
+						add(l, l.phrase, values[j])
+					}
+					i++
+					continue
+				}
+			}
+			// Labels with no values under them.
+			continue
+		}
+		for _, cell := range columnGap.Split(line, -1) {
+			if l, rest, ok := cutLabel(cell); ok && rest != "" {
+				add(l, cell[:len(l.phrase)], rest)
+			} else {
+				fields = append(fields, pkpass.Field{Value: cell})
+			}
+		}
+	}
+
+	p := pkpass.ParseFields(fields)
+	if p.FlightNumber == "" && (p.Departure == "" || p.Arrival == "") {
+		return nil, ErrNoFields
+	}
+	if date != "" && p.DateISO == "" {
+		p.DateISO = date
+		p.SetFieldSource("date_iso", bcbp.FromOCR)
+	}
+	p.Source = bcbp.SourceOCR
+	MarkFields(p)
+	p.Warnings = append([]string{Warning}, p.Warnings...)
+	bcbp.Stamp(p, []byte(strings.Join(lines, "\n")))
+	return p, nil
+}
+
+// MarkFields marks every field of p in FieldSources as read by OCR,
+// including those derived from others: they are no surer than what they
+// were derived from. Enrichment calls it again for the fields it adds.
+func MarkFields(p *bcbp.UnifiedBoardingPass) {
+	for name := range p.FieldSources {
+		p.FieldSources[name] = bcbp.FromOCR
+	}
+}
+
+var clock = regexp.MustCompile(`^\d{1,2}[:h.]\d{2}(\s*[AaPp][Mm])?$`)
+
+var airportCode = regexp.MustCompile(`\b[A-Z]{3}\b`)
+
+// airportIn returns the airport code in a printed place ("LISBON LIS",
+// "London Heathrow (LHR)"), or v as printed when it holds no code, or
+// more than one, the airport dataset knows.
+func airportIn(v string) string {
+	var code string
+	for _, c := range airportCode.FindAllString(v, -1) {
+		if !refdata.IsAirport(c) {
+			continue
+		}
+		if code != "" && c != code {
+			return v
+		}
+		code = c
+	}
+	if code == "" {
+		return v
+	}
+	return code
+}
+
+// dateLayouts are the date formats printed passes use, with and without
+// the year.
+var dateLayouts = []string{
+	"2006-01-02", "02/01/2006", "02.01.2006",
+	"02 Jan 2006", "02Jan2006", "02 Jan 06", "02Jan06", "Jan 02 2006", "Jan 02, 2006",
+	"02 January 2006", "January 02 2006", "January 02, 2006",
+}
+
+var yearlessLayouts = []string{"02 Jan", "02Jan", "Jan 02", "02 January", "January 02"}
+
+// parseDate reads v as a date, as an ISO date. A date without a year is
+// taken in the year that puts it nearest to ref.
+func parseDate(v string, ref time.Time) (string, bool) {
+	// Passes print months in capitals ("12JUN"), which time.Parse only
+	// reads title-cased.
+	v = strings.Join(strings.Fields(titleCase(strings.TrimRight(v, ".,"))), " ")
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.Format(time.DateOnly), true
+		}
+	}
+	for _, layout := range yearlessLayouts {
+		t, err := time.Parse(layout, v)
+		if err != nil {
+			continue
+		}
+		best := time.Time{}
+		for y := ref.Year() - 1; y <= ref.Year()+1; y++ {
+			c := time.Date(y, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			if c.Day() != t.Day() {
+				continue // 29 February outside a leap year
+			}
+			if best.IsZero() || absDays(c.Sub(ref)) < absDays(best.Sub(ref)) {
+				best = c
+			}
+		}
+		return best.Format(time.DateOnly), !best.IsZero()
+	}
+	return "", false
+}
+
+func absDays(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// titleCase lowers every letter that follows another letter.
+func titleCase(s string) string {
+	b := []rune(s)
+	for i := 1; i < len(b); i++ {
+		if isLetter(b[i-1]) && isLetter(b[i]) {
+			b[i] = []rune(strings.ToLower(string(b[i])))[0]
+		}
+	}
+	return string(b)
+}
+
+func isLetter(r rune) bool {
+	return ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z')
+}
@@ -0,0 +1,73 @@
+package pkpass
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ----------------------
+// LOGIC: ARCHIVE LIMITS
+// ----------------------
+
+// A zip's central directory says how many entries it has and how large
+// each one is uncompressed, so an archive that would expand to gigabytes
+// can be refused before anything is inflated. OpenArchive checks those
+// totals; ReadEntry then holds each entry to the size its header claims,
+// since a crafted archive can understate it.
+
+// ArchiveLimits bounds what an archive may hold.
+type ArchiveLimits struct {
+	MaxEntries      int
+	MaxUncompressed int64 // all entries together
+}
+
+// passLimits are generous for a pass: real ones have a pass.json, a few
+// images and some localizations.
+var passLimits = ArchiveLimits{MaxEntries: 512, MaxUncompressed: 64 << 20}
+
+// ErrArchiveTooLarge is returned for an archive, or an entry, over its
+// limits.
+var ErrArchiveTooLarge = errors.New("archive too large")
+
+// OpenArchive opens the zip archive of size bytes read through r, and
+// checks it against l.
+func OpenArchive(r io.ReaderAt, size int64, l ArchiveLimits) (*zip.Reader, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if len(reader.File) > l.MaxEntries {
+		return nil, fmt.Errorf("%w: %d entries, over %d", ErrArchiveTooLarge, len(reader.File), l.MaxEntries)
+	}
+	var total uint64
+	for _, f := range reader.File {
+		total += f.UncompressedSize64
+		if total > uint64(l.MaxUncompressed) {
+			return nil, fmt.Errorf("%w: over %d bytes uncompressed", ErrArchiveTooLarge, l.MaxUncompressed)
+		}
+	}
+	return reader, nil
+}
+
+// ReadEntry reads f whole, failing with ErrArchiveTooLarge when it is over
+// max bytes, and with an error when it inflates past its declared size.
+func ReadEntry(f *zip.File, max int64) ([]byte, error) {
+	if f.UncompressedSize64 > uint64(max) {
+		return nil, fmt.Errorf("%w: %s is %d bytes, over %d", ErrArchiveTooLarge, f.Name, f.UncompressedSize64, max)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(io.LimitReader(rc, int64(f.UncompressedSize64)+1))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(b)) > f.UncompressedSize64 {
+		return nil, fmt.Errorf("%s inflates past its declared %d bytes", f.Name, f.UncompressedSize64)
+	}
+	return b, nil
+}
@@ -0,0 +1,21 @@
+package pkpass_test
+
+import (
+	"os"
+	"testing"
+
+	"bugsbyte/flight-info/pkpass"
+)
+
+func BenchmarkParse(b *testing.B) {
+	pass, err := os.ReadFile("../testdata/bench/boarding.pkpass")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := pkpass.Parse(pass); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
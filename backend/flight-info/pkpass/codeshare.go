@@ -0,0 +1,131 @@
+package pkpass
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// ----------------------
+// LOGIC: CODESHARE FLIGHTS
+// ----------------------
+
+// A codeshare pass shows the marketing flight, the one on the ticket, and
+// says who flies it: "LH 7402 operated by UA 953" in one value, or an
+// "Operated by" / "Operating flight" field of its own. Such a field isn't
+// the flight number, however much its label says "flight". flight_number
+// stays the displayed (marketing) flight, as on the pass; the operated-by
+// clause gives Carrier, the operating carrier as for a barcode, and
+// MarketingCarrier is the displayed flight's carrier when it differs.
+//
+// The barcode message usually carries the operating flight. When the pass
+// has one, it settles the operating carrier: it fills it in when the pass
+// doesn't say, and wins over an operated-by clause that disagrees, with a
+// warning and both values kept in RawData.
+
+// operatedBy splits "LH 7402 operated by UA 953" into the flight and the
+// clause. The clause may be in parentheses or after a comma or slash.
+var operatedBy = regexp.MustCompile(`(?i)^(.*?)[\s,;/(-]*\boperated\s+by\b:?\s*(.*?)[\s)]*$`)
+
+// designator matches a flight designator, "LH 7402" or "UA0953".
+var designator = regexp.MustCompile(`^([A-Z][A-Z0-9]|[0-9][A-Z])\s*0*([0-9]{1,4}[A-Z]?)$`)
+
+// flight is a parsed designator: "UA", "953".
+type flight struct{ carrier, number string }
+
+func (f flight) String() string { return f.carrier + " " + f.number }
+
+// parseFlight reads v as a flight designator.
+func parseFlight(v string) (flight, bool) {
+	m := designator.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(v)))
+	if m == nil {
+		return flight{}, false
+	}
+	return flight{m[1], m[2]}, true
+}
+
+// splitOperatedBy returns the part of v before an "operated by" clause and
+// the clause; ok is false when v has none.
+func splitOperatedBy(v string) (display, clause string, ok bool) {
+	m := operatedBy.FindStringSubmatch(v)
+	if m == nil {
+		return v, "", false
+	}
+	return strings.TrimSpace(m[1]), strings.TrimSpace(m[2]), true
+}
+
+// barcodeMessages lists the messages of pass.json's "barcodes", then of
+// the older single "barcode".
+func barcodeMessages(b []byte) []string {
+	type barcode struct {
+		Message string `json:"message"`
+	}
+	var v struct {
+		Barcodes []barcode `json:"barcodes"`
+		Barcode  *barcode  `json:"barcode"`
+	}
+	if json.Unmarshal(b, &v) != nil {
+		return nil
+	}
+	var msgs []string
+	for _, bc := range v.Barcodes {
+		msgs = append(msgs, bc.Message)
+	}
+	if v.Barcode != nil {
+		msgs = append(msgs, v.Barcode.Message)
+	}
+	return msgs
+}
+
+// applyCodeshare sets the operating and marketing carriers of p from the
+// operated-by clause, if any, and the first barcode message that parses as
+// BCBP, resolved around ref.
+func applyCodeshare(p *bcbp.UnifiedBoardingPass, clause string, messages []string, ref time.Time) {
+	shown, shownOK := parseFlight(p.FlightNumber)
+	if clause != "" {
+		p.RawData["operated_by"] = clause
+	}
+	op, opOK := parseFlight(clause)
+
+	var bc flight
+	bcOK := false
+	for _, msg := range messages {
+		if bp, err := bcbp.ParseAt(msg, ref); err == nil {
+			bc, bcOK = parseFlight(bp.Carrier + bp.FlightNumber)
+			break
+		}
+	}
+
+	source := bcbp.FromPkPassLabel
+	switch {
+	case opOK && bcOK && op != bc:
+		p.RawData["operating_flight_pkpass"] = op.String()
+		p.RawData["operating_flight_barcode"] = bc.String()
+		p.Warnings = append(p.Warnings, fmt.Sprintf("operating flight: the pass says operated by %s, the barcode has %s; kept the barcode's", op, bc))
+		op, source = bc, bcbp.FromBCBPMandatory
+	case !opOK && bcOK && shownOK && bc != shown:
+		if bc.carrier == shown.carrier {
+			// Not a codeshare: one of the two is wrong.
+			p.RawData["flight_number_pkpass"] = shown.String()
+			p.RawData["flight_number_barcode"] = bc.String()
+			p.Warnings = append(p.Warnings, fmt.Sprintf("flight_number: the pass shows %s, the barcode has %s; kept the pass's", shown, bc))
+			return
+		}
+		op, source = bc, bcbp.FromBCBPMandatory
+	case !opOK:
+		return
+	}
+
+	p.Carrier = op.carrier
+	p.SetFieldSource("carrier", source)
+	p.RawData["operating_flight"] = op.String()
+	if shownOK && shown.carrier != op.carrier {
+		p.MarketingCarrier = shown.carrier
+		p.SetFieldSource("marketing_carrier", bcbp.FromPkPassLabel)
+		p.RawData["marketing_flight"] = shown.String()
+	}
+}
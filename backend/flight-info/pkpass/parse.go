@@ -0,0 +1,496 @@
+// Package pkpass reads boarding passes out of Apple Wallet .pkpass files.
+package pkpass
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/internal/refdata"
+)
+
+// Pass is the part of pass.json the parser reads.
+type Pass struct {
+	Description      string `json:"description"`
+	OrganizationName string `json:"organizationName"`
+	RelevantDate     string `json:"relevantDate"`
+	BoardingPass     struct {
+		TransitType     string  `json:"transitType"`
+		PrimaryFields   []Field `json:"primaryFields"`
+		SecondaryFields []Field `json:"secondaryFields"`
+		AuxiliaryFields []Field `json:"auxiliaryFields"`
+		BackFields      []Field `json:"backFields"`
+	} `json:"boardingPass"`
+
+	// Recovery is how a broken pass.json was read, "" if it wasn't broken
+	// (see decodePassJSON).
+	Recovery string `json:"-"`
+
+	// messages are the barcode messages (see barcodeMessages). They are
+	// left out of the Pass as decoded, shown as parser detail, since they
+	// spell out the passenger's name.
+	messages []string
+	// webService is read the same way, as it holds credentials (see
+	// WebService).
+	webService *WebService
+	// semantics are its semantic tags, nil if it has none (see
+	// semanticsOf).
+	semantics *semantics
+}
+
+// Field is one entry of a pass.json field list.
+type Field struct {
+	Key   string      `json:"key"`
+	Label string      `json:"label"`
+	Value interface{} `json:"value"`
+}
+
+// ----------------------
+// LOGIC: PKPASS PARSER
+// ----------------------
+
+// Parse extracts a boarding pass from the pass.json of a .pkpass archive.
+// Fields are matched by key and label ("flight", "seat", "origin", ...)
+// and every field is also kept in RawData under its key. Train and bus
+// passes keep station names as they are, and their coach and platform go
+// to RawData["coach"] and RawData["platform"].
+func Parse(data []byte) (*bcbp.UnifiedBoardingPass, error) {
+	return ParseReader(bytes.NewReader(data), int64(len(data)))
+}
+
+// ParseReader is Parse for an archive of size bytes read through r, such
+// as an upload spooled to a temporary file. Only the central directory and
+// pass.json are read, plus the whole archive once if the pass needs a
+// fallback ID.
+func ParseReader(r io.ReaderAt, size int64) (*bcbp.UnifiedBoardingPass, error) {
+	return ParseReaderAt(r, size, time.Now())
+}
+
+// ParseAt is Parse with the Julian date of a barcode message resolved
+// around ref instead of today; only passes recovered from a broken
+// pass.json have one (see RecoveryBarcodeMessage).
+func ParseAt(data []byte, ref time.Time) (*bcbp.UnifiedBoardingPass, error) {
+	return ParseReaderAt(bytes.NewReader(data), int64(len(data)), ref)
+}
+
+// ParseReaderAt is ParseReader with a reference date, as for ParseAt.
+func ParseReaderAt(r io.ReaderAt, size int64, ref time.Time) (*bcbp.UnifiedBoardingPass, error) {
+	return ParseReaderWith(r, size, ref, DefaultMapper)
+}
+
+// ParseReaderWith is ParseReaderAt with the fields mapped by m instead of
+// DefaultMapper.
+func ParseReaderWith(r io.ReaderAt, size int64, ref time.Time, m Mapper) (*bcbp.UnifiedBoardingPass, error) {
+	pk, err := DecodeReader(r, size)
+	if errors.Is(err, errBrokenPassJSON) {
+		return parseBarcodeMessage(r, size, ref, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	unified := &bcbp.UnifiedBoardingPass{
+		Source:      bcbp.SourcePkPass,
+		TransitMode: transitModes[pk.BoardingPass.TransitType],
+		RawData:     make(map[string]string),
+	}
+	if pk.Recovery != "" {
+		unified.RawData["pkpass_recovery"] = pk.Recovery
+		unified.Warnings = append(unified.Warnings, "pass.json: not valid JSON as sent; read with recovery "+pk.Recovery)
+	}
+	if t, err := time.Parse(time.RFC3339, pk.RelevantDate); err == nil {
+		unified.DateISO = t.Format(time.DateOnly)
+		unified.SetFieldSource("date_iso", bcbp.FromInferred)
+	}
+
+	lm := &labelMatcher{p: unified, ground: unified.TransitMode.Ground()}
+	bp := &pk.BoardingPass
+	all := slices.Concat(bp.PrimaryFields, bp.SecondaryFields, bp.AuxiliaryFields, bp.BackFields)
+	lm.match(all)
+	if m == MapSemantic && pk.semantics != nil {
+		applySemantics(unified, pk.semantics)
+	}
+	dropGateDuplicates(unified)
+	recoverAirports(unified, bp.PrimaryFields, all)
+	bcbp.ClassifyLocations(unified)
+	applyCodeshare(unified, lm.clause, pk.messages, ref)
+	if err := bcbp.StampReader(unified, io.NewSectionReader(r, 0, size)); err != nil {
+		return nil, err
+	}
+
+	return unified, nil
+}
+
+// ParseFields builds a pass from fields that didn't come from a pass.json,
+// such as the labels and values printed on a paper pass, matched by key
+// and label as Parse matches pass.json's. A field without a key or label
+// is unlabeled text, matched by its value alone. The pass has no
+// Source or ID; the caller sets them.
+func ParseFields(fields []Field) *bcbp.UnifiedBoardingPass {
+	p := &bcbp.UnifiedBoardingPass{RawData: make(map[string]string)}
+	lm := &labelMatcher{p: p}
+	lm.match(fields)
+	dropGateDuplicates(p)
+	recoverAirports(p, fields, fields)
+	bcbp.ClassifyLocations(p)
+	applyCodeshare(p, lm.clause, nil, time.Time{})
+	return p
+}
+
+// labelMatcher fills a pass from fields matched by key and label.
+type labelMatcher struct {
+	p *bcbp.UnifiedBoardingPass
+	// ground is set for train and bus passes.
+	ground bool
+	// clause is the first "operated by" clause found (see applyCodeshare).
+	clause string
+}
+
+func (m *labelMatcher) set(name string, field *string, v string) {
+	*field = v
+	m.p.SetFieldSource(name, bcbp.FromPkPassLabel)
+}
+
+func (m *labelMatcher) setPriority() {
+	m.p.PriorityBoarding = true
+	m.p.SetFieldSource("priority_boarding", bcbp.FromPkPassLabel)
+}
+
+// match reads fields in order; where two match the same field, the later
+// one wins, except for the flight number.
+func (m *labelMatcher) match(fields []Field) {
+	for _, f := range fields {
+		valStr := fmt.Sprintf("%v", f.Value)
+		keyLower := strings.ToLower(f.Key)
+		labelLower := strings.ToLower(f.Label)
+
+		if f.Key != "" {
+			m.p.RawData[f.Key] = valStr
+		}
+
+		// Time values are never airports, seats, or names; keep them out
+		// of the keyword matching below (e.g. "departureTime" contains "dep").
+		if clock, date, ok := parseClockTime(valStr); ok {
+			switch {
+			case strings.Contains(keyLower, "board") || strings.Contains(labelLower, "board"):
+				m.set("boarding_time", &m.p.BoardingTime, clock)
+			case strings.Contains(keyLower, "dep") || strings.Contains(labelLower, "depart"):
+				m.set("departure_time", &m.p.DepartureTime, clock)
+			}
+			if date != "" && m.p.DateISO == "" {
+				m.set("date_iso", &m.p.DateISO, date)
+			}
+			continue
+		}
+
+		// Gate, terminal, group and sequence keys often also name the
+		// airport ("departureGate"), so they are settled first, and so
+		// are a train's coach and platform, which aren't seats or gates.
+		switch {
+		case m.ground && containsAny(keyLower, labelLower, "coach", "carriage", "wagon"):
+			m.p.RawData["coach"] = valStr
+			continue
+		case m.ground && containsAny(keyLower, labelLower, "platform", "track", "bay"):
+			m.p.RawData["platform"] = valStr
+			continue
+		case containsAny(keyLower, labelLower, "desk", "counter"):
+			m.set("check_in_desk", &m.p.CheckInDesk, normalizePlace(valStr))
+			continue
+		case containsAny(keyLower, labelLower, "door"):
+			m.set("boarding_door", &m.p.BoardingDoor, normalizePlace(valStr))
+			continue
+		case strings.Contains(keyLower, "gate") || strings.Contains(labelLower, "gate"):
+			m.set("gate", &m.p.Gate, valStr)
+			m.p.RawData["gate"] = valStr
+			continue
+		case strings.Contains(keyLower, "terminal") || strings.Contains(labelLower, "terminal"):
+			m.set("terminal", &m.p.Terminal, valStr)
+			continue
+		case containsAny(keyLower, labelLower, "priority"):
+			if _, priority, _ := bcbp.NormalizeBoardingGroup(valStr); priority || isYes(valStr) {
+				m.setPriority()
+			}
+			continue
+		case strings.Contains(keyLower, "group") || strings.Contains(keyLower, "zone") ||
+			strings.Contains(labelLower, "group") || strings.Contains(labelLower, "zone"):
+			group, priority, ok := bcbp.NormalizeBoardingGroup(valStr)
+			if !ok {
+				group = valStr
+				m.p.Warnings = append(m.p.Warnings, fmt.Sprintf("boarding_group: %q is not a group or zone format the parser knows; kept as printed", valStr))
+			}
+			if group != "" {
+				m.set("boarding_group", &m.p.BoardingGroup, group)
+			}
+			if priority {
+				m.setPriority()
+			}
+			continue
+		case strings.Contains(keyLower, "sequence") || strings.Contains(labelLower, "sequence") ||
+			keyLower == "seq" || labelLower == "seq":
+			m.set("sequence_number", &m.p.SequenceNumber, valStr)
+			continue
+		}
+
+		// A field of its own saying "SkyPriority" or "Speedy Boarding".
+		if group, priority, ok := bcbp.NormalizeBoardingGroup(valStr); ok && priority && group == "" {
+			m.setPriority()
+			continue
+		}
+
+		// An "Operated by" or "Operating flight" field names the
+		// operating flight, not the flight number.
+		if containsAny(keyLower, labelLower, "operat") {
+			if m.clause == "" {
+				m.clause = valStr
+			}
+			continue
+		}
+		if strings.Contains(keyLower, "flight") || strings.Contains(labelLower, "flight") {
+			display, c, ok := splitOperatedBy(valStr)
+			if ok && m.clause == "" {
+				m.clause = c
+			}
+			// The first flight shown, in the pass's own field order,
+			// is the one on the front.
+			if m.p.FlightNumber == "" {
+				m.set("flight_number", &m.p.FlightNumber, display)
+			}
+		}
+		if strings.Contains(keyLower, "seat") || strings.Contains(labelLower, "seat") {
+			m.p.SetSeat(valStr, bcbp.FromPkPassLabel)
+		}
+		if strings.Contains(keyLower, "passenger") || strings.Contains(keyLower, "name") {
+			m.set("passenger_name", &m.p.PassengerName, valStr)
+		}
+		if strings.Contains(keyLower, "origin") || strings.Contains(keyLower, "dep") {
+			m.set("departure_airport", &m.p.Departure, valStr)
+		}
+		if strings.Contains(keyLower, "dest") || strings.Contains(keyLower, "arr") {
+			m.set("arrival_airport", &m.p.Arrival, valStr)
+		}
+		if strings.Contains(keyLower, "pnr") || strings.Contains(keyLower, "record") {
+			m.set("pnr", &m.p.PNR, valStr)
+		}
+		if strings.Contains(keyLower, "class") || strings.Contains(keyLower, "cabin") || strings.Contains(labelLower, "class") {
+			m.set("cabin_class", &m.p.CabinClass, valStr)
+		}
+	}
+}
+
+// parseBarcodeMessage is the last resort for a pass.json that can't be
+// decoded: the pass is parsed from a barcode message in the archive.
+// jsonErr is returned if there is none.
+func parseBarcodeMessage(r io.ReaderAt, size int64, ref time.Time, jsonErr error) (*bcbp.UnifiedBoardingPass, error) {
+	p, file, err := passFromBarcodeMessage(r, size, ref)
+	if err != nil {
+		return nil, fmt.Errorf("%w; %v", jsonErr, err)
+	}
+	p.Source = bcbp.SourcePkPass
+	p.RawData["pkpass_recovery"] = RecoveryBarcodeMessage
+	p.Warnings = append(p.Warnings, fmt.Sprintf("%v; pass read from the barcode message in %s, so only barcode fields are set", jsonErr, file))
+	return p, nil
+}
+
+// Decode reads the pass.json of a .pkpass archive into a Pass, without
+// interpreting any field. A pass.json with a byte order mark, trailing
+// commas or raw newlines in strings is still read (see Pass.Recovery).
+func Decode(data []byte) (*Pass, error) {
+	return DecodeReader(bytes.NewReader(data), int64(len(data)))
+}
+
+// DecodeReader is Decode for an archive of size bytes read through r.
+func DecodeReader(r io.ReaderAt, size int64) (*Pass, error) {
+	b, err := PassJSON(r, size)
+	if err != nil {
+		return nil, err
+	}
+	pk, recovery, err := decodePassJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	pk.Recovery = recovery
+	return pk, nil
+}
+
+// maxPassJSON caps what PassJSON reads; real ones are a few kilobytes.
+const maxPassJSON = 1 << 20
+
+// PassJSON returns the pass.json of an archive of size bytes read through
+// r, as it is in the archive.
+func PassJSON(r io.ReaderAt, size int64) ([]byte, error) {
+	rc, err := openPassJSON(r, size)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(io.LimitReader(rc, maxPassJSON+1))
+	if err == nil && len(b) > maxPassJSON {
+		err = fmt.Errorf("invalid pkpass: pass.json over %d bytes", maxPassJSON)
+	}
+	return b, err
+}
+
+func openPassJSON(r io.ReaderAt, size int64) (io.ReadCloser, error) {
+	reader, err := OpenArchive(r, size, passLimits)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range reader.File {
+		if f.Name == "pass.json" {
+			return f.Open()
+		}
+	}
+	return nil, fmt.Errorf("invalid pkpass: pass.json not found")
+}
+
+// transitModes maps pass.json transitType values to TransitMode.
+var transitModes = map[string]bcbp.TransitMode{
+	"PKTransitTypeAir":     bcbp.TransitAir,
+	"PKTransitTypeTrain":   bcbp.TransitTrain,
+	"PKTransitTypeBus":     bcbp.TransitBus,
+	"PKTransitTypeBoat":    bcbp.TransitBoat,
+	"PKTransitTypeGeneric": bcbp.TransitGeneric,
+}
+
+// isYes reports whether a flag field's value means yes.
+func isYes(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "yes", "y", "true", "1", "sim", "oui", "ja", "sí", "si":
+		return true
+	}
+	return false
+}
+
+// containsAny reports whether the key or label contains any of words.
+func containsAny(key, label string, words ...string) bool {
+	for _, w := range words {
+		if strings.Contains(key, w) || strings.Contains(label, w) {
+			return true
+		}
+	}
+	return false
+}
+
+var airportToken = regexp.MustCompile(`\b[A-Z]{3}\b`)
+
+// recoverAirports fills in a missing departure or arrival from field values
+// such as "LIS → FRA", or two fields valued "LIS" and "FRA": codes are read
+// left to right, primary fields first, then every field. Codes that could
+// go either way (one code for two empty fields, or more codes than empty
+// fields) leave the fields empty rather than guessing. Only airports in
+// the airport dataset count; a city or station code in a field value is
+// rarely meant as one.
+func recoverAirports(p *bcbp.UnifiedBoardingPass, primary, all []Field) {
+	if p.TransitMode.Ground() || (p.Departure != "" && p.Arrival != "") {
+		return
+	}
+	for _, fields := range [][]Field{primary, all} {
+		var codes []string
+		for _, f := range fields {
+			for _, code := range airportToken.FindAllString(fmt.Sprintf("%v", f.Value), -1) {
+				if refdata.IsAirport(code) && code != p.Departure && code != p.Arrival && !slices.Contains(codes, code) {
+					codes = append(codes, code)
+				}
+			}
+		}
+		var missing []*string
+		var names []string
+		if p.Departure == "" {
+			missing, names = append(missing, &p.Departure), append(names, "departure_airport")
+		}
+		if p.Arrival == "" {
+			missing, names = append(missing, &p.Arrival), append(names, "arrival_airport")
+		}
+		switch {
+		case len(codes) > len(missing):
+			return
+		case len(codes) < len(missing):
+			continue
+		}
+		for i, code := range codes {
+			*missing[i] = code
+			p.SetFieldSource(names[i], bcbp.FromInferred)
+		}
+		p.Warnings = append(p.Warnings, strings.Join(names, ", ")+": no field is labeled as an airport; guessed from the codes "+strings.Join(codes, ", ")+" in the field values")
+		return
+	}
+}
+
+// placeLabel is a label repeated in a desk or door value, as in "Desks
+// 340-348" or "Door B".
+var placeLabel = regexp.MustCompile(`(?i)^(?:check-?in\s+)?(?:desks?|counters?|doors?)\b\s*:?\s*`)
+
+// placeRange is a range of desks, as in "340 - 348" or "340 to 348".
+var placeRange = regexp.MustCompile(`(?i)^(\w+)\s*(?:-|–|—|to)\s*(\w+)$`)
+
+// normalizePlace tidies a check-in desk or boarding door: runs of spaces
+// collapsed, a repeated label dropped and a range written "340-348".
+func normalizePlace(v string) string {
+	v = strings.Join(strings.Fields(v), " ")
+	if rest := placeLabel.ReplaceAllString(v, ""); rest != "" {
+		v = rest
+	}
+	if m := placeRange.FindStringSubmatch(v); m != nil {
+		return m[1] + "-" + m[2]
+	}
+	return v
+}
+
+// dropGateDuplicates clears a desk or door that only repeats the gate, as
+// passes that print the gate again as "Boarding door" do, so clients don't
+// show it twice. raw_extra_data keeps the field as printed.
+func dropGateDuplicates(p *bcbp.UnifiedBoardingPass) {
+	gate := strings.Join(strings.Fields(p.Gate), "")
+	if gate == "" {
+		return
+	}
+	for _, f := range []struct {
+		name  string
+		value *string
+	}{{"check_in_desk", &p.CheckInDesk}, {"boarding_door", &p.BoardingDoor}} {
+		if strings.EqualFold(strings.Join(strings.Fields(*f.value), ""), gate) {
+			*f.value = ""
+			delete(p.FieldSources, f.name)
+		}
+	}
+}
+
+var clockPattern = regexp.MustCompile(`^(\d{1,2})[:h](\d{2})\s*([AaPp][Mm])?$`)
+
+// parseClockTime recognizes pkpass time values — "14:35", "2:35 PM", "14h35",
+// or a full RFC 3339 timestamp from a dateStyle field — and returns the
+// wall-clock time as "HH:MM", plus the ISO date when the value carried one.
+func parseClockTime(v string) (clock, date string, ok bool) {
+	v = strings.TrimSpace(v)
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.Format("15:04"), t.Format(time.DateOnly), true
+	}
+
+	m := clockPattern.FindStringSubmatch(v)
+	if m == nil {
+		return "", "", false
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute, _ := strconv.Atoi(m[2])
+	switch strings.ToUpper(m[3]) {
+	case "AM":
+		if hour == 12 {
+			hour = 0
+		}
+	case "PM":
+		if hour < 12 {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return "", "", false
+	}
+	return fmt.Sprintf("%02d:%02d", hour, minute), "", true
+}
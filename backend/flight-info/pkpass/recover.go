@@ -0,0 +1,158 @@
+package pkpass
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// ----------------------
+// LOGIC: BROKEN PASS.JSON RECOVERY
+// ----------------------
+
+// Pass generators get pass.json wrong in a few recurring ways: a byte order
+// mark, trailing commas, raw newlines inside strings. Wallet itself is
+// forgiving about some of them, so such passes are out there. The parser
+// recovers in steps, and RawData["pkpass_recovery"] names the step that
+// worked:
+//
+//	bom              the pass.json parsed once its byte order mark was dropped
+//	lenient_json     it parsed after relaxJSON
+//	barcode_message  it didn't parse at all; the pass comes from a BCBP
+//	                 string found in the archive, usually the barcode message
+//
+// Only when none of them works does Parse fail.
+
+// Recovery paths, as stored in RawData["pkpass_recovery"].
+const (
+	RecoveryBOM            = "bom"
+	RecoveryLenientJSON    = "lenient_json"
+	RecoveryBarcodeMessage = "barcode_message"
+)
+
+// errBrokenPassJSON wraps a pass.json that is not JSON even after
+// relaxJSON, as opposed to an archive that can't be read at all.
+var errBrokenPassJSON = errors.New("pass.json is not valid JSON")
+
+var utf8BOM = []byte("\xEF\xBB\xBF")
+
+// decodePassJSON decodes b into a Pass, recovering from the usual generator
+// mistakes. recovery is "" for a pass.json that was valid as it was.
+func decodePassJSON(b []byte) (pk *Pass, recovery string, err error) {
+	pk = &Pass{}
+	if b2, ok := bytes.CutPrefix(b, utf8BOM); ok {
+		b, recovery = b2, RecoveryBOM
+	}
+	strictErr := json.Unmarshal(b, pk)
+	if strictErr == nil {
+		pk.messages, pk.webService, pk.semantics = barcodeMessages(b), webServiceOf(b), semanticsOf(b)
+		return pk, recovery, nil
+	}
+	pk = &Pass{}
+	if b := relaxJSON(b); json.Unmarshal(b, pk) == nil {
+		pk.messages, pk.webService, pk.semantics = barcodeMessages(b), webServiceOf(b), semanticsOf(b)
+		return pk, RecoveryLenientJSON, nil
+	}
+	return nil, "", fmt.Errorf("%w: %v", errBrokenPassJSON, strictErr)
+}
+
+// relaxJSON fixes the JSON mistakes pass generators make most: trailing
+// commas before a } or ], and raw control characters (newlines, tabs)
+// inside strings, which are escaped. Anything else is left alone.
+func relaxJSON(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	inString, escaped := false, false
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			case c < 0x20:
+				out = fmt.Appendf(out, `\u%04x`, c)
+				continue
+			}
+			out = append(out, c)
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case ',':
+			j := i + 1
+			for j < len(b) && strings.IndexByte(" \t\r\n", b[j]) >= 0 {
+				j++
+			}
+			if j < len(b) && (b[j] == '}' || b[j] == ']') {
+				continue
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// jsonString matches a JSON string literal.
+var jsonString = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+
+// skipExts are archive members never searched for a barcode message.
+var skipExts = []string{".png", ".jpg", ".jpeg", ".gif", ".strings"}
+
+// passFromBarcodeMessage looks through the archive, pass.json first, for a
+// JSON string that parses as BCBP, and returns that pass. Barcode
+// messages are the usual find: a pass.json too broken to decode still
+// has them as intact strings.
+func passFromBarcodeMessage(r io.ReaderAt, size int64, ref time.Time) (*bcbp.UnifiedBoardingPass, string, error) {
+	reader, err := OpenArchive(r, size, passLimits)
+	if err != nil {
+		return nil, "", err
+	}
+	files := slices.Clone(reader.File)
+	slices.SortStableFunc(files, func(a, b *zip.File) int {
+		switch {
+		case a.Name == "pass.json":
+			return -1
+		case b.Name == "pass.json":
+			return 1
+		}
+		return 0
+	})
+	for _, f := range files {
+		if f.FileInfo().IsDir() || slices.Contains(skipExts, strings.ToLower(path.Ext(f.Name))) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			continue
+		}
+		b, err := io.ReadAll(io.LimitReader(rc, maxPassJSON))
+		rc.Close()
+		if err != nil {
+			continue
+		}
+		for _, lit := range jsonString.FindAll(b, -1) {
+			var s string
+			if json.Unmarshal(relaxJSON(lit), &s) != nil || len(s) < 60 || (s[0] != 'M' && s[0] != 'S') {
+				continue
+			}
+			if p, err := bcbp.ParseAt(s, ref); err == nil {
+				return p, f.Name, nil
+			}
+		}
+	}
+	return nil, "", errors.New("no barcode message found in the archive")
+}
@@ -0,0 +1,236 @@
+package pkpass
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// ----------------------
+// LOGIC: SEMANTIC TAGS
+// ----------------------
+
+// Since iOS 15 a pass.json can say what it holds with semantic tags
+// ("semantics"), on the pass and on each field: airlineCode, flightNumber,
+// departureAirportCode, seats, ... A pass that has them can be read without
+// guessing from keys and labels, which the keyword matching gets wrong for
+// keys like "depGate" or labels in other languages.
+//
+// A Mapper is how the fields of a decoded pass become pass fields.
+// MapKeywords is the keyword matching alone; MapSemantic is the keyword
+// matching with what the semantic tags say on top. The API can run the one
+// it doesn't answer with on the same pass and count where the two disagree
+// (see PKPASS_SHADOW_MAPPER) before the default changes.
+
+// Mapper names a way of mapping pass.json fields to pass fields.
+type Mapper string
+
+const (
+	MapKeywords Mapper = "keywords"
+	MapSemantic Mapper = "semantic"
+)
+
+// DefaultMapper is the mapper of Parse and its variants. The API sets it
+// from PKPASS_MAPPER at startup.
+var DefaultMapper = MapKeywords
+
+// ParseMapper reads a mapper name.
+func ParseMapper(v string) (Mapper, error) {
+	switch m := Mapper(strings.ToLower(strings.TrimSpace(v))); m {
+	case MapKeywords, MapSemantic:
+		return m, nil
+	}
+	return "", fmt.Errorf("mapper %q is not %s or %s", v, MapKeywords, MapSemantic)
+}
+
+// semantics are the semantic tags a mapper reads, as strings. The pass's
+// own tags come first; field tags fill in what they leave out.
+type semantics struct {
+	airlineCode   string
+	flightCode    string // airline code and number, "LH400"
+	flightNumber  string // number only
+	departure     string // IATA airport codes
+	arrival       string
+	gate          string
+	terminal      string
+	boardingGroup string
+	sequence      string
+	pnr           string
+	passengerName string
+	seat          string
+	departureDate string // RFC 3339
+	boardingDate  string
+}
+
+// semanticTags is one "semantics" object. Values are decoded one by one,
+// so a tag of an unexpected type is skipped rather than failing the pass.
+type semanticTags map[string]json.RawMessage
+
+// str returns the tag key as a string; numbers are accepted, as
+// generators write flightNumber either way.
+func (t semanticTags) str(key string) string {
+	raw, ok := t[key]
+	if !ok {
+		return ""
+	}
+	var v any
+	if json.Unmarshal(raw, &v) != nil {
+		return ""
+	}
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return fmt.Sprintf("%v", v)
+	}
+	return ""
+}
+
+// name returns passengerName as "GIVEN FAMILY".
+func (t semanticTags) name() string {
+	var n struct {
+		GivenName  string `json:"givenName"`
+		FamilyName string `json:"familyName"`
+	}
+	if json.Unmarshal(t["passengerName"], &n) != nil {
+		return ""
+	}
+	return strings.TrimSpace(n.GivenName + " " + n.FamilyName)
+}
+
+// seat returns the first of seats, by its identifier or its row and
+// number.
+func (t semanticTags) seat() string {
+	var seats []struct {
+		SeatIdentifier string `json:"seatIdentifier"`
+		SeatRow        string `json:"seatRow"`
+		SeatNumber     string `json:"seatNumber"`
+	}
+	if json.Unmarshal(t["seats"], &seats) != nil || len(seats) == 0 {
+		return ""
+	}
+	seat := seats[0]
+	row, number := strings.TrimSpace(seat.SeatRow), strings.TrimSpace(seat.SeatNumber)
+	switch {
+	case strings.TrimSpace(seat.SeatIdentifier) != "":
+		return strings.TrimSpace(seat.SeatIdentifier)
+	case row != "" && strings.HasPrefix(number, row):
+		// Some generators put the whole seat in seatNumber.
+		return number
+	}
+	return row + number
+}
+
+// fill sets the tags of t that s doesn't have yet.
+func (s *semantics) fill(t semanticTags) {
+	for _, f := range []struct {
+		field *string
+		v     string
+	}{
+		{&s.airlineCode, t.str("airlineCode")},
+		{&s.flightCode, t.str("flightCode")},
+		{&s.flightNumber, t.str("flightNumber")},
+		{&s.departure, t.str("departureAirportCode")},
+		{&s.arrival, t.str("destinationAirportCode")},
+		{&s.gate, t.str("departureGate")},
+		{&s.terminal, t.str("departureTerminal")},
+		{&s.boardingGroup, t.str("boardingGroup")},
+		{&s.sequence, t.str("boardingSequenceNumber")},
+		{&s.pnr, t.str("confirmationNumber")},
+		{&s.passengerName, t.name()},
+		{&s.seat, t.seat()},
+		{&s.departureDate, t.str("currentDepartureDate")},
+		{&s.departureDate, t.str("originalDepartureDate")},
+		{&s.boardingDate, t.str("currentBoardingDate")},
+		{&s.boardingDate, t.str("originalBoardingDate")},
+	} {
+		if *f.field == "" {
+			*f.field = f.v
+		}
+	}
+}
+
+// semanticsOf reads the semantic tags of the pass.json b, nil when it has
+// none.
+func semanticsOf(b []byte) *semantics {
+	type field struct {
+		Semantics semanticTags `json:"semantics"`
+	}
+	var v struct {
+		Semantics    semanticTags `json:"semantics"`
+		BoardingPass struct {
+			HeaderFields    []field `json:"headerFields"`
+			PrimaryFields   []field `json:"primaryFields"`
+			SecondaryFields []field `json:"secondaryFields"`
+			AuxiliaryFields []field `json:"auxiliaryFields"`
+			BackFields      []field `json:"backFields"`
+		} `json:"boardingPass"`
+	}
+	if json.Unmarshal(b, &v) != nil {
+		return nil
+	}
+	s := &semantics{}
+	s.fill(v.Semantics)
+	bp := &v.BoardingPass
+	for _, fields := range [][]field{bp.HeaderFields, bp.PrimaryFields, bp.SecondaryFields, bp.AuxiliaryFields, bp.BackFields} {
+		for _, f := range fields {
+			s.fill(f.Semantics)
+		}
+	}
+	if *s == (semantics{}) {
+		return nil
+	}
+	return s
+}
+
+// applySemantics sets the fields of p that the semantic tags s give,
+// over what the keyword matching found.
+func applySemantics(p *bcbp.UnifiedBoardingPass, s *semantics) {
+	set := func(name string, field *string, v string) {
+		if v == "" {
+			return
+		}
+		*field = v
+		p.SetFieldSource(name, bcbp.FromPkPassSemantics)
+	}
+
+	flight := s.flightCode
+	if flight == "" && s.flightNumber != "" {
+		flight = s.airlineCode + s.flightNumber
+	}
+	set("flight_number", &p.FlightNumber, flight)
+	set("departure_airport", &p.Departure, strings.ToUpper(s.departure))
+	set("arrival_airport", &p.Arrival, strings.ToUpper(s.arrival))
+	set("gate", &p.Gate, s.gate)
+	set("terminal", &p.Terminal, s.terminal)
+	set("sequence_number", &p.SequenceNumber, s.sequence)
+	set("pnr", &p.PNR, s.pnr)
+	set("passenger_name", &p.PassengerName, s.passengerName)
+	if s.gate != "" {
+		p.RawData["gate"] = s.gate
+	}
+	if s.boardingGroup != "" {
+		group, priority, ok := bcbp.NormalizeBoardingGroup(s.boardingGroup)
+		if !ok {
+			group = s.boardingGroup
+		}
+		set("boarding_group", &p.BoardingGroup, group)
+		if priority {
+			p.PriorityBoarding = true
+			p.SetFieldSource("priority_boarding", bcbp.FromPkPassSemantics)
+		}
+	}
+	if s.seat != "" {
+		p.SetSeat(s.seat, bcbp.FromPkPassSemantics)
+	}
+	if t, err := time.Parse(time.RFC3339, s.departureDate); err == nil {
+		set("date_iso", &p.DateISO, t.Format(time.DateOnly))
+		set("departure_time", &p.DepartureTime, t.Format("15:04"))
+	}
+	if t, err := time.Parse(time.RFC3339, s.boardingDate); err == nil {
+		set("boarding_time", &p.BoardingTime, t.Format("15:04"))
+	}
+}
@@ -0,0 +1,97 @@
+package pkpass
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// ----------------------
+// LOGIC: PASS WEB SERVICE
+// ----------------------
+
+// A pass.json with webServiceURL and authenticationToken can be updated by
+// its issuer: Wallet asks GET {webServiceURL}/v1/passes/{passTypeIdentifier}/
+// {serialNumber}, with the token in an "ApplePass" Authorization header, and
+// gets the latest version of the pass back. The four values are credentials
+// for the issuer's service, so they are kept off Pass as decoded (and so off
+// parser detail) and only handed out by WebService.
+
+// WebService is where and how to fetch the latest version of a pass.
+type WebService struct {
+	URL                 string `json:"url"`
+	PassTypeIdentifier  string `json:"pass_type_identifier"`
+	SerialNumber        string `json:"serial_number"`
+	AuthenticationToken string `json:"authentication_token"`
+}
+
+// webServiceFields are the pass.json keys WebService is read from.
+type webServiceFields struct {
+	WebServiceURL       string `json:"webServiceURL"`
+	PassTypeIdentifier  string `json:"passTypeIdentifier"`
+	SerialNumber        string `json:"serialNumber"`
+	AuthenticationToken string `json:"authenticationToken"`
+}
+
+// webServiceOf reads the web service of the pass.json b, nil when it has
+// none or it is incomplete.
+func webServiceOf(b []byte) *WebService {
+	var f webServiceFields
+	if json.Unmarshal(b, &f) != nil {
+		return nil
+	}
+	ws := &WebService{
+		URL:                 strings.TrimRight(strings.TrimSpace(f.WebServiceURL), "/"),
+		PassTypeIdentifier:  strings.TrimSpace(f.PassTypeIdentifier),
+		SerialNumber:        strings.TrimSpace(f.SerialNumber),
+		AuthenticationToken: f.AuthenticationToken,
+	}
+	if ws.URL == "" || ws.PassTypeIdentifier == "" || ws.SerialNumber == "" || ws.AuthenticationToken == "" {
+		return nil
+	}
+	if u, err := url.Parse(ws.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil
+	}
+	return ws
+}
+
+// WebService returns the pass's web service; ok is false when pass.json
+// has no usable webServiceURL, authenticationToken, passTypeIdentifier and
+// serialNumber.
+func (p *Pass) WebService() (ws WebService, ok bool) {
+	if p.webService == nil {
+		return WebService{}, false
+	}
+	return *p.webService, true
+}
+
+// WebServiceReader is Pass.WebService for an archive of size bytes read
+// through r.
+func WebServiceReader(r io.ReaderAt, size int64) (ws WebService, ok bool) {
+	pk, err := DecodeReader(r, size)
+	if err != nil {
+		return WebService{}, false
+	}
+	return pk.WebService()
+}
+
+// LatestURL is the URL the latest version of the pass is fetched from.
+func (ws WebService) LatestURL() string {
+	return ws.URL + "/v1/passes/" + url.PathEscape(ws.PassTypeIdentifier) + "/" + url.PathEscape(ws.SerialNumber)
+}
+
+// AuthorizationHeader is the Authorization header value for LatestURL.
+func (ws WebService) AuthorizationHeader() string {
+	return "ApplePass " + ws.AuthenticationToken
+}
+
+// LogValue keeps the credentials out of logs: only the host is shown.
+func (ws WebService) LogValue() slog.Value {
+	host := ""
+	if u, err := url.Parse(ws.URL); err == nil {
+		host = u.Host
+	}
+	return slog.StringValue(host)
+}
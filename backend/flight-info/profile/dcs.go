@@ -0,0 +1,77 @@
+package profile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// The dcs profile is for departure control systems that take the fields
+// in their own fixed formats:
+//
+//	carrier        two characters, without the padding of a BCBP field
+//	flight_number  four digits, zero-padded, and the suffix: "0432", "0432A"
+//	date_iso       DDMMMYY: "14JUL26"
+//	seat           without leading zeros: "012A" is "12A"
+//
+// The same applies to the legs of a group pass, except the date, which
+// they only have as a Julian day.
+
+func init() {
+	Register(Profile{
+		Name:        "dcs",
+		Description: "Departure control system formats: 2-character carrier, 4-digit flight, DDMMMYY date, seat without leading zeros",
+		Apply:       applyDCS,
+	})
+}
+
+func applyDCS(p *bcbp.UnifiedBoardingPass) {
+	p.FlightNumber = dcsFlightNumber(p.FlightNumber, p.Carrier)
+	p.Carrier = strings.TrimSpace(p.Carrier)
+	p.MarketingCarrier = strings.TrimSpace(p.MarketingCarrier)
+	p.Seat = dcsSeat(p.Seat)
+	if t, err := time.Parse(time.DateOnly, p.DateISO); err == nil {
+		p.DateISO = strings.ToUpper(t.Format("02Jan06"))
+	}
+	for _, legs := range [][]bcbp.PassengerLeg{p.Passengers, p.Legs} {
+		for i, l := range legs {
+			legs[i].FlightNumber = dcsFlightNumber(l.FlightNumber, l.Carrier)
+			legs[i].Carrier = strings.TrimSpace(l.Carrier)
+			legs[i].Seat = dcsSeat(l.Seat)
+		}
+	}
+}
+
+// flightPattern is a flight number with an optional airline designator in
+// front (two characters, one of them a letter, or three letters) and an
+// optional suffix letter.
+var flightPattern = regexp.MustCompile(`^(?:[A-Z]{2,3}|[A-Z][0-9]|[0-9][A-Z])?\s*([0-9]{1,4})([A-Z]?)$`)
+
+// dcsFlightNumber zero-pads v to four digits, dropping the carrier in front
+// of it: "TP 432" and "432" are "0432". A value that isn't a flight number
+// is left alone.
+func dcsFlightNumber(v, carrier string) string {
+	s := strings.ToUpper(strings.TrimSpace(v))
+	if c := strings.TrimSpace(carrier); c != "" {
+		if rest, ok := strings.CutPrefix(s, c); ok {
+			s = strings.TrimSpace(rest)
+		}
+	}
+	m := flightPattern.FindStringSubmatch(s)
+	if m == nil {
+		return v
+	}
+	return fmt.Sprintf("%04s%s", m[1], m[2])
+}
+
+// dcsSeat drops the leading zeros of a seat's row.
+func dcsSeat(v string) string {
+	s := strings.TrimSpace(v)
+	for len(s) > 1 && s[0] == '0' && s[1] >= '0' && s[1] <= '9' {
+		s = s[1:]
+	}
+	return s
+}
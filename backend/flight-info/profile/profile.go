@@ -0,0 +1,63 @@
+// Package profile reshapes parsed passes for the systems they are sent to.
+// A profile is a transformation over a finished UnifiedBoardingPass, run
+// after parsing, enrichment and redaction: the parsers never see it, and
+// the default profile leaves the pass as it is.
+package profile
+
+import (
+	"fmt"
+	"slices"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// Default is the name of the profile that changes nothing, also picked by
+// an empty name.
+const Default = "default"
+
+// Profile is a named output format.
+type Profile struct {
+	Name        string
+	Description string
+	// Apply rewrites p in place. Fields it doesn't know how to convert are
+	// left as they are.
+	Apply func(p *bcbp.UnifiedBoardingPass)
+}
+
+var registry = map[string]Profile{}
+
+func init() {
+	Register(Profile{
+		Name:        Default,
+		Description: "The parser's output, unchanged",
+		Apply:       func(*bcbp.UnifiedBoardingPass) {},
+	})
+}
+
+// Register adds p to the profiles Lookup knows. It panics on a duplicate
+// name, so it is meant for init functions.
+func Register(p Profile) {
+	if _, dup := registry[p.Name]; dup {
+		panic(fmt.Sprintf("profile: %q registered twice", p.Name))
+	}
+	registry[p.Name] = p
+}
+
+// Lookup returns the profile called name; "" is Default.
+func Lookup(name string) (Profile, bool) {
+	if name == "" {
+		name = Default
+	}
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Names lists the registered profiles, sorted.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,30 @@
+package scan_test
+
+import (
+	"os"
+	"testing"
+
+	"bugsbyte/flight-info/scan"
+)
+
+// benchImages are the image fixtures in testdata/bench, from a small
+// screenshot to a phone photo.
+var benchImages = []string{"aztec-512.png", "qr-1024.png", "aztec-2048.png", "aztec-photo-2016x1512.jpg"}
+
+func BenchmarkDecode(b *testing.B) {
+	for _, name := range benchImages {
+		img, err := os.ReadFile("../testdata/bench/" + name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(img)))
+			for b.Loop() {
+				if _, _, err := scan.Decode(img); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+// Package scan finds and decodes barcodes in photos and screenshots.
+package scan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"slices"
+	"strings"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/aztec"
+	"github.com/makiuchi-d/gozxing/datamatrix"
+	"github.com/makiuchi-d/gozxing/oned"
+	"github.com/makiuchi-d/gozxing/qrcode"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+)
+
+// ----------------------
+// LOGIC: BARCODE IMAGE DECODING
+// ----------------------
+
+// Input limits: MaxImageBytes of encoded data, MaxImagePixels once decoded
+// (guards against decompression bombs).
+const (
+	MaxImageBytes  = 10 << 20
+	MaxImagePixels = 40_000_000
+)
+
+// ErrNoBarcode is returned by Decode when no reader finds a barcode. An
+// image that also fails a quality check gets a QualityError instead, and
+// one that looks like a Wallet screenshot ErrWalletScreenshot.
+var ErrNoBarcode = errors.New("no barcode found in image")
+
+// ErrImageTooLarge is returned by Decode for images over MaxImagePixels.
+// Any other error means the data isn't a decodable image.
+var ErrImageTooLarge = errors.New("image too large")
+
+// imageReaders are the readers there are, in the order the default
+// profile tries them; Aztec first since that is what most airline apps
+// render. gozxing has no PDF417 reader, so printed PDF417 passes still
+// need the Python backend (or the text endpoint).
+var imageReaders = []imageReader{
+	{"AZTEC", func() gozxing.Reader { return aztec.NewAztecReader() }},
+	{"QR_CODE", qrcode.NewQRCodeReader},
+	{"DATA_MATRIX", func() gozxing.Reader { return datamatrix.NewDataMatrixReader() }},
+	{"CODE_128", oned.NewCode128Reader},
+	// Bag tags: tried last, so it never gets a say on a boarding pass.
+	{"ITF", oned.NewITFReader},
+}
+
+// imageReader is one barcode format. Readers keep per-decode state, so
+// each request builds its own.
+type imageReader struct {
+	format    string
+	newReader func() gozxing.Reader
+}
+
+// Formats lists the formats of imageReaders, in the order the default
+// profile tries them.
+func Formats() []string {
+	formats := make([]string, len(imageReaders))
+	for i, r := range imageReaders {
+		formats[i] = r.format
+	}
+	return formats
+}
+
+// imageContainers are the image files uploads come as, with the bytes they
+// start with. Not all of them have a decoder: phones save HEIC, and TIFF
+// comes from scanners.
+var imageContainers = []struct{ name, magic string }{
+	{"png", "\x89PNG\r\n\x1a\n"},
+	{"jpeg", "\xff\xd8\xff"},
+	{"gif", "GIF89a"},
+	{"bmp", "BM\x00\x00\x00\x00\x00\x00\x00\x00"},
+	{"webp", "RIFF\x00\x00\x00\x00WEBPVP8 "},
+	{"tiff", "II*\x00"},
+	{"heic", "\x00\x00\x00\x18ftypheic"},
+}
+
+// ImageFormats lists the containers of imageContainers Decode reads, by
+// asking the image package whether a decoder is registered for them.
+func ImageFormats() []string {
+	var formats []string
+	for _, c := range imageContainers {
+		// The header alone is truncated, so a registered decoder fails too,
+		// but not with ErrFormat.
+		if _, _, err := image.DecodeConfig(strings.NewReader(c.magic)); !errors.Is(err, image.ErrFormat) {
+			formats = append(formats, c.name)
+		}
+	}
+	return formats
+}
+
+// Decode finds the best barcode (see pickBest) in an encoded PNG, JPEG,
+// GIF, BMP or WebP image and returns its text and format name (AZTEC,
+// QR_CODE, DATA_MATRIX, CODE_128 or ITF).
+func Decode(data []byte) (text, format string, err error) {
+	return DecodeContext(context.Background(), data)
+}
+
+// tracer is the global OpenTelemetry tracer, a no-op unless the program
+// installs a provider.
+var tracer = otel.Tracer("bugsbyte/flight-info/scan")
+
+// DecodeContext is Decode, recording "decode image" and "binarize+read"
+// spans under the span in ctx.
+func DecodeContext(ctx context.Context, data []byte) (text, format string, err error) {
+	res, err := DecodeResult(ctx, data)
+	if err != nil {
+		return "", "", err
+	}
+	return res.Text, res.Format, nil
+}
+
+// Result is the barcode DecodeResult picked.
+type Result struct {
+	Candidate
+	// Rejected are the other barcodes the readers found, best first.
+	Rejected []Candidate
+	// Bounds is the barcode's bounding box, from the points the reader
+	// located, and Image the image's.
+	Bounds, Image image.Rectangle
+	// WalletScreenshot is set when the image looks like a screenshot of
+	// an Apple Wallet pass (see walletScreenshot).
+	WalletScreenshot bool
+	// Profile names the DecodeProfile the image was read with.
+	Profile string
+}
+
+// DecodeResult is DecodeContext returning where the barcode was found and
+// which others were passed over. It stops reading when ctx is done and
+// settles for the best barcode found by then.
+func DecodeResult(ctx context.Context, data []byte) (*Result, error) {
+	prof, _ := LookupDecodeProfile(DefaultDecodeProfile)
+	return DecodeWith(ctx, data, prof)
+}
+
+// DecodeWith is DecodeResult reading the way prof says, within its
+// budget.
+func DecodeWith(ctx context.Context, data []byte, prof DecodeProfile) (*Result, error) {
+	img, err := decodeImage(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	_, span := tracer.Start(ctx, "binarize+read", trace.WithAttributes(attribute.String("barcode.decode_profile", prof.Name)))
+	defer span.End()
+	if prof.Budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, prof.Budget)
+		defer cancel()
+	}
+	var found []*Result
+	for i, l := range luminances(img) {
+		if ctx.Err() != nil || (i > 0 && !prof.Retries) {
+			break
+		}
+		found = readAll(ctx, prof, gozxing.NewLuminanceSourceFromImage(l.image()), l.name, img.Bounds())
+		if len(found) > 0 {
+			break
+		}
+	}
+	if len(found) == 0 && prof.Retries && ctx.Err() == nil {
+		found = readPadded(ctx, prof, img)
+	}
+	if len(found) > 0 {
+		res := pickBest(found)
+		res.WalletScreenshot = walletScreenshot(res.Image, res.Bounds)
+		res.Profile = prof.Name
+		span.SetAttributes(
+			attribute.String("barcode.reader", res.Format),
+			attribute.String("barcode.binarizer", res.Binarizer),
+			attribute.String("barcode.luminance", res.Luminance),
+			attribute.String("barcode.padding", res.Padding),
+			attribute.Int("barcode.candidates", len(found)))
+		return res, nil
+	}
+	err = ErrNoBarcode
+	if walletAspect(img.Bounds()) {
+		err = ErrWalletScreenshot
+	} else if qerr := checkQuality(img); qerr != nil {
+		err = qerr
+	}
+	span.SetStatus(codes.Error, err.Error())
+	return nil, err
+}
+
+// readAll runs each of prof's readers after each of its binarizers over
+// source, and returns what they found, deduplicated. It stops early at a
+// conclusive decode or when ctx is done.
+func readAll(ctx context.Context, prof DecodeProfile, source gozxing.LuminanceSource, lum string, bounds image.Rectangle) []*Result {
+	hints := map[gozxing.DecodeHintType]interface{}{}
+	if prof.TryHarder {
+		hints[gozxing.DecodeHintType_TRY_HARDER] = true
+	}
+	readers := make([]gozxing.Reader, len(prof.Formats))
+	for i, f := range prof.Formats {
+		j := slices.IndexFunc(imageReaders, func(r imageReader) bool { return r.format == f })
+		readers[i] = imageReaders[j].newReader()
+	}
+	var found []*Result
+	for _, b := range prof.Binarizers {
+		bmp, err := gozxing.NewBinaryBitmap(binarizers[b](source))
+		if err != nil {
+			continue
+		}
+		for i, format := range prof.Formats {
+			if ctx.Err() != nil {
+				// Out of time: the best decode so far will do.
+				return found
+			}
+			result, err := readers[i].Decode(bmp, hints)
+			readers[i].Reset()
+			if err != nil || result.GetText() == "" {
+				continue
+			}
+			res := &Result{
+				Candidate: Candidate{Text: result.GetText(), Format: format, Binarizer: b, Luminance: lum},
+				Bounds:    pointsBounds(result.GetResultPoints()),
+				Image:     bounds.Sub(bounds.Min),
+			}
+			if slices.ContainsFunc(found, func(f *Result) bool { return f.Candidate.sameCode(res.Candidate) }) {
+				continue
+			}
+			found = append(found, res)
+			if conclusive(res.Candidate) {
+				return found
+			}
+		}
+	}
+	return found
+}
+
+// pointsBounds is the bounding box of the points a reader located: finder
+// patterns, corners, or the ends of a 1D barcode.
+func pointsBounds(points []gozxing.ResultPoint) image.Rectangle {
+	var r image.Rectangle
+	for i, p := range points {
+		pt := image.Rect(int(p.GetX()), int(p.GetY()), int(p.GetX())+1, int(p.GetY())+1)
+		if i == 0 {
+			r = pt
+			continue
+		}
+		r = r.Union(pt)
+	}
+	return r
+}
+
+// decodeImage decodes data after checking its dimensions against
+// MaxImagePixels.
+func decodeImage(ctx context.Context, data []byte) (image.Image, error) {
+	_, span := tracer.Start(ctx, "decode image", trace.WithAttributes(attribute.Int("image.bytes", len(data))))
+	defer span.End()
+
+	cfg, name, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		err = fmt.Errorf("could not decode image: %w", err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(
+		attribute.String("image.format", name),
+		attribute.Int("image.width", cfg.Width),
+		attribute.Int("image.height", cfg.Height))
+	if cfg.Width*cfg.Height > MaxImagePixels {
+		err = fmt.Errorf("%w: %dx%d", ErrImageTooLarge, cfg.Width, cfg.Height)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		err = fmt.Errorf("could not decode image: %w", err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return img, nil
+}
+
+// grayscale returns the luma plane of a JPEG as an image.Gray; other
+// images are returned as they are. gozxing reads pixels through At, which
+// allocates a color per pixel for YCbCr but not for Gray, so this takes a
+// phone photo from millions of allocations to one.
+func grayscale(img image.Image) image.Image {
+	ycc, ok := img.(*image.YCbCr)
+	if !ok {
+		return img
+	}
+	b := ycc.Rect
+	gray := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := ycc.Y[ycc.YOffset(b.Min.X, y):]
+		copy(gray.Pix[(y-b.Min.Y)*gray.Stride:][:b.Dx()], row[:b.Dx()])
+	}
+	return gray
+}
+
+// Weight is the cost of decoding the image: one unit per started 8
+// megapixels, so a few large photos count like many small screenshots.
+// Undecodable headers weigh 1.
+func Weight(data []byte) int64 {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 1
+	}
+	return 1 + int64(cfg.Width*cfg.Height)/8_000_000
+}
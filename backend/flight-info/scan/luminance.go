@@ -0,0 +1,123 @@
+package scan
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// ----------------------
+// LOGIC: COLOR MODELS
+// ----------------------
+
+// gozxing turns an image into luminance through At, with its own weights
+// ((R+2G+B)/4). That is fine for the 8-bit images phones produce, but
+// print workflows and scanners hand in others: CMYK JPEGs, 16-bit PNGs.
+// DecodeResult converts those to 8 bits first (eightBit), and when no
+// reader finds anything it retries with luminances of its own:
+//
+//	standard_weights  Rec. 601 weights (0.299R + 0.587G + 0.114B), which
+//	                  tell apart colors gozxing's weights make equal, such
+//	                  as blue modules on an orange background
+//	cmyk_uninverted   for CMYK JPEGs, the ink channels as stored. Go takes
+//	                  4-channel JPEGs to be inverted the way Adobe writes
+//	                  them; one from a writer that doesn't invert decodes
+//	                  as a near-black negative in which the code is lost
+//
+// Candidate.Luminance names the retry a barcode was read after; it is
+// empty for the first read.
+
+// Luminance retries, as in Candidate.Luminance.
+const (
+	LuminanceStandardWeights = "standard_weights"
+	LuminanceCMYKUninverted  = "cmyk_uninverted"
+)
+
+// luminance is one way of turning the image into what the binarizers see.
+// image is only called when the luminance is tried.
+type luminance struct {
+	name  string
+	image func() image.Image
+}
+
+// luminances lists the ways img is read, in order: img as it is (after
+// eightBit and grayscale), then the retries that apply to its color model.
+// Standard weights are no retry for gray and YCbCr images, whose luma
+// already uses them.
+func luminances(img image.Image) []luminance {
+	img8 := eightBit(img)
+	ls := []luminance{{"", func() image.Image { return grayscale(img8) }}}
+	switch img8.(type) {
+	case *image.Gray, *image.YCbCr:
+	default:
+		ls = append(ls, luminance{LuminanceStandardWeights, func() image.Image { return standardGray(img8) }})
+	}
+	if cmyk, ok := img.(*image.CMYK); ok {
+		ls = append(ls, luminance{LuminanceCMYKUninverted, func() image.Image { return uninvertedGray(cmyk) }})
+	}
+	return ls
+}
+
+// eightBit converts CMYK and 16-bit images to 8-bit NRGBA (Gray for
+// Gray16); other images are returned as they are. gozxing would read
+// them through At, allocating a 64-bit color per pixel.
+func eightBit(img image.Image) image.Image {
+	b := img.Bounds()
+	switch img := img.(type) {
+	case *image.Gray16:
+		gray := image.NewGray(b)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				gray.Pix[gray.PixOffset(x, y)] = img.Pix[img.PixOffset(x, y)] // high byte
+			}
+		}
+		return gray
+	case *image.CMYK, *image.RGBA64, *image.NRGBA64:
+		nrgba := image.NewNRGBA(b)
+		draw.Draw(nrgba, b, img, b.Min, draw.Src)
+		return nrgba
+	}
+	return img
+}
+
+// standardGray is img's Rec. 601 luma, composited over white like
+// gozxing does with transparency.
+func standardGray(img image.Image) *image.Gray {
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	nrgba, _ := img.(*image.NRGBA)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var c color.NRGBA
+			if nrgba != nil {
+				i := nrgba.PixOffset(x, y)
+				c = color.NRGBA{nrgba.Pix[i], nrgba.Pix[i+1], nrgba.Pix[i+2], nrgba.Pix[i+3]}
+			} else {
+				c = color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			}
+			gray.Pix[gray.PixOffset(x, y)] = rec601(c.R, c.G, c.B, c.A)
+		}
+	}
+	return gray
+}
+
+// uninvertedGray is the Rec. 601 luma of img with its ink channels
+// inverted back, which undoes the inversion Go applied when decoding.
+func uninvertedGray(img *image.CMYK) *image.Gray {
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			r, g, bl, _ := color.CMYK{255 - img.Pix[i], 255 - img.Pix[i+1], 255 - img.Pix[i+2], 255 - img.Pix[i+3]}.RGBA()
+			gray.Pix[gray.PixOffset(x, y)] = rec601(uint8(r>>8), uint8(g>>8), uint8(bl>>8), 0xff)
+		}
+	}
+	return gray
+}
+
+// rec601 is the luma of a non-premultiplied color over white.
+func rec601(r, g, b, a uint8) uint8 {
+	l := (299*uint32(r) + 587*uint32(g) + 114*uint32(b) + 500) / 1000
+	return uint8((l*uint32(a) + 255*(255-uint32(a))) / 255)
+}
@@ -0,0 +1,143 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/makiuchi-d/gozxing"
+)
+
+// ----------------------
+// LOGIC: DECODE PROFILES
+// ----------------------
+
+// The default read tries every reader after every binarizer, then the
+// color retries, because an upload can be anything. A deployment that
+// knows what it will see can do far less: a kiosk camera only ever sees
+// Aztec codes on phone screens, and each QR and 1D attempt on a frame
+// without a code is time the next frame waits for. A DecodeProfile names
+// one such plan. They are all listed in decodeProfiles and checked by
+// CheckDecodeProfiles when the server starts.
+
+// DefaultDecodeProfile is the profile DecodeResult reads with, the one
+// that tries everything.
+const DefaultDecodeProfile = "generic"
+
+// Binarizers, as in Candidate.Binarizer and DecodeProfile.Binarizers.
+const (
+	BinarizerHybrid          = "hybrid"
+	BinarizerGlobalHistogram = "global_histogram"
+)
+
+// binarizers turn luminance into black and white. The hybrid binarizer
+// copes with uneven lighting in photos; the global one does better on
+// clean screenshots and scans.
+var binarizers = map[string]func(gozxing.LuminanceSource) gozxing.Binarizer{
+	BinarizerHybrid:          gozxing.NewHybridBinarizer,
+	BinarizerGlobalHistogram: gozxing.NewGlobalHistgramBinarizer,
+}
+
+// DecodeProfile is how an image is read.
+type DecodeProfile struct {
+	Name        string
+	Description string
+	// Formats are the readers run (see Formats), in order.
+	Formats []string
+	// Binarizers are tried in order, each with every reader.
+	Binarizers []string
+	// Retries turns on the color retries (see luminances), then the
+	// padded ones (see readPadded), when the image as it is gives nothing.
+	Retries bool
+	// TryHarder is gozxing's TRY_HARDER: denser searches, and 1D readers
+	// also try the image turned 90 degrees.
+	TryHarder bool
+	// Budget caps the time spent reading, after which the best barcode
+	// found so far is taken. Zero leaves only the caller's deadline.
+	Budget time.Duration
+}
+
+// decodeProfiles are the profiles LookupDecodeProfile knows, in the order
+// DecodeProfiles lists them. gozxing has no PDF417 reader, so no profile
+// reads the PDF417 most printed passes carry.
+var decodeProfiles = []DecodeProfile{
+	{
+		Name:        DefaultDecodeProfile,
+		Description: "Every reader, both binarizers, the color and padding retries: for uploads that can be anything",
+		Formats:     []string{"AZTEC", "QR_CODE", "DATA_MATRIX", "CODE_128", "ITF"},
+		Binarizers:  []string{BinarizerHybrid, BinarizerGlobalHistogram},
+		Retries:     true,
+		TryHarder:   true,
+	},
+	{
+		Name:        "kiosk_aztec",
+		Description: "Aztec only, hybrid binarizer, no retries, 150ms: for kiosk cameras reading phone screens frame by frame",
+		Formats:     []string{"AZTEC"},
+		Binarizers:  []string{BinarizerHybrid},
+		Budget:      150 * time.Millisecond,
+	},
+	{
+		Name:        "print",
+		Description: "2D codes before bag tags, global binarizer first, color and padding retries: for scans and PDFs of printed passes",
+		Formats:     []string{"AZTEC", "DATA_MATRIX", "QR_CODE", "CODE_128", "ITF"},
+		Binarizers:  []string{BinarizerGlobalHistogram, BinarizerHybrid},
+		Retries:     true,
+		TryHarder:   true,
+	},
+}
+
+// DecodeProfiles lists the profile names, the default first.
+func DecodeProfiles() []string {
+	names := make([]string, len(decodeProfiles))
+	for i, p := range decodeProfiles {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// LookupDecodeProfile finds a profile by name; "" is the default.
+func LookupDecodeProfile(name string) (DecodeProfile, bool) {
+	if name == "" {
+		name = DefaultDecodeProfile
+	}
+	for _, p := range decodeProfiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return DecodeProfile{}, false
+}
+
+// CheckDecodeProfiles checks decodeProfiles: unique names, the default
+// first, and readers and binarizers that exist, each at most once.
+func CheckDecodeProfiles() error {
+	var errs []error
+	seen := map[string]bool{}
+	for i, p := range decodeProfiles {
+		if i == 0 && p.Name != DefaultDecodeProfile {
+			errs = append(errs, fmt.Errorf("decode profile %q is first, not %q", p.Name, DefaultDecodeProfile))
+		}
+		if p.Name == "" || seen[p.Name] {
+			errs = append(errs, fmt.Errorf("decode profile %d: name %q empty or taken", i, p.Name))
+		}
+		seen[p.Name] = true
+		if len(p.Formats) == 0 || len(p.Binarizers) == 0 {
+			errs = append(errs, fmt.Errorf("decode profile %q: no readers or no binarizers", p.Name))
+		}
+		for j, f := range p.Formats {
+			if !slices.Contains(Formats(), f) || slices.Index(p.Formats, f) != j {
+				errs = append(errs, fmt.Errorf("decode profile %q: reader %q unknown or repeated", p.Name, f))
+			}
+		}
+		for j, b := range p.Binarizers {
+			if binarizers[b] == nil || slices.Index(p.Binarizers, b) != j {
+				errs = append(errs, fmt.Errorf("decode profile %q: binarizer %q unknown or repeated", p.Name, b))
+			}
+		}
+		if p.Budget < 0 {
+			errs = append(errs, fmt.Errorf("decode profile %q: negative budget", p.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,113 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// ----------------------
+// LOGIC: IMAGE QUALITY
+// ----------------------
+
+// When no reader finds a barcode, DecodeContext checks whether the image
+// could have held a readable one at all, so the caller can ask for a
+// better photo instead of reporting a bare ErrNoBarcode. The checks only
+// run on that failure path.
+
+// Quality thresholds. The api package sets them from the environment;
+// zero turns a check off.
+var (
+	// MinImageSide is the smallest longer side, in pixels, of an image
+	// that can hold a readable barcode. The longer side, because 1D
+	// barcodes are legitimately cropped to a thin strip.
+	MinImageSide = 320
+	// MinSharpness is the lowest Sharpness of an image that can hold a
+	// readable barcode. Barcodes that fail to decode from blur score 3 to
+	// 50; sharp screenshots score in the thousands.
+	MinSharpness = 50.0
+)
+
+var (
+	// ErrImageTooSmall is in a QualityError for images under MinImageSide.
+	ErrImageTooSmall = errors.New("image too small to read a barcode")
+	// ErrImageBlurry is in a QualityError for images under MinSharpness.
+	ErrImageBlurry = errors.New("image too blurry to read a barcode")
+)
+
+// QualityError is returned by Decode instead of ErrNoBarcode when the
+// image failed a quality check. It matches both its Err and ErrNoBarcode
+// with errors.Is.
+type QualityError struct {
+	Err           error // ErrImageTooSmall or ErrImageBlurry
+	Width, Height int
+	Sharpness     float64 // only measured for ErrImageBlurry
+}
+
+func (e *QualityError) Error() string {
+	if e.Err == ErrImageBlurry {
+		return fmt.Sprintf("%v: sharpness %.1f, under %.1f", e.Err, e.Sharpness, MinSharpness)
+	}
+	return fmt.Sprintf("%v: %dx%d, under %d pixels", e.Err, e.Width, e.Height, MinImageSide)
+}
+
+func (e *QualityError) Unwrap() []error { return []error{e.Err, ErrNoBarcode} }
+
+// checkQuality returns a QualityError for an image too small or too blurry
+// to read, and nil otherwise.
+func checkQuality(img image.Image) error {
+	b := img.Bounds()
+	if MinImageSide > 0 && max(b.Dx(), b.Dy()) < MinImageSide {
+		return &QualityError{Err: ErrImageTooSmall, Width: b.Dx(), Height: b.Dy()}
+	}
+	if MinSharpness > 0 {
+		if s := Sharpness(img); s < MinSharpness {
+			return &QualityError{Err: ErrImageBlurry, Width: b.Dx(), Height: b.Dy(), Sharpness: s}
+		}
+	}
+	return nil
+}
+
+const (
+	// sharpnessRows caps how many rows Sharpness samples, which keeps it
+	// to a few milliseconds on a phone photo.
+	sharpnessRows = 256
+	// sharpnessScale is the image size, in pixels along the longer side,
+	// that Sharpness measures at: larger images are measured with a wider
+	// Laplacian, so a photo and its thumbnail score about the same.
+	sharpnessScale = 512
+)
+
+// Sharpness is the variance of the Laplacian of img's luma, a cheap blur
+// estimate: edges make it large, and blur or heavy JPEG smoothing make it
+// small. Rows are sampled evenly.
+func Sharpness(img image.Image) float64 {
+	img = grayscale(img)
+	b := img.Bounds()
+	if b.Dx() < 3 || b.Dy() < 3 {
+		return 0
+	}
+	luma := func(x, y int) float64 {
+		if g, ok := img.(*image.Gray); ok {
+			return float64(g.Pix[(y-b.Min.Y)*g.Stride+(x-b.Min.X)])
+		}
+		return float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+	}
+	d := max(1, max(b.Dx(), b.Dy())/sharpnessScale)
+	if b.Dx() <= 2*d || b.Dy() <= 2*d {
+		return 0
+	}
+	step := max(1, (b.Dy()-2*d)/sharpnessRows)
+	var n, sum, sumSq float64
+	for y := b.Min.Y + d; y < b.Max.Y-d; y += step {
+		for x := b.Min.X + d; x < b.Max.X-d; x++ {
+			l := luma(x-d, y) + luma(x+d, y) + luma(x, y-d) + luma(x, y+d) - 4*luma(x, y)
+			n++
+			sum += l
+			sumSq += l * l
+		}
+	}
+	mean := sum / n
+	return sumSq/n - mean*mean
+}
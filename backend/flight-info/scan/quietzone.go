@@ -0,0 +1,89 @@
+package scan
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/makiuchi-d/gozxing"
+)
+
+// ----------------------
+// LOGIC: QUIET ZONES
+// ----------------------
+
+// Barcodes are drawn with a quiet zone, a margin of background around the
+// symbol, and the readers look for it. Printed passes trimmed tight and
+// screenshots cropped to the code's edge have none. When every other read
+// fails, DecodeResult reads the image again centered on a border of
+// quietZoneShare of its smaller side:
+//
+//	white  for dark codes on a light background, the usual
+//	black  for light codes on a dark one; the padded image is read
+//	       inverted, so the readers see a dark code on white again
+//
+// Candidate.Padding names the border a barcode was read with; it is empty
+// when the image was read as it came.
+
+// Paddings, as in Candidate.Padding.
+const (
+	PaddingWhite = "white"
+	PaddingBlack = "black"
+)
+
+// quietZoneShare is the width of the added border, as a share of the
+// image's smaller side.
+const quietZoneShare = 0.08
+
+// paddings are the borders tried, in order.
+var paddings = []struct {
+	name   string
+	color  color.Gray
+	invert bool
+}{
+	{PaddingWhite, color.Gray{Y: 0xff}, false},
+	{PaddingBlack, color.Gray{Y: 0}, true},
+}
+
+// readPadded reads img, as DecodeResult first saw it, on each border of
+// paddings until a reader finds something. Bounds are given in img's
+// coordinates.
+func readPadded(ctx context.Context, prof DecodeProfile, img image.Image) []*Result {
+	gray := grayscale(eightBit(img))
+	b := img.Bounds()
+	border := int(float64(min(b.Dx(), b.Dy())) * quietZoneShare)
+	if border == 0 {
+		return nil
+	}
+	for _, p := range paddings {
+		if ctx.Err() != nil {
+			return nil
+		}
+		var source gozxing.LuminanceSource = gozxing.NewLuminanceSourceFromImage(pad(gray, border, p.color))
+		if p.invert {
+			source = source.Invert()
+		}
+		found := readAll(ctx, prof, source, "", b)
+		for _, f := range found {
+			f.Padding = p.name
+			f.Bounds = f.Bounds.Sub(image.Pt(border, border)).Intersect(f.Image)
+		}
+		if len(found) > 0 {
+			return found
+		}
+	}
+	return nil
+}
+
+// pad returns img centered on a border of width n and color c, with
+// transparency composited over white like gozxing does.
+func pad(img image.Image, n int, c color.Gray) *image.Gray {
+	b := img.Bounds()
+	out := image.NewGray(image.Rect(0, 0, b.Dx()+2*n, b.Dy()+2*n))
+	inner := image.Rect(n, n, n+b.Dx(), n+b.Dy())
+	draw.Draw(out, out.Bounds(), &image.Uniform{C: c}, image.Point{}, draw.Src)
+	draw.Draw(out, inner, image.White, image.Point{}, draw.Src)
+	draw.Draw(out, inner, img, b.Min, draw.Over)
+	return out
+}
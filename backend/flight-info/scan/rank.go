@@ -0,0 +1,96 @@
+package scan
+
+import (
+	"cmp"
+	"slices"
+
+	"bugsbyte/flight-info/bagtag"
+	"bugsbyte/flight-info/bcbp"
+	"bugsbyte/flight-info/eticket"
+)
+
+// ----------------------
+// LOGIC: RANKING DECODES
+// ----------------------
+
+// The first reader to succeed isn't always right: on a noisy photo Code
+// 128 can "read" a short string out of the texture, and a page can carry
+// a promotional QR code next to the boarding pass. DecodeResult therefore
+// runs every reader after every binarizer, and ranks what they find:
+//
+//  1. text that parses as BCBP, then a bag tag license plate or an
+//     e-ticket number, then anything
+//  2. longer text
+//  3. 2D formats over 1D ones
+//
+// ties going to the reader that ran first. A BCBP decode from a 2D reader
+// ends the search early: only a second boarding pass in the same image
+// could outrank it.
+
+// Candidate is one barcode a reader found.
+type Candidate struct {
+	Text      string `json:"text"`
+	Format    string `json:"format"`
+	Binarizer string `json:"binarizer"`
+	// Luminance is the retry the barcode was read after (see luminances),
+	// "" for the first read.
+	Luminance string `json:"luminance,omitempty"`
+	// Padding is the border the image was read on (see readPadded), ""
+	// when it was read as it came.
+	Padding string `json:"padding,omitempty"`
+}
+
+// sameCode reports whether c and o are the same barcode, read after
+// different binarizers.
+func (c Candidate) sameCode(o Candidate) bool {
+	return c.Text == o.Text && c.Format == o.Format
+}
+
+// oneD are the 1D formats among the imageReaders.
+var oneD = []string{"CODE_128", "ITF"}
+
+// rank is what candidates are compared on, most significant first.
+func rank(c Candidate) [3]int {
+	kind := 0
+	if _, ok := bagtag.Parse(c.Text); ok {
+		kind = 1
+	} else if _, ok := eticket.Parse(c.Text); ok {
+		kind = 1
+	} else if _, err := bcbp.Parse(c.Text); err == nil {
+		kind = 2
+	}
+	dim := 2
+	if slices.Contains(oneD, c.Format) {
+		dim = 1
+	}
+	return [3]int{kind, len(c.Text), dim}
+}
+
+// conclusive reports whether c can't reasonably be outranked.
+func conclusive(c Candidate) bool {
+	r := rank(c)
+	return r[0] == 2 && r[2] == 2
+}
+
+// pickBest returns the best of found, in the order they were found, with
+// the others as its Rejected.
+func pickBest(found []*Result) *Result {
+	ranks := make(map[*Result][3]int, len(found))
+	for _, f := range found {
+		ranks[f] = rank(f.Candidate)
+	}
+	slices.SortStableFunc(found, func(a, b *Result) int {
+		ra, rb := ranks[a], ranks[b]
+		for i := range ra {
+			if c := cmp.Compare(rb[i], ra[i]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	best := found[0]
+	for _, f := range found[1:] {
+		best.Rejected = append(best.Rejected, f.Candidate)
+	}
+	return best
+}
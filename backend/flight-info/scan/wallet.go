@@ -0,0 +1,56 @@
+package scan
+
+import (
+	"fmt"
+	"image"
+)
+
+// ----------------------
+// LOGIC: WALLET SCREENSHOTS
+// ----------------------
+
+// Travelers often screenshot their Apple Wallet pass instead of sharing
+// the .pkpass. Such a screenshot is a portrait phone screen with the pass's
+// barcode centered in its lower part. It decodes like any other image, but
+// callers tag it so input channels can be told apart, and when it holds
+// no readable barcode, the better answer is to ask for the pass file.
+
+// ErrWalletScreenshot is returned by Decode instead of ErrNoBarcode for an
+// image shaped like a phone screenshot, most likely of a Wallet pass. It
+// matches ErrNoBarcode with errors.Is.
+var ErrWalletScreenshot = fmt.Errorf("%w, and it looks like an Apple Wallet screenshot", ErrNoBarcode)
+
+// Phone screens in portrait are 16:9 to about 20:9; photos and scans are
+// far squarer.
+const (
+	walletMinAspect = 1.7
+	walletMaxAspect = 2.4
+)
+
+// walletAspect reports whether b has the shape of a portrait phone screen.
+func walletAspect(b image.Rectangle) bool {
+	if b.Dx() == 0 {
+		return false
+	}
+	aspect := float64(b.Dy()) / float64(b.Dx())
+	return aspect >= walletMinAspect && aspect <= walletMaxAspect
+}
+
+// walletScreenshot reports whether a barcode at code in an image of bounds
+// img sits where Wallet draws it: horizontally centered (within a tenth of
+// the width) in the lower third of a portrait phone screen.
+func walletScreenshot(img, code image.Rectangle) bool {
+	if !walletAspect(img) || code.Empty() {
+		return false
+	}
+	cx := (code.Min.X + code.Max.X) / 2
+	cy := (code.Min.Y + code.Max.Y) / 2
+	return abs(cx-img.Dx()/2)*10 <= img.Dx() && cy*3 >= img.Dy()*2
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ----------------------
+// MIGRATIONS
+// ----------------------
+
+// Each backend has its own migrations in migrations/<dialect>, one file per
+// version, named NNNN_what.sql and applied in order, each in its own
+// transaction with the version it brings the schema to. A version may also
+// have a Go step (dialect.steps), run after its SQL, for changes SQL can't
+// make, like re-deriving columns from the stored JSON. Migrations are never
+// edited once released: a change to the schema is a new version.
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+	step    func(tx *sql.Tx) error
+}
+
+// migrations reads d's migrations, checking their versions run 1, 2, 3...
+// without gaps.
+func (d *dialect) migrations() ([]migration, error) {
+	dir := path.Join("migrations", d.name)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+	var ms []migration
+	for _, e := range entries {
+		num, name, ok := strings.Cut(strings.TrimSuffix(e.Name(), ".sql"), "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || !strings.HasSuffix(e.Name(), ".sql") {
+			return nil, fmt.Errorf("migration %s: name is not NNNN_what.sql", e.Name())
+		}
+		if version != len(ms)+1 {
+			return nil, fmt.Errorf("migration %s: expected version %d", e.Name(), len(ms)+1)
+		}
+		b, err := migrationFiles.ReadFile(path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, migration{version: version, name: name, sql: string(b), step: d.steps[version]})
+	}
+	return ms, nil
+}
+
+// migrate brings db's schema up to date. The version is read, under the
+// dialect's lock, in the transaction that applies the next migration, so
+// servers starting together against one database apply each only once.
+func (d *dialect) migrate(db *sql.DB) error {
+	ms, err := d.migrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range ms {
+		if err := d.apply(db, m, len(ms)); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+func (d *dialect) apply(db *sql.DB, m migration, latest int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if d.lock != nil {
+		if err := d.lock(tx); err != nil {
+			return err
+		}
+	}
+	version, err := d.version(tx)
+	if err != nil {
+		return err
+	}
+	if version > latest {
+		return fmt.Errorf("database is at schema version %d, newer than this server's %d", version, latest)
+	}
+	if version >= m.version {
+		return nil
+	}
+
+	if _, err := tx.Exec(m.sql); err != nil {
+		return err
+	}
+	if m.step != nil {
+		if err := m.step(tx); err != nil {
+			return err
+		}
+	}
+	if err := d.setVersion(tx, m.version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,71 @@
+-- The schema SQLite reached in ten migrations, in one. Timestamps are Unix
+-- milliseconds (parse_stats.hour: seconds), as on SQLite.
+CREATE TABLE passes (
+	id                   TEXT PRIMARY KEY,
+	source               TEXT NOT NULL,
+	data                 TEXT NOT NULL,
+	created_at           BIGINT NOT NULL,
+	updated_at           BIGINT NOT NULL,
+	passenger            TEXT NOT NULL DEFAULT '',
+	pnr                  TEXT NOT NULL DEFAULT '',
+	flight_number        TEXT NOT NULL DEFAULT '',
+	departure            TEXT NOT NULL DEFAULT '',
+	arrival              TEXT NOT NULL DEFAULT '',
+	date_iso             TEXT NOT NULL DEFAULT '',
+	artifact             TEXT NOT NULL DEFAULT '',
+	artifact_type        TEXT NOT NULL DEFAULT '',
+	web_service          TEXT NOT NULL DEFAULT '',
+	web_service_modified TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX idx_passes_created_at ON passes(created_at DESC);
+CREATE INDEX idx_passes_pnr ON passes(pnr);
+CREATE INDEX idx_passes_flight ON passes(flight_number);
+CREATE INDEX idx_passes_departure ON passes(departure, date_iso);
+CREATE INDEX idx_passes_arrival ON passes(arrival, date_iso);
+CREATE INDEX idx_passes_date ON passes(date_iso);
+CREATE INDEX idx_passes_source ON passes(source);
+
+CREATE TABLE notifications (
+	id           BIGSERIAL PRIMARY KEY,
+	pass_id      TEXT NOT NULL,
+	token        TEXT NOT NULL,
+	lead_seconds BIGINT NOT NULL,
+	send_at      BIGINT NOT NULL,
+	claimed_at   BIGINT,
+	sent_at      BIGINT,
+	error        TEXT NOT NULL DEFAULT '',
+	created_at   BIGINT NOT NULL,
+	UNIQUE (pass_id, token, lead_seconds)
+);
+CREATE INDEX idx_notifications_due ON notifications(send_at) WHERE claimed_at IS NULL;
+
+CREATE TABLE pass_clients (
+	client_id  TEXT NOT NULL,
+	pass_id    TEXT NOT NULL,
+	scanned_at BIGINT NOT NULL,
+	PRIMARY KEY (client_id, pass_id)
+);
+CREATE INDEX idx_pass_clients_pass ON pass_clients(pass_id);
+
+CREATE TABLE parse_stats (
+	hour    BIGINT NOT NULL,
+	source  TEXT NOT NULL,
+	carrier TEXT NOT NULL,
+	error   TEXT NOT NULL,
+	count   BIGINT NOT NULL,
+	PRIMARY KEY (hour, source, carrier, error)
+);
+
+CREATE TABLE imports (
+	sum         TEXT PRIMARY KEY,
+	pass_id     TEXT NOT NULL,
+	imported_at BIGINT NOT NULL
+);
+
+CREATE TABLE pass_history (
+	id         BIGSERIAL PRIMARY KEY,
+	pass_id    TEXT NOT NULL,
+	changed_at BIGINT NOT NULL,
+	changes    TEXT NOT NULL
+);
+CREATE INDEX idx_pass_history_pass ON pass_history(pass_id, id);
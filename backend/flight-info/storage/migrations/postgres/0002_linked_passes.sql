@@ -0,0 +1,2 @@
+-- The pass each lap infant's pass is linked with, and back.
+ALTER TABLE passes ADD COLUMN linked_pass_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS passes (
+	id         TEXT PRIMARY KEY,
+	source     TEXT NOT NULL,
+	data       TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_passes_created_at ON passes(created_at DESC);
@@ -0,0 +1,13 @@
+-- Searchable columns, backfilled from the stored JSON (backfillPassColumns).
+ALTER TABLE passes ADD COLUMN passenger TEXT NOT NULL DEFAULT '';
+ALTER TABLE passes ADD COLUMN pnr TEXT NOT NULL DEFAULT '';
+ALTER TABLE passes ADD COLUMN flight_number TEXT NOT NULL DEFAULT '';
+ALTER TABLE passes ADD COLUMN departure TEXT NOT NULL DEFAULT '';
+ALTER TABLE passes ADD COLUMN arrival TEXT NOT NULL DEFAULT '';
+ALTER TABLE passes ADD COLUMN date_iso TEXT NOT NULL DEFAULT '';
+CREATE INDEX idx_passes_pnr ON passes(pnr);
+CREATE INDEX idx_passes_flight ON passes(flight_number);
+CREATE INDEX idx_passes_departure ON passes(departure, date_iso);
+CREATE INDEX idx_passes_arrival ON passes(arrival, date_iso);
+CREATE INDEX idx_passes_date ON passes(date_iso);
+CREATE INDEX idx_passes_source ON passes(source);
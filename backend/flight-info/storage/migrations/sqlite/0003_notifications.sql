@@ -0,0 +1,14 @@
+-- Push notification registrations.
+CREATE TABLE notifications (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	pass_id      TEXT NOT NULL,
+	token        TEXT NOT NULL,
+	lead_seconds INTEGER NOT NULL,
+	send_at      INTEGER NOT NULL,
+	claimed_at   INTEGER,
+	sent_at      INTEGER,
+	error        TEXT NOT NULL DEFAULT '',
+	created_at   INTEGER NOT NULL,
+	UNIQUE (pass_id, token, lead_seconds)
+);
+CREATE INDEX idx_notifications_due ON notifications(send_at) WHERE claimed_at IS NULL;
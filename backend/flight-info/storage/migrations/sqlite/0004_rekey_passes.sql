@@ -0,0 +1,3 @@
+-- IDs now include the carrier and normalize the flight and name
+-- (bcbp.StableID); rekeyPasses re-keys the stored passes so re-scans still
+-- match.
@@ -0,0 +1,3 @@
+-- The upload each pass was parsed from.
+ALTER TABLE passes ADD COLUMN artifact TEXT NOT NULL DEFAULT '';
+ALTER TABLE passes ADD COLUMN artifact_type TEXT NOT NULL DEFAULT '';
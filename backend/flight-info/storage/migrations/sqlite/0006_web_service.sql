@@ -0,0 +1,4 @@
+-- The sealed web service of Wallet passes, and the Last-Modified of the
+-- version stored.
+ALTER TABLE passes ADD COLUMN web_service TEXT NOT NULL DEFAULT '';
+ALTER TABLE passes ADD COLUMN web_service_modified TEXT NOT NULL DEFAULT '';
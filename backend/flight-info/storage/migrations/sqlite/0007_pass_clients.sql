@@ -0,0 +1,8 @@
+-- The devices that scanned each pass.
+CREATE TABLE pass_clients (
+	client_id  TEXT NOT NULL,
+	pass_id    TEXT NOT NULL,
+	scanned_at INTEGER NOT NULL,
+	PRIMARY KEY (client_id, pass_id)
+);
+CREATE INDEX idx_pass_clients_pass ON pass_clients(pass_id);
@@ -0,0 +1,9 @@
+-- Parse counts by hour.
+CREATE TABLE parse_stats (
+	hour    INTEGER NOT NULL,
+	source  TEXT NOT NULL,
+	carrier TEXT NOT NULL,
+	error   TEXT NOT NULL,
+	count   INTEGER NOT NULL,
+	PRIMARY KEY (hour, source, carrier, error)
+);
@@ -0,0 +1,6 @@
+-- Content already imported.
+CREATE TABLE imports (
+	sum         TEXT PRIMARY KEY,
+	pass_id     TEXT NOT NULL,
+	imported_at INTEGER NOT NULL
+);
@@ -0,0 +1,8 @@
+-- What each update of a pass changed.
+CREATE TABLE pass_history (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	pass_id    TEXT NOT NULL,
+	changed_at INTEGER NOT NULL,
+	changes    TEXT NOT NULL
+);
+CREATE INDEX idx_pass_history_pass ON pass_history(pass_id, id);
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// ----------------------
+// POSTGRES
+// ----------------------
+
+// Postgres runs on the pgx driver, which is only linked into builds with
+// the postgres tag (see postgres_pgx.go), so SQLite-only builds don't carry
+// it. The schema version is the highest row of schema_migrations; a
+// transaction-scoped advisory lock serializes servers migrating together.
+
+// postgresLockKey is the advisory lock migrations hold, any fixed number
+// other applications sharing the database don't use.
+const postgresLockKey = 0x666c696e66 // "flinf"
+
+var postgres = &dialect{
+	name:      "postgres",
+	numbered:  true,
+	forUpdate: ` FOR UPDATE`,
+	lock: func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, postgresLockKey); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version    INTEGER PRIMARY KEY,
+				applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+			)`)
+		return err
+	},
+	version: func(tx *sql.Tx) (int, error) {
+		var v int
+		err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&v)
+		return v, err
+	},
+	setVersion: func(tx *sql.Tx, v int) error {
+		_, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, v)
+		return err
+	},
+}
+
+func openPostgres(url string) (Store, error) {
+	if !slices.Contains(sql.Drivers(), "pgx") {
+		return nil, fmt.Errorf("postgres: this server was built without the Postgres driver (build with -tags postgres)")
+	}
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connecting: %w", err)
+	}
+	if err := postgres.migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating schema: %w", err)
+	}
+	return &sqlStore{db: db, dialect: postgres}, nil
+}
@@ -0,0 +1,6 @@
+//go:build postgres
+
+package storage
+
+// Registers the "pgx" database/sql driver openPostgres uses.
+import _ "github.com/jackc/pgx/v5/stdlib"
@@ -0,0 +1,54 @@
+//go:build postgres
+
+package storage
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"testing"
+)
+
+// TestPostgresStore runs the Store contract against the database
+// TEST_DATABASE_URL names, each store in a schema of its own that is
+// dropped afterwards:
+//
+//	TEST_DATABASE_URL=postgres://postgres@localhost/flightinfo_test go test -tags postgres ./storage
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	testStore(t, func(t *testing.T) Store {
+		t.Helper()
+		b := make([]byte, 6)
+		rand.Read(b)
+		schema := "store_test_" + hex.EncodeToString(b)
+		if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+		u, err := url.Parse(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		s, err := Open(u.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
@@ -0,0 +1,947 @@
+package storage
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// ----------------------
+// SQL STORE
+// ----------------------
+
+// sqlStore is Store on database/sql. Queries are written once, with ?
+// placeholders, in SQL both backends accept; the dialect rewrites the
+// placeholders for Postgres.
+type sqlStore struct {
+	db      *sql.DB
+	dialect *dialect
+}
+
+// dialect is what differs between the backends.
+type dialect struct {
+	name string // also the directory of its migrations
+	// numbered is set for $1, $2... placeholders instead of ?.
+	numbered bool
+	// forUpdate is appended to a SELECT to lock the rows it reads until the
+	// transaction ends. SQLite has no row locks; its transactions take the
+	// write lock when they begin instead.
+	forUpdate string
+
+	// steps are the Go steps of migrations, by version.
+	steps map[int]func(tx *sql.Tx) error
+	// lock, when set, is taken at the start of each migration's
+	// transaction, before the version is read.
+	lock       func(tx *sql.Tx) error
+	version    func(tx *sql.Tx) (int, error)
+	setVersion func(tx *sql.Tx, v int) error
+}
+
+// q rewrites query's placeholders for the dialect.
+func (s *sqlStore) q(query string) string {
+	if !s.dialect.numbered {
+		return query
+	}
+	var b strings.Builder
+	n, quoted := 0, false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// cleanupBatch is how many passes one cleanup transaction deletes. Each batch
+// releases its connection, so API requests queued behind it (all of them,
+// on SQLite's single connection) run between batches.
+const cleanupBatch = 100
+
+// eachBatch is how many rows Each reads per query.
+const eachBatch = 200
+
+// storedPassColumns are the columns scanStoredPass reads, in order.
+const storedPassColumns = `id, source, data, created_at, updated_at, artifact, linked_pass_id`
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+type passColumns struct {
+	passenger, pnr, flight, departure, arrival, dateISO string
+}
+
+// searchColumns normalizes the filterable fields the same way the filters
+// are normalized, so lookups can use plain equality on indexed columns.
+func searchColumns(p *bcbp.UnifiedBoardingPass) passColumns {
+	norm := func(s string) string { return strings.ToUpper(strings.TrimSpace(s)) }
+	return passColumns{
+		passenger: norm(p.PassengerName),
+		pnr:       norm(p.PNR),
+		flight:    norm(p.FlightNumber),
+		departure: norm(p.Departure),
+		arrival:   norm(p.Arrival),
+		dateISO:   p.DateISO,
+	}
+}
+
+// marshalForStorage encodes the pass without the per-response dedup flags
+// and changes.
+func marshalForStorage(p *bcbp.UnifiedBoardingPass) ([]byte, error) {
+	clean := *p
+	clean.Duplicate = false
+	clean.Updated = false
+	clean.Changes = nil
+	return json.Marshal(&clean)
+}
+
+// upsertAttempts bounds how often Upsert starts over after losing the race
+// to insert a new pass.
+const upsertAttempts = 3
+
+// errUpsertRace is returned by upsertOnce when another transaction inserted
+// the pass after it found none.
+var errUpsertRace = errors.New("pass inserted concurrently")
+
+// Upsert reads the stored pass and writes the new one in one transaction,
+// holding the row (or, on SQLite, the database) from the read on, so the
+// changes are against the version this write replaces. Postgres can't lock a
+// row that doesn't exist yet: when there is none, the pass is inserted only
+// if it is still absent, and Upsert starts over otherwise.
+func (s *sqlStore) Upsert(p *bcbp.UnifiedBoardingPass) (sp *StoredPass, changes []bcbp.FieldChange, err error) {
+	data, err := marshalForStorage(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	for range upsertAttempts {
+		sp, changes, err = s.upsertOnce(p, data)
+		if !errors.Is(err, errUpsertRace) {
+			break
+		}
+	}
+	return sp, changes, err
+}
+
+func (s *sqlStore) upsertOnce(p *bcbp.UnifiedBoardingPass, data []byte) (sp *StoredPass, changes []bcbp.FieldChange, err error) {
+	id := ID(p)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var stored string
+	err = tx.QueryRow(s.q(`SELECT data FROM passes WHERE id = ?`+s.dialect.forUpdate), id).Scan(&stored)
+	exists := err == nil
+	switch {
+	case exists:
+		old := &bcbp.UnifiedBoardingPass{}
+		if err := json.Unmarshal([]byte(stored), old); err != nil {
+			return nil, nil, fmt.Errorf("decoding stored pass %s: %w", id, err)
+		}
+		changes = bcbp.Diff(old, p)
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, nil, err
+	}
+
+	onConflict := `DO NOTHING`
+	if exists {
+		onConflict = `DO UPDATE SET
+			source = excluded.source,
+			data = excluded.data,
+			updated_at = excluded.updated_at,
+			passenger = excluded.passenger,
+			pnr = excluded.pnr,
+			flight_number = excluded.flight_number,
+			departure = excluded.departure,
+			arrival = excluded.arrival,
+			date_iso = excluded.date_iso`
+	}
+	c := searchColumns(p)
+	now := time.Now().UTC().UnixMilli()
+	res, err := tx.Exec(s.q(`
+		INSERT INTO passes (id, source, data, created_at, updated_at,
+			passenger, pnr, flight_number, departure, arrival, date_iso)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) `+onConflict),
+		id, string(p.Source), string(data), now, now,
+		c.passenger, c.pnr, c.flight, c.departure, c.arrival, c.dateISO)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exists {
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, nil, err
+		}
+		if n == 0 {
+			return nil, nil, errUpsertRace
+		}
+	}
+	if len(changes) > 0 {
+		if err := s.addRevision(tx, id, now, changes); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := s.linkInfants(tx, c.pnr); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	sp, err = s.Get(id)
+	return sp, changes, err
+}
+
+func (s *sqlStore) InsertIfAbsent(p *bcbp.UnifiedBoardingPass) (sp *StoredPass, inserted bool, err error) {
+	id := ID(p)
+
+	data, err := marshalForStorage(p)
+	if err != nil {
+		return nil, false, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	c := searchColumns(p)
+	now := time.Now().UTC().UnixMilli()
+	res, err := tx.Exec(s.q(`
+		INSERT INTO passes (id, source, data, created_at, updated_at,
+			passenger, pnr, flight_number, departure, arrival, date_iso)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO NOTHING`),
+		id, string(p.Source), string(data), now, now,
+		c.passenger, c.pnr, c.flight, c.departure, c.arrival, c.dateISO)
+	if err != nil {
+		return nil, false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+	if n > 0 {
+		if err := s.linkInfants(tx, c.pnr); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	sp, err = s.Get(id)
+	return sp, n > 0, err
+}
+
+func (s *sqlStore) Get(id string) (*StoredPass, error) {
+	row := s.db.QueryRow(s.q(`SELECT `+storedPassColumns+` FROM passes WHERE id = ?`), id)
+	sp, err := scanStoredPass(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return sp, err
+}
+
+func (s *sqlStore) SetArtifact(id, sum, contentType string) error {
+	res, err := s.db.Exec(s.q(`UPDATE passes SET artifact = ?, artifact_type = ? WHERE id = ?`), sum, contentType, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return cmp.Or(err, ErrNotFound)
+	}
+	return nil
+}
+
+func (s *sqlStore) Artifact(id string) (sum, contentType string, err error) {
+	err = s.db.QueryRow(s.q(`SELECT artifact, artifact_type FROM passes WHERE id = ?`), id).Scan(&sum, &contentType)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrNotFound
+	}
+	return sum, contentType, err
+}
+
+func (s *sqlStore) SetWebService(id, sealed, modified string, replace bool) error {
+	res, err := s.db.Exec(s.q(`
+		UPDATE passes SET web_service = ?, web_service_modified = ?
+		WHERE id = ? AND (? OR web_service = '')`), sealed, modified, id, replace)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	// Nothing updated: either there's no such pass, or it kept its own.
+	_, err = s.Get(id)
+	return err
+}
+
+func (s *sqlStore) WebService(id string) (sealed, modified string, err error) {
+	err = s.db.QueryRow(s.q(`SELECT web_service, web_service_modified FROM passes WHERE id = ?`), id).Scan(&sealed, &modified)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrNotFound
+	}
+	return sealed, modified, err
+}
+
+func (s *sqlStore) ArtifactRefs() (map[string]bool, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT artifact FROM passes WHERE artifact != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	refs := map[string]bool{}
+	for rows.Next() {
+		var sum string
+		if err := rows.Scan(&sum); err != nil {
+			return nil, err
+		}
+		refs[sum] = true
+	}
+	return refs, rows.Err()
+}
+
+// where is the WHERE clause of f, "" when it matches everything, with its
+// arguments.
+func (f PassFilter) where() (string, []any) {
+	var (
+		clauses []string
+		args    []any
+	)
+	eq := func(col, v string) {
+		if v = strings.ToUpper(strings.TrimSpace(v)); v != "" {
+			clauses = append(clauses, col+" = ?")
+			args = append(args, v)
+		}
+	}
+	if v := strings.ToUpper(strings.TrimSpace(f.Passenger)); v != "" {
+		clauses = append(clauses, `passenger LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(v)+"%")
+	}
+	eq("pnr", f.PNR)
+	eq("flight_number", f.Flight)
+	eq("departure", f.Departure)
+	eq("arrival", f.Arrival)
+	if f.DateFrom != "" {
+		clauses = append(clauses, "date_iso != '' AND date_iso >= ?")
+		args = append(args, f.DateFrom)
+	}
+	if f.DateTo != "" {
+		clauses = append(clauses, "date_iso != '' AND date_iso <= ?")
+		args = append(args, f.DateTo)
+	}
+	if f.Source != "" {
+		clauses = append(clauses, "source = ?")
+		args = append(args, strings.ToLower(strings.TrimSpace(f.Source)))
+	}
+	if f.Client != "" {
+		clauses = append(clauses, "id IN (SELECT pass_id FROM pass_clients WHERE client_id = ?)")
+		args = append(args, f.Client)
+	}
+
+	if len(clauses) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(clauses, " AND "), args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *sqlStore) List(f PassFilter, limit, offset int) ([]*StoredPass, int, error) {
+	where, args := f.where()
+
+	var total int
+	if err := s.db.QueryRow(s.q(`SELECT COUNT(*) FROM passes`+where), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	passes, err := s.query(`
+		SELECT `+storedPassColumns+` FROM passes`+where+`
+		ORDER BY created_at DESC, id
+		LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	return passes, total, err
+}
+
+func (s *sqlStore) ListAll(f PassFilter) ([]*StoredPass, error) {
+	where, args := f.where()
+	return s.query(`
+		SELECT `+storedPassColumns+` FROM passes`+where+`
+		ORDER BY created_at DESC, id`, args...)
+}
+
+// Each reads eachBatch rows at a time, keyed on the last row seen.
+func (s *sqlStore) Each(f PassFilter, fn func(*StoredPass) error) error {
+	where, args := f.where()
+	if where == "" {
+		where = " WHERE TRUE"
+	}
+	var after *StoredPass
+	for {
+		page, pageArgs := where, args
+		if after != nil {
+			page += " AND (created_at < ? OR (created_at = ? AND id > ?))"
+			ms := after.CreatedAt.UnixMilli()
+			pageArgs = append(slices.Clone(args), ms, ms, after.ID)
+		}
+		passes, err := s.query(`
+			SELECT `+storedPassColumns+` FROM passes`+page+`
+			ORDER BY created_at DESC, id
+			LIMIT ?`, append(pageArgs, eachBatch)...)
+		if err != nil {
+			return err
+		}
+		for _, sp := range passes {
+			if err := fn(sp); err != nil {
+				return err
+			}
+		}
+		if len(passes) < eachBatch {
+			return nil
+		}
+		after = passes[len(passes)-1]
+	}
+}
+
+// query runs query, with ? placeholders, and reads the passes it selects.
+func (s *sqlStore) query(query string, args ...any) ([]*StoredPass, error) {
+	rows, err := s.db.Query(s.q(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passes := []*StoredPass{}
+	for rows.Next() {
+		sp, err := scanStoredPass(rows)
+		if err != nil {
+			return nil, err
+		}
+		passes = append(passes, sp)
+	}
+	return passes, rows.Err()
+}
+
+func (s *sqlStore) Delete(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(s.q(`DELETE FROM passes WHERE id = ?`), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	for _, table := range passTables {
+		if _, err := tx.Exec(s.q(`DELETE FROM `+table+` WHERE pass_id = ?`), id); err != nil {
+			return err
+		}
+	}
+	if err := unlinkDeleted(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// passTables are the tables whose rows belong to a pass, by pass_id, and
+// go with it.
+var passTables = []string{"notifications", "pass_clients", "pass_history"}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanStoredPass(row rowScanner) (*StoredPass, error) {
+	var (
+		sp                   StoredPass
+		data                 string
+		createdAt, updatedAt int64
+	)
+	if err := row.Scan(&sp.ID, &sp.Source, &data, &createdAt, &updatedAt, &sp.Artifact, &sp.LinkedPassID); err != nil {
+		return nil, err
+	}
+	sp.Pass = &bcbp.UnifiedBoardingPass{}
+	if err := json.Unmarshal([]byte(data), sp.Pass); err != nil {
+		return nil, fmt.Errorf("decoding stored pass %s: %w", sp.ID, err)
+	}
+	sp.CreatedAt = time.UnixMilli(createdAt).UTC()
+	sp.UpdatedAt = time.UnixMilli(updatedAt).UTC()
+	return &sp, nil
+}
+
+// ----------------------
+// SQL STORE: HISTORY AND RESTORE
+// ----------------------
+
+// addRevision records the changes an update of the pass id made at now.
+func (s *sqlStore) addRevision(tx *sql.Tx, id string, now int64, changes []bcbp.FieldChange) error {
+	b, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(s.q(`INSERT INTO pass_history (pass_id, changed_at, changes) VALUES (?, ?, ?)`), id, now, string(b))
+	return err
+}
+
+func (s *sqlStore) History(id string) (*PassHistory, error) {
+	var createdAt int64
+	err := s.db.QueryRow(s.q(`SELECT created_at FROM passes WHERE id = ?`), id).Scan(&createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	h := &PassHistory{ID: id, Revisions: []PassRevision{{
+		Revision: 1,
+		StoredAt: time.UnixMilli(createdAt).UTC(),
+		Changes:  []bcbp.FieldChange{},
+	}}}
+
+	rows, err := s.db.Query(s.q(`SELECT changed_at, changes FROM pass_history WHERE pass_id = ? ORDER BY id`), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			changedAt int64
+			data      string
+		)
+		if err := rows.Scan(&changedAt, &data); err != nil {
+			return nil, err
+		}
+		rev := PassRevision{Revision: len(h.Revisions) + 1, StoredAt: time.UnixMilli(changedAt).UTC()}
+		if err := json.Unmarshal([]byte(data), &rev.Changes); err != nil {
+			return nil, fmt.Errorf("decoding revision %d of pass %s: %w", rev.Revision, id, err)
+		}
+		h.Revisions = append(h.Revisions, rev)
+	}
+	return h, rows.Err()
+}
+
+// sqlRestore is Restore on a transaction of s.
+type sqlRestore struct {
+	s  *sqlStore
+	tx *sql.Tx
+}
+
+func (s *sqlStore) Restore() (Restore, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqlRestore{s, tx}, nil
+}
+
+func (r *sqlRestore) Merge(sp *StoredPass) (RestoreOutcome, error) {
+	id := ID(sp.Pass)
+	now := time.Now().UTC()
+	if sp.CreatedAt.IsZero() {
+		sp.CreatedAt = now
+	}
+	if sp.UpdatedAt.IsZero() {
+		sp.UpdatedAt = sp.CreatedAt
+	}
+	if sp.Source == "" {
+		sp.Source = string(sp.Pass.Source)
+	}
+
+	var (
+		outcome              RestoreOutcome
+		createdAt, updatedAt int64
+		linked               string
+	)
+	err := r.tx.QueryRow(r.s.q(`SELECT created_at, updated_at, linked_pass_id FROM passes WHERE id = ?`), id).Scan(&createdAt, &updatedAt, &linked)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		outcome = RestoreCreated
+		createdAt = sp.CreatedAt.UnixMilli()
+	case err != nil:
+		return 0, err
+	case updatedAt >= sp.UpdatedAt.UnixMilli():
+		return RestoreSkipped, nil
+	default:
+		outcome = RestoreUpdated
+		createdAt = min(createdAt, sp.CreatedAt.UnixMilli())
+	}
+
+	data, err := marshalForStorage(sp.Pass)
+	if err != nil {
+		return 0, err
+	}
+	// The backed-up copy replaces the whole row, uploads and web service
+	// references included, but not its infant link, which the pass at the
+	// other end still holds.
+	if _, err := r.tx.Exec(r.s.q(`DELETE FROM passes WHERE id = ?`), id); err != nil {
+		return 0, err
+	}
+	c := searchColumns(sp.Pass)
+	_, err = r.tx.Exec(r.s.q(`
+		INSERT INTO passes (id, source, data, created_at, updated_at,
+			passenger, pnr, flight_number, departure, arrival, date_iso, linked_pass_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		id, sp.Source, string(data), createdAt, sp.UpdatedAt.UnixMilli(),
+		c.passenger, c.pnr, c.flight, c.departure, c.arrival, c.dateISO, linked)
+	if err != nil {
+		return 0, err
+	}
+	return outcome, r.s.linkInfants(r.tx, c.pnr)
+}
+
+func (r *sqlRestore) Commit() error {
+	return r.tx.Commit()
+}
+
+func (r *sqlRestore) Rollback() error {
+	err := r.tx.Rollback()
+	if errors.Is(err, sql.ErrTxDone) {
+		return nil
+	}
+	return err
+}
+
+// ----------------------
+// SQL STORE: INFANT LINKS
+// ----------------------
+
+// linkInfants links each unlinked lap infant's pass stored under pnr, a
+// normalized PNR, with the unlinked pass of the adult it travels with
+// (see bcbp.AccompanyingAdult), both ways. Links already made are kept,
+// so storing another pass of the booking doesn't move them.
+func (s *sqlStore) linkInfants(tx *sql.Tx, pnr string) error {
+	if pnr == "" {
+		return nil
+	}
+	rows, err := tx.Query(s.q(`SELECT id, data FROM passes WHERE pnr = ? AND linked_pass_id = '' ORDER BY created_at, id`), pnr)
+	if err != nil {
+		return err
+	}
+	var (
+		infantIDs, adultIDs []string
+		infants, adults     []*bcbp.UnifiedBoardingPass
+	)
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		p := &bcbp.UnifiedBoardingPass{}
+		if err := json.Unmarshal([]byte(data), p); err != nil {
+			rows.Close()
+			return fmt.Errorf("decoding stored pass %s: %w", id, err)
+		}
+		if p.PassengerType == bcbp.PassengerInfant {
+			infantIDs, infants = append(infantIDs, id), append(infants, p)
+		} else {
+			adultIDs, adults = append(adultIDs, id), append(adults, p)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i, infant := range infants {
+		j := bcbp.AccompanyingAdult(infant, adults)
+		if j < 0 {
+			continue
+		}
+		for _, link := range [][2]string{{infantIDs[i], adultIDs[j]}, {adultIDs[j], infantIDs[i]}} {
+			if _, err := tx.Exec(s.q(`UPDATE passes SET linked_pass_id = ? WHERE id = ?`), link[1], link[0]); err != nil {
+				return err
+			}
+		}
+		adultIDs, adults = slices.Delete(adultIDs, j, j+1), slices.Delete(adults, j, j+1)
+	}
+	return nil
+}
+
+// unlinkDeleted clears the links to passes tx deleted.
+func unlinkDeleted(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		UPDATE passes SET linked_pass_id = ''
+		WHERE linked_pass_id != '' AND linked_pass_id NOT IN (SELECT id FROM passes)`)
+	return err
+}
+
+// ----------------------
+// SQL STORE: NOTIFICATIONS, CLIENTS, RETENTION, IMPORTS, STATISTICS
+// ----------------------
+
+func (s *sqlStore) AddNotification(passID, token string, lead time.Duration, sendAt time.Time) (*Notification, error) {
+	now := time.Now().UTC().UnixMilli()
+	_, err := s.db.Exec(s.q(`
+		INSERT INTO notifications (pass_id, token, lead_seconds, send_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(pass_id, token, lead_seconds) DO UPDATE SET
+			send_at = excluded.send_at,
+			claimed_at = CASE WHEN notifications.send_at = excluded.send_at THEN notifications.claimed_at END,
+			sent_at = CASE WHEN notifications.send_at = excluded.send_at THEN notifications.sent_at END`),
+		passID, token, int64(lead/time.Second), sendAt.UTC().UnixMilli(), now)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Notification{PassID: passID, LeadSeconds: int64(lead / time.Second), SendAt: sendAt.UTC(), Token: token}
+	err = s.db.QueryRow(s.q(`SELECT id FROM notifications WHERE pass_id = ? AND token = ? AND lead_seconds = ?`),
+		passID, token, n.LeadSeconds).Scan(&n.ID)
+	return n, err
+}
+
+func (s *sqlStore) ClaimDueNotifications(now time.Time, limit int) ([]*Notification, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(s.q(`
+		SELECT id, pass_id, token, lead_seconds, send_at FROM notifications
+		WHERE claimed_at IS NULL AND send_at <= ?
+		ORDER BY send_at LIMIT ?`), now.UTC().UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	var due []*Notification
+	for rows.Next() {
+		n := &Notification{}
+		var sendAt int64
+		if err := rows.Scan(&n.ID, &n.PassID, &n.Token, &n.LeadSeconds, &sendAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		n.SendAt = time.UnixMilli(sendAt).UTC()
+		due = append(due, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Another server sharing a Postgres database may have claimed some in
+	// the meantime; only those this update claims are returned.
+	claimed := due[:0]
+	for _, n := range due {
+		res, err := tx.Exec(s.q(`UPDATE notifications SET claimed_at = ? WHERE id = ? AND claimed_at IS NULL`), now.UTC().UnixMilli(), n.ID)
+		if err != nil {
+			return nil, err
+		}
+		if k, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if k > 0 {
+			claimed = append(claimed, n)
+		}
+	}
+	return claimed, tx.Commit()
+}
+
+func (s *sqlStore) MarkNotification(id int64, sendErr error) error {
+	msg := ""
+	if sendErr != nil {
+		msg = sendErr.Error()
+	}
+	_, err := s.db.Exec(s.q(`UPDATE notifications SET sent_at = ?, error = ? WHERE id = ?`),
+		time.Now().UTC().UnixMilli(), msg, id)
+	return err
+}
+
+func (s *sqlStore) TagClient(id, client string) error {
+	_, err := s.db.Exec(s.q(`
+		INSERT INTO pass_clients (client_id, pass_id, scanned_at) VALUES (?, ?, ?)
+		ON CONFLICT(client_id, pass_id) DO UPDATE SET scanned_at = excluded.scanned_at`),
+		client, id, time.Now().UTC().UnixMilli())
+	return err
+}
+
+func (s *sqlStore) ForgetClient(client string) (deleted, kept int, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	var ids []any
+	rows, err := tx.Query(s.q(`SELECT pass_id FROM pass_clients WHERE client_id = ?`), client)
+	if err != nil {
+		return 0, 0, err
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(ids) == 0 {
+		return 0, 0, err
+	}
+
+	if _, err := tx.Exec(s.q(`DELETE FROM pass_clients WHERE client_id = ?`), client); err != nil {
+		return 0, 0, err
+	}
+	orphans := ` WHERE id IN ` + placeholders(len(ids)) + ` AND id NOT IN (SELECT pass_id FROM pass_clients)`
+	for _, table := range []string{"notifications", "pass_history"} {
+		if _, err := tx.Exec(s.q(`DELETE FROM `+table+` WHERE pass_id IN (SELECT id FROM passes`+orphans+`)`), ids...); err != nil {
+			return 0, 0, err
+		}
+	}
+	res, err := tx.Exec(s.q(`DELETE FROM passes`+orphans), ids...)
+	if err != nil {
+		return 0, 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := unlinkDeleted(tx); err != nil {
+		return 0, 0, err
+	}
+	return int(n), len(ids) - int(n), tx.Commit()
+}
+
+// placeholders is a parenthesized list of n placeholders, for IN.
+func placeholders(n int) string {
+	return "(" + strings.TrimSuffix(strings.Repeat("?,", n), ",") + ")"
+}
+
+func (s *sqlStore) DeleteExpired(ttl time.Duration, now time.Time) (int, error) {
+	cutoff := now.Add(-ttl).UTC()
+	total := 0
+	for {
+		n, err := s.deleteExpiredBatch(cutoff.Format(time.DateOnly), cutoff.UnixMilli())
+		total += n
+		if err != nil || n < cleanupBatch {
+			return total, err
+		}
+	}
+}
+
+func (s *sqlStore) deleteExpiredBatch(cutoffDate string, cutoffMillis int64) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(s.q(`
+		SELECT id FROM passes
+		WHERE (date_iso != '' AND date_iso < ?) OR (date_iso = '' AND created_at < ?)
+		LIMIT ?`), cutoffDate, cutoffMillis, cleanupBatch)
+	if err != nil {
+		return 0, err
+	}
+	var ids []any
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	in := placeholders(len(ids))
+	for _, table := range passTables {
+		if _, err := tx.Exec(s.q(`DELETE FROM `+table+` WHERE pass_id IN `+in), ids...); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := tx.Exec(s.q(`DELETE FROM passes WHERE id IN `+in), ids...); err != nil {
+		return 0, err
+	}
+	if err := unlinkDeleted(tx); err != nil {
+		return 0, err
+	}
+	return len(ids), tx.Commit()
+}
+
+func (s *sqlStore) ImportedPass(sum string) (string, error) {
+	var id string
+	err := s.db.QueryRow(s.q(`SELECT pass_id FROM imports WHERE sum = ?`), sum).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return id, err
+}
+
+func (s *sqlStore) MarkImported(sum, passID string) error {
+	_, err := s.db.Exec(s.q(`
+		INSERT INTO imports (sum, pass_id, imported_at) VALUES (?, ?, ?)
+		ON CONFLICT (sum) DO UPDATE SET pass_id = excluded.pass_id, imported_at = excluded.imported_at`),
+		sum, passID, time.Now().UnixMilli())
+	return err
+}
+
+func (s *sqlStore) AddStats(counts map[StatsKey]int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(s.q(`
+		INSERT INTO parse_stats (hour, source, carrier, error, count) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (hour, source, carrier, error) DO UPDATE SET count = parse_stats.count + excluded.count`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for k, n := range counts {
+		if _, err := stmt.Exec(k.Hour, k.Source, k.Carrier, k.Err, n); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) Stats(from, to time.Time) (map[StatsKey]int64, error) {
+	rows, err := s.db.Query(s.q(`
+		SELECT hour, source, carrier, error, count FROM parse_stats
+		WHERE hour >= ? AND hour < ?`), from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[StatsKey]int64{}
+	for rows.Next() {
+		var k StatsKey
+		var n int64
+		if err := rows.Scan(&k.Hour, &k.Source, &k.Carrier, &k.Err, &n); err != nil {
+			return nil, err
+		}
+		out[k] = n
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// ----------------------
+// SQLITE
+// ----------------------
+
+// sqlite records the schema version in PRAGMA user_version, as it did
+// before migrations moved here, so existing databases upgrade in place.
+var sqlite = &dialect{
+	name: "sqlite",
+	steps: map[int]func(*sql.Tx) error{
+		2: backfillPassColumns,
+		4: rekeyPasses,
+	},
+	version: func(tx *sql.Tx) (int, error) {
+		var v int
+		err := tx.QueryRow(`PRAGMA user_version`).Scan(&v)
+		return v, err
+	},
+	setVersion: func(tx *sql.Tx, v int) error {
+		// PRAGMA does not accept bound parameters.
+		_, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, v))
+		return err
+	},
+}
+
+func openSQLite(path string) (Store, error) {
+	// Transactions BEGIN IMMEDIATE, taking the write lock before their first
+	// read, so a read-then-write such as Upsert can't interleave with another
+	// process writing the same file.
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite", path+sep+"_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only allows one writer; a single connection avoids "database is locked".
+	db.SetMaxOpenConns(1)
+
+	if err := sqlite.migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating schema: %w", err)
+	}
+	return &sqlStore{db: db, dialect: sqlite}, nil
+}
+
+// backfillPassColumns fills the searchable columns of passes stored before
+// they existed from the stored JSON.
+func backfillPassColumns(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, data FROM passes`)
+	if err != nil {
+		return err
+	}
+	passes := map[string]*bcbp.UnifiedBoardingPass{}
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		p := &bcbp.UnifiedBoardingPass{}
+		if err := json.Unmarshal([]byte(data), p); err != nil {
+			rows.Close()
+			return fmt.Errorf("decoding stored pass %s: %w", id, err)
+		}
+		passes[id] = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, p := range passes {
+		c := searchColumns(p)
+		if _, err := tx.Exec(`
+			UPDATE passes SET passenger = ?, pnr = ?, flight_number = ?, departure = ?, arrival = ?, date_iso = ?
+			WHERE id = ?`,
+			c.passenger, c.pnr, c.flight, c.departure, c.arrival, c.dateISO, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rekeyPasses moves every pass to its bcbp.StableID, along with its
+// notifications. Passes without enough fields for a stable ID keep theirs.
+// Two old records that now share an ID are merged, keeping the newer one.
+func rekeyPasses(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, data FROM passes ORDER BY updated_at DESC`)
+	if err != nil {
+		return err
+	}
+	type rekey struct {
+		oldID, newID string
+		data         []byte
+	}
+	var moves []rekey
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		p := &bcbp.UnifiedBoardingPass{}
+		if err := json.Unmarshal([]byte(data), p); err != nil {
+			rows.Close()
+			return fmt.Errorf("decoding stored pass %s: %w", id, err)
+		}
+		newID, ok := bcbp.StableID(p)
+		if !ok || newID == id {
+			continue
+		}
+		p.ID = newID
+		b, err := json.Marshal(p)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		moves = append(moves, rekey{id, newID, b})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range moves {
+		var exists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM passes WHERE id = ?`, m.newID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists > 0 {
+			// Newest first, so the record already there is the newer one.
+			if _, err := tx.Exec(`DELETE FROM passes WHERE id = ?`, m.oldID); err != nil {
+				return err
+			}
+		} else if _, err := tx.Exec(`UPDATE passes SET id = ?, data = ? WHERE id = ?`, m.newID, string(m.data), m.oldID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE OR IGNORE notifications SET pass_id = ? WHERE pass_id = ?`, m.newID, m.oldID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM notifications WHERE pass_id = ?`, m.oldID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+func openTestSQLite(t *testing.T) Store {
+	t.Helper()
+	s, err := Open("sqlite:" + filepath.Join(t.TempDir(), "passes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteStore(t *testing.T) {
+	testStore(t, openTestSQLite)
+}
+
+// TestSQLiteRekey stores passes under IDs from before bcbp.StableID, in a
+// database at schema version 3, and checks that opening it moves them,
+// with their notifications, and merges two that now share an ID.
+func TestSQLiteRekey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passes.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := sqlite.migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range ms[:3] {
+		if err := sqlite.apply(db, m, len(ms)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert := func(id string, p *bcbp.UnifiedBoardingPass, updated int64) {
+		t.Helper()
+		data, err := marshalForStorage(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(`INSERT INTO passes (id, source, data, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+			id, string(p.Source), string(data), updated, updated); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := testPass("XYZ987", "SILVA/JOAO", "576", "2026-10-27", "012C")
+	insert("old-1", p, 1)
+	// The same pass under another old ID, stored later with a new seat.
+	insert("old-2", testPass("XYZ987", "SILVA/JOAO MR", "0576", "2026-10-27", "020A"), 2)
+	if _, err := db.Exec(`INSERT INTO notifications (pass_id, token, lead_seconds, send_at, created_at) VALUES ('old-1', 'token', 3600, 0, 0)`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := Open("sqlite:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	id, _ := bcbp.StableID(p)
+	sp, err := s.Get(id)
+	if err != nil {
+		t.Fatalf("pass not under its stable ID: %v", err)
+	}
+	if sp.Pass.ID != id || sp.Pass.Seat != "020A" {
+		t.Errorf("re-keyed pass has id %s, seat %s; want %s and the newer seat 020A", sp.Pass.ID, sp.Pass.Seat, id)
+	}
+	for _, old := range []string{"old-1", "old-2"} {
+		if _, err := s.Get(old); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: err %v, want ErrNotFound", old, err)
+		}
+	}
+	if all, _ := s.ListAll(PassFilter{}); len(all) != 1 {
+		t.Errorf("%d passes, want the two merged into 1", len(all))
+	}
+	if due, err := s.ClaimDueNotifications(sp.CreatedAt, 10); err != nil || len(due) != 1 || due[0].PassID != id {
+		t.Errorf("notifications %+v, err %v; want the one moved to %s", due, err, id)
+	}
+}
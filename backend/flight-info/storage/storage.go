@@ -0,0 +1,231 @@
+// Package storage persists parsed passes and what is kept with them:
+// notification registrations, client tags, revisions, import records and
+// parse statistics. Store is what the API needs of a backend; Open picks
+// one by the scheme of a database URL, SQLite (sqlite:) or Postgres
+// (postgres:). Both run on database/sql, with the same queries and their
+// own versioned migrations (see migrate.go).
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// ErrNotFound is returned for a pass, or an import record, that isn't
+// stored.
+var ErrNotFound = errors.New("pass not found")
+
+// Store is a pass store. Implementations are safe for concurrent use.
+type Store interface {
+	// Upsert stores the pass under its ID, replacing an earlier version
+	// while keeping its created_at. changes is what p changed in that
+	// version (see bcbp.Diff), kept in the pass's history when not empty;
+	// it is nil when there was no earlier version.
+	Upsert(p *bcbp.UnifiedBoardingPass) (sp *StoredPass, changes []bcbp.FieldChange, err error)
+	// InsertIfAbsent stores the pass unless one with the same ID exists,
+	// in which case the existing record is returned untouched and
+	// inserted is false.
+	InsertIfAbsent(p *bcbp.UnifiedBoardingPass) (sp *StoredPass, inserted bool, err error)
+	// Get returns the stored pass with the given ID, or ErrNotFound.
+	Get(id string) (*StoredPass, error)
+	// Delete removes the pass together with its notification
+	// registrations, client tags and history.
+	Delete(id string) error
+	// List returns the stored passes matching the filter, newest first,
+	// along with the total number of matches ignoring limit and offset.
+	List(f PassFilter, limit, offset int) ([]*StoredPass, int, error)
+	// ListAll returns every stored pass matching the filter, newest first.
+	ListAll(f PassFilter) ([]*StoredPass, error)
+	// Each calls fn for every stored pass matching the filter, newest
+	// first, a page at a time, so large exports neither sit in memory nor
+	// hold a connection while fn writes to a slow client. It stops at the
+	// first error from fn.
+	Each(f PassFilter, fn func(*StoredPass) error) error
+	// History returns the revisions of the stored pass with the given ID,
+	// or ErrNotFound.
+	History(id string) (*PassHistory, error)
+	// Restore starts merging a backup in one transaction.
+	Restore() (Restore, error)
+
+	// SetArtifact references the stored upload sum, of the given content
+	// type, from the pass with the given ID.
+	SetArtifact(id, sum, contentType string) error
+	// Artifact returns the stored upload the pass with the given ID
+	// references, "" for none, or ErrNotFound.
+	Artifact(id string) (sum, contentType string, err error)
+	// ArtifactRefs returns the set of stored uploads passes reference.
+	ArtifactRefs() (map[string]bool, error)
+	// SetWebService stores sealed, the pass's sealed web service, and
+	// modified, the Last-Modified of the version stored, on the pass with
+	// the given ID. Unless replace is set, a web service already stored
+	// is kept.
+	SetWebService(id, sealed, modified string, replace bool) error
+	// WebService returns the sealed web service of the pass with the
+	// given ID and the Last-Modified of its stored version, "" for none,
+	// or ErrNotFound.
+	WebService(id string) (sealed, modified string, err error)
+
+	// AddNotification registers a token for a pass. Registering the same
+	// token and lead time again only reschedules it (and re-arms it if the
+	// pass changed), so clients can call this idempotently.
+	AddNotification(passID, token string, lead time.Duration, sendAt time.Time) (*Notification, error)
+	// ClaimDueNotifications marks up to limit notifications due at now as
+	// claimed before anything is sent, and returns them. A crash after
+	// claiming loses at most that batch instead of re-sending it on
+	// restart: duplicate pushes are worse than a missed one.
+	ClaimDueNotifications(now time.Time, limit int) ([]*Notification, error)
+	// MarkNotification records that a claimed notification was sent, or
+	// failed with sendErr.
+	MarkNotification(id int64, sendErr error) error
+
+	// TagClient records that client scanned the pass with the given ID,
+	// now.
+	TagClient(id, client string) error
+	// ForgetClient removes client's history: its tags, and the passes no
+	// other client scanned, with their notifications and revisions. Passes
+	// in another client's history stay, untagged from this one. It returns
+	// how many of each.
+	ForgetClient(client string) (deleted, kept int, err error)
+
+	// DeleteExpired removes, in batches, every pass whose flight date is
+	// more than ttl before now, together with its notifications and
+	// history. Passes without a resolved date expire ttl after they were
+	// first stored. A flight day counts until its end (UTC): with a 168h
+	// ttl, a pass for a flight on the 1st is deleted from the 9th.
+	DeleteExpired(ttl time.Duration, now time.Time) (int, error)
+
+	// ImportedPass returns the ID of the pass imported from content with
+	// the given SHA-256, or ErrNotFound.
+	ImportedPass(sum string) (string, error)
+	// MarkImported records that content with the given SHA-256 was
+	// imported as the pass with the given ID.
+	MarkImported(sum, passID string) error
+
+	// AddStats adds counts to the parse statistics.
+	AddStats(counts map[StatsKey]int64) error
+	// Stats returns the counts of the hours from from up to, not
+	// including, to.
+	Stats(from, to time.Time) (map[StatsKey]int64, error)
+
+	// Close closes the database.
+	Close() error
+}
+
+// StoredPass is a pass as stored, with its storage timestamps.
+type StoredPass struct {
+	ID        string                    `json:"id"`
+	Source    string                    `json:"source"`
+	CreatedAt time.Time                 `json:"created_at"`
+	UpdatedAt time.Time                 `json:"updated_at"`
+	Pass      *bcbp.UnifiedBoardingPass `json:"pass"`
+	// Artifact is the SHA-256 of the upload the pass was parsed from, when
+	// it was kept.
+	Artifact string `json:"artifact,omitempty"`
+	// LinkedPassID links a lap infant's pass and the pass of the adult it
+	// travels with, on both: stored passes with the same PNR and flight
+	// are linked as they are stored (see bcbp.AccompanyingAdult).
+	LinkedPassID string `json:"linked_pass_id,omitempty"`
+}
+
+// PassFilter narrows List. Empty fields match everything; DateFrom and
+// DateTo are inclusive ISO dates compared against the resolved flight date.
+type PassFilter struct {
+	Passenger string // case-insensitive substring
+	PNR       string
+	Flight    string
+	Departure string
+	Arrival   string
+	DateFrom  string
+	DateTo    string
+	Source    string
+	Client    string // only passes this client scanned (see TagClient)
+}
+
+// Notification is a registered push reminder for a stored pass.
+type Notification struct {
+	ID          int64     `json:"id"`
+	PassID      string    `json:"pass_id"`
+	LeadSeconds int64     `json:"lead_seconds"`
+	SendAt      time.Time `json:"send_at"`
+	// Token is the device's push token, never sent back.
+	Token string `json:"-"`
+}
+
+// StatsKey is one row of the parse statistics.
+type StatsKey struct {
+	Hour    int64 // Unix seconds at the start of the UTC hour
+	Source  string
+	Carrier string
+	Err     string // "" for a parse that succeeded
+}
+
+// PassHistory is every version of a stored pass.
+type PassHistory struct {
+	ID string `json:"id"`
+	// Revisions starts with the version first stored, which has no
+	// changes, then has one per update that changed a field, oldest first.
+	Revisions []PassRevision `json:"revisions"`
+}
+
+// PassRevision is one version of a stored pass.
+type PassRevision struct {
+	Revision int                `json:"revision"`
+	StoredAt time.Time          `json:"stored_at"`
+	Changes  []bcbp.FieldChange `json:"changes"`
+}
+
+// Restore merges backed-up passes into the store in one transaction:
+// either every pass is merged or none is.
+type Restore interface {
+	// Merge stores sp, matched by ID, unless the stored copy has the same
+	// or a later updated_at, and says which it did. Missing timestamps
+	// and source are filled in from the pass.
+	Merge(sp *StoredPass) (RestoreOutcome, error)
+	Commit() error
+	// Rollback undoes the merges; after Commit it does nothing.
+	Rollback() error
+}
+
+// RestoreOutcome is what Restore.Merge did with a pass.
+type RestoreOutcome int
+
+const (
+	RestoreCreated RestoreOutcome = iota // the pass wasn't stored
+	RestoreUpdated                       // the backup had a newer copy
+	RestoreSkipped                       // the stored copy was as new or newer
+)
+
+// ID returns the pass's storage ID, bcbp.StableID, deriving it on first
+// use. Redacted passes already carry the ID of their unmasked data.
+func ID(p *bcbp.UnifiedBoardingPass) string {
+	if p.ID == "" {
+		p.ID, _ = bcbp.StableID(p)
+	}
+	return p.ID
+}
+
+// Open opens the store databaseURL names and brings its schema up to date:
+//
+//	sqlite:PATH, sqlite://PATH     a SQLite file
+//	postgres://..., postgresql://  a Postgres database, in the driver's URL syntax
+func Open(databaseURL string) (Store, error) {
+	scheme, rest, ok := strings.Cut(databaseURL, ":")
+	if !ok {
+		return nil, fmt.Errorf("database URL %q has no scheme: use sqlite: or postgres:", databaseURL)
+	}
+	switch strings.ToLower(scheme) {
+	case "sqlite":
+		path := strings.TrimPrefix(rest, "//")
+		if path == "" {
+			return nil, errors.New("sqlite: database URL has no path")
+		}
+		return openSQLite(path)
+	case "postgres", "postgresql":
+		return openPostgres(databaseURL)
+	}
+	return nil, fmt.Errorf("unsupported database URL scheme %q: use sqlite: or postgres:", scheme)
+}
@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"bugsbyte/flight-info/bcbp"
+)
+
+// ----------------------
+// STORE CONTRACT
+// ----------------------
+
+// testStore runs the behaviour every backend must share against the
+// stores open returns, a fresh, empty one per call.
+
+func testPass(pnr, name, flight, date, seat string) *bcbp.UnifiedBoardingPass {
+	return &bcbp.UnifiedBoardingPass{
+		Source: bcbp.SourceBarcode, PNR: pnr, PassengerName: name,
+		Carrier: "TP", FlightNumber: flight, DateISO: date,
+		Departure: "LIS", Arrival: "FRA", Seat: seat,
+	}
+}
+
+func testStore(t *testing.T, open func(t *testing.T) Store) {
+	t.Run("InsertIfAbsent", func(t *testing.T) {
+		s := open(t)
+		p := testPass("XYZ987", "SILVA/JOAO", "576", "2026-10-27", "012C")
+		sp, inserted, err := s.InsertIfAbsent(p)
+		if err != nil || !inserted {
+			t.Fatalf("first insert: inserted %v, err %v", inserted, err)
+		}
+		if want, _ := bcbp.StableID(p); sp.ID != want {
+			t.Errorf("id %s, want bcbp.StableID %s", sp.ID, want)
+		}
+		sp, inserted, err = s.InsertIfAbsent(testPass("XYZ987", "SILVA/JOAO", "576", "2026-10-27", "020A"))
+		if err != nil || inserted {
+			t.Fatalf("second insert: inserted %v, err %v", inserted, err)
+		}
+		if sp.Pass.Seat != "012C" {
+			t.Errorf("stored seat %q, want the first version's", sp.Pass.Seat)
+		}
+	})
+
+	t.Run("UpsertHistory", func(t *testing.T) {
+		s := open(t)
+		p := testPass("XYZ987", "SILVA/JOAO", "576", "2026-10-27", "012C")
+		first, changes, err := s.Upsert(p)
+		if err != nil || changes != nil {
+			t.Fatalf("first upsert: changes %v, err %v", changes, err)
+		}
+		p2 := testPass("XYZ987", "SILVA/JOAO", "576", "2026-10-27", "020A")
+		sp, changes, err := s.Upsert(p2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []bcbp.FieldChange{{Field: "seat", Old: "012C", New: "020A"}}
+		if !slices.Equal(changes, want) {
+			t.Errorf("changes %v, want %v", changes, want)
+		}
+		if !sp.CreatedAt.Equal(first.CreatedAt) || sp.Pass.Seat != "020A" {
+			t.Errorf("updated record created %v, seat %s; want created %v, seat 020A", sp.CreatedAt, sp.Pass.Seat, first.CreatedAt)
+		}
+		// Case and spacing aren't a change.
+		if _, changes, err := s.Upsert(testPass("XYZ987", "SILVA/JOAO", "576", "2026-10-27", " 020a")); err != nil || len(changes) != 0 {
+			t.Errorf("normalized upsert: changes %v, err %v", changes, err)
+		}
+
+		h, err := s.History(sp.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(h.Revisions) != 2 || len(h.Revisions[0].Changes) != 0 || !slices.Equal(h.Revisions[1].Changes, want) {
+			t.Errorf("history %+v, want the first version and the seat change", h.Revisions)
+		}
+		if _, err := s.History("missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("history of a missing pass: err %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("UpsertConcurrent", func(t *testing.T) {
+		// Each write's changes are against the version it replaced, so the
+		// history is one unbroken chain of seats.
+		s := open(t)
+		const writers = 8
+		var (
+			wg    sync.WaitGroup
+			first = make(chan string, writers)
+			id    = ID(testPass("XYZ987", "SILVA/JOAO", "576", "2026-10-27", ""))
+		)
+		for i := range writers {
+			seat := fmt.Sprintf("%03dA", i+1)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, changes, err := s.Upsert(testPass("XYZ987", "SILVA/JOAO", "576", "2026-10-27", seat))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if changes == nil {
+					first <- seat
+				}
+			}()
+		}
+		wg.Wait()
+		close(first)
+		if t.Failed() {
+			return
+		}
+		if len(first) != 1 {
+			t.Fatalf("%d writes found no stored pass, want 1", len(first))
+		}
+
+		h, err := s.History(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(h.Revisions) != writers {
+			t.Fatalf("%d revisions, want %d", len(h.Revisions), writers)
+		}
+		seat := <-first
+		for i, rev := range h.Revisions[1:] {
+			if len(rev.Changes) != 1 || rev.Changes[0].Field != "seat" || rev.Changes[0].Old != seat {
+				t.Fatalf("revision %d: changes %v, want the seat from %s", i+1, rev.Changes, seat)
+			}
+			seat = rev.Changes[0].New
+		}
+	})
+
+	t.Run("ListAndDelete", func(t *testing.T) {
+		s := open(t)
+		a, _, _ := s.Upsert(testPass("XYZ987", "SILVA/JOAO", "576", "2026-10-27", ""))
+		s.Upsert(testPass("ABC123", "COSTA/ANA", "1234", "2026-11-02", ""))
+		if err := s.TagClient(a.ID, "kiosk-1"); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tc := range []struct {
+			f    PassFilter
+			want int
+		}{
+			{PassFilter{}, 2},
+			{PassFilter{Passenger: "silva"}, 1},
+			{PassFilter{PNR: "abc123"}, 1},
+			{PassFilter{DateFrom: "2026-11-01"}, 1},
+			{PassFilter{Client: "kiosk-1"}, 1},
+			{PassFilter{Arrival: "NRT"}, 0},
+		} {
+			got, total, err := s.List(tc.f, 10, 0)
+			if err != nil || total != tc.want || len(got) != tc.want {
+				t.Errorf("List(%+v): %d of %d, err %v; want %d", tc.f, len(got), total, err, tc.want)
+			}
+		}
+
+		if err := s.Delete(a.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Get(a.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("get after delete: err %v, want ErrNotFound", err)
+		}
+		if err := s.Delete(a.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("second delete: err %v, want ErrNotFound", err)
+		}
+		if got, _, _ := s.List(PassFilter{Client: "kiosk-1"}, 10, 0); len(got) != 0 {
+			t.Errorf("deleted pass still listed for its client")
+		}
+	})
+
+	t.Run("ForgetClient", func(t *testing.T) {
+		s := open(t)
+		only, _, _ := s.Upsert(testPass("XYZ987", "SILVA/JOAO", "576", "2026-10-27", ""))
+		shared, _, _ := s.Upsert(testPass("ABC123", "COSTA/ANA", "1234", "2026-11-02", ""))
+		s.TagClient(only.ID, "a")
+		s.TagClient(shared.ID, "a")
+		s.TagClient(shared.ID, "b")
+		deleted, kept, err := s.ForgetClient("a")
+		if err != nil || deleted != 1 || kept != 1 {
+			t.Fatalf("deleted %d, kept %d, err %v; want 1 and 1", deleted, kept, err)
+		}
+		if _, err := s.Get(shared.ID); err != nil {
+			t.Errorf("pass b scanned too: %v", err)
+		}
+	})
+
+	t.Run("Notifications", func(t *testing.T) {
+		s := open(t)
+		sp, _, _ := s.Upsert(testPass("XYZ987", "SILVA/JOAO", "576", "2026-10-27", ""))
+		now := time.Date(2026, time.October, 27, 6, 0, 0, 0, time.UTC)
+		n, err := s.AddNotification(sp.ID, "token", time.Hour, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.AddNotification(sp.ID, "token", time.Hour, now.Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if due, err := s.ClaimDueNotifications(now, 10); err != nil || len(due) != 0 {
+			t.Fatalf("before the rescheduled time: %d due, err %v", len(due), err)
+		}
+		due, err := s.ClaimDueNotifications(now.Add(time.Hour), 10)
+		if err != nil || len(due) != 1 || due[0].ID != n.ID || due[0].Token != "token" {
+			t.Fatalf("claimed %+v, err %v; want the one registration", due, err)
+		}
+		if again, _ := s.ClaimDueNotifications(now.Add(2*time.Hour), 10); len(again) != 0 {
+			t.Errorf("claimed twice")
+		}
+		if err := s.MarkNotification(n.ID, nil); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("DeleteExpired", func(t *testing.T) {
+		s := open(t)
+		old, _, _ := s.Upsert(testPass("XYZ987", "SILVA/JOAO", "576", "2026-10-01", ""))
+		recent, _, _ := s.Upsert(testPass("ABC123", "COSTA/ANA", "1234", "2026-10-20", ""))
+		now := time.Date(2026, time.October, 27, 12, 0, 0, 0, time.UTC)
+		if n, err := s.DeleteExpired(7*24*time.Hour, now); err != nil || n != 1 {
+			t.Fatalf("deleted %d, err %v; want 1", n, err)
+		}
+		if _, err := s.Get(old.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expired pass still stored")
+		}
+		if _, err := s.Get(recent.ID); err != nil {
+			t.Errorf("recent pass: %v", err)
+		}
+	})
+
+	t.Run("ImportsAndArtifacts", func(t *testing.T) {
+		s := open(t)
+		sp, _, _ := s.Upsert(testPass("XYZ987", "SILVA/JOAO", "576", "2026-10-27", ""))
+		if _, err := s.ImportedPass("sum"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("unknown import: err %v, want ErrNotFound", err)
+		}
+		if err := s.MarkImported("sum", sp.ID); err != nil {
+			t.Fatal(err)
+		}
+		if id, err := s.ImportedPass("sum"); err != nil || id != sp.ID {
+			t.Errorf("imported pass %q, err %v; want %s", id, err, sp.ID)
+		}
+
+		if err := s.SetArtifact(sp.ID, "abc", "image/png"); err != nil {
+			t.Fatal(err)
+		}
+		if sum, ct, err := s.Artifact(sp.ID); err != nil || sum != "abc" || ct != "image/png" {
+			t.Errorf("artifact %q %q, err %v", sum, ct, err)
+		}
+		if refs, err := s.ArtifactRefs(); err != nil || !refs["abc"] {
+			t.Errorf("artifact refs %v, err %v", refs, err)
+		}
+		if err := s.SetArtifact("missing", "abc", "image/png"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("artifact on a missing pass: err %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		s := open(t)
+		hour := time.Date(2026, time.October, 27, 9, 0, 0, 0, time.UTC)
+		k := StatsKey{Hour: hour.Unix(), Source: "barcode", Carrier: "TP"}
+		for range 2 {
+			if err := s.AddStats(map[StatsKey]int64{k: 3}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := s.Stats(hour, hour.Add(time.Hour))
+		if err != nil || got[k] != 6 {
+			t.Errorf("stats %v, err %v; want %v: 6", got, err, k)
+		}
+		if got, _ := s.Stats(hour.Add(time.Hour), hour.Add(2*time.Hour)); len(got) != 0 {
+			t.Errorf("next hour has %v", got)
+		}
+	})
+}
@@ -275,8 +275,8 @@ export default function MainScreen() {
             });
 
             if (!parseResponse.ok) {
-                const errorText = await parseResponse.text();
-                throw new Error(errorText);
+                const err = await parseResponse.json().catch(() => null);
+                throw new Error(err?.error?.message || 'Failed to parse barcode');
             }
 
             const boardingPass = await parseResponse.json();